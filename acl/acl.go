@@ -0,0 +1,384 @@
+// Package acl controls who may talk to the agent and which commands they
+// may use.
+//
+// Lists allow or deny sender and chat IDs per channel, or on every channel
+// with the channel "*". Once a channel has an allowlist, only the senders or
+// chats on it get through. Senders have a role, guest, user, or admin, and
+// permissions require a minimum role for commands or route patterns. The
+// ACL is enforced as router middleware and managed at runtime with the
+// /acl command:
+//
+//	access, err := acl.New(acl.Config{
+//		Admins: []string{"telegram:123456"},
+//		Permissions: []acl.Permission{
+//			{Command: chatvars.CommandPrefix, Role: acl.RoleAdmin},
+//			{Pattern: channels.All(), Role: acl.RoleUser},
+//		},
+//		Sender: router,
+//	})
+//	router.Use(access.Middleware())
+//	router.AddHandler(channels.RouteHandler{
+//		Pattern:   channels.RoutePattern{Prefix: acl.CommandPrefix},
+//		Handler:   acl.Command(access, router),
+//		Priority:  100,
+//		Exclusive: true,
+//	})
+package acl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// AllChannels is the channel of entries that apply on every channel.
+const AllChannels = "*"
+
+// DefaultDeniedMessage is sent to senders lacking a permission.
+const DefaultDeniedMessage = "You don't have permission to do that."
+
+// Role is a sender's level of access.
+type Role string
+
+// Roles, from least to most privileged.
+const (
+	RoleGuest Role = "guest"
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// rank orders roles; unknown roles rank as guests.
+func (r Role) rank() int {
+	switch r {
+	case RoleUser:
+		return 1
+	case RoleAdmin:
+		return 2
+	}
+	return 0
+}
+
+// Allows reports whether the role has at least the access of required.
+func (r Role) Allows(required Role) bool {
+	return r.rank() >= required.rank()
+}
+
+// ParseRole parses a role name.
+func ParseRole(s string) (Role, bool) {
+	switch r := Role(strings.ToLower(s)); r {
+	case RoleGuest, RoleUser, RoleAdmin:
+		return r, true
+	}
+	return "", false
+}
+
+// List names an ACL list.
+type List string
+
+const (
+	ListAllowSenders List = "allow_senders"
+	ListDenySenders  List = "deny_senders"
+	ListAllowChats   List = "allow_chats"
+	ListDenyChats    List = "deny_chats"
+)
+
+// roleList is the list holding the senders with a role.
+func roleList(r Role) List {
+	return List("role_" + string(r))
+}
+
+// Store persists ACL lists. Entries are IDs on a list for a channel, or
+// for AllChannels.
+type Store interface {
+	// Contains reports whether id is on the list for channel.
+	Contains(ctx context.Context, list List, channel, id string) (bool, error)
+
+	// Members returns the IDs on the list for channel, sorted.
+	Members(ctx context.Context, list List, channel string) ([]string, error)
+
+	// Add puts id on the list for channel.
+	Add(ctx context.Context, list List, channel, id string) error
+
+	// Remove takes id off the list for channel. Removing a missing entry
+	// is not an error.
+	Remove(ctx context.Context, list List, channel, id string) error
+}
+
+// Permission requires a minimum role for the messages it matches.
+type Permission struct {
+	// Command matches a chat command, e.g. "/var".
+	Command string
+
+	// Pattern matches messages as a route pattern does. It is ignored when
+	// Command is set.
+	Pattern channels.RoutePattern
+
+	Role Role
+}
+
+// matches reports whether the permission applies to msg.
+func (p Permission) matches(msg channels.IncomingMessage) bool {
+	if p.Command != "" {
+		_, ok := channels.ParseCommand(msg.Content, p.Command)
+		return ok
+	}
+	return p.Pattern.Matches(msg)
+}
+
+// Config configures an ACL.
+type Config struct {
+	// Store persists the lists (default: in-memory).
+	Store Store
+
+	// Admins are senders with the admin role, as "<channel>:<sender>"
+	// with "*" for every channel. They are added to the store on New, so
+	// that someone can manage the ACL.
+	Admins []string
+
+	// DefaultRole is the role of senders without one (default: RoleUser).
+	DefaultRole Role
+
+	// Permissions are checked in order; the first one matching a message
+	// decides the role it requires. The /acl command always requires
+	// RoleAdmin.
+	Permissions []Permission
+
+	// Sender, if set, tells senders lacking a permission. Messages stopped
+	// by the lists are dropped silently.
+	Sender channels.Sender
+
+	// DeniedMessage is sent to senders lacking a permission (default:
+	// DefaultDeniedMessage).
+	DeniedMessage string
+
+	Logger *slog.Logger
+}
+
+func (c Config) withDefaults() Config {
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+	if c.DefaultRole == "" {
+		c.DefaultRole = RoleUser
+	}
+	if c.DeniedMessage == "" {
+		c.DeniedMessage = DefaultDeniedMessage
+	}
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	return c
+}
+
+// ACL enforces access lists, roles, and permissions.
+type ACL struct {
+	config Config
+}
+
+// New creates a new ACL.
+func New(config Config) (*ACL, error) {
+	config = config.withDefaults()
+	if _, ok := ParseRole(string(config.DefaultRole)); !ok {
+		return nil, fmt.Errorf("unknown default role %q", config.DefaultRole)
+	}
+	a := &ACL{config: config}
+	for _, admin := range config.Admins {
+		channel, id, ok := ParseRef(admin)
+		if !ok {
+			return nil, fmt.Errorf("invalid admin %q: want <channel>:<sender>", admin)
+		}
+		if err := a.SetRole(context.Background(), channel, id, RoleAdmin); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// ParseRef splits a "<channel>:<id>" reference.
+func ParseRef(ref string) (channel, id string, ok bool) {
+	channel, id, ok = strings.Cut(ref, ":")
+	return channel, id, ok && channel != "" && id != ""
+}
+
+// roleKey is the context key for the sender's role.
+type roleKey struct{}
+
+// WithRole returns a context carrying the sender's role, as Middleware
+// provides to handlers.
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromContext returns the role of the sender of the message being
+// processed, as set by Middleware.
+func RoleFromContext(ctx context.Context) (Role, bool) {
+	r, ok := ctx.Value(roleKey{}).(Role)
+	return r, ok
+}
+
+// Role returns the role of a sender on a channel.
+func (a *ACL) Role(ctx context.Context, channel, senderID string) (Role, error) {
+	for _, r := range []Role{RoleAdmin, RoleGuest, RoleUser} {
+		ok, err := a.contains(ctx, roleList(r), channel, senderID)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return r, nil
+		}
+	}
+	return a.config.DefaultRole, nil
+}
+
+// SetRole assigns a role to a sender on a channel, or on every channel
+// with AllChannels.
+func (a *ACL) SetRole(ctx context.Context, channel, senderID string, role Role) error {
+	if _, ok := ParseRole(string(role)); !ok {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	for _, r := range []Role{RoleAdmin, RoleGuest, RoleUser} {
+		if err := a.config.Store.Remove(ctx, roleList(r), channel, senderID); err != nil {
+			return fmt.Errorf("remove role: %w", err)
+		}
+	}
+	if err := a.config.Store.Add(ctx, roleList(role), channel, senderID); err != nil {
+		return fmt.Errorf("add role: %w", err)
+	}
+	return nil
+}
+
+// Add puts id on a list for channel.
+func (a *ACL) Add(ctx context.Context, list List, channel, id string) error {
+	if err := a.config.Store.Add(ctx, list, channel, id); err != nil {
+		return fmt.Errorf("add to %s: %w", list, err)
+	}
+	return nil
+}
+
+// Remove takes id off a list for channel.
+func (a *ACL) Remove(ctx context.Context, list List, channel, id string) error {
+	if err := a.config.Store.Remove(ctx, list, channel, id); err != nil {
+		return fmt.Errorf("remove from %s: %w", list, err)
+	}
+	return nil
+}
+
+// Admitted reports whether the lists let msg through. Admins are always
+// admitted, so they cannot lock themselves out.
+func (a *ACL) Admitted(ctx context.Context, msg channels.IncomingMessage, role Role) (bool, error) {
+	if role == RoleAdmin {
+		return true, nil
+	}
+	for _, check := range []struct {
+		allow, deny List
+		id          string
+	}{
+		{ListAllowChats, ListDenyChats, msg.ChatID},
+		{ListAllowSenders, ListDenySenders, msg.SenderID},
+	} {
+		denied, err := a.contains(ctx, check.deny, msg.ChannelName, check.id)
+		if err != nil || denied {
+			return false, err
+		}
+		restricted, err := a.restricted(ctx, check.allow, msg.ChannelName)
+		if err != nil {
+			return false, err
+		}
+		if !restricted {
+			continue
+		}
+		allowed, err := a.contains(ctx, check.allow, msg.ChannelName, check.id)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Required returns the role msg requires.
+func (a *ACL) Required(msg channels.IncomingMessage) Role {
+	if _, ok := channels.ParseCommand(msg.Content, CommandPrefix); ok {
+		return RoleAdmin
+	}
+	for _, p := range a.config.Permissions {
+		if p.matches(msg) {
+			return p.Role
+		}
+	}
+	return RoleGuest
+}
+
+// Middleware returns router middleware enforcing the ACL. Messages the
+// lists stop are dropped, and senders lacking a permission are told so if
+// Sender is set. Handlers find the sender's role with RoleFromContext.
+func (a *ACL) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			role, err := a.Role(ctx, msg.ChannelName, msg.SenderID)
+			if err != nil {
+				return err
+			}
+			admitted, err := a.Admitted(ctx, msg, role)
+			if err != nil {
+				return err
+			}
+			if !admitted {
+				a.config.Logger.Debug("message denied by acl", "channel", msg.ChannelName, "chat", msg.ChatID, "sender", msg.SenderID)
+				return nil
+			}
+
+			if required := a.Required(msg); !role.Allows(required) {
+				a.config.Logger.Info("permission denied",
+					"channel", msg.ChannelName,
+					"chat", msg.ChatID,
+					"sender", msg.SenderID,
+					"role", role,
+					"required", required)
+				if a.config.Sender == nil {
+					return nil
+				}
+				return a.config.Sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+					Content: a.config.DeniedMessage,
+					ReplyTo: msg.ID,
+				})
+			}
+			return next(WithRole(ctx, role), msg)
+		}
+	}
+}
+
+// contains reports whether id is on the list for channel or for every
+// channel.
+func (a *ACL) contains(ctx context.Context, list List, channel, id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+	for _, ch := range []string{channel, AllChannels} {
+		ok, err := a.config.Store.Contains(ctx, list, ch, id)
+		if err != nil {
+			return false, fmt.Errorf("check %s: %w", list, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// restricted reports whether an allowlist has entries for channel or for
+// every channel.
+func (a *ACL) restricted(ctx context.Context, list List, channel string) (bool, error) {
+	for _, ch := range []string{channel, AllChannels} {
+		members, err := a.config.Store.Members(ctx, list, ch)
+		if err != nil {
+			return false, fmt.Errorf("list %s: %w", list, err)
+		}
+		if len(members) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,85 @@
+package acl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+func TestACL(t *testing.T) {
+	sender := &channelstest.Sender{}
+	a, err := New(Config{
+		Admins:      []string{"*:root"},
+		Permissions: []Permission{{Command: "/deploy", Role: RoleAdmin}, {Pattern: channels.All(), Role: RoleUser}},
+		Sender:      sender,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var handled []string
+	var roles []Role
+	handler := a.Middleware()(func(ctx context.Context, msg channels.IncomingMessage) error {
+		role, _ := RoleFromContext(ctx)
+		handled = append(handled, msg.SenderID+": "+msg.Content)
+		roles = append(roles, role)
+		return nil
+	})
+	command := Command(a, sender)
+	ctx := context.Background()
+	say := func(h channels.MessageHandler, from, chat, content string) {
+		t.Helper()
+		msg := channels.IncomingMessage{ChannelName: "telegram", ChatID: chat, SenderID: from, Content: content}
+		if err := h(ctx, msg); err != nil {
+			t.Fatalf("%q failed: %v", content, err)
+		}
+	}
+
+	say(handler, "alice", "1", "hello")
+	say(handler, "alice", "1", "/deploy")
+	if sender.Last("") != DefaultDeniedMessage {
+		t.Errorf("denied reply = %q", sender.Last(""))
+	}
+	say(handler, "root", "1", "/deploy")
+	if len(handled) != 2 || handled[1] != "root: /deploy" || roles[1] != RoleAdmin {
+		t.Fatalf("handled = %q, roles = %q", handled, roles)
+	}
+
+	say(command, "alice", "1", "/acl deny sender telegram:bob")
+	if sender.Last("") != DefaultDeniedMessage {
+		t.Errorf("non-admin /acl reply = %q", sender.Last(""))
+	}
+	for _, cmd := range []string{
+		"/acl deny sender telegram:bob",
+		"/acl allow chat telegram:1",
+		"/acl role telegram:guest1 guest",
+	} {
+		say(command, "root", "9", cmd)
+	}
+	if got := sender.Last(""); got != "telegram:guest1 is now guest." {
+		t.Errorf("role reply = %q", got)
+	}
+
+	handled = nil
+	say(handler, "bob", "1", "hi")      // denied sender
+	say(handler, "carol", "2", "hi")    // chat not on the allowlist
+	say(handler, "guest1", "1", "hi")   // guests lack RoleUser
+	say(handler, "carol", "1", "hi")    // allowed
+	say(handler, "root", "2", "anyway") // admins pass the lists
+	if len(handled) != 2 || handled[0] != "carol: hi" || handled[1] != "root: anyway" {
+		t.Errorf("handled = %q", handled)
+	}
+
+	say(command, "root", "9", "/acl list telegram")
+	if want := "deny_senders: bob\nallow_chats: 1\nrole_guest: guest1"; sender.Last("") != want {
+		t.Errorf("list = %q, want %q", sender.Last(""), want)
+	}
+	say(command, "root", "9", "/acl clear chat telegram:1")
+	handled = nil
+	say(handler, "carol", "2", "hi")
+	if len(handled) != 1 {
+		t.Errorf("cleared allowlist still applies: %q", handled)
+	}
+}
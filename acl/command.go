@@ -0,0 +1,121 @@
+package acl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// CommandPrefix is the chat command managing the ACL.
+const CommandPrefix = "/acl"
+
+// usage is the reply to unknown /acl commands.
+const usage = "Usage: /acl list <channel> | /acl allow|deny|clear sender|chat <channel>:<id> | /acl role <channel>:<sender> guest|user|admin"
+
+// Command returns a handler for the /acl command, where "*" as the
+// channel means every channel:
+//
+//	/acl list <channel>                       show the lists of a channel
+//	/acl allow sender|chat <channel>:<id>     add to an allowlist
+//	/acl deny sender|chat <channel>:<id>      add to a denylist
+//	/acl clear sender|chat <channel>:<id>     remove from both lists
+//	/acl role <channel>:<sender> <role>       assign guest, user, or admin
+//
+// Only admins may use it. Register it with a higher priority than the agent
+// handler and Exclusive set.
+func Command(a *ACL, sender channels.Sender) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		args, ok := channels.ParseCommand(msg.Content, CommandPrefix)
+		if !ok {
+			return nil
+		}
+		role, err := a.Role(ctx, msg.ChannelName, msg.SenderID)
+		if err != nil {
+			return err
+		}
+
+		reply := a.config.DeniedMessage
+		if role == RoleAdmin {
+			if reply, err = a.command(ctx, strings.Fields(args)); err != nil {
+				return err
+			}
+		}
+		return sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+			Content: reply,
+			ReplyTo: msg.ID,
+		})
+	}
+}
+
+// command runs an /acl command and returns the reply.
+func (a *ACL) command(ctx context.Context, args []string) (string, error) {
+	switch {
+	case len(args) == 2 && args[0] == "list":
+		return a.describe(ctx, args[1])
+
+	case len(args) == 3 && args[0] == "role":
+		channel, id, ok := ParseRef(args[1])
+		role, valid := ParseRole(args[2])
+		if !ok || !valid {
+			return usage, nil
+		}
+		if err := a.SetRole(ctx, channel, id, role); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s is now %s.", args[1], role), nil
+
+	case len(args) == 3 && (args[1] == "sender" || args[1] == "chat"):
+		channel, id, ok := ParseRef(args[2])
+		if !ok {
+			return usage, nil
+		}
+		allow, deny := ListAllowSenders, ListDenySenders
+		if args[1] == "chat" {
+			allow, deny = ListAllowChats, ListDenyChats
+		}
+
+		var add List
+		switch args[0] {
+		case "allow":
+			add = allow
+		case "deny":
+			add = deny
+		case "clear":
+		default:
+			return usage, nil
+		}
+		// An ID is on at most one of the two lists
+		for _, list := range []List{allow, deny} {
+			if err := a.Remove(ctx, list, channel, id); err != nil {
+				return "", err
+			}
+		}
+		if add != "" {
+			if err := a.Add(ctx, add, channel, id); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("Updated %s %s.", args[1], args[2]), nil
+	}
+	return usage, nil
+}
+
+// describe lists the entries of a channel's lists.
+func (a *ACL) describe(ctx context.Context, channel string) (string, error) {
+	var lines []string
+	for _, list := range []List{ListAllowSenders, ListDenySenders, ListAllowChats, ListDenyChats, roleList(RoleAdmin), roleList(RoleGuest), roleList(RoleUser)} {
+		ids, err := a.config.Store.Members(ctx, list, channel)
+		if err != nil {
+			return "", fmt.Errorf("list %s: %w", list, err)
+		}
+		if len(ids) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s", list, strings.Join(ids, ", ")))
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("No entries for %s.", channel), nil
+	}
+	return strings.Join(lines, "\n"), nil
+}
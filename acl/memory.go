@@ -0,0 +1,65 @@
+package acl
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store for single-process deployments.
+type MemoryStore struct {
+	lists map[string]map[string]bool
+	mu    sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lists: make(map[string]map[string]bool)}
+}
+
+// key returns the map key of a list for channel.
+func key(list List, channel string) string {
+	return string(list) + "/" + channel
+}
+
+// Contains reports whether id is on the list for channel.
+func (s *MemoryStore) Contains(_ context.Context, list List, channel, id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lists[key(list, channel)][id], nil
+}
+
+// Members returns the IDs on the list for channel, sorted.
+func (s *MemoryStore) Members(_ context.Context, list List, channel string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.lists[key(list, channel)]))
+	for id := range s.lists[key(list, channel)] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Add puts id on the list for channel.
+func (s *MemoryStore) Add(_ context.Context, list List, channel, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key(list, channel)
+	if s.lists[k] == nil {
+		s.lists[k] = make(map[string]bool)
+	}
+	s.lists[k][id] = true
+	return nil
+}
+
+// Remove takes id off the list for channel.
+func (s *MemoryStore) Remove(_ context.Context, list List, channel, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lists[key(list, channel)], id)
+	return nil
+}
+
+// Ensure MemoryStore implements Store.
+var _ Store = (*MemoryStore)(nil)
@@ -3,11 +3,19 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"strings"
+	"text/template"
 
 	"github.com/agentplexus/omnillm"
 	"github.com/agentplexus/omnillm/provider"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/chatvars"
 )
 
 // Agent is the AI agent that processes messages.
@@ -16,6 +24,9 @@ type Agent struct {
 	tools  *ToolRegistry
 	config Config
 	logger *slog.Logger
+
+	// System prompt template, nil unless PromptVars is set
+	prompt *template.Template
 }
 
 // Config configures the agent.
@@ -28,13 +39,40 @@ type Config struct {
 	MaxTokens    int
 	SystemPrompt string
 	Logger       *slog.Logger
+
+	// MaxToolRounds bounds how many times per request the model may call
+	// tools before answering (default: DefaultMaxToolRounds).
+	MaxToolRounds int
+
+	// PromptVars, if set, makes SystemPrompt a text/template rendered per
+	// request with the variables it returns, e.g. chatvars.Vars.FromContext
+	// for per-chat personalization.
+	PromptVars func(ctx context.Context) (map[string]string, error)
 }
 
+// DefaultMaxToolRounds is the default of Config.MaxToolRounds.
+const DefaultMaxToolRounds = 8
+
+// ErrToolRounds is returned when the model keeps calling tools after
+// MaxToolRounds rounds without answering.
+var ErrToolRounds = errors.New("too many tool call rounds")
+
 // New creates a new agent.
 func New(config Config) (*Agent, error) {
 	if config.Logger == nil {
 		config.Logger = slog.Default()
 	}
+	if config.MaxToolRounds <= 0 {
+		config.MaxToolRounds = DefaultMaxToolRounds
+	}
+
+	var prompt *template.Template
+	if config.PromptVars != nil {
+		var err error
+		if prompt, err = chatvars.Parse("system_prompt", config.SystemPrompt); err != nil {
+			return nil, fmt.Errorf("system prompt: %w", err)
+		}
+	}
 
 	// Build provider configuration
 	providerConfig := omnillm.ProviderConfig{
@@ -59,11 +97,167 @@ func New(config Config) (*Agent, error) {
 		tools:  NewToolRegistry(),
 		config: config,
 		logger: config.Logger,
+		prompt: prompt,
 	}, nil
 }
 
-// Process processes a message and returns a response.
+// Process processes a message and returns a response. Tool calls the
+// model requests are dispatched to the registered tools and their results
+// fed back until the model answers.
 func (a *Agent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	req := a.buildRequest(ctx, content)
+
+	for round := 0; ; round++ {
+		resp, err := a.client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("chat completion: %w", err)
+		}
+
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("no response choices")
+		}
+
+		message := resp.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			return message.Content, nil
+		}
+		if err := a.callTools(ctx, req, message, round); err != nil {
+			return "", err
+		}
+	}
+}
+
+// ProcessStream processes a message and streams the response as it is
+// generated. The returned channel is closed when the response is complete;
+// a failure mid-stream is reported as a final chunk with Err set. Tool
+// calls are dispatched as in Process, and the response after them
+// continues the stream.
+func (a *Agent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error) {
+	req := a.buildRequest(ctx, content)
+
+	stream, err := a.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("chat completion stream: %w", err)
+	}
+
+	chunks := make(chan channels.Chunk)
+	go func() {
+		defer close(chunks)
+
+		for round := 0; ; round++ {
+			message, err := a.streamRound(ctx, stream, chunks)
+			if err == nil && len(message.ToolCalls) > 0 {
+				if err = a.callTools(ctx, req, message, round); err == nil {
+					stream, err = a.client.CreateChatCompletionStream(ctx, req)
+					if err != nil {
+						err = fmt.Errorf("chat completion stream: %w", err)
+					}
+				}
+			}
+			if err != nil {
+				select {
+				case chunks <- channels.Chunk{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			if len(message.ToolCalls) == 0 {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// streamRound forwards the content of one streamed completion and returns
+// the assistant message it built, with any tool calls the model requested.
+// It returns ctx's error if the reader stopped reading.
+func (a *Agent) streamRound(ctx context.Context, stream provider.ChatCompletionStream, chunks chan<- channels.Chunk) (provider.Message, error) {
+	defer stream.Close()
+
+	message := provider.Message{Role: provider.RoleAssistant}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return message, nil
+		}
+		if err != nil {
+			return message, fmt.Errorf("stream receive: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta == nil {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		message.ToolCalls = appendToolCalls(message.ToolCalls, delta.ToolCalls)
+		if delta.Content == "" {
+			continue
+		}
+		message.Content += delta.Content
+		select {
+		case chunks <- channels.Chunk{Content: delta.Content}:
+		case <-ctx.Done():
+			return message, ctx.Err()
+		}
+	}
+}
+
+// appendToolCalls adds streamed tool call fragments to calls: a fragment
+// with an ID starts a call, and later fragments continue it.
+func appendToolCalls(calls, fragments []provider.ToolCall) []provider.ToolCall {
+	for _, fragment := range fragments {
+		if fragment.ID != "" || len(calls) == 0 {
+			calls = append(calls, fragment)
+			continue
+		}
+		last := &calls[len(calls)-1]
+		last.Function.Name += fragment.Function.Name
+		last.Function.Arguments += fragment.Function.Arguments
+	}
+	return calls
+}
+
+// callTools dispatches the tool calls of an assistant message and appends
+// the message and the results to req, for the next round of the
+// conversation. It fails once the model has used up MaxToolRounds.
+func (a *Agent) callTools(ctx context.Context, req *provider.ChatCompletionRequest, message provider.Message, round int) error {
+	if round >= a.config.MaxToolRounds {
+		return ErrToolRounds
+	}
+	if message.Role == "" {
+		message.Role = provider.RoleAssistant
+	}
+	req.Messages = append(req.Messages, message)
+
+	for _, call := range message.ToolCalls {
+		result := a.tools.Dispatch(ctx, ToolCallRequest{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: json.RawMessage(call.Function.Arguments),
+		})
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		content := result.Content
+		if result.Err != nil {
+			a.logger.Warn("tool call failed", "tool", call.Function.Name, "error", result.Err)
+			content = "error: " + result.Err.Error()
+		} else {
+			a.logger.Debug("tool call completed", "tool", call.Function.Name)
+		}
+		req.Messages = append(req.Messages, provider.Message{
+			Role:       provider.RoleTool,
+			Content:    content,
+			ToolCallID: &result.CallID,
+		})
+	}
+	return nil
+}
+
+// buildRequest builds a chat completion request for a user message.
+func (a *Agent) buildRequest(ctx context.Context, content string) *provider.ChatCompletionRequest {
 	messages := []provider.Message{
 		{
 			Role:    provider.RoleUser,
@@ -72,11 +266,11 @@ func (a *Agent) Process(ctx context.Context, sessionID, content string) (string,
 	}
 
 	// Add system prompt if configured
-	if a.config.SystemPrompt != "" {
+	if systemPrompt := a.systemPrompt(ctx); systemPrompt != "" {
 		messages = append([]provider.Message{
 			{
 				Role:    provider.RoleSystem,
-				Content: a.config.SystemPrompt,
+				Content: systemPrompt,
 			},
 		}, messages...)
 	}
@@ -89,6 +283,16 @@ func (a *Agent) Process(ctx context.Context, sessionID, content string) (string,
 	if a.config.Temperature > 0 {
 		req.Temperature = &a.config.Temperature
 	}
+
+	// Apply the session's overrides
+	session := channels.SessionConfigFromContext(ctx)
+	if session.Model != "" {
+		req.Model = session.Model
+	}
+	if session.Temperature != nil {
+		temperature := *session.Temperature
+		req.Temperature = &temperature
+	}
 	if a.config.MaxTokens > 0 {
 		req.MaxTokens = &a.config.MaxTokens
 	}
@@ -99,16 +303,50 @@ func (a *Agent) Process(ctx context.Context, sessionID, content string) (string,
 		req.Tools = tools
 	}
 
-	resp, err := a.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", fmt.Errorf("chat completion: %w", err)
-	}
+	return req
+}
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices")
+// systemPrompt returns the system prompt, or the session's, rendered with
+// PromptVars if set and followed by the session's language instruction. If
+// the variables cannot be loaded, they render empty.
+func (a *Agent) systemPrompt(ctx context.Context) string {
+	session := channels.SessionConfigFromContext(ctx)
+	prompt := a.renderPrompt(ctx, session.SystemPrompt)
+	if session.Language != "" {
+		prompt = strings.TrimSpace(prompt + "\n\nAlways reply in " + session.Language + ".")
 	}
+	return prompt
+}
 
-	return resp.Choices[0].Message.Content, nil
+// renderPrompt renders the configured system prompt, or override if set.
+func (a *Agent) renderPrompt(ctx context.Context, override string) string {
+	if a.prompt == nil {
+		if override != "" {
+			return override
+		}
+		return a.config.SystemPrompt
+	}
+	tmpl := a.prompt
+	if override != "" {
+		var err error
+		if tmpl, err = chatvars.Parse("session_prompt", override); err != nil {
+			a.logger.Warn("session system prompt invalid", "error", err)
+			return override
+		}
+	}
+	vars, err := a.config.PromptVars(ctx)
+	if err != nil {
+		a.logger.Warn("prompt variables unavailable", "error", err)
+	}
+	prompt, err := chatvars.Execute(tmpl, vars)
+	if err != nil {
+		a.logger.Error("system prompt render failed", "error", err)
+		if override != "" {
+			return override
+		}
+		return a.config.SystemPrompt
+	}
+	return prompt
 }
 
 // ProcessWithMemory processes a message using conversation memory.
@@ -126,3 +364,6 @@ func (a *Agent) RegisterTool(tool Tool) {
 func (a *Agent) Close() error {
 	return a.client.Close()
 }
+
+// Ensure Agent implements StreamingAgentProcessor interface.
+var _ channels.StreamingAgentProcessor = (*Agent)(nil)
@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Interceptor wraps an agent to add behavior around it, such as logging,
+// caching, token counting, or guardrails, without modifying it.
+type Interceptor func(next channels.AgentProcessor) channels.AgentProcessor
+
+// ProcessFunc processes a message, as AgentProcessor.Process does.
+type ProcessFunc func(ctx context.Context, sessionID, content string) (string, error)
+
+// StreamFunc streams the response to a message, as
+// StreamingAgentProcessor.ProcessStream does.
+type StreamFunc func(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error)
+
+// Chain composes interceptors into one. The first interceptor is the
+// outermost: it sees each request first and its response last. Agents
+// wrapped by the chain can still be closed if the agent they wrap
+// implements io.Closer.
+func Chain(interceptors ...Interceptor) Interceptor {
+	return func(next channels.AgentProcessor) channels.AgentProcessor {
+		a := next
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			a = interceptors[i](a)
+		}
+		return withClose(a, next)
+	}
+}
+
+// Wrap returns an Interceptor running process around the agent's Process
+// and stream around its ProcessStream; each is given the wrapped agent's
+// method as next. The agents it returns stream only if stream is set and
+// the wrapped agent streams, so streaming requests cannot bypass process.
+// A nil process passes requests through. Requests with images for agents
+// implementing channels.MultimodalAgentProcessor run through process too,
+// which sees their text.
+func Wrap(
+	process func(ctx context.Context, sessionID, content string, next ProcessFunc) (string, error),
+	stream func(ctx context.Context, sessionID, content string, next StreamFunc) (<-chan channels.Chunk, error),
+) Interceptor {
+	return func(next channels.AgentProcessor) channels.AgentProcessor {
+		w := &wrapped{next: next, process: process}
+		s, streams := next.(channels.StreamingAgentProcessor)
+		streams = streams && stream != nil
+		m, ok := next.(channels.MultimodalAgentProcessor)
+		switch {
+		case ok && streams:
+			return struct {
+				*streamingWrapped
+				multimodalWrapped
+			}{&streamingWrapped{wrapped: w, next: s, stream: stream}, multimodalWrapped{w, m}}
+		case ok:
+			return struct {
+				*wrapped
+				multimodalWrapped
+			}{w, multimodalWrapped{w, m}}
+		case streams:
+			return &streamingWrapped{wrapped: w, next: s, stream: stream}
+		}
+		return w
+	}
+}
+
+// wrapped is an agent built by Wrap.
+type wrapped struct {
+	next    channels.AgentProcessor
+	process func(ctx context.Context, sessionID, content string, next ProcessFunc) (string, error)
+}
+
+func (w *wrapped) Process(ctx context.Context, sessionID, content string) (string, error) {
+	if w.process == nil {
+		return w.next.Process(ctx, sessionID, content)
+	}
+	return w.process(ctx, sessionID, content, w.next.Process)
+}
+
+// Close closes the wrapped agent if it implements io.Closer.
+func (w *wrapped) Close() error {
+	if closer, ok := w.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// streamingWrapped is an agent built by Wrap around a streaming agent.
+type streamingWrapped struct {
+	*wrapped
+	next   channels.StreamingAgentProcessor
+	stream func(ctx context.Context, sessionID, content string, next StreamFunc) (<-chan channels.Chunk, error)
+}
+
+func (w *streamingWrapped) ProcessStream(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error) {
+	return w.stream(ctx, sessionID, content, w.next.ProcessStream)
+}
+
+// multimodalWrapped adds ProcessMultimodal to an agent built by Wrap around
+// a multimodal agent.
+type multimodalWrapped struct {
+	w    *wrapped
+	next channels.MultimodalAgentProcessor
+}
+
+func (m multimodalWrapped) ProcessMultimodal(ctx context.Context, sessionID, content string, images []channels.Image) (string, error) {
+	next := func(ctx context.Context, sessionID, content string) (string, error) {
+		return m.next.ProcessMultimodal(ctx, sessionID, content, images)
+	}
+	if m.w.process == nil {
+		return next(ctx, sessionID, content)
+	}
+	return m.w.process(ctx, sessionID, content, next)
+}
+
+// Logging logs each request with its duration and, if it failed, its
+// error. Successful requests are logged at debug level.
+func Logging(logger *slog.Logger) Interceptor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	done := func(ctx context.Context, sessionID string, start time.Time, streamed bool, err error) {
+		attrs := []any{"session", sessionID, "duration", time.Since(start), "streamed", streamed}
+		if err != nil {
+			logger.WarnContext(ctx, "agent request failed", append(attrs, "error", err)...)
+			return
+		}
+		logger.DebugContext(ctx, "agent request completed", attrs...)
+	}
+	return Wrap(
+		func(ctx context.Context, sessionID, content string, next ProcessFunc) (string, error) {
+			start := time.Now()
+			response, err := next(ctx, sessionID, content)
+			done(ctx, sessionID, start, false, err)
+			return response, err
+		},
+		func(ctx context.Context, sessionID, content string, next StreamFunc) (<-chan channels.Chunk, error) {
+			start := time.Now()
+			chunks, err := next(ctx, sessionID, content)
+			if err != nil {
+				done(ctx, sessionID, start, true, err)
+				return nil, err
+			}
+			return observe(ctx, chunks, func(err error) {
+				done(ctx, sessionID, start, true, err)
+			}, nil), nil
+		},
+	)
+}
+
+// Guard rejects requests whose content check returns an error, without
+// passing them to the agent, returning the error marked Permanent instead.
+func Guard(check func(ctx context.Context, sessionID, content string) error) Interceptor {
+	return Wrap(
+		func(ctx context.Context, sessionID, content string, next ProcessFunc) (string, error) {
+			if err := check(ctx, sessionID, content); err != nil {
+				return "", Permanent(err)
+			}
+			return next(ctx, sessionID, content)
+		},
+		func(ctx context.Context, sessionID, content string, next StreamFunc) (<-chan channels.Chunk, error) {
+			if err := check(ctx, sessionID, content); err != nil {
+				return nil, Permanent(err)
+			}
+			return next(ctx, sessionID, content)
+		},
+	)
+}
+
+// withClose returns a, closing inner when closed if a does not implement
+// io.Closer itself.
+func withClose(a, inner channels.AgentProcessor) channels.AgentProcessor {
+	closer, ok := inner.(io.Closer)
+	if _, closes := a.(io.Closer); closes || !ok {
+		return a
+	}
+	if s, ok := a.(channels.StreamingAgentProcessor); ok {
+		return struct {
+			channels.StreamingAgentProcessor
+			io.Closer
+		}{s, closer}
+	}
+	return struct {
+		channels.AgentProcessor
+		io.Closer
+	}{a, closer}
+}
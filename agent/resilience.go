@@ -0,0 +1,333 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// ErrTimeout is returned by agents wrapped with Timeout that did not answer
+// in time.
+var ErrTimeout = errors.New("agent timed out")
+
+// ErrCircuitOpen is returned by agents wrapped with CircuitBreaker while
+// the circuit is open, if no fallback is configured.
+var ErrCircuitOpen = errors.New("agent unavailable")
+
+// permanent marks an error that retrying cannot fix.
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// Permanent marks err as not transient, so Retry does not retry it and
+// CircuitBreaker does not count it as a failure of the backend.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanent{err}
+}
+
+// Transient reports whether a request that failed with err may succeed if
+// retried: errors are transient unless they are context cancellations or
+// were marked with Permanent.
+func Transient(err error) bool {
+	var p permanent
+	if err == nil || errors.As(err, &p) {
+		return false
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// Timeout bounds each request to d. Requests that take longer have their
+// context canceled and fail with ErrTimeout. Streamed responses must
+// complete within d.
+func Timeout(d time.Duration) Interceptor {
+	return Wrap(
+		func(ctx context.Context, sessionID, content string, next ProcessFunc) (string, error) {
+			ctx, cancel := context.WithTimeoutCause(ctx, d, ErrTimeout)
+			defer cancel()
+			response, err := next(ctx, sessionID, content)
+			if err != nil && errors.Is(context.Cause(ctx), ErrTimeout) {
+				return "", ErrTimeout
+			}
+			return response, err
+		},
+		func(ctx context.Context, sessionID, content string, next StreamFunc) (<-chan channels.Chunk, error) {
+			ctx, cancel := context.WithTimeoutCause(ctx, d, ErrTimeout)
+			chunks, err := next(ctx, sessionID, content)
+			if err != nil {
+				cancel()
+				if errors.Is(context.Cause(ctx), ErrTimeout) {
+					return nil, ErrTimeout
+				}
+				return nil, err
+			}
+			return observe(ctx, chunks, func(error) { cancel() }, func(err error) error {
+				if errors.Is(context.Cause(ctx), ErrTimeout) {
+					return ErrTimeout
+				}
+				return err
+			}), nil
+		},
+	)
+}
+
+// Retry retries requests that fail with transient errors, waiting the
+// policy's backoff with jitter between attempts. The policy's Retryable
+// defaults to Transient. Streamed responses are retried only if they fail
+// before producing any content.
+func Retry(policy channels.RetryPolicy) Interceptor {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = Transient
+	}
+	// retry waits before the given retry, reporting whether to make it
+	retry := func(ctx context.Context, n int, err error) bool {
+		if n >= policy.MaxAttempts || !policy.Retryable(err) || ctx.Err() != nil {
+			return false
+		}
+		select {
+		case <-time.After(jitter(policy.Backoff(n))):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return Wrap(
+		func(ctx context.Context, sessionID, content string, next ProcessFunc) (string, error) {
+			for n := 1; ; n++ {
+				response, err := next(ctx, sessionID, content)
+				if err == nil || !retry(ctx, n, err) {
+					return response, err
+				}
+			}
+		},
+		func(ctx context.Context, sessionID, content string, next StreamFunc) (<-chan channels.Chunk, error) {
+			for n := 1; ; n++ {
+				chunks, err := next(ctx, sessionID, content)
+				if err == nil {
+					// A stream failing at once is retried like a failed call
+					var first channels.Chunk
+					var ok bool
+					select {
+					case first, ok = <-chunks:
+					case <-ctx.Done():
+						go drain(chunks)
+						return nil, ctx.Err()
+					}
+					if !ok {
+						return chunks, nil
+					}
+					if first.Err == nil || first.Content != "" {
+						return prepend(ctx, first, chunks), nil
+					}
+					err = first.Err
+					go drain(chunks)
+				}
+				if !retry(ctx, n, err) {
+					return nil, err
+				}
+			}
+		},
+	)
+}
+
+// jitter returns a random delay between half of d and d.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// DefaultBreakerThreshold and DefaultBreakerCooldown are the defaults of
+// BreakerConfig.
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// BreakerConfig configures CircuitBreaker.
+type BreakerConfig struct {
+	// Threshold is the number of consecutive failures that opens the
+	// circuit (default: DefaultBreakerThreshold).
+	Threshold int
+
+	// Cooldown is how long the circuit stays open before a single request
+	// is let through to probe the backend (default:
+	// DefaultBreakerCooldown).
+	Cooldown time.Duration
+
+	// Fallback is returned as the response while the circuit is open, such
+	// as "The assistant is unavailable, please try again in a few
+	// minutes." Without it, requests fail with ErrCircuitOpen.
+	Fallback string
+
+	// IsFailure reports whether an error counts as a failure of the
+	// backend (default: Transient).
+	IsFailure func(err error) bool
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.Threshold <= 0 {
+		c.Threshold = DefaultBreakerThreshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = DefaultBreakerCooldown
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = Transient
+	}
+	return c
+}
+
+// breaker is the state of a circuit breaker.
+type breaker struct {
+	config BreakerConfig
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time // zero while closed
+	probing  bool
+}
+
+// allow reports whether a request may go to the backend. Once the
+// cooldown has passed, one request at a time probes it.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || now.Sub(b.openedAt) < b.config.Cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// done records the outcome of a request let through.
+func (b *breaker) done(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil || !b.config.IsFailure(err) {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.config.Threshold || !b.openedAt.IsZero() {
+		b.openedAt = now
+	}
+}
+
+// open returns the response to a request the circuit turned away.
+func (b *breaker) open() (string, error) {
+	if b.config.Fallback == "" {
+		return "", ErrCircuitOpen
+	}
+	return b.config.Fallback, nil
+}
+
+// CircuitBreaker stops sending requests to an agent after consecutive
+// failures, answering with the fallback instead until the backend
+// recovers. Each wrapped agent has its own circuit.
+func CircuitBreaker(config BreakerConfig) Interceptor {
+	config = config.withDefaults()
+	return func(next channels.AgentProcessor) channels.AgentProcessor {
+		b := &breaker{config: config}
+		return Wrap(
+			func(ctx context.Context, sessionID, content string, next ProcessFunc) (string, error) {
+				if !b.allow(time.Now()) {
+					return b.open()
+				}
+				response, err := next(ctx, sessionID, content)
+				b.done(err, time.Now())
+				return response, err
+			},
+			func(ctx context.Context, sessionID, content string, next StreamFunc) (<-chan channels.Chunk, error) {
+				if !b.allow(time.Now()) {
+					response, err := b.open()
+					if err != nil {
+						return nil, err
+					}
+					return prepend(ctx, channels.Chunk{Content: response}, nil), nil
+				}
+				chunks, err := next(ctx, sessionID, content)
+				if err != nil {
+					b.done(err, time.Now())
+					return nil, err
+				}
+				return observe(ctx, chunks, func(err error) {
+					b.done(err, time.Now())
+				}, nil), nil
+			},
+		)(next)
+	}
+}
+
+// observe forwards chunks, calling end with the stream's error, or the
+// context's if the reader stopped reading, once it ends. mapErr, if set,
+// replaces the errors of forwarded chunks.
+func observe(ctx context.Context, chunks <-chan channels.Chunk, end func(error), mapErr func(error) error) <-chan channels.Chunk {
+	out := make(chan channels.Chunk)
+	go func() {
+		defer close(out)
+		var err error
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				if mapErr != nil {
+					chunk.Err = mapErr(chunk.Err)
+				}
+				err = chunk.Err
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				go drain(chunks)
+				end(ctx.Err())
+				return
+			}
+		}
+		end(err)
+	}()
+	return out
+}
+
+// prepend returns a stream of first followed by the chunks of rest, which
+// may be nil. It stops forwarding once ctx is done.
+func prepend(ctx context.Context, first channels.Chunk, rest <-chan channels.Chunk) <-chan channels.Chunk {
+	out := make(chan channels.Chunk, 1)
+	out <- first
+	if rest == nil {
+		close(out)
+		return out
+	}
+	go func() {
+		defer close(out)
+		for chunk := range rest {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				go drain(rest)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// drain reads a stream to its end, so the agent sending it is not blocked.
+func drain(chunks <-chan channels.Chunk) {
+	for range chunks {
+	}
+}
@@ -3,9 +3,13 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/agentplexus/omnillm/provider"
+
+	"github.com/agentplexus/envoy/channels"
 )
 
 // Tool represents an agent tool that can be invoked.
@@ -95,6 +99,32 @@ func (r *ToolRegistry) Execute(ctx context.Context, name string, args json.RawMe
 	return tool.Execute(ctx, args)
 }
 
+// ToolCallRequest is a call to a registered tool requested by the model.
+type ToolCallRequest struct {
+	// ID identifies the call; its result answers the same ID.
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// ToolResult is the outcome of a tool call, fed back to the model.
+type ToolResult struct {
+	CallID  string
+	Content string
+	Err     error
+}
+
+// Dispatch runs a tool call. A failed call's result carries the error, so
+// the model can see it and recover.
+func (r *ToolRegistry) Dispatch(ctx context.Context, call ToolCallRequest) ToolResult {
+	args := call.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	content, err := r.Execute(ctx, call.Name, args)
+	return ToolResult{CallID: call.ID, Content: content, Err: err}
+}
+
 // ToolNotFoundError is returned when a tool is not found.
 type ToolNotFoundError struct {
 	Name string
@@ -129,3 +159,32 @@ func (t *BaseTool) Parameters() map[string]interface{} { return t.parameters }
 func (t *BaseTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
 	return t.handler(ctx, args)
 }
+
+// NewAskTool returns the "ask_user" tool, with which the model asks the user
+// a question over their channel, using channels.Ask, and gets the reply.
+func NewAskTool() *BaseTool {
+	return NewBaseTool("ask_user",
+		"Ask the user a question and wait for their reply. Use it when a detail needed to complete the request is missing.",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"question": map[string]interface{}{
+					"type":        "string",
+					"description": "The question to ask the user.",
+				},
+			},
+			"required": []string{"question"},
+		},
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var params struct {
+				Question string `json:"question"`
+			}
+			if err := json.Unmarshal(args, &params); err != nil {
+				return "", fmt.Errorf("parse arguments: %w", err)
+			}
+			if params.Question == "" {
+				return "", errors.New("question required")
+			}
+			return channels.Ask(ctx, params.Question)
+		})
+}
@@ -0,0 +1,120 @@
+// Package agents provides ready-made agents for common LLM endpoints:
+// OpenAI and OpenAI-compatible servers, Anthropic, and Ollama. The agents
+// they return implement channels.StreamingAgentProcessor, so they stream
+// responses wherever the channel supports it.
+//
+//	a, err := agents.Anthropic(agents.Config{
+//		Model:        "claude-sonnet-4-5",
+//		SystemPrompt: "You are a helpful assistant.",
+//	})
+//	router.SetAgent(a)
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/agentplexus/envoy/agent"
+)
+
+// Provider names, as used in agent configuration.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// DefaultOllamaURL is the address of a local Ollama server.
+const DefaultOllamaURL = "http://localhost:11434"
+
+// ErrNoModel is returned when Config.Model is not set.
+var ErrNoModel = errors.New("model required")
+
+// Config configures a provider agent.
+type Config struct {
+	// Model is the model to use, such as "gpt-4o" or "llama3.2".
+	Model string
+
+	// APIKey authenticates with the provider (default: the provider's
+	// environment variable, OPENAI_API_KEY or ANTHROPIC_API_KEY).
+	APIKey string
+
+	// BaseURL overrides the provider's endpoint, such as the address of an
+	// OpenAI-compatible server.
+	BaseURL string
+
+	SystemPrompt string
+
+	// Temperature and MaxTokens are left to the provider if zero.
+	Temperature float64
+	MaxTokens   int
+
+	// MaxToolRounds bounds tool calls per request (default:
+	// agent.DefaultMaxToolRounds).
+	MaxToolRounds int
+
+	Logger *slog.Logger
+}
+
+// OpenAI returns an agent for the OpenAI API, or any OpenAI-compatible
+// server, such as vLLM, LM Studio, or Groq, at BaseURL.
+func OpenAI(config Config) (*agent.Agent, error) {
+	return newAgent(ProviderOpenAI, "OPENAI_API_KEY", config)
+}
+
+// Anthropic returns an agent for the Anthropic Messages API.
+func Anthropic(config Config) (*agent.Agent, error) {
+	return newAgent(ProviderAnthropic, "ANTHROPIC_API_KEY", config)
+}
+
+// Ollama returns an agent for an Ollama server, at DefaultOllamaURL unless
+// BaseURL is set. No API key is needed.
+func Ollama(config Config) (*agent.Agent, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultOllamaURL
+	}
+	return newAgent(ProviderOllama, "", config)
+}
+
+// New returns an agent for the named provider: ProviderOpenAI,
+// ProviderAnthropic, or ProviderOllama.
+func New(provider string, config Config) (*agent.Agent, error) {
+	switch provider {
+	case ProviderOpenAI:
+		return OpenAI(config)
+	case ProviderAnthropic:
+		return Anthropic(config)
+	case ProviderOllama:
+		return Ollama(config)
+	default:
+		return nil, fmt.Errorf("unknown provider %q", provider)
+	}
+}
+
+// newAgent creates an agent for provider, reading the API key from keyEnv
+// if it is not configured.
+func newAgent(provider, keyEnv string, config Config) (*agent.Agent, error) {
+	if config.Model == "" {
+		return nil, fmt.Errorf("%s: %w", provider, ErrNoModel)
+	}
+	if config.APIKey == "" && keyEnv != "" {
+		config.APIKey = os.Getenv(keyEnv)
+	}
+	a, err := agent.New(agent.Config{
+		Provider:      provider,
+		Model:         config.Model,
+		APIKey:        config.APIKey,
+		BaseURL:       config.BaseURL,
+		Temperature:   config.Temperature,
+		MaxTokens:     config.MaxTokens,
+		SystemPrompt:  config.SystemPrompt,
+		MaxToolRounds: config.MaxToolRounds,
+		Logger:        config.Logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	return a, nil
+}
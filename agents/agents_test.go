@@ -0,0 +1,154 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// seenRequest is what openAIServer saw of the last request.
+type seenRequest struct {
+	model  string
+	system string
+}
+
+// openAIServer is a minimal OpenAI-compatible endpoint that answers
+// "Echo: <last message>", recording the request in seen.
+func openAIServer(t *testing.T, seen *seenRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Model    string `json:"model"`
+			Stream   bool   `json:"stream"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*seen = seenRequest{model: req.Model}
+		if req.Messages[0].Role == "system" {
+			seen.system = req.Messages[0].Content
+		}
+		reply := "Echo: " + req.Messages[len(req.Messages)-1].Content
+
+		if !req.Stream {
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id":    "1",
+				"model": req.Model,
+				"choices": []map[string]any{{
+					"message":       map[string]string{"role": "assistant", "content": reply},
+					"finish_reason": "stop",
+				}},
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range strings.SplitAfter(reply, " ") {
+			data, _ := json.Marshal(map[string]any{
+				"id":      "1",
+				"choices": []map[string]any{{"delta": map[string]string{"content": word}}},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpenAICompatible(t *testing.T) {
+	var seen seenRequest
+	srv := openAIServer(t, &seen)
+	a, err := OpenAI(Config{
+		Model:        "test",
+		APIKey:       "key",
+		BaseURL:      srv.URL,
+		SystemPrompt: "Be brief.",
+	})
+	if err != nil {
+		t.Fatalf("OpenAI failed: %v", err)
+	}
+	defer a.Close()
+
+	ctx := context.Background()
+	response, err := a.Process(ctx, "s1", "hello")
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if response != "Echo: hello" || seen != (seenRequest{"test", "Be brief."}) {
+		t.Errorf("response = %q, request = %+v", response, seen)
+	}
+
+	chunks, err := a.ProcessStream(ctx, "s1", "hello there")
+	if err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	var streamed []string
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream failed: %v", chunk.Err)
+		}
+		streamed = append(streamed, chunk.Content)
+	}
+	if len(streamed) != 3 || strings.Join(streamed, "") != "Echo: hello there" {
+		t.Errorf("streamed = %q", streamed)
+	}
+}
+
+func TestSessionConfig(t *testing.T) {
+	var seen seenRequest
+	srv := openAIServer(t, &seen)
+	a, err := OpenAI(Config{Model: "test", APIKey: "key", BaseURL: srv.URL, SystemPrompt: "Be brief."})
+	if err != nil {
+		t.Fatalf("OpenAI failed: %v", err)
+	}
+	defer a.Close()
+
+	ctx := channels.WithSessionConfig(context.Background(), channels.SessionConfig{
+		Model:    "other",
+		Language: "French",
+	})
+	if _, err := a.Process(ctx, "s1", "hello"); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if want := (seenRequest{"other", "Be brief.\n\nAlways reply in French."}); seen != want {
+		t.Errorf("request = %+v, want %+v", seen, want)
+	}
+
+	ctx = channels.WithSessionConfig(context.Background(), channels.SessionConfig{SystemPrompt: "Be verbose."})
+	if _, err := a.Process(ctx, "s1", "hello"); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if want := (seenRequest{"test", "Be verbose."}); seen != want {
+		t.Errorf("request = %+v, want %+v", seen, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := OpenAI(Config{APIKey: "key"}); !errors.Is(err, ErrNoModel) {
+		t.Errorf("err = %v, want ErrNoModel", err)
+	}
+	if _, err := New("unknown", Config{Model: "m"}); err == nil {
+		t.Error("unknown provider accepted")
+	}
+	a, err := New(ProviderOllama, Config{Model: "llama3.2"})
+	if err != nil {
+		t.Fatalf("Ollama failed: %v", err)
+	}
+	a.Close()
+}
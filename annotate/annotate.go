@@ -0,0 +1,198 @@
+// Package annotate attaches classifier outputs such as sentiment, intent,
+// language, and toxicity to incoming messages.
+//
+// A Pipeline runs as router middleware, so annotations are available to
+// route patterns and handlers:
+//
+//	pipeline := annotate.New(annotate.Config{
+//		Classifiers: []annotate.Classifier{sentimentClassifier},
+//	})
+//	router.Use(pipeline.Middleware())
+//	router.OnMessage(annotate.Where(annotate.KeySentiment, "negative"), escalate)
+package annotate
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// MetadataKey is the IncomingMessage.Metadata key holding Annotations.
+const MetadataKey = "annotations"
+
+// Well-known annotation keys.
+const (
+	KeySentiment = "sentiment" // string: positive, neutral, negative
+	KeyIntent    = "intent"    // string: application-defined intent name
+	KeyLanguage  = "language"  // string: BCP 47 language tag
+	KeyToxicity  = "toxicity"  // float64: 0 (benign) to 1 (toxic)
+)
+
+// Annotations holds classifier outputs keyed by annotation name.
+type Annotations map[string]interface{}
+
+// Classifier produces annotations for a message.
+type Classifier interface {
+	// Name identifies the classifier in logs.
+	Name() string
+
+	// Classify returns annotations for the message.
+	Classify(ctx context.Context, msg channels.IncomingMessage) (Annotations, error)
+}
+
+// ClassifierFunc adapts a function to the Classifier interface.
+type ClassifierFunc struct {
+	ClassifierName string
+	Fn             func(ctx context.Context, msg channels.IncomingMessage) (Annotations, error)
+}
+
+// Name returns the classifier name.
+func (f ClassifierFunc) Name() string {
+	return f.ClassifierName
+}
+
+// Classify calls Fn.
+func (f ClassifierFunc) Classify(ctx context.Context, msg channels.IncomingMessage) (Annotations, error) {
+	return f.Fn(ctx, msg)
+}
+
+// Config configures a Pipeline.
+type Config struct {
+	// Classifiers run concurrently on every message. When two classifiers
+	// set the same key, the one listed later wins.
+	Classifiers []Classifier
+
+	// Timeout bounds each classifier call (default: 5s).
+	Timeout time.Duration
+
+	Logger *slog.Logger
+}
+
+// Pipeline runs classifiers over incoming messages.
+type Pipeline struct {
+	classifiers []Classifier
+	timeout     time.Duration
+	logger      *slog.Logger
+}
+
+// New creates a new Pipeline.
+func New(config Config) *Pipeline {
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Pipeline{
+		classifiers: config.Classifiers,
+		timeout:     config.Timeout,
+		logger:      config.Logger,
+	}
+}
+
+// Middleware returns router middleware that annotates each message before
+// it is routed.
+func (p *Pipeline) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			return next(ctx, p.Annotate(ctx, msg))
+		}
+	}
+}
+
+// Annotate runs all classifiers and returns a copy of msg with their
+// annotations merged into its metadata. Classifier errors are logged and
+// skipped.
+func (p *Pipeline) Annotate(ctx context.Context, msg channels.IncomingMessage) channels.IncomingMessage {
+	if len(p.classifiers) == 0 {
+		return msg
+	}
+
+	results := make([]Annotations, len(p.classifiers))
+	var wg sync.WaitGroup
+	for i, c := range p.classifiers {
+		wg.Add(1)
+		go func(i int, c Classifier) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, p.timeout)
+			defer cancel()
+
+			a, err := c.Classify(ctx, msg)
+			if err != nil {
+				p.logger.Warn("classifier error",
+					"classifier", c.Name(),
+					"channel", msg.ChannelName,
+					"chat", msg.ChatID,
+					"error", err)
+				return
+			}
+			results[i] = a
+		}(i, c)
+	}
+	wg.Wait()
+
+	merged := Annotations{}
+	for k, v := range Get(msg) {
+		merged[k] = v
+	}
+	for _, a := range results {
+		for k, v := range a {
+			merged[k] = v
+		}
+	}
+
+	metadata := make(map[string]interface{}, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataKey] = merged
+	msg.Metadata = metadata
+	return msg
+}
+
+// Get returns the annotations attached to a message, or nil.
+func Get(msg channels.IncomingMessage) Annotations {
+	a, _ := msg.Metadata[MetadataKey].(Annotations)
+	return a
+}
+
+// String returns a string annotation.
+func String(msg channels.IncomingMessage, key string) (string, bool) {
+	v, ok := Get(msg)[key].(string)
+	return v, ok
+}
+
+// Score returns a numeric annotation.
+func Score(msg channels.IncomingMessage, key string) (float64, bool) {
+	v, ok := Get(msg)[key].(float64)
+	return v, ok
+}
+
+// Where returns a route pattern matching messages whose string annotation
+// key equals one of values.
+func Where(key string, values ...string) channels.RoutePattern {
+	return channels.Where(func(msg channels.IncomingMessage) bool {
+		v, ok := String(msg, key)
+		if !ok {
+			return false
+		}
+		for _, want := range values {
+			if v == want {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Above returns a route pattern matching messages whose numeric annotation
+// key is at least threshold.
+func Above(key string, threshold float64) channels.RoutePattern {
+	return channels.Where(func(msg channels.IncomingMessage) bool {
+		v, ok := Score(msg, key)
+		return ok && v >= threshold
+	})
+}
@@ -0,0 +1,98 @@
+package annotate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+func TestPipelineAnnotate(t *testing.T) {
+	intent := &KeywordClassifier{
+		Key: KeyIntent,
+		Labels: map[string][]string{
+			"refund":   {"refund", "money back"},
+			"greeting": {"hello", "hi"},
+		},
+		Order: []string{"refund", "greeting"},
+	}
+	toxicity := ClassifierFunc{
+		ClassifierName: "toxicity",
+		Fn: func(context.Context, channels.IncomingMessage) (Annotations, error) {
+			return Annotations{KeyToxicity: 0.9}, nil
+		},
+	}
+	broken := ClassifierFunc{
+		ClassifierName: "broken",
+		Fn: func(context.Context, channels.IncomingMessage) (Annotations, error) {
+			return nil, errors.New("model unavailable")
+		},
+	}
+
+	p := New(Config{Classifiers: []Classifier{intent, toxicity, broken}})
+	in := channels.IncomingMessage{
+		Content:  "Hi, I want my money back!",
+		Metadata: map[string]interface{}{"guild_id": "g1"},
+	}
+	out := p.Annotate(context.Background(), in)
+
+	if got, _ := String(out, KeyIntent); got != "refund" {
+		t.Errorf("intent = %q, want refund", got)
+	}
+	if got, _ := Score(out, KeyToxicity); got != 0.9 {
+		t.Errorf("toxicity = %v, want 0.9", got)
+	}
+	if out.Metadata["guild_id"] != "g1" {
+		t.Error("existing metadata was not preserved")
+	}
+	if _, ok := in.Metadata[MetadataKey]; ok {
+		t.Error("input message metadata was mutated")
+	}
+}
+
+func TestPipelineRouting(t *testing.T) {
+	sentiment := &KeywordClassifier{
+		Key:    KeySentiment,
+		Labels: map[string][]string{"negative": {"terrible", "angry"}},
+	}
+
+	router := channels.NewRouter(nil)
+	router.Use(New(Config{Classifiers: []Classifier{sentiment}}).Middleware())
+
+	var escalated []string
+	router.OnMessage(Where(KeySentiment, "negative"), func(_ context.Context, msg channels.IncomingMessage) error {
+		escalated = append(escalated, msg.Content)
+		return nil
+	})
+
+	ch := &stubChannel{}
+	router.Register(ch)
+	for _, content := range []string{"this is terrible", "thanks a lot"} {
+		if err := ch.handler(context.Background(), channels.IncomingMessage{ChannelName: "stub", Content: content}); err != nil {
+			t.Fatalf("route failed: %v", err)
+		}
+	}
+	if err := router.Drain(context.Background()); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+
+	if len(escalated) != 1 || escalated[0] != "this is terrible" {
+		t.Errorf("escalated = %v, want only the negative message", escalated)
+	}
+}
+
+// stubChannel captures the router's message handler.
+type stubChannel struct {
+	handler channels.MessageHandler
+}
+
+func (s *stubChannel) Name() string                        { return "stub" }
+func (s *stubChannel) Connect(context.Context) error       { return nil }
+func (s *stubChannel) Disconnect(context.Context) error    { return nil }
+func (s *stubChannel) OnMessage(h channels.MessageHandler) { s.handler = h }
+func (s *stubChannel) OnEvent(channels.EventHandler)       {}
+
+func (s *stubChannel) Send(context.Context, string, channels.OutgoingMessage) error {
+	return nil
+}
@@ -0,0 +1,81 @@
+package annotate
+
+import (
+	"context"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// KeywordClassifier assigns a label when a message contains any of the
+// label's keywords. It is a lightweight rule-based classifier for intents
+// or sentiment that needs no model.
+type KeywordClassifier struct {
+	// Key is the annotation key to set (e.g., KeyIntent).
+	Key string
+
+	// Labels maps each label to its keywords, matched case-insensitively
+	// as whole words.
+	Labels map[string][]string
+
+	// Order lists labels by precedence when several match. Labels not
+	// listed are checked afterwards in unspecified order.
+	Order []string
+}
+
+// Name returns the classifier name.
+func (k *KeywordClassifier) Name() string {
+	return "keyword:" + k.Key
+}
+
+// Classify sets Key to the first label with a matching keyword.
+func (k *KeywordClassifier) Classify(_ context.Context, msg channels.IncomingMessage) (Annotations, error) {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(msg.Content), isSeparator) {
+		words[w] = true
+	}
+
+	for _, label := range k.labels() {
+		for _, kw := range k.Labels[label] {
+			if matchKeyword(words, msg.Content, kw) {
+				return Annotations{k.Key: label}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// labels returns labels in precedence order.
+func (k *KeywordClassifier) labels() []string {
+	labels := make([]string, 0, len(k.Labels))
+	seen := make(map[string]bool, len(k.Order))
+	for _, l := range k.Order {
+		if _, ok := k.Labels[l]; ok && !seen[l] {
+			labels = append(labels, l)
+			seen[l] = true
+		}
+	}
+	for l := range k.Labels {
+		if !seen[l] {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// matchKeyword matches a single word against the word set, and a phrase
+// against the content.
+func matchKeyword(words map[string]bool, content, kw string) bool {
+	kw = strings.ToLower(kw)
+	if strings.ContainsFunc(kw, isSeparator) {
+		return strings.Contains(strings.ToLower(content), kw)
+	}
+	return words[kw]
+}
+
+func isSeparator(r rune) bool {
+	return !(r == '\'' || r == '-' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+}
+
+// Ensure KeywordClassifier implements Classifier.
+var _ Classifier = (*KeywordClassifier)(nil)
@@ -0,0 +1,214 @@
+// Package bridge mirrors messages between chats on different channels.
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// MetadataKey marks outgoing messages sent by a bridge.
+const MetadataKey = "bridged"
+
+// DefaultEchoWindow is how long a mirrored message is remembered so that
+// its echo from the destination chat is not mirrored back.
+const DefaultEchoWindow = time.Minute
+
+// Endpoint identifies a chat on a channel.
+type Endpoint struct {
+	Channel string
+	ChatID  string
+}
+
+func (e Endpoint) String() string {
+	return e.Channel + ":" + e.ChatID
+}
+
+// Link connects two chats. Messages are mirrored both ways unless OneWay
+// is set, in which case only messages from A reach B.
+type Link struct {
+	A, B   Endpoint
+	OneWay bool
+}
+
+// FormatFunc renders a message for a destination chat.
+type FormatFunc func(msg channels.IncomingMessage) string
+
+// Config configures a Bridge.
+type Config struct {
+	// Sender delivers mirrored messages.
+	Sender channels.Sender
+
+	// Links are the bridged chat pairs.
+	Links []Link
+
+	// Format renders mirrored messages (default: "[channel] sender: text").
+	Format FormatFunc
+
+	// IgnoreSenders are sender IDs never mirrored, typically the bots' own
+	// accounts on each platform.
+	IgnoreSenders []string
+
+	// EchoWindow is how long mirrored messages are remembered for loop
+	// prevention (default: 1m).
+	EchoWindow time.Duration
+
+	// Logger is the logger to use.
+	Logger *slog.Logger
+}
+
+// Bridge mirrors messages between linked chats with sender attribution.
+//
+// Loops are prevented three ways: messages from IgnoreSenders are skipped,
+// messages carrying MetadataKey are skipped, and a message arriving in a
+// chat with the same content the bridge just sent there is treated as the
+// bridge's own echo.
+type Bridge struct {
+	sender channels.Sender
+	routes map[Endpoint][]Endpoint
+	format FormatFunc
+	ignore map[string]bool
+	window time.Duration
+	logger *slog.Logger
+	now    func() time.Time
+	mu     sync.Mutex
+	echoes map[string]time.Time
+	swept  time.Time
+}
+
+// New creates a new Bridge.
+func New(config Config) (*Bridge, error) {
+	if config.Sender == nil {
+		return nil, fmt.Errorf("bridge sender required")
+	}
+	if config.Format == nil {
+		config.Format = DefaultFormat
+	}
+	if config.EchoWindow <= 0 {
+		config.EchoWindow = DefaultEchoWindow
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	b := &Bridge{
+		sender: config.Sender,
+		routes: make(map[Endpoint][]Endpoint),
+		format: config.Format,
+		ignore: make(map[string]bool),
+		window: config.EchoWindow,
+		logger: config.Logger,
+		now:    time.Now,
+		echoes: make(map[string]time.Time),
+	}
+	for _, link := range config.Links {
+		if link.A == link.B {
+			return nil, fmt.Errorf("bridge link %s connects a chat to itself", link.A)
+		}
+		b.routes[link.A] = append(b.routes[link.A], link.B)
+		if !link.OneWay {
+			b.routes[link.B] = append(b.routes[link.B], link.A)
+		}
+	}
+	for _, id := range config.IgnoreSenders {
+		b.ignore[id] = true
+	}
+	return b, nil
+}
+
+// DefaultFormat renders "[channel] sender: content".
+func DefaultFormat(msg channels.IncomingMessage) string {
+	sender := msg.SenderName
+	if sender == "" {
+		sender = msg.SenderID
+	}
+	return fmt.Sprintf("[%s] %s: %s", msg.ChannelName, sender, msg.Content)
+}
+
+// Pattern matches messages from bridged chats.
+func (b *Bridge) Pattern() channels.RoutePattern {
+	return channels.Where(func(msg channels.IncomingMessage) bool {
+		_, ok := b.routes[Endpoint{Channel: msg.ChannelName, ChatID: msg.ChatID}]
+		return ok
+	})
+}
+
+// Handler returns a message handler that mirrors messages to linked chats.
+// Register it with router.OnMessage(b.Pattern(), b.Handler()).
+func (b *Bridge) Handler() channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		from := Endpoint{Channel: msg.ChannelName, ChatID: msg.ChatID}
+		targets := b.routes[from]
+		if len(targets) == 0 || b.skip(from, msg) {
+			return nil
+		}
+
+		out := channels.OutgoingMessage{
+			Content:  b.format(msg),
+			Media:    msg.Media,
+			Metadata: map[string]interface{}{MetadataKey: true},
+		}
+
+		var errs []error
+		for _, to := range targets {
+			b.remember(to, out.Content)
+			if err := b.sender.Send(ctx, to.Channel, to.ChatID, out); err != nil {
+				b.logger.Error("bridge send failed", "from", from, "to", to, "error", err)
+				errs = append(errs, fmt.Errorf("mirror to %s: %w", to, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// skip reports whether a message must not be mirrored.
+func (b *Bridge) skip(from Endpoint, msg channels.IncomingMessage) bool {
+	if b.ignore[msg.SenderID] {
+		return true
+	}
+	if bridged, _ := msg.Metadata[MetadataKey].(bool); bridged {
+		return true
+	}
+	return b.isEcho(from, msg.Content)
+}
+
+// remember records content sent to a chat.
+func (b *Bridge) remember(to Endpoint, content string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if now.Sub(b.swept) > b.window {
+		for k, t := range b.echoes {
+			if now.Sub(t) > b.window {
+				delete(b.echoes, k)
+			}
+		}
+		b.swept = now
+	}
+	b.echoes[echoKey(to, content)] = now
+}
+
+// isEcho reports whether content was recently sent to a chat by the bridge,
+// consuming the record.
+func (b *Bridge) isEcho(from Endpoint, content string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := echoKey(from, content)
+	t, ok := b.echoes[key]
+	if !ok {
+		return false
+	}
+	delete(b.echoes, key)
+	return b.now().Sub(t) <= b.window
+}
+
+func echoKey(e Endpoint, content string) string {
+	return e.Channel + "\x00" + e.ChatID + "\x00" + content
+}
@@ -0,0 +1,84 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+// to returns the chat a message was sent to.
+func to(m channelstest.Message) Endpoint {
+	return Endpoint{m.Channel, m.ChatID}
+}
+
+func TestBridge(t *testing.T) {
+	ctx := context.Background()
+	discord := Endpoint{Channel: "discord", ChatID: "general"}
+	telegram := Endpoint{Channel: "telegram", ChatID: "-100"}
+	sender := &channelstest.Sender{}
+	b, err := New(Config{
+		Sender:        sender,
+		Links:         []Link{{A: discord, B: telegram}},
+		IgnoreSenders: []string{"bot"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	handler := b.Handler()
+
+	msg := channels.IncomingMessage{ChannelName: "discord", ChatID: "general", SenderName: "alice", Content: "hello"}
+	if !b.Pattern().Match(msg) {
+		t.Fatal("pattern should match bridged chat")
+	}
+	if err := handler(ctx, msg); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if len(sender.Sent()) != 1 || to(sender.Sent()[0]) != telegram || sender.Sent()[0].Content != "[discord] alice: hello" {
+		t.Fatalf("sent = %+v, want attributed message in telegram", sender.Sent())
+	}
+
+	// The mirrored message echoing back from telegram is not mirrored again.
+	echo := channels.IncomingMessage{ChannelName: "telegram", ChatID: "-100", SenderID: "tgbot", Content: "[discord] alice: hello"}
+	if err := handler(ctx, echo); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	// Nor are messages from ignored senders or marked as bridged.
+	_ = handler(ctx, channels.IncomingMessage{ChannelName: "telegram", ChatID: "-100", SenderID: "bot", Content: "x"})
+	_ = handler(ctx, channels.IncomingMessage{ChannelName: "telegram", ChatID: "-100", Content: "y",
+		Metadata: map[string]interface{}{MetadataKey: true}})
+	if len(sender.Sent()) != 1 {
+		t.Fatalf("sent = %+v, want loop prevented", sender.Sent())
+	}
+
+	reply := channels.IncomingMessage{ChannelName: "telegram", ChatID: "-100", SenderID: "42", Content: "hi alice"}
+	if err := handler(ctx, reply); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if len(sender.Sent()) != 2 || to(sender.Sent()[1]) != discord || sender.Sent()[1].Content != "[telegram] 42: hi alice" {
+		t.Errorf("sent = %+v, want reply mirrored to discord", sender.Sent())
+	}
+}
+
+func TestBridgeOneWay(t *testing.T) {
+	sender := &channelstest.Sender{}
+	b, err := New(Config{
+		Sender: sender,
+		Links: []Link{{
+			A:      Endpoint{Channel: "discord", ChatID: "announcements"},
+			B:      Endpoint{Channel: "telegram", ChatID: "-100"},
+			OneWay: true,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	msg := channels.IncomingMessage{ChannelName: "telegram", ChatID: "-100", Content: "hi"}
+	if b.Pattern().Match(msg) {
+		t.Error("one-way destination should not match")
+	}
+	if err := b.Handler()(context.Background(), msg); err != nil || len(sender.Sent()) != 0 {
+		t.Errorf("sent = %+v, err = %v, want nothing", sender.Sent(), err)
+	}
+}
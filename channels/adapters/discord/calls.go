@@ -0,0 +1,117 @@
+package discord
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// voiceTracker tracks who is in which voice channel so joins and leaves can
+// be turned into call events.
+type voiceTracker struct {
+	mu        sync.Mutex
+	members   map[string]string // guild/user -> voice channel
+	occupants map[string]int    // voice channel -> member count
+}
+
+func newVoiceTracker() *voiceTracker {
+	return &voiceTracker{
+		members:   make(map[string]string),
+		occupants: make(map[string]int),
+	}
+}
+
+// move records a user moving to channelID ("" = disconnected). It returns
+// the channel left, if any, whether that channel is now empty, and whether
+// the user is the first member of the joined channel.
+func (t *voiceTracker) move(guildID, userID, channelID string) (left string, leftEmpty, joinedFirst bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := guildID + "/" + userID
+	prev := t.members[key]
+	if prev == channelID {
+		return "", false, false
+	}
+	if prev != "" {
+		t.occupants[prev]--
+		if t.occupants[prev] <= 0 {
+			delete(t.occupants, prev)
+			leftEmpty = true
+		}
+	}
+	if channelID == "" {
+		delete(t.members, key)
+	} else {
+		t.members[key] = channelID
+		t.occupants[channelID]++
+		joinedFirst = t.occupants[channelID] == 1
+	}
+	return prev, leftEmpty, joinedFirst
+}
+
+// handleVoiceState emits call events for a voice state change.
+func (a *Adapter) handleVoiceState(ctx context.Context, v *discordgo.VoiceStateUpdate) {
+	if v.VoiceState == nil {
+		return
+	}
+	name := v.UserID
+	if v.Member != nil && v.Member.User != nil {
+		name = v.Member.User.Username
+	}
+	participant := map[string]interface{}{
+		channels.EventDataCallKind:        channels.CallKindVoice,
+		channels.EventDataParticipantID:   v.UserID,
+		channels.EventDataParticipantName: name,
+	}
+
+	left, leftEmpty, joinedFirst := a.voice.move(v.GuildID, v.UserID, v.ChannelID)
+	if left != "" {
+		a.emitEvent(ctx, channels.EventTypeCallParticipantLeft, left, participant)
+		if leftEmpty {
+			a.emitEvent(ctx, channels.EventTypeCallEnded, left, map[string]interface{}{
+				channels.EventDataCallKind: channels.CallKindVoice,
+			})
+		}
+	}
+	if v.ChannelID != "" && v.ChannelID != left {
+		if joinedFirst {
+			a.emitEvent(ctx, channels.EventTypeCallStarted, v.ChannelID, map[string]interface{}{
+				channels.EventDataCallKind: channels.CallKindVoice,
+			})
+		}
+		a.emitEvent(ctx, channels.EventTypeCallParticipantJoined, v.ChannelID, participant)
+	}
+}
+
+// handleStage emits call events for a stage instance starting or ending.
+func (a *Adapter) handleStage(ctx context.Context, eventType channels.EventType, stage *discordgo.StageInstance) {
+	if stage == nil {
+		return
+	}
+	a.emitEvent(ctx, eventType, stage.ChannelID, map[string]interface{}{
+		channels.EventDataCallKind:  channels.CallKindStage,
+		channels.EventDataCallTopic: stage.Topic,
+	})
+}
+
+// emitEvent sends an event to the registered event handler.
+func (a *Adapter) emitEvent(ctx context.Context, eventType channels.EventType, chatID string, data map[string]interface{}) {
+	if a.eventHandler == nil {
+		return
+	}
+	event := channels.Event{
+		Type:        eventType,
+		ChannelName: "discord",
+		ChatID:      chatID,
+		Data:        data,
+		Timestamp:   time.Now(),
+	}
+	if err := a.eventHandler(ctx, event); err != nil {
+		a.logger.Error("event handler error", "type", eventType, "error", err)
+	}
+}
@@ -0,0 +1,330 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DefaultDeferAfter is how long a slash command may go unanswered before
+// the adapter defers the reply. Discord requires an answer within three
+// seconds.
+const DefaultDeferAfter = 2 * time.Second
+
+// interactionLifetime is how long interaction tokens accept replies.
+const interactionLifetime = 15 * time.Minute
+
+// optionTypes maps envoy option types to Discord's.
+var optionTypes = map[channels.CommandOptionType]discordgo.ApplicationCommandOptionType{
+	"":                            discordgo.ApplicationCommandOptionString,
+	channels.CommandOptionString:  discordgo.ApplicationCommandOptionString,
+	channels.CommandOptionInteger: discordgo.ApplicationCommandOptionInteger,
+	channels.CommandOptionNumber:  discordgo.ApplicationCommandOptionNumber,
+	channels.CommandOptionBoolean: discordgo.ApplicationCommandOptionBoolean,
+	channels.CommandOptionUser:    discordgo.ApplicationCommandOptionUser,
+	channels.CommandOptionChannel: discordgo.ApplicationCommandOptionChannel,
+}
+
+// applicationCommands converts command specs to slash commands.
+func applicationCommands(specs []channels.CommandSpec) []*discordgo.ApplicationCommand {
+	cmds := make([]*discordgo.ApplicationCommand, 0, len(specs))
+	for _, spec := range specs {
+		cmd := &discordgo.ApplicationCommand{
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        spec.Name,
+			Description: spec.Description,
+		}
+		for _, o := range spec.Options {
+			option := &discordgo.ApplicationCommandOption{
+				Type:        optionTypes[o.Type],
+				Name:        o.Name,
+				Description: o.Description,
+				Required:    o.Required,
+			}
+			for _, c := range o.Choices {
+				option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: c, Value: c})
+			}
+			cmd.Options = append(cmd.Options, option)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// RegisterCommands replaces the bot's slash commands: in the configured
+// guild, or globally without one. Commands are registered again on each
+// connect.
+func (a *Adapter) RegisterCommands(ctx context.Context, specs []channels.CommandSpec) error {
+	a.mu.Lock()
+	a.commands = specs
+	a.mu.Unlock()
+	if a.session == nil {
+		return nil
+	}
+	return a.registerCommands(ctx)
+}
+
+// registerCommands registers the stored commands with Discord.
+func (a *Adapter) registerCommands(ctx context.Context) error {
+	a.mu.Lock()
+	cmds := applicationCommands(a.commands)
+	a.mu.Unlock()
+	_, err := a.session.ApplicationCommandBulkOverwrite(a.session.State.User.ID, a.guildID, cmds, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("register commands: %w", rateLimited(err))
+	}
+	return nil
+}
+
+// commandReply is a slash command invocation accepting replies.
+type commandReply struct {
+	mu          sync.Mutex
+	interaction *discordgo.Interaction
+	timer       *time.Timer
+
+	// deferred is set once Discord shows that the bot is thinking, and
+	// answered once the first reply is shown.
+	deferred bool
+	answered bool
+
+	// responseID is the message answering the invocation; messages lists
+	// it and the follow-ups.
+	responseID string
+	messages   []string
+}
+
+// handleCommand reports a slash command as a message. Replies to it answer
+// the invocation; the reply is deferred if it takes longer than
+// DeferAfter.
+func (a *Adapter) handleCommand(ctx context.Context, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil || a.messageHandler == nil || data.CommandType != discordgo.ChatApplicationCommand {
+		return
+	}
+
+	cmd := &channels.Command{Name: data.Name, Options: map[string]interface{}{}}
+	args := []string{"/" + data.Name}
+	for _, o := range data.Options {
+		value := optionValue(o)
+		cmd.Options[o.Name] = value
+		args = append(args, fmt.Sprint(value))
+	}
+	a.trackReply(i.Interaction)
+
+	chatType, threadID := a.chatType(i.GuildID, i.ChannelID)
+	msg := channels.IncomingMessage{
+		ID:          i.ID,
+		ChannelName: "discord",
+		ChatID:      i.ChannelID,
+		ChatType:    chatType,
+		ThreadID:    threadID,
+		SenderID:    user.ID,
+		SenderName:  user.Username,
+		Content:     strings.Join(args, " "),
+		MentionsBot: true,
+		Command:     cmd,
+		Timestamp:   time.Now(),
+		Metadata:    map[string]interface{}{"guild_id": i.GuildID},
+	}
+	if err := a.messageHandler(ctx, msg); err != nil {
+		a.logger.Error("message handler error", "error", err)
+	}
+}
+
+// optionValue returns the value of a command option.
+func optionValue(o *discordgo.ApplicationCommandInteractionDataOption) interface{} {
+	switch o.Type {
+	case discordgo.ApplicationCommandOptionString:
+		return o.StringValue()
+	case discordgo.ApplicationCommandOptionInteger:
+		return o.IntValue()
+	case discordgo.ApplicationCommandOptionNumber:
+		return o.FloatValue()
+	case discordgo.ApplicationCommandOptionBoolean:
+		return o.BoolValue()
+	}
+	// Users, channels, and roles are given by ID
+	return o.Value
+}
+
+// trackReply accepts replies to an invocation until its token expires.
+func (a *Adapter) trackReply(i *discordgo.Interaction) {
+	r := &commandReply{interaction: i}
+	r.mu.Lock()
+	r.timer = time.AfterFunc(a.deferAfter, func() { a.deferReply(r) })
+	r.mu.Unlock()
+	a.replies.Store(i.ID, r)
+
+	time.AfterFunc(interactionLifetime, func() {
+		a.replies.Delete(i.ID)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, id := range r.messages {
+			a.replyMessages.Delete(id)
+		}
+	})
+}
+
+// deferReply shows that the bot is thinking if no reply was sent yet.
+func (a *Adapter) deferReply(r *commandReply) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.answered || r.deferred {
+		return
+	}
+	err := a.session.InteractionRespond(r.interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		a.logger.Warn("defer command reply failed", "error", err)
+		return
+	}
+	r.deferred = true
+}
+
+// commandReply returns the invocation a message replying to id answers.
+func (a *Adapter) commandReply(id string) (*commandReply, bool) {
+	if id == "" {
+		return nil, false
+	}
+	r, ok := a.replies.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return r.(*commandReply), true
+}
+
+// reply sends a message answering an invocation: as its response, as the
+// deferred response, or as a follow-up once it was answered. Ephemeral
+// replies (data.Flags) are shown only to the invoking user; a deferred
+// response cannot become ephemeral, so it is replaced by an ephemeral
+// follow-up. Stickers are not supported, nor are polls in deferred
+// responses and follow-ups.
+func (a *Adapter) reply(ctx context.Context, r *commandReply, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(data.StickerIDs) > 0 || (data.Poll != nil && (r.deferred || r.answered)) {
+		a.logger.Warn("stickers and polls are not supported in this command reply")
+	}
+	ephemeral := data.Flags&discordgo.MessageFlagsEphemeral != 0
+
+	var sent *discordgo.Message
+	var err error
+	response := false
+	switch {
+	case !r.answered && !r.deferred:
+		r.timer.Stop()
+		err = a.session.InteractionRespond(r.interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:    data.Content,
+				Components: data.Components,
+				Embeds:     data.Embeds,
+				Files:      data.Files,
+				Poll:       data.Poll,
+				Flags:      data.Flags & discordgo.MessageFlagsEphemeral,
+			},
+		}, discordgo.WithContext(ctx))
+		if err == nil {
+			r.answered, response = true, true
+			sent, err = a.session.InteractionResponse(r.interaction, discordgo.WithContext(ctx))
+		}
+	case !r.answered && !ephemeral:
+		edit := &discordgo.WebhookEdit{Content: &data.Content, Files: data.Files}
+		if len(data.Components) > 0 {
+			edit.Components = &data.Components
+		}
+		if len(data.Embeds) > 0 {
+			edit.Embeds = &data.Embeds
+		}
+		sent, err = a.session.InteractionResponseEdit(r.interaction, edit, discordgo.WithContext(ctx))
+		r.answered, response = err == nil, true
+	default:
+		sent, err = a.session.FollowupMessageCreate(r.interaction, true, &discordgo.WebhookParams{
+			Content:    data.Content,
+			Components: data.Components,
+			Embeds:     data.Embeds,
+			Files:      data.Files,
+			Flags:      data.Flags & discordgo.MessageFlagsEphemeral,
+		}, discordgo.WithContext(ctx))
+		if err == nil && !r.answered {
+			// Remove the public "thinking" response the follow-up replaces
+			r.answered = true
+			if err := a.session.InteractionResponseDelete(r.interaction, discordgo.WithContext(ctx)); err != nil {
+				a.logger.Warn("delete deferred command reply failed", "error", err)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reply to command: %w", rateLimited(err))
+	}
+
+	if response {
+		r.responseID = sent.ID
+	}
+	r.messages = append(r.messages, sent.ID)
+	a.replyMessages.Store(sent.ID, r)
+	return sent, nil
+}
+
+// editReply edits a message answering an invocation.
+func (a *Adapter) editReply(ctx context.Context, r *commandReply, messageID string, edit *discordgo.WebhookEdit) error {
+	var err error
+	if messageID == r.responseID {
+		_, err = a.session.InteractionResponseEdit(r.interaction, edit, discordgo.WithContext(ctx))
+	} else {
+		_, err = a.session.FollowupMessageEdit(r.interaction, messageID, edit, discordgo.WithContext(ctx))
+	}
+	if err != nil {
+		return fmt.Errorf("edit message: %w", rateLimited(err))
+	}
+	return nil
+}
+
+// deleteReply deletes a message answering an invocation.
+func (a *Adapter) deleteReply(ctx context.Context, r *commandReply, messageID string) error {
+	var err error
+	if messageID == r.responseID {
+		err = a.session.InteractionResponseDelete(r.interaction, discordgo.WithContext(ctx))
+	} else {
+		err = a.session.FollowupMessageDelete(r.interaction, messageID, discordgo.WithContext(ctx))
+	}
+	if err != nil {
+		return fmt.Errorf("delete message: %w", rateLimited(err))
+	}
+	return nil
+}
+
+// replyStream streams into the answer to an invocation. Edits go through
+// Adapter.EditMessage, which recognizes command replies.
+type replyStream struct {
+	*Adapter
+	command *commandReply
+}
+
+// SendWithReceipt answers the invocation.
+func (s replyStream) SendWithReceipt(ctx context.Context, channelID string, msg channels.OutgoingMessage) (channels.MessageReceipt, error) {
+	receipt := channels.MessageReceipt{ChannelName: "discord", ChatID: channelID}
+	data, err := s.messageSend(msg)
+	if err != nil {
+		return receipt, err
+	}
+	sent, err := s.reply(ctx, s.command, data)
+	if err != nil {
+		return receipt, err
+	}
+	receipt.MessageID, receipt.Timestamp = sent.ID, sent.Timestamp
+	return receipt, nil
+}
+
+var _ channels.CommandRegistrar = (*Adapter)(nil)
@@ -0,0 +1,140 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Custom ID prefixes of rendered components. The adapter acknowledges
+// interactions with them itself; components sent with Message are left to
+// the handlers, which may respond with a Modal.
+const (
+	customIDComponent  = "envoy|c|"
+	customIDQuickReply = "envoy|q|"
+)
+
+// buttonStyles maps envoy button styles to Discord's.
+var buttonStyles = map[channels.ButtonStyle]discordgo.ButtonStyle{
+	channels.ButtonPrimary:   discordgo.PrimaryButton,
+	channels.ButtonSecondary: discordgo.SecondaryButton,
+	channels.ButtonSuccess:   discordgo.SuccessButton,
+	channels.ButtonDanger:    discordgo.DangerButton,
+}
+
+// messageComponents renders message components as action rows.
+func messageComponents(rows [][]channels.Component) ([]discordgo.MessageComponent, error) {
+	if len(rows) > 5 {
+		return nil, errors.New("at most 5 component rows allowed")
+	}
+	var out []discordgo.MessageComponent
+	for _, row := range rows {
+		if len(row) > 5 {
+			return nil, errors.New("at most 5 buttons per row allowed")
+		}
+		var components []discordgo.MessageComponent
+		for _, c := range row {
+			switch {
+			case c.Kind == channels.ComponentSelect:
+				menu := discordgo.SelectMenu{
+					MenuType:    discordgo.StringSelectMenu,
+					CustomID:    customIDComponent + c.ID,
+					Placeholder: c.Placeholder,
+				}
+				for _, o := range c.Options {
+					menu.Options = append(menu.Options, discordgo.SelectMenuOption{
+						Label: o.Label, Value: o.Value, Description: o.Description,
+					})
+				}
+				components = append(components, menu)
+			case c.URL != "":
+				components = append(components, discordgo.Button{Label: c.Label, Style: discordgo.LinkButton, URL: c.URL})
+			case c.Kind == channels.ComponentQuickReply:
+				components = append(components, discordgo.Button{
+					Label: c.Label, Style: discordgo.SecondaryButton, CustomID: customIDQuickReply + c.Label,
+				})
+			default:
+				style, ok := buttonStyles[c.Style]
+				if !ok {
+					style = discordgo.SecondaryButton
+				}
+				components = append(components, discordgo.Button{
+					Label: c.Label, Style: style, CustomID: customIDComponent + c.ID,
+				})
+			}
+		}
+		out = append(out, discordgo.ActionsRow{Components: components})
+	}
+	return out, nil
+}
+
+// handleComponent acknowledges an interaction with a rendered component
+// and reports it: as the user's message for quick replies, otherwise as an
+// interaction event. It reports false for other components.
+func (a *Adapter) handleComponent(ctx context.Context, i *discordgo.InteractionCreate) bool {
+	data := i.MessageComponentData()
+	if !strings.HasPrefix(data.CustomID, customIDComponent) && !strings.HasPrefix(data.CustomID, customIDQuickReply) {
+		return false
+	}
+	err := a.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		a.logger.Warn("acknowledge interaction failed", "error", err)
+	}
+
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return true
+	}
+
+	if label, ok := strings.CutPrefix(data.CustomID, customIDQuickReply); ok {
+		if a.messageHandler == nil {
+			return true
+		}
+		chatType := channels.ChannelTypeGroup
+		if i.GuildID == "" {
+			chatType = channels.ChannelTypeDM
+		}
+		msg := channels.IncomingMessage{
+			ID:          i.ID,
+			ChannelName: "discord",
+			ChatID:      i.ChannelID,
+			ChatType:    chatType,
+			SenderID:    user.ID,
+			SenderName:  user.Username,
+			Content:     label,
+			MentionsBot: true,
+			Timestamp:   time.Now(),
+			Metadata:    map[string]interface{}{"guild_id": i.GuildID},
+		}
+		if i.Message != nil {
+			msg.ReplyTo = i.Message.ID
+		}
+		if err := a.messageHandler(ctx, msg); err != nil {
+			a.logger.Error("message handler error", "error", err)
+		}
+		return true
+	}
+
+	event := map[string]interface{}{
+		channels.EventDataCustomID: strings.TrimPrefix(data.CustomID, customIDComponent),
+		channels.EventDataUserID:   user.ID,
+	}
+	if len(data.Values) > 0 {
+		event[channels.EventDataValues] = data.Values
+	}
+	if i.Message != nil {
+		event[channels.EventDataMessageID] = i.Message.ID
+	}
+	a.emitEvent(ctx, channels.EventTypeInteraction, i.ChannelID, event)
+	return true
+}
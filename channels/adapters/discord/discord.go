@@ -2,23 +2,53 @@
 package discord
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 
 	"github.com/agentplexus/envoy/channels"
 )
 
+// heartbeatTimeout is how long the gateway may go without acknowledging a
+// heartbeat before the connection is considered dead.
+const heartbeatTimeout = 2 * time.Minute
+
 // Adapter implements the Channel interface for Discord.
 type Adapter struct {
 	session        *discordgo.Session
 	token          string
 	guildID        string
+	rateLimits     bool
 	logger         *slog.Logger
 	messageHandler channels.MessageHandler
 	eventHandler   channels.EventHandler
+	voice          *voiceTracker
+
+	mu         sync.Mutex
+	commands   []channels.CommandSpec
+	deferAfter time.Duration
+
+	autoArchive      time.Duration
+	guildAutoArchive map[string]time.Duration
+	followThreads    bool
+	memberEvents     bool
+
+	// Voice channel connections by guild ID
+	voiceChats   map[string]*voiceChat
+	voiceSilence time.Duration
+	maxUtterance time.Duration
+
+	// Slash command invocations by interaction ID, and by the IDs of
+	// the messages answering them
+	replies       sync.Map
+	replyMessages sync.Map
 }
 
 // Config configures the Discord adapter.
@@ -26,6 +56,46 @@ type Config struct {
 	Token   string
 	GuildID string
 	Logger  *slog.Logger
+
+	// ReturnRateLimits makes 429 responses fail with a
+	// *channels.RateLimitedError instead of being retried inside the
+	// Discord client, so the router can schedule the retry.
+	ReturnRateLimits bool
+
+	// Commands are registered as slash commands on connect.
+	Commands []channels.CommandSpec
+
+	// DeferAfter is how long a slash command may go unanswered before
+	// Discord is told that the bot is thinking (default:
+	// DefaultDeferAfter).
+	DeferAfter time.Duration
+
+	// AutoArchive is how long threads and forum posts created by the bot
+	// stay active without messages (default: DefaultAutoArchive).
+	// Discord accepts an hour, a day, three days, or a week; other
+	// durations are rounded up.
+	AutoArchive time.Duration
+
+	// GuildAutoArchive overrides AutoArchive for the guilds with the
+	// given IDs.
+	GuildAutoArchive map[string]time.Duration
+
+	// FollowThreads joins threads and forum posts as they are created in
+	// the bot's guilds, making the bot a member of the conversations.
+	FollowThreads bool
+
+	// MemberEvents reports members joining and leaving the bot's guilds
+	// as member_joined and member_left events. It requires the privileged
+	// Server Members intent, enabled in the Discord developer portal.
+	MemberEvents bool
+
+	// VoiceSilence is how long a speaker in a voice channel must pause to
+	// end an utterance (default: DefaultVoiceSilence).
+	VoiceSilence time.Duration
+
+	// MaxUtterance caps the length of a single utterance (default:
+	// DefaultMaxUtterance).
+	MaxUtterance time.Duration
 }
 
 // New creates a new Discord adapter.
@@ -36,11 +106,38 @@ func New(config Config) (*Adapter, error) {
 	if config.Logger == nil {
 		config.Logger = slog.Default()
 	}
+	if err := channels.ValidateCommands(config.Commands); err != nil {
+		return nil, err
+	}
+	if config.DeferAfter <= 0 || config.DeferAfter > 3*time.Second {
+		config.DeferAfter = DefaultDeferAfter
+	}
+	if config.AutoArchive <= 0 {
+		config.AutoArchive = DefaultAutoArchive
+	}
+	if config.VoiceSilence <= 0 {
+		config.VoiceSilence = DefaultVoiceSilence
+	}
+	if config.MaxUtterance <= 0 {
+		config.MaxUtterance = DefaultMaxUtterance
+	}
 
 	return &Adapter{
-		token:   config.Token,
-		guildID: config.GuildID,
-		logger:  config.Logger,
+		token:      config.Token,
+		guildID:    config.GuildID,
+		rateLimits: config.ReturnRateLimits,
+		logger:     channels.ChannelLogger(config.Logger, "discord"),
+		commands:   config.Commands,
+		deferAfter: config.DeferAfter,
+
+		autoArchive:      config.AutoArchive,
+		guildAutoArchive: config.GuildAutoArchive,
+		followThreads:    config.FollowThreads,
+		memberEvents:     config.MemberEvents,
+
+		voiceChats:   make(map[string]*voiceChat),
+		voiceSilence: config.VoiceSilence,
+		maxUtterance: config.MaxUtterance,
 	}, nil
 }
 
@@ -49,6 +146,11 @@ func (a *Adapter) Name() string {
 	return "discord"
 }
 
+// Capabilities returns the platform's limits. Discord limits messages to 2000 characters.
+func (a *Adapter) Capabilities() channels.Capabilities {
+	return channels.Capabilities{MaxMessageLength: 2000, Components: true, Stickers: true, CustomEmoji: true, ReplyThreads: true}
+}
+
 // Connect establishes connection to Discord.
 func (a *Adapter) Connect(ctx context.Context) error {
 	session, err := discordgo.New("Bot " + a.token)
@@ -57,6 +159,7 @@ func (a *Adapter) Connect(ctx context.Context) error {
 	}
 
 	a.session = session
+	a.session.ShouldRetryOnRateLimit = !a.rateLimits
 
 	// Set up message handler
 	a.session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -67,14 +170,84 @@ func (a *Adapter) Connect(ctx context.Context) error {
 
 		if a.messageHandler != nil {
 			msg := a.convertIncoming(m)
+			msg.MentionsBot = mentionsUser(m, s.State.User.ID)
 			if err := a.messageHandler(ctx, msg); err != nil {
 				a.logger.Error("message handler error", "error", err)
 			}
 		}
 	})
 
+	// Join new threads and forum posts
+	a.session.AddHandler(func(s *discordgo.Session, t *discordgo.ThreadCreate) {
+		a.followThread(t)
+	})
+
+	// Report edited and deleted messages as events
+	a.session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageUpdate) {
+		a.emitEdit(ctx, s, m)
+	})
+	a.session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageDelete) {
+		a.emitEvent(ctx, channels.EventTypeMessageDeleted, m.ChannelID, map[string]interface{}{
+			channels.EventDataMessageID: m.ID,
+		})
+	})
+
+	// Report added and removed reactions, e.g. feedback on bot replies
+	a.session.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		a.emitReaction(ctx, s, r.MessageReaction, false)
+	})
+	a.session.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionRemove) {
+		a.emitReaction(ctx, s, r.MessageReaction, true)
+	})
+
+	// Report users typing
+	a.session.AddHandler(func(s *discordgo.Session, t *discordgo.TypingStart) {
+		a.emitTyping(ctx, s, t)
+	})
+
+	// Report members joining and leaving guilds
+	a.session.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+		a.emitMember(ctx, channels.EventTypeMemberJoined, m.Member)
+	})
+	a.session.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+		a.emitMember(ctx, channels.EventTypeMemberLeft, m.Member)
+	})
+
+	// Report poll votes
+	a.session.AddHandler(func(s *discordgo.Session, v *discordgo.MessagePollVoteAdd) {
+		a.emitPollVote(ctx, v.ChannelID, v.MessageID, v.UserID, v.AnswerID, false)
+	})
+	a.session.AddHandler(func(s *discordgo.Session, v *discordgo.MessagePollVoteRemove) {
+		a.emitPollVote(ctx, v.ChannelID, v.MessageID, v.UserID, v.AnswerID, true)
+	})
+
+	// Report slash commands as messages, and button presses, selections,
+	// and form submissions as events
+	a.session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		a.handleInteraction(ctx, i)
+	})
+
+	// Report voice channel and stage activity as call events
+	a.voice = newVoiceTracker()
+	a.session.AddHandler(func(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+		a.handleVoiceState(ctx, v)
+	})
+	a.session.AddHandler(func(s *discordgo.Session, e *discordgo.StageInstanceEventCreate) {
+		a.handleStage(ctx, channels.EventTypeCallStarted, e.StageInstance)
+	})
+	a.session.AddHandler(func(s *discordgo.Session, e *discordgo.StageInstanceEventDelete) {
+		a.handleStage(ctx, channels.EventTypeCallEnded, e.StageInstance)
+	})
+
 	// Set intents
-	a.session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentsMessageContent
+	a.session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages |
+		discordgo.IntentsMessageContent | discordgo.IntentsGuildVoiceStates |
+		discordgo.IntentsGuildMessageReactions | discordgo.IntentsDirectMessageReactions |
+		discordgo.IntentGuildMessagePolls | discordgo.IntentDirectMessagePolls |
+		discordgo.IntentsGuildMessageTyping | discordgo.IntentsDirectMessageTyping
+	if a.memberEvents {
+		a.session.Identify.Intents |= discordgo.IntentsGuildMembers
+	}
 
 	// Open connection
 	if err := a.session.Open(); err != nil {
@@ -82,11 +255,32 @@ func (a *Adapter) Connect(ctx context.Context) error {
 	}
 
 	a.logger.Info("discord bot connected", "user", a.session.State.User.Username)
+
+	a.mu.Lock()
+	hasCommands := len(a.commands) > 0
+	a.mu.Unlock()
+	if hasCommands {
+		if err := a.registerCommands(ctx); err != nil {
+			a.logger.Error("slash command registration failed", "error", err)
+		}
+	}
 	return nil
 }
 
-// Disconnect closes the Discord connection.
+// Disconnect leaves voice channels and closes the Discord connection.
 func (a *Adapter) Disconnect(ctx context.Context) error {
+	a.mu.Lock()
+	guilds := make([]string, 0, len(a.voiceChats))
+	for guildID := range a.voiceChats {
+		guilds = append(guilds, guildID)
+	}
+	a.mu.Unlock()
+	for _, guildID := range guilds {
+		if err := a.LeaveVoice(ctx, guildID); err != nil {
+			a.logger.Warn("leave voice channel failed", "guild", guildID, "error", err)
+		}
+	}
+
 	if a.session != nil {
 		if err := a.session.Close(); err != nil {
 			return fmt.Errorf("close discord session: %w", err)
@@ -96,29 +290,202 @@ func (a *Adapter) Disconnect(ctx context.Context) error {
 	return nil
 }
 
+// HealthCheck reports an error if the gateway connection is down or has
+// stopped acknowledging heartbeats.
+func (a *Adapter) HealthCheck(ctx context.Context) error {
+	if a.session == nil {
+		return fmt.Errorf("discord session not connected")
+	}
+	a.session.RLock()
+	ready, lastAck := a.session.DataReady, a.session.LastHeartbeatAck
+	a.session.RUnlock()
+	if !ready {
+		return fmt.Errorf("discord gateway not ready")
+	}
+	if !lastAck.IsZero() && time.Since(lastAck) > heartbeatTimeout {
+		return fmt.Errorf("no discord heartbeat ack for %s", time.Since(lastAck).Round(time.Second))
+	}
+	return nil
+}
+
 // Send sends a message to a Discord channel.
 func (a *Adapter) Send(ctx context.Context, channelID string, msg channels.OutgoingMessage) error {
+	_, err := a.SendWithReceipt(ctx, channelID, msg)
+	return err
+}
+
+// SendMessage sends a message to a Discord channel and returns the
+// MessageID of its receipt.
+func (a *Adapter) SendMessage(ctx context.Context, channelID string, msg channels.OutgoingMessage) (string, error) {
+	receipt, err := a.SendWithReceipt(ctx, channelID, msg)
+	return receipt.MessageID, err
+}
+
+// SendWithReceipt sends a message to a Discord channel and returns its
+// receipt. Raw payloads are sent without reporting a message ID.
+func (a *Adapter) SendWithReceipt(ctx context.Context, channelID string, msg channels.OutgoingMessage) (channels.MessageReceipt, error) {
+	// Threads are channels
+	if msg.ThreadID != "" {
+		channelID = msg.ThreadID
+	}
+	receipt := channels.MessageReceipt{ChannelName: "discord", ChatID: channelID}
 	if a.session == nil {
-		return fmt.Errorf("discord session not connected")
+		return receipt, fmt.Errorf("discord session not connected")
+	}
+	if msg.Raw != nil {
+		receipt.Timestamp = time.Now()
+		return receipt, a.sendRaw(ctx, channelID, msg.Raw)
+	}
+	if receipt, ok, err := a.sendVoice(ctx, channelID, msg); ok {
+		return receipt, err
 	}
 
-	// Build message send options
+	data, err := a.messageSend(msg)
+	if err != nil {
+		return receipt, err
+	}
+	if reply, ok := a.commandReply(msg.ReplyTo); ok {
+		if msg.Ephemeral {
+			data.Flags |= discordgo.MessageFlagsEphemeral
+		}
+		sent, err := a.reply(ctx, reply, data)
+		if err != nil {
+			return receipt, err
+		}
+		receipt.MessageID, receipt.Timestamp = sent.ID, sent.Timestamp
+		return receipt, nil
+	}
+
+	sent, err := a.session.ChannelMessageSendComplex(channelID, data)
+	if err != nil {
+		return receipt, fmt.Errorf("send message: %w", rateLimited(err))
+	}
+
+	receipt.MessageID, receipt.Timestamp = sent.ID, sent.Timestamp
+	return receipt, nil
+}
+
+// messageSend builds the Discord message for msg.
+func (a *Adapter) messageSend(msg channels.OutgoingMessage) (*discordgo.MessageSend, error) {
 	data := &discordgo.MessageSend{
 		Content: msg.Content,
 	}
 
-	if msg.ReplyTo != "" {
+	if msg.ReplyTo != "" && !strings.HasPrefix(msg.ReplyTo, utteranceIDPrefix) {
 		data.Reference = &discordgo.MessageReference{
 			MessageID: msg.ReplyTo,
 		}
 	}
 
-	_, err := a.session.ChannelMessageSendComplex(channelID, data)
+	for _, m := range msg.Media {
+		switch m.Type {
+		case channels.MediaTypeSticker:
+			if m.FileID == "" {
+				a.logger.Warn("discord stickers require a sticker ID")
+				continue
+			}
+			data.StickerIDs = append(data.StickerIDs, m.FileID)
+		case channels.MediaTypeAnimation:
+			if len(m.Data) > 0 {
+				name := m.Filename
+				if name == "" {
+					name = "animation.gif"
+				}
+				data.Files = append(data.Files, &discordgo.File{
+					Name:        name,
+					ContentType: m.MimeType,
+					Reader:      bytes.NewReader(m.Data),
+				})
+			} else if m.URL != "" {
+				data.Embeds = append(data.Embeds, &discordgo.MessageEmbed{
+					Description: m.Caption,
+					Image:       &discordgo.MessageEmbedImage{URL: m.URL},
+				})
+			}
+		case channels.MediaTypeVoice, channels.MediaTypeAudio:
+			if len(m.Data) == 0 {
+				a.logger.Warn("discord audio requires data")
+				continue
+			}
+			name := m.Filename
+			if name == "" {
+				name = "audio" + audioExtension(m.MimeType)
+			}
+			data.Files = append(data.Files, &discordgo.File{
+				Name:        name,
+				ContentType: m.MimeType,
+				Reader:      bytes.NewReader(m.Data),
+			})
+		case channels.MediaTypeLocation, channels.MediaTypeContact:
+			if text := payloadText(m); text != "" {
+				data.Content = strings.TrimSpace(data.Content + "\n\n" + text)
+			}
+		case channels.MediaTypePoll:
+			if m.Poll == nil {
+				continue
+			}
+			if data.Poll != nil {
+				return nil, fmt.Errorf("discord messages hold one poll")
+			}
+			poll, err := discordPoll(m.Poll)
+			if err != nil {
+				return nil, err
+			}
+			data.Poll = poll
+		default:
+			a.logger.Warn("unsupported media type", "type", m.Type)
+		}
+	}
+
+	if len(msg.Components) > 0 {
+		components, err := messageComponents(msg.Components)
+		if err != nil {
+			return nil, err
+		}
+		data.Components = components
+	}
+	return data, nil
+}
+
+// DirectChatID opens a DM channel with a user. Ephemeral messages use it
+// since plain bot messages cannot be ephemeral outside interactions.
+func (a *Adapter) DirectChatID(ctx context.Context, userID string) (string, error) {
+	if a.session == nil {
+		return "", fmt.Errorf("discord session not connected")
+	}
+	ch, err := a.session.UserChannelCreate(userID)
 	if err != nil {
-		return fmt.Errorf("send message: %w", err)
+		return "", fmt.Errorf("create DM channel: %w", rateLimited(err))
 	}
+	return ch.ID, nil
+}
 
-	return nil
+// SendEphemeral shows a message to one user only. A reply to the user's
+// slash command becomes an ephemeral reply; anything else is sent to
+// them directly.
+func (a *Adapter) SendEphemeral(ctx context.Context, chatID, userID string, msg channels.OutgoingMessage) error {
+	if r, ok := a.commandReply(msg.ReplyTo); ok && interactionUserID(r.interaction) == userID {
+		msg.Ephemeral = true
+		return a.Send(ctx, chatID, msg)
+	}
+	dm, err := a.DirectChatID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// The original message is not in the DM
+	msg.ReplyTo, msg.ThreadID, msg.Ephemeral = "", "", false
+	return a.Send(ctx, dm, msg)
+}
+
+// interactionUserID returns the ID of the user who invoked an interaction.
+func interactionUserID(i *discordgo.Interaction) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
 }
 
 // OnMessage registers a message handler.
@@ -133,32 +500,55 @@ func (a *Adapter) OnEvent(handler channels.EventHandler) {
 
 // convertIncoming converts a Discord message to an IncomingMessage.
 func (a *Adapter) convertIncoming(m *discordgo.MessageCreate) channels.IncomingMessage {
-	chatType := channels.ChannelTypeGroup
-	// Check if it's a DM
-	if m.GuildID == "" {
-		chatType = channels.ChannelTypeDM
-	}
-
-	// Check for thread
+	chatType, threadID := a.chatType(m.GuildID, m.ChannelID)
 	if m.Thread != nil {
 		chatType = channels.ChannelTypeThread
 	}
+	metadata := map[string]interface{}{
+		"guild_id":      m.GuildID,
+		"discriminator": m.Author.Discriminator,
+	}
+	if threadID != "" {
+		a.threadMetadata(threadID, metadata)
+	}
+
+	media := append(attachments(m), incomingStickers(m.Message)...)
+	if poll := incomingPoll(m.Message); poll != nil {
+		media = append(media, channels.Media{Type: channels.MediaTypePoll, Poll: poll})
+	}
+	content := m.Content
+	if content == "" {
+		content = channels.MediaContent(media)
+	}
 
 	return channels.IncomingMessage{
 		ID:          m.ID,
 		ChannelName: "discord",
 		ChatID:      m.ChannelID,
 		ChatType:    chatType,
+		ThreadID:    threadID,
 		SenderID:    m.Author.ID,
 		SenderName:  m.Author.Username,
-		Content:     m.Content,
+		Content:     content,
+		Media:       media,
 		ReplyTo:     getReplyTo(m),
+		Mentions:    mentionIDs(m),
+		CustomEmoji: channels.ParseCustomEmoji(m.Content),
 		Timestamp:   m.Timestamp,
-		Metadata: map[string]interface{}{
-			"guild_id":      m.GuildID,
-			"discriminator": m.Author.Discriminator,
-		},
+		Metadata:    metadata,
+	}
+}
+
+// chatType returns the type of a chat, and its channel ID if it is a
+// thread.
+func (a *Adapter) chatType(guildID, channelID string) (channels.ChannelType, string) {
+	if ch, err := a.session.State.Channel(channelID); err == nil && ch.IsThread() {
+		return channels.ChannelTypeThread, channelID
 	}
+	if guildID == "" {
+		return channels.ChannelTypeDM, ""
+	}
+	return channels.ChannelTypeGroup, ""
 }
 
 // getReplyTo extracts the reply-to message ID if present.
@@ -169,5 +559,72 @@ func getReplyTo(m *discordgo.MessageCreate) string {
 	return ""
 }
 
-// Ensure Adapter implements Channel interface.
-var _ channels.Channel = (*Adapter)(nil)
+// mentionIDs returns the IDs of users mentioned in a message.
+func mentionIDs(m *discordgo.MessageCreate) []string {
+	ids := make([]string, 0, len(m.Mentions))
+	for _, u := range m.Mentions {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+// mentionsUser reports whether a message mentions or replies to a user.
+func mentionsUser(m *discordgo.MessageCreate, userID string) bool {
+	for _, u := range m.Mentions {
+		if u.ID == userID {
+			return true
+		}
+	}
+	ref := m.ReferencedMessage
+	return ref != nil && ref.Author != nil && ref.Author.ID == userID
+}
+
+// rateLimited converts Discord rate limit errors to *channels.RateLimitedError.
+func rateLimited(err error) error {
+	var rl *discordgo.RateLimitError
+	if errors.As(err, &rl) && rl.RateLimit != nil && rl.TooManyRequests != nil {
+		return &channels.RateLimitedError{Channel: "discord", RetryAfter: rl.RetryAfter, Err: err}
+	}
+	return err
+}
+
+// attachments returns the files attached to a message. Their CDN URLs
+// are signed and expire after about a day.
+func attachments(m *discordgo.MessageCreate) []channels.Media {
+	var media []channels.Media
+	for _, a := range m.Attachments {
+		media = append(media, channels.Media{
+			Type:     channels.MediaTypeFor(a.ContentType),
+			URL:      a.URL,
+			MimeType: a.ContentType,
+			Filename: a.Filename,
+			Size:     int64(a.Size),
+		})
+	}
+	return media
+}
+
+// Ensure Adapter implements Channel, Threader, DirectMessenger,
+// EphemeralSender, and CapabilityReporter interfaces.
+var (
+	_ channels.Channel            = (*Adapter)(nil)
+	_ channels.Threader           = (*Adapter)(nil)
+	_ channels.DirectMessenger    = (*Adapter)(nil)
+	_ channels.EphemeralSender    = (*Adapter)(nil)
+	_ channels.CapabilityReporter = (*Adapter)(nil)
+)
+
+// audioExtension returns the file extension of an audio MIME type.
+func audioExtension(mimeType string) string {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0])) {
+	case "audio/ogg", "audio/opus":
+		return ".ogg"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	case "audio/aac":
+		return ".aac"
+	case "audio/flac":
+		return ".flac"
+	}
+	return ".mp3"
+}
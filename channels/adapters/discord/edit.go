@@ -0,0 +1,85 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// EditMessage replaces the content and components of a message sent by
+// the bot, including replies to slash commands.
+func (a *Adapter) EditMessage(ctx context.Context, channelID, messageID string, msg channels.OutgoingMessage) error {
+	if a.session == nil {
+		return fmt.Errorf("discord session not connected")
+	}
+	if r, ok := a.replyMessages.Load(messageID); ok {
+		edit := &discordgo.WebhookEdit{Content: &msg.Content}
+		if len(msg.Components) > 0 {
+			components, err := messageComponents(msg.Components)
+			if err != nil {
+				return err
+			}
+			edit.Components = &components
+		}
+		return a.editReply(ctx, r.(*commandReply), messageID, edit)
+	}
+	edit := discordgo.NewMessageEdit(channelID, messageID).SetContent(msg.Content)
+	if len(msg.Components) > 0 {
+		components, err := messageComponents(msg.Components)
+		if err != nil {
+			return err
+		}
+		edit.Components = &components
+	}
+	if _, err := a.session.ChannelMessageEditComplex(edit, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("edit message: %w", rateLimited(err))
+	}
+	return nil
+}
+
+// DeleteMessage deletes a message.
+func (a *Adapter) DeleteMessage(ctx context.Context, channelID, messageID string) error {
+	if a.session == nil {
+		return fmt.Errorf("discord session not connected")
+	}
+	if r, ok := a.replyMessages.Load(messageID); ok {
+		return a.deleteReply(ctx, r.(*commandReply), messageID)
+	}
+	if err := a.session.ChannelMessageDelete(channelID, messageID, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("delete message: %w", rateLimited(err))
+	}
+	return nil
+}
+
+// SendTyping shows the typing indicator for about ten seconds.
+func (a *Adapter) SendTyping(ctx context.Context, channelID string) error {
+	if a.session == nil {
+		return fmt.Errorf("discord session not connected")
+	}
+	if err := a.session.ChannelTyping(channelID, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("send typing: %w", err)
+	}
+	return nil
+}
+
+// SendStream streams chunks by editing the message as they arrive.
+func (a *Adapter) SendStream(ctx context.Context, channelID string, chunks <-chan string) error {
+	return channels.StreamEdits(ctx, a, channelID, chunks, channels.DefaultEditInterval)
+}
+
+// SendStreamReply streams chunks like SendStream. A stream replying to a
+// slash command answers the command.
+func (a *Adapter) SendStreamReply(ctx context.Context, channelID, replyTo string, chunks <-chan string) error {
+	if r, ok := a.commandReply(replyTo); ok {
+		return channels.StreamEdits(ctx, replyStream{a, r}, channelID, chunks, channels.DefaultEditInterval)
+	}
+	return a.SendStream(ctx, channelID, chunks)
+}
+
+var (
+	_ channels.MessageEditor = (*Adapter)(nil)
+	_ channels.ReplyStreamer = (*Adapter)(nil)
+)
@@ -0,0 +1,54 @@
+package discord
+
+import (
+	"context"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// self reports whether a user ID is the bot's.
+func self(s *discordgo.Session, userID string) bool {
+	return s.State.User != nil && userID == s.State.User.ID
+}
+
+// emitEdit reports an edited message of a user as a message_edited event.
+// Updates that are not edits, such as link previews being added, are not
+// reported.
+func (a *Adapter) emitEdit(ctx context.Context, s *discordgo.Session, m *discordgo.MessageUpdate) {
+	if m.Message == nil || m.Author == nil || m.EditedTimestamp == nil || self(s, m.Author.ID) {
+		return
+	}
+	data := map[string]interface{}{
+		channels.EventDataMessageID: m.ID,
+		channels.EventDataUserID:    m.Author.ID,
+		channels.EventDataContent:   m.Content,
+	}
+	if m.BeforeUpdate != nil {
+		data[channels.EventDataPreviousContent] = m.BeforeUpdate.Content
+	}
+	a.emitEvent(ctx, channels.EventTypeMessageEdited, m.ChannelID, data)
+}
+
+// emitTyping reports a user starting to type as a typing event.
+func (a *Adapter) emitTyping(ctx context.Context, s *discordgo.Session, t *discordgo.TypingStart) {
+	if self(s, t.UserID) {
+		return
+	}
+	a.emitEvent(ctx, channels.EventTypeTyping, t.ChannelID, map[string]interface{}{
+		channels.EventDataUserID: t.UserID,
+	})
+}
+
+// emitMember reports a member joining or leaving a guild. Members belong
+// to guilds rather than channels, so the event's chat ID is the guild ID.
+func (a *Adapter) emitMember(ctx context.Context, eventType channels.EventType, m *discordgo.Member) {
+	if m == nil || m.User == nil {
+		return
+	}
+	a.emitEvent(ctx, eventType, m.GuildID, map[string]interface{}{
+		channels.EventDataUserID:   m.User.ID,
+		channels.EventDataUserName: m.User.Username,
+	})
+}
@@ -0,0 +1,83 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// maxPollHours is the longest a Discord poll can run.
+const maxPollHours = 32 * 24
+
+// discordPoll converts a poll for sending. Discord polls need 1 to 10
+// options and run for up to 32 days; Anonymous is ignored.
+func discordPoll(p *channels.Poll) (*discordgo.Poll, error) {
+	if len(p.Options) == 0 || len(p.Options) > 10 {
+		return nil, fmt.Errorf("discord polls need 1 to 10 options, got %d", len(p.Options))
+	}
+	poll := &discordgo.Poll{
+		Question:         discordgo.PollMedia{Text: p.Question},
+		AllowMultiselect: p.MultipleAnswers,
+		Duration:         min(int(math.Ceil(p.Duration.Hours())), maxPollHours),
+	}
+	for _, o := range p.Options {
+		poll.Answers = append(poll.Answers, discordgo.PollAnswer{Media: &discordgo.PollMedia{Text: o.Text}})
+	}
+	return poll, nil
+}
+
+// payloadText renders location and contact media, which Discord cannot
+// show, as text. Locations link to a map.
+func payloadText(m channels.Media) string {
+	text := channels.MediaText(m)
+	if m.Type == channels.MediaTypeLocation && m.Location != nil {
+		text += "\nhttps://www.google.com/maps/search/?api=1&query=" +
+			strconv.FormatFloat(m.Location.Latitude, 'f', -1, 64) + "," +
+			strconv.FormatFloat(m.Location.Longitude, 'f', -1, 64)
+	}
+	return text
+}
+
+// incomingPoll returns the poll of a message, identified by the message
+// ID.
+func incomingPoll(m *discordgo.Message) *channels.Poll {
+	if m.Poll == nil {
+		return nil
+	}
+	poll := &channels.Poll{
+		ID:              m.ID,
+		Question:        m.Poll.Question.Text,
+		MultipleAnswers: m.Poll.AllowMultiselect,
+	}
+	votes := make(map[int]int)
+	if m.Poll.Results != nil {
+		poll.Closed = m.Poll.Results.Finalized
+		for _, c := range m.Poll.Results.AnswerCounts {
+			votes[c.ID] = c.Count
+		}
+	}
+	for _, a := range m.Poll.Answers {
+		var text string
+		if a.Media != nil {
+			text = a.Media.Text
+		}
+		poll.Options = append(poll.Options, channels.PollOption{Text: text, Votes: votes[a.AnswerID]})
+	}
+	return poll
+}
+
+// emitPollVote reports a vote on a poll. Discord answer IDs count from 1.
+func (a *Adapter) emitPollVote(ctx context.Context, channelID, messageID, userID string, answerID int, removed bool) {
+	a.emitEvent(ctx, channels.EventTypePollVote, channelID, map[string]interface{}{
+		channels.EventDataPollID:    messageID,
+		channels.EventDataMessageID: messageID,
+		channels.EventDataUserID:    userID,
+		channels.EventDataOptions:   []int{answerID - 1},
+		channels.EventDataRemoved:   removed,
+	})
+}
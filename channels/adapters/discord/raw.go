@@ -0,0 +1,144 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Message is a raw payload sending a message built with discordgo, for
+// fields envoy does not model such as components, polls, or rich embeds.
+type Message struct {
+	Data *discordgo.MessageSend
+}
+
+// Validate checks Discord's message limits.
+func (m Message) Validate() error {
+	if m.Data == nil {
+		return errors.New("message data required")
+	}
+	if utf8.RuneCountInString(m.Data.Content) > 2000 {
+		return errors.New("content exceeds 2000 characters")
+	}
+	if len(m.Data.Components) > 5 {
+		return errors.New("at most 5 component rows allowed")
+	}
+	if len(m.Data.Embeds) > 10 {
+		return errors.New("at most 10 embeds allowed")
+	}
+	return nil
+}
+
+// Modal is a raw payload opening a modal form in response to an
+// interaction. Take the interaction ID and token from an
+// channels.EventTypeInteraction event; Discord expects the response within
+// three seconds. The chat ID the message is sent to is ignored.
+type Modal struct {
+	InteractionID    string
+	InteractionToken string
+
+	// CustomID is reported on the interaction event of the submission.
+	CustomID string
+	Title    string
+
+	// Components holds one to five action rows with a text input each.
+	Components []discordgo.MessageComponent
+}
+
+// Validate checks Discord's modal limits.
+func (m Modal) Validate() error {
+	switch {
+	case m.InteractionID == "" || m.InteractionToken == "":
+		return errors.New("modal requires an interaction ID and token")
+	case m.CustomID == "" || len(m.CustomID) > 100:
+		return errors.New("modal custom ID must have 1 to 100 characters")
+	case m.Title == "" || utf8.RuneCountInString(m.Title) > 45:
+		return errors.New("modal title must have 1 to 45 characters")
+	case len(m.Components) == 0 || len(m.Components) > 5:
+		return errors.New("modal requires 1 to 5 component rows")
+	}
+	return nil
+}
+
+// ValidateRaw accepts Message and Modal payloads.
+func (a *Adapter) ValidateRaw(p channels.RawPayload) error {
+	switch p.(type) {
+	case Message, Modal:
+		return p.Validate()
+	}
+	return fmt.Errorf("%T: %w", p, channels.ErrRawUnsupported)
+}
+
+// sendRaw sends a raw payload.
+func (a *Adapter) sendRaw(ctx context.Context, channelID string, p channels.RawPayload) error {
+	if err := a.ValidateRaw(p); err != nil {
+		return err
+	}
+	switch p := p.(type) {
+	case Message:
+		if _, err := a.session.ChannelMessageSendComplex(channelID, p.Data); err != nil {
+			return fmt.Errorf("send message: %w", rateLimited(err))
+		}
+	case Modal:
+		err := a.session.InteractionRespond(
+			&discordgo.Interaction{ID: p.InteractionID, Token: p.InteractionToken},
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseModal,
+				Data: &discordgo.InteractionResponseData{
+					CustomID:   p.CustomID,
+					Title:      p.Title,
+					Components: p.Components,
+				},
+			})
+		if err != nil {
+			return fmt.Errorf("open modal: %w", rateLimited(err))
+		}
+	}
+	return nil
+}
+
+// handleInteraction reports component and modal submit interactions as
+// events. Slash commands go to handleCommand and interactions with
+// rendered components to handleComponent.
+func (a *Adapter) handleInteraction(ctx context.Context, i *discordgo.InteractionCreate) {
+	if i.Type == discordgo.InteractionApplicationCommand {
+		a.handleCommand(ctx, i)
+		return
+	}
+	if i.Type == discordgo.InteractionMessageComponent && a.handleComponent(ctx, i) {
+		return
+	}
+	data := map[string]interface{}{
+		channels.EventDataInteractionID:    i.ID,
+		channels.EventDataInteractionToken: i.Token,
+	}
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		component := i.MessageComponentData()
+		data[channels.EventDataCustomID] = component.CustomID
+		if len(component.Values) > 0 {
+			data[channels.EventDataValues] = component.Values
+		}
+		if i.Message != nil {
+			data[channels.EventDataMessageID] = i.Message.ID
+		}
+	case discordgo.InteractionModalSubmit:
+		data[channels.EventDataCustomID] = i.ModalSubmitData().CustomID
+	default:
+		return
+	}
+	if i.Member != nil && i.Member.User != nil {
+		data[channels.EventDataUserID] = i.Member.User.ID
+	} else if i.User != nil {
+		data[channels.EventDataUserID] = i.User.ID
+	}
+	a.emitEvent(ctx, channels.EventTypeInteraction, i.ChannelID, data)
+}
+
+// Ensure Adapter implements channels.RawSender.
+var _ channels.RawSender = (*Adapter)(nil)
@@ -0,0 +1,50 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// AddReaction reacts to a message. Custom emoji are referenced as
+// "name:id".
+func (a *Adapter) AddReaction(ctx context.Context, channelID, messageID, emoji string) error {
+	if a.session == nil {
+		return fmt.Errorf("discord session not connected")
+	}
+	if err := a.session.MessageReactionAdd(channelID, messageID, emoji, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("add reaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveReaction removes the bot's reaction from a message.
+func (a *Adapter) RemoveReaction(ctx context.Context, channelID, messageID, emoji string) error {
+	if a.session == nil {
+		return fmt.Errorf("discord session not connected")
+	}
+	if err := a.session.MessageReactionRemove(channelID, messageID, emoji, "@me", discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("remove reaction: %w", err)
+	}
+	return nil
+}
+
+// emitReaction reports a reaction of a user as a reaction event, with
+// custom emoji as "name:id" like AddReaction takes them. The bot's own
+// reactions are not reported.
+func (a *Adapter) emitReaction(ctx context.Context, s *discordgo.Session, r *discordgo.MessageReaction, removed bool) {
+	if self(s, r.UserID) {
+		return
+	}
+	a.emitEvent(ctx, channels.EventTypeReaction, r.ChannelID, map[string]interface{}{
+		channels.EventDataMessageID: r.MessageID,
+		channels.EventDataEmoji:     r.Emoji.APIName(),
+		channels.EventDataUserID:    r.UserID,
+		channels.EventDataRemoved:   removed,
+	})
+}
+
+var _ channels.Reactor = (*Adapter)(nil)
@@ -0,0 +1,40 @@
+package discord
+
+import (
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// stickerFormats maps Discord sticker formats to formats, MIME types, and
+// CDN file extensions.
+var stickerFormats = map[discordgo.StickerFormat]struct {
+	format    channels.StickerFormat
+	mimeType  string
+	extension string
+}{
+	discordgo.StickerFormatTypePNG:    {channels.StickerStatic, "image/png", ".png"},
+	discordgo.StickerFormatTypeAPNG:   {channels.StickerAnimated, "image/apng", ".png"},
+	discordgo.StickerFormatTypeLottie: {channels.StickerAnimated, "application/json", ".json"},
+	discordgo.StickerFormatTypeGIF:    {channels.StickerAnimated, "image/gif", ".gif"},
+}
+
+// incomingStickers returns the stickers of a message, identified by
+// sticker ID and linked to their CDN file.
+func incomingStickers(m *discordgo.Message) []channels.Media {
+	var media []channels.Media
+	for _, s := range m.StickerItems {
+		f, ok := stickerFormats[s.FormatType]
+		if !ok {
+			f = stickerFormats[discordgo.StickerFormatTypePNG]
+		}
+		media = append(media, channels.Media{
+			Type:     channels.MediaTypeSticker,
+			FileID:   s.ID,
+			URL:      "https://media.discordapp.net/stickers/" + s.ID + f.extension,
+			MimeType: f.mimeType,
+			Sticker:  &channels.Sticker{Name: s.Name, Format: f.format},
+		})
+	}
+	return media
+}
@@ -0,0 +1,127 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DefaultAutoArchive is how long threads created by the bot stay active
+// without messages.
+const DefaultAutoArchive = 24 * time.Hour
+
+// archiveDurations are the auto-archive durations Discord accepts, in
+// minutes.
+var archiveDurations = []int{60, 1440, 4320, 10080}
+
+// archiveMinutes returns the auto-archive duration for new threads in a
+// guild: the shortest one Discord accepts that covers the configured one.
+func (a *Adapter) archiveMinutes(guildID string) int {
+	d, ok := a.guildAutoArchive[guildID]
+	if !ok {
+		d = a.autoArchive
+	}
+	for _, m := range archiveDurations {
+		if time.Duration(m)*time.Minute >= d {
+			return m
+		}
+	}
+	return archiveDurations[len(archiveDurations)-1]
+}
+
+// channel returns a channel from the state cache, or from Discord.
+func (a *Adapter) channel(ctx context.Context, channelID string) (*discordgo.Channel, error) {
+	if ch, err := a.session.State.Channel(channelID); err == nil {
+		return ch, nil
+	}
+	ch, err := a.session.Channel(channelID, discordgo.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get channel: %w", rateLimited(err))
+	}
+	return ch, nil
+}
+
+// isForum reports whether a channel holds forum posts.
+func isForum(ch *discordgo.Channel) bool {
+	return ch.Type == discordgo.ChannelTypeGuildForum || ch.Type == discordgo.ChannelTypeGuildMedia
+}
+
+// CreateThread starts a public thread in a text channel, on the message
+// first replies to if any, and posts the first message in it. In forum
+// channels it creates a post titled title, which requires a first message.
+// Threads are channels in Discord, so the returned chat ID is the thread's
+// channel ID.
+func (a *Adapter) CreateThread(ctx context.Context, channelID, title string, first channels.OutgoingMessage) (string, error) {
+	if a.session == nil {
+		return "", fmt.Errorf("discord session not connected")
+	}
+	parent, err := a.channel(ctx, channelID)
+	if err != nil {
+		return "", err
+	}
+	start := &discordgo.ThreadStart{
+		Name:                title,
+		AutoArchiveDuration: a.archiveMinutes(parent.GuildID),
+	}
+
+	if isForum(parent) {
+		if first.Content == "" && len(first.Media) == 0 {
+			return "", fmt.Errorf("forum posts need a first message")
+		}
+		data, err := a.messageSend(first)
+		if err != nil {
+			return "", err
+		}
+		thread, err := a.session.ForumThreadStartComplex(channelID, start, data, discordgo.WithContext(ctx))
+		if err != nil {
+			return "", fmt.Errorf("create forum post: %w", rateLimited(err))
+		}
+		return thread.ID, nil
+	}
+
+	var thread *discordgo.Channel
+	if first.ReplyTo != "" {
+		thread, err = a.session.MessageThreadStartComplex(channelID, first.ReplyTo, start, discordgo.WithContext(ctx))
+		first.ReplyTo = ""
+	} else {
+		start.Type = discordgo.ChannelTypeGuildPublicThread
+		thread, err = a.session.ThreadStartComplex(channelID, start, discordgo.WithContext(ctx))
+	}
+	if err != nil {
+		return "", fmt.Errorf("start thread: %w", rateLimited(err))
+	}
+	if first.Content != "" || len(first.Media) > 0 {
+		if err := a.Send(ctx, thread.ID, first); err != nil {
+			return thread.ID, err
+		}
+	}
+	return thread.ID, nil
+}
+
+// followThread joins a newly created thread with FollowThreads.
+func (a *Adapter) followThread(t *discordgo.ThreadCreate) {
+	if !a.followThreads || !t.NewlyCreated || t.Member != nil {
+		return
+	}
+	if err := a.session.ThreadJoin(t.ID); err != nil {
+		a.logger.Warn("join thread failed", "thread", t.ID, "error", err)
+	}
+}
+
+// threadMetadata adds the title and parent channel of a thread to message
+// metadata, and marks forum posts.
+func (a *Adapter) threadMetadata(threadID string, metadata map[string]interface{}) {
+	thread, err := a.session.State.Channel(threadID)
+	if err != nil {
+		return
+	}
+	metadata["thread_name"] = thread.Name
+	metadata["parent_id"] = thread.ParentID
+	if parent, err := a.session.State.Channel(thread.ParentID); err == nil {
+		metadata["forum"] = isForum(parent)
+	}
+}
@@ -0,0 +1,304 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/media/ogg"
+)
+
+// Defaults for segmenting speech in voice channels.
+const (
+	DefaultVoiceSilence = 700 * time.Millisecond
+	DefaultMaxUtterance = 30 * time.Second
+)
+
+// minUtterance is the shortest speech delivered; shorter sounds are noise.
+const minUtterance = 300 * time.Millisecond
+
+// utteranceIDPrefix starts the message IDs of speech, which has no Discord
+// message to reply to.
+const utteranceIDPrefix = "voice-"
+
+// utteranceSeq numbers utterances across the process, so speech after the
+// bot rejoins a channel is not taken for a redelivery of earlier speech.
+var utteranceSeq atomic.Uint64
+
+// voiceChat is the bot's connection to a voice channel.
+type voiceChat struct {
+	adapter   *Adapter
+	conn      *discordgo.VoiceConnection
+	guildID   string
+	channelID string
+	done      chan struct{}
+
+	mu         sync.Mutex
+	speakers   map[uint32]string // SSRC -> user ID
+	utterances map[uint32]*utterance
+
+	// playMu serializes playback
+	playMu sync.Mutex
+}
+
+// utterance is the speech of one speaker, as Opus packets.
+type utterance struct {
+	packets [][]byte
+	started time.Time
+	last    time.Time
+}
+
+// JoinVoice connects the bot to a voice channel, leaving any other voice
+// channel in the guild. Speech in the channel is delivered as
+// MediaTypeVoice messages in Ogg Opus with the voice channel as chat, and
+// voice media sent to the channel is played in it.
+func (a *Adapter) JoinVoice(ctx context.Context, guildID, channelID string) error {
+	if a.session == nil {
+		return fmt.Errorf("discord session not connected")
+	}
+	a.mu.Lock()
+	current := a.voiceChats[guildID]
+	a.mu.Unlock()
+	if current != nil {
+		if current.channelID == channelID {
+			return nil
+		}
+		if err := a.LeaveVoice(ctx, guildID); err != nil {
+			return err
+		}
+	}
+
+	conn, err := a.session.ChannelVoiceJoin(guildID, channelID, false, false)
+	if err != nil {
+		return fmt.Errorf("join voice channel: %w", err)
+	}
+	chat := &voiceChat{
+		adapter:    a,
+		conn:       conn,
+		guildID:    guildID,
+		channelID:  channelID,
+		done:       make(chan struct{}),
+		speakers:   make(map[uint32]string),
+		utterances: make(map[uint32]*utterance),
+	}
+	conn.AddHandler(func(vc *discordgo.VoiceConnection, vs *discordgo.VoiceSpeakingUpdate) {
+		chat.mu.Lock()
+		chat.speakers[uint32(vs.SSRC)] = vs.UserID
+		chat.mu.Unlock()
+	})
+
+	a.mu.Lock()
+	a.voiceChats[guildID] = chat
+	a.mu.Unlock()
+	go chat.receive()
+
+	a.logger.Info("joined voice channel", "guild", guildID, "channel", channelID)
+	return nil
+}
+
+// LeaveVoice disconnects the bot from the voice channel it is in in a
+// guild, delivering speech in progress.
+func (a *Adapter) LeaveVoice(ctx context.Context, guildID string) error {
+	a.mu.Lock()
+	chat := a.voiceChats[guildID]
+	delete(a.voiceChats, guildID)
+	a.mu.Unlock()
+	if chat == nil {
+		return nil
+	}
+
+	close(chat.done)
+	if err := chat.conn.Disconnect(); err != nil {
+		return fmt.Errorf("leave voice channel: %w", err)
+	}
+	a.logger.Info("left voice channel", "guild", guildID, "channel", chat.channelID)
+	return nil
+}
+
+// voiceChat returns the voice connection to a channel, if any.
+func (a *Adapter) voiceChat(channelID string) (*voiceChat, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, chat := range a.voiceChats {
+		if chat.channelID == channelID {
+			return chat, true
+		}
+	}
+	return nil, false
+}
+
+// receive collects speech until the bot leaves the channel. Discord stops
+// sending a speaker's packets when they pause, so an utterance ends after
+// a gap of VoiceSilence.
+func (c *voiceChat) receive() {
+	tick := time.NewTicker(c.adapter.voiceSilence / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-c.done:
+			c.flush(time.Time{})
+			return
+		case p, ok := <-c.conn.OpusRecv:
+			if !ok {
+				c.flush(time.Time{})
+				return
+			}
+			c.onPacket(p)
+		case now := <-tick.C:
+			c.flush(now.Add(-c.adapter.voiceSilence))
+		}
+	}
+}
+
+// onPacket adds a packet to its speaker's utterance.
+func (c *voiceChat) onPacket(p *discordgo.Packet) {
+	if len(p.Opus) == 0 || string(p.Opus) == string(ogg.SilenceFrame) {
+		return
+	}
+	now := time.Now()
+	u := c.utterances[p.SSRC]
+	if u == nil {
+		u = &utterance{started: now}
+		c.utterances[p.SSRC] = u
+	}
+	u.packets = append(u.packets, append([]byte(nil), p.Opus...))
+	u.last = now
+	if ogg.Duration(u.packets) >= c.adapter.maxUtterance {
+		delete(c.utterances, p.SSRC)
+		c.deliver(p.SSRC, u)
+	}
+}
+
+// flush delivers the utterances without packets since before, or all with
+// a zero time.
+func (c *voiceChat) flush(before time.Time) {
+	for ssrc, u := range c.utterances {
+		if before.IsZero() || u.last.Before(before) {
+			delete(c.utterances, ssrc)
+			c.deliver(ssrc, u)
+		}
+	}
+}
+
+// deliver hands an utterance to the message handler.
+func (c *voiceChat) deliver(ssrc uint32, u *utterance) {
+	a := c.adapter
+	duration := ogg.Duration(u.packets)
+	if a.messageHandler == nil || duration < minUtterance {
+		return
+	}
+	c.mu.Lock()
+	userID := c.speakers[ssrc]
+	c.mu.Unlock()
+	if userID == "" {
+		a.logger.Debug("speech from unknown speaker dropped", "ssrc", ssrc)
+		return
+	}
+	name := userID
+	if m, err := a.session.State.Member(c.guildID, userID); err == nil && m.User != nil {
+		name = m.User.Username
+	}
+
+	msg := channels.IncomingMessage{
+		ID:          fmt.Sprintf("%s%s-%d", utteranceIDPrefix, c.channelID, utteranceSeq.Add(1)),
+		ChannelName: "discord",
+		ChatID:      c.channelID,
+		ChatType:    channels.ChannelTypeGroup,
+		SenderID:    userID,
+		SenderName:  name,
+		MentionsBot: true,
+		Media: []channels.Media{{
+			Type:     channels.MediaTypeVoice,
+			Data:     ogg.EncodeOpus(u.packets, 2),
+			MimeType: ogg.MimeType,
+			Filename: "voice.ogg",
+		}},
+		Timestamp: u.started,
+		Metadata: map[string]interface{}{
+			"guild_id": c.guildID,
+			"duration": duration,
+		},
+	}
+
+	// Handle off the receive loop so audio keeps flowing while the agent
+	// works
+	go func() {
+		if err := a.messageHandler(context.Background(), msg); err != nil {
+			a.logger.Error("message handler error", "error", err)
+		}
+	}()
+}
+
+// play plays Ogg Opus audio in the channel.
+func (c *voiceChat) play(ctx context.Context, m channels.Media) error {
+	if len(m.Data) == 0 {
+		return fmt.Errorf("voice media must include data")
+	}
+	packets, err := ogg.DecodeOpus(m.Data)
+	if err != nil {
+		return fmt.Errorf("discord voice plays Ogg Opus audio: %w", err)
+	}
+
+	c.playMu.Lock()
+	defer c.playMu.Unlock()
+	if err := c.conn.Speaking(true); err != nil {
+		return fmt.Errorf("start speaking: %w", err)
+	}
+	defer func() {
+		if err := c.conn.Speaking(false); err != nil {
+			c.adapter.logger.Warn("stop speaking failed", "error", err)
+		}
+	}()
+	for _, p := range packets {
+		select {
+		case c.conn.OpusSend <- p:
+		case <-c.done:
+			return fmt.Errorf("left voice channel")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// sendVoice plays the voice and audio media of msg in a voice channel the
+// bot is in, after sending the rest of msg to the channel's text chat. It
+// reports false for other channels and messages without audio.
+func (a *Adapter) sendVoice(ctx context.Context, channelID string, msg channels.OutgoingMessage) (channels.MessageReceipt, bool, error) {
+	receipt := channels.MessageReceipt{ChannelName: "discord", ChatID: channelID}
+	chat, ok := a.voiceChat(channelID)
+	if !ok {
+		return receipt, false, nil
+	}
+	var audio, rest []channels.Media
+	for _, m := range msg.Media {
+		if m.Type == channels.MediaTypeVoice || m.Type == channels.MediaTypeAudio {
+			audio = append(audio, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	if len(audio) == 0 {
+		return receipt, false, nil
+	}
+
+	msg.Media = rest
+	receipt.Timestamp = time.Now()
+	if msg.Content != "" || len(rest) > 0 || len(msg.Components) > 0 {
+		var err error
+		if receipt, err = a.SendWithReceipt(ctx, channelID, msg); err != nil {
+			return receipt, true, err
+		}
+	}
+	for _, m := range audio {
+		if err := chat.play(ctx, m); err != nil {
+			return receipt, true, fmt.Errorf("play audio: %w", err)
+		}
+	}
+	return receipt, true, nil
+}
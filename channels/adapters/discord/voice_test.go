@@ -0,0 +1,129 @@
+package discord
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/media/ogg"
+)
+
+// speech is a 180ms Opus packet: three 60ms SILK frames.
+var speech = []byte{0x1B, 0x03, 0x01}
+
+// newTestVoiceChat returns a voice chat delivering utterances to got.
+func newTestVoiceChat(got chan<- channels.IncomingMessage) *voiceChat {
+	a := &Adapter{
+		logger:       slog.Default(),
+		session:      &discordgo.Session{State: discordgo.NewState()},
+		voiceSilence: DefaultVoiceSilence,
+		maxUtterance: time.Second,
+	}
+	a.OnMessage(func(ctx context.Context, msg channels.IncomingMessage) error {
+		got <- msg
+		return nil
+	})
+	return &voiceChat{
+		adapter:    a,
+		guildID:    "g1",
+		channelID:  "v1",
+		speakers:   map[uint32]string{1: "alice", 2: "bob"},
+		utterances: make(map[uint32]*utterance),
+	}
+}
+
+func receive(t *testing.T, got <-chan channels.IncomingMessage) channels.IncomingMessage {
+	t.Helper()
+	select {
+	case msg := <-got:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("utterance not delivered")
+		return channels.IncomingMessage{}
+	}
+}
+
+func TestVoiceUtterances(t *testing.T) {
+	got := make(chan channels.IncomingMessage, 4)
+	c := newTestVoiceChat(got)
+
+	// Silence and noise are not speech
+	c.onPacket(&discordgo.Packet{SSRC: 1, Opus: ogg.SilenceFrame})
+	c.onPacket(&discordgo.Packet{SSRC: 1, Opus: speech})
+	c.onPacket(&discordgo.Packet{SSRC: 2, Opus: speech})
+	c.onPacket(&discordgo.Packet{SSRC: 2, Opus: speech})
+	c.onPacket(&discordgo.Packet{SSRC: 3, Opus: speech})
+	c.onPacket(&discordgo.Packet{SSRC: 3, Opus: speech})
+
+	// Only utterances idle since before the cutoff end
+	c.flush(time.Now().Add(-time.Minute))
+	if len(c.utterances) != 3 {
+		t.Fatalf("utterances = %d, want all still open", len(c.utterances))
+	}
+	c.flush(time.Time{})
+	if len(c.utterances) != 0 {
+		t.Fatalf("utterances = %d after flush", len(c.utterances))
+	}
+
+	// alice's packet is too short, and SSRC 3 has no known speaker
+	msg := receive(t, got)
+	if msg.SenderID != "bob" || msg.ChatID != "v1" || msg.SenderName != "bob" {
+		t.Errorf("msg = %+v", msg)
+	}
+	if len(msg.Media) != 1 || msg.Media[0].MimeType != ogg.MimeType {
+		t.Fatalf("media = %+v", msg.Media)
+	}
+	packets, err := ogg.DecodeOpus(msg.Media[0].Data)
+	if err != nil || len(packets) != 2 {
+		t.Errorf("decoded %d packets, %v; want 2", len(packets), err)
+	}
+	select {
+	case extra := <-got:
+		t.Errorf("unexpected utterance %+v", extra)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestVoiceMaxUtterance(t *testing.T) {
+	got := make(chan channels.IncomingMessage, 4)
+	c := newTestVoiceChat(got)
+
+	// Six 180ms packets pass the one second limit
+	for i := 0; i < 6; i++ {
+		c.onPacket(&discordgo.Packet{SSRC: 2, Opus: speech})
+	}
+	msg := receive(t, got)
+	if d, _ := msg.Metadata["duration"].(time.Duration); d < time.Second {
+		t.Errorf("duration = %v, want the full utterance", d)
+	}
+	if len(c.utterances) != 0 {
+		t.Errorf("utterance still open after delivery")
+	}
+}
+
+func TestVoiceUtteranceIDsUniqueAcrossJoins(t *testing.T) {
+	got := make(chan channels.IncomingMessage, 4)
+	seen := make(map[string]bool)
+
+	// Each join starts a new voice chat for the same channel
+	for join := 0; join < 2; join++ {
+		c := newTestVoiceChat(got)
+		c.onPacket(&discordgo.Packet{SSRC: 2, Opus: speech})
+		c.onPacket(&discordgo.Packet{SSRC: 2, Opus: speech})
+		c.flush(time.Time{})
+
+		msg := receive(t, got)
+		if !strings.HasPrefix(msg.ID, utteranceIDPrefix+"v1-") {
+			t.Errorf("ID = %q", msg.ID)
+		}
+		if seen[msg.ID] {
+			t.Errorf("ID %q reused after rejoining", msg.ID)
+		}
+		seen[msg.ID] = true
+	}
+}
@@ -0,0 +1,133 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Callback data prefixes of rendered components. Callback data without one
+// comes from keyboards sent with Method and is reported as is.
+const (
+	callbackButton     = "c|"
+	callbackSelect     = "s|"
+	callbackQuickReply = "q|"
+)
+
+// maxCallbackData is the Bot API limit on callback data.
+const maxCallbackData = 64
+
+// replyMarkup renders message components: a reply keyboard when there are
+// only quick replies, otherwise an inline keyboard, with menus as one
+// button per option.
+func replyMarkup(rows [][]channels.Component) (*telebot.ReplyMarkup, error) {
+	quickOnly := true
+	for _, row := range rows {
+		for _, c := range row {
+			quickOnly = quickOnly && c.Kind == channels.ComponentQuickReply
+		}
+	}
+	if quickOnly {
+		markup := &telebot.ReplyMarkup{OneTimeKeyboard: true, ResizeKeyboard: true}
+		for _, row := range rows {
+			var buttons []telebot.ReplyButton
+			for _, c := range row {
+				buttons = append(buttons, telebot.ReplyButton{Text: c.Label})
+			}
+			markup.ReplyKeyboard = append(markup.ReplyKeyboard, buttons)
+		}
+		return markup, nil
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	for _, row := range rows {
+		var buttons []telebot.InlineButton
+		for _, c := range row {
+			switch {
+			case c.Kind == channels.ComponentSelect:
+				if strings.Contains(c.ID, "|") {
+					return nil, fmt.Errorf("menu ID %q contains \"|\"", c.ID)
+				}
+				for _, o := range c.Options {
+					data := callbackSelect + c.ID + "|" + o.Value
+					markup.InlineKeyboard = append(markup.InlineKeyboard, []telebot.InlineButton{{Text: o.Label, Data: data}})
+				}
+			case c.URL != "":
+				buttons = append(buttons, telebot.InlineButton{Text: c.Label, URL: c.URL})
+			case c.Kind == channels.ComponentQuickReply:
+				buttons = append(buttons, telebot.InlineButton{Text: c.Label, Data: callbackQuickReply + c.Label})
+			default:
+				buttons = append(buttons, telebot.InlineButton{Text: c.Label, Data: callbackButton + c.ID})
+			}
+		}
+		if len(buttons) > 0 {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, buttons)
+		}
+	}
+	for _, row := range markup.InlineKeyboard {
+		for _, b := range row {
+			if len(b.Data) > maxCallbackData {
+				return nil, fmt.Errorf("callback data %q exceeds %d bytes", b.Data, maxCallbackData)
+			}
+		}
+	}
+	return markup, nil
+}
+
+// handleCallback reports an inline button press: as the user's message for
+// quick replies, otherwise as an interaction event.
+func (a *Adapter) handleCallback(ctx context.Context, c telebot.Context) error {
+	cb := c.Callback()
+	// Stop the button's loading indicator
+	if err := c.Respond(); err != nil {
+		a.logger.Warn("answer callback failed", "error", err)
+	}
+	if cb.Message == nil || cb.Sender == nil {
+		return nil
+	}
+
+	if label, ok := strings.CutPrefix(cb.Data, callbackQuickReply); ok {
+		if a.messageHandler == nil {
+			return nil
+		}
+		msg := a.convertIncoming(cb.Message)
+		msg.ID = cb.ID
+		msg.SenderID = fmt.Sprintf("%d", cb.Sender.ID)
+		msg.SenderName = displayName(cb.Sender)
+		msg.Content = label
+		msg.ReplyTo = fmt.Sprintf("%d", cb.Message.ID)
+		msg.MentionsBot = true
+		msg.Mentions = nil
+		msg.Metadata["username"] = cb.Sender.Username
+		return a.messageHandler(ctx, msg)
+	}
+
+	if a.eventHandler == nil {
+		return nil
+	}
+	data := map[string]interface{}{
+		channels.EventDataCustomID:  cb.Data,
+		channels.EventDataUserID:    fmt.Sprintf("%d", cb.Sender.ID),
+		channels.EventDataMessageID: fmt.Sprintf("%d", cb.Message.ID),
+	}
+	if id, ok := strings.CutPrefix(cb.Data, callbackButton); ok {
+		data[channels.EventDataCustomID] = id
+	} else if rest, ok := strings.CutPrefix(cb.Data, callbackSelect); ok {
+		id, value, _ := strings.Cut(rest, "|")
+		data[channels.EventDataCustomID] = id
+		data[channels.EventDataValues] = []string{value}
+	}
+	msg := a.convertIncoming(cb.Message)
+	return a.eventHandler(ctx, channels.Event{
+		Type:        channels.EventTypeInteraction,
+		ChannelName: "telegram",
+		ChatID:      msg.ChatID,
+		Data:        data,
+		Timestamp:   time.Now(),
+	})
+}
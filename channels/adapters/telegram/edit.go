@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// EditMessage replaces the text and inline keyboard of a message sent by
+// the bot. Quick replies cannot be added by editing.
+func (a *Adapter) EditMessage(ctx context.Context, chatID, messageID string, msg channels.OutgoingMessage) error {
+	stored, err := a.storedMessage(chatID, messageID)
+	if err != nil {
+		return err
+	}
+	opts := &telebot.SendOptions{ParseMode: parseMode(msg.Format)}
+	if len(msg.Components) > 0 {
+		if opts.ReplyMarkup, err = replyMarkup(msg.Components); err != nil {
+			return err
+		}
+		if len(opts.ReplyMarkup.InlineKeyboard) == 0 {
+			return fmt.Errorf("edited messages only support inline buttons")
+		}
+	}
+	_, err = a.bot.Edit(stored, msg.Content, opts)
+	// Telegram trims text, so edits adding only whitespace change nothing
+	if err != nil && !errors.Is(err, telebot.ErrMessageNotModified) && !errors.Is(err, telebot.ErrSameMessageContent) {
+		return fmt.Errorf("edit message: %w", rateLimited(err))
+	}
+	return nil
+}
+
+// DeleteMessage deletes a message. Bots can delete their own messages
+// for 48 hours, and those of others where they are administrators.
+func (a *Adapter) DeleteMessage(ctx context.Context, chatID, messageID string) error {
+	stored, err := a.storedMessage(chatID, messageID)
+	if err != nil {
+		return err
+	}
+	if err := a.bot.Delete(stored); err != nil {
+		return fmt.Errorf("delete message: %w", rateLimited(err))
+	}
+	return nil
+}
+
+// storedMessage references a message in a chat.
+func (a *Adapter) storedMessage(chatID, messageID string) (telebot.StoredMessage, error) {
+	if a.bot == nil {
+		return telebot.StoredMessage{}, fmt.Errorf("telegram bot not connected")
+	}
+	id, _, err := parseChatID(chatID)
+	if err != nil {
+		return telebot.StoredMessage{}, err
+	}
+	return telebot.StoredMessage{MessageID: messageID, ChatID: id}, nil
+}
+
+// SendTyping shows the typing indicator for about five seconds.
+func (a *Adapter) SendTyping(ctx context.Context, chatID string) error {
+	if a.bot == nil {
+		return fmt.Errorf("telegram bot not connected")
+	}
+	id, threadID, err := parseChatID(chatID)
+	if err != nil {
+		return err
+	}
+	if err := a.bot.Notify(&telebot.Chat{ID: id}, telebot.Typing, threadID); err != nil {
+		return fmt.Errorf("send typing: %w", err)
+	}
+	return nil
+}
+
+// SendStream shows a placeholder, then edits it with the text as it
+// arrives, at most once per StreamInterval. Flood errors pause the edits
+// for as long as Telegram asks.
+func (a *Adapter) SendStream(ctx context.Context, chatID string, chunks <-chan string) error {
+	return channels.StreamEditsWith(ctx, a, chatID, chunks, channels.StreamConfig{
+		Interval:    a.streamInterval,
+		Placeholder: a.streamPlaceholder,
+	})
+}
+
+var (
+	_ channels.MessageEditor    = (*Adapter)(nil)
+	_ channels.StreamingChannel = (*Adapter)(nil)
+)
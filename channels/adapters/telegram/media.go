@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DownloadMedia opens a file by its Telegram file ID. Bots can download
+// files of up to 20 MB.
+func (a *Adapter) DownloadMedia(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	if a.bot == nil {
+		return nil, fmt.Errorf("telegram bot not connected")
+	}
+	r, err := a.bot.File(&telebot.File{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", rateLimited(err))
+	}
+	return r, nil
+}
+
+// incomingMedia returns the media attached to a message, referenced by
+// file ID, or its location, contact, or poll. Photos are reported in their
+// largest size, and stickers with their set and emoji.
+func incomingMedia(msg *telebot.Message) []channels.Media {
+	file := func(t channels.MediaType, f telebot.File, mime, name string) []channels.Media {
+		return []channels.Media{{
+			Type:     t,
+			FileID:   f.FileID,
+			MimeType: mime,
+			Filename: name,
+			Size:     f.FileSize,
+			Caption:  msg.Caption,
+		}}
+	}
+	switch {
+	case msg.Photo != nil:
+		return file(channels.MediaTypeImage, msg.Photo.File, "image/jpeg", "")
+	case msg.Document != nil:
+		return file(channels.MediaTypeFor(msg.Document.MIME), msg.Document.File, msg.Document.MIME, msg.Document.FileName)
+	case msg.Voice != nil:
+		return file(channels.MediaTypeVoice, msg.Voice.File, msg.Voice.MIME, "")
+	case msg.Audio != nil:
+		return file(channels.MediaTypeAudio, msg.Audio.File, msg.Audio.MIME, msg.Audio.FileName)
+	case msg.Video != nil:
+		return file(channels.MediaTypeVideo, msg.Video.File, msg.Video.MIME, msg.Video.FileName)
+	case msg.VideoNote != nil:
+		return file(channels.MediaTypeVideo, msg.VideoNote.File, "video/mp4", "")
+	case msg.Animation != nil:
+		return file(channels.MediaTypeAnimation, msg.Animation.File, msg.Animation.MIME, msg.Animation.FileName)
+	case msg.Sticker != nil:
+		media := file(channels.MediaTypeSticker, msg.Sticker.File, "image/webp", "")
+		media[0].Caption = ""
+		media[0].Sticker = &channels.Sticker{
+			SetName:       msg.Sticker.SetName,
+			Emoji:         msg.Sticker.Emoji,
+			Format:        channels.StickerStatic,
+			CustomEmojiID: msg.Sticker.CustomEmoji,
+		}
+		switch {
+		case msg.Sticker.Animated:
+			media[0].MimeType, media[0].Sticker.Format = "application/x-tgsticker", channels.StickerAnimated
+		case msg.Sticker.Video:
+			media[0].MimeType, media[0].Sticker.Format = "video/webm", channels.StickerVideo
+		}
+		return media
+	}
+	return incomingPayload(msg)
+}
+
+var _ channels.MediaDownloader = (*Adapter)(nil)
+
+// customEmoji returns the custom emoji in a message's text or caption,
+// named by the standard emoji they stand for.
+func customEmoji(msg *telebot.Message) []channels.CustomEmoji {
+	var out []channels.CustomEmoji
+	for _, entities := range []telebot.Entities{msg.Entities, msg.CaptionEntities} {
+		for _, e := range entities {
+			if e.Type == telebot.EntityCustomEmoji {
+				out = append(out, channels.CustomEmoji{ID: e.CustomEmoji, Name: msg.EntityText(e)})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// maxPollOpenPeriod is the longest a Telegram poll can be set to close
+// after.
+const maxPollOpenPeriod = 10 * time.Minute
+
+// pollRetention is how long votes are reported on polls sent without an
+// open period, unless Telegram reports them closed earlier.
+const pollRetention = 7 * 24 * time.Hour
+
+// sendPayload sends location, contact, or poll media. Locations with both
+// a title and an address are sent as venues.
+func (a *Adapter) sendPayload(chat *telebot.Chat, threadID int, m channels.Media) (*telebot.Message, error) {
+	var what interface{}
+	switch {
+	case m.Type == channels.MediaTypeLocation && m.Location != nil:
+		loc := telebot.Location{Lat: float32(m.Location.Latitude), Lng: float32(m.Location.Longitude)}
+		what = &loc
+		if m.Location.Title != "" && m.Location.Address != "" {
+			what = &telebot.Venue{Location: loc, Title: m.Location.Title, Address: m.Location.Address}
+		}
+	case m.Type == channels.MediaTypeContact && m.Contact != nil:
+		// telebot cannot send contacts
+		return a.sendContact(chat, threadID, m.Contact)
+	case m.Type == channels.MediaTypePoll && m.Poll != nil:
+		poll, err := a.telegramPoll(m.Poll)
+		if err != nil {
+			return nil, err
+		}
+		what = poll
+	default:
+		return nil, fmt.Errorf("%s media without payload", m.Type)
+	}
+
+	sent, err := a.bot.Send(chat, what, &telebot.SendOptions{ThreadID: threadID})
+	if err != nil {
+		return nil, fmt.Errorf("send %s: %w", m.Type, rateLimited(err))
+	}
+	if sent.Poll != nil && !sent.Poll.Closed {
+		a.trackPoll(sent.Poll, fmt.Sprintf("%d", chat.ID), time.Now())
+	}
+	return sent, nil
+}
+
+// sendContact sends a contact card.
+func (a *Adapter) sendContact(chat *telebot.Chat, threadID int, c *channels.Contact) (*telebot.Message, error) {
+	params := map[string]interface{}{
+		"chat_id":      chat.ID,
+		"phone_number": c.PhoneNumber,
+		"first_name":   c.FirstName,
+	}
+	if c.LastName != "" {
+		params["last_name"] = c.LastName
+	}
+	if c.VCard != "" {
+		params["vcard"] = c.VCard
+	}
+	if threadID != 0 {
+		params["message_thread_id"] = threadID
+	}
+	data, err := a.bot.Raw("sendContact", params)
+	if err != nil {
+		return nil, fmt.Errorf("send contact: %w", rateLimited(err))
+	}
+	var resp struct {
+		Result *telebot.Message `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode sent contact: %w", err)
+	}
+	if resp.Result == nil {
+		return nil, errors.New("decode sent contact: no message")
+	}
+	return resp.Result, nil
+}
+
+// telegramPoll converts a poll for sending. Telegram polls need 2 to 10
+// options and close after at most 10 minutes; longer polls stay open.
+func (a *Adapter) telegramPoll(p *channels.Poll) (*telebot.Poll, error) {
+	if len(p.Options) < 2 || len(p.Options) > 10 {
+		return nil, fmt.Errorf("telegram polls need 2 to 10 options, got %d", len(p.Options))
+	}
+	poll := &telebot.Poll{
+		Type:            telebot.PollRegular,
+		Question:        p.Question,
+		MultipleAnswers: p.MultipleAnswers,
+		Anonymous:       p.Anonymous,
+		Closed:          p.Closed,
+	}
+	for _, o := range p.Options {
+		poll.AddOptions(o.Text)
+	}
+	switch {
+	case p.Duration > maxPollOpenPeriod:
+		a.logger.Warn("poll duration exceeds Telegram's limit, leaving it open", "duration", p.Duration)
+	case p.Duration > 0:
+		poll.OpenPeriod = int(p.Duration.Seconds())
+	}
+	return poll, nil
+}
+
+// incomingPayload returns the location, contact, or poll of a message.
+func incomingPayload(msg *telebot.Message) []channels.Media {
+	switch {
+	case msg.Venue != nil:
+		return []channels.Media{channels.LocationMedia(channels.Location{
+			Latitude:  float64(msg.Venue.Location.Lat),
+			Longitude: float64(msg.Venue.Location.Lng),
+			Title:     msg.Venue.Title,
+			Address:   msg.Venue.Address,
+		})}
+	case msg.Location != nil:
+		return []channels.Media{channels.LocationMedia(channels.Location{
+			Latitude:  float64(msg.Location.Lat),
+			Longitude: float64(msg.Location.Lng),
+		})}
+	case msg.Contact != nil:
+		c := channels.Contact{
+			PhoneNumber: msg.Contact.PhoneNumber,
+			FirstName:   msg.Contact.FirstName,
+			LastName:    msg.Contact.LastName,
+		}
+		if msg.Contact.UserID != 0 {
+			c.UserID = fmt.Sprintf("%d", msg.Contact.UserID)
+		}
+		return []channels.Media{channels.ContactMedia(c)}
+	case msg.Poll != nil:
+		poll := &channels.Poll{
+			ID:              msg.Poll.ID,
+			Question:        msg.Poll.Question,
+			MultipleAnswers: msg.Poll.MultipleAnswers,
+			Anonymous:       msg.Poll.Anonymous,
+			Closed:          msg.Poll.Closed,
+		}
+		for _, o := range msg.Poll.Options {
+			poll.Options = append(poll.Options, channels.PollOption{Text: o.Text, Votes: o.VoterCount})
+		}
+		return []channels.Media{{Type: channels.MediaTypePoll, Poll: poll}}
+	}
+	return nil
+}
+
+// handlePollAnswer reports a vote on a poll the adapter sent. Telegram
+// only reports votes on non-anonymous polls sent by the bot.
+func (a *Adapter) handlePollAnswer(ctx context.Context, answer *telebot.PollAnswer) error {
+	if a.eventHandler == nil || answer == nil {
+		return nil
+	}
+	chatID, ok := a.pollChat(answer.PollID, time.Now())
+	if !ok {
+		a.logger.Debug("vote on unknown poll", "poll_id", answer.PollID)
+		return nil
+	}
+	var userID string
+	switch {
+	case answer.Sender != nil:
+		userID = fmt.Sprintf("%d", answer.Sender.ID)
+	case answer.Chat != nil:
+		userID = fmt.Sprintf("%d", answer.Chat.ID)
+	}
+	return a.eventHandler(ctx, channels.Event{
+		Type:        channels.EventTypePollVote,
+		ChannelName: "telegram",
+		ChatID:      chatID,
+		Data: map[string]interface{}{
+			channels.EventDataPollID:  answer.PollID,
+			channels.EventDataUserID:  userID,
+			channels.EventDataOptions: answer.Options,
+			channels.EventDataRemoved: len(answer.Options) == 0,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// sentPoll is a poll sent by the adapter.
+type sentPoll struct {
+	chatID    string
+	expiresAt time.Time
+}
+
+// trackPoll records a sent poll to report votes on it until it closes:
+// after its open period, or pollRetention for polls without one.
+func (a *Adapter) trackPoll(p *telebot.Poll, chatID string, now time.Time) {
+	ttl := pollRetention
+	if p.OpenPeriod > 0 {
+		ttl = time.Duration(p.OpenPeriod) * time.Second
+	}
+	a.pollsMu.Lock()
+	defer a.pollsMu.Unlock()
+	if a.polls == nil {
+		a.polls = make(map[string]sentPoll)
+	}
+	a.sweepPolls(now)
+	a.polls[p.ID] = sentPoll{chatID: chatID, expiresAt: now.Add(ttl)}
+}
+
+// pollChat returns the chat of an open poll sent by the adapter.
+func (a *Adapter) pollChat(pollID string, now time.Time) (string, bool) {
+	a.pollsMu.Lock()
+	defer a.pollsMu.Unlock()
+	p, ok := a.polls[pollID]
+	if !ok || now.After(p.expiresAt) {
+		return "", false
+	}
+	return p.chatID, true
+}
+
+// forgetPoll stops reporting votes on a closed poll.
+func (a *Adapter) forgetPoll(pollID string) {
+	a.pollsMu.Lock()
+	defer a.pollsMu.Unlock()
+	delete(a.polls, pollID)
+}
+
+// sweepPolls removes expired polls, at most once a minute. The caller
+// holds pollsMu.
+func (a *Adapter) sweepPolls(now time.Time) {
+	if now.Sub(a.pollsSweep) < time.Minute {
+		return
+	}
+	a.pollsSweep = now
+	for id, p := range a.polls {
+		if now.After(p.expiresAt) {
+			delete(a.polls, id)
+		}
+	}
+}
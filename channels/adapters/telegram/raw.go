@@ -0,0 +1,128 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Dice is a raw payload sending an animated emoji with a random value.
+type Dice struct {
+	// Emoji is one of 🎲 (default), 🎯, 🏀, ⚽, 🎳, or 🎰.
+	Emoji string
+}
+
+// diceEmoji lists the emoji Telegram accepts for dice.
+var diceEmoji = []telebot.DiceType{
+	telebot.Cube.Type, telebot.Dart.Type, telebot.Ball.Type,
+	telebot.Goal.Type, telebot.Bowl.Type, telebot.Slot.Type,
+}
+
+// Validate checks that the emoji is a dice type.
+func (d Dice) Validate() error {
+	if d.Emoji == "" {
+		return nil
+	}
+	for _, t := range diceEmoji {
+		if telebot.DiceType(d.Emoji) == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported dice emoji %q", d.Emoji)
+}
+
+// Method is a raw payload calling a Bot API method, for constructs envoy
+// does not model such as polls, venues, or inline keyboards. The chat_id
+// and, for forum topics, message_thread_id parameters are added unless set.
+// Only methods that send a message to the chat are accepted.
+type Method struct {
+	// Name is the Bot API method, e.g. "sendPoll".
+	Name string
+
+	Params map[string]interface{}
+}
+
+// rawMethods lists the Bot API methods a Method payload can call: those
+// that send a message to a chat. Methods that administer the bot or its
+// chats, or read from other chats, are not exposed.
+var rawMethods = []string{
+	"sendMessage",
+	"sendPhoto",
+	"sendAudio",
+	"sendDocument",
+	"sendVideo",
+	"sendAnimation",
+	"sendVoice",
+	"sendVideoNote",
+	"sendMediaGroup",
+	"sendLocation",
+	"sendVenue",
+	"sendContact",
+	"sendPoll",
+	"sendDice",
+	"sendSticker",
+	"sendChatAction",
+	"sendInvoice",
+	"sendGame",
+}
+
+// Validate checks that the method is one of rawMethods.
+func (m Method) Validate() error {
+	if m.Name == "" {
+		return errors.New("method name required")
+	}
+	for _, name := range rawMethods {
+		if m.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported method %q", m.Name)
+}
+
+// ValidateRaw accepts Dice and Method payloads.
+func (a *Adapter) ValidateRaw(p channels.RawPayload) error {
+	switch p.(type) {
+	case Dice, Method:
+		return p.Validate()
+	}
+	return fmt.Errorf("%T: %w", p, channels.ErrRawUnsupported)
+}
+
+// sendRaw sends a raw payload to a chat.
+func (a *Adapter) sendRaw(ctx context.Context, chat *telebot.Chat, threadID int, p channels.RawPayload) error {
+	if err := a.ValidateRaw(p); err != nil {
+		return err
+	}
+	switch p := p.(type) {
+	case Dice:
+		dice := telebot.Cube
+		if p.Emoji != "" {
+			dice = &telebot.Dice{Type: telebot.DiceType(p.Emoji)}
+		}
+		if _, err := a.bot.Send(chat, dice, &telebot.SendOptions{ThreadID: threadID}); err != nil {
+			return fmt.Errorf("send dice: %w", rateLimited(err))
+		}
+	case Method:
+		params := make(map[string]interface{}, len(p.Params)+2)
+		for k, v := range p.Params {
+			params[k] = v
+		}
+		if _, ok := params["chat_id"]; !ok {
+			params["chat_id"] = chat.ID
+		}
+		if _, ok := params["message_thread_id"]; !ok && threadID != 0 {
+			params["message_thread_id"] = threadID
+		}
+		if _, err := a.bot.Raw(p.Name, params); err != nil {
+			return fmt.Errorf("%s: %w", p.Name, rateLimited(err))
+		}
+	}
+	return nil
+}
+
+// Ensure Adapter implements channels.RawSender.
+var _ channels.RawSender = (*Adapter)(nil)
@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// unknownPayload is a raw payload for another platform.
+type unknownPayload struct{}
+
+func (unknownPayload) Validate() error { return nil }
+
+func TestValidateRaw(t *testing.T) {
+	a := &Adapter{}
+	tests := []struct {
+		name    string
+		payload channels.RawPayload
+		wantErr bool
+	}{
+		{"default dice", Dice{}, false},
+		{"dart", Dice{Emoji: "🎯"}, false},
+		{"other emoji", Dice{Emoji: "🍕"}, true},
+		{"poll", Method{Name: "sendPoll", Params: map[string]interface{}{"question": "?"}}, false},
+		{"venue", Method{Name: "sendVenue"}, false},
+		{"unnamed method", Method{}, true},
+		{"admin method", Method{Name: "banChatMember"}, true},
+		{"bot settings", Method{Name: "setWebhook"}, true},
+		{"other chat", Method{Name: "forwardMessage"}, true},
+		{"wrong case", Method{Name: "SendPoll"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := a.ValidateRaw(tt.payload); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRaw(%+v) = %v, wantErr %v", tt.payload, err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := a.ValidateRaw(unknownPayload{}); !errors.Is(err, channels.ErrRawUnsupported) {
+		t.Errorf("ValidateRaw(unknownPayload) = %v, want ErrRawUnsupported", err)
+	}
+}
@@ -0,0 +1,115 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// AddReaction reacts to a message. Bots have a single reaction per
+// message, so it replaces the bot's previous reaction. Custom emoji are
+// referenced by their ID.
+func (a *Adapter) AddReaction(ctx context.Context, chatID, messageID, emoji string) error {
+	if err := a.react(chatID, messageID, []telebot.Reaction{reactionType(emoji)}); err != nil {
+		return fmt.Errorf("add reaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveReaction removes the bot's reaction from a message.
+func (a *Adapter) RemoveReaction(ctx context.Context, chatID, messageID, emoji string) error {
+	if err := a.react(chatID, messageID, nil); err != nil {
+		return fmt.Errorf("remove reaction: %w", err)
+	}
+	return nil
+}
+
+// react sets the bot's reactions on a message.
+func (a *Adapter) react(chatID, messageID string, reactions []telebot.Reaction) error {
+	stored, err := a.storedMessage(chatID, messageID)
+	if err != nil {
+		return err
+	}
+	if len(reactions) == 0 {
+		// telebot omits empty lists, which Telegram needs to clear them.
+		_, err := a.bot.Raw("setMessageReaction", map[string]interface{}{
+			"chat_id":    stored.ChatID,
+			"message_id": messageID,
+			"reaction":   []telebot.Reaction{},
+		})
+		return err
+	}
+	return a.bot.React(&telebot.Chat{ID: stored.ChatID}, stored, telebot.ReactionOptions{Reactions: reactions})
+}
+
+// reactionType returns the reaction type of an emoji or custom emoji ID.
+func reactionType(emoji string) telebot.Reaction {
+	for _, r := range emoji {
+		if r < '0' || r > '9' {
+			return telebot.Reaction{Type: "emoji", Emoji: emoji}
+		}
+	}
+	return telebot.Reaction{Type: "custom_emoji", CustomEmoji: emoji}
+}
+
+// reactionEmoji returns the emoji of a reaction, or the ID of a custom
+// emoji.
+func reactionEmoji(r telebot.Reaction) string {
+	if r.CustomEmoji != "" {
+		return r.CustomEmoji
+	}
+	return r.Emoji
+}
+
+// handleReaction reports a change of a user's reactions as one reaction
+// event per added or removed emoji. Telegram only reports reactions in
+// groups where the bot is an administrator.
+func (a *Adapter) handleReaction(ctx context.Context, mr *telebot.MessageReaction) {
+	if a.eventHandler == nil || mr.Chat == nil {
+		return
+	}
+	userID := ""
+	if mr.User != nil {
+		userID = fmt.Sprintf("%d", mr.User.ID)
+	} else if mr.ActorChat != nil {
+		userID = fmt.Sprintf("%d", mr.ActorChat.ID)
+	}
+
+	old := make(map[string]bool)
+	for _, r := range mr.OldReaction {
+		old[reactionEmoji(r)] = true
+	}
+	emit := func(emoji string, removed bool) {
+		err := a.eventHandler(ctx, channels.Event{
+			Type:        channels.EventTypeReaction,
+			ChannelName: "telegram",
+			ChatID:      fmt.Sprintf("%d", mr.Chat.ID),
+			Data: map[string]interface{}{
+				channels.EventDataMessageID: fmt.Sprintf("%d", mr.MessageID),
+				channels.EventDataEmoji:     emoji,
+				channels.EventDataUserID:    userID,
+				channels.EventDataRemoved:   removed,
+			},
+			Timestamp: mr.Time(),
+		})
+		if err != nil {
+			a.logger.Error("event handler error", "type", channels.EventTypeReaction, "error", err)
+		}
+	}
+	for _, r := range mr.NewReaction {
+		emoji := reactionEmoji(r)
+		if old[emoji] {
+			delete(old, emoji)
+			continue
+		}
+		emit(emoji, false)
+	}
+	for _, r := range mr.OldReaction {
+		if emoji := reactionEmoji(r); old[emoji] {
+			emit(emoji, true)
+		}
+	}
+}
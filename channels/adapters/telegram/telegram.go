@@ -2,10 +2,14 @@
 package telegram
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/telebot.v3"
@@ -20,12 +24,37 @@ type Adapter struct {
 	logger         *slog.Logger
 	messageHandler channels.MessageHandler
 	eventHandler   channels.EventHandler
+
+	streamInterval    time.Duration
+	streamPlaceholder string
+	forumTopics       bool
+
+	// Sent polls by poll ID, to report votes
+	polls      map[string]sentPoll
+	pollsMu    sync.Mutex
+	pollsSweep time.Time
 }
 
 // Config configures the Telegram adapter.
 type Config struct {
 	Token  string
 	Logger *slog.Logger
+
+	// StreamInterval is the minimum time between edits of streamed
+	// responses (default: channels.DefaultEditInterval). Telegram allows
+	// about one edit per second in a chat.
+	StreamInterval time.Duration
+
+	// StreamPlaceholder is shown while the first text of a streamed
+	// response is awaited (default: "…").
+	StreamPlaceholder string
+
+	// ForumTopics gives each topic of a forum supergroup its own chat ID,
+	// "<chat>/<topic>", so replies stay in the topic and each topic has
+	// its own session. It is off by default because it changes the
+	// session IDs of existing forum chats. Topics created with
+	// CreateThread are addressed this way either way.
+	ForumTopics bool
 }
 
 // New creates a new Telegram adapter.
@@ -36,10 +65,19 @@ func New(config Config) (*Adapter, error) {
 	if config.Logger == nil {
 		config.Logger = slog.Default()
 	}
+	if config.StreamInterval <= 0 {
+		config.StreamInterval = channels.DefaultEditInterval
+	}
+	if config.StreamPlaceholder == "" {
+		config.StreamPlaceholder = "…"
+	}
 
 	return &Adapter{
-		token:  config.Token,
-		logger: config.Logger,
+		token:             config.Token,
+		logger:            channels.ChannelLogger(config.Logger, "telegram"),
+		streamInterval:    config.StreamInterval,
+		streamPlaceholder: config.StreamPlaceholder,
+		forumTopics:       config.ForumTopics,
 	}, nil
 }
 
@@ -48,11 +86,43 @@ func (a *Adapter) Name() string {
 	return "telegram"
 }
 
+// Capabilities returns the platform's limits. Telegram limits text messages
+// to 4096 UTF-16 code units, in which most emoji count twice.
+func (a *Adapter) Capabilities() channels.Capabilities {
+	return channels.Capabilities{
+		MaxMessageLength: 4096,
+		MessageLength:    channels.UTF16Length,
+		Components:       true,
+		Stickers:         true,
+	}
+}
+
 // Connect establishes connection to Telegram.
 func (a *Adapter) Connect(ctx context.Context) error {
+	// telebot does not dispatch reaction updates or poll messages, so they
+	// are taken from the poller. Telegram only sends reactions and votes
+	// when explicitly allowed.
+	poller := &telebot.LongPoller{
+		Timeout:        10 * time.Second,
+		AllowedUpdates: []string{"message", "edited_message", "callback_query", "message_reaction", "poll_answer"},
+	}
 	pref := telebot.Settings{
-		Token:  a.token,
-		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+		Token: a.token,
+		Poller: telebot.NewMiddlewarePoller(poller, func(u *telebot.Update) bool {
+			switch {
+			case u.MessageReaction != nil:
+				a.handleReaction(ctx, u.MessageReaction)
+			case u.Message != nil && u.Message.Poll != nil:
+				if a.messageHandler != nil {
+					if err := a.messageHandler(ctx, a.convertIncoming(u.Message)); err != nil {
+						a.logger.Error("message handler error", "error", err)
+					}
+				}
+			default:
+				return true
+			}
+			return false
+		}),
 	}
 
 	bot, err := telebot.NewBot(pref)
@@ -62,14 +132,54 @@ func (a *Adapter) Connect(ctx context.Context) error {
 
 	a.bot = bot
 
-	// Set up message handler
-	a.bot.Handle(telebot.OnText, func(c telebot.Context) error {
+	// Set up message handler for text and media
+	handleMessage := func(c telebot.Context) error {
 		if a.messageHandler == nil {
 			return nil
 		}
 
 		msg := a.convertIncoming(c.Message())
 		return a.messageHandler(ctx, msg)
+	}
+	for _, endpoint := range []string{telebot.OnText, telebot.OnMedia, telebot.OnLocation, telebot.OnVenue, telebot.OnContact} {
+		a.bot.Handle(endpoint, handleMessage)
+	}
+	a.bot.Handle(telebot.OnPollAnswer, func(c telebot.Context) error {
+		return a.handlePollAnswer(ctx, c.PollAnswer())
+	})
+	a.bot.Handle(telebot.OnPoll, func(c telebot.Context) error {
+		if p := c.Poll(); p != nil && p.Closed {
+			a.forgetPoll(p.ID)
+		}
+		return nil
+	})
+
+	// Report button presses as interactions or quick replies
+	a.bot.Handle(telebot.OnCallback, func(c telebot.Context) error {
+		return a.handleCallback(ctx, c)
+	})
+
+	// Report video chats as call events
+	a.bot.Handle(telebot.OnVideoChatStarted, func(c telebot.Context) error {
+		return a.emitCallEvent(ctx, c.Message(), channels.EventTypeCallStarted, nil)
+	})
+	a.bot.Handle(telebot.OnVideoChatEnded, func(c telebot.Context) error {
+		duration := time.Duration(c.Message().VideoChatEnded.Duration) * time.Second
+		return a.emitCallEvent(ctx, c.Message(), channels.EventTypeCallEnded, map[string]interface{}{
+			channels.EventDataCallDuration: duration,
+		})
+	})
+	a.bot.Handle(telebot.OnVideoChatParticipants, func(c telebot.Context) error {
+		// Telegram only reports invitations, not actual joins.
+		var errs []error
+		for _, u := range c.Message().VideoChatParticipants.Users {
+			errs = append(errs, a.emitCallEvent(ctx, c.Message(), channels.EventTypeCallParticipantJoined, map[string]interface{}{
+				channels.EventDataParticipantID:   fmt.Sprintf("%d", u.ID),
+				channels.EventDataParticipantName: displayName(&u),
+				"invited":                         true,
+			}))
+		}
+		return errors.Join(errs...)
 	})
 
 	// Start bot in background
@@ -90,39 +200,182 @@ func (a *Adapter) Disconnect(ctx context.Context) error {
 	return nil
 }
 
+// HealthCheck verifies the bot token with the Telegram API.
+func (a *Adapter) HealthCheck(ctx context.Context) error {
+	if a.bot == nil {
+		return fmt.Errorf("telegram bot not connected")
+	}
+	if _, err := a.bot.Raw("getMe", nil); err != nil {
+		return fmt.Errorf("telegram getMe: %w", err)
+	}
+	return nil
+}
+
 // Send sends a message to a Telegram chat.
 func (a *Adapter) Send(ctx context.Context, chatID string, msg channels.OutgoingMessage) error {
+	_, err := a.SendWithReceipt(ctx, chatID, msg)
+	return err
+}
+
+// SendMessage sends a message to a Telegram chat and returns the
+// MessageID of its receipt.
+func (a *Adapter) SendMessage(ctx context.Context, chatID string, msg channels.OutgoingMessage) (string, error) {
+	receipt, err := a.SendWithReceipt(ctx, chatID, msg)
+	return receipt.MessageID, err
+}
+
+// SendWithReceipt sends a message to a Telegram chat and returns the
+// receipt of the last message sent for it: the text, or the last media.
+// Raw payloads are sent without reporting a message ID.
+func (a *Adapter) SendWithReceipt(ctx context.Context, chatID string, msg channels.OutgoingMessage) (channels.MessageReceipt, error) {
+	receipt := channels.MessageReceipt{ChannelName: "telegram", ChatID: chatID}
 	if a.bot == nil {
-		return fmt.Errorf("telegram bot not connected")
+		return receipt, fmt.Errorf("telegram bot not connected")
 	}
 
 	// Parse chat ID
-	chatIDInt, err := strconv.ParseInt(chatID, 10, 64)
+	chatIDInt, threadID, err := parseChatID(chatID)
 	if err != nil {
-		return fmt.Errorf("parse chat ID: %w", err)
+		return receipt, err
+	}
+	if msg.ThreadID != "" {
+		if threadID, err = strconv.Atoi(msg.ThreadID); err != nil {
+			return receipt, fmt.Errorf("parse thread ID: %w", err)
+		}
+		receipt.ChatID = topicChatID(chatIDInt, threadID)
 	}
 	chat, err := a.bot.ChatByID(chatIDInt)
 	if err != nil {
-		return fmt.Errorf("get chat: %w", err)
+		return receipt, fmt.Errorf("get chat: %w", err)
+	}
+	if msg.Raw != nil {
+		receipt.Timestamp = time.Now()
+		return receipt, a.sendRaw(ctx, chat, threadID, msg.Raw)
 	}
 
 	// Send text message
-	opts := &telebot.SendOptions{}
-	switch msg.Format {
+	opts := &telebot.SendOptions{ThreadID: threadID, ParseMode: parseMode(msg.Format)}
+
+	// TODO: Handle reply_to when msg.ReplyTo != ""
+
+	if len(msg.Components) > 0 {
+		if opts.ReplyMarkup, err = replyMarkup(msg.Components); err != nil {
+			return receipt, err
+		}
+	}
+
+	if msg.Content != "" || len(msg.Media) == 0 {
+		sent, err := a.bot.Send(chat, msg.Content, opts)
+		if err != nil {
+			return receipt, fmt.Errorf("send message: %w", rateLimited(err))
+		}
+		receipt.MessageID, receipt.Timestamp = fmt.Sprintf("%d", sent.ID), sent.Time()
+	}
+
+	for _, m := range msg.Media {
+		var what interface{}
+		switch m.Type {
+		case channels.MediaTypeLocation, channels.MediaTypeContact, channels.MediaTypePoll:
+			sent, err := a.sendPayload(chat, threadID, m)
+			if err != nil {
+				return receipt, err
+			}
+			receipt.MessageID, receipt.Timestamp = fmt.Sprintf("%d", sent.ID), sent.Time()
+			continue
+		case channels.MediaTypeSticker:
+			what = &telebot.Sticker{File: telegramFile(m)}
+		case channels.MediaTypeAnimation:
+			what = &telebot.Animation{File: telegramFile(m), Caption: m.Caption, FileName: m.Filename}
+		case channels.MediaTypeVoice:
+			// Voice notes must be OGG/Opus to play inline
+			what = &telebot.Voice{File: telegramFile(m), Caption: m.Caption, MIME: m.MimeType}
+		case channels.MediaTypeAudio:
+			what = &telebot.Audio{File: telegramFile(m), Caption: m.Caption, FileName: m.Filename, MIME: m.MimeType}
+		default:
+			a.logger.Warn("unsupported media type", "type", m.Type)
+			continue
+		}
+		sent, err := a.bot.Send(chat, what, &telebot.SendOptions{ThreadID: threadID})
+		if err != nil {
+			return receipt, fmt.Errorf("send %s: %w", m.Type, rateLimited(err))
+		}
+		receipt.MessageID, receipt.Timestamp = fmt.Sprintf("%d", sent.ID), sent.Time()
+	}
+
+	return receipt, nil
+}
+
+// parseMode returns the Telegram parse mode of a message format.
+func parseMode(format channels.MessageFormat) telebot.ParseMode {
+	switch format {
 	case channels.MessageFormatMarkdown:
-		opts.ParseMode = telebot.ModeMarkdown
+		return telebot.ModeMarkdown
+	case channels.MessageFormatMarkdownV2:
+		return telebot.ModeMarkdownV2
 	case channels.MessageFormatHTML:
-		opts.ParseMode = telebot.ModeHTML
+		return telebot.ModeHTML
 	}
+	return telebot.ModeDefault
+}
 
-	// TODO: Handle reply_to when msg.ReplyTo != ""
+// rateLimited converts Telegram flood errors to *channels.RateLimitedError.
+func rateLimited(err error) error {
+	var flood telebot.FloodError
+	if errors.As(err, &flood) {
+		return &channels.RateLimitedError{
+			Channel:    "telegram",
+			RetryAfter: time.Duration(flood.RetryAfter) * time.Second,
+			Err:        err,
+		}
+	}
+	return err
+}
+
+// telegramFile references media by file ID, URL, or uploaded data.
+func telegramFile(m channels.Media) telebot.File {
+	switch {
+	case m.FileID != "":
+		return telebot.File{FileID: m.FileID}
+	case m.URL != "":
+		return telebot.FromURL(m.URL)
+	default:
+		return telebot.FromReader(bytes.NewReader(m.Data))
+	}
+}
+
+// CreateThread creates a forum topic in a supergroup and posts the first
+// message in it. The returned chat ID addresses the topic.
+func (a *Adapter) CreateThread(ctx context.Context, chatID, title string, first channels.OutgoingMessage) (string, error) {
+	if a.bot == nil {
+		return "", fmt.Errorf("telegram bot not connected")
+	}
 
-	_, err = a.bot.Send(chat, msg.Content, opts)
+	chatIDInt, _, err := parseChatID(chatID)
+	if err != nil {
+		return "", err
+	}
+	chat, err := a.bot.ChatByID(chatIDInt)
+	if err != nil {
+		return "", fmt.Errorf("get chat: %w", err)
+	}
+	topic, err := a.bot.CreateTopic(chat, &telebot.Topic{Name: title})
 	if err != nil {
-		return fmt.Errorf("send message: %w", err)
+		return "", fmt.Errorf("create topic: %w", rateLimited(err))
 	}
 
-	return nil
+	threadChatID := topicChatID(chatIDInt, topic.ThreadID)
+	if first.Content != "" || len(first.Media) > 0 {
+		if err := a.Send(ctx, threadChatID, first); err != nil {
+			return threadChatID, err
+		}
+	}
+	return threadChatID, nil
+}
+
+// DirectChatID returns the private chat with a user, which shares the
+// user's ID. The user must have started a conversation with the bot.
+func (a *Adapter) DirectChatID(ctx context.Context, userID string) (string, error) {
+	return userID, nil
 }
 
 // OnMessage registers a message handler.
@@ -147,22 +400,40 @@ func (a *Adapter) convertIncoming(msg *telebot.Message) channels.IncomingMessage
 		chatType = channels.ChannelTypeDM
 	}
 
-	senderName := msg.Sender.FirstName
-	if msg.Sender.LastName != "" {
-		senderName += " " + msg.Sender.LastName
+	senderName := displayName(msg.Sender)
+
+	// Media messages carry their text as caption; locations, contacts,
+	// and polls are described
+	media := incomingMedia(msg)
+	content := msg.Text
+	if content == "" {
+		content = msg.Caption
+	}
+	if content == "" {
+		content = channels.MediaContent(media)
 	}
-	if senderName == "" {
-		senderName = msg.Sender.Username
+
+	chatID := fmt.Sprintf("%d", msg.Chat.ID)
+	var threadID string
+	if a.forumTopics && msg.TopicMessage && msg.ThreadID != 0 {
+		chatID = topicChatID(msg.Chat.ID, msg.ThreadID)
+		chatType = channels.ChannelTypeThread
+		threadID = fmt.Sprintf("%d", msg.ThreadID)
 	}
 
 	return channels.IncomingMessage{
 		ID:          fmt.Sprintf("%d", msg.ID),
 		ChannelName: "telegram",
-		ChatID:      fmt.Sprintf("%d", msg.Chat.ID),
+		ChatID:      chatID,
 		ChatType:    chatType,
+		ThreadID:    threadID,
 		SenderID:    fmt.Sprintf("%d", msg.Sender.ID),
 		SenderName:  senderName,
-		Content:     msg.Text,
+		Content:     content,
+		Media:       media,
+		Mentions:    mentions(msg),
+		MentionsBot: a.mentionsBot(msg),
+		CustomEmoji: customEmoji(msg),
 		Timestamp:   msg.Time(),
 		Metadata: map[string]interface{}{
 			"chat_title": msg.Chat.Title,
@@ -171,5 +442,98 @@ func (a *Adapter) convertIncoming(msg *telebot.Message) channels.IncomingMessage
 	}
 }
 
-// Ensure Adapter implements Channel interface.
-var _ channels.Channel = (*Adapter)(nil)
+// mentions returns the users mentioned in a message: user IDs for text
+// mentions and @usernames otherwise.
+func mentions(msg *telebot.Message) []string {
+	var out []string
+	for _, e := range msg.Entities {
+		switch e.Type {
+		case telebot.EntityMention:
+			out = append(out, msg.EntityText(e))
+		case telebot.EntityTMention:
+			if e.User != nil {
+				out = append(out, fmt.Sprintf("%d", e.User.ID))
+			}
+		}
+	}
+	return out
+}
+
+// mentionsBot reports whether a message mentions or replies to the bot.
+func (a *Adapter) mentionsBot(msg *telebot.Message) bool {
+	me := a.bot.Me
+	if me == nil {
+		return false
+	}
+	if msg.ReplyTo != nil && msg.ReplyTo.Sender != nil && msg.ReplyTo.Sender.ID == me.ID {
+		return true
+	}
+	for _, mention := range mentions(msg) {
+		if strings.EqualFold(mention, "@"+me.Username) || mention == fmt.Sprintf("%d", me.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// topicChatID returns the chat ID addressing a forum topic: "<chat>/<thread>".
+func topicChatID(chatID int64, threadID int) string {
+	return fmt.Sprintf("%d/%d", chatID, threadID)
+}
+
+// parseChatID parses a chat ID, optionally addressing a forum topic.
+func parseChatID(chatID string) (int64, int, error) {
+	chatPart, threadPart, hasThread := strings.Cut(chatID, "/")
+	id, err := strconv.ParseInt(chatPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse chat ID: %w", err)
+	}
+	if !hasThread {
+		return id, 0, nil
+	}
+	threadID, err := strconv.Atoi(threadPart)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse thread ID: %w", err)
+	}
+	return id, threadID, nil
+}
+
+// emitCallEvent sends a video chat service message as a call event.
+func (a *Adapter) emitCallEvent(ctx context.Context, msg *telebot.Message, eventType channels.EventType, data map[string]interface{}) error {
+	if a.eventHandler == nil {
+		return nil
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data[channels.EventDataCallKind] = channels.CallKindVideoChat
+	return a.eventHandler(ctx, channels.Event{
+		Type:        eventType,
+		ChannelName: "telegram",
+		ChatID:      fmt.Sprintf("%d", msg.Chat.ID),
+		Data:        data,
+		Timestamp:   msg.Time(),
+	})
+}
+
+// displayName returns a user's full name, falling back to the username.
+func displayName(u *telebot.User) string {
+	name := u.FirstName
+	if u.LastName != "" {
+		name += " " + u.LastName
+	}
+	if name == "" {
+		name = u.Username
+	}
+	return name
+}
+
+// Ensure Adapter implements Channel, Threader, DirectMessenger,
+// CapabilityReporter, and Reactor interfaces.
+var (
+	_ channels.Channel            = (*Adapter)(nil)
+	_ channels.Threader           = (*Adapter)(nil)
+	_ channels.DirectMessenger    = (*Adapter)(nil)
+	_ channels.CapabilityReporter = (*Adapter)(nil)
+	_ channels.Reactor            = (*Adapter)(nil)
+)
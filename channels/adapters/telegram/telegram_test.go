@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+func TestForumTopicChatID(t *testing.T) {
+	msg := &telebot.Message{
+		ID:           7,
+		Chat:         &telebot.Chat{ID: -100, Type: telebot.ChatSuperGroup},
+		Sender:       &telebot.User{ID: 1},
+		Text:         "hi",
+		TopicMessage: true,
+		ThreadID:     5,
+	}
+	tests := []struct {
+		forumTopics  bool
+		wantChatID   string
+		wantThreadID string
+		wantType     channels.ChannelType
+	}{
+		// Existing forum chats keep their chat and session IDs
+		{false, "-100", "", channels.ChannelTypeGroup},
+		{true, "-100/5", "5", channels.ChannelTypeThread},
+	}
+	for _, tt := range tests {
+		a := &Adapter{bot: &telebot.Bot{}, forumTopics: tt.forumTopics}
+		got := a.convertIncoming(msg)
+		if got.ChatID != tt.wantChatID || got.ThreadID != tt.wantThreadID || got.ChatType != tt.wantType {
+			t.Errorf("forumTopics=%v: chat = %q, thread = %q, type = %s; want %q, %q, %s",
+				tt.forumTopics, got.ChatID, got.ThreadID, got.ChatType, tt.wantChatID, tt.wantThreadID, tt.wantType)
+		}
+	}
+}
+
+func TestPollExpiry(t *testing.T) {
+	a := &Adapter{}
+	now := time.Now()
+	a.trackPoll(&telebot.Poll{ID: "timed", OpenPeriod: 60}, "1", now)
+	a.trackPoll(&telebot.Poll{ID: "open"}, "2", now)
+	a.trackPoll(&telebot.Poll{ID: "closed"}, "3", now)
+	a.forgetPoll("closed")
+
+	tests := []struct {
+		id     string
+		at     time.Time
+		wantOK bool
+	}{
+		{"timed", now.Add(30 * time.Second), true},
+		{"timed", now.Add(2 * time.Minute), false},
+		{"open", now.Add(24 * time.Hour), true},
+		{"open", now.Add(pollRetention + time.Minute), false},
+		{"closed", now, false},
+	}
+	for _, tt := range tests {
+		if _, ok := a.pollChat(tt.id, tt.at); ok != tt.wantOK {
+			t.Errorf("pollChat(%s) after %v = %v, want %v", tt.id, tt.at.Sub(now), ok, tt.wantOK)
+		}
+	}
+
+	// Sending another poll sweeps expired ones
+	a.trackPoll(&telebot.Poll{ID: "next"}, "1", now.Add(2*time.Minute))
+	if _, ok := a.polls["timed"]; ok {
+		t.Error("expired poll not swept")
+	}
+}
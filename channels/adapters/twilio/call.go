@@ -0,0 +1,221 @@
+package twilio
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamEvent is a Twilio Media Streams protocol message.
+type streamEvent struct {
+	Event     string       `json:"event"`
+	StreamSID string       `json:"streamSid,omitempty"`
+	Start     *streamStart `json:"start,omitempty"`
+	Media     *streamMedia `json:"media,omitempty"`
+	Mark      *streamMark  `json:"mark,omitempty"`
+}
+
+type streamStart struct {
+	CallSID          string            `json:"callSid"`
+	AccountSID       string            `json:"accountSid"`
+	CustomParameters map[string]string `json:"customParameters"`
+}
+
+type streamMedia struct {
+	Track   string `json:"track,omitempty"`
+	Payload string `json:"payload"`
+}
+
+type streamMark struct {
+	Name string `json:"name"`
+}
+
+// call is an active Twilio Media Stream.
+type call struct {
+	adapter    *Adapter
+	conn       *websocket.Conn
+	callSID    string
+	streamSID  string
+	from       string
+	to         string
+	callerName string
+	seq        int
+
+	// Utterance segmentation state (read loop only)
+	audio      []byte
+	speaking   bool
+	silentFor  time.Duration
+	speechFrom time.Time
+
+	writeMu sync.Mutex
+	once    sync.Once
+}
+
+func newCall(a *Adapter, conn *websocket.Conn) *call {
+	return &call{adapter: a, conn: conn}
+}
+
+// run reads stream events until the call ends.
+func (c *call) run() {
+	var registered bool
+	defer func() {
+		if registered {
+			c.flush()
+			c.adapter.unregister(c)
+		}
+		c.close()
+	}()
+
+	for {
+		var ev streamEvent
+		if err := c.conn.ReadJSON(&ev); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.adapter.logger.Error("twilio media read error", "call", c.callSID, "error", err)
+			}
+			return
+		}
+
+		switch ev.Event {
+		case "start":
+			if ev.Start == nil || registered {
+				continue
+			}
+			if !c.adapter.validStreamToken(ev.Start.CallSID, ev.Start.CustomParameters[tokenParameter]) {
+				c.adapter.logger.Warn("twilio media stream token mismatch", "call", ev.Start.CallSID)
+				return
+			}
+			c.streamSID = ev.StreamSID
+			c.callSID = ev.Start.CallSID
+			c.from = ev.Start.CustomParameters["From"]
+			c.to = ev.Start.CustomParameters["To"]
+			c.callerName = ev.Start.CustomParameters["CallerName"]
+			if err := c.adapter.register(c); err != nil {
+				c.adapter.logger.Warn("twilio media stream rejected", "call", c.callSID, "error", err)
+				return
+			}
+			registered = true
+		case "media":
+			// Audio before an authenticated start is ignored
+			if !registered || ev.Media == nil || (ev.Media.Track != "" && ev.Media.Track != "inbound") {
+				continue
+			}
+			frame, err := base64.StdEncoding.DecodeString(ev.Media.Payload)
+			if err != nil {
+				c.adapter.logger.Warn("invalid media payload", "call", c.callSID, "error", err)
+				continue
+			}
+			c.onFrame(frame)
+		case "stop":
+			return
+		}
+	}
+}
+
+// onFrame feeds a caller audio frame through a simple energy-based voice
+// activity detector and delivers completed utterances.
+func (c *call) onFrame(frame []byte) {
+	speech := rms(frame) >= c.adapter.speechThreshold
+
+	if !c.speaking {
+		if !speech {
+			return
+		}
+		c.speaking = true
+		c.speechFrom = time.Now()
+	}
+
+	c.audio = append(c.audio, frame...)
+	if speech {
+		c.silentFor = 0
+	} else {
+		c.silentFor += time.Duration(len(frame)/frameSize) * frameDuration
+	}
+
+	length := time.Duration(len(c.audio)/frameSize) * frameDuration
+	if c.silentFor >= c.adapter.silence || length >= c.adapter.maxUtterance {
+		c.flush()
+	}
+}
+
+// flush delivers any buffered utterance.
+func (c *call) flush() {
+	if !c.speaking || c.callSID == "" {
+		return
+	}
+	audio := c.audio
+	c.audio = nil
+	c.speaking = false
+	c.silentFor = 0
+	c.adapter.deliver(c, audio, c.speechFrom)
+}
+
+// play streams mu-law audio to the caller in 20ms frames, followed by a mark
+// so Twilio reports when playback completes.
+func (c *call) play(ctx context.Context, audio []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	for off := 0; off < len(audio); off += frameSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := off + frameSize
+		if end > len(audio) {
+			end = len(audio)
+		}
+		if err := c.conn.WriteJSON(streamEvent{
+			Event:     "media",
+			StreamSID: c.streamSID,
+			Media:     &streamMedia{Payload: base64.StdEncoding.EncodeToString(audio[off:end])},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return c.conn.WriteJSON(streamEvent{
+		Event:     "mark",
+		StreamSID: c.streamSID,
+		Mark:      &streamMark{Name: fmt.Sprintf("played-%d", time.Now().UnixNano())},
+	})
+}
+
+// close closes the media stream connection.
+func (c *call) close() {
+	c.once.Do(func() {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		_ = c.conn.Close()
+	})
+}
+
+// rms returns the normalized root-mean-square level of a mu-law frame.
+func rms(frame []byte) float64 {
+	if len(frame) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, b := range frame {
+		s := float64(MulawDecode(b)) / 32768
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(frame)))
+}
+
+// MulawDecode converts a G.711 mu-law sample to 16-bit linear PCM.
+func MulawDecode(u byte) int16 {
+	u = ^u
+	sign := u & 0x80
+	exponent := (u >> 4) & 0x07
+	mantissa := u & 0x0F
+	sample := ((int16(mantissa) << 3) + 0x84) << exponent
+	sample -= 0x84
+	if sign != 0 {
+		return -sample
+	}
+	return sample
+}
@@ -0,0 +1,394 @@
+// Package twilio provides a Twilio Voice channel adapter for envoy.
+//
+// Incoming calls are answered with TwiML that opens a Twilio Media Stream to
+// the adapter. Caller audio is segmented into utterances and delivered as
+// MediaTypeVoice messages; outgoing voice media is streamed back to the call.
+package twilio
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 - Twilio request signatures are defined as HMAC-SHA1
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+const (
+	// MimeTypeMulaw is the audio format used by Twilio Media Streams:
+	// 8kHz mono G.711 mu-law.
+	MimeTypeMulaw = "audio/x-mulaw;rate=8000"
+
+	// frameSize is the number of mu-law bytes in a 20ms frame.
+	frameSize = 160
+
+	// frameDuration is the playback duration of one frame.
+	frameDuration = 20 * time.Millisecond
+
+	// tokenParameter is the stream parameter carrying the stream token.
+	tokenParameter = "StreamToken"
+)
+
+// Adapter implements the Channel interface for Twilio Voice.
+type Adapter struct {
+	address         string
+	publicURL       string
+	authToken       string
+	streamKey       []byte
+	silence         time.Duration
+	maxUtterance    time.Duration
+	speechThreshold float64
+	logger          *slog.Logger
+	upgrader        websocket.Upgrader
+	server          *http.Server
+	messageHandler  channels.MessageHandler
+	eventHandler    channels.EventHandler
+	calls           map[string]*call
+	mu              sync.RWMutex
+}
+
+// Config configures the Twilio Voice adapter.
+type Config struct {
+	// Address is the listen address for the built-in HTTP server. Leave empty
+	// to mount Handler() on an existing server instead.
+	Address string
+
+	// PublicURL is the externally reachable base URL (e.g., "https://envoy.example.com").
+	// Twilio is pointed at PublicURL + "/voice", and the media stream is opened
+	// at the wss:// equivalent of PublicURL + "/media".
+	PublicURL string
+
+	// AuthToken is the Twilio auth token used to validate webhook signatures.
+	// Signature validation is skipped when empty.
+	AuthToken string
+
+	// StreamKey signs the token that admits a call's media stream. Replicas
+	// behind a load balancer must share it. Defaults to AuthToken, or a
+	// random key when that is empty too.
+	StreamKey []byte
+
+	// Silence is how long the caller must pause to end an utterance (default: 700ms).
+	Silence time.Duration
+
+	// MaxUtterance caps the length of a single utterance (default: 30s).
+	MaxUtterance time.Duration
+
+	// SpeechThreshold is the RMS level (0-1) above which a frame counts as speech (default: 0.02).
+	SpeechThreshold float64
+
+	Logger *slog.Logger
+}
+
+// New creates a new Twilio Voice adapter.
+func New(config Config) (*Adapter, error) {
+	if config.PublicURL == "" {
+		return nil, fmt.Errorf("twilio public URL required")
+	}
+	if config.Silence == 0 {
+		config.Silence = 700 * time.Millisecond
+	}
+	if config.MaxUtterance == 0 {
+		config.MaxUtterance = 30 * time.Second
+	}
+	if config.SpeechThreshold == 0 {
+		config.SpeechThreshold = 0.02
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if len(config.StreamKey) == 0 && config.AuthToken != "" {
+		config.StreamKey = []byte(config.AuthToken)
+	}
+	if len(config.StreamKey) == 0 {
+		config.StreamKey = make([]byte, 32)
+		if _, err := rand.Read(config.StreamKey); err != nil {
+			return nil, fmt.Errorf("generate stream key: %w", err)
+		}
+	}
+
+	return &Adapter{
+		address:         config.Address,
+		publicURL:       strings.TrimRight(config.PublicURL, "/"),
+		authToken:       config.AuthToken,
+		streamKey:       config.StreamKey,
+		silence:         config.Silence,
+		maxUtterance:    config.MaxUtterance,
+		speechThreshold: config.SpeechThreshold,
+		logger:          channels.ChannelLogger(config.Logger, "twilio"),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+		},
+		calls: make(map[string]*call),
+	}, nil
+}
+
+// Name returns the channel name.
+func (a *Adapter) Name() string {
+	return "twilio"
+}
+
+// Handler returns the HTTP handler serving the voice webhook and media stream.
+func (a *Adapter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/voice", a.handleVoice)
+	mux.HandleFunc("/media", a.handleMedia)
+	return mux
+}
+
+// Connect starts the built-in HTTP server if an address is configured.
+func (a *Adapter) Connect(ctx context.Context) error {
+	if a.address == "" {
+		return nil
+	}
+
+	a.server = &http.Server{
+		Addr:              a.address,
+		Handler:           a.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		a.logger.Info("twilio voice server starting", "address", a.address)
+		if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			a.logger.Error("twilio voice server error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Disconnect hangs up active media streams and stops the HTTP server.
+func (a *Adapter) Disconnect(ctx context.Context) error {
+	a.mu.Lock()
+	for _, c := range a.calls {
+		c.close()
+	}
+	a.mu.Unlock()
+
+	if a.server != nil {
+		if err := a.server.Shutdown(ctx); err != nil {
+			return fmt.Errorf("shutdown twilio voice server: %w", err)
+		}
+		a.logger.Info("twilio voice server stopped")
+	}
+	return nil
+}
+
+// Send plays voice media into an active call. The chat ID is the call SID.
+// Media must be 8kHz mu-law audio (MimeTypeMulaw); text-only messages cannot
+// be played and return an error.
+func (a *Adapter) Send(ctx context.Context, callSID string, msg channels.OutgoingMessage) error {
+	a.mu.RLock()
+	c, ok := a.calls[callSID]
+	a.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("call not active: %s", callSID)
+	}
+
+	var played bool
+	for _, m := range msg.Media {
+		if m.Type != channels.MediaTypeVoice && m.Type != channels.MediaTypeAudio {
+			continue
+		}
+		if len(m.Data) == 0 {
+			return fmt.Errorf("voice media must include data")
+		}
+		if m.MimeType != "" && !strings.HasPrefix(m.MimeType, "audio/x-mulaw") {
+			return fmt.Errorf("unsupported audio format %q, want %s", m.MimeType, MimeTypeMulaw)
+		}
+		if err := c.play(ctx, m.Data); err != nil {
+			return fmt.Errorf("play audio: %w", err)
+		}
+		played = true
+	}
+
+	if !played {
+		return fmt.Errorf("twilio voice requires audio media; synthesize text before sending")
+	}
+	return nil
+}
+
+// OnMessage registers a message handler.
+func (a *Adapter) OnMessage(handler channels.MessageHandler) {
+	a.messageHandler = handler
+}
+
+// OnEvent registers an event handler.
+func (a *Adapter) OnEvent(handler channels.EventHandler) {
+	a.eventHandler = handler
+}
+
+// handleVoice answers the incoming call webhook with TwiML that connects the
+// call to the media stream.
+func (a *Adapter) handleVoice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if a.authToken != "" && !a.validSignature(r) {
+		a.logger.Warn("twilio webhook signature mismatch", "remote", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	callSID := r.PostForm.Get("CallSid")
+	if callSID == "" {
+		http.Error(w, "missing CallSid", http.StatusBadRequest)
+		return
+	}
+
+	streamURL := "wss" + strings.TrimPrefix(strings.TrimPrefix(a.publicURL, "https"), "http") + "/media"
+
+	type parameter struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+	type twiml struct {
+		XMLName xml.Name `xml:"Response"`
+		Stream  struct {
+			URL        string      `xml:"url,attr"`
+			Parameters []parameter `xml:"Parameter"`
+		} `xml:"Connect>Stream"`
+	}
+
+	var resp twiml
+	resp.Stream.URL = streamURL
+	for _, key := range []string{"From", "To", "CallerName"} {
+		if v := r.PostForm.Get(key); v != "" {
+			resp.Stream.Parameters = append(resp.Stream.Parameters, parameter{Name: key, Value: v})
+		}
+	}
+	resp.Stream.Parameters = append(resp.Stream.Parameters, parameter{Name: tokenParameter, Value: a.streamToken(callSID)})
+
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		a.logger.Error("twiml encode error", "error", err)
+	}
+}
+
+// validSignature checks the X-Twilio-Signature header: base64 HMAC-SHA1 of
+// the full request URL followed by the sorted POST parameters.
+func (a *Adapter) validSignature(r *http.Request) bool {
+	var b strings.Builder
+	b.WriteString(a.publicURL + r.URL.RequestURI())
+
+	keys := make([]string, 0, len(r.PostForm))
+	for k := range r.PostForm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range r.PostForm[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(a.authToken))
+	mac.Write([]byte(b.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Twilio-Signature")))
+}
+
+// streamToken returns the token that admits the media stream of callSID.
+// Only the voice webhook hands it out, so a stream can only be opened for a
+// call Twilio announced.
+func (a *Adapter) streamToken(callSID string) string {
+	mac := hmac.New(sha256.New, a.streamKey)
+	mac.Write([]byte(callSID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validStreamToken reports whether token admits the media stream of callSID.
+func (a *Adapter) validStreamToken(callSID, token string) bool {
+	return hmac.Equal([]byte(a.streamToken(callSID)), []byte(token))
+}
+
+// handleMedia upgrades the Twilio Media Stream connection and runs the call.
+// The stream is authenticated by the token in its start event.
+func (a *Adapter) handleMedia(w http.ResponseWriter, r *http.Request) {
+	conn, err := a.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		a.logger.Error("twilio media upgrade failed", "error", err)
+		return
+	}
+
+	c := newCall(a, conn)
+	c.run()
+}
+
+// register tracks an active call once its stream has started. A call SID
+// already streaming is rejected so a second connection cannot take it over.
+func (a *Adapter) register(c *call) error {
+	a.mu.Lock()
+	if _, ok := a.calls[c.callSID]; ok {
+		a.mu.Unlock()
+		return fmt.Errorf("call already streaming: %s", c.callSID)
+	}
+	a.calls[c.callSID] = c
+	a.mu.Unlock()
+	a.logger.Info("call started", "call", c.callSID, "from", c.from)
+	return nil
+}
+
+// unregister removes a finished call.
+func (a *Adapter) unregister(c *call) {
+	a.mu.Lock()
+	if a.calls[c.callSID] == c {
+		delete(a.calls, c.callSID)
+	}
+	a.mu.Unlock()
+	a.logger.Info("call ended", "call", c.callSID)
+}
+
+// deliver hands a completed utterance to the message handler.
+func (a *Adapter) deliver(c *call, audio []byte, started time.Time) {
+	if a.messageHandler == nil {
+		return
+	}
+
+	c.seq++
+	msg := channels.IncomingMessage{
+		ID:          fmt.Sprintf("%s-%d", c.callSID, c.seq),
+		ChannelName: "twilio",
+		ChatID:      c.callSID,
+		ChatType:    channels.ChannelTypeDM,
+		SenderID:    c.from,
+		SenderName:  c.callerName,
+		Media: []channels.Media{{
+			Type:     channels.MediaTypeVoice,
+			Data:     audio,
+			MimeType: MimeTypeMulaw,
+		}},
+		Timestamp: started,
+		Metadata: map[string]interface{}{
+			"stream_sid": c.streamSID,
+			"to":         c.to,
+			"duration":   time.Duration(len(audio)/frameSize) * frameDuration,
+		},
+	}
+
+	// Handle off the read loop so audio keeps flowing while the agent works
+	go func() {
+		if err := a.messageHandler(context.Background(), msg); err != nil {
+			a.logger.Error("message handler error", "call", c.callSID, "error", err)
+		}
+	}()
+}
+
+// Ensure Adapter implements Channel interface.
+var _ channels.Channel = (*Adapter)(nil)
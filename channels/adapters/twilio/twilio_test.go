@@ -0,0 +1,254 @@
+package twilio
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 - Twilio request signatures are defined as HMAC-SHA1
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// loud is a mu-law frame well above the speech threshold.
+var loud = []byte(strings.Repeat("\x00", frameSize))
+
+// quiet is a mu-law frame of silence.
+var quiet = []byte(strings.Repeat("\xff", frameSize))
+
+func newTestAdapter(t *testing.T, authToken string) (*Adapter, *httptest.Server) {
+	t.Helper()
+	a, err := New(Config{
+		PublicURL:    "https://envoy.example.com",
+		AuthToken:    authToken,
+		Silence:      40 * time.Millisecond,
+		MaxUtterance: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(a.Handler())
+	t.Cleanup(srv.Close)
+	return a, srv
+}
+
+// answer posts the voice webhook for callSID and returns the stream token.
+func answer(t *testing.T, a *Adapter, srv *httptest.Server, callSID string) string {
+	t.Helper()
+	form := url.Values{"CallSid": {callSID}, "From": {"+15550100"}}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/voice", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if a.authToken != "" {
+		req.Header.Set("X-Twilio-Signature", sign(a.authToken, a.publicURL+"/voice", form))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("voice status = %d", resp.StatusCode)
+	}
+
+	var twiml struct {
+		Stream struct {
+			URL        string `xml:"url,attr"`
+			Parameters []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:"value,attr"`
+			} `xml:"Parameter"`
+		} `xml:"Connect>Stream"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&twiml); err != nil {
+		t.Fatal(err)
+	}
+	if twiml.Stream.URL != "wss://envoy.example.com/media" {
+		t.Errorf("stream URL = %q", twiml.Stream.URL)
+	}
+	for _, p := range twiml.Stream.Parameters {
+		if p.Name == tokenParameter {
+			return p.Value
+		}
+	}
+	t.Fatal("no stream token in TwiML")
+	return ""
+}
+
+// sign computes the X-Twilio-Signature for a form POST to rawURL.
+func sign(authToken, rawURL string, form url.Values) string {
+	s := rawURL
+	for _, k := range []string{"CallSid", "From"} {
+		s += k + form.Get(k)
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// openStream dials the media stream and sends its start event.
+func openStream(t *testing.T, srv *httptest.Server, callSID, token string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/media", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if err := conn.WriteJSON(streamEvent{
+		Event:     "start",
+		StreamSID: "MZ1",
+		Start: &streamStart{CallSID: callSID, CustomParameters: map[string]string{
+			"From":         "+15550100",
+			tokenParameter: token,
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func sendFrames(t *testing.T, conn *websocket.Conn, frames ...[]byte) {
+	t.Helper()
+	for _, f := range frames {
+		if err := conn.WriteJSON(streamEvent{
+			Event: "media",
+			Media: &streamMedia{Track: "inbound", Payload: base64.StdEncoding.EncodeToString(f)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// waitClosed reports whether the server closes conn.
+func waitClosed(conn *websocket.Conn) bool {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	return err != nil && !strings.Contains(err.Error(), "timeout")
+}
+
+func TestVoiceWebhookSignature(t *testing.T) {
+	a, srv := newTestAdapter(t, "secret")
+	answer(t, a, srv, "CA1")
+
+	form := url.Values{"CallSid": {"CA1"}}
+	resp, err := http.PostForm(srv.URL+"/voice", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unsigned status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestMediaStreamDeliversUtterance(t *testing.T) {
+	a, srv := newTestAdapter(t, "")
+	got := make(chan channels.IncomingMessage, 1)
+	a.OnMessage(func(ctx context.Context, msg channels.IncomingMessage) error {
+		got <- msg
+		return nil
+	})
+
+	conn := openStream(t, srv, "CA1", answer(t, a, srv, "CA1"))
+	sendFrames(t, conn, quiet, loud, loud, quiet, quiet)
+
+	select {
+	case msg := <-got:
+		if msg.ChatID != "CA1" || msg.SenderID != "+15550100" || msg.ID != "CA1-1" {
+			t.Errorf("msg = %+v", msg)
+		}
+		if len(msg.Media) != 1 || len(msg.Media[0].Data) != 4*frameSize || msg.Media[0].MimeType != MimeTypeMulaw {
+			t.Errorf("media = %+v, want the utterance and its trailing silence", msg.Media)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("utterance not delivered")
+	}
+}
+
+func TestMediaStreamRejectsBadToken(t *testing.T) {
+	a, srv := newTestAdapter(t, "")
+	a.OnMessage(func(ctx context.Context, msg channels.IncomingMessage) error {
+		t.Errorf("unexpected message %+v", msg)
+		return nil
+	})
+
+	for _, token := range []string{"", a.streamToken("CA2")} {
+		conn := openStream(t, srv, "CA1", token)
+		if !waitClosed(conn) {
+			t.Errorf("token %q: stream not closed", token)
+		}
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.calls) != 0 {
+		t.Errorf("calls = %v, want none registered", a.calls)
+	}
+}
+
+func TestMediaStreamRejectsTakeover(t *testing.T) {
+	a, srv := newTestAdapter(t, "")
+	token := answer(t, a, srv, "CA1")
+
+	first := openStream(t, srv, "CA1", token)
+	// A frame round trip guarantees the first start was handled
+	sendFrames(t, first, quiet)
+	deadline := time.Now().Add(time.Second)
+	for {
+		a.mu.RLock()
+		c := a.calls["CA1"]
+		a.mu.RUnlock()
+		if c != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first stream not registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	second := openStream(t, srv, "CA1", token)
+	if !waitClosed(second) {
+		t.Error("second stream for the same call not closed")
+	}
+
+	a.mu.RLock()
+	c := a.calls["CA1"]
+	a.mu.RUnlock()
+	if c == nil || c.streamSID != "MZ1" {
+		t.Fatal("first stream lost its call")
+	}
+	if err := a.Send(context.Background(), "CA1", channels.OutgoingMessage{
+		Media: []channels.Media{{Type: channels.MediaTypeVoice, Data: quiet, MimeType: MimeTypeMulaw}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var ev streamEvent
+	_ = first.SetReadDeadline(time.Now().Add(time.Second))
+	if err := first.ReadJSON(&ev); err != nil || ev.Event != "media" {
+		t.Errorf("first stream got %+v, %v; want the played audio", ev, err)
+	}
+}
+
+func TestMulawDecode(t *testing.T) {
+	tests := []struct {
+		in   byte
+		want int16
+	}{
+		{0xff, 0},
+		{0x7f, 0},
+		{0x80, 32124},
+		{0x00, -32124},
+	}
+	for _, tt := range tests {
+		if got := MulawDecode(tt.in); got != tt.want {
+			t.Errorf("MulawDecode(%#x) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package channels
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultAgentName is the name SetAgent registers its agent under.
+const DefaultAgentName = "default"
+
+// AgentSelector picks an agent by name for a message. Returning "" defers
+// to the registry's routes.
+type AgentSelector func(msg IncomingMessage) string
+
+// agentRoute maps messages matching a pattern to a named agent.
+type agentRoute struct {
+	pattern RoutePattern
+	agent   string
+}
+
+// AgentRegistry holds named agents and decides which one handles a message.
+//
+// An agent is selected by, in order: the selector function, the first
+// matching route, the message's channel, and finally the default agent.
+type AgentRegistry struct {
+	mu           sync.RWMutex
+	agents       map[string]AgentProcessor
+	routes       []agentRoute
+	channels     map[string]string
+	selector     AgentSelector
+	defaultAgent string
+}
+
+// NewAgentRegistry creates an empty agent registry.
+func NewAgentRegistry() *AgentRegistry {
+	return &AgentRegistry{
+		agents:   make(map[string]AgentProcessor),
+		channels: make(map[string]string),
+	}
+}
+
+// Register adds or replaces a named agent. The first agent registered
+// becomes the default unless SetDefault is called.
+func (a *AgentRegistry) Register(name string, agent AgentProcessor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.agents[name] = agent
+	if a.defaultAgent == "" {
+		a.defaultAgent = name
+	}
+}
+
+// Unregister removes a named agent.
+func (a *AgentRegistry) Unregister(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.agents, name)
+	if a.defaultAgent == name {
+		a.defaultAgent = ""
+	}
+}
+
+// Get returns a named agent.
+func (a *AgentRegistry) Get(name string) (AgentProcessor, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	agent, ok := a.agents[name]
+	return agent, ok
+}
+
+// Names returns the registered agent names in sorted order.
+func (a *AgentRegistry) Names() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.agents))
+	for name := range a.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDefault sets the agent used when nothing else selects one.
+func (a *AgentRegistry) SetDefault(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.defaultAgent = name
+}
+
+// Route sends messages matching pattern to the named agent. Routes are
+// checked in the order they were added.
+func (a *AgentRegistry) Route(pattern RoutePattern, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.routes = append(a.routes, agentRoute{pattern: pattern, agent: name})
+}
+
+// SetChannelAgent sends all messages from a channel to the named agent.
+func (a *AgentRegistry) SetChannelAgent(channel, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.channels[channel] = name
+}
+
+// SetSelector sets a function that picks an agent per message. It takes
+// precedence over routes and channel mappings.
+func (a *AgentRegistry) SetSelector(selector AgentSelector) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.selector = selector
+}
+
+// Select returns the agent that should handle a message and its name.
+// It returns an error if the selected agent is not registered, and a nil
+// agent without error if no agent is configured.
+func (a *AgentRegistry) Select(msg IncomingMessage) (string, AgentProcessor, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	name := a.selectName(msg)
+	if name == "" {
+		return "", nil, nil
+	}
+	agent, ok := a.agents[name]
+	if !ok {
+		return name, nil, fmt.Errorf("agent %q not registered", name)
+	}
+	return name, agent, nil
+}
+
+// selectName resolves the agent name for a message. The caller must hold
+// the read lock.
+func (a *AgentRegistry) selectName(msg IncomingMessage) string {
+	if a.selector != nil {
+		if name := a.selector(msg); name != "" {
+			return name
+		}
+	}
+	for _, route := range a.routes {
+		if matchPattern(route.pattern, msg) {
+			return route.agent
+		}
+	}
+	if name, ok := a.channels[msg.ChannelName]; ok {
+		return name
+	}
+	return a.defaultAgent
+}
@@ -0,0 +1,78 @@
+package channels
+
+import (
+	"context"
+	"testing"
+)
+
+// namedAgent replies with its own name.
+type namedAgent string
+
+func (a namedAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return string(a), nil
+}
+
+func TestAgentRegistrySelect(t *testing.T) {
+	agents := NewAgentRegistry()
+	agents.Register("support", namedAgent("support"))
+	agents.Register("sales", namedAgent("sales"))
+	agents.Register("vip", namedAgent("vip"))
+	agents.Route(InChats("sales-chat"), "sales")
+	agents.SetChannelAgent("discord", "sales")
+	agents.SetSelector(func(msg IncomingMessage) string {
+		if msg.SenderID == "ceo" {
+			return "vip"
+		}
+		return ""
+	})
+
+	tests := []struct {
+		name string
+		msg  IncomingMessage
+		want string
+	}{
+		{"default", IncomingMessage{ChannelName: "telegram", ChatID: "1"}, "support"},
+		{"route", IncomingMessage{ChannelName: "telegram", ChatID: "sales-chat"}, "sales"},
+		{"channel", IncomingMessage{ChannelName: "discord", ChatID: "1"}, "sales"},
+		{"selector", IncomingMessage{ChannelName: "discord", ChatID: "1", SenderID: "ceo"}, "vip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, agent, err := agents.Select(tt.msg)
+			if err != nil {
+				t.Fatalf("Select failed: %v", err)
+			}
+			if name != tt.want || agent != namedAgent(tt.want) {
+				t.Errorf("Select = %q, want %q", name, tt.want)
+			}
+		})
+	}
+
+	agents.SetChannelAgent("twilio", "missing")
+	if _, _, err := agents.Select(IncomingMessage{ChannelName: "twilio"}); err == nil {
+		t.Error("expected error for unregistered agent")
+	}
+}
+
+func TestProcessWithAgentRegistry(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	router.SetAgent(namedAgent("support"))
+	router.Agents().Register("sales", namedAgent("sales"))
+	router.Agents().Route(InChats("sales-chat"), "sales")
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	for _, chat := range []string{"1", "sales-chat"} {
+		msg := IncomingMessage{ID: chat, ChannelName: "test", ChatID: chat, Content: "hi"}
+		if err := deliverAndWait(router, ch, msg); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+
+	sent := ch.sentMessages()
+	if len(sent) != 2 || sent[0].Content != "support" || sent[1].Content != "sales" {
+		t.Errorf("sent = %+v, want support then sales", sent)
+	}
+}
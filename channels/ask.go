@@ -0,0 +1,121 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultAskTimeout is how long Ask waits for the user's reply.
+const DefaultAskTimeout = 5 * time.Minute
+
+// Errors returned by Ask.
+var (
+	ErrNoAsker    = errors.New("no user to ask outside message processing")
+	ErrAskPending = errors.New("already waiting for the user's reply")
+	ErrAskTimeout = errors.New("user did not reply in time")
+)
+
+// Asker asks the user who sent the message being processed a question and
+// returns their reply.
+type Asker func(ctx context.Context, question string) (string, error)
+
+type askerKey struct{}
+
+// WithAsker returns a context in which Ask uses asker.
+func WithAsker(ctx context.Context, asker Asker) context.Context {
+	return context.WithValue(ctx, askerKey{}, asker)
+}
+
+// Ask asks the user who sent the message being processed a question over
+// its channel and waits for their reply, so tools can ask for missing
+// details mid-request. The router provides an Asker to its handlers; the
+// user's next message in the chat answers the question instead of being
+// routed.
+func Ask(ctx context.Context, question string) (string, error) {
+	asker, ok := ctx.Value(askerKey{}).(Asker)
+	if !ok {
+		return "", ErrNoAsker
+	}
+	return asker(ctx, question)
+}
+
+// questionSet holds the questions awaiting a reply, by askKey.
+type questionSet struct {
+	mu      sync.Mutex
+	pending map[string]chan IncomingMessage
+}
+
+// askKey identifies the sender of a message in its chat.
+func askKey(msg IncomingMessage) string {
+	return chatKey(msg) + "\x00" + msg.SenderID
+}
+
+// asker returns the Asker for the handlers of msg.
+func (r *Router) asker(msg IncomingMessage) Asker {
+	return func(ctx context.Context, question string) (string, error) {
+		return r.ask(ctx, msg, question)
+	}
+}
+
+// ask sends question to the chat of msg and waits for the sender's next
+// message there.
+func (r *Router) ask(ctx context.Context, msg IncomingMessage, question string) (string, error) {
+	key := askKey(msg)
+	reply := make(chan IncomingMessage, 1)
+	r.questions.mu.Lock()
+	if _, ok := r.questions.pending[key]; ok {
+		r.questions.mu.Unlock()
+		return "", ErrAskPending
+	}
+	r.questions.pending[key] = reply
+	r.questions.mu.Unlock()
+	defer func() {
+		r.questions.mu.Lock()
+		delete(r.questions.pending, key)
+		r.questions.mu.Unlock()
+	}()
+
+	if err := r.Send(ctx, msg.ChannelName, msg.ChatID, OutgoingMessage{
+		Content: question,
+		ReplyTo: msg.ID,
+	}); err != nil {
+		return "", fmt.Errorf("send question: %w", err)
+	}
+
+	timer := time.NewTimer(r.options.askTimeout)
+	defer timer.Stop()
+	select {
+	case answer := <-reply:
+		return answer.Content, nil
+	case <-timer.C:
+		return "", ErrAskTimeout
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// awaiting reports whether a question is waiting for a reply from the
+// sender of msg.
+func (r *Router) awaiting(msg IncomingMessage) bool {
+	r.questions.mu.Lock()
+	defer r.questions.mu.Unlock()
+	_, ok := r.questions.pending[askKey(msg)]
+	return ok
+}
+
+// answer delivers msg to the question awaiting its sender's reply, if any,
+// reporting whether it did.
+func (r *Router) answer(msg IncomingMessage) bool {
+	r.questions.mu.Lock()
+	defer r.questions.mu.Unlock()
+	reply, ok := r.questions.pending[askKey(msg)]
+	if !ok {
+		return false
+	}
+	delete(r.questions.pending, askKey(msg))
+	reply <- msg
+	return true
+}
@@ -0,0 +1,124 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsk(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		answer, err := Ask(ctx, "Which account?")
+		if err != nil {
+			return err
+		}
+		return router.Send(ctx, msg.ChannelName, msg.ChatID, OutgoingMessage{Content: "using " + answer})
+	})
+
+	if err := ch.deliver(IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", SenderID: "alice", Content: "pay"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(ch.sentMessages()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("question not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if sent := ch.sentMessages(); sent[0].Content != "Which account?" || sent[0].ReplyTo != "1" {
+		t.Errorf("question = %+v", sent[0])
+	}
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "2", ChannelName: "test", ChatID: "c1", SenderID: "alice", Content: "savings"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	if sent := ch.sentMessages(); len(sent) != 2 || sent[1].Content != "using savings" {
+		t.Errorf("sent = %+v, want the answer used", sent)
+	}
+}
+
+func TestAskTimeout(t *testing.T) {
+	router := NewRouter(nil, WithAskTimeout(10*time.Millisecond))
+	ch := newMockChannel("test")
+	router.Register(ch)
+	asked := make(chan error, 1)
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		_, err := Ask(ctx, "Which account?")
+		asked <- err
+		return err
+	})
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", SenderID: "alice", Content: "pay"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if err := <-asked; !errors.Is(err, ErrAskTimeout) {
+		t.Errorf("err = %v, want ErrAskTimeout", err)
+	}
+}
+
+func TestAskOutsideRouter(t *testing.T) {
+	if _, err := Ask(context.Background(), "?"); !errors.Is(err, ErrNoAsker) {
+		t.Errorf("err = %v, want ErrNoAsker", err)
+	}
+}
+
+func TestAskAnswerPassesMiddleware(t *testing.T) {
+	router := NewRouter(nil, WithAskTimeout(50*time.Millisecond))
+	ch := newMockChannel("test")
+	router.Register(ch)
+	router.Use(func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg IncomingMessage) error {
+			if msg.Content == "blocked" {
+				return nil
+			}
+			msg.Content = strings.ToUpper(msg.Content)
+			return next(ctx, msg)
+		}
+	})
+	answers := make(chan string, 2)
+	router.OnMessage(Where(func(msg IncomingMessage) bool { return msg.Content == "PAY" }), func(ctx context.Context, msg IncomingMessage) error {
+		for i := 0; i < 2; i++ {
+			answer, err := Ask(ctx, "Which account?")
+			if err != nil {
+				answer = err.Error()
+			}
+			answers <- answer
+		}
+		return nil
+	})
+
+	if err := ch.deliver(IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", SenderID: "alice", Content: "pay"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	waitForQuestion := func(n int) {
+		deadline := time.Now().Add(time.Second)
+		for len(ch.sentMessages()) < n {
+			if time.Now().After(deadline) {
+				t.Fatal("question not sent")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitForQuestion(1)
+	if err := ch.deliver(IncomingMessage{ID: "2", ChannelName: "test", ChatID: "c1", SenderID: "alice", Content: "savings"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if got := <-answers; got != "SAVINGS" {
+		t.Errorf("answer = %q, want the middleware's rewrite", got)
+	}
+
+	waitForQuestion(2)
+	if err := ch.deliver(IncomingMessage{ID: "3", ChannelName: "test", ChatID: "c1", SenderID: "alice", Content: "blocked"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if got := <-answers; got != ErrAskTimeout.Error() {
+		t.Errorf("answer = %q, want the blocked reply withheld", got)
+	}
+}
@@ -3,6 +3,7 @@ package channels
 
 import (
 	"context"
+	"io"
 )
 
 // Channel represents a messaging channel (Telegram, Discord, etc.).
@@ -37,9 +38,125 @@ type StreamingChannel interface {
 	SendStream(ctx context.Context, chatID string, chunks <-chan string) error
 }
 
+// ReplyStreamer extends StreamingChannel with streams answering a message,
+// for platforms that tie a reply to what it answers, such as Discord slash
+// commands. The router uses it for streamed replies when available.
+type ReplyStreamer interface {
+	StreamingChannel
+
+	// SendStreamReply streams chunks into chatID as the reply to replyTo.
+	SendStreamReply(ctx context.Context, chatID, replyTo string, chunks <-chan string) error
+}
+
+// HealthChecker is implemented by channels that can verify their
+// connection is alive. The router's supervisor reconnects channels whose
+// health check fails.
+type HealthChecker interface {
+	Channel
+
+	// HealthCheck returns an error if the connection is no longer usable.
+	HealthCheck(ctx context.Context) error
+}
+
+// Threader extends Channel with thread creation (Discord threads, Telegram
+// forum topics, Slack threads).
+type Threader interface {
+	Channel
+
+	// CreateThread starts a thread in chatID titled title, posts first in it
+	// if it has content, and returns the chat ID that addresses the thread.
+	// Channels reporting Capabilities.ReplyThreads start the thread on the
+	// message first.ReplyTo, if set.
+	CreateThread(ctx context.Context, chatID, title string, first OutgoingMessage) (string, error)
+}
+
+// MediaDownloader extends Channel with downloads of media the platform
+// references by file ID, such as Telegram attachments.
+type MediaDownloader interface {
+	Channel
+
+	// DownloadMedia opens the content of the file fileID.
+	DownloadMedia(ctx context.Context, fileID string) (io.ReadCloser, error)
+}
+
+// EphemeralSender extends Channel with messages shown to a single user in a
+// shared chat.
+type EphemeralSender interface {
+	Channel
+
+	// SendEphemeral sends msg in chatID so that only userID can see it.
+	SendEphemeral(ctx context.Context, chatID, userID string, msg OutgoingMessage) error
+}
+
+// DirectMessenger extends Channel with direct messages to users.
+type DirectMessenger interface {
+	Channel
+
+	// DirectChatID returns the chat ID of the direct conversation with
+	// userID, opening it if needed.
+	DirectChatID(ctx context.Context, userID string) (string, error)
+}
+
+// RawSender extends Channel with platform-specific payloads sent through
+// OutgoingMessage.Raw.
+type RawSender interface {
+	Channel
+
+	// ValidateRaw reports whether the channel accepts p and p is well
+	// formed. It returns an error wrapping ErrRawUnsupported for payloads
+	// of other platforms.
+	ValidateRaw(p RawPayload) error
+}
+
+// CommandRegistrar extends Channel with native commands (Discord slash
+// commands). Invocations arrive as messages with IncomingMessage.Command
+// set, and replies to them answer the invocation.
+type CommandRegistrar interface {
+	Channel
+
+	// RegisterCommands replaces the channel's commands with specs.
+	RegisterCommands(ctx context.Context, specs []CommandSpec) error
+}
+
+// ReceiptSender extends Channel with receipts for sent messages.
+type ReceiptSender interface {
+	Channel
+
+	// SendWithReceipt sends msg like Send and returns its receipt.
+	SendWithReceipt(ctx context.Context, chatID string, msg OutgoingMessage) (MessageReceipt, error)
+}
+
+// MessageEditor extends ReceiptSender with changes to sent messages, e.g.
+// to stream a response into a placeholder message.
+type MessageEditor interface {
+	ReceiptSender
+
+	// EditMessage replaces the content of messageID in chatID with msg's.
+	EditMessage(ctx context.Context, chatID, messageID string, msg OutgoingMessage) error
+
+	// DeleteMessage deletes messageID from chatID.
+	DeleteMessage(ctx context.Context, chatID, messageID string) error
+}
+
+// Reactor extends Channel with emoji reactions on messages.
+type Reactor interface {
+	Channel
+
+	// AddReaction reacts to messageID in chatID with emoji (Unicode, or a
+	// platform-specific custom emoji reference).
+	AddReaction(ctx context.Context, chatID, messageID, emoji string) error
+
+	// RemoveReaction removes the bot's reaction emoji from messageID.
+	RemoveReaction(ctx context.Context, chatID, messageID, emoji string) error
+}
+
 // MessageHandler handles incoming messages.
 type MessageHandler func(ctx context.Context, msg IncomingMessage) error
 
+// Middleware wraps a MessageHandler. Router middleware runs before route
+// matching, so it can enrich, rewrite, or drop messages.
+type Middleware func(next MessageHandler) MessageHandler
+
 // EventHandler handles channel events.
 type EventHandler func(ctx context.Context, event Event) error
 
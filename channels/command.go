@@ -0,0 +1,163 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseCommand reports whether content is the chat command (e.g.,
+// "/search") and returns its arguments. A trailing bot mention, as in
+// "/search@mybot terms", is ignored.
+func ParseCommand(content, command string) (string, bool) {
+	if !strings.HasPrefix(content, command) {
+		return "", false
+	}
+	rest := content[len(command):]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\n' && rest[0] != '@' {
+		return "", false
+	}
+	// Drop a trailing bot mention (e.g., "/search@mybot terms")
+	if strings.HasPrefix(rest, "@") {
+		if i := strings.IndexAny(rest, " \n"); i >= 0 {
+			rest = rest[i:]
+		} else {
+			rest = ""
+		}
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// ErrCommandsUnsupported is returned by RegisterCommands for channels that
+// do not implement CommandRegistrar.
+var ErrCommandsUnsupported = errors.New("channel does not support native commands")
+
+// CommandOptionType is the value type of a command option.
+type CommandOptionType string
+
+const (
+	CommandOptionString  CommandOptionType = "string"
+	CommandOptionInteger CommandOptionType = "integer"
+	CommandOptionNumber  CommandOptionType = "number"
+	CommandOptionBoolean CommandOptionType = "boolean"
+
+	// CommandOptionUser and CommandOptionChannel take a user or channel
+	// picked from the platform's UI, reported by ID.
+	CommandOptionUser    CommandOptionType = "user"
+	CommandOptionChannel CommandOptionType = "channel"
+)
+
+// CommandSpec declares a command for platforms with native command menus,
+// such as Discord slash commands.
+type CommandSpec struct {
+	// Name is typed after the slash: 1 to 32 lowercase letters, digits,
+	// "-", or "_".
+	Name string
+
+	// Description is shown in the command menu (1 to 100 characters).
+	Description string
+
+	// Options are the command's arguments, required ones first.
+	Options []CommandOption
+}
+
+// CommandOption is an argument of a command.
+type CommandOption struct {
+	// Name follows the rules of command names.
+	Name        string
+	Description string
+
+	// Type is the value type (default: CommandOptionString).
+	Type     CommandOptionType
+	Required bool
+
+	// Choices restricts string options to the listed values.
+	Choices []string
+}
+
+// commandName matches valid command and option names.
+var commandName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ValidateCommands checks command specs against the limits shared by the
+// platforms: at most 100 commands with 25 options and 25 choices each.
+func ValidateCommands(specs []CommandSpec) error {
+	if len(specs) > 100 {
+		return errors.New("at most 100 commands allowed")
+	}
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if !commandName.MatchString(spec.Name) {
+			return fmt.Errorf("invalid command name %q", spec.Name)
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("duplicate command %q", spec.Name)
+		}
+		names[spec.Name] = true
+		if n := utf8.RuneCountInString(spec.Description); n == 0 || n > 100 {
+			return fmt.Errorf("command %q: description must have 1 to 100 characters", spec.Name)
+		}
+		if len(spec.Options) > 25 {
+			return fmt.Errorf("command %q: at most 25 options allowed", spec.Name)
+		}
+		optional := false
+		for _, o := range spec.Options {
+			if !commandName.MatchString(o.Name) {
+				return fmt.Errorf("command %q: invalid option name %q", spec.Name, o.Name)
+			}
+			if n := utf8.RuneCountInString(o.Description); n == 0 || n > 100 {
+				return fmt.Errorf("command %q: option %q: description must have 1 to 100 characters", spec.Name, o.Name)
+			}
+			switch o.Type {
+			case "", CommandOptionString:
+			case CommandOptionInteger, CommandOptionNumber, CommandOptionBoolean, CommandOptionUser, CommandOptionChannel:
+				if len(o.Choices) > 0 {
+					return fmt.Errorf("command %q: option %q: only string options have choices", spec.Name, o.Name)
+				}
+			default:
+				return fmt.Errorf("command %q: option %q: unknown type %q", spec.Name, o.Name, o.Type)
+			}
+			if len(o.Choices) > 25 {
+				return fmt.Errorf("command %q: option %q: at most 25 choices allowed", spec.Name, o.Name)
+			}
+			if o.Required && optional {
+				return fmt.Errorf("command %q: required option %q follows an optional one", spec.Name, o.Name)
+			}
+			optional = optional || !o.Required
+		}
+	}
+	return nil
+}
+
+// Command is a native command invocation, such as a Discord slash command.
+// Adapters also set the message content to the command as typed, e.g.
+// "/search flights", so ParseCommand handlers answer native commands too.
+type Command struct {
+	Name string
+
+	// Options holds the given option values by name: string, int64,
+	// float64, or bool, and IDs for users and channels.
+	Options map[string]interface{}
+}
+
+// RegisterCommands replaces the native commands of a channel implementing
+// CommandRegistrar.
+func (r *Router) RegisterCommands(ctx context.Context, channelName string, specs []CommandSpec) error {
+	channel, ok := r.GetChannel(channelName)
+	if !ok {
+		return errChannelNotFound(channelName)
+	}
+	registrar, ok := channel.(CommandRegistrar)
+	if !ok {
+		return fmt.Errorf("%s: %w", channelName, ErrCommandsUnsupported)
+	}
+	if err := ValidateCommands(specs); err != nil {
+		return err
+	}
+	if err := registrar.RegisterCommands(ctx, specs); err != nil {
+		return fmt.Errorf("register commands: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+		ok      bool
+	}{
+		{"/search flight", "flight", true},
+		{"/search@envoybot flight", "flight", true},
+		{"/search", "", true},
+		{"/searching", "", false},
+		{"hello", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseCommand(tt.content, "/search")
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseCommand(%q) = (%q, %v), want (%q, %v)", tt.content, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidateCommands(t *testing.T) {
+	valid := []CommandSpec{
+		{Name: "search", Description: "Search the history", Options: []CommandOption{
+			{Name: "terms", Description: "What to find", Required: true},
+			{Name: "limit", Description: "Maximum results", Type: CommandOptionInteger},
+		}},
+		{Name: "mode", Description: "Change the mode", Options: []CommandOption{
+			{Name: "mode", Description: "New mode", Choices: []string{"fast", "careful"}},
+		}},
+	}
+	if err := ValidateCommands(valid); err != nil {
+		t.Errorf("ValidateCommands(valid) = %v", err)
+	}
+
+	for _, specs := range [][]CommandSpec{
+		{{Name: "Search", Description: "Uppercase"}},
+		{{Name: "search"}},
+		{{Name: "a", Description: "x"}, {Name: "a", Description: "y"}},
+		{{Name: "a", Description: "x", Options: []CommandOption{{Name: "o", Description: "o", Type: "date"}}}},
+		{{Name: "a", Description: "x", Options: []CommandOption{{Name: "o", Description: "o", Type: CommandOptionBoolean, Choices: []string{"y"}}}}},
+		{{Name: "a", Description: "x", Options: []CommandOption{{Name: "o", Description: "o"}, {Name: "p", Description: "p", Required: true}}}},
+	} {
+		if err := ValidateCommands(specs); err == nil {
+			t.Errorf("ValidateCommands(%+v) succeeded", specs)
+		}
+	}
+}
+
+// commandChannel is a mock channel with native commands.
+type commandChannel struct {
+	*mockChannel
+	specs []CommandSpec
+}
+
+func (c *commandChannel) RegisterCommands(ctx context.Context, specs []CommandSpec) error {
+	c.specs = specs
+	return nil
+}
+
+func TestRouterRegisterCommands(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &commandChannel{mockChannel: newMockChannel("discord")}
+	router.Register(ch)
+	router.Register(newMockChannel("plain"))
+	ctx := context.Background()
+	specs := []CommandSpec{{Name: "search", Description: "Search the history"}}
+
+	if err := router.RegisterCommands(ctx, "discord", specs); err != nil || len(ch.specs) != 1 {
+		t.Errorf("RegisterCommands = %v, registered %+v", err, ch.specs)
+	}
+	if err := router.RegisterCommands(ctx, "discord", []CommandSpec{{Name: "bad name"}}); err == nil {
+		t.Error("RegisterCommands with invalid spec succeeded")
+	}
+	if err := router.RegisterCommands(ctx, "plain", specs); !errors.Is(err, ErrCommandsUnsupported) {
+		t.Errorf("RegisterCommands(plain) = %v, want ErrCommandsUnsupported", err)
+	}
+}
@@ -0,0 +1,206 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ComponentKind is the kind of an interactive component.
+type ComponentKind string
+
+const (
+	// ComponentButton reports an interaction with its ID when pressed, or
+	// opens its URL.
+	ComponentButton ComponentKind = "button"
+
+	// ComponentSelect is a menu reporting an interaction with the values
+	// of the chosen options.
+	ComponentSelect ComponentKind = "select"
+
+	// ComponentQuickReply sends its label as the user's message when
+	// pressed, so it answers Ask and reaches the agent like typed text.
+	ComponentQuickReply ComponentKind = "quick_reply"
+)
+
+// ButtonStyle is the look of a button on platforms that style buttons.
+type ButtonStyle string
+
+const (
+	ButtonPrimary   ButtonStyle = "primary"
+	ButtonSecondary ButtonStyle = "secondary"
+	ButtonSuccess   ButtonStyle = "success"
+	ButtonDanger    ButtonStyle = "danger"
+)
+
+// Component is an interactive element attached to a message.
+type Component struct {
+	Kind ComponentKind
+
+	// ID identifies buttons and menus in interactions. Keep it short:
+	// Telegram allows 64 bytes of callback data, including select values.
+	ID string
+
+	// Label is the text shown on buttons and quick replies.
+	Label string
+
+	// Style is the button style (default: ButtonSecondary).
+	Style ButtonStyle
+
+	// URL makes a button open a link instead of reporting an interaction.
+	URL string
+
+	// Placeholder is shown on a menu before a choice is made.
+	Placeholder string
+
+	// Options are the choices of a menu.
+	Options []SelectOption
+}
+
+// SelectOption is a choice of a select menu.
+type SelectOption struct {
+	Label string
+	Value string
+
+	// Description is shown below the label where supported.
+	Description string
+}
+
+// Button returns a button reporting interactions with id.
+func Button(id, label string, style ButtonStyle) Component {
+	return Component{Kind: ComponentButton, ID: id, Label: label, Style: style}
+}
+
+// LinkButton returns a button opening url.
+func LinkButton(label, url string) Component {
+	return Component{Kind: ComponentButton, Label: label, URL: url}
+}
+
+// QuickReplies returns a row of quick replies, one per label.
+func QuickReplies(labels ...string) []Component {
+	row := make([]Component, len(labels))
+	for i, label := range labels {
+		row[i] = Component{Kind: ComponentQuickReply, Label: label}
+	}
+	return row
+}
+
+// ValidateComponents checks the rows of components of a message.
+func ValidateComponents(rows [][]Component) error {
+	for i, row := range rows {
+		if len(row) == 0 {
+			return fmt.Errorf("component row %d is empty", i)
+		}
+		for _, c := range row {
+			switch c.Kind {
+			case ComponentButton:
+				if c.Label == "" || (c.ID == "") == (c.URL == "") {
+					return errors.New("buttons need a label and either an ID or a URL")
+				}
+			case ComponentSelect:
+				if c.ID == "" || len(c.Options) == 0 {
+					return errors.New("menus need an ID and options")
+				}
+				if len(row) > 1 {
+					return errors.New("menus need a row of their own")
+				}
+			case ComponentQuickReply:
+				if c.Label == "" {
+					return errors.New("quick replies need a label")
+				}
+			default:
+				return fmt.Errorf("unknown component kind %q", c.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+// componentsText renders components as text for channels that do not
+// support them.
+func componentsText(rows [][]Component) string {
+	var lines []string
+	for _, row := range rows {
+		var items []string
+		for _, c := range row {
+			switch {
+			case c.Kind == ComponentSelect:
+				for _, o := range c.Options {
+					lines = append(lines, "• "+o.Label)
+				}
+			case c.URL != "":
+				items = append(items, c.Label+": "+c.URL)
+			default:
+				items = append(items, "["+c.Label+"]")
+			}
+		}
+		if len(items) > 0 {
+			lines = append(lines, strings.Join(items, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Event.Data keys for interactions with message components, which carry
+// the component ID in EventDataCustomID.
+const (
+	// EventDataValues holds the chosen values of a menu as []string.
+	EventDataValues = "values"
+)
+
+// Interaction is a press of a button or a choice in a menu.
+type Interaction struct {
+	ChannelName string
+	ChatID      string
+
+	// ComponentID is the ID of the component.
+	ComponentID string
+
+	// Values are the chosen values of a menu.
+	Values []string
+
+	// UserID is the user who interacted.
+	UserID string
+
+	// MessageID is the message the component is attached to, if known.
+	MessageID string
+
+	Timestamp time.Time
+}
+
+// InteractionFromEvent returns the interaction an interaction event
+// reports.
+func InteractionFromEvent(event Event) (Interaction, bool) {
+	if event.Type != EventTypeInteraction {
+		return Interaction{}, false
+	}
+	i := Interaction{
+		ChannelName: event.ChannelName,
+		ChatID:      event.ChatID,
+		Timestamp:   event.Timestamp,
+	}
+	i.ComponentID, _ = event.Data[EventDataCustomID].(string)
+	i.Values, _ = event.Data[EventDataValues].([]string)
+	i.UserID, _ = event.Data[EventDataUserID].(string)
+	i.MessageID, _ = event.Data[EventDataMessageID].(string)
+	return i, true
+}
+
+// InteractionHandler handles interactions with components.
+type InteractionHandler func(ctx context.Context, i Interaction) error
+
+// OnInteraction adds a handler for interactions with components whose ID
+// starts with prefix, e.g. "confirm:" for the buttons of a confirmation
+// flow. Interactions are processed in order with messages from the same
+// chat.
+func (r *Router) OnInteraction(prefix string, handler InteractionHandler) {
+	r.OnEvent(func(ctx context.Context, event Event) error {
+		i, ok := InteractionFromEvent(event)
+		if !ok || !strings.HasPrefix(i.ComponentID, prefix) {
+			return nil
+		}
+		return handler(ctx, i)
+	}, EventTypeInteraction)
+}
@@ -0,0 +1,112 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestValidateComponents(t *testing.T) {
+	valid := [][]Component{
+		{Button("confirm:yes", "Yes", ButtonSuccess), Button("confirm:no", "No", ButtonDanger)},
+		{{Kind: ComponentSelect, ID: "size", Options: []SelectOption{{Label: "Small", Value: "s"}}}},
+		QuickReplies("Maybe"),
+		{LinkButton("Docs", "https://example.com")},
+	}
+	if err := ValidateComponents(valid); err != nil {
+		t.Errorf("ValidateComponents(valid) = %v", err)
+	}
+
+	for _, rows := range [][][]Component{
+		{{}},
+		{{{Kind: ComponentButton, Label: "No target"}}},
+		{{{Kind: ComponentButton, Label: "Both", ID: "x", URL: "https://example.com"}}},
+		{{{Kind: ComponentSelect, ID: "empty"}}},
+		{{{Kind: ComponentSelect, ID: "m", Options: []SelectOption{{Value: "a"}}}, Button("b", "B", "")}},
+		{{{Kind: "slider"}}},
+	} {
+		if err := ValidateComponents(rows); err == nil {
+			t.Errorf("ValidateComponents(%+v) succeeded", rows)
+		}
+	}
+}
+
+// componentChannel is a mock channel rendering components.
+type componentChannel struct {
+	*mockChannel
+}
+
+func (c *componentChannel) Capabilities() Capabilities {
+	return Capabilities{MaxMessageLength: 30, Components: true}
+}
+
+func TestRouterComponents(t *testing.T) {
+	router := NewRouter(nil)
+	plain := newMockChannel("plain")
+	rich := &componentChannel{newMockChannel("rich")}
+	router.Register(plain)
+	router.Register(rich)
+	ctx := context.Background()
+
+	msg := OutgoingMessage{
+		Content:    "Delete all files in the project folder?",
+		Components: [][]Component{{Button("confirm:yes", "Yes", ButtonDanger), Button("confirm:no", "No", "")}},
+	}
+	if err := router.Send(ctx, "plain", "1", msg); err != nil {
+		t.Fatal(err)
+	}
+	if sent := plain.sentMessages(); len(sent) != 1 || sent[0].Components != nil ||
+		sent[0].Content != "Delete all files in the project folder?\n\n[Yes] [No]" {
+		t.Errorf("plain sent = %+v", sent)
+	}
+
+	if err := router.Send(ctx, "rich", "1", msg); err != nil {
+		t.Fatal(err)
+	}
+	sent := rich.sentMessages()
+	if len(sent) < 2 || sent[0].Components != nil || len(sent[len(sent)-1].Components) != 1 {
+		t.Errorf("rich sent = %+v, want components on the last part only", sent)
+	}
+
+	msg.Components = [][]Component{{{Kind: ComponentButton}}}
+	if err := router.Send(ctx, "rich", "1", msg); err == nil {
+		t.Error("Send with invalid components succeeded")
+	}
+}
+
+func TestOnInteraction(t *testing.T) {
+	router := NewRouter(nil, WithWorkers(0))
+	ch := newMockChannel("discord")
+	router.Register(ch)
+
+	var got []Interaction
+	router.OnInteraction("confirm:", func(ctx context.Context, i Interaction) error {
+		got = append(got, i)
+		return nil
+	})
+
+	for _, id := range []string{"confirm:yes", "other"} {
+		err := ch.events(context.Background(), Event{
+			Type:        EventTypeInteraction,
+			ChannelName: "discord",
+			ChatID:      "c1",
+			Data: map[string]interface{}{
+				EventDataCustomID:  id,
+				EventDataUserID:    "u1",
+				EventDataMessageID: "m1",
+				EventDataValues:    []string{"v"},
+			},
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("interactions = %+v, want 1", got)
+	}
+	if i := got[0]; i.ComponentID != "confirm:yes" || i.UserID != "u1" || i.MessageID != "m1" || i.ChatID != "c1" || len(i.Values) != 1 {
+		t.Errorf("interaction = %+v", i)
+	}
+}
@@ -0,0 +1,19 @@
+package channels
+
+import "context"
+
+// messageKey is the context key for the message being processed.
+type messageKey struct{}
+
+// WithMessage returns a context carrying the incoming message being processed.
+func WithMessage(ctx context.Context, msg IncomingMessage) context.Context {
+	return context.WithValue(ctx, messageKey{}, msg)
+}
+
+// MessageFromContext returns the incoming message being processed, if any.
+// The router attaches it before invoking handlers, so agents and tools can
+// discover the conversation they are serving.
+func MessageFromContext(ctx context.Context) (IncomingMessage, bool) {
+	msg, ok := ctx.Value(messageKey{}).(IncomingMessage)
+	return msg, ok
+}
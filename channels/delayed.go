@@ -0,0 +1,96 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// scheduler runs fn after delay in the chat of the message being handled.
+type scheduler func(delay time.Duration, fn func(ctx context.Context))
+
+type schedulerKey struct{}
+
+// withScheduler returns a context in which handlers schedule delayed work
+// with s.
+func withScheduler(ctx context.Context, s scheduler) context.Context {
+	return context.WithValue(ctx, schedulerKey{}, s)
+}
+
+// schedulerFromContext returns the router's scheduler, if any.
+func schedulerFromContext(ctx context.Context) (scheduler, bool) {
+	s, ok := ctx.Value(schedulerKey{}).(scheduler)
+	return s, ok
+}
+
+// delayedSet holds the work handlers scheduled that has not run yet.
+type delayedSet struct {
+	mu      sync.Mutex
+	pending map[*time.Timer]func()
+}
+
+// scheduler returns the scheduler for the handlers of msg. Delayed work
+// runs through the chat's queue, in order with its messages, and is run
+// early by Shutdown rather than lost.
+func (r *Router) scheduler(ctx context.Context, msg IncomingMessage) scheduler {
+	// Delayed work outlives the handler that scheduled it
+	ctx = context.WithoutCancel(ctx)
+	return func(delay time.Duration, fn func(ctx context.Context)) {
+		run := func() { r.submit(ctx, chatKey(msg), fn) }
+
+		r.delayed.mu.Lock()
+		// Checked under the lock so work is not scheduled after Shutdown
+		// ran the pending work
+		if r.lifecycle.closing.Load() {
+			r.delayed.mu.Unlock()
+			run()
+			return
+		}
+		defer r.delayed.mu.Unlock()
+		var timer *time.Timer
+		timer = time.AfterFunc(delay, func() {
+			r.delayed.mu.Lock()
+			_, ok := r.delayed.pending[timer]
+			delete(r.delayed.pending, timer)
+			r.delayed.mu.Unlock()
+			// Shutdown already ran it
+			if ok {
+				run()
+			}
+		})
+		r.delayed.pending[timer] = run
+	}
+}
+
+// submit runs fn in the chat with key, through the dispatcher if there is
+// one. fn is canceled if Shutdown gives up waiting for it.
+func (r *Router) submit(ctx context.Context, key string, fn func(ctx context.Context)) {
+	work := func() {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(r.lifecycle.aborted, cancel)
+		defer stop()
+		fn(ctx)
+	}
+	if r.dispatcher == nil {
+		work()
+		return
+	}
+	r.dispatcher.enqueue(key, work)
+}
+
+// runDelayed runs all scheduled work now.
+func (r *Router) runDelayed() {
+	r.delayed.mu.Lock()
+	var runs []func()
+	for timer, run := range r.delayed.pending {
+		if timer.Stop() {
+			runs = append(runs, run)
+		}
+		delete(r.delayed.pending, timer)
+	}
+	r.delayed.mu.Unlock()
+	for _, run := range runs {
+		run()
+	}
+}
@@ -0,0 +1,126 @@
+package channels
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultWorkers is the default number of chats processed concurrently.
+const DefaultWorkers = 16
+
+// chatQueue holds pending work for one chat.
+type chatQueue struct {
+	pending []func()
+}
+
+// dispatcher processes work in order per key (chat) and concurrently
+// across keys, with at most workers items running at once.
+type dispatcher struct {
+	sem    chan struct{}
+	queues map[string]*chatQueue
+	depth  int
+	idle   *sync.Cond
+	mu     sync.Mutex
+}
+
+func newDispatcher(workers int) *dispatcher {
+	d := &dispatcher{
+		sem:    make(chan struct{}, workers),
+		queues: make(map[string]*chatQueue),
+	}
+	d.idle = sync.NewCond(&d.mu)
+	return d
+}
+
+// enqueue schedules fn after all previously enqueued work for key.
+func (d *dispatcher) enqueue(key string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.depth++
+	if q, ok := d.queues[key]; ok {
+		q.pending = append(q.pending, fn)
+		return
+	}
+	q := &chatQueue{pending: []func(){fn}}
+	d.queues[key] = q
+	go d.run(key, q)
+}
+
+// run drains a chat's queue, taking a worker slot per item so that busy
+// chats do not starve others.
+func (d *dispatcher) run(key string, q *chatQueue) {
+	for {
+		d.mu.Lock()
+		if len(q.pending) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		fn := q.pending[0]
+		q.pending = q.pending[1:]
+		d.mu.Unlock()
+
+		d.sem <- struct{}{}
+		fn()
+		<-d.sem
+
+		d.mu.Lock()
+		d.depth--
+		if d.depth == 0 {
+			d.idle.Broadcast()
+		}
+		d.mu.Unlock()
+	}
+}
+
+// queueDepth returns the number of queued and running items.
+func (d *dispatcher) queueDepth() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.depth
+}
+
+// drain waits until no work is queued or running, or ctx is done.
+func (d *dispatcher) drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.mu.Lock()
+		for d.depth > 0 && ctx.Err() == nil {
+			d.idle.Wait()
+		}
+		d.mu.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// Wake the waiter so it observes ctx
+		d.mu.Lock()
+		d.idle.Broadcast()
+		d.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Drain waits until all queued messages have been processed and all queued
+// outgoing messages delivered. Messages that arrive while draining are
+// waited for too, so disconnect channels first when shutting down.
+func (r *Router) Drain(ctx context.Context) error {
+	if r.dispatcher != nil {
+		if err := r.dispatcher.drain(ctx); err != nil {
+			return err
+		}
+	}
+	if r.outbox != nil {
+		return r.outbox.queue.drain(ctx)
+	}
+	return nil
+}
+
+// chatKey identifies the chat a message belongs to for ordering.
+func chatKey(msg IncomingMessage) string {
+	return msg.ChannelName + "\x00" + msg.ChatID
+}
@@ -0,0 +1,95 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchOrderedPerChat(t *testing.T) {
+	router := NewRouter(nil, WithWorkers(4))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		// Earlier messages take longer, so reordering would show
+		if msg.ID == "1" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		got[msg.ChatID] = append(got[msg.ChatID], msg.ID)
+		mu.Unlock()
+		return nil
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		for _, chat := range []string{"a", "b"} {
+			if err := ch.deliver(IncomingMessage{ID: chat + id, ChannelName: "test", ChatID: chat}); err != nil {
+				t.Fatalf("deliver failed: %v", err)
+			}
+		}
+	}
+	if err := router.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	for _, chat := range []string{"a", "b"} {
+		want := []string{chat + "1", chat + "2", chat + "3"}
+		if len(got[chat]) != 3 || got[chat][0] != want[0] || got[chat][1] != want[1] || got[chat][2] != want[2] {
+			t.Errorf("chat %s order = %v, want %v", chat, got[chat], want)
+		}
+	}
+}
+
+func TestDispatchConcurrentAcrossChats(t *testing.T) {
+	router := NewRouter(nil, WithWorkers(2))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	release := make(chan struct{})
+	handled := make(chan string, 2)
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		if msg.ChatID == "slow" {
+			<-release
+		}
+		handled <- msg.ChatID
+		return nil
+	})
+
+	// The adapter callback must not block on a slow handler
+	_ = ch.deliver(IncomingMessage{ID: "1", ChannelName: "test", ChatID: "slow"})
+	_ = ch.deliver(IncomingMessage{ID: "2", ChannelName: "test", ChatID: "fast"})
+
+	select {
+	case chat := <-handled:
+		if chat != "fast" {
+			t.Errorf("first handled = %s, want fast", chat)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("fast chat was blocked by slow chat")
+	}
+	close(release)
+
+	if err := router.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+}
+
+func TestDispatchSynchronous(t *testing.T) {
+	router := NewRouter(nil, WithWorkers(0))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	handled := false
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		handled = true
+		return nil
+	})
+	_ = ch.deliver(IncomingMessage{ID: "1", ChannelName: "test"})
+	if !handled {
+		t.Error("message should be handled before deliver returns")
+	}
+}
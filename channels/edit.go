@@ -0,0 +1,169 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrEditUnsupported is returned by EditMessage and DeleteMessage for
+// channels that do not implement MessageEditor.
+var ErrEditUnsupported = errors.New("channel does not support editing messages")
+
+// DefaultEditInterval is the default minimum time between edits of a
+// streamed message, within the edit rate limits of Discord and Telegram.
+const DefaultEditInterval = time.Second
+
+// SendMessage sends a message like SendWithReceipt and returns the
+// receipt's MessageID for EditMessage and DeleteMessage.
+func (r *Router) SendMessage(ctx context.Context, channelName, chatID string, msg OutgoingMessage) (string, error) {
+	receipt, err := r.SendWithReceipt(ctx, channelName, chatID, msg)
+	return receipt.MessageID, err
+}
+
+// EditMessage replaces the content of a message sent with
+// SendWithReceipt, rendered for the platform like sent messages. The
+// content must fit the platform's length limit.
+func (r *Router) EditMessage(ctx context.Context, channelName, chatID, messageID string, msg OutgoingMessage) error {
+	editor, err := r.editor(channelName)
+	if err != nil {
+		return err
+	}
+	if msg, err = renderOutgoing(editor, msg); err != nil {
+		return err
+	}
+	if err := editor.EditMessage(ctx, chatID, messageID, msg); err != nil {
+		return fmt.Errorf("edit message: %w", err)
+	}
+	return nil
+}
+
+// DeleteMessage deletes a message sent with SendWithReceipt.
+func (r *Router) DeleteMessage(ctx context.Context, channelName, chatID, messageID string) error {
+	editor, err := r.editor(channelName)
+	if err != nil {
+		return err
+	}
+	if err := editor.DeleteMessage(ctx, chatID, messageID); err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+	return nil
+}
+
+// editor returns the named channel as a MessageEditor.
+func (r *Router) editor(channelName string) (MessageEditor, error) {
+	channel, ok := r.GetChannel(channelName)
+	if !ok {
+		return nil, errChannelNotFound(channelName)
+	}
+	editor, ok := channel.(MessageEditor)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", channelName, ErrEditUnsupported)
+	}
+	return editor, nil
+}
+
+// StreamEdits streams chunks into a chat by sending a message with the
+// first chunk and editing it as further chunks arrive, at most once per
+// interval. Text beyond the platform's length limit continues in a new
+// message. Adapters implement StreamingChannel.SendStream with it.
+func StreamEdits(ctx context.Context, editor MessageEditor, chatID string, chunks <-chan string, interval time.Duration) error {
+	return StreamEditsWith(ctx, editor, chatID, chunks, StreamConfig{Interval: interval})
+}
+
+// StreamConfig configures StreamEditsWith.
+type StreamConfig struct {
+	// Interval is the minimum time between edits. Zero edits on every
+	// chunk.
+	Interval time.Duration
+
+	// Placeholder is sent before the first chunk arrives, e.g. "…", and
+	// deleted if the stream ends without text.
+	Placeholder string
+}
+
+// StreamEditsWith streams chunks like StreamEdits. Rate-limited edits are
+// skipped until the platform's retry delay has passed, and the last edit of
+// each message waits it out, so the complete text is always shown.
+func StreamEditsWith(ctx context.Context, editor MessageEditor, chatID string, chunks <-chan string, config StreamConfig) error {
+	caps := capabilities(editor)
+	limit := caps.MaxMessageLength
+
+	var id, content, shown string
+	var next time.Time
+	send := func(msg OutgoingMessage) error {
+		if id != "" {
+			return editor.EditMessage(ctx, chatID, id, msg)
+		}
+		receipt, err := editor.SendWithReceipt(ctx, chatID, msg)
+		if err == nil {
+			id = receipt.MessageID
+		}
+		return err
+	}
+
+	// update shows the text received so far
+	update := func() error {
+		if content == shown || strings.TrimSpace(content) == "" || time.Now().Before(next) {
+			return nil
+		}
+		err := send(OutgoingMessage{Content: content})
+		if delay, ok := RetryAfter(err); ok {
+			next = time.Now().Add(delay)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		shown, next = content, time.Now().Add(config.Interval)
+		return nil
+	}
+
+	// finish completes the current message
+	finish := func() error {
+		if strings.TrimSpace(content) == "" {
+			if id == "" {
+				return nil
+			}
+			return editor.DeleteMessage(ctx, chatID, id)
+		}
+		if content == shown {
+			return nil
+		}
+		for {
+			err := send(OutgoingMessage{Content: content})
+			if err == nil || !waitRetryAfter(ctx, err) {
+				return err
+			}
+		}
+	}
+
+	if config.Placeholder != "" {
+		if err := send(OutgoingMessage{Content: config.Placeholder}); err != nil {
+			return err
+		}
+		shown, next = config.Placeholder, time.Now().Add(config.Interval)
+	}
+
+	for chunk := range chunks {
+		content += chunk
+		if limit > 0 && caps.messageLength(content) > limit {
+			// Complete the current message, continue in a new one
+			parts := splitMessage(content, limit, caps.messageLength, nil)
+			for _, part := range parts[:len(parts)-1] {
+				content = part
+				if err := finish(); err != nil {
+					return err
+				}
+				id, shown = "", ""
+			}
+			content = parts[len(parts)-1]
+		}
+		if err := update(); err != nil {
+			return err
+		}
+	}
+	return finish()
+}
@@ -0,0 +1,145 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// editorChannel is a mock channel keeping sent messages by ID.
+type editorChannel struct {
+	*mockChannel
+	limit    int
+	messages map[string]string
+	edits    int
+
+	// floods is how many edits fail as rate limited
+	floods int
+}
+
+func newEditorChannel(name string, limit int) *editorChannel {
+	return &editorChannel{mockChannel: newMockChannel(name), limit: limit, messages: map[string]string{}}
+}
+
+func (c *editorChannel) Capabilities() Capabilities {
+	return Capabilities{MaxMessageLength: c.limit}
+}
+
+func (c *editorChannel) SendWithReceipt(ctx context.Context, chatID string, msg OutgoingMessage) (MessageReceipt, error) {
+	id := fmt.Sprintf("m%d", len(c.messages)+1)
+	c.messages[id] = msg.Content
+	return MessageReceipt{ChannelName: c.name, ChatID: chatID, MessageID: id, Timestamp: time.Now()}, nil
+}
+
+func (c *editorChannel) EditMessage(ctx context.Context, chatID, messageID string, msg OutgoingMessage) error {
+	if _, ok := c.messages[messageID]; !ok {
+		return errors.New("unknown message")
+	}
+	if c.floods > 0 {
+		c.floods--
+		return &RateLimitedError{Channel: c.name, RetryAfter: 20 * time.Millisecond, Err: errors.New("flood")}
+	}
+	c.messages[messageID] = msg.Content
+	c.edits++
+	return nil
+}
+
+func (c *editorChannel) DeleteMessage(ctx context.Context, chatID, messageID string) error {
+	delete(c.messages, messageID)
+	return nil
+}
+
+func TestRouterEditMessage(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newEditorChannel("discord", 0)
+	router.Register(ch)
+	router.Register(newMockChannel("plain"))
+	ctx := context.Background()
+
+	receipt, err := router.SendWithReceipt(ctx, "discord", "c1", OutgoingMessage{Content: "Thinking…"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := receipt.MessageID
+	if err := router.EditMessage(ctx, "discord", "c1", id, OutgoingMessage{Content: "**Done**", Format: MessageFormatMarkdown}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ch.messages[id]; got != "**Done**" {
+		t.Errorf("edited = %q", got)
+	}
+	if err := router.DeleteMessage(ctx, "discord", "c1", id); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.messages) != 0 {
+		t.Errorf("messages = %v, want none", ch.messages)
+	}
+
+	if id, err := router.SendMessage(ctx, "discord", "c1", OutgoingMessage{Content: "hi"}); err != nil || id != "m1" {
+		t.Errorf("SendMessage(discord) = %q, %v; want m1", id, err)
+	}
+	if id, err := router.SendMessage(ctx, "plain", "c1", OutgoingMessage{Content: "hi"}); err != nil || id != "" {
+		t.Errorf("SendMessage(plain) = %q, %v", id, err)
+	}
+	if err := router.EditMessage(ctx, "plain", "c1", "1", OutgoingMessage{}); !errors.Is(err, ErrEditUnsupported) {
+		t.Errorf("EditMessage(plain) = %v, want ErrEditUnsupported", err)
+	}
+}
+
+func TestStreamEdits(t *testing.T) {
+	ch := newEditorChannel("discord", 40)
+	chunks := make(chan string)
+	go func() {
+		defer close(chunks)
+		for _, c := range []string{"First sentence. ", "Second sentence. ", "Third sentence. ", "Fourth."} {
+			chunks <- c
+		}
+	}()
+
+	if err := StreamEdits(context.Background(), ch, "c1", chunks, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.messages) != 2 || ch.edits == 0 {
+		t.Fatalf("messages = %q after %d edits, want 2 with edits", ch.messages, ch.edits)
+	}
+	got := ch.messages["m1"] + " " + ch.messages["m2"]
+	if strings.Join(strings.Fields(got), " ") != "First sentence. Second sentence. Third sentence. Fourth." {
+		t.Errorf("streamed = %q", ch.messages)
+	}
+	for id, content := range ch.messages {
+		if len(content) > 40 {
+			t.Errorf("%s has %d characters, want at most 40", id, len(content))
+		}
+	}
+}
+
+func TestStreamEditsWith(t *testing.T) {
+	stream := func(parts ...string) <-chan string {
+		chunks := make(chan string, len(parts))
+		for _, p := range parts {
+			chunks <- p
+		}
+		close(chunks)
+		return chunks
+	}
+	config := StreamConfig{Placeholder: "…"}
+
+	ch := newEditorChannel("telegram", 0)
+	ch.floods = 2
+	if err := StreamEditsWith(context.Background(), ch, "c1", stream("Hello ", "there, ", "world"), config); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.messages) != 1 || ch.messages["m1"] != "Hello there, world" {
+		t.Errorf("messages = %q, want the placeholder edited to the full text", ch.messages)
+	}
+
+	ch = newEditorChannel("telegram", 0)
+	if err := StreamEditsWith(context.Background(), ch, "c1", stream(" "), config); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.messages) != 0 {
+		t.Errorf("messages = %q, want the placeholder deleted", ch.messages)
+	}
+}
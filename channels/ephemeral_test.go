@@ -0,0 +1,46 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// mockDMChannel records the chat each message was sent to.
+type mockDMChannel struct {
+	*mockChannel
+	chats []string
+}
+
+func (m *mockDMChannel) Send(ctx context.Context, chatID string, msg OutgoingMessage) error {
+	m.chats = append(m.chats, chatID)
+	return m.mockChannel.Send(ctx, chatID, msg)
+}
+
+func (m *mockDMChannel) DirectChatID(ctx context.Context, userID string) (string, error) {
+	return "dm-" + userID, nil
+}
+
+func TestEphemeralFallsBackToDM(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &mockDMChannel{mockChannel: newMockChannel("dm")}
+	router.Register(ch)
+	router.Register(newMockChannel("plain"))
+
+	ctx := context.Background()
+	msg := IncomingMessage{ID: "m1", ChannelName: "dm", ChatID: "group", SenderID: "alice"}
+	if err := router.Send(ctx, "dm", "group", Whisper(msg, "your balance is $5")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(ch.chats) != 1 || ch.chats[0] != "dm-alice" {
+		t.Errorf("chats = %v, want [dm-alice]", ch.chats)
+	}
+	if sent := ch.sentMessages(); len(sent) != 1 || sent[0].ReplyTo != "" {
+		t.Errorf("sent = %+v, want DM without reply reference", sent)
+	}
+
+	err := router.Send(ctx, "plain", "group", Whisper(msg, "secret"))
+	if !errors.Is(err, ErrEphemeralUnsupported) {
+		t.Errorf("err = %v, want ErrEphemeralUnsupported", err)
+	}
+}
@@ -0,0 +1,177 @@
+package channels
+
+import (
+	"context"
+	"time"
+
+	"github.com/agentplexus/envoy/events"
+)
+
+// ErrorAction is what the router does with a handler error once any
+// retries are exhausted.
+type ErrorAction int
+
+const (
+	// ErrorActionLog logs the error and continues. This is the default.
+	ErrorActionLog ErrorAction = iota
+
+	// ErrorActionDrop discards the error silently.
+	ErrorActionDrop
+
+	// ErrorActionDeadLetter logs the error and passes the message to the
+	// router's dead letter handler.
+	ErrorActionDeadLetter
+)
+
+// ErrorPolicy controls how the router handles handler errors.
+type ErrorPolicy struct {
+	// Retry, if set, re-runs a failed handler before applying Action.
+	Retry *RetryPolicy
+
+	// Action applies once retries are exhausted.
+	Action ErrorAction
+}
+
+// RetryPolicy retries a failed handler with exponential backoff.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first
+	// (default: 3).
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry (default: 500ms).
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries (default: 30s).
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after each retry (default: 2).
+	Multiplier float64
+
+	// Retryable reports whether an error should be retried (default: all).
+	Retryable func(err error) bool
+}
+
+// attempts returns the total number of attempts.
+func (p *RetryPolicy) attempts() int {
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.MaxAttempts
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry).
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+	maxDelay := p.MaxBackoff
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+
+	d := float64(delay)
+	for i := 1; i < retry; i++ {
+		d *= multiplier
+		if d >= float64(maxDelay) {
+			return maxDelay
+		}
+	}
+	return time.Duration(d)
+}
+
+// retryable reports whether err should be retried.
+func (p *RetryPolicy) retryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// DeadLetter is a message whose handler failed.
+type DeadLetter struct {
+	Message  IncomingMessage
+	Pattern  RoutePattern
+	Err      error
+	Attempts int
+	Time     time.Time
+}
+
+// DeadLetterHandler receives messages whose handlers failed under
+// ErrorActionDeadLetter, for example to persist them for replay.
+type DeadLetterHandler func(ctx context.Context, dl DeadLetter) error
+
+// runHandler runs a route handler under its error policy.
+func (r *Router) runHandler(ctx context.Context, h RouteHandler, msg IncomingMessage) {
+	policy := r.options.errorPolicy
+	if h.ErrorPolicy != nil {
+		policy = *h.ErrorPolicy
+	}
+
+	attempts := 1
+	err := h.Handler(ctx, msg)
+	if err != nil && policy.Retry != nil {
+		for attempts < policy.Retry.attempts() && policy.Retry.retryable(err) {
+			r.log(ctx).Warn("handler error, retrying",
+				"attempt", attempts,
+				"error", err)
+
+			delay := policy.Retry.Backoff(attempts)
+			if retryAfter, ok := RetryAfter(err); ok && retryAfter > delay {
+				delay = retryAfter
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				err = ctx.Err()
+			case <-timer.C:
+				attempts++
+				err = h.Handler(ctx, msg)
+			}
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		return
+	}
+	r.countHandlerError(msg.ChannelName)
+	r.publish(events.HandlerFailed{
+		Channel:   msg.ChannelName,
+		ChatID:    msg.ChatID,
+		MessageID: msg.ID,
+		Attempts:  attempts,
+		Err:       err,
+		Time:      time.Now(),
+	})
+
+	switch policy.Action {
+	case ErrorActionDrop:
+		return
+	case ErrorActionDeadLetter:
+		r.log(ctx).Error("handler error, dead-lettering message",
+			"attempts", attempts,
+			"error", err)
+		if r.options.deadLetter == nil {
+			r.log(ctx).Warn("no dead letter handler configured, message dropped")
+			return
+		}
+		dl := DeadLetter{
+			Message:  msg,
+			Pattern:  h.Pattern,
+			Err:      err,
+			Attempts: attempts,
+			Time:     time.Now(),
+		}
+		if dlErr := r.options.deadLetter(context.WithoutCancel(ctx), dl); dlErr != nil {
+			r.log(ctx).Error("dead letter handler error", "error", dlErr)
+		}
+	default:
+		r.log(ctx).Error("handler error",
+			"attempts", attempts,
+			"error", err)
+	}
+}
@@ -0,0 +1,80 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestErrorPolicyRetry(t *testing.T) {
+	router := NewRouter(nil, WithErrorPolicy(ErrorPolicy{
+		Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	calls := 0
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ChannelName: "test"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestErrorPolicyDeadLetter(t *testing.T) {
+	var letters []DeadLetter
+	router := NewRouter(nil, WithDeadLetter(func(ctx context.Context, dl DeadLetter) error {
+		letters = append(letters, dl)
+		return nil
+	}))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	permanent := errors.New("permanent")
+	calls := 0
+	router.AddHandler(RouteHandler{
+		Pattern: All(),
+		Handler: func(ctx context.Context, msg IncomingMessage) error {
+			calls++
+			return permanent
+		},
+		ErrorPolicy: &ErrorPolicy{
+			Retry: &RetryPolicy{
+				MaxAttempts:    5,
+				InitialBackoff: time.Millisecond,
+				Retryable:      func(err error) bool { return !errors.Is(err, permanent) },
+			},
+			Action: ErrorActionDeadLetter,
+		},
+	})
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "m1", ChannelName: "test"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (non-retryable)", calls)
+	}
+	if len(letters) != 1 || letters[0].Message.ID != "m1" || !errors.Is(letters[0].Err, permanent) {
+		t.Fatalf("dead letters = %+v", letters)
+	}
+}
@@ -0,0 +1,59 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/events"
+)
+
+// failingAgent always fails.
+type failingAgent struct{}
+
+func (failingAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+func TestRouterPublishesEvents(t *testing.T) {
+	bus := events.New(events.Config{})
+	defer bus.Close()
+	got := make(chan events.Event, 10)
+	bus.Subscribe(func(e events.Event) { got <- e })
+
+	router := NewRouter(nil, WithEvents(bus))
+	ch := newMockChannel("test")
+	router.Register(ch)
+	router.SetAgent(failingAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	if err := router.ConnectAll(context.Background()); err != nil {
+		t.Fatalf("ConnectAll: %v", err)
+	}
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "m1", ChannelName: "test", ChatID: "42"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	want := []events.Type{events.TypeChannelConnected, events.TypeAgentFailed, events.TypeHandlerFailed, events.TypeMessageRouted}
+	for i, typ := range want {
+		select {
+		case e := <-got:
+			if e.EventType() != typ {
+				t.Fatalf("event %d = %s, want %s", i, e.EventType(), typ)
+			}
+			switch e := e.(type) {
+			case events.AgentFailed:
+				if e.Agent != DefaultAgentName || e.SessionID != "test:42" || e.MessageID != "m1" || e.Err == nil {
+					t.Errorf("agent failure = %+v", e)
+				}
+			case events.MessageRouted:
+				if e.Handlers != 1 || e.ChatID != "42" {
+					t.Errorf("routed = %+v", e)
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", typ)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package channels
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyWindow is how long the router remembers idempotency keys.
+const DefaultIdempotencyWindow = 10 * time.Minute
+
+// DefaultDedupWindow is how long the router remembers incoming message IDs.
+const DefaultDedupWindow = 10 * time.Minute
+
+// IdempotencyCache remembers recently used idempotency keys for a window so
+// repeated sends of the same message can be dropped.
+type IdempotencyCache struct {
+	window    time.Duration
+	seen      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// NewIdempotencyCache creates a cache that remembers keys for window.
+func NewIdempotencyCache(window time.Duration) *IdempotencyCache {
+	if window <= 0 {
+		window = DefaultIdempotencyWindow
+	}
+	return &IdempotencyCache{
+		window: window,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Reserve records key and reports whether it was not already seen within
+// the window.
+func (c *IdempotencyCache) Reserve(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastSweep) > c.window {
+		for k, t := range c.seen {
+			if now.Sub(t) > c.window {
+				delete(c.seen, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if t, ok := c.seen[key]; ok && now.Sub(t) <= c.window {
+		return false
+	}
+	c.seen[key] = now
+	return true
+}
+
+// Release forgets key so a failed send can be retried.
+func (c *IdempotencyCache) Release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, key)
+}
+
+// idempotencyKey scopes a message's idempotency key to its destination.
+func idempotencyKey(channelName, chatID string, msg OutgoingMessage) string {
+	return channelName + "\x00" + chatID + "\x00" + msg.IdempotencyKey
+}
+
+// dedupKey identifies an incoming message for deduplication. Message IDs
+// are only unique per chat on some platforms, so the chat is part of the key.
+func dedupKey(msg IncomingMessage) string {
+	return msg.ChannelName + "\x00" + msg.ChatID + "\x00" + msg.ID
+}
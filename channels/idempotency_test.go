@@ -0,0 +1,127 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyCacheWindow(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewIdempotencyCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	if !cache.Reserve("k") {
+		t.Fatal("first reserve should succeed")
+	}
+	if cache.Reserve("k") {
+		t.Error("duplicate reserve within window should fail")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if !cache.Reserve("k") {
+		t.Error("reserve after window should succeed")
+	}
+
+	cache.Release("k")
+	if !cache.Reserve("k") {
+		t.Error("reserve after release should succeed")
+	}
+}
+
+// failingChannel fails every Send.
+type failingChannel struct{ *mockChannel }
+
+func (f failingChannel) Send(context.Context, string, OutgoingMessage) error {
+	return errors.New("send failed")
+}
+
+func TestRouterSendIdempotency(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	ctx := context.Background()
+	msg := OutgoingMessage{Content: "deploy finished", IdempotencyKey: "deploy-42"}
+	for i := 0; i < 3; i++ {
+		if err := router.Send(ctx, "test", "c1", msg); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if err := router.Send(ctx, "test", "c2", msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := router.Send(ctx, "test", "c1", OutgoingMessage{Content: "no key"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if got := len(ch.sentMessages()); got != 3 {
+		t.Errorf("sent %d messages, want 3", got)
+	}
+}
+
+func TestRouterSendIdempotencyRetryAfterFailure(t *testing.T) {
+	router := NewRouter(nil)
+	router.Register(failingChannel{newMockChannel("test")})
+
+	msg := OutgoingMessage{Content: "hi", IdempotencyKey: "k"}
+	for i := 0; i < 2; i++ {
+		if err := router.Send(context.Background(), "test", "c1", msg); err == nil {
+			t.Fatalf("attempt %d: expected send error, not a silent drop", i+1)
+		}
+	}
+}
+
+func TestRouterDeduplicatesIncoming(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []RouterOption
+		want int
+	}{
+		{"default", nil, 2},
+		{"disabled", []RouterOption{WithDedupWindow(0)}, 4},
+	}
+	for _, tt := range tests {
+		router := NewRouter(nil, tt.opts...)
+		ch := newMockChannel("test")
+		router.Register(ch)
+
+		handled := 0
+		router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+			handled++
+			return nil
+		})
+
+		for _, id := range []string{"1", "1", "2", "2"} {
+			if err := deliverAndWait(router, ch, IncomingMessage{ID: id, ChannelName: "test"}); err != nil {
+				t.Fatalf("deliver failed: %v", err)
+			}
+		}
+		if handled != tt.want {
+			t.Errorf("%s: handled = %d, want %d", tt.name, handled, tt.want)
+		}
+	}
+}
+
+func TestRouterDedupScopedToChat(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	handled := 0
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		handled++
+		return nil
+	})
+
+	for _, chatID := range []string{"c1", "c2", "c1"} {
+		msg := IncomingMessage{ID: "1", ChatID: chatID, ChannelName: "test"}
+		if err := deliverAndWait(router, ch, msg); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+	if handled != 2 {
+		t.Errorf("handled = %d, want 2", handled)
+	}
+}
@@ -0,0 +1,68 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+)
+
+// Image is an image passed to an agent.
+type Image struct {
+	// Data is the encoded image; empty if the agent should fetch URL.
+	Data []byte
+
+	// MimeType is the MIME type of Data.
+	MimeType string
+
+	// URL is where the image can be fetched.
+	URL string
+}
+
+// MultimodalAgentProcessor is an AgentProcessor that understands images.
+// The router calls ProcessMultimodal instead of Process for messages with
+// image attachments, without streaming.
+type MultimodalAgentProcessor interface {
+	AgentProcessor
+
+	// ProcessMultimodal processes a message with its images.
+	ProcessMultimodal(ctx context.Context, sessionID, content string, images []Image) (string, error)
+}
+
+// ImageLoader loads an image attachment of a message received on the
+// named channel for an agent.
+type ImageLoader func(ctx context.Context, channelName string, m Media) (Image, error)
+
+// SetImageLoader sets how image attachments are loaded for agents
+// implementing MultimodalAgentProcessor, e.g. to download platform files
+// and downscale them. By default, images with data or a URL are passed as
+// is and others are skipped.
+func (r *Router) SetImageLoader(loader ImageLoader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.images = loader
+}
+
+// loadImages loads the image attachments of msg.
+func (r *Router) loadImages(ctx context.Context, msg IncomingMessage) ([]Image, error) {
+	r.mu.RLock()
+	loader := r.images
+	r.mu.RUnlock()
+
+	var images []Image
+	for _, m := range msg.Media {
+		if m.Type != MediaTypeImage {
+			continue
+		}
+		if loader == nil {
+			if len(m.Data) > 0 || m.URL != "" {
+				images = append(images, Image{Data: m.Data, MimeType: m.MimeType, URL: m.URL})
+			}
+			continue
+		}
+		image, err := loader(ctx, msg.ChannelName, m)
+		if err != nil {
+			return nil, fmt.Errorf("load image: %w", err)
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
@@ -0,0 +1,86 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// visionAgent describes the images it is given.
+type visionAgent struct {
+	mockStreamingAgent
+	images []Image
+}
+
+func (a *visionAgent) ProcessMultimodal(ctx context.Context, sessionID, content string, images []Image) (string, error) {
+	a.images = images
+	return "seen: " + content, nil
+}
+
+func TestMultimodalAgent(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &mockStreamingChannel{mockChannel: newMockChannel("test")}
+	router.Register(ch)
+	agent := &visionAgent{}
+	router.SetAgent(agent)
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	photo := IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", Content: "what is this", Media: []Media{
+		{Type: MediaTypeImage, Data: []byte("png"), MimeType: "image/png"},
+		{Type: MediaTypeImage, FileID: "only-on-platform"},
+		{Type: MediaTypeVoice, Data: []byte("ogg")},
+	}}
+	if err := deliverAndWait(router, ch, photo); err != nil {
+		t.Fatal(err)
+	}
+	if sent := ch.sentMessages(); len(sent) != 1 || sent[0].Content != "seen: what is this" || len(ch.chunks) != 0 {
+		t.Errorf("sent = %+v, streamed %q, want one unstreamed reply", sent, ch.chunks)
+	}
+	if len(agent.images) != 1 || string(agent.images[0].Data) != "png" {
+		t.Errorf("images = %+v", agent.images)
+	}
+
+	// Without images, the agent streams as usual
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "2", ChannelName: "test", ChatID: "c1", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.chunks) == 0 {
+		t.Error("text message was not streamed")
+	}
+}
+
+func TestImageLoader(t *testing.T) {
+	var loaded []string
+	failing := false
+	router := NewRouter(nil, WithWorkers(0))
+	router.SetImageLoader(func(ctx context.Context, channelName string, m Media) (Image, error) {
+		if failing {
+			return Image{}, errors.New("download failed")
+		}
+		loaded = append(loaded, channelName+"/"+m.FileID)
+		return Image{Data: []byte(m.FileID), MimeType: "image/jpeg"}, nil
+	})
+	ch := newMockChannel("telegram")
+	router.Register(ch)
+	agent := &visionAgent{}
+	router.SetAgent(agent)
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	msg := IncomingMessage{ID: "1", ChannelName: "telegram", ChatID: "c1", Media: []Media{{Type: MediaTypeImage, FileID: "f1"}}}
+	if err := deliverAndWait(router, ch, msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || loaded[0] != "telegram/f1" || len(agent.images) != 1 || string(agent.images[0].Data) != "f1" {
+		t.Errorf("loaded %v, images %+v", loaded, agent.images)
+	}
+
+	failing = true
+	agent.images = nil
+	msg.ID = "2"
+	if err := deliverAndWait(router, ch, msg); err != nil {
+		t.Fatal(err)
+	}
+	if agent.images != nil || len(ch.sentMessages()) != 1 {
+		t.Errorf("message processed despite failed image load: %+v", ch.sentMessages())
+	}
+}
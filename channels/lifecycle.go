@@ -0,0 +1,511 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long Shutdown waits for in-flight messages.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ErrShuttingDown is returned for messages received after Shutdown began.
+var ErrShuttingDown = errors.New("router is shutting down")
+
+// ConnectResult is the outcome of connecting or disconnecting one channel.
+type ConnectResult struct {
+	Channel  string
+	Err      error
+	Duration time.Duration
+}
+
+// ConnectError reports the channels that failed to connect or disconnect.
+// Channels not listed succeeded.
+type ConnectError struct {
+	Failed []ConnectResult
+}
+
+func (e *ConnectError) Error() string {
+	parts := make([]string, len(e.Failed))
+	for i, r := range e.Failed {
+		parts[i] = fmt.Sprintf("%s: %v", r.Channel, r.Err)
+	}
+	return fmt.Sprintf("%d channel(s) failed: %s", len(e.Failed), strings.Join(parts, "; "))
+}
+
+// Unwrap returns the individual channel errors.
+func (e *ConnectError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, r := range e.Failed {
+		errs[i] = r.Err
+	}
+	return errs
+}
+
+// lifecycleState tracks per-channel connection state.
+type lifecycleState struct {
+	mu        sync.Mutex
+	locks     map[string]channelLock
+	connected map[string]bool
+	retries   map[string]context.CancelFunc
+	wg        sync.WaitGroup
+
+	// ctx is the connection context created by Start, and cancel ends it.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// stopSupervisor stops the health check supervisor and waits for it.
+	stopSupervisor func()
+
+	// closing is set once Shutdown begins; new messages are rejected.
+	closing atomic.Bool
+
+	// aborted is canceled when Shutdown gives up waiting, canceling
+	// in-flight message processing.
+	aborted context.Context
+	abort   context.CancelFunc
+}
+
+func newLifecycleState() *lifecycleState {
+	aborted, abort := context.WithCancel(context.Background())
+	return &lifecycleState{
+		locks:     make(map[string]channelLock),
+		connected: make(map[string]bool),
+		retries:   make(map[string]context.CancelFunc),
+		aborted:   aborted,
+		abort:     abort,
+	}
+}
+
+// channelLock serializes Connect and Disconnect for a channel. Unlike a
+// sync.Mutex, waiting for it can be abandoned, since a hung Connect may
+// hold it indefinitely.
+type channelLock chan struct{}
+
+// lock acquires the lock, or returns ctx's error if ctx is done first.
+func (l channelLock) lock(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l channelLock) unlock() {
+	<-l
+}
+
+// lock returns the lock serializing Connect/Disconnect for a channel.
+func (s *lifecycleState) lock(name string) channelLock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.locks[name]
+	if !ok {
+		l = make(channelLock, 1)
+		s.locks[name] = l
+	}
+	return l
+}
+
+func (s *lifecycleState) setConnected(name string, connected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if connected {
+		s.connected[name] = true
+	} else {
+		delete(s.connected, name)
+	}
+}
+
+func (s *lifecycleState) isConnected(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected[name]
+}
+
+// stopRetry cancels background reconnect attempts for a channel.
+func (s *lifecycleState) stopRetry(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.retries[name]; ok {
+		cancel()
+		delete(s.retries, name)
+	}
+}
+
+// finishRetry removes a finished retry loop unless it has been replaced.
+func (s *lifecycleState) finishRetry(name string, stop context.Context, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stop.Err() == nil {
+		delete(s.retries, name)
+	}
+	cancel()
+}
+
+// stopAllRetries cancels all background reconnect attempts.
+func (s *lifecycleState) stopAllRetries() {
+	s.mu.Lock()
+	for name, cancel := range s.retries {
+		cancel()
+		delete(s.retries, name)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// snapshot returns the registered channels without holding the router lock
+// during I/O.
+func (r *Router) snapshot() []Channel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	channels := make([]Channel, 0, len(r.channels))
+	for _, ch := range r.channels {
+		channels = append(channels, ch)
+	}
+	return channels
+}
+
+// ConnectAll connects all registered channels concurrently. Channels that
+// connect stay connected even if others fail; failed channels are retried
+// in the background until they connect, are unregistered, or DisconnectAll
+// is called. The returned error is a *ConnectError listing the failures.
+func (r *Router) ConnectAll(ctx context.Context) error {
+	results := r.ConnectChannels(ctx)
+	return resultsError(results)
+}
+
+// ConnectChannels connects all registered channels concurrently and returns
+// a result per channel. See ConnectAll.
+func (r *Router) ConnectChannels(ctx context.Context) []ConnectResult {
+	results := r.forEach(r.snapshot(), func(ch Channel) error {
+		return r.connect(ctx, nil, ch)
+	})
+	for _, res := range results {
+		if res.Err != nil {
+			r.logger.Error("failed to connect channel", "name", res.Channel, "error", res.Err)
+			r.retryConnect(ctx, r.channelByName(res.Channel))
+		} else {
+			r.logger.Info("channel connected", "name", res.Channel, "duration", res.Duration)
+		}
+	}
+	return results
+}
+
+// Start connects all channels so the router can be managed as a lifecycle
+// component. Unlike ConnectAll, channels are connected with a context that
+// outlives ctx, and connection failures are logged and retried in the
+// background rather than failing startup.
+func (r *Router) Start(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.lifecycle.mu.Lock()
+	if r.lifecycle.cancel != nil {
+		r.lifecycle.mu.Unlock()
+		cancel()
+		return fmt.Errorf("router already started")
+	}
+	r.lifecycle.ctx = runCtx
+	r.lifecycle.cancel = cancel
+	if r.options.healthInterval > 0 {
+		superviseCtx, stop := context.WithCancel(runCtx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.supervise(superviseCtx)
+		}()
+		r.lifecycle.stopSupervisor = func() {
+			stop()
+			<-done
+		}
+	}
+	r.lifecycle.mu.Unlock()
+
+	r.ConnectChannels(runCtx)
+	return ctx.Err()
+}
+
+// Stop disconnects all channels started by Start and waits for queued
+// messages to be processed.
+func (r *Router) Stop(ctx context.Context) error {
+	r.lifecycle.mu.Lock()
+	stopSupervisor := r.lifecycle.stopSupervisor
+	r.lifecycle.stopSupervisor = nil
+	r.lifecycle.mu.Unlock()
+	if stopSupervisor != nil {
+		stopSupervisor()
+	}
+
+	err := r.DisconnectAll(ctx)
+	if drainErr := r.Drain(ctx); drainErr != nil && err == nil {
+		err = drainErr
+	}
+
+	r.lifecycle.mu.Lock()
+	if r.lifecycle.cancel != nil {
+		r.lifecycle.cancel()
+		r.lifecycle.ctx = nil
+		r.lifecycle.cancel = nil
+	}
+	r.lifecycle.mu.Unlock()
+	return err
+}
+
+// Shutdown stops the router gracefully. New messages are rejected with
+// ErrShuttingDown; queued and in-flight messages, including agent calls and
+// work handlers scheduled for later such as Throttle's flushes, get until
+// the shutdown timeout (see WithShutdownTimeout) or ctx is done to finish,
+// after which their contexts are canceled. Channels are then disconnected.
+// A router cannot be restarted after Shutdown.
+func (r *Router) Shutdown(ctx context.Context) error {
+	r.lifecycle.closing.Store(true)
+	// Work handlers scheduled for later, e.g. coalesced messages, runs now
+	r.runDelayed()
+
+	drainCtx, cancel := context.WithTimeout(ctx, r.options.shutdownTimeout)
+	drainErr := r.Drain(drainCtx)
+	cancel()
+	if drainErr != nil {
+		r.logger.Warn("shutdown timeout reached, canceling in-flight messages", "error", drainErr)
+	}
+	r.lifecycle.abort()
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.options.disconnectTimeout)
+	defer cancel()
+	err := r.Stop(stopCtx)
+	if drainErr != nil {
+		return errors.Join(fmt.Errorf("drain: %w", drainErr), err)
+	}
+	return err
+}
+
+// Run connects all channels and blocks until ctx is done or the process
+// receives SIGINT or SIGTERM, then shuts the router down with Shutdown.
+func (r *Router) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := r.Start(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+	<-ctx.Done()
+	r.logger.Info("router shutting down")
+	return r.Shutdown(context.WithoutCancel(ctx))
+}
+
+// DisconnectAll stops background reconnect attempts and disconnects all
+// registered channels concurrently. The returned error is a *ConnectError
+// listing channels that failed to disconnect.
+func (r *Router) DisconnectAll(ctx context.Context) error {
+	r.lifecycle.stopAllRetries()
+
+	results := r.forEach(r.snapshot(), func(ch Channel) error {
+		return r.disconnect(ctx, ch)
+	})
+	for _, res := range results {
+		if res.Err != nil {
+			r.logger.Error("failed to disconnect channel", "name", res.Channel, "error", res.Err)
+		}
+	}
+	return resultsError(results)
+}
+
+// AddChannel registers a channel and, if the router was started with Start,
+// connects it. A channel that fails to connect stays registered and is
+// retried in the background. Other channels are unaffected.
+func (r *Router) AddChannel(ctx context.Context, ch Channel) error {
+	r.Register(ch)
+
+	r.lifecycle.mu.Lock()
+	runCtx := r.lifecycle.ctx
+	r.lifecycle.mu.Unlock()
+	if runCtx == nil {
+		return nil
+	}
+
+	// Adapters tie the connection's lifetime to the Connect context, so
+	// wait on ctx but connect with the router's run context.
+	if err := r.connect(runCtx, ctx.Done(), ch); err != nil {
+		r.logger.Error("failed to connect channel", "name", ch.Name(), "error", err)
+		r.retryConnect(runCtx, ch)
+		return err
+	}
+	r.logger.Info("channel connected", "name", ch.Name())
+	return nil
+}
+
+// RemoveChannel unregisters a channel and disconnects it if connected.
+func (r *Router) RemoveChannel(ctx context.Context, name string) error {
+	ch := r.channelByName(name)
+	if ch == nil {
+		return errChannelNotFound(name)
+	}
+	r.Unregister(name)
+	if !r.IsConnected(name) {
+		return nil
+	}
+	return r.disconnect(ctx, ch)
+}
+
+// IsConnected reports whether a channel is currently connected.
+func (r *Router) IsConnected(name string) bool {
+	return r.lifecycle.isConnected(name)
+}
+
+// forEach runs fn for each channel concurrently and collects the results in
+// channel order.
+func (r *Router) forEach(channels []Channel, fn func(Channel) error) []ConnectResult {
+	results := make([]ConnectResult, len(channels))
+	var wg sync.WaitGroup
+	for i, ch := range channels {
+		wg.Add(1)
+		go func(i int, ch Channel) {
+			defer wg.Done()
+			start := time.Now()
+			err := fn(ch)
+			results[i] = ConnectResult{Channel: ch.Name(), Err: err, Duration: time.Since(start)}
+		}(i, ch)
+	}
+	wg.Wait()
+	return results
+}
+
+// connect connects a single channel, waiting at most the configured
+// timeout or until abort is closed. ctx is passed to Connect unchanged since
+// adapters may tie the connection's lifetime to it; a Connect that outlives
+// the wait keeps running and marks the channel connected if it succeeds.
+func (r *Router) connect(ctx context.Context, abort <-chan struct{}, ch Channel) error {
+	name := ch.Name()
+	done := make(chan error, 1)
+	go func() {
+		l := r.lifecycle.lock(name)
+		if err := l.lock(ctx); err != nil {
+			done <- err
+			return
+		}
+		defer l.unlock()
+
+		if r.lifecycle.isConnected(name) {
+			done <- nil
+			return
+		}
+		err := ch.Connect(ctx)
+		if err == nil {
+			r.lifecycle.setConnected(name, true)
+			r.emitConnection(name, EventTypeChannelConnected, nil)
+		}
+		done <- err
+	}()
+
+	timer := time.NewTimer(r.options.connectTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("connect timed out after %s", r.options.connectTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-abort:
+		return context.Canceled
+	}
+}
+
+// disconnect disconnects a single channel with the configured timeout,
+// which includes waiting for a Connect in progress.
+func (r *Router) disconnect(ctx context.Context, ch Channel) error {
+	ctx, cancel := context.WithTimeout(ctx, r.options.disconnectTimeout)
+	defer cancel()
+	l := r.lifecycle.lock(ch.Name())
+	if err := l.lock(ctx); err != nil {
+		return fmt.Errorf("wait for connect: %w", err)
+	}
+	defer l.unlock()
+
+	err := ch.Disconnect(ctx)
+	if r.lifecycle.isConnected(ch.Name()) {
+		r.lifecycle.setConnected(ch.Name(), false)
+		r.emitConnection(ch.Name(), EventTypeChannelDisconnected, nil)
+	}
+	return err
+}
+
+// retryConnect reconnects a channel in the background with exponential
+// backoff until it succeeds or the retry is stopped. ctx is the connection
+// context passed to Connect.
+func (r *Router) retryConnect(ctx context.Context, ch Channel) {
+	if ch == nil {
+		return
+	}
+	name := ch.Name()
+
+	stop, cancel := context.WithCancel(ctx)
+	r.lifecycle.mu.Lock()
+	if prev, ok := r.lifecycle.retries[name]; ok {
+		prev()
+	}
+	r.lifecycle.retries[name] = cancel
+	r.lifecycle.wg.Add(1)
+	r.lifecycle.mu.Unlock()
+
+	go func() {
+		defer r.lifecycle.wg.Done()
+		defer r.lifecycle.finishRetry(name, stop, cancel)
+
+		delay := r.options.retryInitial
+		for attempt := 1; ; attempt++ {
+			timer := time.NewTimer(delay)
+			select {
+			case <-stop.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			err := r.connect(ctx, stop.Done(), ch)
+			if err == nil {
+				r.logger.Info("channel reconnected", "name", name, "attempt", attempt)
+				return
+			}
+			if stop.Err() != nil {
+				return
+			}
+			r.logger.Warn("channel reconnect failed",
+				"name", name,
+				"attempt", attempt,
+				"error", err)
+
+			delay *= 2
+			if delay > r.options.retryMax {
+				delay = r.options.retryMax
+			}
+		}
+	}()
+}
+
+// channelByName returns a registered channel or nil.
+func (r *Router) channelByName(name string) Channel {
+	ch, _ := r.GetChannel(name)
+	return ch
+}
+
+// resultsError builds a *ConnectError from failed results, or nil.
+func resultsError(results []ConnectResult) error {
+	var failed []ConnectResult
+	for _, res := range results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &ConnectError{Failed: failed}
+}
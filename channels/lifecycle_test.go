@@ -0,0 +1,238 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyChannel fails to connect a fixed number of times.
+type flakyChannel struct {
+	*mockChannel
+	failures int
+	block    chan struct{}
+
+	mu       sync.Mutex
+	attempts int
+}
+
+func (f *flakyChannel) Connect(ctx context.Context) error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errors.New("connect refused")
+	}
+	return nil
+}
+
+func TestConnectAllPartialFailure(t *testing.T) {
+	router := NewRouter(nil, WithConnectRetry(10*time.Millisecond, 20*time.Millisecond))
+	good := newMockChannel("good")
+	bad := &flakyChannel{mockChannel: newMockChannel("bad"), failures: 2}
+	router.Register(good)
+	router.Register(bad)
+
+	err := router.ConnectAll(context.Background())
+	var connErr *ConnectError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("ConnectAll error = %v, want *ConnectError", err)
+	}
+	if len(connErr.Failed) != 1 || connErr.Failed[0].Channel != "bad" {
+		t.Fatalf("failed = %+v, want only bad", connErr.Failed)
+	}
+	if !router.IsConnected("good") {
+		t.Error("good channel should stay connected")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !router.IsConnected("bad") {
+		if time.Now().After(deadline) {
+			t.Fatal("bad channel was not reconnected in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := router.DisconnectAll(context.Background()); err != nil {
+		t.Fatalf("DisconnectAll failed: %v", err)
+	}
+	if router.IsConnected("good") || router.IsConnected("bad") {
+		t.Error("channels should be disconnected")
+	}
+}
+
+func TestConnectAllTimeout(t *testing.T) {
+	router := NewRouter(nil,
+		WithConnectTimeout(20*time.Millisecond, 0),
+		WithConnectRetry(time.Hour, time.Hour))
+	slow := &flakyChannel{mockChannel: newMockChannel("slow"), block: make(chan struct{})}
+	router.Register(slow)
+	router.Register(newMockChannel("fast"))
+
+	results := router.ConnectChannels(context.Background())
+	for _, res := range results {
+		switch res.Channel {
+		case "slow":
+			if res.Err == nil {
+				t.Error("slow channel should time out")
+			}
+		case "fast":
+			if res.Err != nil {
+				t.Errorf("fast channel failed: %v", res.Err)
+			}
+		}
+	}
+
+	// A Connect that completes after the timeout still counts.
+	close(slow.block)
+	deadline := time.Now().Add(time.Second)
+	for !router.IsConnected("slow") {
+		if time.Now().After(deadline) {
+			t.Fatal("late connect was not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// DisconnectAll stops the pending retry without waiting for it.
+	if err := router.DisconnectAll(context.Background()); err != nil {
+		t.Fatalf("DisconnectAll failed: %v", err)
+	}
+}
+
+func TestDisconnectDuringHungConnect(t *testing.T) {
+	router := NewRouter(nil,
+		WithConnectTimeout(10*time.Millisecond, 20*time.Millisecond),
+		WithConnectRetry(time.Hour, time.Hour))
+	hung := &flakyChannel{mockChannel: newMockChannel("hung"), block: make(chan struct{})}
+	defer close(hung.block)
+	router.Register(hung)
+	router.ConnectChannels(context.Background())
+
+	// The Connect still holds the channel's lock; the wait for it is
+	// bounded by the disconnect timeout.
+	done := make(chan error, 1)
+	go func() { done <- router.DisconnectAll(context.Background()) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("DisconnectAll succeeded while Connect was hung")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DisconnectAll blocked on a hung Connect")
+	}
+}
+
+func TestAddRemoveChannel(t *testing.T) {
+	router := NewRouter(nil)
+	ctx := context.Background()
+
+	// Before Start, channels are only registered
+	early := newMockChannel("early")
+	if err := router.AddChannel(ctx, early); err != nil {
+		t.Fatalf("AddChannel failed: %v", err)
+	}
+	if router.IsConnected("early") {
+		t.Error("channel connected before Start")
+	}
+
+	if err := router.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer router.Stop(ctx)
+
+	late := newMockChannel("late")
+	if err := router.AddChannel(ctx, late); err != nil {
+		t.Fatalf("AddChannel failed: %v", err)
+	}
+	if !router.IsConnected("late") || !router.IsConnected("early") {
+		t.Error("channels should be connected after Start")
+	}
+
+	if err := router.RemoveChannel(ctx, "late"); err != nil {
+		t.Fatalf("RemoveChannel failed: %v", err)
+	}
+	if _, ok := router.GetChannel("late"); ok || router.IsConnected("late") {
+		t.Error("removed channel should be unregistered and disconnected")
+	}
+	if !router.IsConnected("early") {
+		t.Error("removing a channel disconnected another")
+	}
+	if err := router.RemoveChannel(ctx, "late"); err == nil {
+		t.Error("removing an unknown channel should fail")
+	}
+}
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	router := NewRouter(nil, WithShutdownTimeout(time.Second))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		close(started)
+		time.Sleep(20 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+
+	ctx := context.Background()
+	if err := router.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := ch.deliver(IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	<-started
+
+	if err := router.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if !finished.Load() {
+		t.Error("in-flight message was not drained")
+	}
+	if router.IsConnected("test") {
+		t.Error("channel still connected after Shutdown")
+	}
+	if err := ch.deliver(IncomingMessage{ID: "2", ChannelName: "test", ChatID: "c"}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("deliver after Shutdown = %v, want ErrShuttingDown", err)
+	}
+}
+
+func TestShutdownCancelsAfterTimeout(t *testing.T) {
+	router := NewRouter(nil, WithShutdownTimeout(20*time.Millisecond))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+
+	ctx := context.Background()
+	if err := router.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	_ = ch.deliver(IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c"})
+	<-started
+
+	err := router.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown error = %v, want deadline exceeded", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight handler was not canceled")
+	}
+}
@@ -0,0 +1,115 @@
+package channels
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+)
+
+// Standard log attribute keys added to message- and channel-scoped loggers.
+const (
+	LogKeyChannel = "channel"
+	LogKeyChat    = "chat"
+	LogKeySession = "session"
+	LogKeyTraceID = "trace_id"
+)
+
+type loggerKey struct{}
+
+type traceIDKey struct{}
+
+// ContextWithLogger returns a context carrying logger.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or slog.Default().
+// Inside router handlers it is scoped to the message being processed.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// ContextWithTraceID returns a context carrying a trace ID.
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceID returns the trace ID carried by ctx, or "".
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// NewTraceID returns a random 16-character hex trace ID.
+func NewTraceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// SessionID returns the agent session ID for a chat.
+func SessionID(channelName, chatID string) string {
+	return channelName + ":" + chatID
+}
+
+// SplitSessionID returns the channel and chat of a session ID. Session IDs
+// that extend SessionID with further ":"-separated parts, such as per-sender
+// or expiring sessions, resolve to their chat.
+func SplitSessionID(sessionID string) (channelName, chatID string, ok bool) {
+	channelName, rest, ok := strings.Cut(sessionID, ":")
+	if !ok || channelName == "" {
+		return "", "", false
+	}
+	chatID, _, _ = strings.Cut(rest, ":")
+	return channelName, chatID, chatID != ""
+}
+
+// ChannelLogger derives a logger for a channel adapter.
+func ChannelLogger(base *slog.Logger, channelName string) *slog.Logger {
+	if base == nil {
+		base = slog.Default()
+	}
+	return base.With(LogKeyChannel, channelName)
+}
+
+// ChatLogger derives a logger for a chat with the channel, chat, session,
+// and trace ID fields set.
+func ChatLogger(base *slog.Logger, channelName, chatID, traceID string) *slog.Logger {
+	if base == nil {
+		base = slog.Default()
+	}
+	return base.With(
+		LogKeyChannel, channelName,
+		LogKeyChat, chatID,
+		LogKeySession, SessionID(channelName, chatID),
+		LogKeyTraceID, traceID,
+	)
+}
+
+// chatContext scopes ctx to a chat: it keeps an existing trace ID, or
+// takes the OpenTelemetry trace ID of the span in ctx, or creates one, and
+// attaches a chat logger.
+func (r *Router) chatContext(ctx context.Context, channelName, chatID string) context.Context {
+	traceID := TraceID(ctx)
+	if traceID == "" {
+		traceID = spanTraceID(ctx)
+		if traceID == "" {
+			traceID = NewTraceID()
+		}
+		ctx = ContextWithTraceID(ctx, traceID)
+	}
+	return ContextWithLogger(ctx, ChatLogger(r.logger, channelName, chatID, traceID))
+}
+
+// log returns the logger scoped to ctx by the router, or the router's logger.
+func (r *Router) log(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return r.logger
+}
@@ -0,0 +1,47 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestRouterScopedLogger(t *testing.T) {
+	var buf bytes.Buffer
+	router := NewRouter(slog.New(slog.NewJSONHandler(&buf, nil)))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	var traceID string
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		traceID = TraceID(ctx)
+		LoggerFromContext(ctx).Info("handled")
+		return nil
+	})
+	buf.Reset()
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ChannelName: "test", ChatID: "42"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if traceID == "" {
+		t.Fatal("handler context has no trace ID")
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry %q: %v", buf.String(), err)
+	}
+	want := map[string]string{
+		LogKeyChannel: "test",
+		LogKeyChat:    "42",
+		LogKeySession: "test:42",
+		LogKeyTraceID: traceID,
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("log %s = %v, want %q", k, entry[k], v)
+		}
+	}
+}
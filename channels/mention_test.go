@@ -0,0 +1,37 @@
+package channels
+
+import "testing"
+
+func TestMentionGating(t *testing.T) {
+	router := NewRouter(nil, WithMentionGating("gated"))
+	gated := newMockChannel("gated")
+	open := newMockChannel("open")
+	router.Register(gated)
+	router.Register(open)
+	router.SetAgent(mockAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	tests := []struct {
+		ch    *mockChannel
+		msg   IncomingMessage
+		reply bool
+	}{
+		{gated, IncomingMessage{ChannelName: "gated", ChatID: "g", ChatType: ChannelTypeGroup, Content: "chatter"}, false},
+		{gated, IncomingMessage{ChannelName: "gated", ChatID: "g", ChatType: ChannelTypeGroup, Content: "hey bot", MentionsBot: true}, true},
+		{gated, IncomingMessage{ChannelName: "gated", ChatID: "d", ChatType: ChannelTypeDM, Content: "hi"}, true},
+		{open, IncomingMessage{ChannelName: "open", ChatID: "g", ChatType: ChannelTypeGroup, Content: "chatter"}, true},
+	}
+	for _, tt := range tests {
+		before := len(tt.ch.sentMessages())
+		if err := deliverAndWait(router, tt.ch, tt.msg); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+		if replied := len(tt.ch.sentMessages()) > before; replied != tt.reply {
+			t.Errorf("%s %q: replied = %v, want %v", tt.msg.ChannelName, tt.msg.Content, replied, tt.reply)
+		}
+	}
+
+	if !Mentioned().Match(IncomingMessage{ChatType: ChannelTypeGroup, MentionsBot: true}) {
+		t.Error("Mentioned should match messages mentioning the bot")
+	}
+}
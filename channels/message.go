@@ -1,6 +1,9 @@
 package channels
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // IncomingMessage represents a message received from a channel.
 type IncomingMessage struct {
@@ -16,6 +19,10 @@ type IncomingMessage struct {
 	// ChatType is the type of chat (dm, group, channel, thread).
 	ChatType ChannelType
 
+	// ThreadID is the platform ID of the thread the message was posted
+	// in, if any. ChatID addresses the thread, so replies stay in it.
+	ThreadID string
+
 	// SenderID is the sender's identifier.
 	SenderID string
 
@@ -31,6 +38,20 @@ type IncomingMessage struct {
 	// ReplyTo is the ID of the message being replied to, if any.
 	ReplyTo string
 
+	// Mentions lists the users mentioned in the message: user IDs, or
+	// @usernames where the platform does not resolve them.
+	Mentions []string
+
+	// MentionsBot is set by adapters when the message mentions the bot or
+	// replies to one of its messages.
+	MentionsBot bool
+
+	// CustomEmoji lists the custom emoji used in the message.
+	CustomEmoji []CustomEmoji
+
+	// Command is set for native command invocations.
+	Command *Command
+
 	// Timestamp is when the message was sent.
 	Timestamp time.Time
 
@@ -49,11 +70,52 @@ type OutgoingMessage struct {
 	// ReplyTo is the ID of the message to reply to, if any.
 	ReplyTo string
 
+	// ThreadID, if set, posts the message in this thread of the chat, such
+	// as an IncomingMessage.ThreadID.
+	ThreadID string
+
 	// Format specifies the message format.
 	Format MessageFormat
 
 	// Metadata contains channel-specific options.
 	Metadata map[string]interface{}
+
+	// IdempotencyKey, if set, deduplicates sends: a message with the same key
+	// sent to the same chat within the router's idempotency window is dropped.
+	IdempotencyKey string
+
+	// Ephemeral delivers the message only to Recipient: as an ephemeral
+	// message where the channel supports it, otherwise as a direct message.
+	Ephemeral bool
+
+	// Recipient is the user ID an ephemeral message is shown to.
+	Recipient string
+
+	// Components are rows of buttons, menus, and quick replies shown with
+	// the message. Channels that do not support them receive the labels as
+	// text. See Router.OnInteraction for handling presses.
+	Components [][]Component
+
+	// Raw, if set, is a platform-specific payload sent in place of Content
+	// and Media, for constructs envoy does not model. Adapter packages
+	// define the payloads they accept (e.g., discord.Modal, telegram.Dice).
+	Raw RawPayload
+}
+
+// RawPayload is a platform-specific message payload. See RawSender.
+type RawPayload interface {
+	// Validate reports whether the payload is well formed.
+	Validate() error
+}
+
+// Whisper returns an ephemeral reply to msg visible only to its sender.
+func Whisper(msg IncomingMessage, content string) OutgoingMessage {
+	return OutgoingMessage{
+		Content:   content,
+		ReplyTo:   msg.ID,
+		Ephemeral: true,
+		Recipient: msg.SenderID,
+	}
 }
 
 // Media represents attached media.
@@ -67,28 +129,64 @@ type Media struct {
 	// Data is the raw media data (for local media).
 	Data []byte
 
+	// FileID is a platform-specific identifier for media already known to
+	// the platform, such as a Telegram file ID or a Discord sticker ID.
+	FileID string
+
 	// MimeType is the MIME type.
 	MimeType string
 
 	// Filename is the file name.
 	Filename string
 
+	// Size is the size in bytes, if known.
+	Size int64
+
 	// Caption is an optional caption.
 	Caption string
+
+	// Sticker describes sticker media.
+	Sticker *Sticker
+
+	// Location, Contact, and Poll are the payloads of location, contact,
+	// and poll media.
+	Location *Location
+	Contact  *Contact
+	Poll     *Poll
 }
 
 // MediaType represents the type of media.
 type MediaType string
 
 const (
-	MediaTypeImage    MediaType = "image"
-	MediaTypeVideo    MediaType = "video"
-	MediaTypeAudio    MediaType = "audio"
-	MediaTypeDocument MediaType = "document"
-	MediaTypeSticker  MediaType = "sticker"
-	MediaTypeVoice    MediaType = "voice"
+	MediaTypeImage     MediaType = "image"
+	MediaTypeVideo     MediaType = "video"
+	MediaTypeAudio     MediaType = "audio"
+	MediaTypeDocument  MediaType = "document"
+	MediaTypeSticker   MediaType = "sticker"
+	MediaTypeAnimation MediaType = "animation"
+	MediaTypeVoice     MediaType = "voice"
+	MediaTypeLocation  MediaType = "location"
+	MediaTypeContact   MediaType = "contact"
+	MediaTypePoll      MediaType = "poll"
 )
 
+// MediaTypeFor returns the media type of a MIME type, defaulting to
+// MediaTypeDocument.
+func MediaTypeFor(mimeType string) MediaType {
+	switch {
+	case mimeType == "image/gif":
+		return MediaTypeAnimation
+	case strings.HasPrefix(mimeType, "image/"):
+		return MediaTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return MediaTypeVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return MediaTypeAudio
+	}
+	return MediaTypeDocument
+}
+
 // MessageFormat represents the message format.
 type MessageFormat string
 
@@ -96,6 +194,10 @@ const (
 	MessageFormatPlain    MessageFormat = "plain"
 	MessageFormatMarkdown MessageFormat = "markdown"
 	MessageFormatHTML     MessageFormat = "html"
+
+	// MessageFormatMarkdownV2 is Telegram's MarkdownV2. The router renders
+	// Markdown messages into it for Telegram.
+	MessageFormatMarkdownV2 MessageFormat = "markdown_v2"
 )
 
 // Event represents a channel event.
@@ -116,6 +218,37 @@ type Event struct {
 	Timestamp time.Time
 }
 
+// EventDataMessageID is the Event.Data key holding the affected message ID
+// for message events.
+const EventDataMessageID = "message_id"
+
+// Event.Data keys for message_edited events, which also carry
+// EventDataMessageID and EventDataUserID.
+const (
+	// EventDataContent holds the new text of the message.
+	EventDataContent = "content"
+
+	// EventDataPreviousContent holds the text before the edit, if known.
+	EventDataPreviousContent = "previous_content"
+)
+
+// EventDataUserName is the Event.Data key holding the user name on
+// member_joined and member_left events, which also carry EventDataUserID.
+const EventDataUserName = "user_name"
+
+// Event.Data keys for reaction events, which also carry EventDataMessageID.
+const (
+	// EventDataEmoji holds the reaction emoji (Unicode, or the name of a
+	// custom emoji).
+	EventDataEmoji = "emoji"
+
+	// EventDataUserID holds the ID of the user who reacted.
+	EventDataUserID = "user_id"
+
+	// EventDataRemoved is true when the reaction was removed.
+	EventDataRemoved = "removed"
+)
+
 // EventType represents the type of channel event.
 type EventType string
 
@@ -129,4 +262,64 @@ const (
 	EventTypeMemberLeft     EventType = "member_left"
 	EventTypeChannelCreated EventType = "channel_created"
 	EventTypeChannelDeleted EventType = "channel_deleted"
+
+	// Call events cover voice channels, stages, and video chats.
+	EventTypeCallStarted           EventType = "call_started"
+	EventTypeCallEnded             EventType = "call_ended"
+	EventTypeCallParticipantJoined EventType = "call_participant_joined"
+	EventTypeCallParticipantLeft   EventType = "call_participant_left"
+
+	// EventTypeInteraction is a button press, menu selection, or form
+	// submission on a platform with interactive components.
+	EventTypeInteraction EventType = "interaction"
+
+	// EventTypePollVote is a vote on a poll, or its retraction.
+	EventTypePollVote EventType = "poll_vote"
+
+	// Connection events are emitted by the router, with an empty ChatID.
+	EventTypeChannelConnected    EventType = "channel_connected"
+	EventTypeChannelDisconnected EventType = "channel_disconnected"
+)
+
+// Event.Data keys for interaction events, which also carry EventDataUserID
+// and, for components on a message, EventDataMessageID.
+const (
+	// EventDataCustomID holds the custom ID of the component or form.
+	EventDataCustomID = "custom_id"
+
+	// EventDataInteractionID and EventDataInteractionToken identify the
+	// interaction when responding to it, e.g. with a discord.Modal.
+	EventDataInteractionID    = "interaction_id"
+	EventDataInteractionToken = "interaction_token"
+)
+
+// EventDataError is the Event.Data key holding the error message on
+// channel_disconnected events caused by a failure.
+const EventDataError = "error"
+
+// Event.Data keys for call events.
+const (
+	// EventDataCallKind holds the CallKind.
+	EventDataCallKind = "call_kind"
+
+	// EventDataCallTopic holds the call topic, if known.
+	EventDataCallTopic = "call_topic"
+
+	// EventDataCallDuration holds the call duration as a time.Duration on
+	// call_ended events, if known.
+	EventDataCallDuration = "call_duration"
+
+	// EventDataParticipantID and EventDataParticipantName identify the
+	// participant on participant events.
+	EventDataParticipantID   = "participant_id"
+	EventDataParticipantName = "participant_name"
+)
+
+// CallKind is the kind of call an event refers to.
+type CallKind string
+
+const (
+	CallKindVoice     CallKind = "voice"
+	CallKindStage     CallKind = "stage"
+	CallKindVideoChat CallKind = "video_chat"
 )
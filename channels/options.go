@@ -0,0 +1,259 @@
+package channels
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/envoy/events"
+	"github.com/agentplexus/envoy/metrics"
+)
+
+// RouterOption configures a Router.
+type RouterOption func(*routerOptions)
+
+// routerOptions holds Router settings.
+type routerOptions struct {
+	connectTimeout    time.Duration
+	disconnectTimeout time.Duration
+	retryInitial      time.Duration
+	retryMax          time.Duration
+	idempotencyWindow time.Duration
+	dedupWindow       time.Duration
+	workers           int
+	metrics           *metrics.Registry
+	errorPolicy       ErrorPolicy
+	deadLetter        DeadLetterHandler
+	outbox            *OutboxConfig
+	mentionGating     bool
+	mentionChannels   []string
+	threadedReplies   bool
+	threadChannels    []string
+	sessions          SessionResolver
+	healthInterval    time.Duration
+	shutdownTimeout   time.Duration
+	transcript        Transcript
+	tracerProvider    trace.TracerProvider
+	events            *events.Bus
+	askTimeout        time.Duration
+	splitMarker       ContinuationMarker
+	responseHooks     []ResponseHook
+	typing            bool
+	typingInterval    time.Duration
+	typingChannels    []string
+}
+
+// defaultRouterOptions returns the default Router settings.
+func defaultRouterOptions() routerOptions {
+	return routerOptions{
+		connectTimeout:    30 * time.Second,
+		disconnectTimeout: 10 * time.Second,
+		retryInitial:      time.Second,
+		retryMax:          time.Minute,
+		idempotencyWindow: DefaultIdempotencyWindow,
+		dedupWindow:       DefaultDedupWindow,
+		workers:           DefaultWorkers,
+		healthInterval:    DefaultHealthInterval,
+		shutdownTimeout:   DefaultShutdownTimeout,
+		askTimeout:        DefaultAskTimeout,
+		splitMarker:       DefaultContinuationMarker,
+		typingInterval:    DefaultTypingInterval,
+	}
+}
+
+// WithConnectTimeout sets how long each channel may take to connect or
+// disconnect (default: 30s to connect, 10s to disconnect).
+func WithConnectTimeout(connect, disconnect time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		if connect > 0 {
+			o.connectTimeout = connect
+		}
+		if disconnect > 0 {
+			o.disconnectTimeout = disconnect
+		}
+	}
+}
+
+// WithConnectRetry sets the backoff used to retry channels that failed to
+// connect: starting at initial and doubling up to max (default: 1s to 1m).
+func WithConnectRetry(initial, max time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		if initial > 0 {
+			o.retryInitial = initial
+		}
+		if max > 0 {
+			o.retryMax = max
+		}
+	}
+}
+
+// WithIdempotencyWindow sets how long sent idempotency keys are remembered
+// (default: DefaultIdempotencyWindow).
+func WithIdempotencyWindow(d time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		if d > 0 {
+			o.idempotencyWindow = d
+		}
+	}
+}
+
+// WithDedupWindow sets how long incoming message IDs are remembered to drop
+// redelivered messages (default: DefaultDedupWindow). A window of zero or
+// less disables deduplication.
+func WithDedupWindow(d time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		o.dedupWindow = d
+	}
+}
+
+// WithWorkers sets how many chats are processed concurrently (default:
+// DefaultWorkers). Messages within a chat are always processed in order.
+// Zero processes messages synchronously in the adapter's callback.
+func WithWorkers(n int) RouterOption {
+	return func(o *routerOptions) {
+		if n >= 0 {
+			o.workers = n
+		}
+	}
+}
+
+// WithMetrics records message counts and agent latency in reg.
+func WithMetrics(reg *metrics.Registry) RouterOption {
+	return func(o *routerOptions) {
+		o.metrics = reg
+	}
+}
+
+// WithErrorPolicy sets the default policy for handler errors (default: log
+// and continue). RouteHandler.ErrorPolicy overrides it per handler.
+func WithErrorPolicy(policy ErrorPolicy) RouterOption {
+	return func(o *routerOptions) {
+		o.errorPolicy = policy
+	}
+}
+
+// WithDeadLetter sets the handler receiving messages under
+// ErrorActionDeadLetter.
+func WithDeadLetter(handler DeadLetterHandler) RouterOption {
+	return func(o *routerOptions) {
+		o.deadLetter = handler
+	}
+}
+
+// WithOutbox queues outgoing messages: Send returns once a message is queued
+// and a worker per channel delivers it, spacing sends and retrying
+// failures, including waiting out platform rate limits.
+func WithOutbox(config OutboxConfig) RouterOption {
+	return func(o *routerOptions) {
+		o.outbox = &config
+	}
+}
+
+// WithMentionGating makes ProcessWithAgent ignore group, channel, and thread
+// messages unless they mention the bot or reply to it. Direct messages are
+// always processed. If channelNames are given, gating applies only to those
+// channels.
+func WithMentionGating(channelNames ...string) RouterOption {
+	return func(o *routerOptions) {
+		o.mentionGating = true
+		o.mentionChannels = channelNames
+	}
+}
+
+// WithThreadedReplies answers group messages in a thread started from the
+// message, on all channels reporting Capabilities.ReplyThreads or only the
+// named ones, to keep busy chats readable. Messages already in a thread are
+// answered in it; other channels reply to the message as usual.
+func WithThreadedReplies(channelNames ...string) RouterOption {
+	return func(o *routerOptions) {
+		o.threadedReplies = true
+		o.threadChannels = channelNames
+	}
+}
+
+// WithSessions sets how ProcessWithAgent assigns session IDs (default: one
+// session per chat, "<channel>:<chat>").
+func WithSessions(resolver SessionResolver) RouterOption {
+	return func(o *routerOptions) {
+		o.sessions = resolver
+	}
+}
+
+// WithHealthCheck sets how often the supervisor started by Start checks
+// channels implementing HealthChecker (default: DefaultHealthInterval).
+// Zero or less disables health checks.
+func WithHealthCheck(interval time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		o.healthInterval = interval
+	}
+}
+
+// WithShutdownTimeout sets how long Shutdown waits for in-flight messages
+// before canceling them (default: DefaultShutdownTimeout).
+func WithShutdownTimeout(d time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		if d > 0 {
+			o.shutdownTimeout = d
+		}
+	}
+}
+
+// WithTranscript records every processed incoming message and every
+// successfully sent outgoing message in t. Duplicates dropped by
+// deduplication or idempotency keys are not recorded, and recording
+// failures are logged without affecting delivery.
+func WithTranscript(t Transcript) RouterOption {
+	return func(o *routerOptions) {
+		o.transcript = t
+	}
+}
+
+// WithEvents publishes lifecycle events to bus: channel connections,
+// adapter events, routed messages, handler and agent failures, and send
+// retries.
+func WithEvents(bus *events.Bus) RouterOption {
+	return func(o *routerOptions) {
+		o.events = bus
+	}
+}
+
+// WithAskTimeout sets how long Ask waits for the user's reply (default:
+// DefaultAskTimeout).
+func WithAskTimeout(d time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		if d > 0 {
+			o.askTimeout = d
+		}
+	}
+}
+
+// WithContinuationMarker sets the marker appended to the parts of messages
+// split for channels implementing CapabilityReporter (default:
+// DefaultContinuationMarker). A nil marker splits without markers.
+func WithContinuationMarker(marker ContinuationMarker) RouterOption {
+	return func(o *routerOptions) {
+		o.splitMarker = marker
+	}
+}
+
+// WithResponseHook adds a hook run on agent responses before they are sent,
+// in the order added.
+func WithResponseHook(hook ResponseHook) RouterOption {
+	return func(o *routerOptions) {
+		o.responseHooks = append(o.responseHooks, hook)
+	}
+}
+
+// WithTypingIndicator makes ProcessWithAgent show a typing indicator on
+// streaming channels while the agent works, refreshed every interval
+// (default: DefaultTypingInterval), until the response is sent or starts
+// streaming. If channelNames are given, only those channels show it.
+func WithTypingIndicator(interval time.Duration, channelNames ...string) RouterOption {
+	return func(o *routerOptions) {
+		o.typing = true
+		if interval > 0 {
+			o.typingInterval = interval
+		}
+		o.typingChannels = channelNames
+	}
+}
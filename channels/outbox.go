@@ -0,0 +1,242 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/events"
+)
+
+// DefaultOutboxSize is the default maximum number of queued outgoing
+// messages.
+const DefaultOutboxSize = 1000
+
+// DefaultOutboxWorkers is the default number of channels the outbox sends
+// to concurrently.
+const DefaultOutboxWorkers = 4
+
+// ErrOutboxFull is returned when the outbox queue is at capacity.
+var ErrOutboxFull = errors.New("outbox full")
+
+// OutboxConfig configures the router's outbound send queue.
+type OutboxConfig struct {
+	// QueueSize caps the number of queued messages (default:
+	// DefaultOutboxSize).
+	QueueSize int
+
+	// Workers caps how many channels are sent to concurrently (default:
+	// DefaultOutboxWorkers). Each channel is sent to in order.
+	Workers int
+
+	// Intervals sets the minimum time between sends per channel name, e.g.
+	// {"telegram": 50 * time.Millisecond}.
+	Intervals map[string]time.Duration
+
+	// DefaultInterval applies to channels without an entry in Intervals.
+	DefaultInterval time.Duration
+
+	// Retry retries failed sends (default: 5 attempts). Rate-limited sends
+	// wait the platform's retry-after instead of the backoff.
+	Retry RetryPolicy
+}
+
+// Delivery tracks an outgoing message queued in the outbox.
+type Delivery struct {
+	done    chan struct{}
+	receipt MessageReceipt
+	err     error
+}
+
+func newDelivery() *Delivery {
+	return &Delivery{done: make(chan struct{})}
+}
+
+// complete records the delivery result.
+func (d *Delivery) complete(receipt MessageReceipt, err error) {
+	d.receipt, d.err = receipt, err
+	close(d.done)
+}
+
+// Done is closed once the message was sent or failed permanently.
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Err returns the delivery error once Done is closed.
+func (d *Delivery) Err() error {
+	select {
+	case <-d.done:
+		return d.err
+	default:
+		return nil
+	}
+}
+
+// Receipt returns the receipt of the sent message once Done is closed. It
+// is zero for failed deliveries and dropped duplicates.
+func (d *Delivery) Receipt() MessageReceipt {
+	select {
+	case <-d.done:
+		return d.receipt
+	default:
+		return MessageReceipt{}
+	}
+}
+
+// MessageID returns the MessageID of the receipt once Done is closed.
+func (d *Delivery) MessageID() string {
+	return d.Receipt().MessageID
+}
+
+// Wait blocks until the message is delivered or ctx is done. Canceling ctx
+// does not cancel the delivery.
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		return d.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// outbox sends messages in order per channel, spacing sends and retrying
+// failures.
+type outbox struct {
+	config OutboxConfig
+	queue  *dispatcher
+	mu     sync.Mutex
+	next   map[string]time.Time
+}
+
+func newOutbox(config OutboxConfig) *outbox {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultOutboxSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = DefaultOutboxWorkers
+	}
+	if config.Retry.MaxAttempts <= 0 {
+		config.Retry.MaxAttempts = 5
+	}
+	return &outbox{
+		config: config,
+		// One item runs at a time per channel; channels proceed in parallel
+		queue: newDispatcher(config.Workers),
+		next:  make(map[string]time.Time),
+	}
+}
+
+// interval returns the minimum spacing between sends on a channel.
+func (o *outbox) interval(channel string) time.Duration {
+	if d, ok := o.config.Intervals[channel]; ok {
+		return d
+	}
+	return o.config.DefaultInterval
+}
+
+// pause delays the next send on a channel until at least d from now.
+func (o *outbox) pause(channel string, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if until := time.Now().Add(d); until.After(o.next[channel]) {
+		o.next[channel] = until
+	}
+}
+
+// wait blocks until the channel may send again.
+func (o *outbox) wait(ctx context.Context, channel string) error {
+	o.mu.Lock()
+	delay := time.Until(o.next[channel])
+	o.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// deliver sends a queued message, retrying per the outbox policy, and
+// returns its receipt.
+func (r *Router) deliver(ctx context.Context, channel Channel, chatID string, msg OutgoingMessage) (MessageReceipt, error) {
+	o := r.outbox
+	name := channel.Name()
+	retry := &o.config.Retry
+
+	for attempt := 1; ; attempt++ {
+		if err := o.wait(ctx, name); err != nil {
+			return MessageReceipt{}, err
+		}
+		receipt, err := r.sendTo(ctx, channel, chatID, msg)
+		o.pause(name, o.interval(name))
+		if err == nil {
+			return receipt, nil
+		}
+
+		retryAfter, limited := RetryAfter(err)
+		if attempt >= retry.attempts() || (!limited && !retry.retryable(err)) {
+			return MessageReceipt{}, err
+		}
+		delay := retry.Backoff(attempt)
+		if limited {
+			delay = retryAfter
+		}
+		o.pause(name, delay)
+		r.sendRetried(name, chatID, attempt, delay, err)
+		r.log(ctx).Warn("send failed, retrying",
+			"target_channel", name,
+			"target_chat", chatID,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err)
+	}
+}
+
+// sendRetried publishes a send retry.
+func (r *Router) sendRetried(channelName, chatID string, attempt int, delay time.Duration, err error) {
+	r.publish(events.SendRetried{
+		Channel: channelName,
+		ChatID:  chatID,
+		Attempt: attempt,
+		Delay:   delay,
+		Err:     err,
+		Time:    time.Now(),
+	})
+}
+
+// SendAsync queues a message in the outbox and returns a Delivery that can
+// be awaited. Messages with a Raw payload the channel rejects fail
+// immediately. Without an outbox (see WithOutbox) the message is sent
+// immediately and the returned Delivery is already complete.
+func (r *Router) SendAsync(ctx context.Context, channelName, chatID string, msg OutgoingMessage) (*Delivery, error) {
+	channel, ok := r.GetChannel(channelName)
+	if !ok {
+		return nil, errChannelNotFound(channelName)
+	}
+	if err := validateRaw(channel, msg); err != nil {
+		return nil, err
+	}
+
+	d := newDelivery()
+	if r.outbox == nil {
+		d.complete(r.sendTo(ctx, channel, chatID, msg))
+		return d, nil
+	}
+	if r.outbox.queue.queueDepth() >= r.outbox.config.QueueSize {
+		return nil, ErrOutboxFull
+	}
+
+	// Deliver even if the caller's context ends first
+	ctx = context.WithoutCancel(ctx)
+	r.outbox.queue.enqueue(channelName, func() {
+		d.complete(r.deliver(ctx, channel, chatID, msg))
+	})
+	return d, nil
+}
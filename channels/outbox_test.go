@@ -0,0 +1,97 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// transientChannel fails a fixed number of sends.
+type transientChannel struct {
+	*mockChannel
+	mu       sync.Mutex
+	failures int
+}
+
+func (c *transientChannel) Send(ctx context.Context, chatID string, msg OutgoingMessage) error {
+	c.mu.Lock()
+	if c.failures > 0 {
+		c.failures--
+		c.mu.Unlock()
+		return errors.New("transient")
+	}
+	c.mu.Unlock()
+	return c.mockChannel.Send(ctx, chatID, msg)
+}
+
+func TestOutboxRetriesAndAwaits(t *testing.T) {
+	router := NewRouter(nil, WithOutbox(OutboxConfig{
+		Retry: RetryPolicy{InitialBackoff: time.Millisecond},
+	}))
+	ch := &transientChannel{mockChannel: newMockChannel("flaky"), failures: 2}
+	router.Register(ch)
+
+	ctx := context.Background()
+	d, err := router.SendAsync(ctx, "flaky", "1", OutgoingMessage{Content: "hi"})
+	if err != nil {
+		t.Fatalf("SendAsync failed: %v", err)
+	}
+	if err := d.Wait(ctx); err != nil {
+		t.Fatalf("delivery failed: %v", err)
+	}
+	if sent := ch.sentMessages(); len(sent) != 1 {
+		t.Errorf("sent = %+v, want one delivery", sent)
+	}
+
+	if _, err := router.SendAsync(ctx, "missing", "1", OutgoingMessage{}); err == nil {
+		t.Error("expected error for unknown channel")
+	}
+}
+
+func TestOutboxOrderAndRateLimit(t *testing.T) {
+	router := NewRouter(nil, WithOutbox(OutboxConfig{
+		Intervals: map[string]time.Duration{"limited": 5 * time.Millisecond},
+	}))
+	ch := &rateLimitedChannel{mockChannel: newMockChannel("limited")}
+	router.Register(ch)
+
+	ctx := context.Background()
+	start := time.Now()
+	for _, content := range []string{"a", "b", "c"} {
+		if err := router.Send(ctx, "limited", "1", OutgoingMessage{Content: content}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if err := router.Drain(ctx); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	// 20ms retry-after on the first send plus two 5ms intervals
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("delivered in %s, want rate limits honored", elapsed)
+	}
+	sent := ch.sentMessages()
+	if len(sent) != 3 || sent[0].Content != "a" || sent[1].Content != "b" || sent[2].Content != "c" {
+		t.Errorf("sent = %+v, want a, b, c in order", sent)
+	}
+}
+
+func TestOutboxBroadcast(t *testing.T) {
+	router := NewRouter(nil, WithOutbox(OutboxConfig{
+		Retry: RetryPolicy{InitialBackoff: time.Millisecond},
+	}))
+	ch := &transientChannel{mockChannel: newMockChannel("flaky"), failures: 2}
+	router.Register(ch)
+
+	// Transient failures are retried by the outbox; unknown channels are
+	// skipped
+	err := router.Broadcast(context.Background(), map[string]string{"flaky": "1", "missing": "2"}, OutgoingMessage{Content: "hi"})
+	if err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+	if sent := ch.sentMessages(); len(sent) != 1 {
+		t.Errorf("sent = %+v, want one delivery", sent)
+	}
+}
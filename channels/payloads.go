@@ -0,0 +1,132 @@
+package channels
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Location is a shared location or venue.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+
+	// Title and Address describe a venue; empty for plain locations.
+	Title   string
+	Address string
+}
+
+// Contact is a shared contact card.
+type Contact struct {
+	PhoneNumber string
+	FirstName   string
+	LastName    string
+
+	// UserID is the contact's user ID on the platform, if known.
+	UserID string
+
+	// VCard holds additional details as a vCard.
+	VCard string
+}
+
+// Poll is a poll with options to vote for.
+type Poll struct {
+	// ID identifies the poll in vote events. Set on received polls.
+	ID string
+
+	Question string
+	Options  []PollOption
+
+	// MultipleAnswers allows voting for several options.
+	MultipleAnswers bool
+
+	// Anonymous hides who voted. Votes on anonymous polls are not
+	// reported.
+	Anonymous bool
+
+	// Duration is how long the poll is open (default: the platform's,
+	// one day on Discord and unlimited on Telegram).
+	Duration time.Duration
+
+	// Closed is true once voting has ended.
+	Closed bool
+}
+
+// PollOption is an option of a poll.
+type PollOption struct {
+	Text string
+
+	// Votes is the number of votes, if known.
+	Votes int
+}
+
+// LocationMedia returns media sharing a location.
+func LocationMedia(location Location) Media {
+	return Media{Type: MediaTypeLocation, Location: &location}
+}
+
+// ContactMedia returns media sharing a contact.
+func ContactMedia(contact Contact) Media {
+	return Media{Type: MediaTypeContact, Contact: &contact}
+}
+
+// PollMedia returns media starting a poll with the given options.
+func PollMedia(question string, options ...string) Media {
+	poll := &Poll{Question: question}
+	for _, o := range options {
+		poll.Options = append(poll.Options, PollOption{Text: o})
+	}
+	return Media{Type: MediaTypePoll, Poll: poll}
+}
+
+// MediaText describes sticker, location, contact, and poll media as text,
+// for agents and for platforms that cannot show them. Stickers are shown
+// as their emoji. It returns "" for other media.
+func MediaText(m Media) string {
+	switch {
+	case m.Type == MediaTypeSticker:
+		return stickerText(m)
+	case m.Type == MediaTypeLocation && m.Location != nil:
+		l := m.Location
+		coords := formatCoordinate(l.Latitude) + ", " + formatCoordinate(l.Longitude)
+		venue := strings.Trim(l.Title+", "+l.Address, ", ")
+		if venue == "" {
+			return "Location: " + coords
+		}
+		return "Location: " + venue + " (" + coords + ")"
+	case m.Type == MediaTypeContact && m.Contact != nil:
+		c := m.Contact
+		name := strings.TrimSpace(c.FirstName + " " + c.LastName)
+		return "Contact: " + strings.Trim(name+", "+c.PhoneNumber, ", ")
+	case m.Type == MediaTypePoll && m.Poll != nil:
+		lines := []string{"Poll: " + m.Poll.Question}
+		for i, o := range m.Poll.Options {
+			line := fmt.Sprintf("%d. %s", i+1, o.Text)
+			if o.Votes > 0 {
+				line += fmt.Sprintf(" (%d)", o.Votes)
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+// MediaContent returns the text descriptions of the sticker, location,
+// contact, and poll media of a message, one per line, for messages without
+// text.
+func MediaContent(media []Media) string {
+	var lines []string
+	for _, m := range media {
+		if text := MediaText(m); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCoordinate formats a latitude or longitude.
+func formatCoordinate(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,76 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMediaText(t *testing.T) {
+	poll := PollMedia("Lunch?", "Pizza", "Sushi")
+	poll.Poll.Options[1].Votes = 3
+
+	tests := []struct {
+		media Media
+		want  string
+	}{
+		{LocationMedia(Location{Latitude: 52.52, Longitude: 13.405}), "Location: 52.52, 13.405"},
+		{LocationMedia(Location{Latitude: 48.8584, Longitude: 2.2945, Title: "Eiffel Tower", Address: "Champ de Mars, Paris"}), "Location: Eiffel Tower, Champ de Mars, Paris (48.8584, 2.2945)"},
+		{ContactMedia(Contact{FirstName: "Ann", LastName: "Lee", PhoneNumber: "+15550100"}), "Contact: Ann Lee, +15550100"},
+		{ContactMedia(Contact{PhoneNumber: "+15550100"}), "Contact: +15550100"},
+		{poll, "Poll: Lunch?\n1. Pizza\n2. Sushi (3)"},
+		{StickerMedia("CAAC", "👍"), "👍"},
+		{Media{Type: MediaTypeImage, URL: "https://example.com/a.png"}, ""},
+		{Media{Type: MediaTypeLocation}, ""},
+	}
+	for _, tt := range tests {
+		if got := MediaText(tt.media); got != tt.want {
+			t.Errorf("MediaText(%s) = %q, want %q", tt.media.Type, got, tt.want)
+		}
+	}
+
+	media := []Media{{Type: MediaTypeImage}, LocationMedia(Location{Latitude: 1, Longitude: 2}), ContactMedia(Contact{FirstName: "Bo"})}
+	if got, want := MediaContent(media), "Location: 1, 2\nContact: Bo"; got != want {
+		t.Errorf("MediaContent = %q, want %q", got, want)
+	}
+}
+
+func TestOnPollVote(t *testing.T) {
+	router := NewRouter(nil, WithWorkers(0))
+	ch := newMockChannel("telegram")
+	router.Register(ch)
+
+	var got []PollVote
+	router.OnPollVote(func(ctx context.Context, vote PollVote) error {
+		got = append(got, vote)
+		return nil
+	})
+
+	for _, options := range [][]int{{0, 2}, nil} {
+		err := ch.events(context.Background(), Event{
+			Type:        EventTypePollVote,
+			ChannelName: "telegram",
+			ChatID:      "c1",
+			Data: map[string]interface{}{
+				EventDataPollID:  "p1",
+				EventDataUserID:  "u1",
+				EventDataOptions: options,
+				EventDataRemoved: len(options) == 0,
+			},
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("votes = %+v, want 2", got)
+	}
+	if v := got[0]; v.PollID != "p1" || v.UserID != "u1" || v.ChatID != "c1" || len(v.Options) != 2 || v.Options[1] != 2 || v.Removed {
+		t.Errorf("vote = %+v", v)
+	}
+	if !got[1].Removed || len(got[1].Options) != 0 {
+		t.Errorf("vote = %+v, want retraction", got[1])
+	}
+}
@@ -0,0 +1,72 @@
+package channels
+
+import (
+	"context"
+	"time"
+)
+
+// Event.Data keys for poll vote events, which also carry EventDataUserID,
+// EventDataRemoved, and, on platforms where polls are messages,
+// EventDataMessageID.
+const (
+	// EventDataPollID holds the poll's ID.
+	EventDataPollID = "poll_id"
+
+	// EventDataOptions holds the indexes of the options voted for as
+	// []int.
+	EventDataOptions = "options"
+)
+
+// PollVote is a vote on a poll.
+type PollVote struct {
+	ChannelName string
+	ChatID      string
+
+	// PollID is the ID of the poll, as in Poll.ID.
+	PollID string
+
+	// UserID is the user who voted.
+	UserID string
+
+	// Options are the indexes of the options voted for.
+	Options []int
+
+	// Removed is true when the vote for Options was retracted. Telegram
+	// reports retractions without options, retracting all of the user's
+	// votes.
+	Removed bool
+
+	Timestamp time.Time
+}
+
+// PollVoteFromEvent returns the vote a poll vote event reports.
+func PollVoteFromEvent(event Event) (PollVote, bool) {
+	if event.Type != EventTypePollVote {
+		return PollVote{}, false
+	}
+	v := PollVote{
+		ChannelName: event.ChannelName,
+		ChatID:      event.ChatID,
+		Timestamp:   event.Timestamp,
+	}
+	v.PollID, _ = event.Data[EventDataPollID].(string)
+	v.UserID, _ = event.Data[EventDataUserID].(string)
+	v.Options, _ = event.Data[EventDataOptions].([]int)
+	v.Removed, _ = event.Data[EventDataRemoved].(bool)
+	return v, true
+}
+
+// PollVoteHandler handles poll votes.
+type PollVoteHandler func(ctx context.Context, vote PollVote) error
+
+// OnPollVote adds a handler for votes on polls. Votes are processed in
+// order with messages from the same chat.
+func (r *Router) OnPollVote(handler PollVoteHandler) {
+	r.OnEvent(func(ctx context.Context, event Event) error {
+		v, ok := PollVoteFromEvent(event)
+		if !ok {
+			return nil
+		}
+		return handler(ctx, v)
+	}, EventTypePollVote)
+}
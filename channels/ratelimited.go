@@ -0,0 +1,57 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RateLimitedError is returned by adapters when the platform rejects a
+// request for exceeding its rate limit. Callers should wait RetryAfter
+// before retrying.
+type RateLimitedError struct {
+	// Channel is the adapter that was rate limited.
+	Channel string
+
+	// RetryAfter is how long the platform asked callers to wait.
+	RetryAfter time.Duration
+
+	// Err is the underlying platform error.
+	Err error
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s: rate limited, retry after %s: %v", e.Channel, e.RetryAfter, e.Err)
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter reports how long to wait before retrying if err is, or wraps,
+// a *RateLimitedError.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rl *RateLimitedError
+	if errors.As(err, &rl) {
+		return rl.RetryAfter, true
+	}
+	return 0, false
+}
+
+// waitRetryAfter sleeps for the delay requested by a rate-limited err. It
+// returns false if err is not rate limited or ctx ends first.
+func waitRetryAfter(ctx context.Context, err error) bool {
+	delay, ok := RetryAfter(err)
+	if !ok {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,50 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// rateLimitedChannel rejects the first send with a RateLimitedError.
+type rateLimitedChannel struct {
+	*mockChannel
+	limited bool
+}
+
+func (c *rateLimitedChannel) Send(ctx context.Context, chatID string, msg OutgoingMessage) error {
+	if !c.limited {
+		c.limited = true
+		return &RateLimitedError{Channel: c.name, RetryAfter: 20 * time.Millisecond, Err: errors.New("429")}
+	}
+	return c.mockChannel.Send(ctx, chatID, msg)
+}
+
+func TestRetryAfter(t *testing.T) {
+	err := fmt.Errorf("send message: %w", &RateLimitedError{RetryAfter: 3 * time.Second})
+	if d, ok := RetryAfter(err); !ok || d != 3*time.Second {
+		t.Errorf("RetryAfter = %v, %v; want 3s, true", d, ok)
+	}
+	if _, ok := RetryAfter(errors.New("boom")); ok {
+		t.Error("plain error should not carry retry-after")
+	}
+}
+
+func TestBroadcastWaitsForRetryAfter(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &rateLimitedChannel{mockChannel: newMockChannel("limited")}
+	router.Register(ch)
+
+	start := time.Now()
+	if err := router.Broadcast(context.Background(), map[string]string{"limited": "1"}, OutgoingMessage{Content: "hi"}); err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Broadcast returned after %s, want at least the retry-after delay", elapsed)
+	}
+	if sent := ch.sentMessages(); len(sent) != 1 {
+		t.Errorf("sent = %+v, want one delivery after retry", sent)
+	}
+}
@@ -0,0 +1,70 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// diceRoll is a raw payload accepted by mockRawChannel.
+type diceRoll struct{ sides int }
+
+func (d diceRoll) Validate() error {
+	if d.sides < 2 {
+		return errors.New("dice need at least two sides")
+	}
+	return nil
+}
+
+// poll is a raw payload no channel accepts.
+type poll struct{}
+
+func (poll) Validate() error { return nil }
+
+type mockRawChannel struct{ *mockChannel }
+
+func (m *mockRawChannel) ValidateRaw(p RawPayload) error {
+	if _, ok := p.(diceRoll); !ok {
+		return ErrRawUnsupported
+	}
+	return p.Validate()
+}
+
+func TestRawPayload(t *testing.T) {
+	ctx := context.Background()
+	ch := &mockRawChannel{newMockChannel("raw")}
+	for _, outbox := range []bool{false, true} {
+		var opts []RouterOption
+		if outbox {
+			opts = append(opts, WithOutbox(OutboxConfig{}))
+		}
+		router := NewRouter(nil, opts...)
+		router.Register(ch)
+		router.Register(newMockChannel("plain"))
+
+		if err := router.Send(ctx, "raw", "c1", OutgoingMessage{Raw: diceRoll{sides: 6}}); err != nil {
+			t.Errorf("Send failed: %v", err)
+		}
+		for _, tc := range []struct {
+			channel string
+			raw     RawPayload
+		}{
+			{"raw", diceRoll{sides: 1}},
+			{"raw", poll{}},
+			{"plain", diceRoll{sides: 6}},
+		} {
+			// Rejected before queueing, even with an outbox
+			_, err := router.SendAsync(ctx, tc.channel, "c1", OutgoingMessage{Raw: tc.raw})
+			if err == nil {
+				t.Errorf("%s %T: expected error", tc.channel, tc.raw)
+			}
+			if tc.channel == "plain" && !errors.Is(err, ErrRawUnsupported) {
+				t.Errorf("err = %v, want ErrRawUnsupported", err)
+			}
+		}
+		router.Drain(ctx)
+	}
+	if sent := ch.sentMessages(); len(sent) != 2 {
+		t.Errorf("sent %d messages, want 2", len(sent))
+	}
+}
@@ -0,0 +1,96 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReactionsUnsupported is returned by AddReaction and RemoveReaction for
+// channels that do not implement Reactor.
+var ErrReactionsUnsupported = errors.New("channel does not support reactions")
+
+// AddReaction reacts to a message on a channel implementing Reactor, e.g. to
+// acknowledge a request before the reply is ready.
+func (r *Router) AddReaction(ctx context.Context, channelName, chatID, messageID, emoji string) error {
+	reactor, err := r.reactor(channelName)
+	if err != nil {
+		return err
+	}
+	if err := reactor.AddReaction(ctx, chatID, messageID, emoji); err != nil {
+		return fmt.Errorf("add reaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveReaction removes a reaction added with AddReaction.
+func (r *Router) RemoveReaction(ctx context.Context, channelName, chatID, messageID, emoji string) error {
+	reactor, err := r.reactor(channelName)
+	if err != nil {
+		return err
+	}
+	if err := reactor.RemoveReaction(ctx, chatID, messageID, emoji); err != nil {
+		return fmt.Errorf("remove reaction: %w", err)
+	}
+	return nil
+}
+
+// reactor returns the named channel as a Reactor.
+func (r *Router) reactor(channelName string) (Reactor, error) {
+	channel, ok := r.GetChannel(channelName)
+	if !ok {
+		return nil, errChannelNotFound(channelName)
+	}
+	reactor, ok := channel.(Reactor)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", channelName, ErrReactionsUnsupported)
+	}
+	return reactor, nil
+}
+
+// Reaction is a user adding or removing a reaction on a message.
+type Reaction struct {
+	ChannelName string
+	ChatID      string
+	MessageID   string
+	UserID      string
+	Emoji       string
+
+	// Removed is true when the user took the reaction back.
+	Removed bool
+
+	Timestamp time.Time
+}
+
+// ReactionFromEvent returns the reaction a reaction event reports.
+func ReactionFromEvent(event Event) (Reaction, bool) {
+	if event.Type != EventTypeReaction {
+		return Reaction{}, false
+	}
+	reaction := Reaction{
+		ChannelName: event.ChannelName,
+		ChatID:      event.ChatID,
+		Timestamp:   event.Timestamp,
+	}
+	reaction.MessageID, _ = event.Data[EventDataMessageID].(string)
+	reaction.UserID, _ = event.Data[EventDataUserID].(string)
+	reaction.Emoji, _ = event.Data[EventDataEmoji].(string)
+	reaction.Removed, _ = event.Data[EventDataRemoved].(bool)
+	return reaction, true
+}
+
+// ReactionHandler handles reactions of users.
+type ReactionHandler func(ctx context.Context, reaction Reaction) error
+
+// OnReaction adds a handler for reactions users add to or remove from
+// messages, e.g. thumbs up and down as feedback on replies.
+func (r *Router) OnReaction(handler ReactionHandler) {
+	r.OnEvent(func(ctx context.Context, event Event) error {
+		reaction, ok := ReactionFromEvent(event)
+		if !ok {
+			return nil
+		}
+		return handler(ctx, reaction)
+	}, EventTypeReaction)
+}
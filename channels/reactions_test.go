@@ -0,0 +1,92 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// reactorChannel is a mock channel recording reactions.
+type reactorChannel struct {
+	*mockChannel
+	reactions map[string]string
+}
+
+func (c *reactorChannel) AddReaction(ctx context.Context, chatID, messageID, emoji string) error {
+	c.reactions[chatID+"/"+messageID] = emoji
+	return nil
+}
+
+func (c *reactorChannel) RemoveReaction(ctx context.Context, chatID, messageID, emoji string) error {
+	delete(c.reactions, chatID+"/"+messageID)
+	return nil
+}
+
+func TestRouterReactions(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &reactorChannel{newMockChannel("discord"), map[string]string{}}
+	router.Register(ch)
+	router.Register(newMockChannel("plain"))
+	ctx := context.Background()
+
+	if err := router.AddReaction(ctx, "discord", "c1", "m1", "👀"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ch.reactions["c1/m1"]; got != "👀" {
+		t.Errorf("reaction = %q, want 👀", got)
+	}
+	if err := router.RemoveReaction(ctx, "discord", "c1", "m1", "👀"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.reactions) != 0 {
+		t.Errorf("reactions = %v, want none", ch.reactions)
+	}
+
+	if err := router.AddReaction(ctx, "plain", "c1", "m1", "👀"); !errors.Is(err, ErrReactionsUnsupported) {
+		t.Errorf("AddReaction(plain) = %v, want ErrReactionsUnsupported", err)
+	}
+	if err := router.AddReaction(ctx, "missing", "c1", "m1", "👀"); err == nil {
+		t.Error("AddReaction(missing) succeeded")
+	}
+}
+
+func TestOnReaction(t *testing.T) {
+	router := NewRouter(nil, WithWorkers(0))
+	ch := newMockChannel("telegram")
+	router.Register(ch)
+
+	var got []Reaction
+	router.OnReaction(func(ctx context.Context, reaction Reaction) error {
+		got = append(got, reaction)
+		return nil
+	})
+
+	for _, removed := range []bool{false, true} {
+		err := ch.events(context.Background(), Event{
+			Type:        EventTypeReaction,
+			ChannelName: "telegram",
+			ChatID:      "c1",
+			Data: map[string]interface{}{
+				EventDataMessageID: "m1",
+				EventDataUserID:    "u1",
+				EventDataEmoji:     "👍",
+				EventDataRemoved:   removed,
+			},
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("reactions = %+v, want 2", got)
+	}
+	if r := got[0]; r.MessageID != "m1" || r.UserID != "u1" || r.Emoji != "👍" || r.ChatID != "c1" || r.Removed {
+		t.Errorf("reaction = %+v", r)
+	}
+	if !got[1].Removed {
+		t.Errorf("reaction = %+v, want removed", got[1])
+	}
+}
@@ -0,0 +1,47 @@
+package channels
+
+import (
+	"context"
+	"time"
+)
+
+// MessageReceipt identifies a sent message, e.g. to edit, delete, react
+// to, or reply to it later.
+type MessageReceipt struct {
+	ChannelName string
+
+	// ChatID is the chat the message was delivered to: the direct chat
+	// for ephemeral messages delivered by direct message.
+	ChatID string
+
+	// MessageID is the platform ID of the message, or of its last part if
+	// it was split. It is empty for channels that do not implement
+	// ReceiptSender.
+	MessageID string
+
+	// PartIDs holds the IDs of all parts of a split message, in order.
+	PartIDs []string
+
+	// Timestamp is when the message was sent.
+	Timestamp time.Time
+}
+
+// localReceipt returns the receipt of a message sent on a channel that
+// does not report receipts.
+func localReceipt(channel Channel, chatID string) MessageReceipt {
+	return MessageReceipt{ChannelName: channel.Name(), ChatID: chatID, Timestamp: time.Now()}
+}
+
+// SendWithReceipt sends a message like Send and returns its receipt. With
+// an outbox (see WithOutbox), it waits for the delivery. Dropped duplicates
+// return a zero receipt.
+func (r *Router) SendWithReceipt(ctx context.Context, channelName, chatID string, msg OutgoingMessage) (MessageReceipt, error) {
+	d, err := r.SendAsync(ctx, channelName, chatID, msg)
+	if err != nil {
+		return MessageReceipt{}, err
+	}
+	if err := d.Wait(ctx); err != nil {
+		return MessageReceipt{}, err
+	}
+	return d.Receipt(), nil
+}
@@ -0,0 +1,39 @@
+package channels
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSendWithReceipt(t *testing.T) {
+	ctx := context.Background()
+	for _, outbox := range []bool{false, true} {
+		var opts []RouterOption
+		if outbox {
+			opts = append(opts, WithOutbox(OutboxConfig{}))
+		}
+		router := NewRouter(nil, opts...)
+		ch := newEditorChannel("discord", 40)
+		router.Register(ch)
+		router.Register(newMockChannel("plain"))
+
+		receipt, err := router.SendWithReceipt(ctx, "discord", "c1", OutgoingMessage{Content: "One sentence here. Another sentence follows."})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.ChannelName != "discord" || receipt.ChatID != "c1" || receipt.MessageID != "m2" || receipt.Timestamp.IsZero() {
+			t.Errorf("receipt = %+v", receipt)
+		}
+		if len(receipt.PartIDs) != 2 || receipt.PartIDs[0] != "m1" {
+			t.Errorf("PartIDs = %v, want [m1 m2]", receipt.PartIDs)
+		}
+
+		receipt, err = router.SendWithReceipt(ctx, "plain", "c1", OutgoingMessage{Content: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.ChannelName != "plain" || receipt.ChatID != "c1" || receipt.MessageID != "" || receipt.Timestamp.IsZero() {
+			t.Errorf("plain receipt = %+v", receipt)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+)
+
+// ResponseHook adjusts an agent's response to msg before it is sent, e.g.
+// to attach a spoken version of it. Returning an error drops the response.
+type ResponseHook func(ctx context.Context, msg IncomingMessage, response OutgoingMessage) (OutgoingMessage, error)
+
+// runResponseHooks runs the configured response hooks on response.
+func (r *Router) runResponseHooks(ctx context.Context, msg IncomingMessage, response OutgoingMessage) (OutgoingMessage, error) {
+	for _, hook := range r.options.responseHooks {
+		var err error
+		if response, err = hook(ctx, msg, response); err != nil {
+			return response, fmt.Errorf("response hook: %w", err)
+		}
+	}
+	return response, nil
+}
+
+// sendHookMedia runs the response hooks on a streamed response and sends
+// the media they attach as a follow-up message. The streamed text has
+// already been shown, so changes to the content are ignored.
+func (r *Router) sendHookMedia(ctx context.Context, msg IncomingMessage, chatID string, streamed OutgoingMessage) error {
+	if len(r.options.responseHooks) == 0 {
+		return nil
+	}
+	out, err := r.runResponseHooks(ctx, msg, streamed)
+	if err != nil {
+		return err
+	}
+	if len(out.Media) == 0 {
+		return nil
+	}
+	return r.Send(ctx, msg.ChannelName, chatID, OutgoingMessage{
+		Media:    out.Media,
+		ReplyTo:  streamed.ReplyTo,
+		ThreadID: out.ThreadID,
+	})
+}
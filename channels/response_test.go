@@ -0,0 +1,39 @@
+package channels
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResponseHook(t *testing.T) {
+	voice := func(ctx context.Context, msg IncomingMessage, response OutgoingMessage) (OutgoingMessage, error) {
+		if _, ok := MessageFromContext(ctx); !ok {
+			t.Error("hook context lacks the incoming message")
+		}
+		response.Media = append(response.Media, Media{Type: MediaTypeVoice, Data: []byte(response.Content)})
+		return response, nil
+	}
+	router := NewRouter(nil, WithResponseHook(voice))
+	plain := newMockChannel("plain")
+	streaming := &mockStreamingChannel{mockChannel: newMockChannel("streaming")}
+	router.Register(plain)
+	router.Register(streaming)
+	router.SetAgent(mockStreamingAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	if err := deliverAndWait(router, plain, IncomingMessage{ID: "1", ChannelName: "plain", ChatID: "c1", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	sent := plain.sentMessages()
+	if len(sent) != 1 || sent[0].Content != "echo: hi" || len(sent[0].Media) != 1 || string(sent[0].Media[0].Data) != "echo: hi" {
+		t.Errorf("plain sent = %+v", sent)
+	}
+
+	if err := deliverAndWait(router, streaming, IncomingMessage{ID: "2", ChannelName: "streaming", ChatID: "c1", Content: "one two"}); err != nil {
+		t.Fatal(err)
+	}
+	sent = streaming.sentMessages()
+	if len(sent) != 1 || sent[0].Content != "" || len(sent[0].Media) != 1 || string(sent[0].Media[0].Data) != "one two " || sent[0].ReplyTo != "2" {
+		t.Errorf("streaming sent = %+v, want the voice after the stream", sent)
+	}
+}
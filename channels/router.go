@@ -2,9 +2,20 @@ package channels
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/envoy/events"
+	"github.com/agentplexus/envoy/markup"
+	"github.com/agentplexus/envoy/mention"
+	"github.com/agentplexus/envoy/metrics"
 )
 
 // AgentProcessor processes messages through an AI agent.
@@ -12,19 +23,82 @@ type AgentProcessor interface {
 	Process(ctx context.Context, sessionID, content string) (string, error)
 }
 
-// Router routes messages between channels and the agent.
+// StreamingAgentProcessor is an AgentProcessor that can stream its response
+// incrementally. The returned channel must be closed when the response is
+// complete or ctx is canceled.
+type StreamingAgentProcessor interface {
+	AgentProcessor
+
+	// ProcessStream processes a message and streams the response.
+	ProcessStream(ctx context.Context, sessionID, content string) (<-chan Chunk, error)
+}
+
+// Chunk is an incremental piece of a streamed agent response.
+type Chunk struct {
+	// Content is the text generated since the previous chunk.
+	Content string
+
+	// Err is set on the final chunk if the stream failed.
+	Err error
+}
+
+// Sender sends outgoing messages. Packages replying to commands and
+// relaying messages depend on it; *Router satisfies it.
+type Sender interface {
+	Send(ctx context.Context, channelName, chatID string, msg OutgoingMessage) error
+}
+
+// Router routes messages between channels and agents.
 type Router struct {
-	channels map[string]Channel
-	handlers []RouteHandler
-	agent    AgentProcessor
-	logger   *slog.Logger
-	mu       sync.RWMutex
+	channels   map[string]Channel
+	handlers   []RouteHandler
+	events     []eventRoute
+	middleware []Middleware
+	agents     *AgentRegistry
+	images     ImageLoader
+	logger     *slog.Logger
+	options    routerOptions
+	mu         sync.RWMutex
+
+	// Channel lifecycle state
+	lifecycle *lifecycleState
+
+	// Recently sent idempotency keys
+	sent *IdempotencyCache
+
+	// Recently received message IDs, nil if deduplication is disabled
+	received *IdempotencyCache
+
+	// Per-chat worker pool, nil if dispatch is synchronous
+	dispatcher *dispatcher
+
+	// Outbound send queue, nil if sends are synchronous
+	outbox *outbox
+
+	// Questions asked with Ask awaiting the user's reply
+	questions questionSet
+
+	// Work scheduled by handlers, e.g. Throttle's flushes
+	delayed delayedSet
+
+	tracer trace.Tracer
 }
 
 // RouteHandler processes routed messages.
 type RouteHandler struct {
 	Pattern RoutePattern
 	Handler MessageHandler
+
+	// Priority orders handlers; higher runs first. Handlers with equal
+	// priority run in registration order.
+	Priority int
+
+	// Exclusive stops propagation: once an exclusive handler matches, no
+	// lower-ordered handlers run.
+	Exclusive bool
+
+	// ErrorPolicy overrides the router's error policy for this handler.
+	ErrorPolicy *ErrorPolicy
 }
 
 // RoutePattern defines which messages to match.
@@ -35,68 +109,282 @@ type RoutePattern struct {
 	// ChatTypes limits to specific chat types (empty = all).
 	ChatTypes []ChannelType
 
+	// Senders limits to specific sender IDs (empty = all).
+	Senders []string
+
+	// Chats limits to specific chat IDs (empty = all).
+	Chats []string
+
 	// Prefix matches messages starting with a prefix.
 	Prefix string
+
+	// Match, if set, must also return true for the message to match.
+	Match func(msg IncomingMessage) bool
 }
 
 // NewRouter creates a new message router.
-func NewRouter(logger *slog.Logger) *Router {
+func NewRouter(logger *slog.Logger, opts ...RouterOption) *Router {
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &Router{
-		channels: make(map[string]Channel),
-		handlers: []RouteHandler{},
-		logger:   logger,
+	options := defaultRouterOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	r := &Router{
+		channels:  make(map[string]Channel),
+		handlers:  []RouteHandler{},
+		logger:    logger,
+		options:   options,
+		agents:    NewAgentRegistry(),
+		lifecycle: newLifecycleState(),
+		sent:      NewIdempotencyCache(options.idempotencyWindow),
+		tracer:    newTracer(options.tracerProvider),
+		questions: questionSet{pending: make(map[string]chan IncomingMessage)},
+		delayed:   delayedSet{pending: make(map[*time.Timer]func())},
 	}
+	if options.dedupWindow > 0 {
+		r.received = NewIdempotencyCache(options.dedupWindow)
+	}
+	if options.outbox != nil {
+		r.outbox = newOutbox(*options.outbox)
+		if options.metrics != nil {
+			options.metrics.GaugeFunc("outbox_depth", nil, func() float64 {
+				return float64(r.outbox.queue.queueDepth())
+			})
+		}
+	}
+	if options.workers > 0 {
+		r.dispatcher = newDispatcher(options.workers)
+		if options.metrics != nil {
+			options.metrics.GaugeFunc("router_queue_depth", nil, func() float64 {
+				return float64(r.dispatcher.queueDepth())
+			})
+		}
+	}
+	return r
 }
 
-// SetAgent sets the agent processor for the router.
+// SetAgent registers agent as the router's default agent. It is shorthand
+// for Agents().Register(DefaultAgentName, agent) followed by SetDefault.
 func (r *Router) SetAgent(agent AgentProcessor) {
+	agents := r.Agents()
+	agents.Register(DefaultAgentName, agent)
+	agents.SetDefault(DefaultAgentName)
+}
+
+// SetAgents replaces the router's agent registry.
+func (r *Router) SetAgents(agents *AgentRegistry) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.agent = agent
+	r.agents = agents
+}
+
+// Agents returns the router's agent registry.
+func (r *Router) Agents() *AgentRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.agents
 }
 
-// ProcessWithAgent creates a message handler that processes through the agent and sends responses.
+// ProcessWithAgent creates a message handler that processes through the agent
+// selected by the router's AgentRegistry and sends responses.
+// When both the agent and the message's channel support streaming, the response
+// is streamed to the chat as it is generated.
 func (r *Router) ProcessWithAgent() MessageHandler {
 	return func(ctx context.Context, msg IncomingMessage) error {
-		r.mu.RLock()
-		agent := r.agent
-		r.mu.RUnlock()
-
+		name, agent, err := r.Agents().Select(msg)
+		if err != nil {
+			r.log(ctx).Error("agent selection failed", "error", err)
+			return err
+		}
 		if agent == nil {
-			r.logger.Warn("no agent configured, message not processed",
-				"channel", msg.ChannelName,
-				"chat", msg.ChatID)
+			r.log(ctx).Warn("no agent configured, message not processed")
+			return nil
+		}
+		if r.gated(msg) {
+			r.log(ctx).Debug("group message does not mention the bot, not processed")
 			return nil
 		}
 
-		// Use chatID as session ID for conversation continuity
-		sessionID := fmt.Sprintf("%s:%s", msg.ChannelName, msg.ChatID)
+		ctx, sessionID, err := r.resolveSession(ctx, msg)
+		if err != nil {
+			r.log(ctx).Error("session resolution failed", "error", err)
+			return err
+		}
 
-		r.logger.Info("processing message",
-			"channel", msg.ChannelName,
-			"chat", msg.ChatID,
-			"from", msg.SenderName)
+		r.log(ctx).Info("processing message",
+			"from", msg.SenderName,
+			"agent", name)
 
-		response, err := agent.Process(ctx, sessionID, msg.Content)
-		if err != nil {
-			r.logger.Error("agent processing error",
-				"channel", msg.ChannelName,
-				"chat", msg.ChatID,
-				"error", err)
+		start := time.Now()
+		defer r.observeAgent(start)
+
+		ctx, span := r.tracer.Start(ctx, SpanAgent, trace.WithAttributes(
+			AttrAgent.String(name),
+			AttrSession.String(sessionID),
+		))
+		err = r.processWith(ctx, name, agent, sessionID, msg)
+		EndSpan(span, err)
+		return err
+	}
+}
+
+// processWith processes a message through agent and sends the response,
+// streaming it when both the agent and the channel support streaming.
+// Messages with images for a MultimodalAgentProcessor are not streamed.
+func (r *Router) processWith(ctx context.Context, name string, agent AgentProcessor, sessionID string, msg IncomingMessage) error {
+	stopTyping := r.startTyping(ctx, msg)
+	defer stopTyping()
+
+	var images []Image
+	if _, ok := agent.(MultimodalAgentProcessor); ok {
+		var err error
+		if images, err = r.loadImages(ctx, msg); err != nil {
+			r.agentFailed(name, sessionID, msg, err)
+			r.log(ctx).Error("image loading error", "error", err)
 			return err
 		}
+	}
 
-		// Send response back to the same channel/chat
-		return r.Send(ctx, msg.ChannelName, msg.ChatID, OutgoingMessage{
-			Content: response,
-			ReplyTo: msg.ID,
-		})
+	if streamer, ok := agent.(StreamingAgentProcessor); ok && len(images) == 0 {
+		if channel, ok := r.streamingChannel(msg.ChannelName); ok {
+			trace.SpanFromContext(ctx).SetAttributes(AttrStreaming.Bool(true))
+			return r.processStream(ctx, name, streamer, channel, sessionID, msg, stopTyping)
+		}
+	}
+
+	var response string
+	var err error
+	if len(images) > 0 {
+		response, err = agent.(MultimodalAgentProcessor).ProcessMultimodal(ctx, sessionID, msg.Content, images)
+	} else {
+		response, err = agent.Process(ctx, sessionID, msg.Content)
+	}
+	if err != nil {
+		r.agentFailed(name, sessionID, msg, err)
+		r.log(ctx).Error("agent processing error", "error", err)
+		return err
+	}
+
+	// Send response back to the same channel/chat
+	chatID, replyTo := r.replyTarget(ctx, msg)
+	out, err := r.runResponseHooks(ctx, msg, OutgoingMessage{
+		Content: response,
+		ReplyTo: replyTo,
+	})
+	if err != nil {
+		return err
+	}
+	stopTyping()
+	return r.Send(ctx, msg.ChannelName, chatID, out)
+}
+
+// SessionResolver assigns agent session IDs to messages.
+type SessionResolver interface {
+	SessionID(ctx context.Context, msg IncomingMessage) (string, error)
+}
+
+// resolveSession returns the agent session ID for a message: from the
+// configured SessionResolver, or one session per chat. If the resolver is a
+// SessionConfigResolver, the returned context carries the session's
+// configuration.
+func (r *Router) resolveSession(ctx context.Context, msg IncomingMessage) (context.Context, string, error) {
+	switch resolver := r.options.sessions.(type) {
+	case nil:
+		return ctx, SessionID(msg.ChannelName, msg.ChatID), nil
+	case SessionConfigResolver:
+		id, config, err := resolver.ResolveSession(ctx, msg)
+		if err != nil || config.IsZero() {
+			return ctx, id, err
+		}
+		return WithSessionConfig(ctx, config), id, nil
+	default:
+		id, err := resolver.SessionID(ctx, msg)
+		return ctx, id, err
 	}
 }
 
+// gated reports whether mention gating suppresses the agent for msg.
+func (r *Router) gated(msg IncomingMessage) bool {
+	if !r.options.mentionGating || msg.MentionsBot || msg.ChatType == ChannelTypeDM {
+		return false
+	}
+	return len(r.options.mentionChannels) == 0 || contains(r.options.mentionChannels, msg.ChannelName)
+}
+
+// processStream pipes a streamed agent response into a streaming channel,
+// calling stopTyping when the first text arrives.
+func (r *Router) processStream(ctx context.Context, name string, agent StreamingAgentProcessor, channel StreamingChannel, sessionID string, msg IncomingMessage, stopTyping func()) error {
+	// Canceling stops the agent if the channel gives up on the stream
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks, err := agent.ProcessStream(ctx, sessionID, msg.Content)
+	if err != nil {
+		r.agentFailed(name, sessionID, msg, err)
+		r.log(ctx).Error("agent processing error", "error", err)
+		return err
+	}
+
+	// The full response is kept for the transcript
+	var response strings.Builder
+	text := make(chan string)
+	streamErr := make(chan error, 1)
+	go func() {
+		defer close(text)
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				streamErr <- chunk.Err
+				return
+			}
+			if chunk.Content == "" {
+				continue
+			}
+			response.WriteString(chunk.Content)
+			stopTyping()
+			select {
+			case text <- chunk.Content:
+			case <-ctx.Done():
+				streamErr <- ctx.Err()
+				return
+			}
+		}
+		streamErr <- nil
+	}()
+
+	chatID, replyTo := r.replyTarget(ctx, msg)
+	var sendErr error
+	if rs, ok := channel.(ReplyStreamer); ok && replyTo != "" {
+		sendErr = rs.SendStreamReply(ctx, chatID, replyTo, text)
+	} else {
+		sendErr = channel.SendStream(ctx, chatID, text)
+	}
+	cancel()
+
+	if err := <-streamErr; err != nil && sendErr == nil {
+		r.agentFailed(name, sessionID, msg, err)
+		r.log(ctx).Error("agent stream error", "error", err)
+		return err
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	// ctx was canceled above once the stream ended
+	ctx = context.WithoutCancel(ctx)
+	full := OutgoingMessage{Content: response.String(), ReplyTo: replyTo}
+	r.recordOutgoing(ctx, msg.ChannelName, chatID, "", full)
+	return r.sendHookMedia(ctx, msg, chatID, full)
+}
+
+// streamingChannel returns the named channel if it supports streaming.
+func (r *Router) streamingChannel(name string) (StreamingChannel, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	channel, ok := r.channels[name].(StreamingChannel)
+	return channel, ok
+}
+
 // Register adds a channel to the router.
 func (r *Router) Register(channel Channel) {
 	r.mu.Lock()
@@ -105,47 +393,315 @@ func (r *Router) Register(channel Channel) {
 	name := channel.Name()
 	r.channels[name] = channel
 
-	// Set up message handler
+	// Set up message and event handlers
 	channel.OnMessage(func(ctx context.Context, msg IncomingMessage) error {
 		return r.route(ctx, msg)
 	})
+	channel.OnEvent(func(ctx context.Context, event Event) error {
+		if r.lifecycle.closing.Load() {
+			return ErrShuttingDown
+		}
+		r.publish(events.ChannelEvent{
+			Channel: event.ChannelName,
+			ChatID:  event.ChatID,
+			Kind:    string(event.Type),
+			Data:    event.Data,
+			Time:    event.Timestamp,
+		})
+		return r.routeEvent(ctx, event)
+	})
 
 	r.logger.Info("channel registered", "name", name)
 }
 
-// Unregister removes a channel from the router.
+// Unregister removes a channel from the router. Any background reconnect
+// attempts for the channel are stopped; the channel is not disconnected.
 func (r *Router) Unregister(name string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	delete(r.channels, name)
+	r.lifecycle.stopRetry(name)
 	r.logger.Info("channel unregistered", "name", name)
 }
 
-// OnMessage adds a message handler with a pattern.
-func (r *Router) OnMessage(pattern RoutePattern, handler MessageHandler) {
+// eventRoute is a registered event handler.
+type eventRoute struct {
+	types   []EventType
+	handler EventHandler
+}
+
+// OnEvent adds a handler for channel events of the given types (none = all).
+// Events are processed in order with messages from the same chat.
+func (r *Router) OnEvent(handler EventHandler, types ...EventType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, eventRoute{types: types, handler: handler})
+}
+
+// routeEvent dispatches an event to matching handlers.
+func (r *Router) routeEvent(ctx context.Context, event Event) error {
+	ctx, span := r.tracer.Start(ctx, SpanEvent,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(chatAttributes(event.ChannelName, event.ChatID)...),
+		trace.WithAttributes(AttrEventType.String(string(event.Type))))
+	ctx = r.chatContext(ctx, event.ChannelName, event.ChatID)
+	if r.dispatcher == nil {
+		r.processEvent(ctx, event)
+		span.End()
+		return nil
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	r.dispatcher.enqueue(event.ChannelName+"\x00"+event.ChatID, func() {
+		r.processEvent(ctx, event)
+		span.End()
+	})
+	return nil
+}
+
+// processEvent runs the handlers matching an event.
+func (r *Router) processEvent(ctx context.Context, event Event) {
+	r.mu.RLock()
+	routes := make([]eventRoute, len(r.events))
+	copy(routes, r.events)
+	r.mu.RUnlock()
+
+	for _, route := range routes {
+		if len(route.types) > 0 && !containsEventType(route.types, event.Type) {
+			continue
+		}
+		if err := route.handler(ctx, event); err != nil {
+			r.log(ctx).Error("event handler error",
+				"type", event.Type,
+				"error", err)
+		}
+	}
+}
+
+// containsEventType reports whether types contains t.
+func containsEventType(types []EventType, t EventType) bool {
+	for _, et := range types {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Use adds middleware applied to every incoming message before routing.
+// Middleware runs in the order added.
+func (r *Router) Use(mw ...Middleware) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.handlers = append(r.handlers, RouteHandler{
+	r.middleware = append(r.middleware, mw...)
+}
+
+// OnMessage adds a message handler with a pattern.
+func (r *Router) OnMessage(pattern RoutePattern, handler MessageHandler) {
+	r.AddHandler(RouteHandler{
 		Pattern: pattern,
 		Handler: handler,
 	})
 }
 
-// Send sends a message to a specific channel and chat.
+// AddHandler adds a route handler, honoring its Priority and Exclusive
+// settings. A catch-all fallback is a low-priority handler registered after
+// exclusive handlers for everything it should not see.
+func (r *Router) AddHandler(h RouteHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, h)
+	sort.SliceStable(r.handlers, func(i, j int) bool {
+		return r.handlers[i].Priority > r.handlers[j].Priority
+	})
+}
+
+var _ Sender = (*Router)(nil)
+
+// Send sends a message to a specific channel and chat. Messages with an
+// IdempotencyKey already sent to the same chat are dropped. With an outbox
+// (see WithOutbox), Send only queues the message; use SendAsync to await
+// delivery.
 func (r *Router) Send(ctx context.Context, channelName, chatID string, msg OutgoingMessage) error {
-	r.mu.RLock()
-	channel, ok := r.channels[channelName]
-	r.mu.RUnlock()
+	d, err := r.SendAsync(ctx, channelName, chatID, msg)
+	if err != nil {
+		return err
+	}
+	return d.Err()
+}
 
+// ErrThreadsUnsupported is returned by CreateThread for channels that do not
+// implement Threader.
+var ErrThreadsUnsupported = errors.New("channel does not support threads")
+
+// CreateThread starts a thread in a chat on a channel implementing Threader,
+// posting firstMessage in it. The returned chat ID can be used with Send to
+// post further messages to the thread.
+func (r *Router) CreateThread(ctx context.Context, channelName, chatID, title string, firstMessage OutgoingMessage) (string, error) {
+	channel, ok := r.GetChannel(channelName)
+	if !ok {
+		return "", errChannelNotFound(channelName)
+	}
+	threader, ok := channel.(Threader)
 	if !ok {
-		return fmt.Errorf("channel not found: %s", channelName)
+		return "", fmt.Errorf("%s: %w", channelName, ErrThreadsUnsupported)
+	}
+
+	threadID, err := threader.CreateThread(ctx, chatID, title, firstMessage)
+	if err != nil {
+		return threadID, fmt.Errorf("create thread: %w", err)
+	}
+	return threadID, nil
+}
+
+// errChannelNotFound reports an unregistered channel name.
+func errChannelNotFound(name string) error {
+	return fmt.Errorf("channel not found: %s", name)
+}
+
+// sendTo sends a message to a channel, honoring its idempotency key, and
+// returns its receipt.
+func (r *Router) sendTo(ctx context.Context, channel Channel, chatID string, msg OutgoingMessage) (MessageReceipt, error) {
+	if msg.IdempotencyKey == "" {
+		receipt, err := r.deliverTo(ctx, channel, chatID, msg)
+		if err := r.countSent(channel, err); err != nil {
+			return MessageReceipt{}, err
+		}
+		r.recordOutgoing(ctx, channel.Name(), chatID, receipt.MessageID, msg)
+		return receipt, nil
+	}
+
+	key := idempotencyKey(channel.Name(), chatID, msg)
+	if !r.sent.Reserve(key) {
+		r.logger.Debug("duplicate message dropped",
+			"channel", channel.Name(),
+			"chat", chatID,
+			"idempotency_key", msg.IdempotencyKey)
+		return MessageReceipt{}, nil
+	}
+	receipt, err := r.deliverTo(ctx, channel, chatID, msg)
+	if err := r.countSent(channel, err); err != nil {
+		r.sent.Release(key)
+		return MessageReceipt{}, err
+	}
+	r.recordOutgoing(ctx, channel.Name(), chatID, receipt.MessageID, msg)
+	return receipt, nil
+}
+
+// ErrRawUnsupported is returned when a channel does not accept an
+// OutgoingMessage.Raw payload.
+var ErrRawUnsupported = errors.New("raw payload not supported by channel")
+
+// validateRaw checks a message's raw payload, if any, with its channel.
+func validateRaw(channel Channel, msg OutgoingMessage) error {
+	if msg.Raw == nil {
+		return nil
+	}
+	rs, ok := channel.(RawSender)
+	if !ok {
+		return fmt.Errorf("%s: %w", channel.Name(), ErrRawUnsupported)
+	}
+	if err := rs.ValidateRaw(msg.Raw); err != nil {
+		return fmt.Errorf("%s: invalid raw payload: %w", channel.Name(), err)
+	}
+	return nil
+}
+
+// ErrEphemeralUnsupported is returned when an ephemeral message cannot be
+// delivered privately on a channel.
+var ErrEphemeralUnsupported = errors.New("channel supports neither ephemeral nor direct messages")
+
+// deliverTo hands a message to the channel rendered for the platform, split
+// to the platform's length limit, delivering ephemeral messages privately:
+// natively if supported, otherwise by direct message.
+func (r *Router) deliverTo(ctx context.Context, channel Channel, chatID string, msg OutgoingMessage) (receipt MessageReceipt, err error) {
+	ctx, span := r.tracer.Start(ctx, SpanSend,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(chatAttributes(channel.Name(), chatID)...))
+	defer func() { EndSpan(span, err) }()
+
+	if msg, err = renderOutgoing(channel, msg); err != nil {
+		return MessageReceipt{}, err
+	}
+
+	send := func(chatID string, msg OutgoingMessage) (MessageReceipt, error) {
+		if rs, ok := channel.(ReceiptSender); ok {
+			return rs.SendWithReceipt(ctx, chatID, msg)
+		}
+		return localReceipt(channel, chatID), channel.Send(ctx, chatID, msg)
+	}
+	if msg.Ephemeral {
+		if msg.Recipient == "" {
+			return MessageReceipt{}, fmt.Errorf("ephemeral message has no recipient")
+		}
+		if es, ok := channel.(EphemeralSender); ok {
+			send = func(chatID string, msg OutgoingMessage) (MessageReceipt, error) {
+				return localReceipt(channel, chatID), es.SendEphemeral(ctx, chatID, msg.Recipient, msg)
+			}
+		} else {
+			dm, ok := channel.(DirectMessenger)
+			if !ok {
+				return MessageReceipt{}, fmt.Errorf("%s: %w", channel.Name(), ErrEphemeralUnsupported)
+			}
+			if chatID, err = dm.DirectChatID(ctx, msg.Recipient); err != nil {
+				return MessageReceipt{}, fmt.Errorf("open direct message: %w", err)
+			}
+			// The original message is not in the direct chat
+			msg.ReplyTo = ""
+		}
 	}
 
-	return channel.Send(ctx, chatID, msg)
+	var partIDs []string
+	for _, part := range splitOutgoing(channel, msg, r.options.splitMarker) {
+		if receipt, err = send(chatID, part); err != nil {
+			return MessageReceipt{}, err
+		}
+		if receipt.MessageID != "" {
+			partIDs = append(partIDs, receipt.MessageID)
+		}
+	}
+	if len(partIDs) > 1 {
+		receipt.PartIDs = partIDs
+	}
+	return receipt, nil
 }
 
-// Broadcast sends a message to all registered channels.
+// renderOutgoing renders a message's components, stickers, custom emoji,
+// mentions, and Markdown for the channel's platform.
+func renderOutgoing(channel Channel, msg OutgoingMessage) (OutgoingMessage, error) {
+	caps := capabilities(channel)
+	if len(msg.Components) > 0 {
+		if err := ValidateComponents(msg.Components); err != nil {
+			return msg, err
+		}
+		if !caps.Components {
+			msg.Content = strings.TrimSpace(msg.Content + "\n\n" + componentsText(msg.Components))
+			msg.Components = nil
+		}
+	}
+	if !caps.Stickers {
+		msg = stickersText(msg)
+	}
+	if !caps.CustomEmoji {
+		msg.Content = customEmojiText(msg.Content)
+	}
+	msg.Content = mention.Render(channel.Name(), msg.Content, mention.Format(msg.Format))
+	if msg.Format == MessageFormatMarkdown {
+		content, format := markup.Render(channel.Name(), msg.Content)
+		msg.Content, msg.Format = content, MessageFormat(format)
+	}
+	return msg, nil
+}
+
+// Broadcast sends a message to a chat on each of the given channels,
+// skipping channels that are not registered. With an outbox (see
+// WithOutbox) the messages are queued like other sends and Broadcast
+// waits for their delivery.
 func (r *Router) Broadcast(ctx context.Context, chatIDs map[string]string, msg OutgoingMessage) error {
+	if r.outbox != nil {
+		return r.broadcastQueued(ctx, chatIDs, msg)
+	}
+
 	r.mu.RLock()
 	channels := make(map[string]Channel, len(r.channels))
 	for k, v := range r.channels {
@@ -156,48 +712,55 @@ func (r *Router) Broadcast(ctx context.Context, chatIDs map[string]string, msg O
 	var errs []error
 	for name, chatID := range chatIDs {
 		if channel, ok := channels[name]; ok {
-			if err := channel.Send(ctx, chatID, msg); err != nil {
+			err := validateRaw(channel, msg)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			_, err = r.sendTo(ctx, channel, chatID, msg)
+			// Pause as long as the platform asks, then retry once
+			if retryAfter, ok := RetryAfter(err); ok {
+				r.sendRetried(name, chatID, 1, retryAfter, err)
+				if waitRetryAfter(ctx, err) {
+					_, err = r.sendTo(ctx, channel, chatID, msg)
+				}
+			}
+			if err != nil {
 				errs = append(errs, fmt.Errorf("%s: %w", name, err))
 			}
 		}
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("broadcast errors: %v", errs)
+		return fmt.Errorf("broadcast errors: %w", errors.Join(errs...))
 	}
 	return nil
 }
 
-// ConnectAll connects all registered channels.
-func (r *Router) ConnectAll(ctx context.Context) error {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	for name, channel := range r.channels {
-		if err := channel.Connect(ctx); err != nil {
-			return fmt.Errorf("connect %s: %w", name, err)
+// broadcastQueued broadcasts through the outbox, which spaces and retries
+// the sends.
+func (r *Router) broadcastQueued(ctx context.Context, chatIDs map[string]string, msg OutgoingMessage) error {
+	var errs []error
+	deliveries := make(map[string]*Delivery, len(chatIDs))
+	for name, chatID := range chatIDs {
+		if _, ok := r.GetChannel(name); !ok {
+			continue
 		}
-		r.logger.Info("channel connected", "name", name)
+		d, err := r.SendAsync(ctx, name, chatID, msg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		deliveries[name] = d
 	}
-	return nil
-}
-
-// DisconnectAll disconnects all registered channels.
-func (r *Router) DisconnectAll(ctx context.Context) error {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	var errs []error
-	for name, channel := range r.channels {
-		if err := channel.Disconnect(ctx); err != nil {
+	for name, d := range deliveries {
+		if err := d.Wait(ctx); err != nil {
 			errs = append(errs, fmt.Errorf("%s: %w", name, err))
-		} else {
-			r.logger.Info("channel disconnected", "name", name)
 		}
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("disconnect errors: %v", errs)
+		return fmt.Errorf("broadcast errors: %w", errors.Join(errs...))
 	}
 	return nil
 }
@@ -222,27 +785,169 @@ func (r *Router) ListChannels() []string {
 	return names
 }
 
-// route dispatches a message to matching handlers.
+// route runs middleware and dispatches a message to matching handlers. With a
+// worker pool, the message is queued behind earlier messages from the same
+// chat and route returns immediately.
 func (r *Router) route(ctx context.Context, msg IncomingMessage) error {
+	if r.lifecycle.closing.Load() {
+		return ErrShuttingDown
+	}
+	ctx, span := r.tracer.Start(ctx, SpanReceive,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(chatAttributes(msg.ChannelName, msg.ChatID)...),
+		trace.WithAttributes(AttrMessageID.String(msg.ID)))
+	ctx = r.chatContext(ctx, msg.ChannelName, msg.ChatID)
+	if r.options.metrics != nil {
+		r.options.metrics.Counter("messages_received", metrics.Labels{"channel": msg.ChannelName}).Inc()
+	}
+
+	// Drop webhook retries and reconnect replays
+	if r.received != nil && msg.ID != "" && !r.received.Reserve(dedupKey(msg)) {
+		r.log(ctx).Debug("duplicate message dropped", "id", msg.ID)
+		if r.options.metrics != nil {
+			r.options.metrics.Counter("messages_deduplicated", metrics.Labels{"channel": msg.ChannelName}).Inc()
+		}
+		span.SetAttributes(AttrDuplicate.Bool(true))
+		span.End()
+		return nil
+	}
+
+	// Replies to questions skip the chat's queue, where the handler asking
+	// the question blocks later messages, but still pass through middleware
+	if r.awaiting(msg) {
+		err := r.process(ctx, msg, true)
+		EndSpan(span, err)
+		return err
+	}
+
+	if r.dispatcher == nil {
+		err := r.process(ctx, msg, false)
+		EndSpan(span, err)
+		return err
+	}
+
+	// Detach from the adapter callback, which may end before processing
+	ctx = context.WithoutCancel(ctx)
+	r.dispatcher.enqueue(chatKey(msg), func() {
+		EndSpan(span, r.process(ctx, msg, false))
+	})
+	return nil
+}
+
+// process runs middleware and handlers for a message. An answer is handed
+// to the question awaiting it once middleware lets it through. Processing is
+// canceled if Shutdown gives up waiting for it.
+func (r *Router) process(ctx context.Context, msg IncomingMessage, answer bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(r.lifecycle.aborted, cancel)
+	defer stop()
+	r.recordIncoming(ctx, msg)
+
 	r.mu.RLock()
 	handlers := make([]RouteHandler, len(r.handlers))
 	copy(handlers, r.handlers)
+	middleware := make([]Middleware, len(r.middleware))
+	copy(middleware, r.middleware)
 	r.mu.RUnlock()
 
+	next := func(ctx context.Context, msg IncomingMessage) error {
+		// The question may have timed out while middleware ran
+		if answer && r.answer(msg) {
+			return nil
+		}
+		return r.dispatch(ctx, handlers, msg)
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	return next(ctx, msg)
+}
+
+// dispatch invokes the handlers matching a message.
+func (r *Router) dispatch(ctx context.Context, handlers []RouteHandler, msg IncomingMessage) error {
+	ctx, span := r.tracer.Start(ctx, SpanRoute)
+	defer span.End()
+
+	ctx = WithAsker(WithMessage(ctx, msg), r.asker(msg))
+	ctx = withScheduler(ctx, r.scheduler(ctx, msg))
+	matched := 0
 	for _, h := range handlers {
 		if matchPattern(h.Pattern, msg) {
-			if err := h.Handler(ctx, msg); err != nil {
-				r.logger.Error("handler error",
-					"channel", msg.ChannelName,
-					"chat", msg.ChatID,
-					"error", err)
-				// Continue to other handlers
+			matched++
+			// Errors are handled by policy; continue to other handlers
+			r.runHandler(ctx, h, msg)
+			if h.Exclusive {
+				break
 			}
 		}
 	}
+	span.SetAttributes(AttrHandlers.Int(matched))
+	r.publish(events.MessageRouted{
+		Channel:   msg.ChannelName,
+		ChatID:    msg.ChatID,
+		MessageID: msg.ID,
+		Handlers:  matched,
+		Time:      time.Now(),
+	})
 	return nil
 }
 
+// countSent records a send attempt's outcome and returns err.
+func (r *Router) countSent(channel Channel, err error) error {
+	if r.options.metrics == nil {
+		return err
+	}
+	name := "messages_sent"
+	if err != nil {
+		name = "send_errors"
+	}
+	r.options.metrics.Counter(name, metrics.Labels{"channel": channel.Name()}).Inc()
+	return err
+}
+
+// observeAgent records agent latency.
+func (r *Router) observeAgent(start time.Time) {
+	if r.options.metrics != nil {
+		r.options.metrics.Timer("agent_latency", nil).Since(start)
+	}
+}
+
+// agentFailed records an agent failure.
+func (r *Router) agentFailed(name, sessionID string, msg IncomingMessage, err error) {
+	if r.options.metrics != nil {
+		r.options.metrics.Counter("agent_errors", nil).Inc()
+	}
+	r.publish(events.AgentFailed{
+		Channel:   msg.ChannelName,
+		ChatID:    msg.ChatID,
+		MessageID: msg.ID,
+		Agent:     name,
+		SessionID: sessionID,
+		Err:       err,
+		Time:      time.Now(),
+	})
+}
+
+// publish publishes a lifecycle event if an event bus is configured.
+func (r *Router) publish(e events.Event) {
+	if r.options.events != nil {
+		r.options.events.Publish(e)
+	}
+}
+
+// countHandlerError records a handler failure that retries did not resolve.
+func (r *Router) countHandlerError(channelName string) {
+	if r.options.metrics != nil {
+		r.options.metrics.Counter("handler_errors", metrics.Labels{"channel": channelName}).Inc()
+	}
+}
+
+// Matches reports whether a message matches the pattern.
+func (p RoutePattern) Matches(msg IncomingMessage) bool {
+	return matchPattern(p, msg)
+}
+
 // matchPattern checks if a message matches a route pattern.
 func matchPattern(pattern RoutePattern, msg IncomingMessage) bool {
 	// Check channel filter
@@ -273,6 +978,16 @@ func matchPattern(pattern RoutePattern, msg IncomingMessage) bool {
 		}
 	}
 
+	// Check sender filter
+	if len(pattern.Senders) > 0 && !contains(pattern.Senders, msg.SenderID) {
+		return false
+	}
+
+	// Check chat filter
+	if len(pattern.Chats) > 0 && !contains(pattern.Chats, msg.ChatID) {
+		return false
+	}
+
 	// Check prefix filter
 	if pattern.Prefix != "" {
 		if len(msg.Content) < len(pattern.Prefix) {
@@ -283,9 +998,23 @@ func matchPattern(pattern RoutePattern, msg IncomingMessage) bool {
 		}
 	}
 
+	if pattern.Match != nil && !pattern.Match(msg) {
+		return false
+	}
+
 	return true
 }
 
+// contains reports whether values contains v.
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
 // All returns a pattern that matches all messages.
 func All() RoutePattern {
 	return RoutePattern{}
@@ -296,6 +1025,29 @@ func FromChannels(channels ...string) RoutePattern {
 	return RoutePattern{Channels: channels}
 }
 
+// FromSenders returns a pattern that matches messages from specific sender IDs.
+func FromSenders(senders ...string) RoutePattern {
+	return RoutePattern{Senders: senders}
+}
+
+// InChats returns a pattern that matches messages in specific chat IDs.
+func InChats(chats ...string) RoutePattern {
+	return RoutePattern{Chats: chats}
+}
+
+// Where returns a pattern that matches messages for which fn returns true.
+func Where(fn func(msg IncomingMessage) bool) RoutePattern {
+	return RoutePattern{Match: fn}
+}
+
+// Mentioned returns a pattern that matches direct messages and messages that
+// mention or reply to the bot.
+func Mentioned() RoutePattern {
+	return Where(func(msg IncomingMessage) bool {
+		return msg.MentionsBot || msg.ChatType == ChannelTypeDM
+	})
+}
+
 // DMOnly returns a pattern that matches only DM messages.
 func DMOnly() RoutePattern {
 	return RoutePattern{ChatTypes: []ChannelType{ChannelTypeDM}}
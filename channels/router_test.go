@@ -0,0 +1,367 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockChannel is an in-memory channel for testing.
+type mockChannel struct {
+	name    string
+	handler MessageHandler
+	events  EventHandler
+	sent    []OutgoingMessage
+	mu      sync.Mutex
+}
+
+func newMockChannel(name string) *mockChannel {
+	return &mockChannel{name: name}
+}
+
+func (m *mockChannel) Name() string                         { return m.name }
+func (m *mockChannel) Connect(ctx context.Context) error    { return nil }
+func (m *mockChannel) Disconnect(ctx context.Context) error { return nil }
+func (m *mockChannel) OnMessage(handler MessageHandler)     { m.handler = handler }
+func (m *mockChannel) OnEvent(handler EventHandler)         { m.events = handler }
+func (m *mockChannel) deliver(msg IncomingMessage) error    { return m.handler(context.Background(), msg) }
+
+func (m *mockChannel) Send(ctx context.Context, chatID string, msg OutgoingMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// deliverAndWait delivers a message through a mock channel and waits for the
+// router to process it.
+func deliverAndWait(r *Router, ch interface{ deliver(IncomingMessage) error }, msg IncomingMessage) error {
+	if err := ch.deliver(msg); err != nil {
+		return err
+	}
+	return r.Drain(context.Background())
+}
+
+func (m *mockChannel) sentMessages() []OutgoingMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]OutgoingMessage, len(m.sent))
+	copy(out, m.sent)
+	return out
+}
+
+// mockStreamingChannel collects streamed chunks.
+type mockStreamingChannel struct {
+	*mockChannel
+	chunks []string
+}
+
+func (m *mockStreamingChannel) SendTyping(context.Context, string) error { return nil }
+
+func (m *mockStreamingChannel) SendStream(ctx context.Context, chatID string, chunks <-chan string) error {
+	for c := range chunks {
+		m.chunks = append(m.chunks, c)
+	}
+	return nil
+}
+
+// mockAgent echoes content, optionally as a stream of words.
+type mockAgent struct{}
+
+func (mockAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return "echo: " + content, nil
+}
+
+type mockStreamingAgent struct{ mockAgent }
+
+func (mockStreamingAgent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan Chunk, error) {
+	out := make(chan Chunk)
+	go func() {
+		defer close(out)
+		for _, w := range strings.Fields(content) {
+			select {
+			case out <- Chunk{Content: w + " "}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestProcessWithAgentStreaming(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &mockStreamingChannel{mockChannel: newMockChannel("test")}
+	router.Register(ch)
+	router.SetAgent(mockStreamingAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", Content: "one two three"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	if got := strings.Join(ch.chunks, ""); got != "one two three " {
+		t.Errorf("streamed = %q, want %q", got, "one two three ")
+	}
+	if len(ch.sentMessages()) != 0 {
+		t.Errorf("expected no non-streamed sends, got %d", len(ch.sentMessages()))
+	}
+}
+
+// replyStreamingChannel records the message each stream replies to.
+type replyStreamingChannel struct {
+	*mockStreamingChannel
+	replyTo []string
+}
+
+func (m *replyStreamingChannel) SendStreamReply(ctx context.Context, chatID, replyTo string, chunks <-chan string) error {
+	m.replyTo = append(m.replyTo, replyTo)
+	return m.SendStream(ctx, chatID, chunks)
+}
+
+func TestProcessWithAgentStreamingReply(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &replyStreamingChannel{mockStreamingChannel: &mockStreamingChannel{mockChannel: newMockChannel("test")}}
+	router.Register(ch)
+	router.SetAgent(mockStreamingAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	for _, id := range []string{"1", "2"} {
+		if err := deliverAndWait(router, ch, IncomingMessage{ID: id, ChannelName: "test", ChatID: "c1", Content: "hi"}); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+	if len(ch.replyTo) != 2 || ch.replyTo[0] != "1" || ch.replyTo[1] != "2" {
+		t.Errorf("streams replied to %v, want each its own message", ch.replyTo)
+	}
+}
+
+// brokenStreamingChannel fails streams without reading them.
+type brokenStreamingChannel struct {
+	*mockStreamingChannel
+}
+
+func (brokenStreamingChannel) SendStream(context.Context, string, <-chan string) error {
+	return errors.New("stream failed")
+}
+
+// endlessStreamingAgent streams until its context is canceled.
+type endlessStreamingAgent struct {
+	mockAgent
+	stopped chan struct{}
+}
+
+func (a endlessStreamingAgent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan Chunk, error) {
+	out := make(chan Chunk)
+	go func() {
+		defer close(a.stopped)
+		defer close(out)
+		for {
+			select {
+			case out <- Chunk{Content: "more "}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestProcessWithAgentStreamingCancelsAgent(t *testing.T) {
+	router := NewRouter(nil)
+	ch := brokenStreamingChannel{&mockStreamingChannel{mockChannel: newMockChannel("test")}}
+	router.Register(ch)
+	agent := endlessStreamingAgent{stopped: make(chan struct{})}
+	router.SetAgent(agent)
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", Content: "go"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	select {
+	case <-agent.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("agent stream not canceled after the channel failed")
+	}
+}
+
+func TestProcessWithAgentNonStreamingChannel(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+	router.SetAgent(mockStreamingAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", Content: "hi"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	sent := ch.sentMessages()
+	if len(sent) != 1 || sent[0].Content != "echo: hi" {
+		t.Fatalf("sent = %+v, want single echo reply", sent)
+	}
+	if sent[0].ReplyTo != "1" {
+		t.Errorf("ReplyTo = %s, want 1", sent[0].ReplyTo)
+	}
+}
+
+func TestMatchPatternSendersAndChats(t *testing.T) {
+	msg := IncomingMessage{ChannelName: "telegram", ChatID: "room1", SenderID: "alice", Content: "/deploy"}
+
+	tests := []struct {
+		name    string
+		pattern RoutePattern
+		want    bool
+	}{
+		{"all", All(), true},
+		{"sender match", FromSenders("bob", "alice"), true},
+		{"sender mismatch", FromSenders("bob"), false},
+		{"chat match", InChats("room1"), true},
+		{"chat mismatch", InChats("room2"), false},
+		{"combined", RoutePattern{Senders: []string{"alice"}, Chats: []string{"room1"}, Prefix: "/deploy"}, true},
+		{"combined mismatch", RoutePattern{Senders: []string{"alice"}, Chats: []string{"room2"}}, false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, msg); got != tt.want {
+			t.Errorf("%s: matchPattern = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	var order []string
+	router.Use(
+		func(next MessageHandler) MessageHandler {
+			return func(ctx context.Context, msg IncomingMessage) error {
+				order = append(order, "first")
+				msg.Content = strings.ToUpper(msg.Content)
+				return next(ctx, msg)
+			}
+		},
+		func(next MessageHandler) MessageHandler {
+			return func(ctx context.Context, msg IncomingMessage) error {
+				order = append(order, "second")
+				if msg.Content == "DROP" {
+					return nil
+				}
+				return next(ctx, msg)
+			}
+		},
+	)
+
+	var got []string
+	router.OnMessage(Where(func(msg IncomingMessage) bool { return msg.Content != "" }), func(ctx context.Context, msg IncomingMessage) error {
+		got = append(got, msg.Content)
+		return nil
+	})
+
+	for _, content := range []string{"hello", "drop"} {
+		if err := deliverAndWait(router, ch, IncomingMessage{ChannelName: "test", Content: content}); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+
+	if strings.Join(order, ",") != "first,second,first,second" {
+		t.Errorf("middleware order = %v", order)
+	}
+	if len(got) != 1 || got[0] != "HELLO" {
+		t.Errorf("handled = %v, want [HELLO]", got)
+	}
+}
+
+func TestRouterPriorityAndExclusive(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	var calls []string
+	record := func(name string) MessageHandler {
+		return func(ctx context.Context, msg IncomingMessage) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	router.OnMessage(All(), record("fallback"))
+	router.AddHandler(RouteHandler{Pattern: RoutePattern{Prefix: "/"}, Handler: record("command"), Priority: 10, Exclusive: true})
+	router.AddHandler(RouteHandler{Pattern: All(), Handler: record("audit"), Priority: 20})
+
+	for _, content := range []string{"/help", "hello"} {
+		calls = nil
+		if err := deliverAndWait(router, ch, IncomingMessage{ChannelName: "test", Content: content}); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+		want := map[string]string{
+			"/help": "audit,command",
+			"hello": "audit,fallback",
+		}[content]
+		if got := strings.Join(calls, ","); got != want {
+			t.Errorf("%s: calls = %s, want %s", content, got, want)
+		}
+	}
+}
+
+func TestRouterOnEvent(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	var mu sync.Mutex
+	var got []EventType
+	router.OnEvent(func(ctx context.Context, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event.Type)
+		return nil
+	}, EventTypeMessageDeleted)
+
+	for _, typ := range []EventType{EventTypeTyping, EventTypeMessageDeleted} {
+		if err := ch.events(context.Background(), Event{Type: typ, ChannelName: "test", ChatID: "1"}); err != nil {
+			t.Fatalf("event handler failed: %v", err)
+		}
+	}
+	if err := router.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != EventTypeMessageDeleted {
+		t.Errorf("events = %v, want [message_deleted]", got)
+	}
+}
+
+func TestSendRendersMentions(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("discord")
+	router.Register(ch)
+
+	if err := router.Send(context.Background(), "discord", "1", OutgoingMessage{Content: "hi <@envoy:user:42>"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if sent := ch.sentMessages(); len(sent) != 1 || sent[0].Content != "hi <@42>" {
+		t.Errorf("sent = %+v, want rendered Discord mention", sent)
+	}
+}
+
+func TestRouterRendersMarkdown(t *testing.T) {
+	router := NewRouter(nil)
+	ch := newMockChannel("telegram")
+	router.Register(ch)
+
+	msg := OutgoingMessage{Content: "**Done.** See `x`", Format: MessageFormatMarkdown}
+	if err := router.Send(context.Background(), "telegram", "1", msg); err != nil {
+		t.Fatal(err)
+	}
+	sent := ch.sentMessages()
+	if len(sent) != 1 || sent[0].Content != "*Done\\.* See `x`" || sent[0].Format != MessageFormatMarkdownV2 {
+		t.Errorf("sent = %+v", sent)
+	}
+}
@@ -0,0 +1,46 @@
+package channels
+
+import "context"
+
+// SessionConfig overrides agent settings for one session, such as a
+// customer's preferred language or a model chosen by an operator. Unset
+// fields keep the agent's configuration.
+type SessionConfig struct {
+	SystemPrompt string   `json:"system_prompt,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+
+	// Language is the language the agent should reply in, such as "French"
+	// or "de".
+	Language string `json:"language,omitempty"`
+}
+
+// IsZero reports whether c overrides nothing.
+func (c SessionConfig) IsZero() bool {
+	return c.SystemPrompt == "" && c.Model == "" && c.Temperature == nil && c.Language == ""
+}
+
+// SessionConfigResolver is a SessionResolver that also returns the
+// overrides of the session it resolves. The router passes them to agents
+// with WithSessionConfig.
+type SessionConfigResolver interface {
+	SessionResolver
+
+	// ResolveSession returns the session ID and configuration for msg.
+	ResolveSession(ctx context.Context, msg IncomingMessage) (string, SessionConfig, error)
+}
+
+type sessionConfigKey struct{}
+
+// WithSessionConfig returns a context carrying the configuration of the
+// session being processed.
+func WithSessionConfig(ctx context.Context, config SessionConfig) context.Context {
+	return context.WithValue(ctx, sessionConfigKey{}, config)
+}
+
+// SessionConfigFromContext returns the configuration of the session being
+// processed, so agents can apply it; the zero SessionConfig if none is set.
+func SessionConfigFromContext(ctx context.Context) SessionConfig {
+	config, _ := ctx.Value(sessionConfigKey{}).(SessionConfig)
+	return config
+}
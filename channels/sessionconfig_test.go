@@ -0,0 +1,41 @@
+package channels
+
+import (
+	"context"
+	"testing"
+)
+
+// configResolver gives every message the same session and configuration.
+type configResolver struct {
+	config SessionConfig
+}
+
+func (r configResolver) SessionID(ctx context.Context, msg IncomingMessage) (string, error) {
+	return "s1", nil
+}
+
+func (r configResolver) ResolveSession(ctx context.Context, msg IncomingMessage) (string, SessionConfig, error) {
+	return "s1", r.config, nil
+}
+
+// configAgent replies with the language of its session configuration.
+type configAgent struct{}
+
+func (configAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return sessionID + " " + SessionConfigFromContext(ctx).Language, nil
+}
+
+func TestRouterPassesSessionConfig(t *testing.T) {
+	router := NewRouter(nil, WithSessions(configResolver{SessionConfig{Language: "French"}}))
+	ch := newMockChannel("test")
+	router.Register(ch)
+	router.SetAgent(configAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", Content: "hi"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if sent := ch.sentMessages(); len(sent) != 1 || sent[0].Content != "s1 French" {
+		t.Errorf("sent = %+v, want the session's language", sent)
+	}
+}
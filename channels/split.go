@@ -0,0 +1,298 @@
+package channels
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/agentplexus/envoy/markup"
+)
+
+// Capabilities describes the limits of a messaging platform.
+type Capabilities struct {
+	// MaxMessageLength is the maximum length of a text message, as
+	// measured by MessageLength (0 = unlimited). The router splits longer
+	// messages.
+	MaxMessageLength int
+
+	// MessageLength measures text against MaxMessageLength (default:
+	// characters, as utf8.RuneCountInString).
+	MessageLength LengthFunc
+
+	// Components reports support for OutgoingMessage.Components.
+	Components bool
+
+	// Stickers reports support for sticker media. Stickers sent to other
+	// channels are replaced by their emoji or name.
+	Stickers bool
+
+	// CustomEmoji reports support for custom emoji written as <:name:id>.
+	// Other channels show them as :name:.
+	CustomEmoji bool
+
+	// ReplyThreads reports that Threader.CreateThread starts threads on
+	// the message the first message replies to.
+	ReplyThreads bool
+}
+
+// messageLength returns the length of s against MaxMessageLength.
+func (c Capabilities) messageLength(s string) int {
+	if c.MessageLength == nil {
+		return utf8.RuneCountInString(s)
+	}
+	return c.MessageLength(s)
+}
+
+// LengthFunc measures text against a platform's message length limit.
+type LengthFunc func(s string) int
+
+// UTF16Length returns the number of UTF-16 code units in s, in which
+// Telegram counts its limits: characters outside the Basic Multilingual
+// Plane, such as most emoji, count twice.
+func UTF16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// CapabilityReporter extends Channel with the limits of its platform.
+type CapabilityReporter interface {
+	Channel
+
+	// Capabilities returns the platform's limits.
+	Capabilities() Capabilities
+}
+
+// ContinuationMarker returns the text appended to part of total parts of a
+// split message, including its separator.
+type ContinuationMarker func(part, total int) string
+
+// DefaultContinuationMarker numbers the parts of a split message, e.g.
+// "(2/3)" on a line of its own.
+func DefaultContinuationMarker(part, total int) string {
+	return fmt.Sprintf("\n(%d/%d)", part, total)
+}
+
+// minSplitBudget is the smallest part length markers may leave.
+const minSplitBudget = 16
+
+// fenceClose closes a code block interrupted by a split.
+const fenceClose = "\n```"
+
+// SplitMessage splits content into parts of at most limit characters,
+// preferring to cut between paragraphs and code blocks, then between
+// lines, sentences, and words. Code blocks cut in two are closed at the end
+// of the first part and reopened at the start of the next. Each part ends
+// with its marker unless marker is nil. Content within the limit is
+// returned as is.
+func SplitMessage(content string, limit int, marker ContinuationMarker) []string {
+	return splitMessage(content, limit, Capabilities{}.messageLength, marker)
+}
+
+// splitMessage splits content like SplitMessage, measuring lengths with
+// length.
+func splitMessage(content string, limit int, length LengthFunc, marker ContinuationMarker) []string {
+	if limit <= 0 || length(content) <= limit {
+		return []string{content}
+	}
+
+	var parts []string
+	for total := 2; ; {
+		budget := limit
+		if marker != nil {
+			budget -= length(marker(total, total))
+		}
+		if budget < minSplitBudget {
+			budget, marker = limit, nil
+		}
+		parts = splitRunes([]rune(content), budget, length)
+		if marker == nil || len(parts) <= total {
+			break
+		}
+		total = len(parts)
+	}
+
+	if marker != nil {
+		for i := range parts {
+			parts[i] += marker(i+1, len(parts))
+		}
+	}
+	return parts
+}
+
+// splitRunes cuts text into parts of at most budget, measured by length.
+func splitRunes(text []rune, budget int, length LengthFunc) []string {
+	var parts []string
+	for length(string(text)) > budget {
+		window := fitRunes(text, budget-len(fenceClose), length)
+		cut, fence := cutPoint(text, window)
+		part := string(text[:cut])
+		rest := text[cut:]
+		if fence != "" {
+			part = strings.TrimRight(part, "\n") + fenceClose
+			if utf8.RuneCountInString(fence)+1 < window {
+				rest = append([]rune(fence+"\n"), rest...)
+			}
+		} else {
+			part = strings.TrimRight(part, " \t\n")
+			for len(rest) > 0 && rest[0] == '\n' {
+				rest = rest[1:]
+			}
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+		text = rest
+	}
+	if len(text) > 0 {
+		parts = append(parts, string(text))
+	}
+	return parts
+}
+
+// fitRunes returns how many runes from the start of text fit in budget,
+// measured by length.
+func fitRunes(text []rune, budget int, length LengthFunc) int {
+	n, used := 0, 0
+	for n < len(text) {
+		used += length(string(text[n]))
+		if used > budget {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// Cut preferences, best first.
+const (
+	cutBlock    = iota // between paragraphs or code blocks
+	cutLine            // between lines
+	cutCodeLine        // between lines of a code block
+	cutSentence        // after a sentence
+	cutWord            // between words
+	cutKinds
+)
+
+// cutPoint returns where to cut text so the first part has at most window
+// runes, and the opening line of the code block the cut falls in, if any.
+func cutPoint(text []rune, window int) (int, string) {
+	var (
+		best   [cutKinds]int
+		open   [cutKinds]string
+		fence  string
+		body   int // start of the open code block's first line
+		closed bool
+	)
+	record := func(kind, at int) {
+		best[kind], open[kind] = at, fence
+	}
+
+	limit := min(window, len(text))
+	for i := 0; i <= limit; i++ {
+		if i == 0 || text[i-1] == '\n' {
+			// Start of a line
+			line := text[i:]
+			wasClosed := closed
+			closed = false
+			isFence := len(line) >= 3 && string(line[:3]) == "```"
+			if i > 0 {
+				switch {
+				case fence != "":
+					// Keep a line of the block, so reopening it makes progress
+					if i > body {
+						record(cutCodeLine, i)
+					}
+				case wasClosed, isFence, i > 1 && text[i-2] == '\n':
+					record(cutBlock, i)
+				default:
+					record(cutLine, i)
+				}
+			}
+			if isFence {
+				if fence == "" {
+					end := len(line)
+					for j, r := range line {
+						if r == '\n' {
+							end = j
+							break
+						}
+					}
+					fence, body = string(line[:end]), i+end+1
+				} else {
+					fence, closed = "", true
+				}
+			}
+		}
+		if i > 1 && text[i-1] == ' ' && fence == "" {
+			if p := text[i-2]; p == '.' || p == '!' || p == '?' {
+				record(cutSentence, i)
+			} else {
+				record(cutWord, i)
+			}
+		}
+	}
+
+	// Prefer the best kind of cut that keeps at least half the window
+	for kind := range cutKinds {
+		if best[kind] >= window/2 {
+			return best[kind], open[kind]
+		}
+	}
+	at, kind := 0, 0
+	for k := range cutKinds {
+		if best[k] > at {
+			at, kind = best[k], k
+		}
+	}
+	if at > 0 {
+		return at, open[kind]
+	}
+	return limit, fence
+}
+
+// splitOutgoing splits a message too long for channel into several, with
+// the reply on the first and the media and components on the last. Markers
+// are escaped for the message's format.
+func splitOutgoing(channel Channel, msg OutgoingMessage, marker ContinuationMarker) []OutgoingMessage {
+	reporter, ok := channel.(CapabilityReporter)
+	if !ok || msg.Raw != nil {
+		return []OutgoingMessage{msg}
+	}
+	if marker != nil {
+		plain := marker
+		marker = func(part, total int) string {
+			return markup.Escape(markup.Format(msg.Format), plain(part, total))
+		}
+	}
+	caps := reporter.Capabilities()
+	parts := splitMessage(msg.Content, caps.MaxMessageLength, caps.messageLength, marker)
+	if len(parts) == 1 {
+		return []OutgoingMessage{msg}
+	}
+	messages := make([]OutgoingMessage, len(parts))
+	for i, content := range parts {
+		part := msg
+		part.Content = content
+		if i > 0 {
+			part.ReplyTo = ""
+		}
+		if i < len(parts)-1 {
+			part.Media, part.Components = nil, nil
+		}
+		messages[i] = part
+	}
+	return messages
+}
+
+// capabilities returns the capabilities of channel; none if it does not
+// report them.
+func capabilities(channel Channel) Capabilities {
+	if reporter, ok := channel.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return Capabilities{}
+}
@@ -0,0 +1,182 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	t.Run("short", func(t *testing.T) {
+		parts := SplitMessage("hello", 10, DefaultContinuationMarker)
+		if len(parts) != 1 || parts[0] != "hello" {
+			t.Errorf("parts = %q", parts)
+		}
+	})
+
+	t.Run("paragraphs", func(t *testing.T) {
+		content := strings.Repeat("a", 30) + "\n\n" + strings.Repeat("b", 30) + " tail words here"
+		parts := SplitMessage(content, 50, nil)
+		if len(parts) != 2 || parts[0] != strings.Repeat("a", 30) || !strings.HasPrefix(parts[1], "bbb") {
+			t.Errorf("parts = %q", parts)
+		}
+	})
+
+	t.Run("words", func(t *testing.T) {
+		content := strings.Repeat("word ", 40)
+		parts := SplitMessage(content, 50, DefaultContinuationMarker)
+		for i, part := range parts {
+			if n := utf8.RuneCountInString(part); n > 50 {
+				t.Errorf("part %d has %d characters", i, n)
+			}
+			body, _, _ := strings.Cut(part, "\n(")
+			if strings.HasSuffix(body, "wor") || strings.HasPrefix(body, "d") {
+				t.Errorf("part %d cuts a word: %q", i, part)
+			}
+		}
+		if last := parts[len(parts)-1]; !strings.HasSuffix(last, "/"+string(rune('0'+len(parts)))+")") {
+			t.Errorf("last part %q lacks marker", last)
+		}
+	})
+
+	t.Run("code fence", func(t *testing.T) {
+		var code strings.Builder
+		for range 10 {
+			code.WriteString("fmt.Println(\"line\")\n")
+		}
+		content := "Here is the code:\n```go\n" + code.String() + "```\nDone."
+		parts := SplitMessage(content, 120, nil)
+		if len(parts) < 2 {
+			t.Fatalf("parts = %q", parts)
+		}
+		for i, part := range parts {
+			if n := utf8.RuneCountInString(part); n > 120 {
+				t.Errorf("part %d has %d characters", i, n)
+			}
+			if strings.Count(part, "```")%2 != 0 {
+				t.Errorf("part %d has an unbalanced fence: %q", i, part)
+			}
+		}
+		if !strings.HasPrefix(parts[1], "```go\n") {
+			t.Errorf("second part does not reopen the block: %q", parts[1])
+		}
+		if strings.Count(strings.Join(parts, ""), "Println") != 10 {
+			t.Errorf("lines lost: %q", parts)
+		}
+	})
+
+	t.Run("utf-16", func(t *testing.T) {
+		// Each emoji is one rune but two UTF-16 code units
+		content := strings.Repeat("😀 ", 30)
+		if parts := SplitMessage(content, 60, nil); len(parts) != 1 {
+			t.Errorf("rune parts = %q, want one", parts)
+		}
+		parts := splitMessage(content, 60, UTF16Length, DefaultContinuationMarker)
+		if len(parts) < 2 {
+			t.Fatalf("parts = %q", parts)
+		}
+		for i, part := range parts {
+			if n := UTF16Length(part); n > 60 {
+				t.Errorf("part %d has %d code units", i, n)
+			}
+		}
+		if n := strings.Count(strings.Join(parts, ""), "😀"); n != 30 {
+			t.Errorf("emoji = %d, want 30", n)
+		}
+	})
+
+	t.Run("no boundaries", func(t *testing.T) {
+		parts := SplitMessage(strings.Repeat("x", 100), 40, nil)
+		if strings.Join(parts, "") != strings.Repeat("x", 100) {
+			t.Errorf("parts = %q", parts)
+		}
+	})
+}
+
+// limitedChannel is a mock channel with a message length limit.
+type limitedChannel struct {
+	*mockChannel
+}
+
+func (c *limitedChannel) Capabilities() Capabilities {
+	return Capabilities{MaxMessageLength: 40}
+}
+
+func TestUTF16Length(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"hello", 5},
+		{"héllo", 5},
+		{"😀", 2},
+		{"a😀b𝄞", 6},
+	}
+	for _, tt := range tests {
+		if got := UTF16Length(tt.in); got != tt.want {
+			t.Errorf("UTF16Length(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRouterSplitsLongMessages(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &limitedChannel{newMockChannel("limited")}
+	router.Register(ch)
+
+	msg := OutgoingMessage{
+		Content: strings.Repeat("Sentence number one. ", 5),
+		ReplyTo: "7",
+		Media:   []Media{{Type: MediaTypeImage, URL: "https://example.com/a.png"}},
+	}
+	if err := router.Send(context.Background(), "limited", "chat", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := ch.sentMessages()
+	if len(sent) < 2 {
+		t.Fatalf("sent %d messages, want several", len(sent))
+	}
+	for i, part := range sent {
+		if n := utf8.RuneCountInString(part.Content); n > 40 {
+			t.Errorf("part %d has %d characters", i, n)
+		}
+		if (part.ReplyTo != "") != (i == 0) || (len(part.Media) > 0) != (i == len(sent)-1) {
+			t.Errorf("part %d = %+v", i, part)
+		}
+	}
+	if !strings.HasSuffix(sent[0].Content, "(1/"+string(rune('0'+len(sent)))+")") {
+		t.Errorf("first part %q lacks marker", sent[0].Content)
+	}
+}
+
+func TestRouterSplitEscapesMarker(t *testing.T) {
+	router := NewRouter(nil)
+	ch := &limitedChannel{newMockChannel("telegram")}
+	router.Register(ch)
+
+	msg := OutgoingMessage{
+		Content: strings.Repeat("Sentence number one. ", 5),
+		Format:  MessageFormatMarkdown,
+	}
+	if err := router.Send(context.Background(), "telegram", "chat", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := ch.sentMessages()
+	if len(sent) < 2 {
+		t.Fatalf("sent %d messages, want several", len(sent))
+	}
+	for i, part := range sent {
+		if part.Format != MessageFormatMarkdownV2 {
+			t.Errorf("part %d format = %q", i, part.Format)
+		}
+		if n := utf8.RuneCountInString(part.Content); n > 40 {
+			t.Errorf("part %d has %d characters", i, n)
+		}
+		if !strings.Contains(part.Content, `\(`+string(rune('1'+i))+"/") {
+			t.Errorf("part %d %q lacks an escaped marker", i, part.Content)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package channels
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StickerSet maps response names (e.g., "thumbs_up", "celebrate") to
+// per-channel sticker or animation media, so handlers can reply with a
+// sticker without knowing each platform's file IDs.
+type StickerSet struct {
+	mu      sync.RWMutex
+	entries map[string]map[string]Media
+}
+
+// NewStickerSet creates an empty sticker set.
+func NewStickerSet() *StickerSet {
+	return &StickerSet{entries: make(map[string]map[string]Media)}
+}
+
+// Add maps a response name to media for a channel. An empty channel name
+// registers a fallback used for channels without their own entry.
+func (s *StickerSet) Add(name, channel string, media Media) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[name] == nil {
+		s.entries[name] = make(map[string]Media)
+	}
+	s.entries[name][channel] = media
+}
+
+// Get returns the media for a response name on a channel.
+func (s *StickerSet) Get(name, channel string) (Media, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	byChannel := s.entries[name]
+	if m, ok := byChannel[channel]; ok {
+		return m, true
+	}
+	m, ok := byChannel[""]
+	return m, ok
+}
+
+// Message builds an outgoing message sending the named sticker on a channel.
+func (s *StickerSet) Message(name, channel string) (OutgoingMessage, bool) {
+	m, ok := s.Get(name, channel)
+	if !ok {
+		return OutgoingMessage{}, false
+	}
+	return OutgoingMessage{Media: []Media{m}}, true
+}
+
+// Names returns the registered response names in sorted order.
+func (s *StickerSet) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StickerFormat is the format of a sticker.
+type StickerFormat string
+
+const (
+	StickerStatic   StickerFormat = "static"
+	StickerAnimated StickerFormat = "animated"
+	StickerVideo    StickerFormat = "video"
+)
+
+// Sticker describes sticker media, which Media.FileID identifies: by
+// Telegram file ID or Discord sticker ID.
+type Sticker struct {
+	// Name is the sticker's name, on platforms that name stickers.
+	Name string
+
+	// SetName is the set (pack) the sticker belongs to, if known.
+	SetName string
+
+	// Emoji is the emoji the sticker stands for. Channels without
+	// stickers show it instead.
+	Emoji string
+
+	Format StickerFormat
+
+	// CustomEmojiID is set for Telegram custom emoji stickers.
+	CustomEmojiID string
+}
+
+// StickerMedia returns media sending the sticker with a platform ID; emoji
+// is shown on channels without stickers.
+func StickerMedia(id, emoji string) Media {
+	return Media{Type: MediaTypeSticker, FileID: id, Sticker: &Sticker{Emoji: emoji}}
+}
+
+// stickerText returns the text shown for a sticker: its emoji, or its name
+// as :name:.
+func stickerText(m Media) string {
+	switch {
+	case m.Sticker == nil:
+		return ""
+	case m.Sticker.Emoji != "":
+		return m.Sticker.Emoji
+	case m.Sticker.Name != "":
+		return ":" + m.Sticker.Name + ":"
+	}
+	return ""
+}
+
+// stickersText replaces the stickers of a message with their text.
+func stickersText(msg OutgoingMessage) OutgoingMessage {
+	var media []Media
+	var text []string
+	for _, m := range msg.Media {
+		if m.Type != MediaTypeSticker {
+			media = append(media, m)
+			continue
+		}
+		if t := stickerText(m); t != "" {
+			text = append(text, t)
+		}
+	}
+	if len(media) == len(msg.Media) {
+		return msg
+	}
+	msg.Media = media
+	msg.Content = strings.TrimSpace(msg.Content + " " + strings.Join(text, " "))
+	return msg
+}
+
+// CustomEmoji is a custom emoji used in a message.
+type CustomEmoji struct {
+	// ID is the platform's emoji ID.
+	ID string
+
+	// Name is the emoji's name on Discord, or the standard emoji it
+	// stands for on Telegram.
+	Name string
+
+	Animated bool
+}
+
+// customEmojiPattern matches custom emoji written as <:name:id>, or
+// <a:name:id> for animated ones.
+var customEmojiPattern = regexp.MustCompile(`<(a?):(\w+):(\d+)>`)
+
+// ParseCustomEmoji returns the custom emoji written as <:name:id> in
+// content, as Discord writes them.
+func ParseCustomEmoji(content string) []CustomEmoji {
+	var out []CustomEmoji
+	for _, m := range customEmojiPattern.FindAllStringSubmatch(content, -1) {
+		out = append(out, CustomEmoji{ID: m[3], Name: m[2], Animated: m[1] == "a"})
+	}
+	return out
+}
+
+// customEmojiText replaces custom emoji written as <:name:id> with :name:.
+func customEmojiText(content string) string {
+	if !strings.Contains(content, "<") {
+		return content
+	}
+	return customEmojiPattern.ReplaceAllString(content, ":$2:")
+}
@@ -0,0 +1,70 @@
+package channels
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStickerSet(t *testing.T) {
+	s := NewStickerSet()
+	s.Add("thumbs_up", "telegram", Media{Type: MediaTypeSticker, FileID: "CAACAgIAAxkBAAE"})
+	s.Add("thumbs_up", "", Media{Type: MediaTypeAnimation, URL: "https://example.com/thumbs.gif"})
+
+	msg, ok := s.Message("thumbs_up", "telegram")
+	if !ok || len(msg.Media) != 1 || msg.Media[0].FileID != "CAACAgIAAxkBAAE" {
+		t.Errorf("telegram message = %+v, want sticker", msg)
+	}
+	m, ok := s.Get("thumbs_up", "discord")
+	if !ok || m.Type != MediaTypeAnimation {
+		t.Errorf("discord media = %+v, want fallback animation", m)
+	}
+	if _, ok := s.Get("wave", "telegram"); ok {
+		t.Error("unknown name should not resolve")
+	}
+}
+
+// stickerChannel is a mock channel sending stickers and custom emoji.
+type stickerChannel struct {
+	*mockChannel
+}
+
+func (c *stickerChannel) Capabilities() Capabilities {
+	return Capabilities{Stickers: true, CustomEmoji: true}
+}
+
+func TestStickerFallback(t *testing.T) {
+	router := NewRouter(nil)
+	plain := newMockChannel("plain")
+	rich := &stickerChannel{newMockChannel("rich")}
+	router.Register(plain)
+	router.Register(rich)
+	ctx := context.Background()
+
+	msg := OutgoingMessage{
+		Content: "Nice one <:partyblob:123456>",
+		Media: []Media{
+			StickerMedia("s1", "🎉"),
+			{Type: MediaTypeSticker, FileID: "s2", Sticker: &Sticker{Name: "wave"}},
+			{Type: MediaTypeImage, URL: "https://example.com/a.png"},
+		},
+	}
+	for _, ch := range []*mockChannel{plain, rich.mockChannel} {
+		if err := router.Send(ctx, ch.name, "c1", msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if sent := plain.sentMessages(); len(sent) != 1 || sent[0].Content != "Nice one :partyblob: 🎉 :wave:" || len(sent[0].Media) != 1 || sent[0].Media[0].Type != MediaTypeImage {
+		t.Errorf("plain sent = %+v", sent)
+	}
+	if sent := rich.sentMessages(); len(sent) != 1 || sent[0].Content != msg.Content || len(sent[0].Media) != 3 {
+		t.Errorf("rich sent = %+v, want stickers and emoji kept", sent)
+	}
+}
+
+func TestParseCustomEmoji(t *testing.T) {
+	got := ParseCustomEmoji("gg <:partyblob:123> <a:dance:456> <@789> <:broken:>")
+	if len(got) != 2 || got[0] != (CustomEmoji{ID: "123", Name: "partyblob"}) || got[1] != (CustomEmoji{ID: "456", Name: "dance", Animated: true}) {
+		t.Errorf("ParseCustomEmoji = %+v", got)
+	}
+}
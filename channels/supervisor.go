@@ -0,0 +1,85 @@
+package channels
+
+import (
+	"context"
+	"time"
+
+	"github.com/agentplexus/envoy/events"
+	"github.com/agentplexus/envoy/metrics"
+)
+
+// DefaultHealthInterval is how often connected channels are health checked.
+const DefaultHealthInterval = 30 * time.Second
+
+// supervise health checks connected channels every interval until ctx is
+// done, reconnecting channels that fail.
+func (r *Router) supervise(ctx context.Context) {
+	ticker := time.NewTicker(r.options.healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.forEach(r.snapshot(), func(ch Channel) error {
+				r.checkHealth(ctx, ch)
+				return nil
+			})
+		}
+	}
+}
+
+// checkHealth health checks a connected channel and, if the check fails,
+// disconnects it and reconnects in the background with backoff.
+func (r *Router) checkHealth(ctx context.Context, ch Channel) {
+	hc, ok := ch.(HealthChecker)
+	if !ok || !r.IsConnected(ch.Name()) {
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, r.options.connectTimeout)
+	err := hc.HealthCheck(checkCtx)
+	cancel()
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+
+	name := ch.Name()
+	r.logger.Warn("channel health check failed, reconnecting", "name", name, "error", err)
+	if r.options.metrics != nil {
+		r.options.metrics.Counter("channel_health_failures", metrics.Labels{"channel": name}).Inc()
+	}
+
+	// Release the broken connection before connecting again
+	discCtx, cancel := context.WithTimeout(ctx, r.options.disconnectTimeout)
+	l := r.lifecycle.lock(name)
+	if l.lock(discCtx) != nil {
+		cancel()
+		return
+	}
+	_ = ch.Disconnect(discCtx)
+	cancel()
+	r.lifecycle.setConnected(name, false)
+	l.unlock()
+
+	r.emitConnection(name, EventTypeChannelDisconnected, err)
+	r.retryConnect(ctx, ch)
+}
+
+// emitConnection routes a channel_connected or channel_disconnected event.
+func (r *Router) emitConnection(name string, eventType EventType, err error) {
+	event := Event{
+		Type:        eventType,
+		ChannelName: name,
+		Timestamp:   time.Now(),
+	}
+	if err != nil {
+		event.Data = map[string]interface{}{EventDataError: err.Error()}
+	}
+	if eventType == EventTypeChannelConnected {
+		r.publish(events.ChannelConnected{Channel: name, Time: event.Timestamp})
+	} else {
+		r.publish(events.ChannelDisconnected{Channel: name, Err: err, Time: event.Timestamp})
+	}
+	_ = r.routeEvent(context.Background(), event)
+}
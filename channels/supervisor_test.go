@@ -0,0 +1,101 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// unhealthyChannel fails health checks until reconnected.
+type unhealthyChannel struct {
+	*mockChannel
+
+	mu       sync.Mutex
+	connects int
+	broken   bool
+}
+
+func (u *unhealthyChannel) Connect(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.connects++
+	u.broken = false
+	return nil
+}
+
+func (u *unhealthyChannel) HealthCheck(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.broken {
+		return errors.New("connection lost")
+	}
+	return nil
+}
+
+func (u *unhealthyChannel) breakConnection() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.broken = true
+}
+
+func TestSupervisorReconnects(t *testing.T) {
+	router := NewRouter(nil,
+		WithHealthCheck(10*time.Millisecond),
+		WithConnectRetry(5*time.Millisecond, 10*time.Millisecond))
+	ch := &unhealthyChannel{mockChannel: newMockChannel("flaky")}
+	router.Register(ch)
+
+	var mu sync.Mutex
+	var events []Event
+	router.OnEvent(func(ctx context.Context, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+		return nil
+	}, EventTypeChannelConnected, EventTypeChannelDisconnected)
+
+	ctx := context.Background()
+	if err := router.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	ch.breakConnection()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		ch.mu.Lock()
+		connects := ch.connects
+		ch.mu.Unlock()
+		if connects >= 2 && router.IsConnected("flaky") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("channel was not reconnected after failing its health check")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := router.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []EventType{EventTypeChannelConnected, EventTypeChannelDisconnected, EventTypeChannelConnected, EventTypeChannelDisconnected}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events = %v, want %v", types, want)
+		}
+	}
+	if events[1].Data[EventDataError] != "connection lost" {
+		t.Errorf("disconnect event data = %v, want error", events[1].Data)
+	}
+}
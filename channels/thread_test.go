@@ -0,0 +1,120 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockThreader creates threads as "<chat>/<title>".
+type mockThreader struct {
+	*mockChannel
+}
+
+func (m *mockThreader) CreateThread(ctx context.Context, chatID, title string, first OutgoingMessage) (string, error) {
+	threadID := chatID + "/" + title
+	return threadID, m.Send(ctx, threadID, first)
+}
+
+func TestRouterCreateThread(t *testing.T) {
+	router := NewRouter(nil)
+	threads := &mockThreader{newMockChannel("threads")}
+	router.Register(threads)
+	router.Register(newMockChannel("plain"))
+
+	ctx := context.Background()
+	threadID, err := router.CreateThread(ctx, "threads", "c1", "incident", OutgoingMessage{Content: "started"})
+	if err != nil {
+		t.Fatalf("CreateThread failed: %v", err)
+	}
+	if threadID != "c1/incident" {
+		t.Errorf("threadID = %q, want c1/incident", threadID)
+	}
+	if sent := threads.sentMessages(); len(sent) != 1 || sent[0].Content != "started" {
+		t.Errorf("sent = %+v, want first message", sent)
+	}
+
+	if _, err := router.CreateThread(ctx, "plain", "c1", "x", OutgoingMessage{}); !errors.Is(err, ErrThreadsUnsupported) {
+		t.Errorf("err = %v, want ErrThreadsUnsupported", err)
+	}
+}
+
+// threadChannel is a mock channel starting threads on messages.
+type threadChannel struct {
+	*mockChannel
+	mu      sync.Mutex
+	threads map[string]string
+	chats   []string
+}
+
+func (c *threadChannel) Capabilities() Capabilities {
+	return Capabilities{ReplyThreads: true}
+}
+
+func (c *threadChannel) CreateThread(ctx context.Context, chatID, title string, first OutgoingMessage) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.threads[first.ReplyTo] = title
+	return "thread-" + first.ReplyTo, nil
+}
+
+func (c *threadChannel) Send(ctx context.Context, chatID string, msg OutgoingMessage) error {
+	c.mu.Lock()
+	c.chats = append(c.chats, chatID)
+	c.mu.Unlock()
+	return c.mockChannel.Send(ctx, chatID, msg)
+}
+
+func TestThreadedReplies(t *testing.T) {
+	router := NewRouter(nil, WithThreadedReplies())
+	ch := &threadChannel{mockChannel: newMockChannel("discord"), threads: map[string]string{}}
+	plain := newMockChannel("plain")
+	// Threads, but not on messages
+	topics := &mockThreader{newMockChannel("topics")}
+	router.Register(ch)
+	router.Register(plain)
+	router.Register(topics)
+	router.SetAgent(mockAgent{})
+	router.OnMessage(All(), router.ProcessWithAgent())
+
+	for _, msg := range []IncomingMessage{
+		{ID: "1", ChannelName: "discord", ChatID: "g", ChatType: ChannelTypeGroup, Content: "Why is the build red?\nIt failed twice."},
+		{ID: "2", ChannelName: "discord", ChatID: "t", ChatType: ChannelTypeThread, ThreadID: "t", Content: "still?"},
+		{ID: "3", ChannelName: "discord", ChatID: "d", ChatType: ChannelTypeDM, Content: "hi"},
+		{ID: "4", ChannelName: "plain", ChatID: "g", ChatType: ChannelTypeGroup, Content: "hi"},
+		{ID: "5", ChannelName: "topics", ChatID: "g", ChatType: ChannelTypeGroup, Content: "hi"},
+	} {
+		target := map[string]*mockChannel{"discord": ch.mockChannel, "plain": plain, "topics": topics.mockChannel}[msg.ChannelName]
+		if err := deliverAndWait(router, target, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(ch.threads) != 1 || ch.threads["1"] != "Why is the build red?" {
+		t.Errorf("threads = %v, want one on message 1", ch.threads)
+	}
+	if want := []string{"thread-1", "t", "d"}; strings.Join(ch.chats, ",") != strings.Join(want, ",") {
+		t.Errorf("replies went to %v, want %v", ch.chats, want)
+	}
+	sent := ch.sentMessages()
+	if sent[0].ReplyTo != "" || sent[1].ReplyTo != "2" {
+		t.Errorf("ReplyTo = %q, %q, want none in new threads", sent[0].ReplyTo, sent[1].ReplyTo)
+	}
+	if sent := plain.sentMessages(); len(sent) != 1 || sent[0].ReplyTo != "4" {
+		t.Errorf("plain sent = %+v, want a reply", sent)
+	}
+	if sent := topics.sentMessages(); len(sent) != 1 || sent[0].ReplyTo != "5" {
+		t.Errorf("topics sent = %+v, want a reply", sent)
+	}
+}
+
+func TestThreadTitle(t *testing.T) {
+	if got := threadTitle("  \n"); got != "Reply" {
+		t.Errorf("threadTitle(blank) = %q", got)
+	}
+	if got := threadTitle(strings.Repeat("é", 100)); len([]rune(got)) != maxThreadTitle || !strings.HasSuffix(got, "…") {
+		t.Errorf("threadTitle(long) = %q", got)
+	}
+}
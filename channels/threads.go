@@ -0,0 +1,52 @@
+package channels
+
+import (
+	"context"
+	"strings"
+)
+
+// maxThreadTitle is the length threaded reply titles are cut to, within
+// Discord's limit of 100 characters.
+const maxThreadTitle = 80
+
+// replyTarget returns where to answer msg: in a thread started from it
+// with threaded replies, otherwise as a reply in its chat.
+func (r *Router) replyTarget(ctx context.Context, msg IncomingMessage) (chatID, replyTo string) {
+	if !r.threaded(msg) {
+		return msg.ChatID, msg.ID
+	}
+	threadID, err := r.CreateThread(ctx, msg.ChannelName, msg.ChatID, threadTitle(msg.Content), OutgoingMessage{ReplyTo: msg.ID})
+	if err != nil {
+		r.log(ctx).Warn("threaded reply failed, replying in chat", "error", err)
+		return msg.ChatID, msg.ID
+	}
+	return threadID, ""
+}
+
+// threaded reports whether msg is answered in a new thread.
+func (r *Router) threaded(msg IncomingMessage) bool {
+	if !r.options.threadedReplies || msg.ChatType != ChannelTypeGroup || msg.ThreadID != "" || msg.ID == "" || msg.Command != nil {
+		return false
+	}
+	if len(r.options.threadChannels) > 0 && !contains(r.options.threadChannels, msg.ChannelName) {
+		return false
+	}
+	channel, ok := r.GetChannel(msg.ChannelName)
+	if !ok {
+		return false
+	}
+	_, ok = channel.(Threader)
+	return ok && capabilities(channel).ReplyThreads
+}
+
+// threadTitle derives a thread title from the first line of a message.
+func threadTitle(content string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	if title == "" {
+		return "Reply"
+	}
+	if runes := []rune(title); len(runes) > maxThreadTitle {
+		title = strings.TrimSpace(string(runes[:maxThreadTitle-1])) + "…"
+	}
+	return title
+}
@@ -0,0 +1,155 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleConfig limits how often a handler runs in each chat.
+type ThrottleConfig struct {
+	// Interval is the minimum time between handler runs in a chat.
+	Interval time.Duration
+
+	// Coalesce holds messages that arrive during the interval and passes
+	// them to the handler as a single message once it ends. Otherwise
+	// those messages are dropped.
+	Coalesce bool
+
+	// Separator joins coalesced message contents (default: "\n").
+	Separator string
+}
+
+// Throttle wraps a handler so it runs at most once per interval in each
+// chat:
+//
+//	router.OnMessage(channels.RoutePattern{ChatTypes: []channels.ChannelType{channels.ChannelTypeGroup}},
+//		channels.Throttle(channels.ThrottleConfig{Interval: 30 * time.Second, Coalesce: true},
+//			router.ProcessWithAgent()))
+//
+// A coalesced message is the latest message with the contents of all held
+// messages joined in order.
+func Throttle(config ThrottleConfig, next MessageHandler) MessageHandler {
+	if config.Interval <= 0 {
+		return next
+	}
+	if config.Separator == "" {
+		config.Separator = "\n"
+	}
+	t := &throttler{
+		config: config,
+		next:   next,
+		chats:  make(map[string]*throttleState),
+		now:    time.Now,
+	}
+	return t.handle
+}
+
+// throttleState tracks a chat's last handler run and held messages.
+type throttleState struct {
+	last      time.Time
+	pending   []IncomingMessage
+	scheduled bool
+}
+
+type throttler struct {
+	config ThrottleConfig
+	next   MessageHandler
+	now    func() time.Time
+
+	mu        sync.Mutex
+	chats     map[string]*throttleState
+	lastSweep time.Time
+}
+
+func (t *throttler) handle(ctx context.Context, msg IncomingMessage) error {
+	key := msg.ChannelName + "\x00" + msg.ChatID
+
+	t.mu.Lock()
+	now := t.now()
+	t.sweep(now)
+	st, ok := t.chats[key]
+	if !ok {
+		st = &throttleState{}
+		t.chats[key] = st
+	}
+	wait := st.last.Add(t.config.Interval).Sub(now)
+	if !st.scheduled && wait <= 0 {
+		st.last = now
+		t.mu.Unlock()
+		return t.next(ctx, msg)
+	}
+
+	if !t.config.Coalesce {
+		t.mu.Unlock()
+		LoggerFromContext(ctx).Debug("message throttled", "message", msg.ID)
+		return nil
+	}
+	st.pending = append(st.pending, msg)
+	if !st.scheduled {
+		st.scheduled = true
+		t.schedule(ctx, wait, key)
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// schedule flushes a chat's held messages after wait. Under a router the
+// flush runs through the chat's queue and is run by Shutdown instead of
+// being lost.
+func (t *throttler) schedule(ctx context.Context, wait time.Duration, key string) {
+	if s, ok := schedulerFromContext(ctx); ok {
+		s(wait, func(ctx context.Context) { t.flush(ctx, key) })
+		return
+	}
+	// The flush outlives this handler call
+	flushCtx := context.WithoutCancel(ctx)
+	time.AfterFunc(wait, func() { t.flush(flushCtx, key) })
+}
+
+// flush runs the handler with a chat's held messages combined.
+func (t *throttler) flush(ctx context.Context, key string) {
+	t.mu.Lock()
+	st := t.chats[key]
+	msgs := st.pending
+	st.pending = nil
+	st.scheduled = false
+	st.last = t.now()
+	t.mu.Unlock()
+
+	if len(msgs) == 0 {
+		return
+	}
+	if err := t.next(ctx, coalesce(msgs, t.config.Separator)); err != nil {
+		LoggerFromContext(ctx).Error("coalesced handler error", "messages", len(msgs), "error", err)
+	}
+}
+
+// sweep drops chats idle for longer than the interval so memory stays
+// bounded by recently active chats. The caller must hold t.mu.
+func (t *throttler) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.config.Interval {
+		return
+	}
+	t.lastSweep = now
+	for key, st := range t.chats {
+		if !st.scheduled && now.Sub(st.last) >= t.config.Interval {
+			delete(t.chats, key)
+		}
+	}
+}
+
+// coalesce combines messages into the latest one.
+func coalesce(msgs []IncomingMessage, separator string) IncomingMessage {
+	msg := msgs[len(msgs)-1]
+	if len(msgs) == 1 {
+		return msg
+	}
+	contents := make([]string, len(msgs))
+	for i, m := range msgs {
+		contents[i] = m.Content
+	}
+	msg.Content = strings.Join(contents, separator)
+	return msg
+}
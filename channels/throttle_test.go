@@ -0,0 +1,94 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleDrops(t *testing.T) {
+	var calls int
+	handler := Throttle(ThrottleConfig{Interval: time.Hour}, func(ctx context.Context, msg IncomingMessage) error {
+		calls++
+		return nil
+	})
+
+	for _, chat := range []string{"a", "a", "a", "b"} {
+		_ = handler(context.Background(), IncomingMessage{ChannelName: "test", ChatID: chat})
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 (once per chat)", calls)
+	}
+}
+
+func TestThrottleCoalesces(t *testing.T) {
+	var mu sync.Mutex
+	var got []IncomingMessage
+	done := make(chan struct{}, 2)
+	handler := Throttle(ThrottleConfig{Interval: 50 * time.Millisecond, Coalesce: true}, func(ctx context.Context, msg IncomingMessage) error {
+		mu.Lock()
+		got = append(got, msg)
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+
+	for i, content := range []string{"one", "two", "three"} {
+		msg := IncomingMessage{ID: string(rune('1' + i)), ChannelName: "test", ChatID: "c", Content: content}
+		if err := handler(context.Background(), msg); err != nil {
+			t.Fatalf("handler failed: %v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for coalesced call")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("calls = %d, want 2", len(got))
+	}
+	if got[0].Content != "one" {
+		t.Errorf("first call = %q, want one", got[0].Content)
+	}
+	if got[1].Content != "two\nthree" || got[1].ID != "3" {
+		t.Errorf("coalesced call = %q (id %s), want \"two\\nthree\" (id 3)", got[1].Content, got[1].ID)
+	}
+}
+
+func TestThrottleFlushesThroughRouter(t *testing.T) {
+	router := NewRouter(nil, WithWorkers(2))
+	ch := newMockChannel("test")
+	router.Register(ch)
+
+	var mu sync.Mutex
+	var got []string
+	router.OnMessage(All(), Throttle(ThrottleConfig{Interval: time.Hour, Coalesce: true}, func(ctx context.Context, msg IncomingMessage) error {
+		mu.Lock()
+		got = append(got, msg.Content)
+		mu.Unlock()
+		return nil
+	}))
+
+	for i, content := range []string{"one", "two", "three"} {
+		if err := deliverAndWait(router, ch, IncomingMessage{ID: string(rune('1' + i)), ChannelName: "test", ChatID: "c", Content: content}); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+
+	// Shutdown runs the held flush instead of dropping it
+	if err := router.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[1] != "two\nthree" {
+		t.Errorf("calls = %q, want the held messages flushed on shutdown", got)
+	}
+}
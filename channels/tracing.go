@@ -0,0 +1,79 @@
+package channels
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the router's OpenTelemetry
+// spans.
+const TracerName = "github.com/agentplexus/envoy/channels"
+
+// Span names of the message path. A message produces a receive span
+// covering queueing and processing, with a route span for handler
+// dispatch, an agent span per agent call, and a send span per delivery.
+const (
+	SpanReceive = "envoy.receive"
+	SpanEvent   = "envoy.event"
+	SpanRoute   = "envoy.route"
+	SpanAgent   = "envoy.agent"
+	SpanSend    = "envoy.send"
+)
+
+// Span attribute keys.
+const (
+	AttrChannel   = attribute.Key("envoy.channel")
+	AttrChat      = attribute.Key("envoy.chat")
+	AttrMessageID = attribute.Key("envoy.message.id")
+	AttrEventType = attribute.Key("envoy.event.type")
+	AttrSession   = attribute.Key("envoy.session")
+	AttrAgent     = attribute.Key("envoy.agent")
+	AttrHandlers  = attribute.Key("envoy.handlers.matched")
+	AttrDuplicate = attribute.Key("envoy.duplicate")
+	AttrStreaming = attribute.Key("envoy.streaming")
+)
+
+// WithTracerProvider sets the OpenTelemetry tracer provider for the
+// router's spans (default: the global provider, which records nothing
+// until one is installed with otel.SetTracerProvider).
+func WithTracerProvider(tp trace.TracerProvider) RouterOption {
+	return func(o *routerOptions) {
+		o.tracerProvider = tp
+	}
+}
+
+// newTracer returns the tracer for tp, or for the global provider if nil.
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(TracerName)
+}
+
+// chatAttributes returns the span attributes identifying a chat.
+func chatAttributes(channelName, chatID string) []attribute.KeyValue {
+	return []attribute.KeyValue{AttrChannel.String(channelName), AttrChat.String(chatID)}
+}
+
+// EndSpan records err, if any, on span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// spanTraceID returns the OpenTelemetry trace ID of the span in ctx, or ""
+// if there is none.
+func spanTraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
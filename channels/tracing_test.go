@@ -0,0 +1,66 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRouterTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	var buf bytes.Buffer
+	router := NewRouter(slog.New(slog.NewJSONHandler(&buf, nil)), WithTracerProvider(tp))
+	ch := newMockChannel("test")
+	router.Register(ch)
+	router.SetAgent(mockAgent{})
+	router.OnMessage(All(), func(ctx context.Context, msg IncomingMessage) error {
+		LoggerFromContext(ctx).Info("handled")
+		return router.ProcessWithAgent()(ctx, msg)
+	})
+	buf.Reset()
+
+	if err := deliverAndWait(router, ch, IncomingMessage{ID: "m1", ChannelName: "test", ChatID: "42", Content: "hi"}); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	ended := spans.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range ended {
+		byName[s.Name()] = s
+	}
+	parents := map[string]string{SpanRoute: SpanReceive, SpanAgent: SpanRoute, SpanSend: SpanAgent}
+	for _, name := range []string{SpanReceive, SpanRoute, SpanAgent, SpanSend} {
+		if byName[name] == nil {
+			t.Fatalf("no %s span in %d ended spans", name, len(ended))
+		}
+	}
+	for child, parent := range parents {
+		if got, want := byName[child].Parent().SpanID(), byName[parent].SpanContext().SpanID(); got != want {
+			t.Errorf("%s parent = %s, want %s span %s", child, got, parent, want)
+		}
+	}
+
+	receive := byName[SpanReceive]
+	attrs := make(map[string]string)
+	for _, kv := range receive.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs[string(AttrChannel)] != "test" || attrs[string(AttrChat)] != "42" || attrs[string(AttrMessageID)] != "m1" {
+		t.Errorf("receive attributes = %v", attrs)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(bytes.SplitN(buf.Bytes(), []byte("\n"), 2)[0], &entry); err != nil {
+		t.Fatalf("decode log entry %q: %v", buf.String(), err)
+	}
+	if want := receive.SpanContext().TraceID().String(); entry[LogKeyTraceID] != want {
+		t.Errorf("log trace_id = %v, want span trace ID %s", entry[LogKeyTraceID], want)
+	}
+}
@@ -0,0 +1,46 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/agentplexus/envoy/metrics"
+)
+
+// Transcript persists the messages passing through the router, e.g. to keep
+// compliance records. See WithTranscript.
+type Transcript interface {
+	// RecordIncoming records a received message.
+	RecordIncoming(ctx context.Context, msg IncomingMessage) error
+
+	// RecordOutgoing records a message sent to a chat. messageID is the
+	// platform ID from the message's receipt, if the channel reported one.
+	RecordOutgoing(ctx context.Context, channelName, chatID, messageID string, msg OutgoingMessage) error
+}
+
+// recordIncoming records a received message in the transcript, if any.
+// Failures are logged and do not stop processing.
+func (r *Router) recordIncoming(ctx context.Context, msg IncomingMessage) {
+	if r.options.transcript == nil {
+		return
+	}
+	if err := r.options.transcript.RecordIncoming(ctx, msg); err != nil {
+		r.transcriptError(ctx, msg.ChannelName, err)
+	}
+}
+
+// recordOutgoing records a sent message in the transcript, if any.
+func (r *Router) recordOutgoing(ctx context.Context, channelName, chatID, messageID string, msg OutgoingMessage) {
+	if r.options.transcript == nil {
+		return
+	}
+	if err := r.options.transcript.RecordOutgoing(ctx, channelName, chatID, messageID, msg); err != nil {
+		r.transcriptError(ctx, channelName, err)
+	}
+}
+
+func (r *Router) transcriptError(ctx context.Context, channelName string, err error) {
+	r.log(ctx).Error("transcript record failed", "channel", channelName, "error", err)
+	if r.options.metrics != nil {
+		r.options.metrics.Counter("transcript_errors", metrics.Labels{"channel": channelName}).Inc()
+	}
+}
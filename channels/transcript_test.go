@@ -0,0 +1,90 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// recordingTranscript collects recorded messages as "<direction>:<content>".
+type recordingTranscript struct {
+	mu      sync.Mutex
+	entries []string
+	ids     []string
+}
+
+func (t *recordingTranscript) RecordIncoming(ctx context.Context, msg IncomingMessage) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, "in:"+msg.Content)
+	return nil
+}
+
+func (t *recordingTranscript) RecordOutgoing(ctx context.Context, channelName, chatID, messageID string, msg OutgoingMessage) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, "out:"+msg.Content)
+	t.ids = append(t.ids, messageID)
+	return nil
+}
+
+func TestTranscript(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		channel interface {
+			Channel
+			deliver(IncomingMessage) error
+		}
+		reply string
+	}{
+		{"send", newMockChannel("test"), "echo: one two"},
+		{"stream", &mockStreamingChannel{mockChannel: newMockChannel("test")}, "one two "},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			transcript := &recordingTranscript{}
+			router := NewRouter(nil, WithTranscript(transcript))
+			router.Register(tc.channel)
+			router.SetAgent(mockStreamingAgent{})
+			router.OnMessage(All(), router.ProcessWithAgent())
+
+			msg := IncomingMessage{ID: "1", ChannelName: "test", ChatID: "c1", Content: "one two"}
+			if err := deliverAndWait(router, tc.channel, msg); err != nil {
+				t.Fatalf("deliver failed: %v", err)
+			}
+			// Redelivery is deduplicated and not recorded again
+			if err := deliverAndWait(router, tc.channel, msg); err != nil {
+				t.Fatalf("deliver failed: %v", err)
+			}
+
+			want := []string{"in:one two", "out:" + tc.reply}
+			if len(transcript.entries) != len(want) {
+				t.Fatalf("entries = %q, want %q", transcript.entries, want)
+			}
+			for i := range want {
+				if transcript.entries[i] != want[i] {
+					t.Errorf("entries[%d] = %q, want %q", i, transcript.entries[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTranscriptMessageID(t *testing.T) {
+	transcript := &recordingTranscript{}
+	router := NewRouter(nil, WithTranscript(transcript))
+	router.Register(newEditorChannel("discord", 0))
+	router.Register(newMockChannel("plain"))
+	ctx := context.Background()
+
+	if err := router.Send(ctx, "discord", "c1", OutgoingMessage{Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Send(ctx, "plain", "c1", OutgoingMessage{Content: "hi", IdempotencyKey: "k1"}); err != nil {
+		t.Fatal(err)
+	}
+	// The platform ID from the receipt, none for channels without receipts
+	want := []string{"m1", ""}
+	if len(transcript.ids) != len(want) || transcript.ids[0] != want[0] || transcript.ids[1] != want[1] {
+		t.Errorf("recorded IDs = %q, want %q", transcript.ids, want)
+	}
+}
@@ -0,0 +1,59 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultTypingInterval is how often typing indicators are refreshed while
+// an agent works. Telegram shows them for about five seconds and Discord
+// for about ten.
+const DefaultTypingInterval = 4 * time.Second
+
+// typing reports whether ProcessWithAgent shows typing indicators for msg.
+func (r *Router) typing(msg IncomingMessage) bool {
+	if !r.options.typing {
+		return false
+	}
+	return len(r.options.typingChannels) == 0 || contains(r.options.typingChannels, msg.ChannelName)
+}
+
+// startTyping shows a typing indicator in msg's chat on streaming channels,
+// refreshing it until the returned function is called. The function may be
+// called more than once; once it returns, no further indicators are sent.
+func (r *Router) startTyping(ctx context.Context, msg IncomingMessage) (stop func()) {
+	if !r.typing(msg) {
+		return func() {}
+	}
+	channel, ok := r.streamingChannel(msg.ChannelName)
+	if !ok {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.options.typingInterval)
+		defer ticker.Stop()
+		for {
+			if err := channel.SendTyping(ctx, msg.ChatID); err != nil && ctx.Err() == nil {
+				r.log(ctx).Debug("typing indicator failed", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+}
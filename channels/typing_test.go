@@ -0,0 +1,96 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// typingChannel is a mock streaming channel counting typing indicators and
+// noting whether any were sent after a message.
+type typingChannel struct {
+	*mockChannel
+	mu       sync.Mutex
+	typings  int
+	late     bool
+	answered bool
+}
+
+func (c *typingChannel) Send(ctx context.Context, chatID string, msg OutgoingMessage) error {
+	c.mu.Lock()
+	c.answered = true
+	c.mu.Unlock()
+	return c.mockChannel.Send(ctx, chatID, msg)
+}
+
+func (c *typingChannel) SendTyping(context.Context, string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.typings++
+	c.late = c.late || c.answered
+	return nil
+}
+
+func (c *typingChannel) SendStream(ctx context.Context, chatID string, chunks <-chan string) error {
+	for range chunks {
+		c.mu.Lock()
+		c.answered = true
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+func (c *typingChannel) counts() (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.typings, c.late
+}
+
+// slowAgent answers after a delay.
+type slowAgent struct{ delay time.Duration }
+
+func (a slowAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	time.Sleep(a.delay)
+	return "done", nil
+}
+
+type slowStreamingAgent struct{ slowAgent }
+
+func (a slowStreamingAgent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan Chunk, error) {
+	out := make(chan Chunk)
+	go func() {
+		defer close(out)
+		time.Sleep(a.delay)
+		for _, c := range []string{"do", "ne"} {
+			out <- Chunk{Content: c}
+			time.Sleep(a.delay)
+		}
+	}()
+	return out, nil
+}
+
+func TestTypingIndicator(t *testing.T) {
+	for _, agent := range []AgentProcessor{slowAgent{50 * time.Millisecond}, slowStreamingAgent{slowAgent{50 * time.Millisecond}}} {
+		router := NewRouter(nil, WithTypingIndicator(10*time.Millisecond, "busy"))
+		busy := &typingChannel{mockChannel: newMockChannel("busy")}
+		quiet := &typingChannel{mockChannel: newMockChannel("quiet")}
+		router.Register(busy)
+		router.Register(quiet)
+		router.SetAgent(agent)
+		router.OnMessage(All(), router.ProcessWithAgent())
+
+		for _, ch := range []*typingChannel{busy, quiet} {
+			if err := deliverAndWait(router, ch, IncomingMessage{ID: "1", ChannelName: ch.Name(), ChatID: "c1", Content: "hi"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if n, late := busy.counts(); n < 3 || late {
+			t.Errorf("%T: typing indicators = %d, after response = %v; want several before it", agent, n, late)
+		}
+		if n, _ := quiet.counts(); n != 0 {
+			t.Errorf("%T: typing indicators on other channel = %d", agent, n)
+		}
+	}
+}
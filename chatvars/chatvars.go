@@ -0,0 +1,150 @@
+// Package chatvars stores per-chat variables, such as a customer tier or
+// project name, and exposes them to message templates and system prompts.
+//
+// Variables are set with the /var chat command or the gateway admin API and
+// rendered with text/template, so "Hi! You are on the {{.tier}} plan." reads
+// the chat's "tier" variable. Unset variables render empty.
+//
+//	vars := chatvars.New(chatvars.Config{})
+//	router.Use(access.Middleware()) // an *acl.ACL naming the admins
+//	router.AddHandler(channels.RouteHandler{
+//		Pattern:   channels.RoutePattern{Prefix: chatvars.CommandPrefix},
+//		Handler:   chatvars.Command(vars, router),
+//		Priority:  100,
+//		Exclusive: true,
+//	})
+//	agent.New(agent.Config{
+//		SystemPrompt: "You support {{.project}} customers on the {{.tier}} plan.",
+//		PromptVars:   vars.FromContext,
+//	})
+package chatvars
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Store persists variables by chat key.
+type Store interface {
+	// Get returns a chat's variables; an empty map if none are set.
+	Get(ctx context.Context, chat string) (map[string]string, error)
+
+	// Set sets a variable.
+	Set(ctx context.Context, chat, key, value string) error
+
+	// Delete removes a variable. Deleting a missing variable is not an
+	// error.
+	Delete(ctx context.Context, chat, key string) error
+}
+
+// Config configures Vars.
+type Config struct {
+	// Store persists variables (default: in-memory).
+	Store Store
+}
+
+// Vars reads and writes per-chat variables.
+type Vars struct {
+	store Store
+}
+
+// New creates a new Vars.
+func New(config Config) *Vars {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	return &Vars{store: config.Store}
+}
+
+// keyPattern restricts keys to names usable as {{.key}} in templates.
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidKey reports whether key is a valid variable name: a letter or
+// underscore followed by letters, digits, or underscores.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Get returns the variables of a chat.
+func (v *Vars) Get(ctx context.Context, channelName, chatID string) (map[string]string, error) {
+	vars, err := v.store.Get(ctx, channels.SessionID(channelName, chatID))
+	if err != nil {
+		return nil, fmt.Errorf("get chat variables: %w", err)
+	}
+	return vars, nil
+}
+
+// Set sets a chat variable.
+func (v *Vars) Set(ctx context.Context, channelName, chatID, key, value string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid variable name %q", key)
+	}
+	if err := v.store.Set(ctx, channels.SessionID(channelName, chatID), key, value); err != nil {
+		return fmt.Errorf("set chat variable: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a chat variable.
+func (v *Vars) Delete(ctx context.Context, channelName, chatID, key string) error {
+	if err := v.store.Delete(ctx, channels.SessionID(channelName, chatID), key); err != nil {
+		return fmt.Errorf("delete chat variable: %w", err)
+	}
+	return nil
+}
+
+// FromContext returns the variables of the chat whose message is being
+// processed (see channels.MessageFromContext), or none outside message
+// processing. It fits agent.Config.PromptVars.
+func (v *Vars) FromContext(ctx context.Context) (map[string]string, error) {
+	msg, ok := channels.MessageFromContext(ctx)
+	if !ok {
+		return map[string]string{}, nil
+	}
+	return v.Get(ctx, msg.ChannelName, msg.ChatID)
+}
+
+// Render executes text as a template with a chat's variables.
+func (v *Vars) Render(ctx context.Context, channelName, chatID, text string) (string, error) {
+	vars, err := v.Get(ctx, channelName, chatID)
+	if err != nil {
+		return "", err
+	}
+	return Render(text, vars)
+}
+
+// Parse parses a template referencing chat variables. Unset variables render
+// empty.
+func Parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Execute renders a parsed template with variables.
+func Execute(tmpl *template.Template, vars map[string]string) (string, error) {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", fmt.Errorf("render template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// Render executes text as a template with variables.
+func Render(text string, vars map[string]string) (string, error) {
+	tmpl, err := Parse("message", text)
+	if err != nil {
+		return "", err
+	}
+	return Execute(tmpl, vars)
+}
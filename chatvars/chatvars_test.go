@@ -0,0 +1,70 @@
+package chatvars
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agentplexus/envoy/acl"
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+func TestCommandAndRender(t *testing.T) {
+	ctx := context.Background()
+	v := New(Config{})
+	sender := &channelstest.Sender{}
+	handler := Command(v, sender)
+
+	// Only admins change variables
+	for _, role := range []acl.Role{"", acl.RoleUser} {
+		msg := channels.IncomingMessage{ChannelName: "telegram", ChatID: "42", Content: "/var set tier free"}
+		if err := handler(acl.WithRole(ctx, role), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sender.Contents()) != 2 || sender.Contents()[0] != "Only admins can change variables." {
+		t.Fatalf("replies = %q, want denials", sender.Contents())
+	}
+	sender.Reset()
+
+	admin := acl.WithRole(ctx, acl.RoleAdmin)
+	for _, content := range []string{
+		"/var set tier gold plus",
+		"/var set project Apollo",
+		"/var set 1bad x",
+		"/var unset project",
+		"/var",
+	} {
+		msg := channels.IncomingMessage{ChannelName: "telegram", ChatID: "42", Content: content}
+		if err := handler(admin, msg); err != nil {
+			t.Fatalf("%s: %v", content, err)
+		}
+	}
+	want := []string{"Set tier.", "Set project.", "Usage: /var set <key> <value>", "Removed project.", "tier = gold plus"}
+	if len(sender.Contents()) != len(want) {
+		t.Fatalf("replies = %q, want %q", sender.Contents(), want)
+	}
+	for i := range want {
+		if sender.Contents()[i] != want[i] {
+			t.Errorf("reply %d = %q, want %q", i, sender.Contents()[i], want[i])
+		}
+	}
+
+	got, err := v.Render(ctx, "telegram", "42", "Plan: {{.tier}}, project: {{.project}}")
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got != "Plan: gold plus, project: " {
+		t.Errorf("rendered = %q", got)
+	}
+
+	// Variables are scoped to the chat of the message in ctx
+	vars, err := v.FromContext(channels.WithMessage(ctx, channels.IncomingMessage{ChannelName: "telegram", ChatID: "7"}))
+	if err != nil || len(vars) != 0 {
+		t.Errorf("other chat vars = %v, %v, want none", vars, err)
+	}
+	vars, err = v.FromContext(channels.WithMessage(ctx, channels.IncomingMessage{ChannelName: "telegram", ChatID: "42"}))
+	if err != nil || vars["tier"] != "gold plus" {
+		t.Errorf("vars = %v, %v", vars, err)
+	}
+}
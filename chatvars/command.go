@@ -0,0 +1,93 @@
+package chatvars
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agentplexus/envoy/acl"
+	"github.com/agentplexus/envoy/channels"
+)
+
+// CommandPrefix is the chat command managing the chat's variables.
+const CommandPrefix = "/var"
+
+// Command returns a handler for the /var command:
+//
+//	/var                    list the chat's variables
+//	/var set <key> <value>  set a variable
+//	/var unset <key>        remove a variable
+//
+// Variables end up in system prompts, so only senders the acl middleware
+// gives the admin role may set or unset them; without the middleware
+// variables are read-only in chat. Register it with a higher priority than
+// the agent handler and Exclusive set.
+func Command(v *Vars, sender channels.Sender) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		args, ok := channels.ParseCommand(msg.Content, CommandPrefix)
+		if !ok {
+			return nil
+		}
+
+		reply, err := v.command(ctx, msg, args)
+		if err != nil {
+			return err
+		}
+		return sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+			Content: reply,
+			ReplyTo: msg.ID,
+		})
+	}
+}
+
+// command runs a /var command and returns the reply.
+func (v *Vars) command(ctx context.Context, msg channels.IncomingMessage, args string) (string, error) {
+	action, rest, _ := strings.Cut(args, " ")
+	if action == "set" || action == "unset" {
+		if role, _ := acl.RoleFromContext(ctx); role != acl.RoleAdmin {
+			return "Only admins can change variables.", nil
+		}
+	}
+	switch action {
+	case "":
+		vars, err := v.Get(ctx, msg.ChannelName, msg.ChatID)
+		if err != nil {
+			return "", err
+		}
+		if len(vars) == 0 {
+			return "No variables set.", nil
+		}
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var sb strings.Builder
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "%s = %s\n", k, vars[k])
+		}
+		return strings.TrimSuffix(sb.String(), "\n"), nil
+
+	case "set":
+		key, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		if !ValidKey(key) {
+			return "Usage: /var set <key> <value>", nil
+		}
+		if err := v.Set(ctx, msg.ChannelName, msg.ChatID, key, strings.TrimSpace(value)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Set %s.", key), nil
+
+	case "unset":
+		key := strings.TrimSpace(rest)
+		if key == "" {
+			return "Usage: /var unset <key>", nil
+		}
+		if err := v.Delete(ctx, msg.ChannelName, msg.ChatID, key); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Removed %s.", key), nil
+	}
+	return "Usage: /var [set <key> <value> | unset <key>]", nil
+}
@@ -0,0 +1,53 @@
+package chatvars
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store for single-process deployments.
+type MemoryStore struct {
+	chats map[string]map[string]string
+	mu    sync.Mutex
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{chats: make(map[string]map[string]string)}
+}
+
+// Get returns a copy of a chat's variables.
+func (s *MemoryStore) Get(_ context.Context, chat string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vars := make(map[string]string, len(s.chats[chat]))
+	for k, v := range s.chats[chat] {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// Set sets a variable.
+func (s *MemoryStore) Set(_ context.Context, chat, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chats[chat] == nil {
+		s.chats[chat] = make(map[string]string)
+	}
+	s.chats[chat][key] = value
+	return nil
+}
+
+// Delete removes a variable.
+func (s *MemoryStore) Delete(_ context.Context, chat, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chats[chat], key)
+	if len(s.chats[chat]) == 0 {
+		delete(s.chats, chat)
+	}
+	return nil
+}
+
+// Ensure MemoryStore implements Store.
+var _ Store = (*MemoryStore)(nil)
@@ -8,10 +8,18 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cobra"
 
+	"github.com/agentplexus/envoy/acl"
 	"github.com/agentplexus/envoy/agent"
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/chatvars"
+	"github.com/agentplexus/envoy/config"
 	"github.com/agentplexus/envoy/gateway"
+	"github.com/agentplexus/envoy/gateway/redisbridge"
+	"github.com/agentplexus/envoy/metrics"
+	"github.com/agentplexus/envoy/ratelimit"
 )
 
 var (
@@ -30,7 +38,11 @@ var gatewayRunCmd = &cobra.Command{
 	Long: `Start the envoy WebSocket gateway server.
 
 The gateway serves as the control plane for all connected clients,
-routing messages between channels and the AI agent.`,
+routing messages between channels and the AI agent.
+
+In relay mode (mode: relay) no agent is created: the configured channels
+are connected, bridges mirror messages between chats, and messages posted
+to /admin/messages are delivered to channels.`,
 	RunE: runGateway,
 }
 
@@ -50,9 +62,68 @@ func runGateway(cmd *cobra.Command, args []string) error {
 		address = gatewayAddress
 	}
 
-	// Create agent if API key is configured
+	// Agents of the router and the gateway share the interceptors
+	interceptors := []agent.Interceptor{agent.Logging(logger)}
+
+	// Chat variables fill in the agents' system prompt templates
+	vars := chatvars.New(chatvars.Config{})
+
+	// Collect metrics for the dashboard and /metrics if enabled, including
+	// the router's
+	var registry *metrics.Registry
+	if cfg.Gateway.Dashboard {
+		if cfg.Gateway.AdminToken == "" {
+			logger.Warn("dashboard enabled but no admin token configured, dashboard disabled")
+		} else {
+			registry = metrics.NewRegistry(metrics.Config{})
+		}
+	}
+	if cfg.Gateway.Metrics && registry == nil {
+		registry = metrics.NewRegistry(metrics.Config{})
+	}
+	if cfg.Gateway.Metrics && cfg.Gateway.AdminToken == "" && !cfg.Gateway.PublicMetrics {
+		logger.Warn("metrics enabled but no admin token configured, /metrics disabled (set public_metrics to serve it unauthenticated)")
+	}
+	var routerOptions []channels.RouterOption
+	if registry != nil {
+		routerOptions = append(routerOptions, channels.WithMetrics(registry))
+	}
+
+	// In relay mode, or with router integration enabled, connect the
+	// channels and deliver messages sent through the gateway
+	var wiring *config.Wiring
+	var sender channels.Sender
+	if cfg.Relay() || cfg.Gateway.Router {
+		var err error
+		wiring, err = config.Build(cfg, config.BuildOptions{
+			Logger:        logger,
+			Interceptors:  interceptors,
+			PromptVars:    vars.FromContext,
+			RouterOptions: routerOptions,
+		})
+		if err != nil {
+			return fmt.Errorf("build router: %w", err)
+		}
+		defer wiring.Close()
+		sender = wiring.Router
+		if !cfg.Relay() {
+			if err := useChatCommands(wiring.Router, cfg.Access, vars); err != nil {
+				return err
+			}
+		}
+		if cfg.Relay() && cfg.Gateway.AdminToken == "" {
+			logger.Warn("relay mode without an admin token, message API disabled")
+		}
+	}
+
+	// Create agent if API key is configured, sharing the router's default
+	// agent when there is one
 	var agentProcessor gateway.AgentProcessor
-	if cfg.Agent.APIKey != "" {
+	if cfg.Relay() {
+		logger.Info("relay mode, agent disabled")
+	} else if a, ok := wiringAgent(wiring); ok {
+		agentProcessor = a
+	} else if cfg.Agent.APIKey != "" {
 		agentInstance, err := agent.New(agent.Config{
 			Provider:     cfg.Agent.Provider,
 			Model:        cfg.Agent.Model,
@@ -67,20 +138,68 @@ func runGateway(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("create agent: %w", err)
 		}
 		defer agentInstance.Close()
-		agentProcessor = agentInstance
+		agentProcessor = agent.Chain(interceptors...)(agentInstance)
 		logger.Info("agent initialized", "provider", cfg.Agent.Provider, "model", cfg.Agent.Model)
 	} else {
 		logger.Warn("no API key configured, agent disabled (messages will be echoed)")
 	}
 
+	if cfg.Gateway.Diagnostics && cfg.Gateway.AdminToken == "" {
+		logger.Warn("diagnostics enabled but no admin token configured, diagnostics disabled")
+	}
+
+	authenticator, err := newAuthenticator(cfg.Gateway.Auth)
+	if err != nil {
+		return err
+	}
+
+	bridge, err := newBridge(cfg.Gateway.Cluster)
+	if err != nil {
+		return err
+	}
+
 	// Create gateway
 	gw, err := gateway.New(gateway.Config{
-		Address:      address,
-		ReadTimeout:  cfg.Gateway.ReadTimeout,
-		WriteTimeout: cfg.Gateway.WriteTimeout,
-		PingInterval: cfg.Gateway.PingInterval,
-		Agent:        agentProcessor,
-		Logger:       logger,
+		Address:         address,
+		ReadTimeout:     cfg.Gateway.ReadTimeout,
+		WriteTimeout:    cfg.Gateway.WriteTimeout,
+		PingInterval:    cfg.Gateway.PingInterval,
+		PongTimeout:     cfg.Gateway.PongTimeout,
+		FrameTimeout:    cfg.Gateway.FrameTimeout,
+		IdleTimeout:     cfg.Gateway.IdleTimeout,
+		RequestTimeout:  cfg.Gateway.RequestTimeout,
+		Agent:           agentProcessor,
+		Logger:          logger,
+		AdminToken:      cfg.Gateway.AdminToken,
+		Metrics:         registry,
+		MetricsEndpoint: cfg.Gateway.Metrics,
+		PublicMetrics:   cfg.Gateway.PublicMetrics,
+		Diagnostics:     cfg.Gateway.Diagnostics,
+		Sender:          sender,
+		Authenticator:   authenticator,
+		Bridge:          bridge,
+		Vars:            vars,
+		Limits: gateway.LimitsConfig{
+			MessageRate: ratelimit.Rate{
+				Events: cfg.Gateway.Limits.Messages,
+				Per:    cfg.Gateway.Limits.MessagesPer,
+				Burst:  cfg.Gateway.Limits.MessagesBurst,
+			},
+			MaxInFlight:  cfg.Gateway.Limits.MaxInFlight,
+			SendQueue:    cfg.Gateway.Limits.SendQueue,
+			SlowConsumer: gateway.SlowConsumerPolicy(cfg.Gateway.Limits.SlowConsumer),
+		},
+		Resume: gateway.ResumeConfig{
+			BufferSize: cfg.Gateway.Resume.BufferSize,
+			TTL:        cfg.Gateway.Resume.TTL,
+		},
+		Files: gateway.FilesConfig{
+			MaxFileSize: cfg.Gateway.Files.MaxFileSize,
+			ChunkSize:   cfg.Gateway.Files.ChunkSize,
+			TTL:         cfg.Gateway.Files.TTL,
+			MaxUploads:  cfg.Gateway.Files.MaxUploads,
+			MaxBytes:    cfg.Gateway.Files.MaxBytes,
+		},
 	})
 	if err != nil {
 		return fmt.Errorf("create gateway: %w", err)
@@ -99,6 +218,29 @@ func runGateway(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	if wiring != nil {
+		// WebSocket chat goes through the router's routes
+		if cfg.Gateway.Router {
+			wiring.Router.Register(gateway.NewChannel(gw))
+		}
+		if err := wiring.Router.Start(ctx); err != nil {
+			return fmt.Errorf("start router: %w", err)
+		}
+		defer wiring.Router.Stop(context.Background())
+
+		// Reconnect channels when their tokens rotate in the secret store
+		if len(cfg.SecretRefs()) > 0 {
+			watcher := config.SecretsWatcher(cfg, func(ctx context.Context) error {
+				next, err := config.Load(cfgFile)
+				if err != nil {
+					return err
+				}
+				return wiring.Apply(ctx, next)
+			}, logger)
+			go watcher.Run(ctx)
+		}
+	}
+
 	// Start gateway
 	fmt.Printf("Starting gateway on %s\n", address)
 	if err := gw.Run(ctx); err != nil && err != context.Canceled {
@@ -108,3 +250,80 @@ func runGateway(cmd *cobra.Command, args []string) error {
 	fmt.Println("Gateway stopped")
 	return nil
 }
+
+// useChatCommands enforces the admin role on administrative chat commands
+// and routes /var to the chat variables.
+func useChatCommands(router *channels.Router, cfg config.AccessConfig, vars *chatvars.Vars) error {
+	access, err := acl.New(acl.Config{
+		Admins: cfg.Admins,
+		Permissions: []acl.Permission{
+			{Command: chatvars.CommandPrefix, Role: acl.RoleAdmin},
+		},
+		Sender: router,
+	})
+	if err != nil {
+		return fmt.Errorf("create acl: %w", err)
+	}
+	router.Use(access.Middleware())
+	router.AddHandler(channels.RouteHandler{
+		Pattern:   channels.RoutePattern{Prefix: chatvars.CommandPrefix},
+		Handler:   chatvars.Command(vars, router),
+		Priority:  100,
+		Exclusive: true,
+	})
+	return nil
+}
+
+// wiringAgent returns the default agent of the router wiring, if any.
+func wiringAgent(w *config.Wiring) (gateway.AgentProcessor, bool) {
+	if w == nil {
+		return nil, false
+	}
+	return w.Agent(channels.DefaultAgentName)
+}
+
+// newBridge connects to the other gateway instances through Redis, or
+// returns nil if no cluster is configured.
+func newBridge(cfg config.ClusterConfig) (gateway.Bridge, error) {
+	if cfg.RedisURL == "" {
+		return nil, nil
+	}
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse cluster redis url: %w", err)
+	}
+	return redisbridge.New(redisbridge.Config{
+		Client:  redis.NewClient(opts),
+		Channel: cfg.Channel,
+	})
+}
+
+// newAuthenticator creates the gateway authenticator from the auth config,
+// or returns nil if no method is configured.
+func newAuthenticator(cfg config.AuthConfig) (gateway.Authenticator, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	var auths gateway.Authenticators
+	if len(cfg.APIKeys) > 0 {
+		keys := make(gateway.APIKeys, len(cfg.APIKeys))
+		for i, k := range cfg.APIKeys {
+			keys[i] = gateway.APIKey{Key: k.Key, Subject: k.Subject}
+		}
+		auths = append(auths, keys)
+	}
+	if cfg.JWT.JWKSURL != "" || cfg.JWT.Secret != "" {
+		jwt, err := gateway.NewJWT(gateway.JWTConfig{
+			JWKSURL:  cfg.JWT.JWKSURL,
+			Secret:   []byte(cfg.JWT.Secret),
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+			Leeway:   cfg.JWT.Leeway,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create jwt authenticator: %w", err)
+		}
+		auths = append(auths, jwt)
+	}
+	return auths, nil
+}
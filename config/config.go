@@ -3,77 +3,305 @@ package config
 
 import "time"
 
+// Modes of operation.
+const (
+	// ModeAgent answers messages with the configured agents. This is the
+	// default.
+	ModeAgent = "agent"
+
+	// ModeRelay runs envoy without agents, as a delivery hub: messages are
+	// posted through the gateway API and mirrored between chats by bridges.
+	ModeRelay = "relay"
+)
+
 // Config is the root configuration for envoy.
 type Config struct {
-	Gateway       GatewayConfig       `json:"gateway" yaml:"gateway"`
-	Agent         AgentConfig         `json:"agent" yaml:"agent"`
-	Channels      ChannelsConfig      `json:"channels" yaml:"channels"`
-	Tools         ToolsConfig         `json:"tools" yaml:"tools"`
-	Observability ObservabilityConfig `json:"observability" yaml:"observability"`
+	// Mode is ModeAgent (the default when empty) or ModeRelay.
+	Mode string `json:"mode" yaml:"mode" toml:"mode"`
+
+	Gateway       GatewayConfig          `json:"gateway" yaml:"gateway" toml:"gateway"`
+	Agent         AgentConfig            `json:"agent" yaml:"agent" toml:"agent"`
+	Agents        map[string]AgentConfig `json:"agents" yaml:"agents" toml:"agents"`
+	Routes        []RouteConfig          `json:"routes" yaml:"routes" toml:"routes"`
+	Channels      ChannelsConfig         `json:"channels" yaml:"channels" toml:"channels"`
+	Bridge        BridgeConfig           `json:"bridge" yaml:"bridge" toml:"bridge"`
+	Access        AccessConfig           `json:"access" yaml:"access" toml:"access"`
+	Tools         ToolsConfig            `json:"tools" yaml:"tools" toml:"tools"`
+	Observability ObservabilityConfig    `json:"observability" yaml:"observability" toml:"observability"`
+	Secrets       SecretsConfig          `json:"secrets" yaml:"secrets" toml:"secrets"`
+}
+
+// Relay reports whether envoy runs in relay mode, without agents.
+func (c *Config) Relay() bool {
+	return c.Mode == ModeRelay
 }
 
 // GatewayConfig configures the WebSocket gateway.
 type GatewayConfig struct {
-	Address      string        `json:"address" yaml:"address"`
-	ReadTimeout  time.Duration `json:"read_timeout" yaml:"read_timeout"`
-	WriteTimeout time.Duration `json:"write_timeout" yaml:"write_timeout"`
-	PingInterval time.Duration `json:"ping_interval" yaml:"ping_interval"`
+	Address        string        `json:"address" yaml:"address" toml:"address"`
+	ReadTimeout    time.Duration `json:"read_timeout" yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout   time.Duration `json:"write_timeout" yaml:"write_timeout" toml:"write_timeout"`
+	PingInterval   time.Duration `json:"ping_interval" yaml:"ping_interval" toml:"ping_interval"`
+	PongTimeout    time.Duration `json:"pong_timeout" yaml:"pong_timeout" toml:"pong_timeout"`
+	FrameTimeout   time.Duration `json:"frame_timeout" yaml:"frame_timeout" toml:"frame_timeout"`
+	IdleTimeout    time.Duration `json:"idle_timeout" yaml:"idle_timeout" toml:"idle_timeout"`
+	RequestTimeout time.Duration `json:"request_timeout" yaml:"request_timeout" toml:"request_timeout"`
+	AdminToken     string        `json:"admin_token" yaml:"admin_token" toml:"admin_token"`
+	Dashboard      bool          `json:"dashboard" yaml:"dashboard" toml:"dashboard"`
+	Diagnostics    bool          `json:"diagnostics" yaml:"diagnostics" toml:"diagnostics"`
+	Metrics        bool          `json:"metrics" yaml:"metrics" toml:"metrics"`
+	PublicMetrics  bool          `json:"public_metrics" yaml:"public_metrics" toml:"public_metrics"`
+	Auth           AuthConfig    `json:"auth" yaml:"auth" toml:"auth"`
+
+	// Limits protects the gateway from misbehaving clients.
+	Limits LimitsConfig `json:"limits" yaml:"limits" toml:"limits"`
+
+	// Resume lets clients reconnect to their session and receive the
+	// messages they missed.
+	Resume ResumeConfig `json:"resume" yaml:"resume" toml:"resume"`
+
+	// Files lets clients upload files to attach to chat messages and
+	// download files sent to them.
+	Files FilesConfig `json:"files" yaml:"files" toml:"files"`
+
+	// Cluster connects gateway instances, so broadcasts and channel
+	// publishes reach clients on every instance.
+	Cluster ClusterConfig `json:"cluster" yaml:"cluster" toml:"cluster"`
+
+	// Router routes WebSocket chat through the channel router, so routes
+	// and middleware apply to gateway clients and router sends reach them.
+	Router bool `json:"router" yaml:"router" toml:"router"`
+}
+
+// ClusterConfig configures the pub/sub connection between gateway
+// instances.
+type ClusterConfig struct {
+	// RedisURL is the Redis server, e.g. "redis://localhost:6379/0".
+	// Instances run standalone when empty.
+	RedisURL string `json:"redis_url" yaml:"redis_url" toml:"redis_url"`
+
+	// Channel is the pub/sub channel shared by the instances.
+	Channel string `json:"channel" yaml:"channel" toml:"channel"`
+}
+
+// LimitsConfig limits what each gateway client can send and how far it can
+// fall behind reading. Zero values are unlimited or use the defaults.
+type LimitsConfig struct {
+	// Messages is the number of messages a connection may send per
+	// MessagesPer, with bursts of up to MessagesBurst.
+	Messages      int           `json:"messages" yaml:"messages" toml:"messages"`
+	MessagesPer   time.Duration `json:"messages_per" yaml:"messages_per" toml:"messages_per"`
+	MessagesBurst int           `json:"messages_burst" yaml:"messages_burst" toml:"messages_burst"`
+
+	// MaxInFlight limits the streaming agent requests per client.
+	MaxInFlight int `json:"max_in_flight" yaml:"max_in_flight" toml:"max_in_flight"`
+
+	// SendQueue is the number of outbound messages queued per client.
+	SendQueue int `json:"send_queue" yaml:"send_queue" toml:"send_queue"`
+
+	// SlowConsumer is "drop" to drop messages that do not fit in the queue
+	// or "disconnect" to close the client's connection.
+	SlowConsumer string `json:"slow_consumer" yaml:"slow_consumer" toml:"slow_consumer"`
+}
+
+// ResumeConfig configures resumable gateway sessions. Resuming is off
+// unless BufferSize is set.
+type ResumeConfig struct {
+	// BufferSize is the number of messages kept per session.
+	BufferSize int `json:"buffer_size" yaml:"buffer_size" toml:"buffer_size"`
+
+	// TTL is how long a disconnected session can be resumed.
+	TTL time.Duration `json:"ttl" yaml:"ttl" toml:"ttl"`
+}
+
+// FilesConfig configures gateway file transfer. Transfers are off unless
+// MaxFileSize is set.
+type FilesConfig struct {
+	// MaxFileSize is the largest upload, in bytes.
+	MaxFileSize int64 `json:"max_file_size" yaml:"max_file_size" toml:"max_file_size"`
+
+	// ChunkSize is the largest chunk transferred per message, in bytes.
+	ChunkSize int `json:"chunk_size" yaml:"chunk_size" toml:"chunk_size"`
+
+	// TTL is how long unused files are kept.
+	TTL time.Duration `json:"ttl" yaml:"ttl" toml:"ttl"`
+
+	// MaxUploads is the number of uploads a client can hold, in progress
+	// or waiting to be attached.
+	MaxUploads int `json:"max_uploads" yaml:"max_uploads" toml:"max_uploads"`
+
+	// MaxBytes is the budget for all files held by the gateway, in bytes.
+	MaxBytes int64 `json:"max_bytes" yaml:"max_bytes" toml:"max_bytes"`
+}
+
+// AuthConfig configures how gateway clients authenticate. With API keys or
+// a JWT issuer configured, clients must authenticate before chatting.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `json:"api_keys" yaml:"api_keys" toml:"api_keys"`
+	JWT     JWTConfig      `json:"jwt" yaml:"jwt" toml:"jwt"`
+}
+
+// Enabled reports whether any authentication method is configured.
+func (c AuthConfig) Enabled() bool {
+	return len(c.APIKeys) > 0 || c.JWT.JWKSURL != "" || c.JWT.Secret != ""
+}
+
+// APIKeyConfig is a static API key.
+type APIKeyConfig struct {
+	Key     string `json:"key" yaml:"key" toml:"key"`
+	Subject string `json:"subject" yaml:"subject" toml:"subject"`
+}
+
+// JWTConfig configures JWT authentication. Tokens are verified with keys
+// from JWKSURL or, for HMAC-signed tokens, with Secret.
+type JWTConfig struct {
+	JWKSURL  string        `json:"jwks_url" yaml:"jwks_url" toml:"jwks_url"`
+	Secret   string        `json:"secret" yaml:"secret" toml:"secret"`
+	Issuer   string        `json:"issuer" yaml:"issuer" toml:"issuer"`
+	Audience string        `json:"audience" yaml:"audience" toml:"audience"`
+	Leeway   time.Duration `json:"leeway" yaml:"leeway" toml:"leeway"`
 }
 
 // AgentConfig configures the AI agent.
 type AgentConfig struct {
-	Provider     string  `json:"provider" yaml:"provider"`
-	Model        string  `json:"model" yaml:"model"`
-	APIKey       string  `json:"api_key" yaml:"api_key"`
-	BaseURL      string  `json:"base_url" yaml:"base_url"`
-	Temperature  float64 `json:"temperature" yaml:"temperature"`
-	MaxTokens    int     `json:"max_tokens" yaml:"max_tokens"`
-	SystemPrompt string  `json:"system_prompt" yaml:"system_prompt"`
+	Provider     string  `json:"provider" yaml:"provider" toml:"provider"`
+	Model        string  `json:"model" yaml:"model" toml:"model"`
+	APIKey       string  `json:"api_key" yaml:"api_key" toml:"api_key"`
+	BaseURL      string  `json:"base_url" yaml:"base_url" toml:"base_url"`
+	Temperature  float64 `json:"temperature" yaml:"temperature" toml:"temperature"`
+	MaxTokens    int     `json:"max_tokens" yaml:"max_tokens" toml:"max_tokens"`
+	SystemPrompt string  `json:"system_prompt" yaml:"system_prompt" toml:"system_prompt"`
+}
+
+// RouteConfig binds messages matching a pattern to a named agent. Routes are
+// tried in order and the first match wins.
+type RouteConfig struct {
+	// Channels limits the route to channel names (empty = all).
+	Channels []string `json:"channels" yaml:"channels" toml:"channels"`
+
+	// ChatTypes limits the route to chat types: dm, group, channel, or thread
+	// (empty = all).
+	ChatTypes []string `json:"chat_types" yaml:"chat_types" toml:"chat_types"`
+
+	// Senders limits the route to sender IDs (empty = all).
+	Senders []string `json:"senders" yaml:"senders" toml:"senders"`
+
+	// Chats limits the route to chat IDs (empty = all).
+	Chats []string `json:"chats" yaml:"chats" toml:"chats"`
+
+	// Prefix matches messages starting with a prefix.
+	Prefix string `json:"prefix" yaml:"prefix" toml:"prefix"`
+
+	// Agent names the agent that handles matching messages. Empty uses the
+	// default agent.
+	Agent string `json:"agent" yaml:"agent" toml:"agent"`
+
+	// Throttle limits how often the agent responds in each chat.
+	Throttle ThrottleConfig `json:"throttle" yaml:"throttle" toml:"throttle"`
+}
+
+// ThrottleConfig limits agent responses per chat, e.g. at most once every
+// 30s in groups.
+type ThrottleConfig struct {
+	// Interval is the minimum time between responses in a chat. Zero
+	// disables throttling.
+	Interval time.Duration `json:"interval" yaml:"interval" toml:"interval"`
+
+	// Coalesce answers messages received during the interval with one
+	// agent call once it ends, instead of ignoring them.
+	Coalesce bool `json:"coalesce" yaml:"coalesce" toml:"coalesce"`
 }
 
 // ChannelsConfig configures messaging channels.
 type ChannelsConfig struct {
-	Telegram TelegramConfig `json:"telegram" yaml:"telegram"`
-	Discord  DiscordConfig  `json:"discord" yaml:"discord"`
+	Telegram TelegramConfig `json:"telegram" yaml:"telegram" toml:"telegram"`
+	Discord  DiscordConfig  `json:"discord" yaml:"discord" toml:"discord"`
 }
 
-// TelegramConfig configures the Telegram channel.
+// TelegramConfig configures the Telegram channel. TokenSecret references
+// the bot token in a secret store, e.g. "vault:bots/telegram#token", and
+// takes precedence over Token. ForumTopics addresses forum topics as
+// separate chats (see telegram.Config.ForumTopics).
 type TelegramConfig struct {
-	Enabled bool   `json:"enabled" yaml:"enabled"`
-	Token   string `json:"token" yaml:"token"`
+	Enabled        bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Token          string `json:"token" yaml:"token" toml:"token"`
+	TokenSecret    string `json:"token_secret" yaml:"token_secret" toml:"token_secret"`
+	RequireMention bool   `json:"require_mention" yaml:"require_mention" toml:"require_mention"`
+	ForumTopics    bool   `json:"forum_topics" yaml:"forum_topics" toml:"forum_topics"`
 }
 
-// DiscordConfig configures the Discord channel.
+// DiscordConfig configures the Discord channel. TokenSecret references the
+// bot token in a secret store and takes precedence over Token.
 type DiscordConfig struct {
-	Enabled bool   `json:"enabled" yaml:"enabled"`
-	Token   string `json:"token" yaml:"token"`
-	GuildID string `json:"guild_id" yaml:"guild_id"`
+	Enabled        bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Token          string `json:"token" yaml:"token" toml:"token"`
+	TokenSecret    string `json:"token_secret" yaml:"token_secret" toml:"token_secret"`
+	GuildID        string `json:"guild_id" yaml:"guild_id" toml:"guild_id"`
+	RequireMention bool   `json:"require_mention" yaml:"require_mention" toml:"require_mention"`
+}
+
+// MentionGated returns the names of channels that only respond in groups
+// when mentioned.
+func (c ChannelsConfig) MentionGated() []string {
+	var names []string
+	if c.Telegram.RequireMention {
+		names = append(names, "telegram")
+	}
+	if c.Discord.RequireMention {
+		names = append(names, "discord")
+	}
+	return names
+}
+
+// BridgeConfig mirrors messages between chats on different channels.
+type BridgeConfig struct {
+	Links []LinkConfig `json:"links" yaml:"links" toml:"links"`
+
+	// IgnoreSenders are sender IDs never mirrored, typically the bots' own
+	// accounts on each platform.
+	IgnoreSenders []string `json:"ignore_senders" yaml:"ignore_senders" toml:"ignore_senders"`
+}
+
+// LinkConfig connects two chats, each written as "channel:chat_id".
+// Messages are mirrored both ways unless OneWay is set, in which case only
+// messages from A reach B.
+type LinkConfig struct {
+	A      string `json:"a" yaml:"a" toml:"a"`
+	B      string `json:"b" yaml:"b" toml:"b"`
+	OneWay bool   `json:"one_way" yaml:"one_way" toml:"one_way"`
+}
+
+// AccessConfig configures who may use the administrative chat commands,
+// such as /var.
+type AccessConfig struct {
+	// Admins are senders with the admin role, as "<channel>:<sender>".
+	Admins []string `json:"admins" yaml:"admins" toml:"admins"`
 }
 
 // ToolsConfig configures available tools.
 type ToolsConfig struct {
-	Browser BrowserToolConfig `json:"browser" yaml:"browser"`
-	Shell   ShellToolConfig   `json:"shell" yaml:"shell"`
+	Browser BrowserToolConfig `json:"browser" yaml:"browser" toml:"browser"`
+	Shell   ShellToolConfig   `json:"shell" yaml:"shell" toml:"shell"`
 }
 
 // BrowserToolConfig configures the browser automation tool.
 type BrowserToolConfig struct {
-	Enabled  bool   `json:"enabled" yaml:"enabled"`
-	Headless bool   `json:"headless" yaml:"headless"`
-	UserData string `json:"user_data" yaml:"user_data"`
+	Enabled  bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Headless bool   `json:"headless" yaml:"headless" toml:"headless"`
+	UserData string `json:"user_data" yaml:"user_data" toml:"user_data"`
 }
 
 // ShellToolConfig configures the shell execution tool.
 type ShellToolConfig struct {
-	Enabled    bool     `json:"enabled" yaml:"enabled"`
-	WorkingDir string   `json:"working_dir" yaml:"working_dir"`
-	Allowlist  []string `json:"allowlist" yaml:"allowlist"`
+	Enabled    bool     `json:"enabled" yaml:"enabled" toml:"enabled"`
+	WorkingDir string   `json:"working_dir" yaml:"working_dir" toml:"working_dir"`
+	Allowlist  []string `json:"allowlist" yaml:"allowlist" toml:"allowlist"`
 }
 
 // ObservabilityConfig configures observability features.
 type ObservabilityConfig struct {
-	Enabled  bool   `json:"enabled" yaml:"enabled"`
-	Provider string `json:"provider" yaml:"provider"`
-	Endpoint string `json:"endpoint" yaml:"endpoint"`
-	APIKey   string `json:"api_key" yaml:"api_key"`
+	Enabled  bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Provider string `json:"provider" yaml:"provider" toml:"provider"`
+	Endpoint string `json:"endpoint" yaml:"endpoint" toml:"endpoint"`
+	APIKey   string `json:"api_key" yaml:"api_key" toml:"api_key"`
 }
@@ -142,3 +142,154 @@ func TestLoadNonexistent(t *testing.T) {
 		t.Error("Expected error for nonexistent file")
 	}
 }
+
+func TestLoadTOML(t *testing.T) {
+	t.Setenv("TEST_ENVOY_MODEL", "gpt-4o")
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.toml")
+
+	content := `
+[gateway]
+address = "localhost:7000"
+read_timeout = "10s"
+
+[agent]
+provider = "openai"
+model = "${TEST_ENVOY_MODEL}"
+api_key = "${TEST_ENVOY_MISSING:-fallback}"
+
+[agents.support]
+system_prompt = "You are a support agent."
+
+[[routes]]
+channels = ["discord"]
+chat_types = ["group"]
+agent = "support"
+
+[routes.throttle]
+interval = "30s"
+coalesce = true
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Gateway.Address != "localhost:7000" {
+		t.Errorf("Gateway.Address = %s, want localhost:7000", cfg.Gateway.Address)
+	}
+	if cfg.Gateway.ReadTimeout != 10*time.Second {
+		t.Errorf("Gateway.ReadTimeout = %v, want 10s", cfg.Gateway.ReadTimeout)
+	}
+	if cfg.Agent.Model != "gpt-4o" {
+		t.Errorf("Agent.Model = %s, want gpt-4o", cfg.Agent.Model)
+	}
+	if cfg.Agent.APIKey != "fallback" {
+		t.Errorf("Agent.APIKey = %s, want fallback", cfg.Agent.APIKey)
+	}
+	if cfg.Agents["support"].SystemPrompt != "You are a support agent." {
+		t.Errorf("Agents[support] = %+v", cfg.Agents["support"])
+	}
+	if len(cfg.Routes) != 1 || cfg.Routes[0].Agent != "support" || cfg.Routes[0].ChatTypes[0] != "group" {
+		t.Errorf("Routes = %+v", cfg.Routes)
+	}
+	if throttle := cfg.Routes[0].Throttle; throttle.Interval != 30*time.Second || !throttle.Coalesce {
+		t.Errorf("Routes[0].Throttle = %+v, want 30s coalescing", throttle)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("TEST_ENVOY_TOKEN", "secret")
+
+	tests := []struct {
+		in, want string
+	}{
+		{"token: ${TEST_ENVOY_TOKEN}", "token: secret"},
+		{"token: ${TEST_ENVOY_UNSET:-none}", "token: none"},
+		{"token: ${TEST_ENVOY_UNSET}", "token: "},
+		{"password: pa$$word", "password: pa$$word"},
+		{"token: $TEST_ENVOY_TOKEN", "token: $TEST_ENVOY_TOKEN"},
+	}
+	for _, tt := range tests {
+		if got := Interpolate(tt.in); got != tt.want {
+			t.Errorf("Interpolate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadInterpolatesValues(t *testing.T) {
+	// A value that would add YAML structure if substituted before parsing
+	t.Setenv("TEST_ENVOY_TOKEN", "tg-token\n  discord:\n    enabled: true")
+	t.Setenv("TEST_ENVOY_ADDRESS", ":9999")
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+gateway:
+  address: "${TEST_ENVOY_ADDRESS}"
+channels:
+  telegram:
+    token: ${TEST_ENVOY_TOKEN}
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Gateway.Address != ":9999" {
+		t.Errorf("Gateway.Address = %q", cfg.Gateway.Address)
+	}
+	if cfg.Channels.Telegram.Token != os.Getenv("TEST_ENVOY_TOKEN") {
+		t.Errorf("Telegram.Token = %q, want the variable verbatim", cfg.Channels.Telegram.Token)
+	}
+	if cfg.Channels.Discord.Enabled {
+		t.Error("variable value changed the config structure")
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "telegram"), []byte("tg-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ENVOY_DISCORD", "dc-token")
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := `
+channels:
+  telegram:
+    token_secret: file:telegram
+  discord:
+    token_secret: env:TEST_ENVOY_DISCORD
+secrets:
+  dir: ` + dir + `
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Channels.Telegram.Token != "tg-token" {
+		t.Errorf("Telegram.Token = %q, want tg-token", cfg.Channels.Telegram.Token)
+	}
+	if cfg.Channels.Discord.Token != "dc-token" {
+		t.Errorf("Discord.Token = %q, want dc-token", cfg.Channels.Discord.Token)
+	}
+	if refs := cfg.SecretRefs(); len(refs) != 2 {
+		t.Errorf("SecretRefs = %q, want 2 refs", refs)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "telegram")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Error("Expected error for unresolvable secret")
+	}
+}
@@ -1,17 +1,26 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
+// secretsTimeout bounds resolving the secrets of a configuration.
+const secretsTimeout = 30 * time.Second
+
 // Load reads configuration from a file and environment variables.
-// Environment variables override file values.
+// Environment variables override file values, and credentials referencing
+// secrets are resolved last.
 func Load(path string) (*Config, error) {
 	cfg := Default()
 
@@ -23,11 +32,27 @@ func Load(path string) (*Config, error) {
 
 	loadEnv(&cfg)
 
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+	if err := ResolveSecrets(ctx, &cfg, cfg.Secrets.Resolver()); err != nil {
+		return nil, fmt.Errorf("resolve secrets: %w", err)
+	}
 	return &cfg, nil
 }
 
-// loadFile reads configuration from a YAML or JSON file.
+// loadFile reads configuration from a YAML, JSON, or TOML file, then
+// interpolates environment variables in its string values. Interpolating
+// after parsing keeps variable values from changing the file's structure.
 func loadFile(path string, cfg *Config) error {
+	if err := parseFile(path, cfg); err != nil {
+		return err
+	}
+	interpolateValue(reflect.ValueOf(cfg).Elem())
+	return nil
+}
+
+// parseFile decodes a YAML, JSON, or TOML file into cfg.
+func parseFile(path string, cfg *Config) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
@@ -39,6 +64,9 @@ func loadFile(path string, cfg *Config) error {
 		return yaml.Unmarshal(data, cfg)
 	case ".json":
 		return json.Unmarshal(data, cfg)
+	case ".toml":
+		_, err := toml.Decode(string(data), cfg)
+		return err
 	default:
 		// Try YAML first, then JSON
 		if err := yaml.Unmarshal(data, cfg); err != nil {
@@ -50,10 +78,17 @@ func loadFile(path string, cfg *Config) error {
 
 // loadEnv loads configuration from environment variables.
 func loadEnv(cfg *Config) {
+	if v := os.Getenv("ENVOY_MODE"); v != "" {
+		cfg.Mode = v
+	}
+
 	// Gateway
 	if v := os.Getenv("ENVOY_GATEWAY_ADDRESS"); v != "" {
 		cfg.Gateway.Address = v
 	}
+	if v := os.Getenv("ENVOY_ADMIN_TOKEN"); v != "" {
+		cfg.Gateway.AdminToken = v
+	}
 
 	// Agent
 	if v := os.Getenv("ENVOY_AGENT_PROVIDER"); v != "" {
@@ -102,6 +137,64 @@ func loadEnv(cfg *Config) {
 	}
 }
 
+// interpolation matches ${VAR} and ${VAR:-default}.
+var interpolation = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolateValue interpolates the strings in v, which must be settable,
+// and in the structs, pointers, slices, maps, and interfaces it holds.
+func interpolateValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(Interpolate(v.String()))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			interpolateValue(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				interpolateValue(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			interpolateValue(v.Index(i))
+		}
+	case reflect.Map:
+		// Map values are not addressable; interpolate copies and store them
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			interpolateValue(elem)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Interface:
+		if v.IsNil() || !v.CanSet() {
+			return
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		interpolateValue(elem)
+		v.Set(elem)
+	}
+}
+
+// Interpolate replaces ${VAR} with the value of the environment variable VAR,
+// and ${VAR:-default} with default when VAR is unset or empty. Unlike
+// ExpandEnvVars, bare $VAR is left alone so values such as passwords may
+// contain dollar signs.
+func Interpolate(s string) string {
+	return interpolation.ReplaceAllStringFunc(s, func(match string) string {
+		groups := interpolation.FindStringSubmatch(match)
+		if v := os.Getenv(groups[1]); v != "" {
+			return v
+		}
+		return groups[2]
+	})
+}
+
 // ExpandEnvVars expands environment variables in string values.
 // Supports ${VAR} and $VAR syntax.
 func ExpandEnvVars(s string) string {
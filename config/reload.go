@@ -0,0 +1,97 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultPollInterval is how often a Reloader checks the config file for
+// changes.
+const DefaultPollInterval = 2 * time.Second
+
+// ReloadOptions configures a Reloader.
+type ReloadOptions struct {
+	// PollInterval is how often the file's modification time is checked
+	// (default: DefaultPollInterval).
+	PollInterval time.Duration
+
+	Logger *slog.Logger
+}
+
+// Reloader reapplies a config file to a Wiring when the file changes, when
+// the process receives SIGHUP, or when Reload is called (e.g., from an admin
+// API).
+type Reloader struct {
+	path   string
+	wiring *Wiring
+	opts   ReloadOptions
+}
+
+// NewReloader creates a Reloader for the config file at path.
+func NewReloader(path string, wiring *Wiring, opts ReloadOptions) *Reloader {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Reloader{path: path, wiring: wiring, opts: opts}
+}
+
+// Reload loads the config file and applies it.
+func (r *Reloader) Reload(ctx context.Context) error {
+	cfg, err := Load(r.path)
+	if err != nil {
+		return err
+	}
+	if err := r.wiring.Apply(ctx, cfg); err != nil {
+		return fmt.Errorf("apply config: %w", err)
+	}
+	r.opts.Logger.Info("config reloaded", "path", r.path)
+	return nil
+}
+
+// Run watches for file changes and SIGHUP until ctx is done. Reload
+// failures are logged and the previous configuration stays in effect.
+func (r *Reloader) Run(ctx context.Context) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	modTime := r.modTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			modTime = r.modTime()
+		case <-ticker.C:
+			t := r.modTime()
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+		}
+		if err := r.Reload(ctx); err != nil {
+			r.opts.Logger.Error("config reload failed", "path", r.path, "error", err)
+		}
+	}
+}
+
+// modTime returns the config file's modification time, or the zero time if
+// it cannot be read.
+func (r *Reloader) modTime() time.Time {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,489 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/agentplexus/envoy/agent"
+	"github.com/agentplexus/envoy/bridge"
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/channels/adapters/discord"
+	"github.com/agentplexus/envoy/channels/adapters/telegram"
+)
+
+// Channel names created from ChannelsConfig.
+var channelNames = []string{"telegram", "discord"}
+
+// BuildOptions customizes Build.
+type BuildOptions struct {
+	Logger *slog.Logger
+
+	// RouterOptions are passed to the router.
+	RouterOptions []channels.RouterOption
+
+	// NewAgent creates a named agent (default: agent.New).
+	NewAgent func(name string, config AgentConfig) (channels.AgentProcessor, error)
+
+	// Interceptors wrap every agent created, the first outermost.
+	Interceptors []agent.Interceptor
+
+	// PromptVars supplies the variables of system prompt templates to the
+	// default agents, e.g. chatvars.Vars.FromContext. Without it, system
+	// prompts are used verbatim.
+	PromptVars func(ctx context.Context) (map[string]string, error)
+
+	// NewChannel creates the named channel adapter, "telegram" or
+	// "discord", from the channels config (default: the built-in adapters).
+	NewChannel func(name string, config ChannelsConfig) (channels.Channel, error)
+}
+
+// Wiring is a router built from configuration, along with the agents it
+// owns. Apply updates it in place when the configuration changes.
+type Wiring struct {
+	Router *channels.Router
+
+	opts  BuildOptions
+	table atomic.Pointer[routeTable]
+
+	// mu serializes Apply and guards the fields below.
+	mu           sync.Mutex
+	config       Config
+	agents       map[string]channels.AgentProcessor
+	agentConfigs map[string]AgentConfig
+}
+
+// Build creates a router with the configured channels registered, agents
+// bound to their routes, agent processing enabled, and bridged chats
+// linked.
+//
+// The agent section configures the default agent; entries under agents add
+// named agents that inherit any unset fields from it. Without routes every
+// message goes to the default agent; with routes, only messages matching a
+// route are processed, by the agent of the first matching route.
+//
+// In relay mode no agents are created and messages are not processed by
+// agents: the router only delivers messages sent through it and mirrors
+// messages between bridged chats.
+func Build(cfg *Config, opts BuildOptions) (*Wiring, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.NewAgent == nil {
+		opts.NewAgent = newAgent(opts.Logger, opts.PromptVars)
+	}
+	if opts.NewChannel == nil {
+		opts.NewChannel = newChannel(opts.Logger)
+	}
+
+	w := &Wiring{
+		Router:       channels.NewRouter(opts.Logger, opts.RouterOptions...),
+		opts:         opts,
+		agents:       make(map[string]channels.AgentProcessor),
+		agentConfigs: make(map[string]AgentConfig),
+	}
+	w.table.Store(&routeTable{})
+	w.Router.Agents().SetSelector(w.selectAgent)
+	w.Router.OnMessage(channels.RoutePattern{Match: w.matches}, w.handle)
+	w.Router.OnMessage(channels.RoutePattern{Match: w.bridged}, w.mirror)
+
+	if err := w.Apply(context.Background(), cfg); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Agent returns a named agent.
+func (w *Wiring) Agent(name string) (channels.AgentProcessor, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	a, ok := w.agents[name]
+	return a, ok
+}
+
+// Close releases the agents. The router's channels are disconnected by
+// stopping the router.
+func (w *Wiring) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := closeAgents(w.agents)
+	w.agents = make(map[string]channels.AgentProcessor)
+	return err
+}
+
+// Apply updates the wiring to match cfg without dropping connections that
+// did not change: channels whose settings changed are reconnected, added
+// channels are registered (and connected if the router is running), removed
+// channels are disconnected, changed agents are replaced, and routes are
+// swapped atomically. Throttle state restarts with the new routes.
+//
+// If an agent, route, or channel cannot be created, nothing is changed.
+// Connection failures of added channels are returned after the rest of the
+// configuration is applied; those channels keep retrying in the background.
+func (w *Wiring) Apply(ctx context.Context, cfg *Config) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Create everything that can fail before changing anything
+	if err := validateMode(cfg); err != nil {
+		return err
+	}
+	agentConfigs := make(map[string]AgentConfig)
+	defaultAgent := ""
+	if !cfg.Relay() {
+		agentConfigs[channels.DefaultAgentName] = cfg.Agent
+		for name, c := range cfg.Agents {
+			agentConfigs[name] = c.inherit(cfg.Agent)
+		}
+		defaultAgent = channels.DefaultAgentName
+	}
+	created := make(map[string]channels.AgentProcessor)
+	for name, c := range agentConfigs {
+		if old, ok := w.agentConfigs[name]; ok && old == c {
+			continue
+		}
+		a, err := w.opts.NewAgent(name, c)
+		if err != nil {
+			_ = closeAgents(created)
+			return fmt.Errorf("create agent %s: %w", name, err)
+		}
+		if len(w.opts.Interceptors) > 0 {
+			a = agent.Chain(w.opts.Interceptors...)(a)
+		}
+		created[name] = a
+	}
+
+	table, err := w.buildTable(cfg, agentConfigs)
+	if err != nil {
+		_ = closeAgents(created)
+		return err
+	}
+
+	var added []channels.Channel
+	var removed []string
+	for _, name := range channelNames {
+		oldOn, oldSettings := channelSettings(name, w.config.Channels)
+		newOn, newSettings := channelSettings(name, cfg.Channels)
+		changed := oldOn && newOn && oldSettings != newSettings
+		if oldOn && (!newOn || changed) {
+			removed = append(removed, name)
+		}
+		if newOn && (!oldOn || changed) {
+			ch, err := w.opts.NewChannel(name, cfg.Channels)
+			if err != nil {
+				_ = closeAgents(created)
+				return fmt.Errorf("create %s adapter: %w", name, err)
+			}
+			added = append(added, ch)
+		}
+	}
+
+	// Swap agents, then routes, then channels
+	registry := w.Router.Agents()
+	retired := make(map[string]channels.AgentProcessor)
+	for name, a := range created {
+		if old, ok := w.agents[name]; ok {
+			retired[name] = old
+		}
+		w.agents[name] = a
+		registry.Register(name, a)
+	}
+	for name, a := range w.agents {
+		if _, ok := agentConfigs[name]; !ok {
+			registry.Unregister(name)
+			delete(w.agents, name)
+			retired[name] = a
+		}
+	}
+	registry.SetDefault(defaultAgent)
+	w.agentConfigs = agentConfigs
+	w.table.Store(table)
+
+	var errs []error
+	for _, name := range removed {
+		if err := w.Router.RemoveChannel(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("remove channel %s: %w", name, err))
+		}
+	}
+	for _, ch := range added {
+		if err := w.Router.AddChannel(ctx, ch); err != nil {
+			errs = append(errs, fmt.Errorf("add channel %s: %w", ch.Name(), err))
+		}
+	}
+	w.config = *cfg
+
+	if err := closeAgents(retired); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// routeTable is an immutable set of compiled routes.
+type routeTable struct {
+	routes []route
+
+	// gated lists channels that only respond in groups when mentioned.
+	gated []string
+
+	// bridge mirrors messages between linked chats, if any are configured.
+	bridge *bridge.Bridge
+}
+
+// route is a compiled RouteConfig.
+type route struct {
+	pattern channels.RoutePattern
+	agent   string
+	handler channels.MessageHandler
+}
+
+// validateMode checks the mode and the settings it rules out.
+func validateMode(cfg *Config) error {
+	switch cfg.Mode {
+	case "", ModeAgent:
+		return nil
+	case ModeRelay:
+		if len(cfg.Routes) > 0 || len(cfg.Agents) > 0 {
+			return fmt.Errorf("relay mode: routes and agents require agent mode")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q", cfg.Mode)
+}
+
+// buildTable compiles the configured routes and bridge. Without routes, a
+// single route sends every message to the default agent, except in relay
+// mode, which has no routes.
+func (w *Wiring) buildTable(cfg *Config, agents map[string]AgentConfig) (*routeTable, error) {
+	configs := cfg.Routes
+	if len(configs) == 0 && !cfg.Relay() {
+		configs = []RouteConfig{{}}
+	}
+
+	table := &routeTable{
+		routes: make([]route, len(configs)),
+		gated:  cfg.Channels.MentionGated(),
+	}
+	if len(cfg.Bridge.Links) > 0 {
+		b, err := w.newBridge(cfg.Bridge)
+		if err != nil {
+			return nil, err
+		}
+		table.bridge = b
+	}
+	for i, rc := range configs {
+		name := rc.Agent
+		if name == "" {
+			name = channels.DefaultAgentName
+		}
+		if _, ok := agents[name]; !ok {
+			return nil, fmt.Errorf("route %d: unknown agent %q", i, name)
+		}
+		table.routes[i] = route{
+			pattern: rc.Pattern(),
+			agent:   name,
+			handler: channels.Throttle(channels.ThrottleConfig{
+				Interval: rc.Throttle.Interval,
+				Coalesce: rc.Throttle.Coalesce,
+			}, w.Router.ProcessWithAgent()),
+		}
+	}
+	return table, nil
+}
+
+// match returns the first route matching msg, or nil. Group messages on
+// mention-gated channels match only if they mention the bot.
+func (t *routeTable) match(msg channels.IncomingMessage) *route {
+	if !msg.MentionsBot && msg.ChatType != channels.ChannelTypeDM {
+		for _, name := range t.gated {
+			if name == msg.ChannelName {
+				return nil
+			}
+		}
+	}
+	for i := range t.routes {
+		if t.routes[i].pattern.Matches(msg) {
+			return &t.routes[i]
+		}
+	}
+	return nil
+}
+
+func (w *Wiring) matches(msg channels.IncomingMessage) bool {
+	return w.table.Load().match(msg) != nil
+}
+
+// handle runs the handler of the route matching msg.
+func (w *Wiring) handle(ctx context.Context, msg channels.IncomingMessage) error {
+	r := w.table.Load().match(msg)
+	if r == nil {
+		return nil
+	}
+	return r.handler(ctx, msg)
+}
+
+func (w *Wiring) bridged(msg channels.IncomingMessage) bool {
+	b := w.table.Load().bridge
+	return b != nil && b.Pattern().Matches(msg)
+}
+
+// mirror passes msg to the bridge.
+func (w *Wiring) mirror(ctx context.Context, msg channels.IncomingMessage) error {
+	b := w.table.Load().bridge
+	if b == nil {
+		return nil
+	}
+	return b.Handler()(ctx, msg)
+}
+
+// newBridge creates a bridge sending through the router. Loop prevention
+// state starts over with each new bridge.
+func (w *Wiring) newBridge(c BridgeConfig) (*bridge.Bridge, error) {
+	links := make([]bridge.Link, len(c.Links))
+	for i, lc := range c.Links {
+		a, err := parseEndpoint(lc.A)
+		if err != nil {
+			return nil, fmt.Errorf("bridge link %d: %w", i, err)
+		}
+		b, err := parseEndpoint(lc.B)
+		if err != nil {
+			return nil, fmt.Errorf("bridge link %d: %w", i, err)
+		}
+		links[i] = bridge.Link{A: a, B: b, OneWay: lc.OneWay}
+	}
+	b, err := bridge.New(bridge.Config{
+		Sender:        w.Router,
+		Links:         links,
+		IgnoreSenders: c.IgnoreSenders,
+		Logger:        w.opts.Logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create bridge: %w", err)
+	}
+	return b, nil
+}
+
+// parseEndpoint parses a "channel:chat_id" endpoint.
+func parseEndpoint(s string) (bridge.Endpoint, error) {
+	channel, chatID, ok := strings.Cut(s, ":")
+	if !ok || channel == "" || chatID == "" {
+		return bridge.Endpoint{}, fmt.Errorf("invalid endpoint %q, want channel:chat_id", s)
+	}
+	return bridge.Endpoint{Channel: channel, ChatID: chatID}, nil
+}
+
+// selectAgent picks the agent of the route matching msg.
+func (w *Wiring) selectAgent(msg channels.IncomingMessage) string {
+	if r := w.table.Load().match(msg); r != nil {
+		return r.agent
+	}
+	return ""
+}
+
+// channelSettings reports whether a channel is enabled and returns the
+// settings that require reconnecting when changed.
+func channelSettings(name string, c ChannelsConfig) (bool, any) {
+	switch name {
+	case "telegram":
+		return c.Telegram.Enabled, TelegramConfig{Token: c.Telegram.Token}
+	case "discord":
+		return c.Discord.Enabled, DiscordConfig{Token: c.Discord.Token, GuildID: c.Discord.GuildID}
+	}
+	return false, nil
+}
+
+// newChannel returns a channel constructor backed by the built-in adapters.
+func newChannel(logger *slog.Logger) func(string, ChannelsConfig) (channels.Channel, error) {
+	return func(name string, c ChannelsConfig) (channels.Channel, error) {
+		switch name {
+		case "telegram":
+			return telegram.New(telegram.Config{
+				Token:       c.Telegram.Token,
+				Logger:      logger,
+				ForumTopics: c.Telegram.ForumTopics,
+			})
+		case "discord":
+			return discord.New(discord.Config{
+				Token:   c.Discord.Token,
+				GuildID: c.Discord.GuildID,
+				Logger:  logger,
+			})
+		}
+		return nil, fmt.Errorf("unknown channel %q", name)
+	}
+}
+
+// Pattern returns the router pattern for the route.
+func (r RouteConfig) Pattern() channels.RoutePattern {
+	pattern := channels.RoutePattern{
+		Channels: r.Channels,
+		Senders:  r.Senders,
+		Chats:    r.Chats,
+		Prefix:   r.Prefix,
+	}
+	for _, t := range r.ChatTypes {
+		pattern.ChatTypes = append(pattern.ChatTypes, channels.ChannelType(t))
+	}
+	return pattern
+}
+
+// inherit fills unset fields from base.
+func (c AgentConfig) inherit(base AgentConfig) AgentConfig {
+	if c.Provider == "" {
+		c.Provider = base.Provider
+	}
+	if c.Model == "" {
+		c.Model = base.Model
+	}
+	if c.APIKey == "" {
+		c.APIKey = base.APIKey
+	}
+	if c.BaseURL == "" {
+		c.BaseURL = base.BaseURL
+	}
+	if c.Temperature == 0 {
+		c.Temperature = base.Temperature
+	}
+	if c.MaxTokens == 0 {
+		c.MaxTokens = base.MaxTokens
+	}
+	if c.SystemPrompt == "" {
+		c.SystemPrompt = base.SystemPrompt
+	}
+	return c
+}
+
+// closeAgents closes the agents that implement io.Closer.
+func closeAgents(agents map[string]channels.AgentProcessor) error {
+	var errs []error
+	for name, a := range agents {
+		if closer, ok := a.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close agent %s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newAgent returns an agent constructor backed by agent.New.
+func newAgent(logger *slog.Logger, promptVars func(context.Context) (map[string]string, error)) func(string, AgentConfig) (channels.AgentProcessor, error) {
+	return func(name string, c AgentConfig) (channels.AgentProcessor, error) {
+		return agent.New(agent.Config{
+			Provider:     c.Provider,
+			Model:        c.Model,
+			APIKey:       c.APIKey,
+			BaseURL:      c.BaseURL,
+			Temperature:  c.Temperature,
+			MaxTokens:    c.MaxTokens,
+			SystemPrompt: c.SystemPrompt,
+			PromptVars:   promptVars,
+			Logger:       logger.With("agent", name),
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/agentplexus/envoy/agent"
+	"github.com/agentplexus/envoy/channels"
+)
+
+type namedAgent struct {
+	name   string
+	config AgentConfig
+}
+
+func (a *namedAgent) Process(_ context.Context, _, content string) (string, error) {
+	return a.name + ": " + content, nil
+}
+
+// fakeChannel records connection state.
+type fakeChannel struct {
+	name  string
+	token string
+
+	mu        sync.Mutex
+	connected bool
+	sent      []string
+}
+
+func (f *fakeChannel) Name() string { return f.name }
+
+func (f *fakeChannel) Connect(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = true
+	return nil
+}
+
+func (f *fakeChannel) Disconnect(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = false
+	return nil
+}
+
+func (f *fakeChannel) Send(_ context.Context, chatID string, msg channels.OutgoingMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, chatID+": "+msg.Content)
+	return nil
+}
+
+func (f *fakeChannel) OnMessage(channels.MessageHandler) {}
+
+func (f *fakeChannel) OnEvent(channels.EventHandler) {}
+
+func (f *fakeChannel) isConnected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
+
+func buildTest(t *testing.T, cfg *Config) *Wiring {
+	t.Helper()
+	w, err := Build(cfg, BuildOptions{
+		NewAgent: func(name string, c AgentConfig) (channels.AgentProcessor, error) {
+			return &namedAgent{name: name, config: c}, nil
+		},
+		NewChannel: func(name string, c ChannelsConfig) (channels.Channel, error) {
+			token := c.Telegram.Token
+			if name == "discord" {
+				token = c.Discord.Token
+			}
+			return &fakeChannel{name: name, token: token}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return w
+}
+
+func TestBuildRoutes(t *testing.T) {
+	cfg := Default()
+	cfg.Agents = map[string]AgentConfig{
+		"support": {Model: "support-model"},
+	}
+	cfg.Routes = []RouteConfig{
+		{Channels: []string{"discord"}, Agent: "support"},
+		{Prefix: "!ask"},
+	}
+	w := buildTest(t, &cfg)
+
+	a, ok := w.Agent("support")
+	if !ok {
+		t.Fatal("support agent not created")
+	}
+	support := a.(*namedAgent)
+	if support.config.Model != "support-model" || support.config.Provider != cfg.Agent.Provider {
+		t.Errorf("support config = %+v, want model override and inherited provider", support.config)
+	}
+
+	tests := []struct {
+		msg  channels.IncomingMessage
+		want string
+	}{
+		{channels.IncomingMessage{ChannelName: "discord", Content: "hi"}, "support"},
+		{channels.IncomingMessage{ChannelName: "telegram", Content: "!ask hi"}, channels.DefaultAgentName},
+	}
+	for _, tt := range tests {
+		name, _, err := w.Router.Agents().Select(tt.msg)
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		if name != tt.want {
+			t.Errorf("Select(%s %q) = %s, want %s", tt.msg.ChannelName, tt.msg.Content, name, tt.want)
+		}
+	}
+}
+
+func TestBuildUnknownAgent(t *testing.T) {
+	cfg := Default()
+	cfg.Routes = []RouteConfig{{Agent: "missing"}}
+
+	_, err := Build(&cfg, BuildOptions{
+		NewAgent: func(name string, c AgentConfig) (channels.AgentProcessor, error) {
+			return &namedAgent{name: name}, nil
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), `unknown agent "missing"`) {
+		t.Errorf("Build error = %v, want unknown agent", err)
+	}
+}
+
+// closingAgent records whether it was closed.
+type closingAgent struct {
+	namedAgent
+	closed bool
+}
+
+func (a *closingAgent) Close() error {
+	a.closed = true
+	return nil
+}
+
+func TestBuildInterceptors(t *testing.T) {
+	cfg := Default()
+	var created *closingAgent
+	shout := agent.Wrap(func(ctx context.Context, sessionID, content string, next agent.ProcessFunc) (string, error) {
+		response, err := next(ctx, sessionID, content)
+		return strings.ToUpper(response), err
+	}, nil)
+	w, err := Build(&cfg, BuildOptions{
+		NewAgent: func(name string, c AgentConfig) (channels.AgentProcessor, error) {
+			created = &closingAgent{namedAgent: namedAgent{name: name}}
+			return created, nil
+		},
+		Interceptors: []agent.Interceptor{
+			agent.Guard(func(_ context.Context, _, content string) error {
+				if strings.Contains(content, "secret") {
+					return errors.New("blocked")
+				}
+				return nil
+			}),
+			shout,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	a, _ := w.Agent(channels.DefaultAgentName)
+	if got, err := a.Process(context.Background(), "s1", "hi"); err != nil || got != "DEFAULT: HI" {
+		t.Errorf("Process = %q, %v", got, err)
+	}
+	if _, err := a.Process(context.Background(), "s1", "the secret"); err == nil || err.Error() != "blocked" {
+		t.Errorf("guarded Process error = %v", err)
+	}
+	if err := w.Close(); err != nil || !created.closed {
+		t.Errorf("Close = %v, agent closed %v", err, created.closed)
+	}
+}
+
+func TestApply(t *testing.T) {
+	cfg := Default()
+	cfg.Channels.Telegram = TelegramConfig{Enabled: true, Token: "tg"}
+	cfg.Channels.Discord = DiscordConfig{Enabled: true, Token: "dc"}
+	w := buildTest(t, &cfg)
+
+	ctx := context.Background()
+	if err := w.Router.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Router.Stop(ctx)
+
+	tg, _ := w.Router.GetChannel("telegram")
+	dc, _ := w.Router.GetChannel("discord")
+	def, _ := w.Agent(channels.DefaultAgentName)
+
+	// Disable Discord, add an agent and route, and gate Telegram groups
+	next := cfg
+	next.Channels.Discord.Enabled = false
+	next.Channels.Telegram.RequireMention = true
+	next.Agents = map[string]AgentConfig{"support": {}}
+	next.Routes = []RouteConfig{{Prefix: "!help", Agent: "support"}}
+	if err := w.Apply(ctx, &next); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if ch, _ := w.Router.GetChannel("telegram"); ch != tg || !tg.(*fakeChannel).isConnected() {
+		t.Error("unchanged telegram channel was replaced or disconnected")
+	}
+	if _, ok := w.Router.GetChannel("discord"); ok || dc.(*fakeChannel).isConnected() {
+		t.Error("discord channel should be removed and disconnected")
+	}
+	if a, _ := w.Agent(channels.DefaultAgentName); a != def {
+		t.Error("unchanged default agent was replaced")
+	}
+
+	tests := []struct {
+		msg   channels.IncomingMessage
+		match bool
+		agent string
+	}{
+		{channels.IncomingMessage{ChannelName: "telegram", ChatType: channels.ChannelTypeDM, Content: "!help me"}, true, "support"},
+		{channels.IncomingMessage{ChannelName: "telegram", ChatType: channels.ChannelTypeDM, Content: "hello"}, false, ""},
+		{channels.IncomingMessage{ChannelName: "telegram", ChatType: channels.ChannelTypeGroup, Content: "!help me"}, false, ""},
+	}
+	for _, tt := range tests {
+		if got := w.matches(tt.msg); got != tt.match {
+			t.Errorf("matches(%s %q) = %v, want %v", tt.msg.ChatType, tt.msg.Content, got, tt.match)
+		}
+		if got := w.selectAgent(tt.msg); got != tt.agent {
+			t.Errorf("selectAgent(%s %q) = %q, want %q", tt.msg.ChatType, tt.msg.Content, got, tt.agent)
+		}
+	}
+
+	// A new token reconnects the channel
+	next.Channels.Telegram.Token = "tg2"
+	if err := w.Apply(ctx, &next); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	ch, _ := w.Router.GetChannel("telegram")
+	if ch == tg || ch.(*fakeChannel).token != "tg2" || !ch.(*fakeChannel).isConnected() {
+		t.Error("telegram channel was not reconnected with the new token")
+	}
+	if tg.(*fakeChannel).isConnected() {
+		t.Error("old telegram channel is still connected")
+	}
+}
+
+func TestApplyInvalidKeepsConfig(t *testing.T) {
+	cfg := Default()
+	w := buildTest(t, &cfg)
+
+	bad := cfg
+	bad.Routes = []RouteConfig{{Agent: "missing"}}
+	if err := w.Apply(context.Background(), &bad); err == nil {
+		t.Fatal("Apply succeeded with unknown agent")
+	}
+
+	msg := channels.IncomingMessage{ChannelName: "telegram", Content: "hi"}
+	if !w.matches(msg) {
+		t.Error("previous routes should stay in effect")
+	}
+}
+
+func TestBuildRelay(t *testing.T) {
+	cfg := Default()
+	cfg.Mode = ModeRelay
+	cfg.Channels.Telegram = TelegramConfig{Enabled: true, Token: "tg"}
+	cfg.Channels.Discord = DiscordConfig{Enabled: true, Token: "dc"}
+	cfg.Bridge.Links = []LinkConfig{{A: "telegram:-100", B: "discord:general", OneWay: true}}
+	w := buildTest(t, &cfg)
+
+	ctx := context.Background()
+	if err := w.Router.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Router.Stop(ctx)
+
+	if names := w.Router.Agents().Names(); len(names) != 0 {
+		t.Errorf("agents = %v, want none in relay mode", names)
+	}
+	msg := channels.IncomingMessage{ChannelName: "telegram", ChatID: "-100", SenderName: "ann", Content: "hi"}
+	if w.matches(msg) {
+		t.Error("relay mode routed a message to an agent")
+	}
+	if !w.bridged(msg) {
+		t.Fatal("bridged chat not matched")
+	}
+	if err := w.mirror(ctx, msg); err != nil {
+		t.Fatalf("mirror failed: %v", err)
+	}
+	dc, _ := w.Router.GetChannel("discord")
+	if sent := dc.(*fakeChannel).sent; len(sent) != 1 || sent[0] != "general: [telegram] ann: hi" {
+		t.Errorf("discord sent = %v", sent)
+	}
+
+	// Routes and agents need agent mode
+	bad := cfg
+	bad.Routes = []RouteConfig{{Prefix: "!ask"}}
+	if err := w.Apply(ctx, &bad); err == nil || !strings.Contains(err.Error(), "relay mode") {
+		t.Errorf("Apply error = %v, want relay mode error", err)
+	}
+	bad = cfg
+	bad.Bridge.Links = []LinkConfig{{A: "telegram", B: "discord:general"}}
+	if err := w.Apply(ctx, &bad); err == nil || !strings.Contains(err.Error(), "invalid endpoint") {
+		t.Errorf("Apply error = %v, want invalid endpoint", err)
+	}
+
+	// Switching to agent mode creates the default agent
+	next := cfg
+	next.Mode = ModeAgent
+	if err := w.Apply(ctx, &next); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, ok := w.Agent(channels.DefaultAgentName); !ok || !w.matches(msg) {
+		t.Error("agent mode did not restore the default agent route")
+	}
+}
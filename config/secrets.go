@@ -0,0 +1,102 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/agentplexus/envoy/secrets"
+)
+
+// SecretsConfig configures the secret stores that credentials such as
+// token_secret reference. The "env" and "file" schemes are always
+// available; "vault" and "aws" are added when configured.
+type SecretsConfig struct {
+	// Dir is the directory of "file:" references (default: the working
+	// directory).
+	Dir string `json:"dir" yaml:"dir" toml:"dir"`
+
+	// VaultAddress enables "vault:<path>#<key>" references. The token is
+	// read from VAULT_TOKEN.
+	VaultAddress string `json:"vault_address" yaml:"vault_address" toml:"vault_address"`
+	VaultMount   string `json:"vault_mount" yaml:"vault_mount" toml:"vault_mount"`
+
+	// AWSRegion enables "aws:<secret-id>#<key>" references. Credentials
+	// are read from the standard AWS environment variables.
+	AWSRegion string `json:"aws_region" yaml:"aws_region" toml:"aws_region"`
+
+	// RotationInterval is how often referenced secrets are checked for
+	// rotation (default: secrets.DefaultWatchInterval).
+	RotationInterval time.Duration `json:"rotation_interval" yaml:"rotation_interval" toml:"rotation_interval"`
+}
+
+// Resolver returns a resolver for the configured secret stores.
+func (c SecretsConfig) Resolver() *secrets.Resolver {
+	providers := map[string]secrets.Provider{"file": secrets.File{Dir: c.Dir}}
+	if c.VaultAddress != "" {
+		providers["vault"] = &secrets.Vault{Address: c.VaultAddress, Mount: c.VaultMount}
+	}
+	if c.AWSRegion != "" {
+		providers["aws"] = &secrets.AWS{Region: c.AWSRegion}
+	}
+	return secrets.NewResolver(providers)
+}
+
+// secretFields returns the secret references of cfg with the fields their
+// values go to.
+func secretFields(cfg *Config) map[string][]*string {
+	fields := make(map[string][]*string)
+	for ref, field := range map[*string]*string{
+		&cfg.Channels.Telegram.TokenSecret: &cfg.Channels.Telegram.Token,
+		&cfg.Channels.Discord.TokenSecret:  &cfg.Channels.Discord.Token,
+	} {
+		if *ref != "" {
+			fields[*ref] = append(fields[*ref], field)
+		}
+	}
+	return fields
+}
+
+// SecretRefs returns the secret references of the configuration.
+func (c *Config) SecretRefs() []string {
+	var refs []string
+	for ref := range secretFields(c) {
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// ResolveSecrets sets the credentials that reference secrets to their
+// current values.
+func ResolveSecrets(ctx context.Context, cfg *Config, resolver *secrets.Resolver) error {
+	for ref, fields := range secretFields(cfg) {
+		value, err := resolver.Resolve(ctx, ref)
+		if err != nil {
+			return err
+		}
+		for _, field := range fields {
+			*field = value
+		}
+	}
+	return nil
+}
+
+// SecretsWatcher returns a watcher of the secrets cfg references that calls
+// reload when one rotates, e.g. Reloader.Reload. Channels whose token
+// changed then reconnect with the new one. Run it as a lifecycle service.
+func SecretsWatcher(cfg *Config, reload func(ctx context.Context) error, logger *slog.Logger) *secrets.Watcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return secrets.NewWatcher(secrets.WatchConfig{
+		Resolver: cfg.Secrets.Resolver(),
+		Refs:     cfg.SecretRefs(),
+		Interval: cfg.Secrets.RotationInterval,
+		OnRotate: func(ctx context.Context, refs []string) {
+			if err := reload(ctx); err != nil {
+				logger.Error("reload after secret rotation failed", "refs", refs, "error", err)
+			}
+		},
+		Logger: logger,
+	})
+}
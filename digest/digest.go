@@ -0,0 +1,341 @@
+// Package digest emails a periodic digest of flagged conversations.
+//
+// A Collector records flags raised by moderation, thumbs-down feedback, or
+// escalation handlers, and a Job mails a summary of the flags raised since
+// the previous digest with links into the admin session dumps:
+//
+//	flags := digest.NewCollector()
+//	router.OnMessage(annotate.Where(annotate.KeyIntent, "escalate"), flags.Handler(digest.ReasonEscalation))
+//	router.OnEvent(flags.ReactionHandler(), channels.EventTypeReaction)
+//
+//	job := digest.New(digest.Config{
+//		Flags:   flags,
+//		Mailer:  &digest.SMTPMailer{Addr: "smtp.example.com:587", From: "envoy@example.com"},
+//		To:      []string{"support-leads@example.com"},
+//		BaseURL: "https://envoy.example.com",
+//	})
+//	manager.Add("digest", lifecycle.Service(job.Run))
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Reason is why a conversation was flagged.
+type Reason string
+
+const (
+	ReasonModeration Reason = "moderation"
+	ReasonFeedback   Reason = "feedback"
+	ReasonEscalation Reason = "escalation"
+)
+
+// DefaultInterval is the time between digests.
+const DefaultInterval = 24 * time.Hour
+
+// DefaultSubject is the digest email subject. %d is replaced with the
+// number of flagged conversations.
+const DefaultSubject = "Envoy digest: %d flagged conversation(s)"
+
+// Flag marks a conversation for review.
+type Flag struct {
+	Reason      Reason
+	ChannelName string
+	ChatID      string
+	MessageID   string
+	SenderID    string
+
+	// Excerpt is the flagged message text, if known.
+	Excerpt string
+
+	// Note adds context, such as the classifier score or reaction.
+	Note string
+
+	At time.Time
+}
+
+// Collector records flags until they are included in a digest.
+type Collector struct {
+	mu    sync.Mutex
+	flags []Flag
+}
+
+// NewCollector creates an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Add records a flag.
+func (c *Collector) Add(f Flag) {
+	if f.At.IsZero() {
+		f.At = time.Now()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flags = append(c.flags, f)
+}
+
+// Take returns and removes all recorded flags.
+func (c *Collector) Take() []Flag {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	flags := c.flags
+	c.flags = nil
+	return flags
+}
+
+// Handler returns a message handler that flags every message it receives.
+// Register it with the pattern that identifies flagged messages.
+func (c *Collector) Handler(reason Reason) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		c.Add(Flag{
+			Reason:      reason,
+			ChannelName: msg.ChannelName,
+			ChatID:      msg.ChatID,
+			MessageID:   msg.ID,
+			SenderID:    msg.SenderID,
+			Excerpt:     msg.Content,
+			At:          msg.Timestamp,
+		})
+		return nil
+	}
+}
+
+// DefaultNegativeReactions are the emoji ReactionHandler treats as
+// thumbs-down feedback.
+var DefaultNegativeReactions = []string{"👎", "thumbsdown", "-1"}
+
+// ReactionHandler returns an event handler that flags messages receiving a
+// negative reaction (default: DefaultNegativeReactions). Register it with
+// router.OnEvent(h, channels.EventTypeReaction).
+func (c *Collector) ReactionHandler(emoji ...string) channels.EventHandler {
+	if len(emoji) == 0 {
+		emoji = DefaultNegativeReactions
+	}
+	return func(ctx context.Context, event channels.Event) error {
+		// Taking a reaction back is not feedback
+		if removed, _ := event.Data[channels.EventDataRemoved].(bool); event.Type != channels.EventTypeReaction || removed {
+			return nil
+		}
+		e, _ := event.Data[channels.EventDataEmoji].(string)
+		if !contains(emoji, e) {
+			return nil
+		}
+		messageID, _ := event.Data[channels.EventDataMessageID].(string)
+		userID, _ := event.Data[channels.EventDataUserID].(string)
+		c.Add(Flag{
+			Reason:      ReasonFeedback,
+			ChannelName: event.ChannelName,
+			ChatID:      event.ChatID,
+			MessageID:   messageID,
+			SenderID:    userID,
+			Note:        "reacted " + e,
+			At:          event.Timestamp,
+		})
+		return nil
+	}
+}
+
+// Email is a plain text email.
+type Email struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails.
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// Config configures a Job.
+type Config struct {
+	// Flags supplies the flags to report.
+	Flags *Collector
+
+	// Mailer delivers the digest.
+	Mailer Mailer
+
+	// From and To address the digest. From may be left empty if the
+	// Mailer sets it.
+	From string
+	To   []string
+
+	// Subject is the email subject, in which %d is replaced with the
+	// number of flagged conversations (default: DefaultSubject). Subjects
+	// without %d are used as is.
+	Subject string
+
+	// BaseURL is the gateway's external URL, used for links to the admin
+	// session dumps at /debug/sessions/{id}. Links are omitted when empty.
+	BaseURL string
+
+	// Link, if set, overrides the link generated for a conversation.
+	Link func(channelName, chatID string) string
+
+	// Interval is the time between digests (default: DefaultInterval).
+	Interval time.Duration
+
+	Logger *slog.Logger
+}
+
+// Job sends digests of flagged conversations.
+type Job struct {
+	config Config
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// New creates a new Job.
+func New(config Config) *Job {
+	if config.Subject == "" {
+		config.Subject = DefaultSubject
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Link == nil && config.BaseURL != "" {
+		base := strings.TrimSuffix(config.BaseURL, "/")
+		config.Link = func(channelName, chatID string) string {
+			return base + "/debug/sessions/" + url.PathEscape(channels.SessionID(channelName, chatID))
+		}
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Job{config: config, logger: config.Logger, now: time.Now}
+}
+
+// Run sends a digest every interval until ctx is done.
+func (j *Job) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := j.Send(ctx); err != nil {
+				j.logger.Error("digest failed", "error", err)
+			}
+		}
+	}
+}
+
+// Send mails a digest of the flags recorded since the previous digest. No
+// email is sent if nothing was flagged. If delivery fails, the flags are
+// kept for the next digest.
+func (j *Job) Send(ctx context.Context) error {
+	flags := j.config.Flags.Take()
+	if len(flags) == 0 {
+		return nil
+	}
+
+	email := j.Compose(flags)
+	if err := j.config.Mailer.Send(ctx, email); err != nil {
+		for _, f := range flags {
+			j.config.Flags.Add(f)
+		}
+		return fmt.Errorf("send digest: %w", err)
+	}
+	j.logger.Info("digest sent", "flags", len(flags), "to", strings.Join(j.config.To, ","))
+	return nil
+}
+
+// conversation groups the flags of one chat.
+type conversation struct {
+	channelName string
+	chatID      string
+	flags       []Flag
+}
+
+// Compose builds the digest email for flags, grouped by conversation, most
+// flagged first.
+func (j *Job) Compose(flags []Flag) Email {
+	byChat := make(map[string]*conversation)
+	var convs []*conversation
+	for _, f := range flags {
+		key := channels.SessionID(f.ChannelName, f.ChatID)
+		c, ok := byChat[key]
+		if !ok {
+			c = &conversation{channelName: f.ChannelName, chatID: f.ChatID}
+			byChat[key] = c
+			convs = append(convs, c)
+		}
+		c.flags = append(c.flags, f)
+	}
+	sort.SliceStable(convs, func(a, b int) bool {
+		return len(convs[a].flags) > len(convs[b].flags)
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d flag(s) in %d conversation(s) as of %s.\n",
+		len(flags), len(convs), j.now().UTC().Format(time.RFC1123))
+	for _, c := range convs {
+		fmt.Fprintf(&sb, "\n%s / %s\n", c.channelName, c.chatID)
+		if j.config.Link != nil {
+			fmt.Fprintf(&sb, "  %s\n", j.config.Link(c.channelName, c.chatID))
+		}
+		for _, f := range c.flags {
+			fmt.Fprintf(&sb, "  - [%s] %s", f.Reason, f.At.UTC().Format("2006-01-02 15:04"))
+			if f.SenderID != "" {
+				fmt.Fprintf(&sb, " by %s", f.SenderID)
+			}
+			if f.MessageID != "" {
+				fmt.Fprintf(&sb, " (message %s)", f.MessageID)
+			}
+			if f.Note != "" {
+				fmt.Fprintf(&sb, ": %s", f.Note)
+			}
+			sb.WriteString("\n")
+			if f.Excerpt != "" {
+				fmt.Fprintf(&sb, "    %q\n", excerpt(f.Excerpt, 200))
+			}
+		}
+	}
+
+	return Email{
+		From:    j.config.From,
+		To:      j.config.To,
+		Subject: subject(j.config.Subject, len(convs)),
+		Body:    sb.String(),
+	}
+}
+
+// subject formats the email subject with the number of conversations if
+// it has a %d verb.
+func subject(format string, n int) string {
+	if !strings.Contains(format, "%d") {
+		return format
+	}
+	return fmt.Sprintf(format, n)
+}
+
+// excerpt truncates s to at most n runes.
+func excerpt(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// contains reports whether values contains v.
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+type fakeMailer struct {
+	sent []Email
+	err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, email Email) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+func TestDigest(t *testing.T) {
+	ctx := context.Background()
+	flags := NewCollector()
+
+	handler := flags.Handler(ReasonEscalation)
+	if err := handler(ctx, channels.IncomingMessage{
+		ID: "m1", ChannelName: "telegram", ChatID: "42", SenderID: "u1", Content: "I want a human",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reactions := flags.ReactionHandler()
+	for _, r := range []struct {
+		emoji   string
+		removed bool
+	}{{"👎", false}, {"👍", false}, {"👎", true}} {
+		if err := reactions(ctx, channels.Event{
+			Type: channels.EventTypeReaction, ChannelName: "discord", ChatID: "c1",
+			Data: map[string]interface{}{
+				channels.EventDataMessageID: "m2",
+				channels.EventDataEmoji:     r.emoji,
+				channels.EventDataUserID:    "u2",
+				channels.EventDataRemoved:   r.removed,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mailer := &fakeMailer{}
+	job := New(Config{
+		Flags:   flags,
+		Mailer:  mailer,
+		From:    "envoy@example.com",
+		To:      []string{"leads@example.com"},
+		BaseURL: "https://envoy.example.com/",
+	})
+	if err := job.Send(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(mailer.sent))
+	}
+
+	email := mailer.sent[0]
+	if email.Subject != "Envoy digest: 2 flagged conversation(s)" {
+		t.Errorf("subject = %q", email.Subject)
+	}
+	for _, want := range []string{
+		"[escalation]",
+		`"I want a human"`,
+		"https://envoy.example.com/debug/sessions/telegram:42",
+		"[feedback]",
+		"reacted 👎",
+	} {
+		if !strings.Contains(email.Body, want) {
+			t.Errorf("body missing %q:\n%s", want, email.Body)
+		}
+	}
+	if strings.Contains(email.Body, "👍") {
+		t.Errorf("positive reaction flagged:\n%s", email.Body)
+	}
+
+	// Flags are reported once
+	if err := job.Send(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Errorf("empty digest sent")
+	}
+}
+
+func TestDigestKeepsFlagsOnFailure(t *testing.T) {
+	flags := NewCollector()
+	flags.Add(Flag{Reason: ReasonModeration, ChannelName: "telegram", ChatID: "1", At: time.Now()})
+
+	mailer := &fakeMailer{err: errors.New("smtp down")}
+	job := New(Config{Flags: flags, Mailer: mailer, To: []string{"a@example.com"}})
+	if err := job.Send(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+
+	mailer.err = nil
+	if err := job.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 || !strings.Contains(mailer.sent[0].Body, "[moderation]") {
+		t.Errorf("flag not retried: %+v", mailer.sent)
+	}
+}
+
+func TestSubject(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{DefaultSubject, "Envoy digest: 3 flagged conversation(s)"},
+		{"Daily digest", "Daily digest"},
+		{"100% reviewed", "100% reviewed"},
+	}
+	for _, tt := range tests {
+		if got := subject(tt.format, 3); got != tt.want {
+			t.Errorf("subject(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
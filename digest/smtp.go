@@ -0,0 +1,58 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends email through an SMTP server.
+type SMTPMailer struct {
+	// Addr is the server address, e.g. "smtp.example.com:587".
+	Addr string
+
+	// Auth authenticates with the server, e.g. smtp.PlainAuth. Optional.
+	Auth smtp.Auth
+
+	// From is the sender address used when the email has none.
+	From string
+}
+
+// Send delivers email. The context is not used by net/smtp and is only
+// checked before sending.
+func (m *SMTPMailer) Send(ctx context.Context, email Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	from := email.From
+	if from == "" {
+		from = m.From
+	}
+	if from == "" || len(email.To) == 0 {
+		return fmt.Errorf("email requires a sender and recipients")
+	}
+
+	msg := buildMessage(from, email)
+	if err := smtp.SendMail(m.Addr, m.Auth, from, email.To, msg); err != nil {
+		return fmt.Errorf("smtp send: %w", err)
+	}
+	return nil
+}
+
+// buildMessage formats an RFC 5322 plain text message.
+func buildMessage(from string, email Email) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", from)
+	fmt.Fprintf(&sb, "To: %s\r\n", strings.Join(email.To, ", "))
+	fmt.Fprintf(&sb, "Subject: %s\r\n", email.Subject)
+	fmt.Fprintf(&sb, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	sb.WriteString(strings.ReplaceAll(email.Body, "\n", "\r\n"))
+	return []byte(sb.String())
+}
+
+// Ensure SMTPMailer implements Mailer.
+var _ Mailer = (*SMTPMailer)(nil)
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuffer is the number of events queued per subscriber.
+const DefaultBuffer = 256
+
+// Handler receives events.
+type Handler func(e Event)
+
+// Config configures a Bus.
+type Config struct {
+	// Buffer is the number of events queued per subscriber before further
+	// events are dropped for it (default: DefaultBuffer).
+	Buffer int
+
+	Logger *slog.Logger
+}
+
+// Bus delivers published events to subscribers. Publishing never blocks:
+// each subscriber receives events in order on its own goroutine, and
+// events are dropped for subscribers that fall behind.
+type Bus struct {
+	buffer  int
+	logger  *slog.Logger
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+// subscriber is a registered handler with its queue.
+type subscriber struct {
+	types   []Type
+	handler Handler
+	queue   chan Event
+	done    chan struct{}
+	once    sync.Once
+}
+
+// New creates a new Bus.
+func New(config Config) *Bus {
+	if config.Buffer <= 0 {
+		config.Buffer = DefaultBuffer
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Bus{
+		buffer: config.Buffer,
+		logger: config.Logger,
+		subs:   make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe calls handler for published events of the given types (none =
+// all) until the returned function is called. Unsubscribing waits for the
+// event being handled, if any; queued events are discarded.
+func (b *Bus) Subscribe(handler Handler, types ...Type) (unsubscribe func()) {
+	s := &subscriber{
+		types:   types,
+		handler: handler,
+		queue:   make(chan Event, b.buffer),
+		done:    make(chan struct{}),
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.run()
+	}()
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		s.stop()
+		return func() {}
+	}
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, s)
+		b.mu.Unlock()
+		s.stop()
+		<-stopped
+	}
+}
+
+// Publish delivers e to matching subscribers without blocking.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	t := e.EventType()
+	for s := range b.subs {
+		if !s.wants(t) {
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			if b.dropped.Add(1) == 1 {
+				b.logger.Warn("event subscriber falling behind, dropping events", "type", t)
+			}
+		}
+	}
+}
+
+// Dropped returns the number of events dropped for slow subscribers.
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Close unsubscribes all subscribers. Later events are discarded.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[*subscriber]struct{})
+	b.closed = true
+	b.mu.Unlock()
+	for s := range subs {
+		s.stop()
+	}
+}
+
+// wants reports whether the subscriber handles events of type t.
+func (s *subscriber) wants(t Type) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, st := range s.types {
+		if st == t {
+			return true
+		}
+	}
+	return false
+}
+
+// run handles queued events until stopped.
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case e := <-s.queue:
+			s.handler(e)
+		}
+	}
+}
+
+func (s *subscriber) stop() {
+	s.once.Do(func() { close(s.done) })
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// collect subscribes to bus and returns a function waiting for n events.
+func collect(t *testing.T, bus *Bus, types ...Type) (wait func(n int) []Event, unsubscribe func()) {
+	var mu sync.Mutex
+	var got []Event
+	unsubscribe = bus.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e)
+	}, types...)
+
+	return func(n int) []Event {
+		deadline := time.Now().Add(time.Second)
+		for {
+			mu.Lock()
+			if len(got) >= n || time.Now().After(deadline) {
+				out := append([]Event(nil), got...)
+				mu.Unlock()
+				return out
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}, unsubscribe
+}
+
+func TestBusSubscribe(t *testing.T) {
+	bus := New(Config{})
+	defer bus.Close()
+
+	waitAll, _ := collect(t, bus)
+	waitFailed, unsubscribe := collect(t, bus, TypeAgentFailed)
+
+	bus.Publish(ChannelConnected{Channel: "telegram"})
+	bus.Publish(AgentFailed{Channel: "telegram", Agent: "default"})
+
+	all := waitAll(2)
+	if len(all) != 2 || all[0].EventType() != TypeChannelConnected || all[1].EventType() != TypeAgentFailed {
+		t.Errorf("all = %+v, want connected then agent failed", all)
+	}
+	failed := waitFailed(1)
+	if len(failed) != 1 || failed[0].(AgentFailed).Agent != "default" {
+		t.Errorf("filtered = %+v, want the agent failure", failed)
+	}
+
+	unsubscribe()
+	bus.Publish(AgentFailed{})
+	if got := waitAll(3); len(got) != 3 {
+		t.Fatalf("remaining subscriber got %d events, want 3", len(got))
+	}
+	if got := waitFailed(1); len(got) != 1 {
+		t.Errorf("unsubscribed handler got %d events, want 1", len(got))
+	}
+}
+
+func TestBusDropsForSlowSubscribers(t *testing.T) {
+	bus := New(Config{Buffer: 1})
+	defer bus.Close()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	bus.Subscribe(func(e Event) {
+		started <- struct{}{}
+		<-release
+	})
+
+	// One event is being handled, one is queued, and the rest are dropped
+	bus.Publish(MessageRouted{})
+	<-started
+	for i := 0; i < 4; i++ {
+		bus.Publish(MessageRouted{})
+	}
+	close(release)
+	if got := bus.Dropped(); got != 3 {
+		t.Errorf("Dropped = %d, want 3", got)
+	}
+}
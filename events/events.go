@@ -0,0 +1,136 @@
+// Package events provides an in-process bus for envoy lifecycle events,
+// such as channels connecting or agents failing, so dashboards and
+// alerting can observe the router without patching it.
+//
+//	bus := events.New(events.Config{})
+//	router := channels.NewRouter(logger, channels.WithEvents(bus))
+//	bus.Subscribe(func(e events.Event) {
+//		if f, ok := e.(events.AgentFailed); ok {
+//			alert(f.Channel, f.Err)
+//		}
+//	}, events.TypeAgentFailed)
+package events
+
+import (
+	"time"
+)
+
+// Type identifies an event type.
+type Type string
+
+const (
+	TypeChannelConnected    Type = "channel.connected"
+	TypeChannelDisconnected Type = "channel.disconnected"
+	TypeChannelEvent        Type = "channel.event"
+	TypeMessageRouted       Type = "message.routed"
+	TypeHandlerFailed       Type = "handler.failed"
+	TypeAgentFailed         Type = "agent.failed"
+	TypeSendRetried         Type = "send.retried"
+	TypeMessageModerated    Type = "message.moderated"
+)
+
+// Event is a lifecycle event. Subscribers switch on the concrete type.
+type Event interface {
+	EventType() Type
+}
+
+// ChannelConnected is published when a channel connects or reconnects.
+type ChannelConnected struct {
+	Channel string
+	Time    time.Time
+}
+
+// ChannelDisconnected is published when a channel disconnects. Err is set
+// if the connection failed, e.g. a health check.
+type ChannelDisconnected struct {
+	Channel string
+	Err     error
+	Time    time.Time
+}
+
+// ChannelEvent is an event reported by a channel adapter, such as a
+// reaction or a member joining.
+type ChannelEvent struct {
+	Channel string
+	ChatID  string
+
+	// Kind is the adapter's event type, e.g. "reaction".
+	Kind string
+	Data map[string]interface{}
+	Time time.Time
+}
+
+// MessageRouted is published after an incoming message was dispatched to
+// its matching handlers.
+type MessageRouted struct {
+	Channel   string
+	ChatID    string
+	MessageID string
+
+	// Handlers is the number of handlers that matched the message.
+	Handlers int
+	Time     time.Time
+}
+
+// HandlerFailed is published when a message handler fails after any
+// retries.
+type HandlerFailed struct {
+	Channel   string
+	ChatID    string
+	MessageID string
+	Attempts  int
+	Err       error
+	Time      time.Time
+}
+
+// AgentFailed is published when an agent fails to process a message.
+type AgentFailed struct {
+	Channel   string
+	ChatID    string
+	MessageID string
+	Agent     string
+	SessionID string
+	Err       error
+	Time      time.Time
+}
+
+// SendRetried is published when a failed send is scheduled for retry.
+type SendRetried struct {
+	Channel string
+	ChatID  string
+
+	// Attempt is the number of the failed attempt.
+	Attempt int
+	Delay   time.Duration
+	Err     error
+	Time    time.Time
+}
+
+// MessageModerated is published when moderation flags, redacts, or blocks
+// an incoming message or an agent response.
+type MessageModerated struct {
+	Channel   string
+	ChatID    string
+	MessageID string
+	SenderID  string
+
+	// Direction is "incoming" for user messages and "outgoing" for agent
+	// responses.
+	Direction string
+
+	// Action is "flag", "redact", or "block".
+	Action     string
+	Moderator  string
+	Categories []string
+	Reason     string
+	Time       time.Time
+}
+
+func (ChannelConnected) EventType() Type    { return TypeChannelConnected }
+func (ChannelDisconnected) EventType() Type { return TypeChannelDisconnected }
+func (ChannelEvent) EventType() Type        { return TypeChannelEvent }
+func (MessageRouted) EventType() Type       { return TypeMessageRouted }
+func (HandlerFailed) EventType() Type       { return TypeHandlerFailed }
+func (AgentFailed) EventType() Type         { return TypeAgentFailed }
+func (SendRetried) EventType() Type         { return TypeSendRetried }
+func (MessageModerated) EventType() Type    { return TypeMessageModerated }
@@ -10,16 +10,15 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/agentplexus/envoy/agent"
-	"github.com/agentplexus/envoy/channels"
-	"github.com/agentplexus/envoy/channels/adapters/telegram"
 	"github.com/agentplexus/envoy/config"
 	"github.com/agentplexus/envoy/gateway"
+	"github.com/agentplexus/envoy/lifecycle"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load("")
+	// Load configuration, optionally from the file named by ENVOY_CONFIG
+	configPath := os.Getenv("ENVOY_CONFIG")
+	cfg, err := config.Load(configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
@@ -27,78 +26,55 @@ func main() {
 	// Create logger
 	logger := slog.Default()
 
-	// Create agent
-	agentInstance, err := agent.New(agent.Config{
-		Provider:     cfg.Agent.Provider,
-		Model:        cfg.Agent.Model,
-		APIKey:       cfg.Agent.APIKey,
-		Temperature:  cfg.Agent.Temperature,
-		MaxTokens:    cfg.Agent.MaxTokens,
-		SystemPrompt: cfg.Agent.SystemPrompt,
-		Logger:       logger,
-	})
+	// Build the router with channels, agents, and routes from the config
+	wiring, err := config.Build(cfg, config.BuildOptions{Logger: logger})
 	if err != nil {
-		log.Fatalf("Failed to create agent: %v", err)
+		log.Fatalf("Failed to build router: %v", err)
 	}
-	defer agentInstance.Close()
-
-	// Create channel router
-	router := channels.NewRouter(logger)
-
-	// Add Telegram channel if configured
-	if cfg.Channels.Telegram.Enabled {
-		tg, err := telegram.New(telegram.Config{
-			Token:  cfg.Channels.Telegram.Token,
-			Logger: logger,
-		})
-		if err != nil {
-			log.Fatalf("Failed to create Telegram adapter: %v", err)
-		}
-		router.Register(tg)
+	defer wiring.Close()
+	router := wiring.Router
+
+	// Reload routes and channels when the config file changes or on SIGHUP
+	var reloader *config.Reloader
+	gatewayConfig := gateway.Config{
+		Address:    cfg.Gateway.Address,
+		AdminToken: cfg.Gateway.AdminToken,
+		Logger:     logger,
 	}
-
-	// Set the agent on the router and use the built-in processor
-	router.SetAgent(agentInstance)
-	router.OnMessage(channels.All(), router.ProcessWithAgent())
-
-	// Connect channels
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	if err := router.ConnectAll(ctx); err != nil {
-		log.Fatalf("Failed to connect channels: %v", err)
+	if configPath != "" {
+		reloader = config.NewReloader(configPath, wiring, config.ReloadOptions{Logger: logger})
+		gatewayConfig.Reload = reloader.Reload
 	}
 
-	// Create and start gateway
-	gw, err := gateway.New(gateway.Config{
-		Address: cfg.Gateway.Address,
-		Logger:  logger,
-	})
+	// Create gateway
+	gw, err := gateway.New(gatewayConfig)
 	if err != nil {
 		log.Fatalf("Failed to create gateway: %v", err)
 	}
 
-	// Handle shutdown
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	// Channels connect before the gateway starts and disconnect after it stops
+	manager := lifecycle.New(lifecycle.Config{Logger: logger})
+	manager.Add("router", router)
+	manager.Add("gateway", lifecycle.Service(gw.Run), lifecycle.DependsOn("router"))
+	if reloader != nil {
+		manager.Add("reloader", lifecycle.Service(reloader.Run), lifecycle.DependsOn("router"))
+	}
 
-	go func() {
-		<-sigCh
-		fmt.Println("\nShutting down...")
-		cancel()
-	}()
+	// Reconnect channels when tokens referenced with token_secret rotate
+	if reloader != nil && len(cfg.SecretRefs()) > 0 {
+		watcher := config.SecretsWatcher(cfg, reloader.Reload, logger)
+		manager.Add("secrets", lifecycle.Service(watcher.Run), lifecycle.DependsOn("router"))
+	}
+
+	// Handle shutdown
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	// Start gateway
 	fmt.Printf("Envoy starting on %s\n", cfg.Gateway.Address)
 	fmt.Println("Press Ctrl+C to stop")
 
-	if err := gw.Run(ctx); err != nil && err != context.Canceled {
-		log.Fatalf("Gateway error: %v", err)
-	}
-
-	// Disconnect channels
-	if err := router.DisconnectAll(context.Background()); err != nil {
-		log.Printf("Warning: disconnect error: %v", err)
+	if err := manager.Run(ctx); err != nil {
+		log.Printf("Warning: %v", err)
 	}
 	fmt.Println("Envoy stopped")
 }
@@ -0,0 +1,298 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/chatvars"
+	agentsessions "github.com/agentplexus/envoy/sessions"
+)
+
+// requireAdmin wraps a handler so it only runs for requests carrying the
+// admin token, either as a bearer token or as the HTTP basic auth password
+// (so the dashboard can be opened in a browser).
+func (g *Gateway) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !g.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="envoy admin"`)
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isAdmin reports whether a request carries the admin token.
+func (g *Gateway) isAdmin(r *http.Request) bool {
+	if g.config.AdminToken == "" {
+		return false
+	}
+
+	var token string
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	} else if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(g.config.AdminToken)) == 1
+}
+
+// handleReload reapplies the configuration.
+func (g *Gateway) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := g.config.Reload(r.Context()); err != nil {
+		g.logger.Error("config reload failed", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, map[string]bool{"reloaded": true})
+}
+
+// handleGetVars returns a chat's variables.
+func (g *Gateway) handleGetVars(w http.ResponseWriter, r *http.Request) {
+	vars, err := g.config.Vars.Get(r.Context(), r.PathValue("channel"), r.PathValue("chat"))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, vars)
+}
+
+// handleSetVar sets a chat variable from a {"value": "..."} body.
+func (g *Gateway) handleSetVar(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if !chatvars.ValidKey(key) {
+		writeAPIError(w, http.StatusBadRequest, "invalid variable name")
+		return
+	}
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := g.config.Vars.Set(r.Context(), r.PathValue("channel"), r.PathValue("chat"), key, body.Value); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusNoContent, nil)
+}
+
+// handleDeleteVar removes a chat variable.
+func (g *Gateway) handleDeleteVar(w http.ResponseWriter, r *http.Request) {
+	if err := g.config.Vars.Delete(r.Context(), r.PathValue("channel"), r.PathValue("chat"), r.PathValue("key")); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusNoContent, nil)
+}
+
+// handleGetSessionConfig returns the configuration of a conversation's
+// active session, by session key.
+func (g *Gateway) handleGetSessionConfig(w http.ResponseWriter, r *http.Request) {
+	s, err := g.config.Sessions.Session(r.Context(), r.PathValue("key"))
+	if errors.Is(err, agentsessions.ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, s.Config)
+}
+
+// handleSetSessionConfig replaces the configuration of a conversation's
+// active session with the request body.
+func (g *Gateway) handleSetSessionConfig(w http.ResponseWriter, r *http.Request) {
+	var config channels.SessionConfig
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize)).Decode(&config); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	g.setSessionConfig(w, r, config)
+}
+
+// handleDeleteSessionConfig removes the overrides of a conversation's
+// active session.
+func (g *Gateway) handleDeleteSessionConfig(w http.ResponseWriter, r *http.Request) {
+	g.setSessionConfig(w, r, channels.SessionConfig{})
+}
+
+// setSessionConfig sets the configuration of the session named in the
+// request path.
+func (g *Gateway) setSessionConfig(w http.ResponseWriter, r *http.Request, config channels.SessionConfig) {
+	err := g.config.Sessions.SetConfig(r.Context(), r.PathValue("key"), config)
+	if errors.Is(err, agentsessions.ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusNoContent, nil)
+}
+
+// handleRestore returns the {"content": "..."} body with its redaction
+// tokens replaced by the original values. Each restore is logged for audit.
+func (g *Gateway) handleRestore(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	content, err := g.config.Redactor.Restore(r.Context(), body.Content)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	g.logger.Info("redaction tokens restored", "remote_addr", r.RemoteAddr)
+	writeAPIResponse(w, http.StatusOK, map[string]string{"content": content})
+}
+
+// clientInfo describes a connected client in GET /admin/clients.
+type clientInfo struct {
+	ID            string                 `json:"id"`
+	Transport     string                 `json:"transport"`
+	RemoteAddr    string                 `json:"remote_addr,omitempty"`
+	ConnectedAt   time.Time              `json:"connected_at"`
+	Subject       string                 `json:"subject,omitempty"`
+	Version       int                    `json:"version"`
+	Encoding      string                 `json:"encoding"`
+	Capabilities  []Capability           `json:"capabilities"`
+	Subscriptions []string               `json:"subscriptions"`
+	Resumable     bool                   `json:"resumable"`
+	Requests      int                    `json:"requests"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// handleListClients lists the connected clients, oldest first.
+func (g *Gateway) handleListClients(w http.ResponseWriter, _ *http.Request) {
+	g.mu.RLock()
+	clients := make([]*Client, 0, len(g.clients))
+	for _, client := range g.clients {
+		clients = append(clients, client)
+	}
+	g.mu.RUnlock()
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].connectedAt.Before(clients[j].connectedAt)
+	})
+
+	infos := make([]clientInfo, len(clients))
+	for i, c := range clients {
+		info := clientInfo{
+			ID:            c.ID,
+			Transport:     "websocket",
+			RemoteAddr:    c.remoteAddr,
+			ConnectedAt:   c.connectedAt,
+			Subject:       subject(c.Principal()),
+			Version:       c.Version(),
+			Encoding:      c.Encoding(),
+			Capabilities:  c.Capabilities(),
+			Subscriptions: c.Subscriptions(),
+			Resumable:     c.session != nil,
+			Requests:      c.streams.len(),
+		}
+		if c.conn == nil {
+			info.Transport = "sse"
+		}
+		c.mu.RLock()
+		if len(c.metadata) > 0 {
+			info.Metadata = make(map[string]interface{}, len(c.metadata))
+			for k, v := range c.metadata {
+				info.Metadata[k] = v
+			}
+		}
+		c.mu.RUnlock()
+		infos[i] = info
+	}
+	writeAPIResponse(w, http.StatusOK, map[string]interface{}{"clients": infos})
+}
+
+// handleDisconnectClient closes a client's connection. Its session, if
+// any, ends, so it cannot resume.
+func (g *Gateway) handleDisconnectClient(w http.ResponseWriter, r *http.Request) {
+	if !g.Disconnect(r.PathValue("id"), "disconnected by administrator") {
+		writeAPIError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	writeAPIResponse(w, http.StatusNoContent, nil)
+}
+
+// EventAnnouncement is the event carrying announcements posted to
+// POST /admin/announcements, with the text in its "message" data.
+const EventAnnouncement = "announcement"
+
+// handleAnnounce sends an announcement event to every client or, with a
+// channel, to the channel's subscribers.
+func (g *Gateway) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Content string `json:"content"`
+		Channel string `json:"channel"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize)).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.Content == "" {
+		writeAPIError(w, http.StatusBadRequest, "content required")
+		return
+	}
+
+	msg := NewEventMessage(EventAnnouncement, "", map[string]interface{}{"message": body.Content})
+	var n int
+	if body.Channel != "" {
+		n = g.Publish(body.Channel, msg)
+	} else {
+		n = len(g.recipients())
+		g.Broadcast(msg)
+	}
+	writeAPIResponse(w, http.StatusOK, map[string]int{"clients": n})
+}
+
+// handleSubscriptionCounts returns the number of connected clients
+// subscribed to each channel pattern.
+func (g *Gateway) handleSubscriptionCounts(w http.ResponseWriter, _ *http.Request) {
+	g.mu.RLock()
+	counts := make(map[string]int)
+	for _, client := range g.clients {
+		for _, pattern := range client.Subscriptions() {
+			counts[pattern]++
+		}
+	}
+	g.mu.RUnlock()
+	writeAPIResponse(w, http.StatusOK, map[string]interface{}{"channels": counts})
+}
+
+// handleGetMaintenance returns the maintenance mode.
+func (g *Gateway) handleGetMaintenance(w http.ResponseWriter, _ *http.Request) {
+	writeAPIResponse(w, http.StatusOK, g.Maintenance())
+}
+
+// handleSetMaintenance turns maintenance mode on or off from an
+// {"enabled": true, "message": "..."} body.
+func (g *Gateway) handleSetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Enabled *bool  `json:"enabled"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize)).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.Enabled == nil {
+		writeAPIError(w, http.StatusBadRequest, "enabled required")
+		return
+	}
+	g.SetMaintenance(*body.Enabled, body.Message)
+	writeAPIResponse(w, http.StatusOK, g.Maintenance())
+}
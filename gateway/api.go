@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+//go:generate go run ../internal/apigen -spec openapi.yaml -out api.gen.go -package gateway
+
+// maxAPIBodySize is the maximum accepted REST request body size.
+const maxAPIBodySize = 1 << 20 // 1MB
+
+// openAPISpec is the REST API description served at /openapi.yaml.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// APIError is an error with an HTTP status, returned by ServerInterface
+// implementations to control the REST error response.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// GetHealth reports gateway health.
+func (g *Gateway) GetHealth(_ context.Context) (*HealthResponse, error) {
+	return &HealthResponse{
+		Status:  "ok",
+		Clients: g.ClientCount(),
+	}, nil
+}
+
+// handleOpenAPI serves the OpenAPI spec.
+func (g *Gateway) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPISpec)
+}
+
+// writeAPIResponse writes a JSON success response.
+func writeAPIResponse(w http.ResponseWriter, status int, body interface{}) {
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeAPIError writes a JSON error response.
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIResponse(w, status, &ErrorResponse{Error: message})
+}
+
+// writeAPIErr writes err as a JSON error response, using the status from an
+// APIError if present.
+func writeAPIErr(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		writeAPIError(w, apiErr.Status, apiErr.Message)
+		return
+	}
+	writeAPIError(w, http.StatusInternalServerError, err.Error())
+}
+
+// Ensure Gateway implements ServerInterface.
+var _ ServerInterface = (*Gateway)(nil)
@@ -0,0 +1,151 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials is returned by authenticators for tokens they do
+// not accept.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is the authenticated identity of a client.
+type Principal struct {
+	// Subject identifies the caller, e.g. an API key's name or a JWT's
+	// "sub" claim.
+	Subject string
+
+	// Method is the authenticator that accepted the credentials, e.g.
+	// "api_key" or "jwt".
+	Method string
+
+	// Claims holds the JWT claims, if any.
+	Claims map[string]interface{}
+
+	// ExpiresAt is when the credentials expire (zero = never).
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the principal's credentials have expired.
+func (p *Principal) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// Authenticator validates client credentials.
+type Authenticator interface {
+	// Authenticate returns the principal for a token, or an error wrapping
+	// ErrInvalidCredentials if the token is not accepted.
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface.
+type AuthenticatorFunc func(ctx context.Context, token string) (*Principal, error)
+
+// Authenticate calls f.
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	return f(ctx, token)
+}
+
+// APIKey is a static API key.
+type APIKey struct {
+	// Key is the secret presented by clients.
+	Key string
+
+	// Subject names the key's owner.
+	Subject string
+}
+
+// APIKeys authenticates static API keys.
+type APIKeys []APIKey
+
+// Authenticate accepts tokens matching one of the keys.
+func (k APIKeys) Authenticate(_ context.Context, token string) (*Principal, error) {
+	var match *APIKey
+	for i := range k {
+		// Compare every key so timing does not reveal which one matched
+		if subtle.ConstantTimeCompare([]byte(token), []byte(k[i].Key)) == 1 && match == nil {
+			match = &k[i]
+		}
+	}
+	if token == "" || match == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Subject: match.Subject, Method: "api_key"}, nil
+}
+
+// Authenticators tries each authenticator in order and returns the first
+// principal. It fails with the last error if none accepts the token.
+type Authenticators []Authenticator
+
+// Authenticate tries each authenticator in order.
+func (a Authenticators) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	err := ErrInvalidCredentials
+	for _, auth := range a {
+		p, authErr := auth.Authenticate(ctx, token)
+		if authErr == nil {
+			return p, nil
+		}
+		err = authErr
+	}
+	return nil, err
+}
+
+var (
+	_ Authenticator = APIKeys(nil)
+	_ Authenticator = Authenticators(nil)
+)
+
+type principalKey struct{}
+
+// withPrincipal returns a context carrying p.
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated principal of the client
+// whose message is being handled, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// bearerToken returns the bearer token of a request, if any.
+func bearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// authenticate validates token with the configured authenticator.
+func (g *Gateway) authenticate(ctx context.Context, token string) (*Principal, error) {
+	p, err := g.config.Authenticator.Authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrInvalidCredentials
+	}
+	if p.Expired(time.Now()) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	}
+	return p, nil
+}
+
+// requiresAuth reports whether a client must authenticate before sending
+// messages of type t.
+func (g *Gateway) requiresAuth(t MessageType) bool {
+	if g.config.Authenticator == nil {
+		return false
+	}
+	switch t {
+	case MessageTypeChat, MessageTypeSubscribe, MessageTypeUpload, MessageTypeUploadChunk, MessageTypeDownload:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+package gateway
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// signJWT builds a token signed with key: an *rsa.PrivateKey (RS256), an
+// *ecdsa.PrivateKey (ES256), or a []byte secret (HS256).
+func signJWT(t *testing.T, key interface{}, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	alg := "HS256"
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		alg = "ES256"
+	}
+	segment := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(map[string]string{"alg": alg, "kid": kid}) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func TestAPIKeys(t *testing.T) {
+	keys := APIKeys{{Key: "k1", Subject: "ci"}, {Key: "k2", Subject: "ops"}}
+	p, err := keys.Authenticate(context.Background(), "k2")
+	if err != nil || p.Subject != "ops" || p.Method != "api_key" {
+		t.Errorf("Authenticate(k2) = %+v, %v", p, err)
+	}
+	for _, token := range []string{"", "k3"} {
+		if _, err := keys.Authenticate(context.Background(), token); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate(%q) = %v, want ErrInvalidCredentials", token, err)
+		}
+	}
+}
+
+func TestJWTWithJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var fetches atomic.Int32
+	var rotated atomic.Bool
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys := []map[string]string{{
+			"kty": "RSA", "kid": "rsa", "use": "sig",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		}}
+		if rotated.Load() {
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": "ec", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer jwks.Close()
+
+	auth, err := NewJWT(JWTConfig{JWKSURL: jwks.URL, Issuer: "https://idp", Audience: "envoy"})
+	if err != nil {
+		t.Fatalf("NewJWT: %v", err)
+	}
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "alice", "iss": "https://idp", "aud": []string{"envoy", "other"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	ctx := context.Background()
+
+	p, err := auth.Authenticate(ctx, signJWT(t, rsaKey, "rsa", claims(nil)))
+	if err != nil || p.Subject != "alice" || p.Method != "jwt" || p.ExpiresAt.IsZero() {
+		t.Fatalf("Authenticate(RS256) = %+v, %v", p, err)
+	}
+
+	// Same signature over a different payload
+	good := strings.Split(signJWT(t, rsaKey, "rsa", claims(nil)), ".")
+	forged := strings.Split(signJWT(t, rsaKey, "rsa", claims(map[string]interface{}{"sub": "mallory"})), ".")
+	rejected := map[string]string{
+		"expired":        signJWT(t, rsaKey, "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()})),
+		"no expiry":      signJWT(t, rsaKey, "rsa", claims(map[string]interface{}{"exp": nil})),
+		"wrong audience": signJWT(t, rsaKey, "rsa", claims(map[string]interface{}{"aud": "other"})),
+		"wrong issuer":   signJWT(t, rsaKey, "rsa", claims(map[string]interface{}{"iss": "https://evil"})),
+		"tampered":       good[0] + "." + forged[1] + "." + good[2],
+		"hmac":           signJWT(t, []byte("secret"), "rsa", claims(nil)),
+		"none":           b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"sub":"alice"}`)) + ".",
+	}
+	for name, token := range rejected {
+		if _, err := auth.Authenticate(ctx, token); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s token: err = %v, want ErrInvalidCredentials", name, err)
+		}
+	}
+
+	// An unknown key ID refetches the JWKS, at most once a minute
+	rotated.Store(true)
+	ecToken := signJWT(t, ecKey, "ec", claims(nil))
+	if _, err := auth.Authenticate(ctx, ecToken); err == nil {
+		t.Error("unknown key accepted before the refetch interval")
+	}
+	now = now.Add(2 * time.Minute)
+	if p, err := auth.Authenticate(ctx, ecToken); err != nil || p.Subject != "alice" {
+		t.Errorf("Authenticate(ES256) after rotation = %+v, %v", p, err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
+
+func TestJWTFetchOutlivesRequest(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "rsa",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	auth, err := NewJWT(JWTConfig{JWKSURL: jwks.URL})
+	if err != nil {
+		t.Fatalf("NewJWT: %v", err)
+	}
+	token := signJWT(t, rsaKey, "rsa", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+
+	// A request that gives up does not cancel the fetch for later ones
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := auth.Authenticate(ctx, token); err == nil {
+		t.Fatal("Authenticate succeeded before the JWKS was fetched")
+	}
+	close(release)
+	if p, err := auth.Authenticate(context.Background(), token); err != nil || p.Subject != "alice" {
+		t.Fatalf("Authenticate = %+v, %v", p, err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1", n)
+	}
+}
+
+func TestJWTFailedFetchThrottled(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var fetches atomic.Int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer jwks.Close()
+
+	auth, err := NewJWT(JWTConfig{JWKSURL: jwks.URL})
+	if err != nil {
+		t.Fatalf("NewJWT: %v", err)
+	}
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+	token := signJWT(t, rsaKey, "rsa", map[string]interface{}{"sub": "alice", "exp": now.Add(time.Hour).Unix()})
+
+	// Requests within a minute of a failed fetch get its error
+	for i := 0; i < 5; i++ {
+		if _, err := auth.Authenticate(context.Background(), token); err == nil || !strings.Contains(err.Error(), "status 503") {
+			t.Fatalf("Authenticate = %v, want the fetch error", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1", n)
+	}
+	now = now.Add(2 * time.Minute)
+	_, _ = auth.Authenticate(context.Background(), token)
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches after a minute = %d, want 2", n)
+	}
+}
+
+func TestJWTWithSecret(t *testing.T) {
+	auth, err := NewJWT(JWTConfig{Secret: []byte("secret")})
+	if err != nil {
+		t.Fatalf("NewJWT: %v", err)
+	}
+	claims := map[string]interface{}{"sub": "bob", "exp": time.Now().Add(time.Hour).Unix()}
+	if p, err := auth.Authenticate(context.Background(), signJWT(t, []byte("secret"), "", claims)); err != nil || p.Subject != "bob" {
+		t.Errorf("Authenticate = %+v, %v", p, err)
+	}
+	if _, err := auth.Authenticate(context.Background(), signJWT(t, []byte("other"), "", claims)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong secret: err = %v", err)
+	}
+	if _, err := auth.Authenticate(context.Background(), signJWT(t, []byte("secret"), "", map[string]interface{}{"sub": "bob"})); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("no exp: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := NewJWT(JWTConfig{}); err == nil {
+		t.Error("NewJWT without keys succeeded")
+	}
+}
+
+func TestGatewayAuthentication(t *testing.T) {
+	gw, err := New(Config{
+		Agent:         &mockAgent{},
+		Authenticator: APIKeys{{Key: "good", Subject: "ci"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	roundTrip := func(msg Message) Message {
+		t.Helper()
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return resp
+	}
+
+	if resp := roundTrip(Message{ID: "1", Type: MessageTypeChat, Content: "hi"}); resp.Error != "authentication required" {
+		t.Errorf("unauthenticated chat = %+v, want authentication required", resp)
+	}
+	if resp := roundTrip(Message{ID: "2", Type: MessageTypeAuth, Data: map[string]interface{}{"token": "bad"}}); resp.Type != MessageTypeError {
+		t.Errorf("bad token auth = %+v, want error", resp)
+	}
+	if resp := roundTrip(Message{ID: "3", Type: MessageTypeAuth, Data: map[string]interface{}{"token": "good"}}); resp.Data["subject"] != "ci" {
+		t.Errorf("auth response = %+v, want subject ci", resp)
+	}
+	if resp := roundTrip(Message{ID: "4", Type: MessageTypeChat, Content: "hi"}); resp.Type != MessageTypeResponse {
+		t.Errorf("authenticated chat = %+v, want response", resp)
+	}
+
+	// Bearer tokens authenticate the connection
+	header := http.Header{"Authorization": {"Bearer bad"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, header); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("dial with bad token: err = %v, want 401", err)
+	}
+	header.Set("Authorization", "Bearer good")
+	conn2, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("dial with token: %v", err)
+	}
+	defer conn2.Close()
+	_ = conn2.WriteJSON(Message{ID: "5", Type: MessageTypeChat, Content: "hi"})
+	var resp Message
+	_ = conn2.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn2.ReadJSON(&resp); err != nil || resp.Type != MessageTypeResponse {
+		t.Errorf("chat on authenticated connection = %+v, %v", resp, err)
+	}
+}
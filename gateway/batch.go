@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+)
+
+// Event names batched by default.
+const (
+	EventTyping    = "typing"
+	EventPresence  = "presence"
+	EventTelemetry = "telemetry"
+)
+
+// DefaultBatchEvents lists the events batched when BatchConfig.Events is
+// empty.
+var DefaultBatchEvents = []string{EventTyping, EventPresence, EventTelemetry}
+
+// BatchConfig configures batching of high-frequency events. Batched events
+// are delivered to each client as a single "events" frame per flush
+// interval instead of one frame per event.
+type BatchConfig struct {
+	// Events lists the event names to batch (default: DefaultBatchEvents).
+	Events []string
+
+	// Interval is the default flush interval. Zero leaves batching off
+	// until a client enables it with a "batch" message.
+	Interval time.Duration
+
+	// MaxInterval caps the interval a client may request (default: 5s).
+	MaxInterval time.Duration
+
+	// MaxSize flushes a batch early once it holds this many events
+	// (default: 100).
+	MaxSize int
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if len(c.Events) == 0 {
+		c.Events = DefaultBatchEvents
+	}
+	if c.MaxInterval <= 0 {
+		c.MaxInterval = 5 * time.Second
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = 100
+	}
+	return c
+}
+
+// batcher buffers a client's batchable events and flushes them as one frame.
+type batcher struct {
+	events  map[string]bool
+	max     time.Duration
+	maxSize int
+	flushTo func(*Message)
+
+	mu       sync.Mutex
+	interval time.Duration
+	pending  []*Message
+	timer    *time.Timer
+	stopped  bool
+}
+
+func newBatcher(config BatchConfig, flushTo func(*Message)) *batcher {
+	events := make(map[string]bool, len(config.Events))
+	for _, e := range config.Events {
+		events[e] = true
+	}
+	return &batcher{
+		events:   events,
+		max:      config.MaxInterval,
+		maxSize:  config.MaxSize,
+		flushTo:  flushTo,
+		interval: min(config.Interval, config.MaxInterval),
+	}
+}
+
+// setInterval changes the flush interval, clamped to the maximum, and
+// returns the interval in effect. Zero disables batching after flushing
+// any pending events.
+func (b *batcher) setInterval(d time.Duration) time.Duration {
+	b.mu.Lock()
+	b.interval = max(0, min(d, b.max))
+	interval := b.interval
+	b.mu.Unlock()
+	if interval == 0 {
+		b.flush()
+	}
+	return interval
+}
+
+// add buffers msg if it is a batched event and batching is on. Typing and
+// presence frames are batched as the events of the same name.
+func (b *batcher) add(msg *Message) bool {
+	name := msg.Content
+	switch msg.Type {
+	case MessageTypeEvent:
+	case MessageTypeTyping, MessageTypePresence:
+		name = string(msg.Type)
+	default:
+		return false
+	}
+	if !b.events[name] {
+		return false
+	}
+
+	b.mu.Lock()
+	if b.interval <= 0 || b.stopped {
+		b.mu.Unlock()
+		return false
+	}
+	b.pending = append(b.pending, msg)
+	full := len(b.pending) >= b.maxSize
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.flush)
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.flush()
+	}
+	return true
+}
+
+// flush sends pending events as a single frame.
+func (b *batcher) flush() {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	events := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	b.flushTo(&Message{
+		Type:      MessageTypeEvents,
+		Events:    events,
+		Timestamp: time.Now(),
+	})
+}
+
+// stop discards pending events and ends batching.
+func (b *batcher) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
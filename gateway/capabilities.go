@@ -0,0 +1,147 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Capability is an optional protocol feature a client supports. Clients
+// declare capabilities when connecting, with a comma-separated
+// "capabilities" query parameter on /ws, or later with a hello message
+// listing them in data.capabilities. The gateway only uses features a
+// client declared, so older clients keep working as the protocol evolves.
+type Capability string
+
+const (
+	// CapabilityStream streams chat responses from streaming agents as a
+	// "stream_start" frame, "stream_chunk" frames, and a "stream_end" frame,
+	// all carrying the request ID. The request keeps running while the
+	// client sends other messages and can be stopped with a "cancel"
+	// message carrying the same ID.
+	CapabilityStream Capability = "stream"
+
+	// CapabilityBinary delivers attachment data in binary frames instead
+	// of base64 in JSON. MessagePack clients always receive attachment
+	// data as binary values.
+	CapabilityBinary Capability = "binary"
+
+	// CapabilityAcks acknowledges each client message with an "ack" frame
+	// carrying its ID as soon as it is received.
+	CapabilityAcks Capability = "acks"
+
+	// CapabilityResume gives the client a resumable session, announced in
+	// a "session" frame, and numbers the frames sent to it (see
+	// ResumeConfig). It can only be declared when connecting, and only if
+	// the gateway has resuming enabled.
+	CapabilityResume Capability = "resume"
+
+	// CapabilityTyping sends "typing" frames while the agent processes the
+	// client's chat messages, and delivers typing indicators as "typing"
+	// frames instead of typing events.
+	CapabilityTyping Capability = "typing"
+
+	// CapabilityPresence delivers "presence" frames announcing when other
+	// clients join, leave, or change status on the channels the client
+	// subscribed to by name.
+	CapabilityPresence Capability = "presence"
+)
+
+// Capabilities lists the capabilities the gateway supports.
+var Capabilities = []Capability{CapabilityStream, CapabilityBinary, CapabilityAcks, CapabilityResume, CapabilityTyping, CapabilityPresence}
+
+// parseCapabilities returns the supported capabilities among names, which
+// may be comma-separated. Unknown capabilities are ignored.
+func parseCapabilities(names ...string) map[Capability]bool {
+	caps := make(map[Capability]bool)
+	for _, list := range names {
+		for _, name := range strings.Split(list, ",") {
+			c := Capability(strings.TrimSpace(name))
+			for _, supported := range Capabilities {
+				if c == supported {
+					caps[c] = true
+				}
+			}
+		}
+	}
+	return caps
+}
+
+// setCapabilities replaces the client's capabilities.
+func (c *Client) setCapabilities(caps map[Capability]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.caps = caps
+}
+
+// Supports reports whether the client declared a capability.
+func (c *Client) Supports(capability Capability) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.caps[capability]
+}
+
+// Capabilities returns the capabilities in use for the client.
+func (c *Client) Capabilities() []Capability {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	caps := []Capability{}
+	for _, capability := range Capabilities {
+		if c.caps[capability] {
+			caps = append(caps, capability)
+		}
+	}
+	return caps
+}
+
+// handleHello replaces the client's capabilities with those listed in
+// data.capabilities. A hello offering protocol versions, in data.version or
+// data.versions, is answered with a welcome carrying the highest version
+// both sides support, the capabilities in use, and the client ID; others
+// get a response with the capabilities.
+func (h *DefaultMessageHandler) handleHello(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	offered, versioned := helloVersions(msg)
+	version := 0
+	if versioned {
+		v, err := negotiateVersion(offered)
+		if err != nil {
+			return unsupportedVersion(msg.ID, err), nil
+		}
+		version = v
+	}
+
+	list, _ := msg.Data["capabilities"].([]interface{})
+	names := make([]string, 0, len(list))
+	for _, v := range list {
+		if name, ok := v.(string); ok {
+			names = append(names, name)
+		}
+	}
+	caps := parseCapabilities(names...)
+	// Sessions only start when connecting
+	caps[CapabilityResume] = client.session != nil
+	client.setCapabilities(caps)
+
+	if !versioned {
+		return &Message{
+			ID:   msg.ID,
+			Type: MessageTypeResponse,
+			Data: map[string]interface{}{
+				"capabilities": client.Capabilities(),
+			},
+			Timestamp: time.Now(),
+		}, nil
+	}
+	client.setVersion(version)
+	return &Message{
+		ID:   msg.ID,
+		Type: MessageTypeWelcome,
+		Data: map[string]interface{}{
+			"version":      version,
+			"versions":     ProtocolVersions(),
+			"capabilities": client.Capabilities(),
+			"client_id":    client.ID,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
@@ -0,0 +1,224 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// ChannelName is the name of the gateway's router channel.
+const ChannelName = "gateway"
+
+// BroadcastChatID addresses every connected client in Channel.Send.
+const BroadcastChatID = "*"
+
+// Channel adapts the gateway to channels.Channel, so WebSocket clients are
+// one more channel of a router: while connected, chat messages from clients
+// go through the router's middleware and routes instead of the gateway's
+// agent, and router sends reach clients.
+//
+// Chat IDs are client IDs. BroadcastChatID sends to every client, and a
+// chat ID of "#" followed by a subscription channel publishes to its
+// subscribers.
+type Channel struct {
+	gateway *Gateway
+	next    MessageHandler
+
+	mu             sync.RWMutex
+	connected      bool
+	messageHandler channels.MessageHandler
+	eventHandler   channels.EventHandler
+}
+
+var _ channels.StreamingChannel = (*Channel)(nil)
+
+// NewChannel creates a router channel for the gateway. It takes over the
+// gateway's message handler, passing messages other than routed chat to the
+// handler set before.
+func NewChannel(gw *Gateway) *Channel {
+	c := &Channel{gateway: gw, next: gw.onMessage}
+	gw.OnMessage(c.handle)
+	return c
+}
+
+// Name returns the channel name.
+func (c *Channel) Name() string {
+	return ChannelName
+}
+
+// Connect starts routing chat messages through the router.
+func (c *Channel) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = true
+	return nil
+}
+
+// Disconnect returns chat messages to the gateway's own handler.
+func (c *Channel) Disconnect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = false
+	return nil
+}
+
+// OnMessage registers a handler for incoming messages.
+func (c *Channel) OnMessage(handler channels.MessageHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messageHandler = handler
+}
+
+// OnEvent registers a handler for channel events. The gateway reports no
+// events.
+func (c *Channel) OnEvent(handler channels.EventHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.eventHandler = handler
+}
+
+// Send sends a message to a client, to every client, or to the subscribers
+// of a channel. Media is attached, kept for download if the gateway has
+// file transfer enabled.
+func (c *Channel) Send(ctx context.Context, chatID string, msg channels.OutgoingMessage) error {
+	out := &Message{
+		ID:        msg.ReplyTo,
+		Type:      MessageTypeResponse,
+		Content:   msg.Content,
+		Data:      msg.Metadata,
+		Timestamp: time.Now(),
+	}
+	if len(msg.Media) > 0 {
+		out.Attachments = c.gateway.mediaAttachments(msg.Media)
+	}
+	return c.deliver(chatID, out)
+}
+
+// SendTyping sends a typing frame, delivered as a typing event to clients
+// that did not declare CapabilityTyping.
+func (c *Channel) SendTyping(ctx context.Context, chatID string) error {
+	return c.deliver(chatID, &Message{
+		Type:      MessageTypeTyping,
+		Data:      map[string]interface{}{"typing": true},
+		Timestamp: time.Now(),
+	})
+}
+
+// SendStream sends chunks as stream_start, stream_chunk, and stream_end
+// frames sharing a new ID.
+func (c *Channel) SendStream(ctx context.Context, chatID string, chunks <-chan string) error {
+	id := uuid.New().String()
+	if err := c.deliver(chatID, &Message{ID: id, Type: MessageTypeStreamStart, Timestamp: time.Now()}); err != nil {
+		return err
+	}
+	end := &Message{ID: id, Type: MessageTypeStreamEnd}
+	defer func() {
+		end.Timestamp = time.Now()
+		_ = c.deliver(chatID, end)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			end.Data = map[string]interface{}{"canceled": true}
+			return ctx.Err()
+		case chunk, ok := <-chunks:
+			if !ok {
+				return nil
+			}
+			if err := c.deliver(chatID, &Message{ID: id, Type: MessageTypeStreamChunk, Content: chunk, Timestamp: time.Now()}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deliver sends msg to the clients chatID addresses.
+func (c *Channel) deliver(chatID string, msg *Message) error {
+	switch {
+	case chatID == BroadcastChatID:
+		c.gateway.Broadcast(msg)
+	case strings.HasPrefix(chatID, "#"):
+		c.gateway.Publish(chatID[1:], msg)
+	default:
+		client := c.gateway.GetClient(chatID)
+		if client == nil {
+			return fmt.Errorf("gateway client %s not connected", chatID)
+		}
+		client.Send(msg)
+	}
+	return nil
+}
+
+// handle passes chat messages to the router while connected, within the
+// gateway's RequestTimeout. The router's replies are sent through Send.
+func (c *Channel) handle(ctx context.Context, client *Client, msg *Message) (*Message, error) {
+	c.mu.RLock()
+	handler := c.messageHandler
+	if !c.connected {
+		handler = nil
+	}
+	c.mu.RUnlock()
+	if msg.Type != MessageTypeChat || handler == nil {
+		return c.next(ctx, client, msg)
+	}
+
+	ctx, cancel := c.gateway.requestContext(ctx)
+	defer cancel()
+	err := c.gateway.await(ctx, func() error {
+		return handler(ctx, c.incoming(client, msg))
+	})
+	if errors.Is(err, errRequestTimeout) {
+		return c.gateway.timeoutError(msg.ID), nil
+	}
+	if err != nil {
+		return NewErrorMessage(msg.ID, err.Error()), nil
+	}
+	return nil, nil
+}
+
+// incoming converts a client chat message, with its attachments as media
+// and its metadata merged into the incoming metadata; the client_id and
+// channel keys set by the gateway take precedence. Messages without an ID
+// are assigned one so replies can refer to them.
+func (c *Channel) incoming(client *Client, msg *Message) channels.IncomingMessage {
+	id := msg.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+	in := channels.IncomingMessage{
+		ID:          id,
+		ChannelName: ChannelName,
+		ChatID:      client.ID,
+		ChatType:    channels.ChannelTypeDM,
+		SenderID:    client.ID,
+		Content:     msg.Content,
+		MentionsBot: true,
+		Timestamp:   msg.Timestamp,
+		Metadata:    make(map[string]interface{}, len(msg.Metadata)+2),
+	}
+	for k, v := range msg.Metadata {
+		in.Metadata[k] = v
+	}
+	in.Metadata["client_id"] = client.ID
+	if in.Timestamp.IsZero() {
+		in.Timestamp = time.Now()
+	}
+	if p := client.Principal(); p != nil && p.Subject != "" {
+		in.SenderID = p.Subject
+		in.SenderName = p.Subject
+	}
+	if msg.Channel != "" {
+		in.Metadata["channel"] = msg.Channel
+	}
+	if len(msg.Attachments) > 0 {
+		in.Media = attachmentMedia(msg.Attachments)
+	}
+	return in
+}
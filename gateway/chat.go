@@ -0,0 +1,274 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DefaultRequestTTL is how long the results of async chat requests are
+// kept for polling.
+const DefaultRequestTTL = 10 * time.Minute
+
+// DefaultMaxAsyncRequests is the number of async chat requests processed
+// at once when Config.MaxAsyncRequests is zero.
+const DefaultMaxAsyncRequests = 64
+
+// Chat request modes and statuses.
+const (
+	ChatModeSync  = "sync"
+	ChatModeAsync = "async"
+
+	ChatStatusPending   = "pending"
+	ChatStatusCompleted = "completed"
+	ChatStatusFailed    = "failed"
+)
+
+// PostChat sends a message to the agent, starting a new session unless the
+// request names one.
+func (g *Gateway) PostChat(ctx context.Context, body *ChatRequest) (*ChatResponse, error) {
+	sessionID := body.SessionID
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	}
+	return g.chat(ctx, sessionID, body)
+}
+
+// PostSessionMessage sends a message to the agent in a session.
+func (g *Gateway) PostSessionMessage(ctx context.Context, id string, body *ChatRequest) (*ChatResponse, error) {
+	return g.chat(ctx, id, body)
+}
+
+// GetChatRequest returns the status of an async chat request. Requests are
+// only visible to the principal that made them.
+func (g *Gateway) GetChatRequest(ctx context.Context, id string) (*ChatResponse, error) {
+	p, err := g.apiPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, ok := g.requests.get(id, subject(p))
+	if !ok {
+		return nil, &APIError{Status: http.StatusNotFound, Message: "request not found"}
+	}
+	return resp, nil
+}
+
+// chat processes a REST chat message, waiting for the response in sync
+// mode. In async mode it returns a pending request whose result is kept
+// for polling and, if the request names a client, also sent to it.
+//
+// Agent sessions of authenticated callers are scoped to their subject, so
+// callers cannot read each other's conversations by guessing session IDs.
+func (g *Gateway) chat(ctx context.Context, sessionID string, body *ChatRequest) (*ChatResponse, error) {
+	p, err := g.apiPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if g.agent == nil {
+		return nil, &APIError{Status: http.StatusServiceUnavailable, Message: "no agent configured"}
+	}
+	if reason := g.maintenanceError(); reason != "" {
+		return nil, &APIError{Status: http.StatusServiceUnavailable, Message: reason}
+	}
+	agentSession := sessionID
+	if s := subject(p); s != "" {
+		agentSession = s + ":" + sessionID
+	}
+
+	if body.Mode != ChatModeAsync {
+		content, err := g.process(ctx, agentSession, body.Content)
+		if errors.Is(err, errRequestTimeout) {
+			return nil, &APIError{Status: http.StatusGatewayTimeout, Message: err.Error()}
+		}
+		if err != nil {
+			return nil, &APIError{Status: http.StatusBadGateway, Message: err.Error()}
+		}
+		return &ChatResponse{SessionID: sessionID, Status: ChatStatusCompleted, Content: content}, nil
+	}
+
+	select {
+	case g.asyncSlots <- struct{}{}:
+	default:
+		return nil, &APIError{Status: http.StatusTooManyRequests, Message: "too many async requests in progress"}
+	}
+	pending := ChatResponse{
+		RequestID: uuid.New().String(),
+		SessionID: sessionID,
+		Status:    ChatStatusPending,
+	}
+	// A request the agent never answers is dropped once it could no
+	// longer complete
+	g.requests.add(pending, subject(p), time.Now().Add(g.config.RequestTimeout+g.config.RequestTTL))
+	go func() {
+		defer func() { <-g.asyncSlots }()
+		resp := pending
+		// The request outlives the HTTP call; process bounds it with
+		// RequestTimeout
+		content, err := g.process(context.WithoutCancel(ctx), agentSession, body.Content)
+		if err != nil {
+			resp.Status = ChatStatusFailed
+			resp.Error = err.Error()
+		} else {
+			resp.Status = ChatStatusCompleted
+			resp.Content = content
+		}
+		g.requests.complete(resp)
+		if body.ClientID != "" {
+			g.deliverChat(body.ClientID, subject(p), &resp)
+		}
+	}()
+	return &pending, nil
+}
+
+// process runs a message through the agent in a span, within
+// RequestTimeout.
+func (g *Gateway) process(ctx context.Context, sessionID, content string) (string, error) {
+	ctx, cancel := g.requestContext(ctx)
+	defer cancel()
+	ctx, span := g.tracer.Start(ctx, channels.SpanAgent,
+		trace.WithAttributes(channels.AttrSession.String(sessionID)))
+	var response string
+	err := g.await(ctx, func() error {
+		var err error
+		response, err = g.agent.Process(ctx, sessionID, content)
+		return err
+	})
+	channels.EndSpan(span, err)
+	if err != nil {
+		if errors.Is(err, errRequestTimeout) {
+			g.countTimeout()
+		}
+		return "", err
+	}
+	return response, nil
+}
+
+// deliverChat sends the result of an async request to a connected client.
+// With an Authenticator configured, the client must be authenticated as
+// the requester.
+func (g *Gateway) deliverChat(clientID, owner string, resp *ChatResponse) {
+	client := g.GetClient(clientID)
+	if client == nil {
+		return
+	}
+	if g.config.Authenticator != nil && subject(client.Principal()) != owner {
+		g.logger.Warn("async chat result not delivered to client of another principal", "client", clientID)
+		return
+	}
+	msg := &Message{
+		ID:      resp.RequestID,
+		Type:    MessageTypeResponse,
+		Content: resp.Content,
+		Data: map[string]interface{}{
+			"request_id": resp.RequestID,
+			"session_id": resp.SessionID,
+			"status":     resp.Status,
+		},
+		Timestamp: time.Now(),
+	}
+	if resp.Error != "" {
+		msg.Type = MessageTypeError
+		msg.Error = resp.Error
+	}
+	client.Send(msg)
+}
+
+// withAPIPrincipal authenticates REST requests carrying a bearer token and
+// puts the principal in the request context. Invalid tokens are rejected;
+// operations requiring a principal check for it with apiPrincipal.
+func (g *Gateway) withAPIPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := bearerToken(r); token != "" && g.config.Authenticator != nil {
+			p, err := g.authenticate(r.Context(), token)
+			if err != nil {
+				g.logger.Warn("api authentication failed", "error", err)
+				writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			r = r.WithContext(withPrincipal(r.Context(), p))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiPrincipal returns the principal of a REST request, failing with 401 if
+// an Authenticator is configured and the request is not authenticated.
+func (g *Gateway) apiPrincipal(ctx context.Context) (*Principal, error) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok && g.config.Authenticator != nil {
+		return nil, &APIError{Status: http.StatusUnauthorized, Message: "unauthorized"}
+	}
+	return p, nil
+}
+
+// subject returns the subject of p, or "" if p is nil.
+func subject(p *Principal) string {
+	if p == nil {
+		return ""
+	}
+	return p.Subject
+}
+
+// chatRequests holds async chat requests until their results expire.
+type chatRequests struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	requests map[string]*chatRequest
+}
+
+type chatRequest struct {
+	owner string
+	resp  ChatResponse
+
+	// expires is when the request is dropped: the deadline of a pending
+	// request, or the end of the TTL of a result.
+	expires time.Time
+}
+
+func newChatRequests(ttl time.Duration) *chatRequests {
+	return &chatRequests{ttl: ttl, requests: make(map[string]*chatRequest)}
+}
+
+// add records a pending request dropped at deadline unless it completes,
+// dropping expired requests.
+func (c *chatRequests) add(resp ChatResponse, owner string, deadline time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for id, r := range c.requests {
+		if now.After(r.expires) {
+			delete(c.requests, id)
+		}
+	}
+	c.requests[resp.RequestID] = &chatRequest{owner: owner, resp: resp, expires: deadline}
+}
+
+// complete records the result of a request.
+func (c *chatRequests) complete(resp ChatResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.requests[resp.RequestID]; ok {
+		r.resp = resp
+		r.expires = time.Now().Add(c.ttl)
+	}
+}
+
+// get returns a request made by owner.
+func (c *chatRequests) get(id, owner string) (*ChatResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.requests[id]
+	if !ok || r.owner != owner || time.Now().After(r.expires) {
+		return nil, false
+	}
+	resp := r.resp
+	return &resp, true
+}
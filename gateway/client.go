@@ -2,29 +2,22 @@ package gateway
 
 import (
 	"context"
-	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-)
-
-const (
-	// Time allowed to write a message to the peer.
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer.
-	pongWait = 60 * time.Second
 
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = (pongWait * 9) / 10
-
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512 * 1024 // 512KB
+	"github.com/agentplexus/envoy/channels"
 )
 
-// Client represents a connected WebSocket client.
+// Maximum message size allowed from peer.
+const maxMessageSize = 512 * 1024 // 512KB
+
+// Client represents a connected WebSocket or Server-Sent Events client.
+// SSE clients have no connection: their messages are written by the
+// events handler.
 type Client struct {
 	ID       string
 	conn     *websocket.Conn
@@ -34,37 +27,125 @@ type Client struct {
 	once     sync.Once
 	metadata map[string]interface{}
 	mu       sync.RWMutex
+	batch    *batcher
+	caps     map[Capability]bool
+	streams  *streams
+
+	// subs holds the channel patterns the client subscribed to.
+	subs map[string]bool
+
+	// status is the presence status set by the client; empty is online.
+	status string
+
+	// principal is the authenticated identity, if any.
+	principal *Principal
+
+	// version is the negotiated protocol version.
+	version int
+
+	// encoding is the wire encoding selected with the subprotocol.
+	encoding string
+
+	// session buffers the frames of clients with resumable sessions.
+	session *session
+
+	// connectedAt and remoteAddr describe the connection for the admin API.
+	connectedAt time.Time
+	remoteAddr  string
+
+	// lastSeen is when the client last sent a frame, including pongs, and
+	// lastMessage when it last sent a message, in Unix nanoseconds.
+	lastSeen    atomic.Int64
+	lastMessage atomic.Int64
 }
 
 // newClient creates a new client.
 func newClient(conn *websocket.Conn, gateway *Gateway) *Client {
-	return &Client{
+	c := &Client{
 		ID:       uuid.New().String(),
 		conn:     conn,
 		gateway:  gateway,
-		send:     make(chan *Message, 256),
+		send:     make(chan *Message, gateway.config.Limits.SendQueue),
 		done:     make(chan struct{}),
 		metadata: make(map[string]interface{}),
+		caps:     make(map[Capability]bool),
+		streams:  newStreams(gateway.config.Limits.MaxInFlight),
+		subs:     make(map[string]bool),
+		version:  ProtocolVersion,
+		encoding: EncodingJSON,
+
+		connectedAt: time.Now(),
 	}
+	c.batch = newBatcher(gateway.config.Batching, c.enqueue)
+	c.lastSeen.Store(c.connectedAt.UnixNano())
+	c.lastMessage.Store(c.connectedAt.UnixNano())
+	return c
 }
 
-// Send queues a message to be sent to the client.
+// Send queues a message to be sent to the client. Batched event types are
+// held until the client's next flush.
 func (c *Client) Send(msg *Message) {
+	if msg = c.adapt(msg); msg == nil {
+		return
+	}
+	if c.batch.add(msg) {
+		return
+	}
+	c.enqueue(msg)
+}
+
+// enqueue hands a frame to the write pump, through the client's session if
+// it has one.
+func (c *Client) enqueue(msg *Message) {
+	if c.session != nil {
+		c.session.deliver(msg)
+		return
+	}
+	c.push(msg)
+}
+
+// push hands a frame to the write pump. If the queue is full, the frame is
+// dropped and, under SlowConsumerDisconnect, the client is closed.
+func (c *Client) push(msg *Message) {
 	select {
 	case c.send <- msg:
 	case <-c.done:
 	default:
-		// Channel full, drop message
+		if c.gateway.config.Limits.SlowConsumer == SlowConsumerDisconnect {
+			c.gateway.logger.Warn("slow client disconnected, send buffer full", "client", c.ID)
+			c.gateway.countLimit("slow_consumer")
+			// Closing takes the session lock held while pushing
+			go c.Close()
+			return
+		}
 		c.gateway.logger.Warn("message dropped, send buffer full", "client", c.ID)
+		c.gateway.countLimit("send_queue")
 	}
 }
 
+// SetBatchInterval sets how often batched events are flushed to the client
+// and returns the interval in effect after clamping. Zero disables batching.
+func (c *Client) SetBatchInterval(d time.Duration) time.Duration {
+	return c.batch.setInterval(d)
+}
+
 // Close closes the client connection.
 func (c *Client) Close() {
 	c.once.Do(func() {
 		close(c.done)
-		c.conn.Close()
+		// Requests of resumable clients run to completion, so the client
+		// gets their responses when it resumes
+		if c.session != nil {
+			c.session.detach(c, c.gateway.sessions.config.TTL)
+		} else {
+			c.streams.cancelAll()
+		}
+		c.batch.stop()
+		if c.conn != nil {
+			c.conn.Close()
+		}
 		c.gateway.unregisterClient(c)
+		c.gateway.announcePresence(c, c.presenceChannels(), PresenceOffline)
 	})
 }
 
@@ -83,14 +164,39 @@ func (c *Client) GetMetadata(key string) (interface{}, bool) {
 	return v, ok
 }
 
+// Principal returns the client's authenticated identity, or nil if the
+// client has not authenticated or its credentials expired.
+func (c *Client) Principal() *Principal {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.principal == nil || c.principal.Expired(time.Now()) {
+		return nil
+	}
+	return c.principal
+}
+
+// setPrincipal records the client's authenticated identity.
+func (c *Client) setPrincipal(p *Principal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.principal = p
+	c.metadata["authenticated"] = true
+}
+
 // readPump reads messages from the WebSocket connection.
 func (c *Client) readPump() {
 	defer c.Close()
 
+	// Every frame, including pongs, extends the read deadline
+	alive := func() error {
+		now := time.Now()
+		c.lastSeen.Store(now.UnixNano())
+		return c.conn.SetReadDeadline(now.Add(c.gateway.config.PongTimeout))
+	}
 	c.conn.SetReadLimit(maxMessageSize)
-	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	_ = alive()
 	c.conn.SetPongHandler(func(string) error {
-		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return alive()
 	})
 
 	for {
@@ -101,19 +207,57 @@ func (c *Client) readPump() {
 			}
 			return
 		}
+		_ = alive()
+		c.lastMessage.Store(c.lastSeen.Load())
 
-		var msg Message
-		if err := json.Unmarshal(data, &msg); err != nil {
+		c.gateway.observeMessage("in", len(data))
+
+		msg, err := c.protocol().decode(data)
+		if err != nil {
 			c.gateway.logger.Error("message decode error", "client", c.ID, "error", err)
 			continue
 		}
 
+		if c.Supports(CapabilityAcks) && msg.ID != "" {
+			c.Send(&Message{ID: msg.ID, Type: MessageTypeAck, Timestamp: time.Now()})
+		}
+
+		if !c.gateway.allowMessage(c) {
+			c.Send(NewErrorMessage(msg.ID, "rate limit exceeded"))
+			continue
+		}
+
+		if msg.Type == MessageTypeChat {
+			if reason := c.gateway.maintenanceError(); reason != "" {
+				c.Send(NewErrorMessage(msg.ID, reason))
+				continue
+			}
+			if err := c.gateway.attachFiles(c, msg); err != nil {
+				c.Send(NewErrorMessage(msg.ID, err.Error()))
+				continue
+			}
+		}
+
+		if c.gateway.requiresAuth(msg.Type) && c.Principal() == nil {
+			c.Send(NewErrorMessage(msg.ID, "authentication required"))
+			continue
+		}
+
 		// Handle message
 		if c.gateway.onMessage != nil {
-			ctx := context.Background()
-			response, err := c.gateway.onMessage(ctx, c, &msg)
+			ctx, span := c.messageContext(context.Background(), msg)
+			if hook := c.gateway.config.MetadataHook; hook != nil {
+				if err := hook(ctx, c, msg); err != nil {
+					channels.EndSpan(span, err)
+					c.Send(NewErrorMessage(msg.ID, err.Error()))
+					continue
+				}
+				ctx = withMetadata(ctx, msg.Metadata)
+			}
+			response, err := c.gateway.onMessage(ctx, c, msg)
+			channels.EndSpan(span, err)
 			if err != nil {
-				c.gateway.logger.Error("message handler error", "client", c.ID, "error", err)
+				channels.LoggerFromContext(ctx).Error("message handler error", "error", err)
 				c.Send(&Message{
 					Type:  MessageTypeError,
 					Error: err.Error(),
@@ -121,6 +265,7 @@ func (c *Client) readPump() {
 				continue
 			}
 			if response != nil {
+				replyMetadata(msg, response)
 				c.Send(response)
 			}
 		}
@@ -129,7 +274,7 @@ func (c *Client) readPump() {
 
 // writePump writes messages to the WebSocket connection.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.gateway.config.PingInterval)
 	defer func() {
 		ticker.Stop()
 		c.Close()
@@ -138,25 +283,19 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case msg, ok := <-c.send:
-			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.gateway.config.FrameTimeout))
 			if !ok {
 				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
-			data, err := json.Marshal(msg)
-			if err != nil {
-				c.gateway.logger.Error("message encode error", "client", c.ID, "error", err)
-				continue
-			}
-
-			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			if err := c.write(msg); err != nil {
 				c.gateway.logger.Error("websocket write error", "client", c.ID, "error", err)
 				return
 			}
 
 		case <-ticker.C:
-			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.gateway.config.FrameTimeout))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -166,3 +305,41 @@ func (c *Client) writePump() {
 		}
 	}
 }
+
+// write writes a message frame, followed by binary attachment frames for
+// JSON clients with CapabilityBinary. Messages that cannot be encoded are
+// logged and skipped.
+func (c *Client) write(msg *Message) error {
+	proto := c.protocol()
+	var binary [][]byte
+	if len(msg.Attachments) > 0 && c.Supports(CapabilityBinary) && proto.frameType() == websocket.TextMessage {
+		frame := *msg
+		frame.Attachments = make([]*Attachment, len(msg.Attachments))
+		for i, a := range msg.Attachments {
+			stripped := *a
+			stripped.Size = len(a.Data)
+			stripped.Data = nil
+			frame.Attachments[i] = &stripped
+			binary = append(binary, a.Data)
+		}
+		msg = &frame
+	}
+
+	data, err := proto.encode(msg)
+	if err != nil {
+		c.gateway.logger.Error("message encode error", "client", c.ID, "error", err)
+		return nil
+	}
+	c.gateway.observeMessage("out", len(data))
+	if err := c.conn.WriteMessage(proto.frameType(), data); err != nil {
+		return err
+	}
+	for _, b := range binary {
+		c.gateway.observeMessage("out", len(b))
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.gateway.config.FrameTimeout))
+		if err := c.conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
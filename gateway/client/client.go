@@ -0,0 +1,717 @@
+// Package client is a Go client for the gateway's WebSocket protocol. It
+// reconnects when the connection drops, correlates responses with requests
+// by message ID, and iterates over streamed chat responses.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/gateway"
+)
+
+// writeWait is the time allowed to write a frame.
+const writeWait = 10 * time.Second
+
+// ErrClosed is returned by requests on a closed client.
+var ErrClosed = errors.New("client closed")
+
+// ErrDisconnected is returned by requests whose connection dropped before
+// they were answered, when the gateway could not resume the session.
+var ErrDisconnected = errors.New("connection lost")
+
+// Error is an error frame returned by the gateway for a request.
+type Error struct {
+	ID      string
+	Message string
+
+	// Code classifies the error, such as gateway.ErrorCodeTimeout, if the
+	// gateway gave a code.
+	Code string
+}
+
+func (e *Error) Error() string {
+	return "gateway: " + e.Message
+}
+
+// newError returns the error of an error frame, or of a stream_end frame
+// reporting a failure.
+func newError(f *gateway.Message) *Error {
+	code, _ := f.Data["code"].(string)
+	return &Error{ID: f.ID, Message: f.Error, Code: code}
+}
+
+// Config configures a client.
+type Config struct {
+	// URL is the gateway's WebSocket endpoint, such as
+	// ws://127.0.0.1:18789/ws.
+	URL string
+
+	// Token is sent as a bearer token when connecting.
+	Token string
+
+	// Header is added to the upgrade request.
+	Header http.Header
+
+	// Capabilities are declared to the gateway (default: stream and
+	// resume). With CapabilityResume, the client resumes its session after
+	// reconnecting, so requests in flight are still answered and missed
+	// messages are delivered.
+	Capabilities []gateway.Capability
+
+	// Metadata is sent with every request, such as the user's locale. Keys
+	// set on a request's own metadata take precedence.
+	Metadata gateway.Metadata
+
+	// Dialer opens connections (default: websocket.DefaultDialer).
+	Dialer *websocket.Dialer
+
+	// Reconnect is the backoff between reconnection attempts. Attempts are
+	// not limited: the client reconnects until it is closed.
+	Reconnect channels.RetryPolicy
+
+	// OnMessage receives the frames that do not answer a request, such as
+	// events, channel publishes, announcements, and typing and presence
+	// frames. Batched events are
+	// passed one at a time. It is called from the read loop, so it must not
+	// block.
+	OnMessage func(msg *gateway.Message)
+
+	Logger *slog.Logger
+}
+
+func (c Config) withDefaults() Config {
+	if c.Capabilities == nil {
+		c.Capabilities = []gateway.Capability{gateway.CapabilityStream, gateway.CapabilityResume}
+	}
+	if c.Dialer == nil {
+		c.Dialer = websocket.DefaultDialer
+	}
+	if c.OnMessage == nil {
+		c.OnMessage = func(*gateway.Message) {}
+	}
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	return c
+}
+
+// Client is a connection to the gateway that reconnects when it drops.
+// After reconnecting without resuming, the client authenticates again with
+// the last Auth token and restores its subscriptions. Its methods are safe
+// for concurrent use.
+type Client struct {
+	config Config
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// writeMu serializes writes to the connection.
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	conn  *websocket.Conn
+	ready chan struct{} // closed while conn accepts requests
+	calls map[string]*call
+	subs  map[string]bool
+	auth  string
+	id    string
+
+	// session and lastSeq resume the session after reconnecting.
+	session string
+	lastSeq uint64
+}
+
+// call is a request waiting for its response frames. frames is closed
+// after the last one.
+type call struct {
+	frames chan *gateway.Message
+
+	// started is set once a stream_start frame arrives, after which only
+	// stream_end ends the call. Guarded by Client.mu.
+	started bool
+
+	// gone is closed when the caller stops reading frames.
+	gone     chan struct{}
+	goneOnce sync.Once
+
+	// failed is closed with err when the request cannot be answered.
+	failed chan struct{}
+	err    error
+}
+
+// Dial connects to the gateway. Only the first connection is made in Dial;
+// later ones are made in the background when the connection drops.
+func Dial(ctx context.Context, config Config) (*Client, error) {
+	if config.URL == "" {
+		return nil, errors.New("gateway URL required")
+	}
+	c := &Client{
+		config: config.withDefaults(),
+		ready:  make(chan struct{}),
+		calls:  make(map[string]*call),
+		subs:   make(map[string]bool),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	conn, replay, resumed, err := c.connect(ctx)
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	c.start(conn, replay, resumed)
+	return c, nil
+}
+
+// ID returns the client ID the gateway assigned. It is kept across
+// reconnections while the session resumes.
+func (c *Client) ID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.id
+}
+
+// Close closes the connection and stops reconnecting. Requests in flight
+// fail with ErrClosed.
+func (c *Client) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	conn := c.conn
+	c.failCalls(ErrClosed)
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	c.writeMu.Lock()
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+	c.writeMu.Unlock()
+	return conn.Close()
+}
+
+// Request sends a message and waits for its response. Messages without an
+// ID are assigned one. Error frames are returned as *Error. For requests
+// answered with a stream, intermediate frames are skipped and the
+// stream_end frame is returned.
+func (c *Client) Request(ctx context.Context, msg *gateway.Message) (*gateway.Message, error) {
+	call, err := c.send(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer call.leave()
+	var last *gateway.Message
+	for {
+		f, err := call.next(ctx)
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.Type == gateway.MessageTypeError {
+			return f, newError(f)
+		}
+		last = f
+	}
+}
+
+// Auth authenticates the connection with a token. The token is sent again
+// after reconnecting if the session could not be resumed.
+func (c *Client) Auth(ctx context.Context, token string) error {
+	if _, err := c.Request(ctx, authMessage(token)); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.auth = token
+	c.mu.Unlock()
+	return nil
+}
+
+// Subscribe subscribes to a channel pattern, such as "deploys.*". Messages
+// published on matching channels are passed to Config.OnMessage.
+func (c *Client) Subscribe(ctx context.Context, pattern string) error {
+	if _, err := c.Request(ctx, &gateway.Message{Type: gateway.MessageTypeSubscribe, Channel: pattern}); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.subs[pattern] = true
+	c.mu.Unlock()
+	return nil
+}
+
+// Unsubscribe removes a channel pattern from the subscriptions.
+func (c *Client) Unsubscribe(ctx context.Context, pattern string) error {
+	c.mu.Lock()
+	delete(c.subs, pattern)
+	c.mu.Unlock()
+	_, err := c.Request(ctx, &gateway.Message{Type: gateway.MessageTypeUnsubscribe, Channel: pattern})
+	return err
+}
+
+// Typing tells the other subscribers of a channel whether the client is
+// typing.
+func (c *Client) Typing(ctx context.Context, channel string, typing bool) error {
+	_, err := c.Request(ctx, &gateway.Message{
+		Type:    gateway.MessageTypeTyping,
+		Channel: channel,
+		Data:    map[string]interface{}{"typing": typing},
+	})
+	return err
+}
+
+// SetStatus sets the client's presence status, such as "away", announced
+// on the channels it subscribed to by name.
+func (c *Client) SetStatus(ctx context.Context, status string) error {
+	_, err := c.Request(ctx, &gateway.Message{
+		Type: gateway.MessageTypePresence,
+		Data: map[string]interface{}{"status": status},
+	})
+	return err
+}
+
+// Ping checks that the gateway answers.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Request(ctx, &gateway.Message{Type: gateway.MessageTypePing})
+	return err
+}
+
+// Chat sends a chat message, attaching the uploaded files, and returns the
+// complete response.
+func (c *Client) Chat(ctx context.Context, content string, files ...string) (string, error) {
+	stream, err := c.ChatStream(ctx, content, files...)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	var sb strings.Builder
+	for stream.Next() {
+		sb.WriteString(stream.Chunk())
+	}
+	if err := stream.Err(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// ChatStream sends a chat message, attaching the uploaded files, and
+// returns an iterator over the response as it is generated. Without
+// CapabilityStream, or if the agent does not stream, the response arrives
+// as a single chunk.
+func (c *Client) ChatStream(ctx context.Context, content string, files ...string) (*Stream, error) {
+	msg := &gateway.Message{Type: gateway.MessageTypeChat, Content: content}
+	if len(files) > 0 {
+		msg.Data = map[string]interface{}{"files": files}
+	}
+	call, err := c.send(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{client: c, ctx: ctx, id: msg.ID, call: call}, nil
+}
+
+// send waits for a connection, then sends msg as a request.
+func (c *Client) send(ctx context.Context, msg *gateway.Message) (*call, error) {
+	for {
+		if c.ctx.Err() != nil {
+			return nil, ErrClosed
+		}
+		c.mu.Lock()
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+		select {
+		case <-ready:
+			return c.roundTrip(conn, msg)
+		default:
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.ctx.Done():
+			return nil, ErrClosed
+		}
+	}
+}
+
+// roundTrip registers a call for msg and writes it to conn.
+func (c *Client) roundTrip(conn *websocket.Conn, msg *gateway.Message) (*call, error) {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if len(c.config.Metadata) > 0 {
+		meta := c.config.Metadata.Clone()
+		for k, v := range msg.Metadata {
+			meta[k] = v
+		}
+		msg.Metadata = meta
+	}
+	call := &call{
+		frames: make(chan *gateway.Message, 16),
+		gone:   make(chan struct{}),
+		failed: make(chan struct{}),
+	}
+	c.mu.Lock()
+	c.calls[msg.ID] = call
+	c.mu.Unlock()
+	if err := c.write(conn, msg); err != nil {
+		c.mu.Lock()
+		delete(c.calls, msg.ID)
+		c.mu.Unlock()
+		return nil, err
+	}
+	return call, nil
+}
+
+// write sends a frame.
+func (c *Client) write(conn *websocket.Conn, msg *gateway.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// connect dials the gateway, resuming the session if there is one, and
+// greets it. It returns the frames received before the welcome, which are
+// the frames replayed by a resumed session, and whether the session was
+// resumed.
+func (c *Client) connect(ctx context.Context) (*websocket.Conn, []*gateway.Message, bool, error) {
+	u, err := url.Parse(c.config.URL)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("invalid gateway URL: %w", err)
+	}
+	caps := make([]string, len(c.config.Capabilities))
+	for i, capability := range c.config.Capabilities {
+		caps[i] = string(capability)
+	}
+	q := u.Query()
+	q.Set("capabilities", strings.Join(caps, ","))
+	c.mu.Lock()
+	if c.session != "" {
+		q.Set("session", c.session)
+		q.Set("last_seq", strconv.FormatUint(c.lastSeq, 10))
+	}
+	c.mu.Unlock()
+	u.RawQuery = q.Encode()
+
+	header := c.config.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if c.config.Token != "" {
+		header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+	conn, resp, err := c.config.Dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, nil, false, fmt.Errorf("dial gateway: %w (status %d)", err, resp.StatusCode)
+		}
+		return nil, nil, false, fmt.Errorf("dial gateway: %w", err)
+	}
+
+	replay, resumed, err := c.greet(ctx, conn, caps)
+	if err != nil {
+		conn.Close()
+		return nil, nil, false, err
+	}
+	return conn, replay, resumed, nil
+}
+
+// greet negotiates the protocol version with a hello and reads frames up
+// to the welcome.
+func (c *Client) greet(ctx context.Context, conn *websocket.Conn, caps []string) ([]*gateway.Message, bool, error) {
+	hello := &gateway.Message{
+		ID:   uuid.New().String(),
+		Type: gateway.MessageTypeHello,
+		Data: map[string]interface{}{
+			"versions":     []int{gateway.ProtocolVersion},
+			"capabilities": caps,
+		},
+	}
+	if err := c.write(conn, hello); err != nil {
+		return nil, false, err
+	}
+	deadline := time.Now().Add(writeWait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	var replay []*gateway.Message
+	resumed := false
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			return nil, false, fmt.Errorf("greet gateway: %w", err)
+		}
+		switch {
+		case msg.Type == gateway.MessageTypeSession:
+			token, _ := msg.Data["token"].(string)
+			id, _ := msg.Data["client_id"].(string)
+			resumed, _ = msg.Data["resumed"].(bool)
+			c.mu.Lock()
+			c.session, c.id = token, id
+			if !resumed {
+				c.lastSeq = 0
+			}
+			c.mu.Unlock()
+		case msg.ID == hello.ID && msg.Type == gateway.MessageTypeError:
+			return nil, false, newError(msg)
+		case msg.ID == hello.ID:
+			id, _ := msg.Data["client_id"].(string)
+			c.mu.Lock()
+			c.id = id
+			c.mu.Unlock()
+			return replay, resumed, nil
+		default:
+			replay = append(replay, msg)
+		}
+	}
+}
+
+// start makes conn the client's connection. Unless the session resumed,
+// requests sent on the previous connection fail, and the client
+// authenticates and subscribes again before it accepts requests: ready is
+// closed once conn accepts requests.
+func (c *Client) start(conn *websocket.Conn, replay []*gateway.Message, resumed bool) {
+	c.mu.Lock()
+	if !resumed {
+		c.failCalls(ErrDisconnected)
+	}
+	c.conn = conn
+	auth := c.auth
+	subs := make([]string, 0, len(c.subs))
+	for pattern := range c.subs {
+		subs = append(subs, pattern)
+	}
+	c.mu.Unlock()
+
+	for _, msg := range replay {
+		c.dispatch(msg)
+	}
+	go c.readLoop(conn)
+
+	if !resumed {
+		var restore []*gateway.Message
+		if auth != "" {
+			restore = append(restore, authMessage(auth))
+		}
+		for _, pattern := range subs {
+			restore = append(restore, &gateway.Message{Type: gateway.MessageTypeSubscribe, Channel: pattern})
+		}
+		for _, msg := range restore {
+			if err := c.restore(conn, msg); err != nil {
+				c.config.Logger.Warn("gateway client restore failed", "type", msg.Type, "error", err)
+			}
+		}
+	}
+
+	// If the connection already dropped, the next one opens the client
+	c.mu.Lock()
+	if c.conn == conn {
+		close(c.ready)
+	}
+	c.mu.Unlock()
+}
+
+// restore sends a request on a connection that is not yet accepting
+// requests and waits for its response.
+func (c *Client) restore(conn *websocket.Conn, msg *gateway.Message) error {
+	call, err := c.roundTrip(conn, msg)
+	if err != nil {
+		return err
+	}
+	defer call.leave()
+	ctx, cancel := context.WithTimeout(c.ctx, writeWait)
+	defer cancel()
+	f, err := call.next(ctx)
+	if err != nil {
+		return err
+	}
+	if f.Type == gateway.MessageTypeError {
+		return newError(f)
+	}
+	return nil
+}
+
+// readLoop dispatches the frames read from conn until it fails, then
+// reconnects.
+func (c *Client) readLoop(conn *websocket.Conn) {
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			if c.ctx.Err() == nil {
+				c.config.Logger.Warn("gateway connection lost", "error", err)
+			}
+			break
+		}
+		c.dispatch(msg)
+	}
+	conn.Close()
+
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+		select {
+		case <-c.ready:
+			c.ready = make(chan struct{})
+		default:
+		}
+	}
+	if c.session == "" {
+		c.failCalls(ErrDisconnected)
+	}
+	c.mu.Unlock()
+	if c.ctx.Err() == nil {
+		c.reconnect()
+	}
+}
+
+// reconnect dials until a connection is made or the client is closed.
+func (c *Client) reconnect() {
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(c.config.Reconnect.Backoff(attempt)):
+		case <-c.ctx.Done():
+			return
+		}
+		conn, replay, resumed, err := c.connect(c.ctx)
+		if err != nil {
+			c.config.Logger.Warn("gateway reconnect failed", "attempt", attempt, "error", err)
+			continue
+		}
+		c.config.Logger.Info("gateway reconnected", "resumed", resumed)
+		c.start(conn, replay, resumed)
+		return
+	}
+}
+
+// dispatch passes a frame to the call waiting for it or to OnMessage.
+func (c *Client) dispatch(msg *gateway.Message) {
+	c.mu.Lock()
+	if msg.Seq > c.lastSeq {
+		c.lastSeq = msg.Seq
+	}
+	c.mu.Unlock()
+
+	switch msg.Type {
+	case gateway.MessageTypeEvents:
+		for _, event := range msg.Events {
+			c.dispatch(event)
+		}
+		return
+	case gateway.MessageTypeAck, gateway.MessageTypeSession:
+		return
+	case gateway.MessageTypeTyping, gateway.MessageTypePresence:
+		// The agent's typing frames carry the chat request's ID but do
+		// not answer it
+		c.config.OnMessage(msg)
+		return
+	}
+
+	c.mu.Lock()
+	var call *call
+	last := false
+	if msg.ID != "" {
+		call = c.calls[msg.ID]
+	}
+	if call != nil {
+		if msg.Type == gateway.MessageTypeStreamStart {
+			call.started = true
+		}
+		last = msg.Type == gateway.MessageTypeStreamEnd || !call.started && final(msg)
+		if last {
+			delete(c.calls, msg.ID)
+		}
+	}
+	c.mu.Unlock()
+	if call == nil {
+		c.config.OnMessage(msg)
+		return
+	}
+	select {
+	case call.frames <- msg:
+	case <-call.gone:
+	}
+	if last {
+		close(call.frames)
+	}
+}
+
+// failCalls fails every request in flight. c.mu must be held.
+func (c *Client) failCalls(err error) {
+	for id, call := range c.calls {
+		call.err = err
+		close(call.failed)
+		delete(c.calls, id)
+	}
+}
+
+// next returns the call's next frame, or io.EOF after the last one.
+func (call *call) next(ctx context.Context) (*gateway.Message, error) {
+	select {
+	case f, ok := <-call.frames:
+		if !ok {
+			return nil, io.EOF
+		}
+		return f, nil
+	case <-call.failed:
+		return nil, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// leave stops the caller reading frames; later frames are dropped.
+func (call *call) leave() {
+	call.goneOnce.Do(func() { close(call.gone) })
+}
+
+// final reports whether a frame is the last one answering a request that
+// is not streaming.
+func final(msg *gateway.Message) bool {
+	switch msg.Type {
+	case gateway.MessageTypeStreamStart, gateway.MessageTypeStreamChunk:
+		return false
+	}
+	return true
+}
+
+func authMessage(token string) *gateway.Message {
+	return &gateway.Message{Type: gateway.MessageTypeAuth, Data: map[string]interface{}{"token": token}}
+}
+
+// readMessage reads and decodes a JSON frame, skipping binary frames.
+func readMessage(conn *websocket.Conn) (*gateway.Message, error) {
+	for {
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if typ != websocket.TextMessage {
+			continue
+		}
+		var msg gateway.Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("decode frame: %w", err)
+		}
+		return &msg, nil
+	}
+}
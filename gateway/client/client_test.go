@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/gateway"
+)
+
+// streamingAgent streams its response in two chunks.
+type streamingAgent struct{}
+
+func (streamingAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return "Hello, " + content, nil
+}
+
+func (streamingAgent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error) {
+	ch := make(chan channels.Chunk, 2)
+	ch <- channels.Chunk{Content: "Hello, "}
+	ch <- channels.Chunk{Content: content}
+	close(ch)
+	return ch, nil
+}
+
+func newTestClient(t *testing.T, received chan<- *gateway.Message) (*gateway.Gateway, *Client) {
+	t.Helper()
+	gw, err := gateway.New(gateway.Config{
+		Agent:  streamingAgent{},
+		Resume: gateway.ResumeConfig{BufferSize: 16},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.Handler())
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, Config{
+		URL:       "ws" + strings.TrimPrefix(server.URL, "http") + "/ws",
+		Reconnect: channels.RetryPolicy{InitialBackoff: 10 * time.Millisecond},
+		OnMessage: func(msg *gateway.Message) { received <- msg },
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return gw, c
+}
+
+func TestChat(t *testing.T) {
+	_, c := newTestClient(t, make(chan *gateway.Message, 1))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if got, err := c.Chat(ctx, "world"); err != nil || got != "Hello, world" {
+		t.Errorf("Chat = %q, %v", got, err)
+	}
+
+	stream, err := c.ChatStream(ctx, "there")
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	defer stream.Close()
+	var chunks []string
+	for stream.Next() {
+		chunks = append(chunks, stream.Chunk())
+	}
+	if err := stream.Err(); err != nil || strings.Join(chunks, "|") != "Hello, |there" {
+		t.Errorf("chunks = %q, %v", chunks, err)
+	}
+
+	var gwErr *Error
+	if err := c.Unsubscribe(ctx, "nothing"); !errors.As(err, &gwErr) || gwErr.Message != "not subscribed" {
+		t.Errorf("Unsubscribe = %v, want gateway error", err)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	received := make(chan *gateway.Message, 4)
+	gw, c := newTestClient(t, received)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Subscribe(ctx, "deploys.*"); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	expect := func(content string) {
+		t.Helper()
+		select {
+		case msg := <-received:
+			if msg.Content != content {
+				t.Errorf("received %q, want %q", msg.Content, content)
+			}
+		case <-ctx.Done():
+			t.Fatalf("%q not received", content)
+		}
+	}
+
+	// A dropped connection resumes the session, replaying missed frames
+	id := c.ID()
+	c.mu.Lock()
+	c.conn.Close()
+	c.mu.Unlock()
+	gw.Publish("deploys.prod", &gateway.Message{Type: gateway.MessageTypeEvent, Content: "missed"})
+	expect("missed")
+	if c.ID() != id {
+		t.Errorf("ID after resume = %q, want %q", c.ID(), id)
+	}
+
+	// Without the session, the client subscribes again
+	gw.Disconnect(id, "test")
+	for c.ID() == id {
+		if ctx.Err() != nil {
+			t.Fatal("client did not reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	gw.Publish("deploys.prod", &gateway.Message{Type: gateway.MessageTypeEvent, Content: "restored"})
+	expect("restored")
+}
+
+func TestFiles(t *testing.T) {
+	gw, err := gateway.New(gateway.Config{Files: gateway.FilesConfig{MaxFileSize: 1 << 20, ChunkSize: 1000}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	// Reply with the uploaded file, reversed
+	ch := gateway.NewChannel(gw)
+	ch.OnMessage(func(ctx context.Context, in channels.IncomingMessage) error {
+		data := make([]byte, 0, len(in.Media[0].Data))
+		for i := len(in.Media[0].Data) - 1; i >= 0; i-- {
+			data = append(data, in.Media[0].Data[i])
+		}
+		return ch.Send(ctx, in.ChatID, channels.OutgoingMessage{
+			ReplyTo: in.ID,
+			Media:   []channels.Media{{Data: data, Filename: "reversed"}},
+		})
+	})
+	_ = ch.Connect(context.Background())
+	server := httptest.NewServer(gw.Handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, Config{URL: "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	data := []byte(strings.Repeat("0123456789", 250))
+	id, err := c.Upload(ctx, "digits.txt", "text/plain", data)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	stream, err := c.ChatStream(ctx, "reverse", id)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil || len(stream.Attachments()) != 1 {
+		t.Fatalf("attachments = %+v, %v", stream.Attachments(), err)
+	}
+	a, err := c.Download(ctx, stream.Attachments()[0].ID)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if len(a.Data) != len(data) || a.Data[0] != '9' || a.Name != "reversed" {
+		t.Errorf("downloaded %d bytes named %q", len(a.Data), a.Name)
+	}
+}
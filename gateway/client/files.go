@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/agentplexus/envoy/gateway"
+)
+
+// Upload uploads a file in chunks and returns its ID, to attach to a chat
+// message. If the connection drops, the upload continues from the last
+// chunk the gateway received.
+func (c *Client) Upload(ctx context.Context, name, mimeType string, data []byte) (string, error) {
+	resp, err := c.Request(ctx, &gateway.Message{
+		Type: gateway.MessageTypeUpload,
+		Data: map[string]interface{}{"name": name, "mime_type": mimeType, "size": len(data)},
+	})
+	if err != nil {
+		return "", err
+	}
+	id, _ := resp.Data["file_id"].(string)
+	chunkSize, _ := resp.Data["chunk_size"].(float64)
+	if id == "" || chunkSize <= 0 {
+		return "", errors.New("invalid upload response")
+	}
+
+	offset := 0
+	for offset < len(data) {
+		end := min(offset+int(chunkSize), len(data))
+		resp, err := c.Request(ctx, &gateway.Message{
+			Type:  gateway.MessageTypeUploadChunk,
+			Data:  map[string]interface{}{"file_id": id, "offset": offset},
+			Chunk: data[offset:end],
+		})
+		if errors.Is(err, ErrDisconnected) {
+			resp, err = c.Request(ctx, &gateway.Message{
+				Type: gateway.MessageTypeUpload,
+				Data: map[string]interface{}{"file_id": id},
+			})
+		}
+		if err != nil {
+			return "", fmt.Errorf("upload %s: %w", name, err)
+		}
+		received, _ := resp.Data["received"].(float64)
+		offset = int(received)
+	}
+	return id, nil
+}
+
+// Download fetches a file sent as an attachment with an ID, in chunks. If
+// the connection drops, the download continues where it stopped.
+func (c *Client) Download(ctx context.Context, fileID string) (*gateway.Attachment, error) {
+	a := &gateway.Attachment{ID: fileID}
+	for {
+		resp, err := c.Request(ctx, &gateway.Message{
+			Type: gateway.MessageTypeDownload,
+			Data: map[string]interface{}{"file_id": fileID, "offset": len(a.Data)},
+		})
+		if errors.Is(err, ErrDisconnected) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("download %s: %w", fileID, err)
+		}
+		a.Name, _ = resp.Data["name"].(string)
+		a.MimeType, _ = resp.Data["mime_type"].(string)
+		a.Data = append(a.Data, resp.Chunk...)
+		a.Size = len(a.Data)
+		if eof, _ := resp.Data["eof"].(bool); eof {
+			return a, nil
+		}
+		if len(resp.Chunk) == 0 {
+			return nil, fmt.Errorf("download %s: empty chunk", fileID)
+		}
+	}
+}
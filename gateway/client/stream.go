@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/agentplexus/envoy/gateway"
+)
+
+// ErrCanceled is returned by a stream whose request was canceled.
+var ErrCanceled = errors.New("request canceled")
+
+// Stream iterates over a chat response as it is generated:
+//
+//	for stream.Next() {
+//		fmt.Print(stream.Chunk())
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type Stream struct {
+	client *Client
+	ctx    context.Context
+	id     string
+	call   *call
+
+	chunk       string
+	attachments []*gateway.Attachment
+	done        bool
+	closed      bool
+	err         error
+}
+
+// ID returns the request ID.
+func (s *Stream) ID() string {
+	return s.id
+}
+
+// Next waits for the next chunk of the response, returning false when the
+// response is complete or the request failed.
+func (s *Stream) Next() bool {
+	for !s.done && s.err == nil {
+		f, err := s.call.next(s.ctx)
+		if err == io.EOF {
+			s.done = true
+			break
+		}
+		if err != nil {
+			s.err = err
+			break
+		}
+		switch f.Type {
+		case gateway.MessageTypeStreamChunk:
+			s.chunk = f.Content
+			return true
+		case gateway.MessageTypeResponse:
+			// The whole response, from an agent that does not stream
+			s.attachments = append(s.attachments, f.Attachments...)
+			if f.Content != "" {
+				s.chunk = f.Content
+				return true
+			}
+		case gateway.MessageTypeStreamEnd:
+			if f.Error != "" {
+				s.err = newError(f)
+			} else if canceled, _ := f.Data["canceled"].(bool); canceled {
+				s.err = ErrCanceled
+			}
+		case gateway.MessageTypeError:
+			s.err = newError(f)
+		}
+	}
+	return false
+}
+
+// Chunk returns the text of the chunk read by Next.
+func (s *Stream) Chunk() string {
+	return s.chunk
+}
+
+// Attachments returns the files attached to the response, available once
+// Next returns false. Files with an ID are fetched with Client.Download.
+func (s *Stream) Attachments() []*gateway.Attachment {
+	return s.attachments
+}
+
+// Err returns the error that ended the stream, if any.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close stops reading the response. If it is still being generated, the
+// request is canceled.
+func (s *Stream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	defer s.call.leave()
+	if s.done || (s.err != nil && !errors.Is(s.err, s.ctx.Err())) {
+		return nil
+	}
+	s.client.mu.Lock()
+	conn := s.client.conn
+	s.client.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return s.client.write(conn, &gateway.Message{ID: s.id, Type: gateway.MessageTypeCancel})
+}
@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Bridge carries broadcasts and channel publishes between gateway
+// instances, so behind a load balancer a message published on one instance
+// reaches the clients connected to the others. Implementations deliver
+// every published payload to every subscribed instance, including the
+// publisher; instances skip their own messages.
+//
+// See package redisbridge for a Redis implementation.
+type Bridge interface {
+	// Publish sends a payload to all instances.
+	Publish(ctx context.Context, payload []byte) error
+
+	// Subscribe passes the payloads published by any instance to handler
+	// until ctx is done or the subscription fails.
+	Subscribe(ctx context.Context, handler func(payload []byte)) error
+}
+
+// bridgeRetry is the delay before resubscribing after a bridge failure.
+const bridgeRetry = time.Second
+
+// bridgeQueueSize is how many messages wait to be published on the
+// bridge; more are dropped so a slow bridge does not hold up broadcasts.
+const bridgeQueueSize = 256
+
+// bridgeMessage is a broadcast or channel publish sent over the bridge.
+type bridgeMessage struct {
+	// Node is the ID of the sending instance.
+	Node string `json:"node"`
+
+	// Channel is the subscription channel of a publish, or empty for a
+	// broadcast.
+	Channel string   `json:"channel,omitempty"`
+	Message *Message `json:"message"`
+}
+
+// share queues a broadcast or channel publish for the other instances
+// without waiting for the bridge.
+func (g *Gateway) share(channel string, msg *Message) {
+	if g.config.Bridge == nil {
+		return
+	}
+	data, err := json.Marshal(bridgeMessage{Node: g.node, Channel: channel, Message: msg})
+	if err != nil {
+		g.logger.Error("bridge encode error", "error", err)
+		return
+	}
+	select {
+	case g.bridgeQueue <- data:
+	default:
+		g.logger.Warn("bridge queue full, message not shared", "type", msg.Type, "channel", channel)
+	}
+}
+
+// startBridge connects the gateway to the other instances until ctx is
+// done.
+func (g *Gateway) startBridge(ctx context.Context) {
+	go g.publishBridge(ctx)
+	go g.runBridge(ctx)
+}
+
+// publishBridge publishes the shared messages in order until ctx is done.
+func (g *Gateway) publishBridge(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-g.bridgeQueue:
+			publishCtx, cancel := context.WithTimeout(ctx, g.config.FrameTimeout)
+			if err := g.config.Bridge.Publish(publishCtx, data); err != nil {
+				g.logger.Warn("bridge publish failed", "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// runBridge delivers messages from other instances to local clients until
+// ctx is done, resubscribing after failures.
+func (g *Gateway) runBridge(ctx context.Context) {
+	for {
+		err := g.config.Bridge.Subscribe(ctx, g.receive)
+		if ctx.Err() != nil {
+			return
+		}
+		g.logger.Warn("bridge subscription failed", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(bridgeRetry):
+		}
+	}
+}
+
+// receive delivers a bridged message to local clients.
+func (g *Gateway) receive(payload []byte) {
+	var m bridgeMessage
+	if err := json.Unmarshal(payload, &m); err != nil || m.Message == nil {
+		g.logger.Warn("invalid bridge message", "error", err)
+		return
+	}
+	if m.Node == g.node {
+		return
+	}
+	if m.Channel == "" {
+		g.broadcast(m.Message)
+	} else {
+		g.publish(m.Channel, m.Message)
+	}
+}
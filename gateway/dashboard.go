@@ -0,0 +1,27 @@
+package gateway
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// dashboardHTML is the metrics dashboard page. It polls
+// /debug/dashboard/data and renders counters and sparklines client-side.
+//
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// handleDashboard serves the metrics dashboard page.
+func (g *Gateway) handleDashboard(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(dashboardHTML)
+}
+
+// handleDashboardData serves a metrics snapshot as JSON.
+func (g *Gateway) handleDashboardData(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(g.config.Metrics.Snapshot())
+}
@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// registerDiagnostics adds pprof, goroutine dump, and expvar endpoints
+// behind admin auth.
+func (g *Gateway) registerDiagnostics(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/pprof/", g.requireAdmin(pprof.Index))
+	mux.HandleFunc("GET /debug/pprof/cmdline", g.requireAdmin(pprof.Cmdline))
+	mux.HandleFunc("GET /debug/pprof/profile", g.requireAdmin(pprof.Profile))
+	mux.HandleFunc("GET /debug/pprof/symbol", g.requireAdmin(pprof.Symbol))
+	mux.HandleFunc("POST /debug/pprof/symbol", g.requireAdmin(pprof.Symbol))
+	mux.HandleFunc("GET /debug/pprof/trace", g.requireAdmin(pprof.Trace))
+	mux.HandleFunc("GET /debug/goroutines", g.requireAdmin(handleGoroutines))
+	mux.HandleFunc("GET /debug/vars", g.requireAdmin(expvar.Handler().ServeHTTP))
+}
+
+// handleGoroutines writes a full stack dump of all goroutines.
+func handleGoroutines(w http.ResponseWriter, _ *http.Request) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		if len(buf) >= 64<<20 {
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(buf)
+}
+
+// handleSessionDump serves a session's state as JSON.
+func (g *Gateway) handleSessionDump(w http.ResponseWriter, r *http.Request) {
+	state, err := g.config.Inspector.Dump(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(state)
+}
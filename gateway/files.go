@@ -0,0 +1,476 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+const (
+	// DefaultChunkSize is the default and maximum size of file transfer
+	// chunks, small enough for base64 chunks to fit in a JSON frame.
+	DefaultChunkSize = 256 << 10 // 256KB
+
+	// DefaultFileTTL is how long unused files are kept.
+	DefaultFileTTL = 15 * time.Minute
+
+	// DefaultMaxUploads is the number of uploads a client can hold.
+	DefaultMaxUploads = 8
+
+	// DefaultMaxFileBytes is the default budget for all files held.
+	DefaultMaxFileBytes = 256 << 20 // 256MB
+)
+
+var errFileNotFound = errors.New("file not found")
+
+// FilesConfig configures chunked file transfer. Clients upload files with
+// an "upload" message giving the name, mime_type, and size in its data,
+// answered with a file_id, then "upload_chunk" messages carrying the
+// file_id, the offset, and the bytes in the chunk field. A client that lost
+// its connection resumes with an upload message carrying only the file_id,
+// answered with the bytes received so far. Chat messages attach uploaded
+// files by listing their IDs in data.files.
+//
+// Files sent to clients through the router Channel are kept for download
+// and announced as attachments with an ID and no data. Clients fetch them
+// in chunks with "download" messages carrying the file_id and offset.
+type FilesConfig struct {
+	// MaxFileSize is the largest file clients can upload, in bytes. Zero
+	// disables file transfer.
+	MaxFileSize int64
+
+	// ChunkSize is the largest chunk sent or accepted (default and maximum:
+	// DefaultChunkSize).
+	ChunkSize int
+
+	// TTL is how long unused files are kept (default: DefaultFileTTL).
+	TTL time.Duration
+
+	// MaxUploads is the number of uploads a client can hold, in progress
+	// or waiting to be attached (default: DefaultMaxUploads).
+	MaxUploads int
+
+	// MaxBytes is the budget for all files held, uploads counting their
+	// full size from the start (default: DefaultMaxFileBytes). Downloads
+	// that do not fit are sent inline.
+	MaxBytes int64
+}
+
+func (c FilesConfig) withDefaults() FilesConfig {
+	if c.ChunkSize <= 0 || c.ChunkSize > DefaultChunkSize {
+		c.ChunkSize = DefaultChunkSize
+	}
+	if c.TTL <= 0 {
+		c.TTL = DefaultFileTTL
+	}
+	if c.MaxUploads <= 0 {
+		c.MaxUploads = DefaultMaxUploads
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = DefaultMaxFileBytes
+	}
+	return c
+}
+
+// file is an upload in progress, a finished upload waiting to be attached,
+// or a file kept for download.
+type file struct {
+	id       string
+	owner    string
+	name     string
+	mimeType string
+	size     int64
+	data     []byte
+	expires  time.Time
+}
+
+func (f *file) complete() bool {
+	return int64(len(f.data)) == f.size
+}
+
+// files holds the files being transferred, by ID.
+type files struct {
+	config FilesConfig
+
+	mu    sync.Mutex
+	byID  map[string]*file
+	bytes int64
+}
+
+func newFiles(config FilesConfig) *files {
+	return &files{config: config.withDefaults(), byID: make(map[string]*file)}
+}
+
+// enabled reports whether clients can transfer files.
+func (fs *files) enabled() bool {
+	return fs.config.MaxFileSize > 0
+}
+
+// expire removes the files expired at now.
+func (fs *files) expire(now time.Time) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.sweep(now)
+}
+
+// sweep removes expired files. fs.mu must be held.
+func (fs *files) sweep(now time.Time) {
+	for id, f := range fs.byID {
+		if now.After(f.expires) {
+			fs.remove(id)
+		}
+	}
+}
+
+// remove deletes a file and releases its bytes. fs.mu must be held.
+func (fs *files) remove(id string) {
+	if f, ok := fs.byID[id]; ok {
+		fs.bytes -= f.size
+		delete(fs.byID, id)
+	}
+}
+
+// get returns an unexpired file, extending the TTL of uploads in progress
+// and downloads. Finished uploads expire TTL after completion, so a client
+// cannot keep a file it never attaches. fs.mu must be held.
+func (fs *files) get(id string) (*file, bool) {
+	now := time.Now()
+	fs.sweep(now)
+	f, ok := fs.byID[id]
+	if ok && (f.owner == "" || !f.complete()) {
+		f.expires = now.Add(fs.config.TTL)
+	}
+	return f, ok
+}
+
+// upload starts an upload for owner.
+func (fs *files) upload(owner, name, mimeType string, size int64) (*file, error) {
+	if size <= 0 {
+		return nil, errors.New("size required")
+	}
+	if size > fs.config.MaxFileSize {
+		return nil, fmt.Errorf("file too large, maximum size is %d bytes", fs.config.MaxFileSize)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.sweep(time.Now())
+	held := 0
+	for _, f := range fs.byID {
+		if f.owner == owner {
+			held++
+		}
+	}
+	if held >= fs.config.MaxUploads {
+		return nil, errors.New("too many uploads, attach or wait for them to expire")
+	}
+	if fs.bytes+size > fs.config.MaxBytes {
+		return nil, errors.New("file storage full, try again later")
+	}
+	f := &file{
+		id:       uuid.New().String(),
+		owner:    owner,
+		name:     name,
+		mimeType: mimeType,
+		size:     size,
+		expires:  time.Now().Add(fs.config.TTL),
+	}
+	fs.byID[f.id] = f
+	fs.bytes += size
+	return f, nil
+}
+
+// received returns the number of bytes received of owner's upload.
+func (fs *files) received(owner, id string) (int64, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.get(id)
+	if !ok || f.owner != owner {
+		return 0, errFileNotFound
+	}
+	return int64(len(f.data)), nil
+}
+
+// write adds a chunk at offset to owner's upload and returns the bytes
+// received and whether the upload is complete. Chunks may overlap what was
+// received, so a resumed client can resend its last chunk, but not leave a
+// gap.
+func (fs *files) write(owner, id string, offset int64, chunk []byte) (int64, bool, error) {
+	if len(chunk) > fs.config.ChunkSize {
+		return 0, false, fmt.Errorf("chunk too large, maximum size is %d bytes", fs.config.ChunkSize)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.get(id)
+	if !ok || f.owner != owner {
+		return 0, false, errFileNotFound
+	}
+	received := int64(len(f.data))
+	if offset < 0 || offset > received {
+		return received, false, fmt.Errorf("expected offset %d", received)
+	}
+	if end := offset + int64(len(chunk)); end > received {
+		if end > f.size {
+			return received, false, errors.New("chunk exceeds file size")
+		}
+		f.data = append(f.data, chunk[received-offset:]...)
+		if f.complete() {
+			f.expires = time.Now().Add(fs.config.TTL)
+		}
+	}
+	return int64(len(f.data)), f.complete(), nil
+}
+
+// take removes owner's finished upload and returns it as an attachment.
+func (fs *files) take(owner, id string) (*Attachment, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.get(id)
+	if !ok || f.owner != owner {
+		return nil, fmt.Errorf("%w: %s", errFileNotFound, id)
+	}
+	if !f.complete() {
+		return nil, fmt.Errorf("upload %s incomplete", id)
+	}
+	fs.remove(id)
+	return &Attachment{Name: f.name, MimeType: f.mimeType, Size: len(f.data), Data: f.data}, nil
+}
+
+// add keeps data for download and returns its ID. Downloads have no owner:
+// any client given the ID can fetch the file until it expires. The
+// downloads expiring soonest are dropped to make room; add reports false
+// if data does not fit in MaxBytes.
+func (fs *files) add(name, mimeType string, data []byte) (string, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.sweep(time.Now())
+	size := int64(len(data))
+	evictable := int64(0)
+	for _, f := range fs.byID {
+		if f.owner == "" {
+			evictable += f.size
+		}
+	}
+	if fs.bytes-evictable+size > fs.config.MaxBytes {
+		return "", false
+	}
+	for fs.bytes+size > fs.config.MaxBytes {
+		var oldest *file
+		for _, f := range fs.byID {
+			if f.owner == "" && (oldest == nil || f.expires.Before(oldest.expires)) {
+				oldest = f
+			}
+		}
+		fs.remove(oldest.id)
+	}
+	f := &file{
+		id:       uuid.New().String(),
+		name:     name,
+		mimeType: mimeType,
+		size:     size,
+		data:     data,
+		expires:  time.Now().Add(fs.config.TTL),
+	}
+	fs.byID[f.id] = f
+	fs.bytes += size
+	return f.id, true
+}
+
+// read returns the chunk of a download at offset.
+func (fs *files) read(id string, offset int64) (*file, []byte, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.get(id)
+	if !ok || f.owner != "" {
+		return nil, nil, errFileNotFound
+	}
+	if offset < 0 || offset > f.size {
+		return nil, nil, errors.New("offset out of range")
+	}
+	end := min(offset+int64(fs.config.ChunkSize), f.size)
+	return f, f.data[offset:end], nil
+}
+
+// fileOwner returns the key a client's uploads are kept under: its subject
+// if authenticated, so uploads survive reconnecting, or its ID.
+func fileOwner(client *Client) string {
+	if p := client.Principal(); p != nil && p.Subject != "" {
+		return "subject:" + p.Subject
+	}
+	return "client:" + client.ID
+}
+
+// attachFiles replaces the file IDs in a chat message's data.files with
+// the uploaded files, as attachments.
+func (g *Gateway) attachFiles(client *Client, msg *Message) error {
+	list, ok := msg.Data["files"].([]interface{})
+	if !ok {
+		return nil
+	}
+	if !g.files.enabled() {
+		return errors.New("file transfer disabled")
+	}
+	owner := fileOwner(client)
+	for _, v := range list {
+		id, _ := v.(string)
+		a, err := g.files.take(owner, id)
+		if err != nil {
+			return err
+		}
+		msg.Attachments = append(msg.Attachments, a)
+	}
+	delete(msg.Data, "files")
+	return nil
+}
+
+// handleUpload starts an upload from data.name, data.mime_type, and
+// data.size, or reports the progress of the upload in data.file_id.
+func (h *DefaultMessageHandler) handleUpload(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	fs := h.gateway.files
+	if !fs.enabled() {
+		return NewErrorMessage(msg.ID, "file transfer disabled"), nil
+	}
+	owner := fileOwner(client)
+	id, _ := msg.Data["file_id"].(string)
+	var received int64
+	if id != "" {
+		n, err := fs.received(owner, id)
+		if err != nil {
+			return NewErrorMessage(msg.ID, err.Error()), nil
+		}
+		received = n
+	} else {
+		name, _ := msg.Data["name"].(string)
+		mimeType, _ := msg.Data["mime_type"].(string)
+		size, _ := msg.Data["size"].(float64)
+		f, err := fs.upload(owner, name, mimeType, int64(size))
+		if err != nil {
+			return NewErrorMessage(msg.ID, err.Error()), nil
+		}
+		id = f.id
+	}
+
+	return &Message{
+		ID:   msg.ID,
+		Type: MessageTypeResponse,
+		Data: map[string]interface{}{
+			"file_id":    id,
+			"received":   received,
+			"chunk_size": fs.config.ChunkSize,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// handleUploadChunk writes the message's chunk at data.offset of the
+// upload in data.file_id.
+func (h *DefaultMessageHandler) handleUploadChunk(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	fs := h.gateway.files
+	if !fs.enabled() {
+		return NewErrorMessage(msg.ID, "file transfer disabled"), nil
+	}
+	id, _ := msg.Data["file_id"].(string)
+	offset, _ := msg.Data["offset"].(float64)
+	received, complete, err := fs.write(fileOwner(client), id, int64(offset), msg.Chunk)
+	if err != nil {
+		reply := NewErrorMessage(msg.ID, err.Error())
+		reply.Data = map[string]interface{}{"file_id": id, "received": received}
+		return reply, nil
+	}
+
+	return &Message{
+		ID:   msg.ID,
+		Type: MessageTypeResponse,
+		Data: map[string]interface{}{
+			"file_id":  id,
+			"received": received,
+			"complete": complete,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// handleDownload returns the chunk at data.offset of the file in
+// data.file_id.
+func (h *DefaultMessageHandler) handleDownload(_ context.Context, _ *Client, msg *Message) (*Message, error) {
+	fs := h.gateway.files
+	if !fs.enabled() {
+		return NewErrorMessage(msg.ID, "file transfer disabled"), nil
+	}
+	id, _ := msg.Data["file_id"].(string)
+	offset, _ := msg.Data["offset"].(float64)
+	f, chunk, err := fs.read(id, int64(offset))
+	if err != nil {
+		return NewErrorMessage(msg.ID, err.Error()), nil
+	}
+
+	return &Message{
+		ID:   msg.ID,
+		Type: MessageTypeResponse,
+		Data: map[string]interface{}{
+			"file_id":   id,
+			"name":      f.name,
+			"mime_type": f.mimeType,
+			"size":      f.size,
+			"offset":    int64(offset),
+			"eof":       int64(offset)+int64(len(chunk)) == f.size,
+		},
+		Chunk:     chunk,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// mediaAttachments converts outgoing media to attachments. With file
+// transfer enabled, media data is kept for download instead of being sent
+// inline.
+func (g *Gateway) mediaAttachments(media []channels.Media) []*Attachment {
+	attachments := make([]*Attachment, 0, len(media))
+	for _, m := range media {
+		a := &Attachment{Name: m.Filename, MimeType: m.MimeType, Size: len(m.Data), URL: m.URL}
+		if len(m.Data) > 0 {
+			var kept bool
+			if g.files.enabled() {
+				a.ID, kept = g.files.add(m.Filename, m.MimeType, m.Data)
+			}
+			if !kept {
+				a.Data = m.Data
+			}
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments
+}
+
+// attachmentMedia converts uploaded attachments to media for the router.
+func attachmentMedia(attachments []*Attachment) []channels.Media {
+	media := make([]channels.Media, 0, len(attachments))
+	for _, a := range attachments {
+		media = append(media, channels.Media{
+			Type:     mediaType(a.MimeType),
+			URL:      a.URL,
+			Data:     a.Data,
+			MimeType: a.MimeType,
+			Filename: a.Name,
+		})
+	}
+	return media
+}
+
+// mediaType derives the media type from a MIME type.
+func mediaType(mimeType string) channels.MediaType {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return channels.MediaTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return channels.MediaTypeVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return channels.MediaTypeAudio
+	}
+	return channels.MediaTypeDocument
+}
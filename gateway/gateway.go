@@ -6,12 +6,28 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/chatvars"
+	"github.com/agentplexus/envoy/handoff"
+	"github.com/agentplexus/envoy/inspect"
+	"github.com/agentplexus/envoy/metrics"
+	"github.com/agentplexus/envoy/ratelimit"
+	"github.com/agentplexus/envoy/redact"
+	agentsessions "github.com/agentplexus/envoy/sessions"
 )
 
+// MetricsNamespace prefixes the metric names served at /metrics.
+const MetricsNamespace = "envoy"
+
 // AgentProcessor processes messages through an AI agent.
 type AgentProcessor interface {
 	Process(ctx context.Context, sessionID, content string) (string, error)
@@ -25,6 +41,121 @@ type Config struct {
 	PingInterval time.Duration
 	Logger       *slog.Logger
 	Agent        AgentProcessor
+
+	// PongTimeout closes WebSocket connections that send nothing, not even
+	// a pong to the pings sent every PingInterval, for this long (default:
+	// twice PingInterval). Their resumable sessions are kept.
+	PongTimeout time.Duration
+
+	// FrameTimeout bounds the writing of each frame to a client (default:
+	// DefaultFrameTimeout).
+	FrameTimeout time.Duration
+
+	// RequestTimeout bounds how long the agent may take to answer a chat
+	// message (default: DefaultRequestTimeout). A request that takes
+	// longer has its context canceled and is answered with an error frame
+	// whose data.code is ErrorCodeTimeout.
+	RequestTimeout time.Duration
+
+	// IdleTimeout disconnects WebSocket clients that send no messages for
+	// this long, even if they answer pings, ending their sessions. Zero
+	// keeps idle clients.
+	IdleTimeout time.Duration
+
+	// AdminToken protects admin endpoints such as /debug/dashboard.
+	// Admin endpoints are disabled when empty.
+	AdminToken string
+
+	// Metrics is shown on the dashboard. The gateway records its client
+	// count and WebSocket message sizes in it.
+	Metrics *metrics.Registry
+
+	// MetricsEndpoint serves Metrics in the Prometheus text format at
+	// /metrics. The endpoint requires AdminToken; without one it is only
+	// served if PublicMetrics is set.
+	MetricsEndpoint bool
+
+	// PublicMetrics serves /metrics without authentication when no
+	// AdminToken is set, for scrapers on a trusted network.
+	PublicMetrics bool
+
+	// Diagnostics exposes pprof, goroutine dump, and expvar endpoints under
+	// /debug. Requires AdminToken.
+	Diagnostics bool
+
+	// Inspector serves session dumps at /debug/sessions/{id}. Requires
+	// AdminToken.
+	Inspector *inspect.Inspector
+
+	// Compression negotiates per-message deflate with clients that support
+	// it (default: off).
+	Compression bool
+
+	// Batching combines high-frequency events into periodic frames.
+	Batching BatchConfig
+
+	// Reload reapplies the configuration, served at POST /admin/reload.
+	// Requires AdminToken.
+	Reload func(ctx context.Context) error
+
+	// Vars serves per-chat variables at /admin/vars/{channel}/{chat}.
+	// Requires AdminToken.
+	Vars *chatvars.Vars
+
+	// Sessions serves the agent configuration of sessions at
+	// /admin/sessions/{key}/config, where key is the conversation's session
+	// key, such as "telegram:42". Requires AdminToken.
+	Sessions *agentsessions.Manager
+
+	// Handoff serves the chats in human mode at /admin/handoffs. Requires
+	// AdminToken.
+	Handoff *handoff.Manager
+
+	// Redactor restores the personal data behind redaction tokens at
+	// /admin/redactions/restore, for authorized replay. Requires AdminToken.
+	Redactor *redact.Redactor
+
+	// Authenticator validates the tokens of auth messages and of bearer
+	// tokens sent when connecting. When set, clients must authenticate
+	// before sending chat and subscribe messages. Without it, auth messages
+	// are accepted without checks.
+	Authenticator Authenticator
+
+	// Limits bounds the message rate, in-flight requests, and outbound
+	// queue of each client.
+	Limits LimitsConfig
+
+	// Resume lets clients declaring CapabilityResume reconnect to their
+	// session and receive the frames they missed.
+	Resume ResumeConfig
+
+	// Files enables chunked file upload and download.
+	Files FilesConfig
+
+	// RequestTTL is how long results of async REST chat requests are kept
+	// for polling (default: DefaultRequestTTL).
+	RequestTTL time.Duration
+
+	// MaxAsyncRequests is the number of async REST chat requests processed
+	// at once (default: DefaultMaxAsyncRequests). Further async requests
+	// are rejected with 429 Too Many Requests.
+	MaxAsyncRequests int
+
+	// Sender delivers messages posted to POST /admin/messages, letting
+	// webhooks and scripts notify chats on any channel. Requires AdminToken.
+	Sender channels.Sender
+
+	// MetadataHook runs on each WebSocket client message before it is
+	// handled, to read, validate, or modify its metadata.
+	MetadataHook MetadataHook
+
+	// Bridge shares broadcasts and channel publishes with other gateway
+	// instances.
+	Bridge Bridge
+
+	// TracerProvider records spans for client messages. Defaults to the
+	// global OpenTelemetry provider.
+	TracerProvider trace.TracerProvider
 }
 
 // Gateway is the WebSocket control plane server.
@@ -35,6 +166,21 @@ type Gateway struct {
 	mu       sync.RWMutex
 	logger   *slog.Logger
 	agent    AgentProcessor
+	tracer   trace.Tracer
+	requests *chatRequests
+	sessions *sessions
+	files    *files
+	limiter  *ratelimit.Limiter
+
+	// asyncSlots holds a token per async chat request in progress
+	asyncSlots chan struct{}
+
+	// node identifies the instance to other instances on the Bridge, and
+	// bridgeQueue holds the messages waiting to be published on it.
+	node        string
+	bridgeQueue chan []byte
+
+	maintenance maintenance
 
 	// Handlers
 	onMessage MessageHandler
@@ -57,23 +203,64 @@ func New(config Config) (*Gateway, error) {
 	if config.PingInterval == 0 {
 		config.PingInterval = 30 * time.Second
 	}
+	if config.PongTimeout == 0 {
+		config.PongTimeout = 2 * config.PingInterval
+	}
+	if config.PongTimeout <= config.PingInterval {
+		return nil, fmt.Errorf("pong timeout %s must exceed ping interval %s", config.PongTimeout, config.PingInterval)
+	}
+	if config.FrameTimeout <= 0 {
+		config.FrameTimeout = DefaultFrameTimeout
+	}
 	if config.Logger == nil {
 		config.Logger = slog.Default()
 	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = DefaultRequestTimeout
+	}
+	if config.RequestTTL <= 0 {
+		config.RequestTTL = DefaultRequestTTL
+	}
+	if config.MaxAsyncRequests <= 0 {
+		config.MaxAsyncRequests = DefaultMaxAsyncRequests
+	}
+	config.Batching = config.Batching.withDefaults()
+	config.Limits = config.Limits.withDefaults()
+	if p := config.Limits.SlowConsumer; p != SlowConsumerDrop && p != SlowConsumerDisconnect {
+		return nil, fmt.Errorf("unknown slow consumer policy %q", p)
+	}
 
 	gw := &Gateway{
 		config: config,
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: config.Compression,
+			Subprotocols:      subprotocols(),
 			CheckOrigin: func(r *http.Request) bool {
 				// TODO: Implement proper origin checking
 				return true
 			},
 		},
-		clients: make(map[string]*Client),
-		logger:  config.Logger,
-		agent:   config.Agent,
+		clients:    make(map[string]*Client),
+		logger:     config.Logger,
+		agent:      config.Agent,
+		tracer:     newTracer(config.TracerProvider),
+		requests:   newChatRequests(config.RequestTTL),
+		asyncSlots: make(chan struct{}, config.MaxAsyncRequests),
+		sessions:   newSessions(config.Resume),
+		files:      newFiles(config.Files),
+		limiter:    newMessageLimiter(config.Limits),
+		node:       uuid.New().String(),
+	}
+	if config.Bridge != nil {
+		gw.bridgeQueue = make(chan []byte, bridgeQueueSize)
+	}
+
+	if config.Metrics != nil {
+		config.Metrics.GaugeFunc("gateway_clients", nil, func() float64 {
+			return float64(gw.ClientCount())
+		})
 	}
 
 	// Set up default message handler
@@ -90,9 +277,7 @@ func (g *Gateway) OnMessage(handler MessageHandler) {
 
 // Run starts the gateway server.
 func (g *Gateway) Run(ctx context.Context) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", g.handleWebSocket)
-	mux.HandleFunc("/health", g.handleHealth)
+	mux := g.routes()
 
 	server := &http.Server{
 		Addr:         g.config.Address,
@@ -101,6 +286,11 @@ func (g *Gateway) Run(ctx context.Context) error {
 		WriteTimeout: g.config.WriteTimeout,
 	}
 
+	if g.config.Bridge != nil {
+		g.startBridge(ctx)
+	}
+	go g.reapClients(ctx)
+
 	// Start server in goroutine
 	errCh := make(chan error, 1)
 	go func() {
@@ -122,16 +312,133 @@ func (g *Gateway) Run(ctx context.Context) error {
 	}
 }
 
+// Handler returns the gateway's HTTP handler, for serving the gateway from
+// another server.
+func (g *Gateway) Handler() http.Handler {
+	return g.routes()
+}
+
+// routes builds the gateway's HTTP handler.
+func (g *Gateway) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", g.handleWebSocket)
+	mux.HandleFunc("GET /events", g.handleEvents)
+	mux.HandleFunc("GET /openapi.yaml", g.handleOpenAPI)
+	api := http.NewServeMux()
+	registerAPI(api, g)
+	mux.Handle("/", g.withAPIPrincipal(api))
+	if g.config.AdminToken != "" && g.config.Metrics != nil {
+		mux.HandleFunc("GET /debug/dashboard", g.requireAdmin(g.handleDashboard))
+		mux.HandleFunc("GET /debug/dashboard/data", g.requireAdmin(g.handleDashboardData))
+	}
+	if g.config.Metrics != nil && g.config.MetricsEndpoint {
+		handler := g.config.Metrics.PrometheusHandler(MetricsNamespace).ServeHTTP
+		switch {
+		case g.config.AdminToken != "":
+			mux.HandleFunc("GET /metrics", g.requireAdmin(handler))
+		case g.config.PublicMetrics:
+			mux.HandleFunc("GET /metrics", handler)
+		}
+	}
+	if g.config.AdminToken != "" && g.config.Diagnostics {
+		g.registerDiagnostics(mux)
+	}
+	if g.config.AdminToken != "" {
+		mux.HandleFunc("GET /admin/clients", g.requireAdmin(g.handleListClients))
+		mux.HandleFunc("DELETE /admin/clients/{id}", g.requireAdmin(g.handleDisconnectClient))
+		mux.HandleFunc("POST /admin/announcements", g.requireAdmin(g.handleAnnounce))
+		mux.HandleFunc("GET /admin/subscriptions", g.requireAdmin(g.handleSubscriptionCounts))
+		mux.HandleFunc("GET /admin/maintenance", g.requireAdmin(g.handleGetMaintenance))
+		mux.HandleFunc("PUT /admin/maintenance", g.requireAdmin(g.handleSetMaintenance))
+	}
+	if g.config.AdminToken != "" && g.config.Reload != nil {
+		mux.HandleFunc("POST /admin/reload", g.requireAdmin(g.handleReload))
+	}
+	if g.config.AdminToken != "" && g.config.Vars != nil {
+		mux.HandleFunc("GET /admin/vars/{channel}/{chat}", g.requireAdmin(g.handleGetVars))
+		mux.HandleFunc("PUT /admin/vars/{channel}/{chat}/{key}", g.requireAdmin(g.handleSetVar))
+		mux.HandleFunc("DELETE /admin/vars/{channel}/{chat}/{key}", g.requireAdmin(g.handleDeleteVar))
+	}
+	if g.config.AdminToken != "" && g.config.Sessions != nil {
+		mux.HandleFunc("GET /admin/sessions/{key}/config", g.requireAdmin(g.handleGetSessionConfig))
+		mux.HandleFunc("PUT /admin/sessions/{key}/config", g.requireAdmin(g.handleSetSessionConfig))
+		mux.HandleFunc("DELETE /admin/sessions/{key}/config", g.requireAdmin(g.handleDeleteSessionConfig))
+	}
+	if g.config.AdminToken != "" && g.config.Handoff != nil {
+		mux.HandleFunc("GET /admin/handoffs", g.requireAdmin(g.handleListHandoffs))
+		mux.HandleFunc("PUT /admin/handoffs/{channel}/{chat}", g.requireAdmin(g.handleStartHandoff))
+		mux.HandleFunc("DELETE /admin/handoffs/{channel}/{chat}", g.requireAdmin(g.handleReleaseHandoff))
+		mux.HandleFunc("POST /admin/handoffs/{channel}/{chat}/messages", g.requireAdmin(g.handleHandoffReply))
+	}
+	if g.config.AdminToken != "" && g.config.Redactor != nil {
+		mux.HandleFunc("POST /admin/redactions/restore", g.requireAdmin(g.handleRestore))
+	}
+	if g.config.AdminToken != "" && g.config.Sender != nil {
+		mux.HandleFunc("POST /admin/messages", g.requireAdmin(g.handleSend))
+	}
+	if g.config.AdminToken != "" && g.config.Inspector != nil {
+		mux.HandleFunc("GET /debug/sessions/{id}", g.requireAdmin(g.handleSessionDump))
+	}
+	return mux
+}
+
 // handleWebSocket handles WebSocket upgrade requests.
 func (g *Gateway) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if g.refuseMaintenance(w) {
+		return
+	}
+
+	var principal *Principal
+	if token := bearerToken(r); token != "" && g.config.Authenticator != nil {
+		p, err := g.authenticate(r.Context(), token)
+		if err != nil {
+			g.logger.Warn("websocket authentication failed", "error", err)
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		principal = p
+	}
+
+	// Clients may negotiate the protocol version when connecting, with
+	// comma-separated "version" query parameters. The version in use is
+	// returned in a response header.
+	version := ProtocolVersion
+	if offered := r.URL.Query()["version"]; len(offered) > 0 {
+		v, err := negotiateVersion(parseVersions(offered...))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		version = v
+	}
+
+	header := http.Header{ProtocolVersionHeader: {strconv.Itoa(version)}}
+	conn, err := g.upgrader.Upgrade(w, r, header)
 	if err != nil {
 		g.logger.Error("websocket upgrade failed", "error", err)
 		return
 	}
 
 	client := newClient(conn, g)
+	client.remoteAddr = r.RemoteAddr
+	client.setVersion(version)
+	if sub := conn.Subprotocol(); sub != "" {
+		client.encoding = strings.TrimPrefix(sub, SubprotocolPrefix)
+	}
+	caps := parseCapabilities(r.URL.Query()["capabilities"]...)
+	if !g.sessions.enabled() {
+		delete(caps, CapabilityResume)
+	}
+	client.setCapabilities(caps)
+	if principal != nil {
+		client.setPrincipal(principal)
+	}
+	if caps[CapabilityResume] {
+		g.sessions.start(client, r.URL.Query())
+	}
 	g.registerClient(client)
+	// A resumed client is back on the channels it subscribed to
+	g.announcePresence(client, client.presenceChannels(), client.Status())
 
 	go client.readPump()
 	go client.writePump()
@@ -139,9 +446,14 @@ func (g *Gateway) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 // handleHealth handles health check requests.
 func (g *Gateway) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"ok","clients":%d}`, g.ClientCount())
+	getHealthHandler(g)(w, r)
+}
+
+// observeMessage records the size of a WebSocket message sent or received.
+func (g *Gateway) observeMessage(direction string, size int) {
+	if g.config.Metrics != nil {
+		g.config.Metrics.Histogram("gateway_message_bytes", metrics.Labels{"direction": direction}, metrics.DefaultSizeBuckets).Observe(float64(size))
+	}
 }
 
 // registerClient registers a new client.
@@ -156,7 +468,8 @@ func (g *Gateway) registerClient(client *Client) {
 func (g *Gateway) unregisterClient(client *Client) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	if _, ok := g.clients[client.ID]; ok {
+	// A resumed client may have taken over the ID
+	if g.clients[client.ID] == client {
 		delete(g.clients, client.ID)
 		g.logger.Info("client disconnected", "id", client.ID)
 	}
@@ -169,18 +482,63 @@ func (g *Gateway) ClientCount() int {
 	return len(g.clients)
 }
 
-// Broadcast sends a message to all connected clients.
+// Broadcast sends a message to all connected clients and to the sessions
+// of disconnected resumable clients, on this instance and, with a Bridge,
+// on the others.
 func (g *Gateway) Broadcast(msg *Message) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	for _, client := range g.clients {
+	g.broadcast(msg)
+	g.share("", msg)
+}
+
+// broadcast sends a message to the clients of this instance.
+func (g *Gateway) broadcast(msg *Message) {
+	for _, client := range g.recipients() {
 		client.Send(msg)
 	}
 }
 
-// GetClient returns a client by ID.
+// GetClient returns a client by ID. For a disconnected client with a
+// resumable session, it returns the closed client, whose messages are
+// buffered for when it resumes.
 func (g *Gateway) GetClient(id string) *Client {
 	g.mu.RLock()
-	defer g.mu.RUnlock()
-	return g.clients[id]
+	client := g.clients[id]
+	g.mu.RUnlock()
+	if client == nil {
+		client = g.sessions.detached(id)
+	}
+	return client
+}
+
+// Disconnect closes a client's connection, ending its resumable session
+// and canceling its requests, and reports whether the client was found.
+func (g *Gateway) Disconnect(id, reason string) bool {
+	client := g.GetClient(id)
+	if client == nil {
+		return false
+	}
+	if client.session != nil {
+		g.sessions.end(client.session)
+	}
+	if client.conn != nil {
+		_ = client.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason),
+			time.Now().Add(g.config.FrameTimeout))
+	}
+	client.Close()
+	client.streams.cancelAll()
+	g.logger.Info("client disconnected", "id", id, "reason", reason)
+	return true
+}
+
+// recipients returns the connected clients and the closed clients of
+// resumable sessions.
+func (g *Gateway) recipients() []*Client {
+	g.mu.RLock()
+	clients := make([]*Client, 0, len(g.clients))
+	for _, client := range g.clients {
+		clients = append(clients, client)
+	}
+	g.mu.RUnlock()
+	return append(clients, g.sessions.detachedClients()...)
 }
@@ -1,15 +1,30 @@
 package gateway
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/chatvars"
+	"github.com/agentplexus/envoy/handoff"
+	"github.com/agentplexus/envoy/internal/channelstest"
+	"github.com/agentplexus/envoy/metrics"
+	"github.com/agentplexus/envoy/ratelimit"
+	"github.com/agentplexus/envoy/redact"
+	agentsessions "github.com/agentplexus/envoy/sessions"
 )
 
 // mockAgent is a simple agent for testing.
@@ -196,6 +211,85 @@ func TestGatewayHealth(t *testing.T) {
 	}
 }
 
+func TestChatAPI(t *testing.T) {
+	gw, err := New(Config{
+		Agent:         &mockAgent{},
+		Authenticator: APIKeys{{Key: "k1", Subject: "ci"}, {Key: "k2", Subject: "ops"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	call := func(method, path, token, body string) (int, ChatResponse) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out ChatResponse
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if status, _ := call(http.MethodPost, "/v1/chat", "", `{"content": "hi"}`); status != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", status)
+	}
+	if status, _ := call(http.MethodPost, "/v1/chat", "k1", `{"content": ""}`); status != http.StatusBadRequest {
+		t.Errorf("empty content status = %d, want 400", status)
+	}
+
+	status, resp := call(http.MethodPost, "/v1/chat", "k1", `{"content": "hi"}`)
+	if status != http.StatusOK || resp.Status != ChatStatusCompleted || resp.Content != "Echo: hi" || resp.SessionID == "" {
+		t.Errorf("sync chat = %d %+v", status, resp)
+	}
+	_, resp = call(http.MethodPost, "/v1/sessions/s1/messages", "k1", `{"content": "again"}`)
+	if resp.SessionID != "s1" || resp.Content != "Echo: again" {
+		t.Errorf("session message = %+v", resp)
+	}
+
+	// Async results are polled or pushed to the requester's client
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws",
+		http.Header{"Authorization": {"Bearer k1"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_ = conn.WriteJSON(Message{Type: MessageTypeAuth, Data: map[string]interface{}{"token": "k1"}})
+	var auth Message
+	if err := conn.ReadJSON(&auth); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	status, pending := call(http.MethodPost, "/v1/chat", "k1",
+		`{"content": "later", "mode": "async", "client_id": "`+auth.Data["client_id"].(string)+`"}`)
+	if status != http.StatusOK || pending.Status != ChatStatusPending || pending.RequestID == "" {
+		t.Fatalf("async chat = %d %+v", status, pending)
+	}
+	var pushed Message
+	if err := conn.ReadJSON(&pushed); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if pushed.ID != pending.RequestID || pushed.Content != "Echo: later" || pushed.Data["status"] != ChatStatusCompleted {
+		t.Errorf("pushed result = %+v", pushed)
+	}
+
+	_, polled := call(http.MethodGet, "/v1/requests/"+pending.RequestID, "k1", "")
+	if polled.Status != ChatStatusCompleted || polled.Content != "Echo: later" {
+		t.Errorf("polled result = %+v", polled)
+	}
+	if status, _ := call(http.MethodGet, "/v1/requests/"+pending.RequestID, "k2", ""); status != http.StatusNotFound {
+		t.Errorf("other principal poll status = %d, want 404", status)
+	}
+}
+
 func TestGatewayNoAgent(t *testing.T) {
 	// Create gateway without agent (echo mode)
 	gw, err := New(Config{Address: "127.0.0.1:0"})
@@ -295,3 +389,2063 @@ func TestGatewayBroadcast(t *testing.T) {
 		}
 	}
 }
+
+func TestDashboardRequiresAdmin(t *testing.T) {
+	registry := metrics.NewRegistry(metrics.Config{})
+	registry.Counter("messages_received", metrics.Labels{"channel": "telegram"}).Inc()
+
+	gw, err := New(Config{AdminToken: "secret", Metrics: registry})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/dashboard", gw.requireAdmin(gw.handleDashboard))
+	mux.HandleFunc("GET /debug/dashboard/data", gw.requireAdmin(gw.handleDashboardData))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/debug/dashboard")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/debug/dashboard/data", nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("authenticated status = %d, want 200", resp.StatusCode)
+	}
+
+	var snap metrics.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	names := map[string]bool{}
+	for _, s := range snap.Samples {
+		names[s.Name] = true
+	}
+	if !names["messages_received"] || !names["gateway_clients"] {
+		t.Errorf("samples = %+v, want messages_received and gateway_clients", snap.Samples)
+	}
+}
+
+func TestDiagnosticsEndpoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		auth       string
+		wantStatus int
+	}{
+		{"disabled by default", Config{AdminToken: "secret"}, "Bearer secret", http.StatusNotFound},
+		{"requires admin token", Config{Diagnostics: true}, "Bearer secret", http.StatusNotFound},
+		{"unauthenticated", Config{AdminToken: "secret", Diagnostics: true}, "", http.StatusUnauthorized},
+		{"wrong token", Config{AdminToken: "secret", Diagnostics: true}, "Bearer nope", http.StatusUnauthorized},
+		{"authenticated", Config{AdminToken: "secret", Diagnostics: true}, "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, err := New(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create gateway: %v", err)
+			}
+			server := httptest.NewServer(gw.routes())
+			defer server.Close()
+
+			for _, path := range []string{"/debug/goroutines", "/debug/vars", "/debug/pprof/heap"} {
+				req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+				if tt.auth != "" {
+					req.Header.Set("Authorization", tt.auth)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("%s: status = %d, want %d", path, resp.StatusCode, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayEventBatching(t *testing.T) {
+	gw, err := New(Config{Address: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", gw.handleWebSocket)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Enable batching
+	if err := conn.WriteJSON(Message{
+		ID:   "batch-1",
+		Type: MessageTypeBatch,
+		Data: map[string]interface{}{"interval_ms": 50},
+	}); err != nil {
+		t.Fatalf("Failed to send batch message: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	var resp Message
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatalf("Failed to read batch response: %v", err)
+	}
+	if resp.Data["interval_ms"] != float64(50) {
+		t.Fatalf("interval_ms = %v, want 50", resp.Data["interval_ms"])
+	}
+
+	for i := 0; i < 3; i++ {
+		gw.Broadcast(NewEventMessage(EventTyping, "discord", map[string]interface{}{"n": i}))
+	}
+	gw.Broadcast(NewEventMessage("message", "discord", nil))
+
+	// Unbatched events are sent immediately, ahead of the batch
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read event: %v", err)
+	}
+	if msg.Type != MessageTypeEvent || msg.Content != "message" {
+		t.Fatalf("first frame = %s %q, want message event", msg.Type, msg.Content)
+	}
+
+	msg = Message{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read batch: %v", err)
+	}
+	if msg.Type != MessageTypeEvents {
+		t.Fatalf("second frame = %s, want events", msg.Type)
+	}
+	if len(msg.Events) != 3 {
+		t.Fatalf("batch has %d events, want 3", len(msg.Events))
+	}
+	for i, e := range msg.Events {
+		if e.Content != EventTyping || e.Data["n"] != float64(i) {
+			t.Errorf("event %d = %q %v", i, e.Content, e.Data)
+		}
+	}
+}
+
+func TestGatewayCompression(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		gw, err := New(Config{Address: "127.0.0.1:0", Compression: enabled})
+		if err != nil {
+			t.Fatalf("Failed to create gateway: %v", err)
+		}
+		server := httptest.NewServer(http.HandlerFunc(gw.handleWebSocket))
+
+		dialer := websocket.Dialer{EnableCompression: true}
+		conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		extensions := resp.Header.Get("Sec-WebSocket-Extensions")
+		if got := strings.Contains(extensions, "permessage-deflate"); got != enabled {
+			t.Errorf("Compression %v: extensions = %q", enabled, extensions)
+		}
+
+		// Compressed frames round-trip
+		if err := conn.WriteJSON(Message{ID: "p", Type: MessageTypePing}); err != nil {
+			t.Fatalf("Failed to send ping: %v", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		var pong Message
+		if err := conn.ReadJSON(&pong); err != nil || pong.Type != MessageTypePong {
+			t.Errorf("Compression %v: pong = %+v, %v", enabled, pong, err)
+		}
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestBatchIntervalClamped(t *testing.T) {
+	var flushed []*Message
+	b := newBatcher(BatchConfig{MaxInterval: time.Second}.withDefaults(), func(m *Message) {
+		flushed = append(flushed, m)
+	})
+
+	if got := b.setInterval(time.Minute); got != time.Second {
+		t.Errorf("setInterval(1m) = %v, want 1s", got)
+	}
+	if !b.add(NewEventMessage(EventPresence, "", nil)) {
+		t.Fatal("presence event not batched")
+	}
+	if b.add(NewChatResponse("1", "hi")) {
+		t.Error("response should not be batched")
+	}
+
+	// Disabling batching flushes pending events
+	b.setInterval(0)
+	if len(flushed) != 1 || len(flushed[0].Events) != 1 {
+		t.Errorf("flushed = %v, want one frame with one event", flushed)
+	}
+	if b.add(NewEventMessage(EventPresence, "", nil)) {
+		t.Error("event batched after batching was disabled")
+	}
+}
+
+func TestReloadEndpoint(t *testing.T) {
+	reloads := 0
+	gw, err := New(Config{
+		AdminToken: "secret",
+		Reload: func(ctx context.Context) error {
+			reloads++
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/admin/reload", "", nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/admin/reload", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || reloads != 1 {
+		t.Errorf("status = %d, reloads = %d, want 200 and 1", resp.StatusCode, reloads)
+	}
+}
+
+func TestVarsEndpoints(t *testing.T) {
+	vars := chatvars.New(chatvars.Config{})
+	gw, err := New(Config{AdminToken: "secret", Vars: vars})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPut, "/admin/vars/telegram/42/tier", `{"value": "gold"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("set status = %d, want 204", resp.StatusCode)
+	}
+	resp = do(http.MethodPut, "/admin/vars/telegram/42/not-a-name", `{"value": "x"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid key status = %d, want 400", resp.StatusCode)
+	}
+
+	resp = do(http.MethodGet, "/admin/vars/telegram/42", "")
+	var got map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resp.Body.Close()
+	if got["tier"] != "gold" || len(got) != 1 {
+		t.Errorf("vars = %v, want tier=gold", got)
+	}
+
+	resp = do(http.MethodDelete, "/admin/vars/telegram/42/tier", "")
+	resp.Body.Close()
+	if v, _ := vars.Get(context.Background(), "telegram", "42"); len(v) != 0 {
+		t.Errorf("vars after delete = %v", v)
+	}
+}
+
+func TestSessionConfigEndpoints(t *testing.T) {
+	manager := agentsessions.New(agentsessions.Config{})
+	msg := channels.IncomingMessage{ChannelName: "telegram", ChatID: "42"}
+	if _, err := manager.Resolve(context.Background(), msg); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	gw, err := New(Config{AdminToken: "secret", Sessions: manager})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPut, "/admin/sessions/telegram:42/config", `{"model": "small", "language": "German"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("set status = %d, want 204", resp.StatusCode)
+	}
+	resp = do(http.MethodPut, "/admin/sessions/telegram:7/config", `{"model": "small"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session status = %d, want 404", resp.StatusCode)
+	}
+
+	resp = do(http.MethodGet, "/admin/sessions/telegram:42/config", "")
+	var got channels.SessionConfig
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resp.Body.Close()
+	if got != (channels.SessionConfig{Model: "small", Language: "German"}) {
+		t.Errorf("config = %+v", got)
+	}
+
+	resp = do(http.MethodDelete, "/admin/sessions/telegram:42/config", "")
+	resp.Body.Close()
+	if _, config, _ := manager.ResolveSession(context.Background(), msg); !config.IsZero() {
+		t.Errorf("config after delete = %+v", config)
+	}
+}
+
+func TestHandoffEndpoints(t *testing.T) {
+	sender := &channelstest.Sender{Err: telegramOnly}
+	handoffs, err := handoff.New(handoff.Config{
+		Operators: handoff.Target{Channel: "telegram", ChatID: "ops"},
+		Sender:    sender,
+	})
+	if err != nil {
+		t.Fatalf("handoff.New failed: %v", err)
+	}
+	gw, err := New(Config{AdminToken: "secret", Handoff: handoffs})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPut, "/admin/handoffs/telegram/42", `{"reason": "angry customer"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("start status = %d, want 200", resp.StatusCode)
+	}
+
+	resp = do(http.MethodGet, "/admin/handoffs", "")
+	var list struct {
+		Handoffs []handoff.Handoff `json:"handoffs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resp.Body.Close()
+	if len(list.Handoffs) != 1 || list.Handoffs[0].Reason != "angry customer" {
+		t.Errorf("handoffs = %+v", list.Handoffs)
+	}
+
+	resp = do(http.MethodPost, "/admin/handoffs/telegram/42/messages", `{"content": "Hi, I'm Sam."}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || sender.Last("42") != "Hi, I'm Sam." {
+		t.Errorf("reply status = %d, sent = %q", resp.StatusCode, sender.Contents())
+	}
+
+	resp = do(http.MethodDelete, "/admin/handoffs/telegram/42", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("release status = %d, want 204", resp.StatusCode)
+	}
+	resp = do(http.MethodDelete, "/admin/handoffs/telegram/42", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("second release status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestRestoreEndpoint(t *testing.T) {
+	redactor := redact.New(redact.Config{Vault: redact.NewMemoryVault()})
+	masked, err := redactor.Redact(context.Background(), "mail ann@example.com")
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	gw, err := New(Config{AdminToken: "secret", Redactor: redactor})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	body, _ := json.Marshal(map[string]string{"content": masked})
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "secret": http.StatusOK} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/admin/redactions/restore", strings.NewReader(string(body)))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var got struct {
+			Content string `json:"content"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("status with token %q = %d, want %d", token, resp.StatusCode, want)
+		}
+		if want == http.StatusOK && got.Content != "mail ann@example.com" {
+			t.Errorf("restored = %q", got.Content)
+		}
+	}
+}
+
+// telegramOnly fails sends to channels other than telegram.
+func telegramOnly(channelName, _ string) error {
+	if channelName != "telegram" {
+		return fmt.Errorf("channel not found: %s", channelName)
+	}
+	return nil
+}
+
+func TestSendEndpoint(t *testing.T) {
+	sender := &channelstest.Sender{Err: telegramOnly}
+	gw, err := New(Config{AdminToken: "secret", Sender: sender})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	send := func(token, body string) (*http.Response, []SendResult) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/admin/messages", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Results []SendResult `json:"results"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out.Results
+	}
+
+	body := `{"targets": [{"channel": "telegram", "chat_id": "42"}], "content": "deployed"}`
+	if resp, _ := send("wrong", body); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", resp.StatusCode)
+	}
+	if resp, _ := send("secret", `{"content": "deployed"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("no targets status = %d, want 400", resp.StatusCode)
+	}
+
+	resp, results := send("secret", body)
+	if resp.StatusCode != http.StatusOK || len(results) != 1 || results[0].Error != "" {
+		t.Errorf("status = %d, results = %+v, want 200 without errors", resp.StatusCode, results)
+	}
+
+	resp, results = send("secret", `{"targets": [{"channel": "telegram", "chat_id": "7"}, {"channel": "slack", "chat_id": "C1"}], "content": "down"}`)
+	if resp.StatusCode != http.StatusBadGateway || len(results) != 2 || results[0].Error != "" || results[1].Error == "" {
+		t.Errorf("status = %d, results = %+v, want 502 with the slack target failed", resp.StatusCode, results)
+	}
+	var sent []string
+	for _, m := range sender.Sent() {
+		sent = append(sent, m.ChatID+": "+m.Content)
+	}
+	if want := []string{"42: deployed", "7: down"}; strings.Join(sent, ",") != strings.Join(want, ",") {
+		t.Errorf("sent = %v, want %v", sent, want)
+	}
+}
+
+// mockStreamingAgent streams its response in two chunks.
+type mockStreamingAgent struct {
+	mockAgent
+}
+
+func (m *mockStreamingAgent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error) {
+	ch := make(chan channels.Chunk, 2)
+	ch <- channels.Chunk{Content: "Hello, "}
+	ch <- channels.Chunk{Content: "world"}
+	close(ch)
+	return ch, nil
+}
+
+func TestClientCapabilities(t *testing.T) {
+	gw, err := New(Config{Address: "127.0.0.1:0", Agent: &mockStreamingAgent{}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", gw.handleWebSocket)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	dial := func(t *testing.T, query string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+query, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		return conn
+	}
+	read := func(t *testing.T, conn *websocket.Conn) Message {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		return msg
+	}
+
+	t.Run("legacy", func(t *testing.T) {
+		conn := dial(t, "")
+		defer conn.Close()
+
+		if err := conn.WriteJSON(Message{ID: "c1", Type: MessageTypeChat, Content: "hi"}); err != nil {
+			t.Fatalf("Failed to send chat: %v", err)
+		}
+		if msg := read(t, conn); msg.Type != MessageTypeResponse || msg.Content != "Echo: hi" {
+			t.Fatalf("got %s %q, want unstreamed response", msg.Type, msg.Content)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		conn := dial(t, "?capabilities=stream,acks,unknown")
+		defer conn.Close()
+
+		if err := conn.WriteJSON(Message{ID: "c1", Type: MessageTypeChat, Content: "hi"}); err != nil {
+			t.Fatalf("Failed to send chat: %v", err)
+		}
+		var got []string
+		for {
+			msg := read(t, conn)
+			if msg.ID != "c1" {
+				t.Fatalf("frame ID = %q, want c1", msg.ID)
+			}
+			got = append(got, string(msg.Type)+":"+msg.Content)
+			if msg.Type == MessageTypeStreamEnd {
+				break
+			}
+		}
+		want := []string{"ack:", "stream_start:", "stream_chunk:Hello, ", "stream_chunk:world", "stream_end:"}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Fatalf("frames = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("hello", func(t *testing.T) {
+		conn := dial(t, "")
+		defer conn.Close()
+
+		if err := conn.WriteJSON(Message{
+			ID:   "h1",
+			Type: MessageTypeHello,
+			Data: map[string]interface{}{"capabilities": []string{"binary", "bogus"}},
+		}); err != nil {
+			t.Fatalf("Failed to send hello: %v", err)
+		}
+		msg := read(t, conn)
+		caps, _ := msg.Data["capabilities"].([]interface{})
+		if len(caps) != 1 || caps[0] != "binary" {
+			t.Fatalf("capabilities = %v, want [binary]", msg.Data["capabilities"])
+		}
+
+		gw.Broadcast(&Message{
+			Type:        MessageTypeEvent,
+			Attachments: []*Attachment{{Name: "a.txt", Data: []byte("abc")}},
+		})
+		msg = read(t, conn)
+		if len(msg.Attachments) != 1 || msg.Attachments[0].Data != nil || msg.Attachments[0].Size != 3 {
+			t.Fatalf("attachments = %+v, want metadata only", msg.Attachments[0])
+		}
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read attachment: %v", err)
+		}
+		if kind != websocket.BinaryMessage || string(data) != "abc" {
+			t.Fatalf("attachment frame = %d %q, want binary abc", kind, data)
+		}
+	})
+}
+
+// blockingStreamingAgent streams one chunk, then waits for cancellation.
+type blockingStreamingAgent struct {
+	mockAgent
+}
+
+func (m *blockingStreamingAgent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error) {
+	ch := make(chan channels.Chunk)
+	go func() {
+		defer close(ch)
+		ch <- channels.Chunk{Content: "thinking"}
+		<-ctx.Done()
+		ch <- channels.Chunk{Err: ctx.Err()}
+	}()
+	return ch, nil
+}
+
+func TestStreamingResponses(t *testing.T) {
+	stream := func(t *testing.T, agent AgentProcessor) *websocket.Conn {
+		t.Helper()
+		gw, err := New(Config{Agent: agent})
+		if err != nil {
+			t.Fatalf("Failed to create gateway: %v", err)
+		}
+		server := httptest.NewServer(gw.routes())
+		t.Cleanup(server.Close)
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?capabilities=stream", nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		return conn
+	}
+	read := func(t *testing.T, conn *websocket.Conn) Message {
+		t.Helper()
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		return msg
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		conn := stream(t, &mockStreamingAgent{})
+		if err := conn.WriteJSON(Message{ID: "s1", Type: MessageTypeChat, Content: "hi"}); err != nil {
+			t.Fatalf("Failed to send chat: %v", err)
+		}
+		var got []string
+		for {
+			msg := read(t, conn)
+			if msg.ID != "s1" {
+				t.Fatalf("frame ID = %q, want s1", msg.ID)
+			}
+			got = append(got, string(msg.Type)+":"+msg.Content)
+			if msg.Type == MessageTypeStreamEnd {
+				if msg.Error != "" || msg.Data["canceled"] != nil {
+					t.Errorf("stream_end = %+v, want success", msg)
+				}
+				break
+			}
+		}
+		want := []string{"stream_start:", "stream_chunk:Hello, ", "stream_chunk:world", "stream_end:"}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Fatalf("frames = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		conn := stream(t, &blockingStreamingAgent{})
+		if err := conn.WriteJSON(Message{ID: "s2", Type: MessageTypeChat, Content: "hi"}); err != nil {
+			t.Fatalf("Failed to send chat: %v", err)
+		}
+		if msg := read(t, conn); msg.Type != MessageTypeStreamStart {
+			t.Fatalf("first frame = %s, want stream_start", msg.Type)
+		}
+		if msg := read(t, conn); msg.Type != MessageTypeStreamChunk || msg.Content != "thinking" {
+			t.Fatalf("second frame = %s %q, want the first chunk", msg.Type, msg.Content)
+		}
+
+		// The connection keeps serving messages while the stream runs
+		if err := conn.WriteJSON(Message{ID: "p1", Type: MessageTypePing}); err != nil {
+			t.Fatalf("Failed to send ping: %v", err)
+		}
+		if msg := read(t, conn); msg.Type != MessageTypePong {
+			t.Fatalf("got %s, want pong during the stream", msg.Type)
+		}
+
+		if err := conn.WriteJSON(Message{ID: "s2", Type: MessageTypeCancel}); err != nil {
+			t.Fatalf("Failed to send cancel: %v", err)
+		}
+		var canceled, ended bool
+		for !canceled || !ended {
+			msg := read(t, conn)
+			switch msg.Type {
+			case MessageTypeResponse:
+				canceled = msg.Data["canceled"] == true
+			case MessageTypeStreamEnd:
+				ended = msg.Data["canceled"] == true && msg.Error == ""
+			default:
+				t.Fatalf("unexpected %s frame", msg.Type)
+			}
+		}
+
+		if err := conn.WriteJSON(Message{ID: "s2", Type: MessageTypeCancel}); err != nil {
+			t.Fatalf("Failed to send cancel: %v", err)
+		}
+		if msg := read(t, conn); msg.Error != "no active request" {
+			t.Errorf("second cancel = %+v, want no active request", msg)
+		}
+	})
+}
+
+func TestEventStream(t *testing.T) {
+	gw, err := New(Config{Authenticator: APIKeys{{Key: "good", Subject: "web"}}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?token=bad")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("bad token status = %d, want 401", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events?token=good&channels=alerts,deploys", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	events := bufio.NewReader(resp.Body)
+	next := func() (string, Message) {
+		t.Helper()
+		var event string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read event: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				event = name
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var msg Message
+				if err := json.Unmarshal([]byte(data), &msg); err != nil {
+					t.Fatalf("decode event: %v", err)
+				}
+				return event, msg
+			}
+		}
+	}
+
+	event, ready := next()
+	clientID, _ := ready.Data["client_id"].(string)
+	if event != "ready" || clientID == "" {
+		t.Fatalf("first event = %s %+v, want ready with a client ID", event, ready)
+	}
+	client := gw.GetClient(clientID)
+	if client == nil {
+		t.Fatal("SSE client not registered")
+	}
+	if subs := client.Subscriptions(); strings.Join(subs, ",") != "alerts,deploys" {
+		t.Errorf("subscriptions = %v", subs)
+	}
+	if client.Principal() == nil || client.Principal().Subject != "web" {
+		t.Errorf("principal = %+v, want web", client.Principal())
+	}
+
+	gw.Broadcast(NewEventMessage("deployed", "deploys", map[string]interface{}{"version": "1.2"}))
+	event, msg := next()
+	if event != "event" || msg.Content != "deployed" || msg.Data["version"] != "1.2" {
+		t.Errorf("broadcast = %s %+v", event, msg)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for gw.ClientCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if gw.ClientCount() != 0 {
+		t.Error("SSE client not unregistered after disconnect")
+	}
+}
+
+func TestMatchChannel(t *testing.T) {
+	tests := []struct {
+		pattern, channel string
+		want             bool
+	}{
+		{"alerts", "alerts", true},
+		{"alerts", "alerts.prod", false},
+		{"*", "anything", true},
+		{"deploys.*", "deploys.prod", true},
+		{"deploys.*", "deploys", false},
+		{"*.prod", "deploys.prod", true},
+		{"*.prod", "deploys.staging", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxcyyb", false},
+		{"a*a", "a", false},
+	}
+	for _, tt := range tests {
+		if got := MatchChannel(tt.pattern, tt.channel); got != tt.want {
+			t.Errorf("MatchChannel(%q, %q) = %v, want %v", tt.pattern, tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	gw, err := New(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	dial := func(channel string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.WriteJSON(Message{Type: MessageTypeSubscribe, Channel: channel}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil || resp.Data["subscribed"] != true {
+			t.Fatalf("subscribe %s = %+v, %v", channel, resp, err)
+		}
+		return conn
+	}
+	deploys := dial("deploys.*")
+	defer deploys.Close()
+	alerts := dial("alerts")
+	defer alerts.Close()
+
+	if n := gw.Publish("deploys.prod", NewEventMessage("deployed", "", nil)); n != 1 {
+		t.Errorf("Publish reached %d clients, want 1", n)
+	}
+	var msg Message
+	if err := deploys.ReadJSON(&msg); err != nil || msg.Channel != "deploys.prod" || msg.Content != "deployed" {
+		t.Errorf("subscriber received %+v, %v", msg, err)
+	}
+
+	// The alerts client only receives alerts
+	gw.Publish("alerts", NewEventMessage("disk full", "", nil))
+	if err := alerts.ReadJSON(&msg); err != nil || msg.Content != "disk full" {
+		t.Errorf("alerts subscriber received %+v, %v", msg, err)
+	}
+
+	if err := deploys.WriteJSON(Message{Type: MessageTypeUnsubscribe, Channel: "deploys.*"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := deploys.ReadJSON(&msg); err != nil || msg.Data["unsubscribed"] != true {
+		t.Fatalf("unsubscribe = %+v, %v", msg, err)
+	}
+	if n := gw.Publish("deploys.prod", NewEventMessage("deployed", "", nil)); n != 0 {
+		t.Errorf("Publish after unsubscribe reached %d clients, want 0", n)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	registry := metrics.NewRegistry(metrics.Config{})
+	gw, err := New(Config{Metrics: registry, MetricsEndpoint: true, PublicMetrics: true})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(Message{ID: "p", Type: MessageTypePing}); err != nil {
+		t.Fatalf("Failed to send ping: %v", err)
+	}
+	var pong Message
+	if err := conn.ReadJSON(&pong); err != nil {
+		t.Fatalf("Failed to read pong: %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != metrics.PrometheusContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body strings.Builder
+	_, _ = io.Copy(&body, resp.Body)
+	for _, want := range []string{
+		"envoy_gateway_clients 1\n",
+		`envoy_gateway_message_bytes_count{direction="in"} 1` + "\n",
+		`envoy_gateway_message_bytes_count{direction="out"} 1` + "\n",
+	} {
+		if !strings.Contains(body.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, body.String())
+		}
+	}
+}
+
+func TestMetricsEndpointFailsClosed(t *testing.T) {
+	registry := metrics.NewRegistry(metrics.Config{})
+	tests := []struct {
+		name   string
+		config Config
+		header string
+		want   int
+	}{
+		{"no token", Config{Metrics: registry, MetricsEndpoint: true}, "", http.StatusNotFound},
+		{"missing token", Config{Metrics: registry, MetricsEndpoint: true, AdminToken: "secret"}, "", http.StatusUnauthorized},
+		{"admin token", Config{Metrics: registry, MetricsEndpoint: true, AdminToken: "secret"}, "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, err := New(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create gateway: %v", err)
+			}
+			server := httptest.NewServer(gw.routes())
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterChannel(t *testing.T) {
+	gw, err := New(Config{Agent: &mockAgent{}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ch := NewChannel(gw)
+	router := channels.NewRouter(slog.Default())
+	router.Register(ch)
+	router.OnMessage(channels.RoutePattern{}, func(ctx context.Context, msg channels.IncomingMessage) error {
+		return router.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+			Content: "Routed: " + msg.Content,
+			ReplyTo: msg.ID,
+		})
+	})
+	ctx := context.Background()
+	if err := router.ConnectAll(ctx); err != nil {
+		t.Fatalf("ConnectAll: %v", err)
+	}
+
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	if err := conn.WriteJSON(Message{ID: "1", Type: MessageTypeChat, Content: "hi"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil || msg.ID != "1" || msg.Content != "Routed: hi" {
+		t.Errorf("routed chat = %+v, %v", msg, err)
+	}
+
+	// Router sends reach clients
+	if err := router.Send(ctx, ChannelName, BroadcastChatID, channels.OutgoingMessage{Content: "notice"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg.Content != "notice" {
+		t.Errorf("broadcast = %+v, %v", msg, err)
+	}
+	if err := router.Send(ctx, ChannelName, "missing", channels.OutgoingMessage{Content: "x"}); err == nil {
+		t.Error("Send to unknown client succeeded")
+	}
+
+	// Disconnected, chat goes to the gateway's agent
+	if err := router.DisconnectAll(ctx); err != nil {
+		t.Fatalf("DisconnectAll: %v", err)
+	}
+	if err := conn.WriteJSON(Message{ID: "2", Type: MessageTypeChat, Content: "hi"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg.Content != "Echo: hi" {
+		t.Errorf("chat after disconnect = %+v, %v", msg, err)
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	gw, err := New(Config{Resume: ResumeConfig{BufferSize: 10}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?capabilities=resume"
+
+	dial := func(query string) (*websocket.Conn, Message) {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+query, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil || msg.Type != MessageTypeSession {
+			t.Fatalf("session frame = %+v, %v", msg, err)
+		}
+		return conn, msg
+	}
+
+	conn, session := dial("")
+	token, _ := session.Data["token"].(string)
+	clientID, _ := session.Data["client_id"].(string)
+	if token == "" || clientID == "" || session.Data["resumed"] != false {
+		t.Fatalf("new session = %+v", session)
+	}
+	if err := conn.WriteJSON(Message{ID: "1", Type: MessageTypeSubscribe, Channel: "alerts"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil || msg.Seq != 1 {
+		t.Fatalf("subscribe response = %+v, %v", msg, err)
+	}
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for gw.ClientCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Messages sent while disconnected are buffered
+	if n := gw.Publish("alerts", NewEventMessage("disk full", "", nil)); n != 1 {
+		t.Errorf("Publish while disconnected reached %d clients, want 1", n)
+	}
+	if c := gw.GetClient(clientID); c == nil {
+		t.Fatal("GetClient of disconnected session = nil")
+	} else {
+		c.Send(NewChatResponse("2", "late reply"))
+	}
+
+	conn, session = dial("&session=" + token + "&last_seq=1")
+	defer conn.Close()
+	if session.Data["resumed"] != true || session.Data["client_id"] != clientID {
+		t.Fatalf("resumed session = %+v", session)
+	}
+	for _, want := range []string{"disk full", "late reply"} {
+		if err := conn.ReadJSON(&msg); err != nil || msg.Content != want {
+			t.Errorf("replayed frame = %+v, %v, want %q", msg, err, want)
+		}
+	}
+	if msg.Seq != 3 {
+		t.Errorf("last replayed seq = %d, want 3", msg.Seq)
+	}
+	if gw.GetClient(clientID).Subscriptions()[0] != "alerts" {
+		t.Error("subscriptions not restored")
+	}
+
+	// The session is in use, so it cannot be resumed twice
+	other, session := dial("&session=" + token)
+	defer other.Close()
+	if session.Data["resumed"] != false || session.Data["client_id"] == clientID {
+		t.Errorf("second resume = %+v, want new session", session)
+	}
+}
+
+func TestClientLimits(t *testing.T) {
+	if _, err := New(Config{Limits: LimitsConfig{SlowConsumer: "block"}}); err == nil {
+		t.Error("New with unknown slow consumer policy succeeded")
+	}
+
+	gw, err := New(Config{
+		Agent: &blockingStreamingAgent{},
+		Limits: LimitsConfig{
+			MessageRate: ratelimit.Rate{Events: 3, Per: time.Hour},
+			MaxInFlight: 1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?capabilities=stream", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// waitFor reads frames until one for id arrives
+	waitFor := func(id string) Message {
+		t.Helper()
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if msg.ID == id && msg.Type != MessageTypeStreamStart && msg.Type != MessageTypeStreamChunk {
+				return msg
+			}
+		}
+	}
+	for _, id := range []string{"c1", "c2"} {
+		if err := conn.WriteJSON(Message{ID: id, Type: MessageTypeChat, Content: "hi"}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if msg := waitFor("c2"); msg.Error != "too many requests in flight" {
+		t.Errorf("second stream = %+v, want in-flight error", msg)
+	}
+	if err := conn.WriteJSON(Message{ID: "c1", Type: MessageTypeCancel}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := waitFor("c1"); msg.Data["canceled"] != true {
+		t.Errorf("cancel = %+v", msg)
+	}
+	if err := conn.WriteJSON(Message{ID: "p1", Type: MessageTypePing}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := waitFor("p1"); msg.Error != "rate limit exceeded" {
+		t.Errorf("fourth message = %+v, want rate limit error", msg)
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	gw, err := New(Config{Limits: LimitsConfig{SendQueue: 1, SlowConsumer: SlowConsumerDisconnect}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	client := newClient(nil, gw)
+	gw.registerClient(client)
+	client.Send(NewChatResponse("1", "a"))
+	client.Send(NewChatResponse("2", "b"))
+	select {
+	case <-client.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow client not disconnected")
+	}
+	if gw.ClientCount() != 0 {
+		t.Errorf("ClientCount = %d, want 0", gw.ClientCount())
+	}
+}
+
+// memBridge is an in-process Bridge delivering payloads synchronously.
+type memBridge struct {
+	mu       sync.Mutex
+	handlers []func([]byte)
+}
+
+func (b *memBridge) Publish(_ context.Context, payload []byte) error {
+	b.mu.Lock()
+	handlers := append([]func([]byte){}, b.handlers...)
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(payload)
+	}
+	return nil
+}
+
+func (b *memBridge) Subscribe(ctx context.Context, handler func([]byte)) error {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBridge(t *testing.T) {
+	bridge := &memBridge{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var conns []*websocket.Conn
+	var gws []*Gateway
+	for range 2 {
+		gw, err := New(Config{Bridge: bridge})
+		if err != nil {
+			t.Fatalf("Failed to create gateway: %v", err)
+		}
+		gw.startBridge(ctx)
+		server := httptest.NewServer(gw.routes())
+		defer server.Close()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.WriteJSON(Message{ID: "s", Type: MessageTypeSubscribe, Channel: "alerts"}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil || resp.Data["subscribed"] != true {
+			t.Fatalf("subscribe = %+v, %v", resp, err)
+		}
+		gws = append(gws, gw)
+		conns = append(conns, conn)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		bridge.mu.Lock()
+		n := len(bridge.handlers)
+		bridge.mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := gws[0].Publish("alerts", NewEventMessage("disk full", "", nil)); n != 1 {
+		t.Errorf("Publish reached %d local clients, want 1", n)
+	}
+	gws[0].Broadcast(NewEventMessage("maintenance", "", nil))
+
+	// Each client receives each message once, wherever it was sent
+	for i, conn := range conns {
+		var got []string
+		for len(got) < 2 {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			got = append(got, msg.Content)
+		}
+		if err := conn.WriteJSON(Message{ID: "p", Type: MessageTypePing}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if msg.Type == MessageTypePong {
+				break
+			}
+			got = append(got, msg.Content)
+		}
+		if strings.Join(got, ",") != "disk full,maintenance" {
+			t.Errorf("client %d received %v", i, got)
+		}
+	}
+}
+
+// stuckBridge is a Bridge whose publishes hang until canceled.
+type stuckBridge struct{}
+
+func (stuckBridge) Publish(ctx context.Context, _ []byte) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (stuckBridge) Subscribe(ctx context.Context, _ func([]byte)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBridgeDoesNotBlockBroadcast(t *testing.T) {
+	gw, err := New(Config{Bridge: stuckBridge{}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gw.startBridge(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// More than the queue holds
+		for range 2 * bridgeQueueSize {
+			gw.Broadcast(NewEventMessage("maintenance", "", nil))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Broadcast blocked on the bridge")
+	}
+}
+
+func TestProtocolVersion(t *testing.T) {
+	gw, err := New(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"?version=7", nil); err == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("dial with unknown version: err = %v, want 400", err)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL+"?version=1,7", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if v := resp.Header.Get(ProtocolVersionHeader); v != "1" {
+		t.Errorf("%s = %q, want 1", ProtocolVersionHeader, v)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	roundTrip := func(msg Message) Message {
+		t.Helper()
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return resp
+	}
+	hello := roundTrip(Message{ID: "h1", Type: MessageTypeHello, Data: map[string]interface{}{"versions": []int{1, 2}}})
+	if hello.Type != MessageTypeWelcome || hello.Data["version"] != float64(1) || hello.Data["client_id"] == "" {
+		t.Errorf("welcome = %+v", hello)
+	}
+	hello = roundTrip(Message{ID: "h2", Type: MessageTypeHello, Data: map[string]interface{}{"version": 9}})
+	versions, _ := hello.Data["versions"].([]interface{})
+	if hello.Type != MessageTypeError || len(versions) != 1 || versions[0] != float64(1) {
+		t.Errorf("hello with unknown version = %+v, want error listing versions", hello)
+	}
+
+	// The connection keeps working after a rejected hello
+	if pong := roundTrip(Message{ID: "p1", Type: MessageTypePing}); pong.Type != MessageTypePong {
+		t.Errorf("ping = %+v", pong)
+	}
+}
+
+func TestMsgpackEncoding(t *testing.T) {
+	gw, err := New(Config{Agent: &mockAgent{}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	dialer := websocket.Dialer{Subprotocols: []string{"envoy.msgpack", "envoy.json"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != "envoy.msgpack" {
+		t.Fatalf("subprotocol = %q, want envoy.msgpack", conn.Subprotocol())
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	codec := msgpackV1{}
+	read := func() *Message {
+		t.Helper()
+		kind, data, err := conn.ReadMessage()
+		if err != nil || kind != websocket.BinaryMessage {
+			t.Fatalf("read = %d, %v, want a binary frame", kind, err)
+		}
+		msg, err := codec.decode(data)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return msg
+	}
+	data, _ := codec.encode(&Message{ID: "1", Type: MessageTypeChat, Content: "hi", Timestamp: time.Now(), Metadata: Metadata{MetaLocale: "fr"}})
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := read(); msg.ID != "1" || msg.Type != MessageTypeResponse || msg.Content != "Echo: hi" || msg.Timestamp.IsZero() || msg.Metadata.Get(MetaLocale) != "fr" {
+		t.Errorf("chat response = %+v", msg)
+	}
+
+	gw.Broadcast(&Message{
+		Type:        MessageTypeEvent,
+		Content:     "upload",
+		Data:        map[string]interface{}{"count": 2, "tags": []string{"a"}},
+		Attachments: []*Attachment{{Name: "a.bin", Data: []byte{0, 1, 2}}},
+	})
+	msg := read()
+	tags, _ := msg.Data["tags"].([]interface{})
+	if msg.Content != "upload" || msg.Data["count"] != float64(2) || len(tags) != 1 || tags[0] != "a" {
+		t.Errorf("event = %+v", msg)
+	}
+	if len(msg.Attachments) != 1 || string(msg.Attachments[0].Data) != "\x00\x01\x02" || msg.Attachments[0].Size != 3 {
+		t.Errorf("attachments = %+v", msg.Attachments)
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	gw, err := New(Config{AdminToken: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+
+	do := func(method, path, body string, out interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.WriteJSON(&Message{ID: "1", Type: MessageTypeSubscribe, Channel: "deploys.*"})
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	var list struct {
+		Clients []clientInfo `json:"clients"`
+	}
+	if status := do(http.MethodGet, "/admin/clients", "", &list); status != http.StatusOK || len(list.Clients) != 1 {
+		t.Fatalf("list status = %d, clients = %+v", status, list.Clients)
+	}
+	if c := list.Clients[0]; c.Transport != "websocket" || c.Encoding != EncodingJSON || len(c.Subscriptions) != 1 {
+		t.Errorf("client = %+v", c)
+	}
+	id := list.Clients[0].ID
+
+	var counts struct {
+		Channels map[string]int `json:"channels"`
+	}
+	do(http.MethodGet, "/admin/subscriptions", "", &counts)
+	if counts.Channels["deploys.*"] != 1 {
+		t.Errorf("subscription counts = %v", counts.Channels)
+	}
+
+	var sent map[string]int
+	if status := do(http.MethodPost, "/admin/announcements", `{"content": "rolling out", "channel": "deploys.prod"}`, &sent); status != http.StatusOK || sent["clients"] != 1 {
+		t.Errorf("announce status = %d, response = %v", status, sent)
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg.Content != EventAnnouncement || msg.Data["message"] != "rolling out" {
+		t.Errorf("announcement = %+v, %v", msg, err)
+	}
+
+	var status MaintenanceStatus
+	do(http.MethodPut, "/admin/maintenance", `{"enabled": true, "message": "back soon"}`, &status)
+	if !status.Enabled || status.Message != "back soon" {
+		t.Errorf("maintenance = %+v", status)
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg.Content != EventMaintenance || msg.Data["enabled"] != true {
+		t.Errorf("maintenance event = %+v, %v", msg, err)
+	}
+	_ = conn.WriteJSON(&Message{ID: "2", Type: MessageTypeChat, Content: "hi"})
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != MessageTypeError || msg.Error != "back soon" {
+		t.Errorf("chat during maintenance = %+v, %v", msg, err)
+	}
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial during maintenance: err = %v", err)
+	}
+	do(http.MethodPut, "/admin/maintenance", `{"enabled": false}`, &status)
+	if status.Enabled {
+		t.Errorf("maintenance still enabled")
+	}
+
+	if status := do(http.MethodDelete, "/admin/clients/"+id, "", nil); status != http.StatusNoContent {
+		t.Errorf("disconnect status = %d, want 204", status)
+	}
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+				t.Errorf("read after disconnect: %v", err)
+			}
+			break
+		}
+	}
+	if status := do(http.MethodDelete, "/admin/clients/"+id, "", nil); status != http.StatusNotFound {
+		t.Errorf("second disconnect status = %d, want 404", status)
+	}
+}
+
+func TestFileTransfer(t *testing.T) {
+	gw, err := New(Config{Files: FilesConfig{MaxFileSize: 10, ChunkSize: 4}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ch := NewChannel(gw)
+	received := make(chan channels.IncomingMessage, 1)
+	ch.OnMessage(func(ctx context.Context, msg channels.IncomingMessage) error {
+		received <- msg
+		return nil
+	})
+	_ = ch.Connect(context.Background())
+
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	request := func(msg Message) Message {
+		t.Helper()
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return resp
+	}
+
+	resp := request(Message{ID: "1", Type: MessageTypeUpload, Data: map[string]interface{}{"name": "a.txt", "size": 11}})
+	if resp.Type != MessageTypeError {
+		t.Errorf("oversized upload = %+v, want error", resp)
+	}
+	resp = request(Message{ID: "2", Type: MessageTypeUpload, Data: map[string]interface{}{"name": "a.txt", "mime_type": "text/plain", "size": 6}})
+	id, _ := resp.Data["file_id"].(string)
+	if id == "" || resp.Data["chunk_size"] != float64(4) {
+		t.Fatalf("upload = %+v", resp)
+	}
+	chunk := func(offset int, data string) Message {
+		t.Helper()
+		return request(Message{ID: "c", Type: MessageTypeUploadChunk, Data: map[string]interface{}{"file_id": id, "offset": offset}, Chunk: []byte(data)})
+	}
+	if resp := chunk(0, "hell"); resp.Data["received"] != float64(4) || resp.Data["complete"] != false {
+		t.Errorf("first chunk = %+v", resp)
+	}
+	if resp := chunk(6, "!"); resp.Type != MessageTypeError || resp.Data["received"] != float64(4) {
+		t.Errorf("chunk after a gap = %+v, want error", resp)
+	}
+	// Resuming reports the bytes received; overlapping chunks are accepted
+	if resp := request(Message{ID: "3", Type: MessageTypeUpload, Data: map[string]interface{}{"file_id": id}}); resp.Data["received"] != float64(4) {
+		t.Errorf("resume = %+v", resp)
+	}
+	if resp := chunk(2, "llo!"); resp.Data["received"] != float64(6) || resp.Data["complete"] != true {
+		t.Errorf("last chunk = %+v", resp)
+	}
+
+	if err := conn.WriteJSON(Message{ID: "4", Type: MessageTypeChat, Content: "see file", Data: map[string]interface{}{"files": []string{id}}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case in := <-received:
+		if len(in.Media) != 1 || string(in.Media[0].Data) != "hello!" || in.Media[0].Type != channels.MediaTypeDocument || in.Media[0].Filename != "a.txt" {
+			t.Errorf("media = %+v", in.Media)
+		}
+		if err := ch.Send(context.Background(), in.ChatID, channels.OutgoingMessage{
+			ReplyTo: in.ID,
+			Media:   []channels.Media{{Data: []byte("result"), MimeType: "image/png", Filename: "r.png"}},
+		}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("chat not routed")
+	}
+
+	var reply Message
+	if err := conn.ReadJSON(&reply); err != nil || len(reply.Attachments) != 1 {
+		t.Fatalf("reply = %+v, %v", reply, err)
+	}
+	a := reply.Attachments[0]
+	if a.ID == "" || len(a.Data) != 0 || a.Size != 6 {
+		t.Errorf("attachment = %+v, want a download ID", a)
+	}
+	var data []byte
+	for {
+		resp := request(Message{ID: "5", Type: MessageTypeDownload, Data: map[string]interface{}{"file_id": a.ID, "offset": len(data)}})
+		if resp.Type == MessageTypeError {
+			t.Fatalf("download: %s", resp.Error)
+		}
+		data = append(data, resp.Chunk...)
+		if resp.Data["eof"] == true {
+			break
+		}
+	}
+	if string(data) != "result" {
+		t.Errorf("downloaded %q, want result", data)
+	}
+
+	// Uploads are consumed by the chat message
+	resp = request(Message{ID: "6", Type: MessageTypeChat, Data: map[string]interface{}{"files": []string{id}}})
+	if resp.Type != MessageTypeError {
+		t.Errorf("reused upload = %+v, want error", resp)
+	}
+}
+
+func TestFileLimits(t *testing.T) {
+	fs := newFiles(FilesConfig{MaxFileSize: 10, MaxUploads: 2, MaxBytes: 16, TTL: time.Minute})
+
+	// Finished uploads count against the owner's limit until attached
+	a, err := fs.upload("alice", "a", "", 4)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if _, _, err := fs.write("alice", a.id, 0, []byte("done")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := fs.upload("alice", "b", "", 4); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if _, err := fs.upload("alice", "c", "", 4); err == nil {
+		t.Error("upload beyond MaxUploads accepted")
+	}
+	if _, err := fs.take("alice", a.id); err != nil {
+		t.Fatalf("take: %v", err)
+	}
+	if _, err := fs.upload("alice", "c", "", 4); err != nil {
+		t.Errorf("upload after attaching = %v", err)
+	}
+
+	// Uploads reserve their size; downloads evict older downloads
+	if _, err := fs.upload("bob", "d", "", 10); err == nil {
+		t.Error("upload beyond MaxBytes accepted")
+	}
+	first, ok := fs.add("x", "", make([]byte, 6))
+	if !ok {
+		t.Fatal("download not kept")
+	}
+	second, ok := fs.add("y", "", make([]byte, 6))
+	if !ok {
+		t.Fatal("download not kept")
+	}
+	if _, _, err := fs.read(first, 0); err == nil {
+		t.Error("oldest download not evicted")
+	}
+	if _, ok := fs.add("z", "", make([]byte, 10)); ok {
+		t.Error("download larger than the free budget kept")
+	}
+	if _, _, err := fs.read(second, 0); err != nil {
+		t.Errorf("download evicted for one that did not fit: %v", err)
+	}
+
+	// Finished uploads expire even if the client keeps asking about them
+	fs.mu.Lock()
+	for _, f := range fs.byID {
+		f.expires = time.Now().Add(-time.Second)
+	}
+	fs.mu.Unlock()
+	fs.expire(time.Now())
+	if len(fs.byID) != 0 || fs.bytes != 0 {
+		t.Errorf("files = %d, bytes = %d after expiry", len(fs.byID), fs.bytes)
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	if _, err := New(Config{PingInterval: time.Second, PongTimeout: time.Second}); err == nil {
+		t.Error("New accepted a pong timeout not exceeding the ping interval")
+	}
+	gw, err := New(Config{PingInterval: 20 * time.Millisecond, PongTimeout: 100 * time.Millisecond, IdleTimeout: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go gw.reapClients(ctx)
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	dial := func() *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	waitForClients := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for gw.ClientCount() != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("ClientCount = %d, want %d", gw.ClientCount(), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A client that does not read never answers pings
+	dial()
+	waitForClients(1)
+	waitForClients(0)
+
+	// A client answering pings but sending nothing is idle
+	conn := dial()
+	if err := conn.WriteJSON(Message{ID: "1", Type: MessageTypeSubscribe, Channel: "news"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(150 * time.Millisecond)
+	if gw.ClientCount() != 1 {
+		t.Fatalf("ClientCount = %d, want the client answering pings kept", gw.ClientCount())
+	}
+	select {
+	case err := <-closed:
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("idle close = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle client not disconnected")
+	}
+	waitForClients(0)
+	if n := gw.Publish("news", NewEventMessage("update", "", nil)); n != 0 {
+		t.Errorf("Publish reached %d clients after reaping", n)
+	}
+}
+
+func TestTypingAndPresence(t *testing.T) {
+	gw, err := New(Config{Agent: &mockAgent{}})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	dial := func(caps string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?capabilities="+caps, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		return conn
+	}
+	request := func(conn *websocket.Conn, msg Message) Message {
+		t.Helper()
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return resp
+	}
+	alice := dial("typing,presence")
+	request(alice, Message{ID: "1", Type: MessageTypeSubscribe, Channel: "room"})
+	bob := dial("presence")
+	request(bob, Message{ID: "1", Type: MessageTypeSubscribe, Channel: "room"})
+
+	// Joining, typing, and status changes reach the other subscribers
+	var msg Message
+	if err := alice.ReadJSON(&msg); err != nil || msg.Type != MessageTypePresence || msg.Channel != "room" || msg.Data["status"] != PresenceOnline {
+		t.Fatalf("join = %+v, %v", msg, err)
+	}
+	bobID := msg.Data["client_id"]
+	if err := bob.WriteJSON(Message{Type: MessageTypeTyping, Channel: "room"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := alice.ReadJSON(&msg); err != nil || msg.Type != MessageTypeTyping || msg.Data["typing"] != true || msg.Data["client_id"] != bobID {
+		t.Errorf("typing = %+v, %v", msg, err)
+	}
+	if resp := request(bob, Message{ID: "2", Type: MessageTypePresence, Data: map[string]interface{}{"status": "away"}}); resp.Data["status"] != "away" {
+		t.Errorf("set status = %+v", resp)
+	}
+	if err := alice.ReadJSON(&msg); err != nil || msg.Type != MessageTypePresence || msg.Data["status"] != "away" {
+		t.Errorf("status change = %+v, %v", msg, err)
+	}
+	resp := request(alice, Message{ID: "2", Type: MessageTypePresence, Channel: "room"})
+	if members, _ := resp.Data["members"].([]interface{}); len(members) != 2 {
+		t.Errorf("members = %+v", resp.Data)
+	}
+
+	// The agent types while processing chat messages
+	if resp := request(alice, Message{ID: "3", Type: MessageTypeChat, Content: "hi"}); resp.Type != MessageTypeTyping || resp.ID != "3" {
+		t.Errorf("agent typing = %+v", resp)
+	}
+	if err := alice.ReadJSON(&msg); err != nil || msg.Content != "Echo: hi" {
+		t.Errorf("chat = %+v, %v", msg, err)
+	}
+
+	// Clients without CapabilityTyping get router typing as an event
+	if err := NewChannel(gw).SendTyping(context.Background(), BroadcastChatID); err != nil {
+		t.Fatalf("SendTyping: %v", err)
+	}
+	if err := alice.ReadJSON(&msg); err != nil || msg.Type != MessageTypeTyping {
+		t.Errorf("router typing = %+v, %v", msg, err)
+	}
+	if err := bob.ReadJSON(&msg); err != nil || msg.Type != MessageTypeEvent || msg.Content != EventTyping {
+		t.Errorf("legacy router typing = %+v, %v", msg, err)
+	}
+
+	// Leaving announces the client offline
+	bob.Close()
+	if err := alice.ReadJSON(&msg); err != nil || msg.Type != MessageTypePresence || msg.Data["status"] != PresenceOffline || msg.Data["client_id"] != bobID {
+		t.Errorf("leave = %+v, %v", msg, err)
+	}
+}
+
+// hungAgent never answers, ignoring its context.
+type hungAgent struct {
+	release chan struct{}
+}
+
+func (m *hungAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	<-m.release
+	return "too late", nil
+}
+
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	for name, agent := range map[string]AgentProcessor{
+		"hung": &hungAgent{release: release},
+	} {
+		t.Run(name, func(t *testing.T) {
+			gw, err := New(Config{Agent: agent, RequestTimeout: 50 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("Failed to create gateway: %v", err)
+			}
+			server := httptest.NewServer(gw.routes())
+			defer server.Close()
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+			if err := conn.WriteJSON(Message{ID: "1", Type: MessageTypeChat, Content: "hi"}); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			var msg Message
+			for msg.Type != MessageTypeError {
+				if err := conn.ReadJSON(&msg); err != nil {
+					t.Fatalf("read: %v", err)
+				}
+				if msg.Type == MessageTypeResponse {
+					t.Fatalf("response after timeout: %+v", msg)
+				}
+			}
+			if msg.ID != "1" || msg.Data["code"] != ErrorCodeTimeout {
+				t.Errorf("timeout error = %+v", msg)
+			}
+
+			// The client is not held by the request
+			if err := conn.WriteJSON(Message{ID: "2", Type: MessageTypePing}); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if err := conn.ReadJSON(&msg); err != nil || msg.Type != MessageTypePong {
+				t.Errorf("ping after timeout = %+v, %v", msg, err)
+			}
+
+			resp, err := http.Post(server.URL+"/v1/chat", "application/json", strings.NewReader(`{"content": "hi"}`))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusGatewayTimeout {
+				t.Errorf("REST chat status = %d, want 504", resp.StatusCode)
+			}
+		})
+	}
+
+	gw, err := New(Config{Agent: &blockingStreamingAgent{}, RequestTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?capabilities=stream", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.WriteJSON(Message{ID: "1", Type: MessageTypeChat, Content: "hi"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var msg Message
+	for msg.Type != MessageTypeStreamEnd {
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	}
+	if msg.Error == "" || msg.Data["code"] != ErrorCodeTimeout {
+		t.Errorf("stream_end = %+v", msg)
+	}
+}
+
+func TestAsyncChatLimits(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	gw, err := New(Config{Agent: &hungAgent{release: release}, RequestTimeout: 50 * time.Millisecond, MaxAsyncRequests: 1})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ctx := context.Background()
+
+	first, err := gw.PostChat(ctx, &ChatRequest{Content: "hi", Mode: ChatModeAsync})
+	if err != nil {
+		t.Fatalf("PostChat: %v", err)
+	}
+	var apiErr *APIError
+	if _, err := gw.PostChat(ctx, &ChatRequest{Content: "hi", Mode: ChatModeAsync}); !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests {
+		t.Errorf("second async request = %v, want 429", err)
+	}
+
+	// The request times out and frees its slot
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := gw.PostChat(ctx, &ChatRequest{Content: "hi", Mode: ChatModeAsync})
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("async request after the timeout = %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if resp, ok := gw.requests.get(first.RequestID, ""); !ok || resp.Status != ChatStatusFailed {
+		t.Errorf("request = %+v, want it failed by the timeout", resp)
+	}
+
+	// Pending requests are dropped at their deadline
+	requests := newChatRequests(time.Minute)
+	requests.add(ChatResponse{RequestID: "r1", Status: ChatStatusPending}, "", time.Now().Add(-time.Second))
+	requests.add(ChatResponse{RequestID: "r2", Status: ChatStatusPending}, "", time.Now().Add(time.Minute))
+	if _, ok := requests.requests["r1"]; ok {
+		t.Error("expired pending request kept")
+	}
+}
+
+// localeAgent answers in the locale of the message's metadata.
+type localeAgent struct{}
+
+func (localeAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return MetadataFromContext(ctx).Get(MetaLocale) + ": " + content, nil
+}
+
+func TestMessageMetadata(t *testing.T) {
+	gw, err := New(Config{
+		Agent: localeAgent{},
+		MetadataHook: func(ctx context.Context, client *Client, msg *Message) error {
+			if msg.Type != MessageTypeChat {
+				return nil
+			}
+			if msg.Metadata.Get(MetaClientVersion) == "" {
+				return errors.New("client_version required")
+			}
+			if msg.Metadata.Get(MetaLocale) == "" {
+				msg.Metadata[MetaLocale] = "en"
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	server := httptest.NewServer(gw.routes())
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	chat := func(meta Metadata) Message {
+		t.Helper()
+		if err := conn.WriteJSON(Message{ID: "1", Type: MessageTypeChat, Content: "hi", Metadata: meta}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return resp
+	}
+
+	if resp := chat(nil); resp.Type != MessageTypeError || resp.Error != "client_version required" {
+		t.Errorf("chat without version = %+v", resp)
+	}
+	resp := chat(Metadata{MetaClientVersion: "1.2", "experiment": "b"})
+	if resp.Content != "en: hi" || resp.Metadata.Get("experiment") != "b" || resp.Metadata.Get(MetaLocale) != "en" {
+		t.Errorf("chat = %+v", resp)
+	}
+	if resp := chat(Metadata{MetaClientVersion: "1.2", MetaLocale: "de"}); resp.Content != "de: hi" {
+		t.Errorf("chat in German = %+v", resp)
+	}
+
+	// The router sees metadata with the incoming message
+	ch := NewChannel(gw)
+	got := make(chan channels.IncomingMessage, 1)
+	ch.OnMessage(func(ctx context.Context, in channels.IncomingMessage) error {
+		got <- in
+		return nil
+	})
+	_ = ch.Connect(context.Background())
+	if err := conn.WriteJSON(Message{Type: MessageTypeChat, Content: "hi", Metadata: Metadata{MetaClientVersion: "1.2", "client_id": "spoofed"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case in := <-got:
+		if in.Metadata[MetaClientVersion] != "1.2" || in.Metadata[MetaLocale] != "en" || in.Metadata["client_id"] == "spoofed" {
+			t.Errorf("incoming metadata = %+v", in.Metadata)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("router did not receive the message")
+	}
+}
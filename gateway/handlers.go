@@ -2,7 +2,13 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/envoy/channels"
 )
 
 // DefaultMessageHandler provides a basic message handler implementation.
@@ -26,6 +32,24 @@ func (h *DefaultMessageHandler) Handle(ctx context.Context, client *Client, msg
 		return h.handleAuth(ctx, client, msg)
 	case MessageTypeSubscribe:
 		return h.handleSubscribe(ctx, client, msg)
+	case MessageTypeUnsubscribe:
+		return h.handleUnsubscribe(ctx, client, msg)
+	case MessageTypeBatch:
+		return h.handleBatch(ctx, client, msg)
+	case MessageTypeHello:
+		return h.handleHello(ctx, client, msg)
+	case MessageTypeCancel:
+		return h.handleCancel(ctx, client, msg)
+	case MessageTypeUpload:
+		return h.handleUpload(ctx, client, msg)
+	case MessageTypeUploadChunk:
+		return h.handleUploadChunk(ctx, client, msg)
+	case MessageTypeDownload:
+		return h.handleDownload(ctx, client, msg)
+	case MessageTypeTyping:
+		return h.handleTyping(ctx, client, msg)
+	case MessageTypePresence:
+		return h.handlePresence(ctx, client, msg)
 	default:
 		return NewErrorMessage(msg.ID, "unknown message type"), nil
 	}
@@ -40,7 +64,8 @@ func (h *DefaultMessageHandler) handlePing(_ context.Context, _ *Client, msg *Me
 	}, nil
 }
 
-// handleChat handles chat messages.
+// handleChat handles chat messages. Clients declaring CapabilityTyping get
+// a typing frame carrying the message ID while the agent processes it.
 func (h *DefaultMessageHandler) handleChat(ctx context.Context, client *Client, msg *Message) (*Message, error) {
 	// If no agent configured, echo the message
 	if h.gateway.agent == nil {
@@ -52,9 +77,47 @@ func (h *DefaultMessageHandler) handleChat(ctx context.Context, client *Client,
 		}, nil
 	}
 
+	if client.Supports(CapabilityTyping) {
+		client.Send(&Message{
+			ID:        msg.ID,
+			Type:      MessageTypeTyping,
+			Channel:   msg.Channel,
+			Data:      map[string]interface{}{"typing": true},
+			Timestamp: time.Now(),
+		})
+	}
+
+	if agent, ok := h.gateway.agent.(channels.StreamingAgentProcessor); ok && client.Supports(CapabilityStream) {
+		return h.startStream(ctx, agent, client, msg)
+	}
+
+	// Requests without an ID are tracked under a new one
+	id := msg.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+	ctx, cancel := h.gateway.requestContext(ctx)
+	defer cancel()
+	if err := client.streams.add(id, cancel); err != nil {
+		return NewErrorMessage(msg.ID, err.Error()), nil
+	}
+	defer client.streams.remove(id)
+
+	ctx, span := h.gateway.tracer.Start(ctx, channels.SpanAgent,
+		trace.WithAttributes(channels.AttrSession.String(client.ID)))
+
 	// Process through agent
 	// Use client ID as session ID for conversation continuity
-	response, err := h.gateway.agent.Process(ctx, client.ID, msg.Content)
+	var response string
+	err := h.gateway.await(ctx, func() error {
+		var err error
+		response, err = h.gateway.agent.Process(ctx, client.ID, msg.Content)
+		return err
+	})
+	channels.EndSpan(span, err)
+	if errors.Is(err, errRequestTimeout) {
+		return h.gateway.timeoutError(msg.ID), nil
+	}
 	if err != nil {
 		return NewErrorMessage(msg.ID, err.Error()), nil
 	}
@@ -68,45 +131,54 @@ func (h *DefaultMessageHandler) handleChat(ctx context.Context, client *Client,
 	}, nil
 }
 
-// handleAuth handles authentication messages.
-func (h *DefaultMessageHandler) handleAuth(_ context.Context, client *Client, msg *Message) (*Message, error) {
-	// TODO: Implement proper authentication
-	// For now, accept all auth requests
-	client.SetMetadata("authenticated", true)
+// handleAuth authenticates the client with the token in data.token, or in
+// the content. Without an authenticator configured, every client is
+// accepted.
+func (h *DefaultMessageHandler) handleAuth(ctx context.Context, client *Client, msg *Message) (*Message, error) {
+	principal := &Principal{}
+	if h.gateway.config.Authenticator != nil {
+		token, _ := msg.Data["token"].(string)
+		if token == "" {
+			token = msg.Content
+		}
+		p, err := h.gateway.authenticate(ctx, token)
+		if err != nil {
+			channels.LoggerFromContext(ctx).Warn("authentication failed", "error", err)
+			return NewErrorMessage(msg.ID, "authentication failed"), nil
+		}
+		principal = p
+	}
+	client.setPrincipal(principal)
 
+	data := map[string]interface{}{
+		"authenticated": true,
+		"client_id":     client.ID,
+	}
+	if principal.Subject != "" {
+		data["subject"] = principal.Subject
+	}
 	return &Message{
-		ID:   msg.ID,
-		Type: MessageTypeResponse,
-		Data: map[string]interface{}{
-			"authenticated": true,
-			"client_id":     client.ID,
-		},
+		ID:        msg.ID,
+		Type:      MessageTypeResponse,
+		Data:      data,
 		Timestamp: time.Now(),
 	}, nil
 }
 
-// handleSubscribe handles channel subscription messages.
-func (h *DefaultMessageHandler) handleSubscribe(_ context.Context, client *Client, msg *Message) (*Message, error) {
-	channel := msg.Channel
-	if channel == "" {
-		return NewErrorMessage(msg.ID, "channel required"), nil
-	}
-
-	// Store subscription in client metadata
-	subs, _ := client.GetMetadata("subscriptions")
-	subscriptions, ok := subs.([]string)
-	if !ok {
-		subscriptions = []string{}
+// handleBatch sets the client's event batching interval from
+// data.interval_ms; zero turns batching off.
+func (h *DefaultMessageHandler) handleBatch(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	ms, ok := msg.Data["interval_ms"].(float64)
+	if !ok || ms < 0 {
+		return NewErrorMessage(msg.ID, "interval_ms required"), nil
 	}
-	subscriptions = append(subscriptions, channel)
-	client.SetMetadata("subscriptions", subscriptions)
 
+	interval := client.SetBatchInterval(time.Duration(ms) * time.Millisecond)
 	return &Message{
-		ID:      msg.ID,
-		Type:    MessageTypeResponse,
-		Channel: channel,
+		ID:   msg.ID,
+		Type: MessageTypeResponse,
 		Data: map[string]interface{}{
-			"subscribed": true,
+			"interval_ms": interval.Milliseconds(),
 		},
 		Timestamp: time.Now(),
 	}, nil
@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/agentplexus/envoy/handoff"
+)
+
+// handleListHandoffs returns the chats in human mode.
+func (g *Gateway) handleListHandoffs(w http.ResponseWriter, r *http.Request) {
+	handoffs, err := g.config.Handoff.List(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, map[string]interface{}{"handoffs": handoffs})
+}
+
+// handleStartHandoff puts a chat in human mode, with an optional
+// {"reason": "..."} body.
+func (g *Gateway) handleStartHandoff(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	h, err := g.config.Handoff.Start(r.Context(), r.PathValue("channel"), r.PathValue("chat"), body.Reason, "admin")
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, h)
+}
+
+// handleReleaseHandoff returns a chat to the agent.
+func (g *Gateway) handleReleaseHandoff(w http.ResponseWriter, r *http.Request) {
+	err := g.config.Handoff.Release(r.Context(), r.PathValue("channel"), r.PathValue("chat"))
+	g.writeHandoffResult(w, err)
+}
+
+// handleHandoffReply sends an operator's {"content": "..."} message to a
+// chat in human mode.
+func (g *Gateway) handleHandoffReply(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Content == "" {
+		writeAPIError(w, http.StatusBadRequest, "content required")
+		return
+	}
+	err := g.config.Handoff.Reply(r.Context(), r.PathValue("channel"), r.PathValue("chat"), body.Content)
+	g.writeHandoffResult(w, err)
+}
+
+// writeHandoffResult answers a handoff request that returns no content.
+func (g *Gateway) writeHandoffResult(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, handoff.ErrNotFound):
+		writeAPIError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeAPIError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeAPIResponse(w, http.StatusNoContent, nil)
+	}
+}
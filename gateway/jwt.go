@@ -0,0 +1,264 @@
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultJWKSRefresh is how often a JWT authenticator refetches its JWKS.
+const DefaultJWKSRefresh = time.Hour
+
+// DefaultJWKSTimeout bounds a JWKS fetch.
+const DefaultJWKSTimeout = 10 * time.Second
+
+// jwksMinRefresh limits refetches triggered by unknown key IDs.
+const jwksMinRefresh = time.Minute
+
+// maxJWKSSize is the maximum accepted JWKS document size.
+const maxJWKSSize = 1 << 20 // 1MB
+
+// JWTConfig configures a JWT authenticator.
+type JWTConfig struct {
+	// JWKSURL is fetched for the RSA and ECDSA public keys that sign tokens
+	// (RS256, PS256, ES256, and their SHA-384 and SHA-512 variants).
+	JWKSURL string
+
+	// Secret verifies HMAC-signed tokens (HS256, HS384, HS512).
+	Secret []byte
+
+	// Issuer, if set, must match the "iss" claim.
+	Issuer string
+
+	// Audience, if set, must be listed in the "aud" claim.
+	Audience string
+
+	// Leeway allows for clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+
+	// RefreshInterval is how often the JWKS is refetched (default:
+	// DefaultJWKSRefresh). Tokens signed by unknown keys trigger an early
+	// refetch at most once a minute.
+	RefreshInterval time.Duration
+
+	// FetchTimeout bounds a JWKS fetch (default: DefaultJWKSTimeout).
+	FetchTimeout time.Duration
+
+	// HTTPClient fetches the JWKS (default: http.DefaultClient).
+	HTTPClient *http.Client
+}
+
+// JWT authenticates JSON Web Tokens signed with a shared secret or with
+// keys from a JWKS endpoint. Tokens must carry an "exp" claim.
+type JWT struct {
+	config JWTConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	keys     map[string]interface{}
+	fetched  time.Time
+	fetchErr error
+	// refreshing is closed when the JWKS fetch in flight completes
+	refreshing chan struct{}
+}
+
+// NewJWT creates a JWT authenticator.
+func NewJWT(config JWTConfig) (*JWT, error) {
+	if config.JWKSURL == "" && len(config.Secret) == 0 {
+		return nil, errors.New("jwt authenticator requires a JWKS URL or a secret")
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DefaultJWKSRefresh
+	}
+	if config.FetchTimeout <= 0 {
+		config.FetchTimeout = DefaultJWKSTimeout
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &JWT{config: config, now: time.Now}, nil
+}
+
+// jwtMethods lists the supported signing algorithms.
+var jwtMethods = []string{
+	"HS256", "HS384", "HS512",
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}
+
+// Authenticate verifies a token's signature and claims.
+func (j *JWT) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(j.config.Leeway),
+		jwt.WithTimeFunc(j.now),
+	}
+	if j.config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.config.Issuer))
+	}
+	if j.config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(j.config.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.NewParser(opts...).ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if len(j.config.Secret) == 0 {
+				return nil, errors.New("HMAC tokens not accepted")
+			}
+			return j.config.Secret, nil
+		default:
+			kid, _ := t.Header["kid"].(string)
+			return j.key(ctx, kid)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	p := &Principal{Method: "jwt", Claims: claims}
+	p.Subject, _ = claims.GetSubject()
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		p.ExpiresAt = exp.Time
+	}
+	return p, nil
+}
+
+// key returns the JWKS key with the given ID, refetching the JWKS when it
+// is stale or the key is unknown, but at most once a minute: until then,
+// the error of a failed fetch is returned. A token without a key ID uses
+// the only key of a single-key set.
+func (j *JWT) key(ctx context.Context, kid string) (interface{}, error) {
+	if j.config.JWKSURL == "" {
+		return nil, errors.New("only HMAC tokens accepted")
+	}
+
+	j.mu.Lock()
+	// fetched is the last fetch attempt: failed fetches also count, so an
+	// unreachable endpoint is not hammered
+	age := j.now().Sub(j.fetched)
+	key, ok := j.lookup(kid)
+	fresh := j.keys != nil && age <= j.config.RefreshInterval
+	throttled := !j.fetched.IsZero() && age <= jwksMinRefresh && j.refreshing == nil
+	if (fresh && ok) || throttled {
+		fetchErr := j.fetchErr
+		j.mu.Unlock()
+		switch {
+		case ok:
+			return key, nil
+		case fetchErr != nil:
+			return nil, fetchErr
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	// Concurrent lookups share one fetch, which outlives the request that
+	// started it
+	done := j.refreshing
+	if done == nil {
+		done = make(chan struct{})
+		j.refreshing = done
+		j.fetched = j.now()
+		go j.refresh(done)
+	}
+	j.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// A failed fetch keeps the cached keys
+	if key, ok = j.lookup(kid); ok {
+		return key, nil
+	}
+	if j.fetchErr != nil {
+		return nil, j.fetchErr
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// refresh fetches the JWKS and closes done.
+func (j *JWT) refresh(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), j.config.FetchTimeout)
+	defer cancel()
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+	}
+	j.fetchErr = err
+	j.refreshing = nil
+	j.mu.Unlock()
+	close(done)
+}
+
+// lookup finds a cached key. The caller must hold mu.
+func (j *JWT) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// fetch returns the keys of the JWKS. Keys that are not RSA or ECDSA
+// signing keys are skipped.
+func (j *JWT) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.config.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	resp, err := j.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, raw := range set.Keys {
+		// Keys are decoded one by one so an unsupported key does not
+		// reject the whole set
+		var k jose.JSONWebKey
+		if err := k.UnmarshalJSON(raw); err != nil || !k.Valid() || !k.IsPublic() {
+			continue
+		}
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys[k.KeyID] = k.Key
+		}
+	}
+	return keys, nil
+}
+
+var _ Authenticator = (*JWT)(nil)
@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/agentplexus/envoy/metrics"
+)
+
+// DefaultFrameTimeout is the time allowed to write a frame to a client.
+const DefaultFrameTimeout = 10 * time.Second
+
+// reapClients closes stale and idle WebSocket clients until ctx is done.
+// Read deadlines close most dead connections; this catches the rest and
+// enforces IdleTimeout.
+func (g *Gateway) reapClients(ctx context.Context) {
+	interval := g.config.PingInterval
+	if t := g.config.IdleTimeout; t > 0 && t/2 < interval {
+		interval = t / 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.reap(now)
+		}
+	}
+}
+
+// reap closes the WebSocket clients silent for longer than PongTimeout,
+// which may resume their sessions, and disconnects those that sent no
+// messages for longer than IdleTimeout. Closed clients are unregistered,
+// so they no longer count as connected or receive publishes.
+func (g *Gateway) reap(now time.Time) {
+	g.mu.RLock()
+	clients := make([]*Client, 0, len(g.clients))
+	for _, client := range g.clients {
+		// Event stream clients cannot send; their writes fail instead
+		if client.conn != nil {
+			clients = append(clients, client)
+		}
+	}
+	g.mu.RUnlock()
+
+	for _, client := range clients {
+		switch {
+		case now.Sub(time.Unix(0, client.lastSeen.Load())) > g.config.PongTimeout:
+			g.logger.Info("stale client closed", "id", client.ID)
+			g.countReaped("stale")
+			client.Close()
+		case g.config.IdleTimeout > 0 && now.Sub(time.Unix(0, client.lastMessage.Load())) > g.config.IdleTimeout:
+			g.countReaped("idle")
+			g.Disconnect(client.ID, "idle timeout")
+		}
+	}
+
+	// Files expire even when no client transfers any
+	g.files.expire(now)
+}
+
+// countReaped counts a client closed by the reaper.
+func (g *Gateway) countReaped(reason string) {
+	if g.config.Metrics != nil {
+		g.config.Metrics.Counter("gateway_reaped", metrics.Labels{"reason": reason}).Inc()
+	}
+}
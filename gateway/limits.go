@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/metrics"
+	"github.com/agentplexus/envoy/ratelimit"
+)
+
+// DefaultSendQueue is the number of outbound frames queued per client.
+const DefaultSendQueue = 256
+
+// SlowConsumerPolicy decides what happens when a client's outbound queue
+// is full.
+type SlowConsumerPolicy string
+
+const (
+	// SlowConsumerDrop drops frames that do not fit in the queue.
+	SlowConsumerDrop SlowConsumerPolicy = "drop"
+
+	// SlowConsumerDisconnect closes the connection of a client whose queue
+	// is full. Clients with resumable sessions can resume and receive the
+	// buffered frames.
+	SlowConsumerDisconnect SlowConsumerPolicy = "disconnect"
+)
+
+// LimitsConfig protects the gateway from clients sending too much or
+// reading too slowly.
+type LimitsConfig struct {
+	// MessageRate limits the messages each connection sends. Messages over
+	// the limit are rejected with an error frame. The zero Rate is
+	// unlimited.
+	MessageRate ratelimit.Rate
+
+	// MaxInFlight limits the streaming agent requests a client has running
+	// or queued; zero is unlimited. Other chat messages are processed one
+	// at a time per connection.
+	MaxInFlight int
+
+	// SendQueue is the number of outbound frames queued per client
+	// (default: DefaultSendQueue).
+	SendQueue int
+
+	// SlowConsumer is the policy for clients whose queue is full (default:
+	// SlowConsumerDrop).
+	SlowConsumer SlowConsumerPolicy
+}
+
+func (c LimitsConfig) withDefaults() LimitsConfig {
+	if c.SendQueue <= 0 {
+		c.SendQueue = DefaultSendQueue
+	}
+	if c.SlowConsumer == "" {
+		c.SlowConsumer = SlowConsumerDrop
+	}
+	return c
+}
+
+// newMessageLimiter returns a limiter for the message rate of connections,
+// or nil if it is unlimited.
+func newMessageLimiter(config LimitsConfig) *ratelimit.Limiter {
+	if config.MessageRate.Events <= 0 || config.MessageRate.Per <= 0 {
+		return nil
+	}
+	return ratelimit.New(ratelimit.Config{PerSender: config.MessageRate})
+}
+
+// allowMessage reports whether a client message is within the message rate.
+func (g *Gateway) allowMessage(client *Client) bool {
+	if g.limiter == nil {
+		return true
+	}
+	_, ok := g.limiter.Allow(channels.IncomingMessage{ChannelName: ChannelName, SenderID: client.ID})
+	if !ok {
+		g.countLimit("rate_limited")
+	}
+	return ok
+}
+
+// countLimit counts a client hitting a limit.
+func (g *Gateway) countLimit(limit string) {
+	if g.config.Metrics != nil {
+		g.config.Metrics.Counter("gateway_limited", metrics.Labels{"limit": limit}).Inc()
+	}
+}
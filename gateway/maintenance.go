@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultMaintenanceMessage is sent to clients turned away during
+// maintenance when no message was given.
+const DefaultMaintenanceMessage = "gateway is under maintenance"
+
+// EventMaintenance is the event sent to clients when maintenance mode is
+// turned on or off, with the "enabled" flag and "message" in its data.
+const EventMaintenance = "maintenance"
+
+// maintenance is the gateway's maintenance mode. While it is on, new
+// WebSocket and event stream connections are refused and chat messages are
+// rejected. Connected clients stay connected and keep receiving messages.
+type maintenance struct {
+	mu      sync.RWMutex
+	enabled bool
+	message string
+	since   time.Time
+}
+
+// MaintenanceStatus describes the gateway's maintenance mode.
+type MaintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// Maintenance returns the gateway's maintenance mode.
+func (g *Gateway) Maintenance() MaintenanceStatus {
+	g.maintenance.mu.RLock()
+	defer g.maintenance.mu.RUnlock()
+	if !g.maintenance.enabled {
+		return MaintenanceStatus{}
+	}
+	since := g.maintenance.since
+	return MaintenanceStatus{Enabled: true, Message: g.maintenance.message, Since: &since}
+}
+
+// SetMaintenance turns maintenance mode on or off and notifies the
+// connected clients. The message is sent to clients that are turned away.
+func (g *Gateway) SetMaintenance(enabled bool, message string) {
+	if message == "" {
+		message = DefaultMaintenanceMessage
+	}
+	g.maintenance.mu.Lock()
+	changed := g.maintenance.enabled != enabled
+	g.maintenance.enabled = enabled
+	g.maintenance.message = message
+	if changed {
+		g.maintenance.since = time.Now()
+	}
+	g.maintenance.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	g.logger.Info("maintenance mode changed", "enabled", enabled)
+	data := map[string]interface{}{"enabled": enabled}
+	if enabled {
+		data["message"] = message
+	}
+	g.broadcast(NewEventMessage(EventMaintenance, "", data))
+}
+
+// maintenanceError returns the message rejecting requests during
+// maintenance, or "" if the gateway is not in maintenance.
+func (g *Gateway) maintenanceError() string {
+	g.maintenance.mu.RLock()
+	defer g.maintenance.mu.RUnlock()
+	if !g.maintenance.enabled {
+		return ""
+	}
+	return g.maintenance.message
+}
+
+// refuseMaintenance writes a 503 response and returns true if the gateway
+// is in maintenance.
+func (g *Gateway) refuseMaintenance(w http.ResponseWriter) bool {
+	msg := g.maintenanceError()
+	if msg == "" {
+		return false
+	}
+	writeAPIError(w, http.StatusServiceUnavailable, msg)
+	return true
+}
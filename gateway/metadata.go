@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"context"
+	"maps"
+)
+
+// Well-known metadata keys.
+const (
+	MetaLocale        = "locale"
+	MetaClientVersion = "client_version"
+)
+
+// Metadata holds per-message attributes set by clients or the gateway,
+// such as the user's locale, the client version, or experiment flags. It
+// is sent in a message's "metadata" field.
+type Metadata map[string]string
+
+// Get returns the value of key, or "" if it is not set.
+func (m Metadata) Get(key string) string {
+	return m[key]
+}
+
+// Clone returns a copy of m.
+func (m Metadata) Clone() Metadata {
+	if m == nil {
+		return nil
+	}
+	return maps.Clone(m)
+}
+
+// MetadataHook reads or modifies a client message's metadata before the
+// message is handled. Returning an error rejects the message with an error
+// frame carrying the error.
+type MetadataHook func(ctx context.Context, client *Client, msg *Message) error
+
+type metadataKey struct{}
+
+// withMetadata returns a context carrying the metadata of the message
+// being handled.
+func withMetadata(ctx context.Context, m Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, m)
+}
+
+// MetadataFromContext returns the metadata of the client message being
+// handled, so agents and handlers can read it.
+func MetadataFromContext(ctx context.Context) Metadata {
+	m, _ := ctx.Value(metadataKey{}).(Metadata)
+	return m
+}
+
+// replyMetadata gives a response the metadata of the message it answers,
+// unless the handler set its own.
+func replyMetadata(msg, response *Message) {
+	if response.Metadata == nil && response.ID == msg.ID {
+		response.Metadata = msg.Metadata.Clone()
+	}
+}
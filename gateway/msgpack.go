@@ -0,0 +1,228 @@
+package gateway
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/agentplexus/envoy/internal/msgpack"
+)
+
+// msgpackV1 encodes messages as MessagePack maps in binary frames, with
+// the keys of the JSON encoding. Attachment data is sent as binary values,
+// and timestamps as MessagePack timestamps.
+type msgpackV1 struct{}
+
+func (msgpackV1) frameType() int {
+	return websocket.BinaryMessage
+}
+
+func (msgpackV1) encode(msg *Message) ([]byte, error) {
+	return appendMessage(nil, msg)
+}
+
+func (msgpackV1) decode(data []byte) (*Message, error) {
+	v, rest, err := msgpack.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("msgpack: %d bytes after message", len(rest))
+	}
+	return decodeMessage(v)
+}
+
+// appendMessage appends msg as a map, omitting empty fields.
+func appendMessage(b []byte, msg *Message) ([]byte, error) {
+	fields := []struct{ key, value string }{
+		{"id", msg.ID},
+		{"type", string(msg.Type)},
+		{"channel", msg.Channel},
+		{"content", msg.Content},
+		{"error", msg.Error},
+	}
+	n := 0
+	for _, f := range fields {
+		if f.value != "" {
+			n++
+		}
+	}
+	for _, set := range []bool{len(msg.Data) > 0, !msg.Timestamp.IsZero(), msg.Seq > 0, len(msg.Events) > 0, len(msg.Attachments) > 0, len(msg.Chunk) > 0, len(msg.Metadata) > 0} {
+		if set {
+			n++
+		}
+	}
+
+	b = msgpack.AppendMapHeader(b, n)
+	for _, f := range fields {
+		if f.value != "" {
+			b = msgpack.AppendString(msgpack.AppendString(b, f.key), f.value)
+		}
+	}
+	if len(msg.Data) > 0 {
+		var err error
+		if b, err = msgpack.Append(msgpack.AppendString(b, "data"), msg.Data); err != nil {
+			return nil, err
+		}
+	}
+	if !msg.Timestamp.IsZero() {
+		b = msgpack.AppendTime(msgpack.AppendString(b, "timestamp"), msg.Timestamp)
+	}
+	if msg.Seq > 0 {
+		b = msgpack.AppendUint(msgpack.AppendString(b, "seq"), msg.Seq)
+	}
+	if len(msg.Events) > 0 {
+		b = msgpack.AppendArrayHeader(msgpack.AppendString(b, "events"), len(msg.Events))
+		for _, e := range msg.Events {
+			var err error
+			if b, err = appendMessage(b, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(msg.Attachments) > 0 {
+		b = msgpack.AppendArrayHeader(msgpack.AppendString(b, "attachments"), len(msg.Attachments))
+		for _, a := range msg.Attachments {
+			optional := []struct{ key, value string }{{"id", a.ID}, {"url", a.URL}}
+			n := 4
+			for _, f := range optional {
+				if f.value != "" {
+					n++
+				}
+			}
+			b = msgpack.AppendMapHeader(b, n)
+			b = msgpack.AppendString(msgpack.AppendString(b, "name"), a.Name)
+			b = msgpack.AppendString(msgpack.AppendString(b, "mime_type"), a.MimeType)
+			b = msgpack.AppendInt(msgpack.AppendString(b, "size"), int64(max(a.Size, len(a.Data))))
+			b = msgpack.AppendBytes(msgpack.AppendString(b, "data"), a.Data)
+			for _, f := range optional {
+				if f.value != "" {
+					b = msgpack.AppendString(msgpack.AppendString(b, f.key), f.value)
+				}
+			}
+		}
+	}
+	if len(msg.Chunk) > 0 {
+		b = msgpack.AppendBytes(msgpack.AppendString(b, "chunk"), msg.Chunk)
+	}
+	if len(msg.Metadata) > 0 {
+		b = msgpack.AppendMapHeader(msgpack.AppendString(b, "metadata"), len(msg.Metadata))
+		for k, v := range msg.Metadata {
+			b = msgpack.AppendString(msgpack.AppendString(b, k), v)
+		}
+	}
+	return b, nil
+}
+
+// decodeMetadata converts a decoded map of strings to metadata.
+func decodeMetadata(v interface{}) (Metadata, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	meta := make(Metadata, len(m))
+	for k, value := range m {
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		meta[k] = s
+	}
+	return meta, true
+}
+
+// decodeMessage converts a decoded map to a message. Unknown keys are
+// ignored, as with JSON.
+func decodeMessage(v interface{}) (*Message, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: message is a %T, not a map", v)
+	}
+	msg := &Message{}
+	for key, value := range m {
+		var ok bool
+		switch key {
+		case "id":
+			msg.ID, ok = value.(string)
+		case "type":
+			var t string
+			t, ok = value.(string)
+			msg.Type = MessageType(t)
+		case "channel":
+			msg.Channel, ok = value.(string)
+		case "content":
+			msg.Content, ok = value.(string)
+		case "error":
+			msg.Error, ok = value.(string)
+		case "data":
+			msg.Data, ok = value.(map[string]interface{})
+		case "timestamp":
+			msg.Timestamp, ok = decodeTime(value)
+		case "seq":
+			var f float64
+			f, ok = value.(float64)
+			msg.Seq = uint64(f)
+		case "events":
+			var list []interface{}
+			if list, ok = value.([]interface{}); ok {
+				for _, e := range list {
+					event, err := decodeMessage(e)
+					if err != nil {
+						return nil, err
+					}
+					msg.Events = append(msg.Events, event)
+				}
+			}
+		case "chunk":
+			msg.Chunk, ok = value.([]byte)
+		case "metadata":
+			msg.Metadata, ok = decodeMetadata(value)
+		case "attachments":
+			var list []interface{}
+			if list, ok = value.([]interface{}); ok {
+				for _, a := range list {
+					attachment, err := decodeAttachment(a)
+					if err != nil {
+						return nil, err
+					}
+					msg.Attachments = append(msg.Attachments, attachment)
+				}
+			}
+		default:
+			ok = true
+		}
+		if !ok && value != nil {
+			return nil, fmt.Errorf("msgpack: invalid %s of type %T", key, value)
+		}
+	}
+	return msg, nil
+}
+
+func decodeAttachment(v interface{}) (*Attachment, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: attachment is a %T, not a map", v)
+	}
+	a := &Attachment{}
+	a.ID, _ = m["id"].(string)
+	a.URL, _ = m["url"].(string)
+	a.Name, _ = m["name"].(string)
+	a.MimeType, _ = m["mime_type"].(string)
+	size, _ := m["size"].(float64)
+	a.Size = int(size)
+	a.Data, _ = m["data"].([]byte)
+	return a, nil
+}
+
+// decodeTime accepts MessagePack timestamps and RFC 3339 strings.
+func decodeTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
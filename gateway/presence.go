@@ -0,0 +1,197 @@
+package gateway
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Presence statuses. Clients may set any other status with a presence
+// message, such as "away".
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+)
+
+// handleTyping relays a typing indicator, with data.typing (default true),
+// to the other subscribers of the channel in the message's channel field.
+// Only messages with an ID are answered.
+func (h *DefaultMessageHandler) handleTyping(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	if msg.Channel == "" {
+		return NewErrorMessage(msg.ID, "channel required"), nil
+	}
+	if !client.Subscribed(msg.Channel) {
+		return NewErrorMessage(msg.ID, "not subscribed"), nil
+	}
+	typing, ok := msg.Data["typing"].(bool)
+	if !ok {
+		typing = true
+	}
+
+	data := client.participant()
+	data["typing"] = typing
+	h.gateway.publishFrom(client, msg.Channel, &Message{
+		Type:      MessageTypeTyping,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+	if msg.ID == "" {
+		return nil, nil
+	}
+	return &Message{
+		ID:        msg.ID,
+		Type:      MessageTypeResponse,
+		Channel:   msg.Channel,
+		Data:      map[string]interface{}{"typing": typing},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// handlePresence sets the client's status to data.status, announcing it on
+// the channels the client subscribed to, or returns the members present on
+// the channel in the message's channel field.
+func (h *DefaultMessageHandler) handlePresence(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	if status, _ := msg.Data["status"].(string); status != "" {
+		if status == PresenceOffline {
+			return NewErrorMessage(msg.ID, "invalid status"), nil
+		}
+		client.setStatus(status)
+		h.gateway.announcePresence(client, client.presenceChannels(), status)
+		return &Message{
+			ID:        msg.ID,
+			Type:      MessageTypeResponse,
+			Data:      map[string]interface{}{"status": status},
+			Timestamp: time.Now(),
+		}, nil
+	}
+
+	if msg.Channel == "" {
+		return NewErrorMessage(msg.ID, "channel or status required"), nil
+	}
+	if !client.Subscribed(msg.Channel) {
+		return NewErrorMessage(msg.ID, "not subscribed"), nil
+	}
+	return &Message{
+		ID:      msg.ID,
+		Type:    MessageTypeResponse,
+		Channel: msg.Channel,
+		Data: map[string]interface{}{
+			"members": h.gateway.members(msg.Channel),
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// members returns the connected clients on this instance subscribed to
+// channel by name, ordered by client ID.
+func (g *Gateway) members(channel string) []map[string]interface{} {
+	g.mu.RLock()
+	clients := make([]*Client, 0, len(g.clients))
+	for _, client := range g.clients {
+		clients = append(clients, client)
+	}
+	g.mu.RUnlock()
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+
+	members := []map[string]interface{}{}
+	for _, client := range clients {
+		if client.present(channel) {
+			member := client.participant()
+			member["status"] = client.Status()
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// announcePresence publishes the client's status on channels to their
+// other subscribers.
+func (g *Gateway) announcePresence(client *Client, channels []string, status string) {
+	for _, channel := range channels {
+		data := client.participant()
+		data["status"] = status
+		g.publishFrom(client, channel, &Message{
+			Type:      MessageTypePresence,
+			Data:      data,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// publishFrom publishes a message on a channel to every subscriber but the
+// sender.
+func (g *Gateway) publishFrom(sender *Client, channel string, msg *Message) {
+	msg.Channel = channel
+	for _, client := range g.recipients() {
+		if client.ID != sender.ID && client.Subscribed(channel) {
+			client.Send(msg)
+		}
+	}
+	g.share(channel, msg)
+}
+
+// adapt returns the frame to send to the client for msg. Clients that did
+// not declare CapabilityTyping get typing frames as typing events, as sent
+// before the capability existed, and clients that did not declare
+// CapabilityPresence get no presence frames.
+func (c *Client) adapt(msg *Message) *Message {
+	switch msg.Type {
+	case MessageTypeTyping:
+		if !c.Supports(CapabilityTyping) {
+			return NewEventMessage(EventTyping, msg.Channel, msg.Data)
+		}
+	case MessageTypePresence:
+		if !c.Supports(CapabilityPresence) {
+			return nil
+		}
+	}
+	return msg
+}
+
+// participant returns the fields identifying the client in typing and
+// presence frames.
+func (c *Client) participant() map[string]interface{} {
+	data := map[string]interface{}{"client_id": c.ID}
+	if p := c.Principal(); p != nil && p.Subject != "" {
+		data["subject"] = p.Subject
+	}
+	return data
+}
+
+// Status returns the client's presence status.
+func (c *Client) Status() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.status == "" {
+		return PresenceOnline
+	}
+	return c.status
+}
+
+// setStatus sets the client's presence status.
+func (c *Client) setStatus(status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status = status
+}
+
+// presenceChannels returns the channels the client subscribed to by name.
+// Patterns with wildcards do not make a client present on the channels
+// they match.
+func (c *Client) presenceChannels() []string {
+	var channels []string
+	for _, pattern := range c.Subscriptions() {
+		if !strings.Contains(pattern, "*") {
+			channels = append(channels, pattern)
+		}
+	}
+	return channels
+}
+
+// present reports whether the client subscribed to channel by name.
+func (c *Client) present(channel string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.subs[channel]
+}
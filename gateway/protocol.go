@@ -7,16 +7,47 @@ type MessageType string
 
 const (
 	// Client -> Gateway
-	MessageTypeChat      MessageType = "chat"
-	MessageTypePing      MessageType = "ping"
-	MessageTypeAuth      MessageType = "auth"
-	MessageTypeSubscribe MessageType = "subscribe"
+	MessageTypeChat        MessageType = "chat"
+	MessageTypePing        MessageType = "ping"
+	MessageTypeAuth        MessageType = "auth"
+	MessageTypeSubscribe   MessageType = "subscribe"
+	MessageTypeUnsubscribe MessageType = "unsubscribe"
+	MessageTypeBatch       MessageType = "batch"
+	MessageTypeHello       MessageType = "hello"
+	MessageTypeCancel      MessageType = "cancel"
+
+	// File transfer, see FilesConfig
+	MessageTypeUpload      MessageType = "upload"
+	MessageTypeUploadChunk MessageType = "upload_chunk"
+	MessageTypeDownload    MessageType = "download"
+
+	// Typing indicators and presence, sent both ways: clients send them on
+	// channels they subscribed to, and receive those of other subscribers
+	// and of the agent
+	MessageTypeTyping   MessageType = "typing"
+	MessageTypePresence MessageType = "presence"
 
 	// Gateway -> Client
 	MessageTypeResponse MessageType = "response"
 	MessageTypePong     MessageType = "pong"
 	MessageTypeError    MessageType = "error"
 	MessageTypeEvent    MessageType = "event"
+	MessageTypeEvents   MessageType = "events"
+
+	// Sent only to clients declaring CapabilityAcks
+	MessageTypeAck MessageType = "ack"
+
+	// Streamed chat responses, sent to clients declaring CapabilityStream
+	MessageTypeStreamStart MessageType = "stream_start"
+	MessageTypeStreamChunk MessageType = "stream_chunk"
+	MessageTypeStreamEnd   MessageType = "stream_end"
+
+	// Reply to a hello offering protocol versions
+	MessageTypeWelcome MessageType = "welcome"
+
+	// Resumable session frame, sent first to clients declaring
+	// CapabilityResume
+	MessageTypeSession MessageType = "session"
 )
 
 // Message is the base message structure for gateway communication.
@@ -28,6 +59,38 @@ type Message struct {
 	Data      map[string]interface{} `json:"data,omitempty"`
 	Error     string                 `json:"error,omitempty"`
 	Timestamp time.Time              `json:"timestamp,omitempty"`
+
+	// Metadata holds per-message attributes. Responses carry the metadata
+	// of the message they answer unless the handler set their own.
+	Metadata Metadata `json:"metadata,omitempty"`
+
+	// Seq numbers the frames sent to clients with resumable sessions.
+	Seq uint64 `json:"seq,omitempty"`
+
+	// Events holds the batched events of an "events" frame.
+	Events []*Message `json:"events,omitempty"`
+
+	// Attachments holds files sent with the message.
+	Attachments []*Attachment `json:"attachments,omitempty"`
+
+	// Chunk carries a part of a file in upload_chunk messages and download
+	// responses.
+	Chunk []byte `json:"chunk,omitempty"`
+}
+
+// Attachment is a file sent with a message. Its data is base64 encoded in
+// the JSON frame, unless the client declared CapabilityBinary: then the
+// frame carries no data and each attachment's data follows in its own
+// binary frame, in order.
+//
+// Files kept for download have an ID and no data; remote media has a URL.
+type Attachment struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	Size     int    `json:"size"`
+	Data     []byte `json:"data,omitempty"`
+	URL      string `json:"url,omitempty"`
 }
 
 // ChatMessage represents a chat message.
@@ -0,0 +1,77 @@
+// Package redisbridge connects gateway instances through Redis pub/sub, so
+// broadcasts and channel publishes reach clients on every instance.
+package redisbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/agentplexus/envoy/gateway"
+)
+
+// Bridge publishes gateway messages on a Redis pub/sub channel.
+type Bridge struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// Config configures the Redis bridge.
+type Config struct {
+	// Client is the Redis client.
+	Client redis.UniversalClient
+
+	// Channel is the pub/sub channel shared by the instances (default:
+	// "envoy:gateway").
+	Channel string
+}
+
+// New creates a new Redis bridge.
+func New(config Config) (*Bridge, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("redis client required")
+	}
+	if config.Channel == "" {
+		config.Channel = "envoy:gateway"
+	}
+	return &Bridge{
+		client:  config.Client,
+		channel: config.Channel,
+	}, nil
+}
+
+// Publish sends a payload to all instances.
+func (b *Bridge) Publish(ctx context.Context, payload []byte) error {
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("redis publish: %w", err)
+	}
+	return nil
+}
+
+// Subscribe passes published payloads to handler until ctx is done or the
+// subscription fails.
+func (b *Bridge) Subscribe(ctx context.Context, handler func(payload []byte)) error {
+	sub := b.client.Subscribe(ctx, b.channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("redis subscribe: %w", err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			handler([]byte(msg.Payload))
+		}
+	}
+}
+
+// Ensure Bridge implements gateway.Bridge.
+var _ gateway.Bridge = (*Bridge)(nil)
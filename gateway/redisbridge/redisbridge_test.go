@@ -0,0 +1,62 @@
+package redisbridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBridge(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	if _, err := New(Config{}); err == nil {
+		t.Error("New without a client succeeded")
+	}
+	b, err := New(Config{Client: client})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Subscribe(ctx, func(payload []byte) { received <- string(payload) })
+	}()
+
+	// Publish once the subscription is in place
+	deadline := time.Now().Add(2 * time.Second)
+	for server.PubSubNumSub("envoy:gateway")["envoy:gateway"] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not established")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := b.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got != "hello" {
+			t.Errorf("received %q, want hello", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("payload not received")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Subscribe = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscribe did not return after cancel")
+	}
+}
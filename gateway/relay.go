@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// SendTarget is a chat to deliver a message to.
+type SendTarget struct {
+	Channel string `json:"channel"`
+	ChatID  string `json:"chat_id"`
+}
+
+// SendRequest is the body of POST /admin/messages.
+type SendRequest struct {
+	Targets []SendTarget `json:"targets"`
+	Content string       `json:"content"`
+
+	// Format is plain (the default), markdown, or html.
+	Format string `json:"format,omitempty"`
+
+	// IdempotencyKey deduplicates retried requests per chat.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// SendResult reports the delivery to one target.
+type SendResult struct {
+	SendTarget
+	Error string `json:"error,omitempty"`
+}
+
+// handleSend delivers a message to the requested chats. It responds 200 if
+// every delivery succeeded and 502 otherwise, with a result per target.
+func (g *Gateway) handleSend(w http.ResponseWriter, r *http.Request) {
+	var req SendRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Targets) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "targets is required")
+		return
+	}
+	for _, t := range req.Targets {
+		if t.Channel == "" || t.ChatID == "" {
+			writeAPIError(w, http.StatusBadRequest, "targets require channel and chat_id")
+			return
+		}
+	}
+	if req.Content == "" {
+		writeAPIError(w, http.StatusBadRequest, "content is required")
+		return
+	}
+	format := channels.MessageFormat(req.Format)
+	switch format {
+	case "", channels.MessageFormatPlain, channels.MessageFormatMarkdown, channels.MessageFormatHTML:
+	default:
+		writeAPIError(w, http.StatusBadRequest, "format must be plain, markdown, or html")
+		return
+	}
+
+	msg := channels.OutgoingMessage{
+		Content:        req.Content,
+		Format:         format,
+		IdempotencyKey: req.IdempotencyKey,
+	}
+	status := http.StatusOK
+	results := make([]SendResult, len(req.Targets))
+	for i, t := range req.Targets {
+		results[i].SendTarget = t
+		if err := g.config.Sender.Send(r.Context(), t.Channel, t.ChatID, msg); err != nil {
+			g.logger.Error("send failed", "channel", t.Channel, "chat", t.ChatID, "error", err)
+			results[i].Error = err.Error()
+			status = http.StatusBadGateway
+		}
+	}
+	writeAPIResponse(w, status, map[string][]SendResult{"results": results})
+}
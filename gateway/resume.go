@@ -0,0 +1,234 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultResumeTTL is how long the session of a disconnected client is kept
+// for resuming.
+const DefaultResumeTTL = 5 * time.Minute
+
+// ResumeConfig configures resumable sessions for WebSocket clients that
+// declare CapabilityResume when connecting. Frames sent to those clients
+// carry a sequence number and are kept in a buffer per session. A client
+// that reconnects with the session's token and the last sequence number it
+// received gets its client ID, subscriptions, and identity back, and the
+// buffered frames it missed, including those sent while it was
+// disconnected.
+type ResumeConfig struct {
+	// BufferSize is the number of frames kept per session. Zero disables
+	// resuming.
+	BufferSize int
+
+	// TTL is how long a disconnected session can be resumed (default:
+	// DefaultResumeTTL).
+	TTL time.Duration
+}
+
+func (c ResumeConfig) withDefaults() ResumeConfig {
+	if c.TTL <= 0 {
+		c.TTL = DefaultResumeTTL
+	}
+	return c
+}
+
+// session is the resumable state of a client. Frames sent to any client of
+// the session are numbered and buffered, then passed to the connected
+// client, if any.
+type session struct {
+	token string
+	size  int
+
+	mu       sync.Mutex
+	clientID string
+	client   *Client
+	last     *Client
+	seq      uint64
+	buffer   []*Message
+	expires  time.Time
+}
+
+// deliver numbers and buffers msg, then passes it to the connected client.
+// Frames are passed under the lock so clients receive them in order.
+func (s *session) deliver(msg *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	frame := *msg
+	frame.Seq = s.seq
+	if len(s.buffer) == s.size {
+		copy(s.buffer, s.buffer[1:])
+		s.buffer = s.buffer[:s.size-1]
+	}
+	s.buffer = append(s.buffer, &frame)
+	if s.client != nil {
+		s.client.push(&frame)
+	}
+}
+
+// attach connects client to the session, first sending it the session frame
+// and the buffered frames after lastSeq.
+func (s *session) attach(client *Client, lastSeq uint64, resumed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client.push(&Message{
+		Type: MessageTypeSession,
+		Data: map[string]interface{}{
+			"token":     s.token,
+			"client_id": s.clientID,
+			"resumed":   resumed,
+			"seq":       s.seq,
+		},
+		Timestamp: time.Now(),
+	})
+	for _, frame := range s.buffer {
+		if frame.Seq > lastSeq {
+			client.push(frame)
+		}
+	}
+	s.client = client
+	s.last = client
+}
+
+// detach disconnects client from the session, which expires after ttl.
+func (s *session) detach(client *Client, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == client {
+		s.client = nil
+		s.expires = time.Now().Add(ttl)
+	}
+}
+
+// detached returns the last client of a disconnected, unexpired session.
+func (s *session) detached(now time.Time) (*Client, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil || now.After(s.expires) {
+		return nil, false
+	}
+	return s.last, true
+}
+
+// expired reports whether the session is disconnected and past its TTL.
+func (s *session) expired(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client == nil && now.After(s.expires)
+}
+
+// sessions holds the resumable sessions by token.
+type sessions struct {
+	config ResumeConfig
+
+	mu      sync.Mutex
+	byToken map[string]*session
+}
+
+func newSessions(config ResumeConfig) *sessions {
+	return &sessions{config: config.withDefaults(), byToken: make(map[string]*session)}
+}
+
+// enabled reports whether clients can resume sessions.
+func (s *sessions) enabled() bool {
+	return s.config.BufferSize > 0
+}
+
+// start attaches client to the session named by the "session" query
+// parameter, resuming after the "last_seq" parameter, or to a new session
+// if the token is unknown or expired or the session belongs to another
+// principal. A resumed client takes over the ID, subscriptions, principal,
+// presence status, and streaming requests of the session's last client.
+func (s *sessions) start(client *Client, query url.Values) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, sess := range s.byToken {
+		if sess.expired(now) {
+			delete(s.byToken, token)
+		}
+	}
+	sess, ok := s.byToken[query.Get("session")]
+	var last *Client
+	if ok {
+		last, ok = sess.detached(now)
+	}
+	if ok && client.principal != nil && subject(last.principal) != client.principal.Subject {
+		ok = false
+	}
+	if !ok {
+		sess = &session{token: newSessionToken(), size: s.config.BufferSize, clientID: client.ID}
+		s.byToken[sess.token] = sess
+	}
+
+	var lastSeq uint64
+	if ok {
+		lastSeq, _ = strconv.ParseUint(query.Get("last_seq"), 10, 64)
+		client.ID = sess.clientID
+		client.streams = last.streams
+		last.mu.RLock()
+		for pattern := range last.subs {
+			client.subs[pattern] = true
+		}
+		client.status = last.status
+		if client.principal == nil && last.principal != nil {
+			client.principal = last.principal
+			client.metadata["authenticated"] = true
+		}
+		last.mu.RUnlock()
+	}
+	client.session = sess
+	sess.attach(client, lastSeq, ok)
+}
+
+// end removes a session, so its client cannot resume it.
+func (s *sessions) end(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byToken[sess.token] == sess {
+		delete(s.byToken, sess.token)
+	}
+}
+
+// detached returns the last client of the disconnected session of a client
+// ID, so messages for the client are buffered until it resumes.
+func (s *sessions) detached(clientID string) *Client {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.byToken {
+		if sess.clientID != clientID {
+			continue
+		}
+		if c, ok := sess.detached(now); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// detachedClients returns the last clients of the disconnected sessions.
+func (s *sessions) detachedClients() []*Client {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var clients []*Client
+	for _, sess := range s.byToken {
+		if c, ok := sess.detached(now); ok {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// newSessionToken returns a random session token.
+func newSessionToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,115 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleEvents streams messages to a Server-Sent Events client, for browsers
+// behind proxies that block WebSockets. SSE clients are registered like
+// WebSocket clients, so they receive broadcasts, but they only receive:
+// requests go through the REST API. Each message is sent as an event named
+// after its type with the JSON message as data, starting with a "ready"
+// event carrying the client ID.
+//
+// Clients subscribe with comma-separated "channels" query parameters. With
+// an Authenticator configured, the token is read from the Authorization
+// header or, since EventSource cannot set headers, the "token" query
+// parameter.
+func (g *Gateway) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if g.refuseMaintenance(w) {
+		return
+	}
+
+	var principal *Principal
+	if g.config.Authenticator != nil {
+		token := bearerToken(r)
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		p, err := g.authenticate(r.Context(), token)
+		if err != nil {
+			g.logger.Warn("event stream authentication failed", "error", err)
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		principal = p
+	}
+
+	client := newClient(nil, g)
+	client.remoteAddr = r.RemoteAddr
+	if principal != nil {
+		client.setPrincipal(principal)
+	}
+	for _, list := range r.URL.Query()["channels"] {
+		for _, channel := range strings.Split(list, ",") {
+			if channel = strings.TrimSpace(channel); channel == "" {
+				continue
+			}
+			if err := client.subscribe(channel); err != nil {
+				writeAPIError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	g.registerClient(client)
+	defer client.Close()
+	g.announcePresence(client, client.presenceChannels(), client.Status())
+
+	// The server's write timeout would end the stream, so each write gets
+	// its own deadline instead
+	write := func(frame string) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(g.config.FrameTimeout))
+		if _, err := fmt.Fprint(w, frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	ready := &Message{Type: "ready", Data: map[string]interface{}{"client_id": client.ID}, Timestamp: time.Now()}
+	if err := write(sseFrame(ready)); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(g.config.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-client.send:
+			frame := sseFrame(msg)
+			g.observeMessage("out", len(frame))
+			if err := write(frame); err != nil {
+				g.logger.Debug("event stream write error", "client", client.ID, "error", err)
+				return
+			}
+		case <-ticker.C:
+			// Comments keep proxies from closing idle streams
+			if err := write(": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// sseFrame encodes a message as a Server-Sent Event named after its type.
+func sseFrame(msg *Message) string {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		data, _ = json.Marshal(NewErrorMessage(msg.ID, "message encode error"))
+	}
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", msg.Type, data)
+}
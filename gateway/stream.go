@@ -0,0 +1,214 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// errTooManyInFlight is returned when a client exceeds
+// LimitsConfig.MaxInFlight.
+var errTooManyInFlight = errors.New("too many requests in flight")
+
+// streams tracks a client's agent requests so they can be canceled.
+// Streams run one at a time, in the order they were requested, so the
+// agent session sees whole exchanges.
+type streams struct {
+	turn chan struct{}
+
+	// max limits the running and queued requests; zero is unlimited.
+	max int
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newStreams(max int) *streams {
+	return &streams{
+		turn:    make(chan struct{}, 1),
+		max:     max,
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// add registers a request. It fails if the ID is already streaming or the
+// client has the maximum number of requests in flight.
+func (s *streams) add(id string, cancel context.CancelFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cancels[id]; ok {
+		return errors.New("request already streaming")
+	}
+	if s.max > 0 && len(s.cancels) >= s.max {
+		return errTooManyInFlight
+	}
+	s.cancels[id] = cancel
+	return nil
+}
+
+func (s *streams) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cancels, id)
+}
+
+// len returns the number of requests running or queued.
+func (s *streams) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.cancels)
+}
+
+// cancel cancels a request, reporting whether it was active.
+func (s *streams) cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel, ok := s.cancels[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// cancelAll cancels every active request.
+func (s *streams) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+}
+
+// startStream streams an agent response to a client declaring
+// CapabilityStream in the background, so the client can cancel it while
+// it runs. Requests without an ID are assigned one, announced in the
+// stream_start frame.
+func (h *DefaultMessageHandler) startStream(ctx context.Context, agent channels.StreamingAgentProcessor, client *Client, msg *Message) (*Message, error) {
+	id := msg.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+	ctx, cancel := h.gateway.requestContext(ctx)
+	if err := client.streams.add(id, cancel); err != nil {
+		cancel()
+		if errors.Is(err, errTooManyInFlight) {
+			h.gateway.countLimit("in_flight")
+		}
+		return NewErrorMessage(msg.ID, err.Error()), nil
+	}
+
+	go func() {
+		defer cancel()
+
+		ctx, span := h.gateway.tracer.Start(ctx, channels.SpanAgent, trace.WithAttributes(
+			channels.AttrSession.String(client.ID),
+			channels.AttrStreaming.Bool(true),
+		))
+		end := &Message{ID: id, Type: MessageTypeStreamEnd, Channel: msg.Channel, Metadata: msg.Metadata.Clone()}
+		err := h.runStream(ctx, agent, client, id, msg, end)
+		channels.EndSpan(span, err)
+
+		// Done before stream_end, so the request cannot be canceled after
+		// the client learns it ended
+		client.streams.remove(id)
+		end.Timestamp = time.Now()
+		client.Send(end)
+	}()
+	return nil, nil
+}
+
+// runStream waits for the client's earlier streams to finish, then sends
+// stream_start and a stream_chunk per chunk. It records in end, the
+// stream_end frame, whether the request was canceled and the error if it
+// failed or timed out. The timeout includes the wait.
+func (h *DefaultMessageHandler) runStream(ctx context.Context, agent channels.StreamingAgentProcessor, client *Client, id string, msg *Message, end *Message) error {
+	select {
+	case client.streams.turn <- struct{}{}:
+		defer func() { <-client.streams.turn }()
+	case <-ctx.Done():
+		return h.gateway.stopStream(ctx, end)
+	}
+
+	client.Send(&Message{ID: id, Type: MessageTypeStreamStart, Channel: msg.Channel, Timestamp: time.Now()})
+	var chunks <-chan channels.Chunk
+	err := h.gateway.await(ctx, func() error {
+		var err error
+		chunks, err = agent.ProcessStream(ctx, client.ID, msg.Content)
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return h.gateway.stopStream(ctx, end)
+		}
+		end.Error = err.Error()
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Let the agent finish sending without blocking it
+			go func() {
+				for range chunks {
+				}
+			}()
+			return h.gateway.stopStream(ctx, end)
+		case chunk, ok := <-chunks:
+			if !ok {
+				return nil
+			}
+			if chunk.Err != nil {
+				if ctx.Err() != nil {
+					return h.gateway.stopStream(ctx, end)
+				}
+				end.Error = chunk.Err.Error()
+				return chunk.Err
+			}
+			if chunk.Content == "" {
+				continue
+			}
+			client.Send(&Message{
+				ID:        id,
+				Type:      MessageTypeStreamChunk,
+				Content:   chunk.Content,
+				Channel:   msg.Channel,
+				Timestamp: time.Now(),
+			})
+		}
+	}
+}
+
+// stopStream records in end, the stream_end frame, why the stream stopped
+// once ctx is done: a timeout is an error, other stops are cancellations.
+func (g *Gateway) stopStream(ctx context.Context, end *Message) error {
+	if !timedOut(ctx) {
+		end.Data = map[string]interface{}{"canceled": true}
+		return nil
+	}
+	g.countTimeout()
+	end.Error = errRequestTimeout.Error()
+	end.Data = map[string]interface{}{"code": ErrorCodeTimeout}
+	return errRequestTimeout
+}
+
+// handleCancel cancels the client's streaming request with the message's
+// ID.
+func (h *DefaultMessageHandler) handleCancel(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	if msg.ID == "" || !client.streams.cancel(msg.ID) {
+		return NewErrorMessage(msg.ID, "no active request"), nil
+	}
+	return &Message{
+		ID:   msg.ID,
+		Type: MessageTypeResponse,
+		Data: map[string]interface{}{
+			"canceled": true,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
@@ -0,0 +1,166 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxSubscriptions is the number of channel patterns a client may subscribe
+// to.
+const MaxSubscriptions = 100
+
+// errTooManySubscriptions is returned when a client exceeds
+// MaxSubscriptions.
+var errTooManySubscriptions = errors.New("too many subscriptions")
+
+// MatchChannel reports whether a channel matches a subscription pattern.
+// A '*' in the pattern matches any run of characters, so "deploys.*"
+// matches "deploys.prod" and "*" matches every channel.
+func MatchChannel(pattern, channel string) bool {
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return pattern == channel
+	}
+	if !strings.HasPrefix(channel, pattern[:star]) {
+		return false
+	}
+	channel, pattern = channel[star:], pattern[star+1:]
+	for {
+		star = strings.IndexByte(pattern, '*')
+		if star < 0 {
+			return strings.HasSuffix(channel, pattern)
+		}
+		// Match each literal between stars at its leftmost position
+		i := strings.Index(channel, pattern[:star])
+		if i < 0 {
+			return false
+		}
+		channel, pattern = channel[i+star:], pattern[star+1:]
+	}
+}
+
+// subscribe adds a channel pattern to the client's subscriptions.
+func (c *Client) subscribe(pattern string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.subs[pattern] && len(c.subs) >= MaxSubscriptions {
+		return errTooManySubscriptions
+	}
+	c.subs[pattern] = true
+	return nil
+}
+
+// unsubscribe removes a channel pattern, reporting whether the client was
+// subscribed to it.
+func (c *Client) unsubscribe(pattern string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ok := c.subs[pattern]
+	delete(c.subs, pattern)
+	return ok
+}
+
+// Subscriptions returns the client's channel patterns in sorted order.
+func (c *Client) Subscriptions() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	patterns := make([]string, 0, len(c.subs))
+	for p := range c.subs {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// Subscribed reports whether the client subscribed to a pattern matching
+// channel.
+func (c *Client) Subscribed(channel string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for p := range c.subs {
+		if MatchChannel(p, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish sends a message on a channel to the clients subscribed to it,
+// including disconnected clients with resumable sessions, and returns how
+// many it reached. The message's Channel is set to channel. With a Bridge,
+// the message is also published on the other instances, whose clients are
+// not counted.
+func (g *Gateway) Publish(channel string, msg *Message) int {
+	msg.Channel = channel
+	n := g.publish(channel, msg)
+	g.share(channel, msg)
+	return n
+}
+
+// publish sends a message to the subscribers of a channel on this
+// instance.
+func (g *Gateway) publish(channel string, msg *Message) int {
+	n := 0
+	for _, client := range g.recipients() {
+		if client.Subscribed(channel) {
+			client.Send(msg)
+			n++
+		}
+	}
+	return n
+}
+
+// handleSubscribe subscribes the client to the channel pattern in the
+// message's channel field. Subscribing to a channel by name announces the
+// client's presence on it.
+func (h *DefaultMessageHandler) handleSubscribe(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	channel := msg.Channel
+	if channel == "" {
+		return NewErrorMessage(msg.ID, "channel required"), nil
+	}
+	joined := !client.present(channel)
+	if err := client.subscribe(channel); err != nil {
+		return NewErrorMessage(msg.ID, err.Error()), nil
+	}
+	if joined && !strings.Contains(channel, "*") {
+		h.gateway.announcePresence(client, []string{channel}, client.Status())
+	}
+
+	return &Message{
+		ID:      msg.ID,
+		Type:    MessageTypeResponse,
+		Channel: channel,
+		Data: map[string]interface{}{
+			"subscribed": true,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// handleUnsubscribe removes the channel pattern in the message's channel
+// field from the client's subscriptions.
+func (h *DefaultMessageHandler) handleUnsubscribe(_ context.Context, client *Client, msg *Message) (*Message, error) {
+	channel := msg.Channel
+	if channel == "" {
+		return NewErrorMessage(msg.ID, "channel required"), nil
+	}
+	if !client.unsubscribe(channel) {
+		return NewErrorMessage(msg.ID, "not subscribed"), nil
+	}
+	if !strings.Contains(channel, "*") {
+		h.gateway.announcePresence(client, []string{channel}, PresenceOffline)
+	}
+
+	return &Message{
+		ID:      msg.ID,
+		Type:    MessageTypeResponse,
+		Channel: channel,
+		Data: map[string]interface{}{
+			"unsubscribed": true,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultRequestTimeout bounds agent requests when Config.RequestTimeout
+// is zero.
+const DefaultRequestTimeout = 5 * time.Minute
+
+// ErrorCodeTimeout is the data.code of error frames, and stream_end
+// frames, answering requests the agent did not complete within
+// Config.RequestTimeout.
+const ErrorCodeTimeout = "timeout"
+
+// errRequestTimeout is the cause of the contexts of timed out requests.
+var errRequestTimeout = errors.New("request timed out")
+
+// requestContext returns the context of an agent request, canceled with
+// errRequestTimeout once RequestTimeout has elapsed.
+func (g *Gateway) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, g.config.RequestTimeout, errRequestTimeout)
+}
+
+// await runs fn and returns its error, or errRequestTimeout as soon as ctx
+// times out, so an agent ignoring its context cannot hold the client.
+func (g *Gateway) await(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		if err != nil && timedOut(ctx) {
+			return errRequestTimeout
+		}
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// timedOut reports whether ctx was canceled by its request timeout.
+func timedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRequestTimeout)
+}
+
+// timeoutError counts a timed out request and returns the error frame
+// answering it.
+func (g *Gateway) timeoutError(id string) *Message {
+	g.countTimeout()
+	msg := NewErrorMessage(id, errRequestTimeout.Error())
+	msg.Data = map[string]interface{}{"code": ErrorCodeTimeout}
+	return msg
+}
+
+// countTimeout counts an agent request that timed out.
+func (g *Gateway) countTimeout() {
+	if g.config.Metrics != nil {
+		g.config.Metrics.Counter("gateway_request_timeouts", nil).Inc()
+	}
+}
@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// TracerName is the instrumentation name of the gateway's OpenTelemetry
+// spans.
+const TracerName = "github.com/agentplexus/envoy/gateway"
+
+// SpanMessage is the span covering the handling of a client message.
+// Chat messages processed by the agent get a channels.SpanAgent child span.
+const SpanMessage = "envoy.gateway.message"
+
+// Span attribute keys.
+const (
+	AttrClient      = attribute.Key("envoy.gateway.client")
+	AttrMessageType = attribute.Key("envoy.gateway.message.type")
+)
+
+// newTracer returns the tracer for tp, or for the global provider if nil.
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(TracerName)
+}
+
+// messageContext starts the span for a client message and scopes ctx to it,
+// with a trace ID and a logger carrying it for downstream handlers.
+func (c *Client) messageContext(ctx context.Context, msg *Message) (context.Context, trace.Span) {
+	ctx, span := c.gateway.tracer.Start(ctx, SpanMessage,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			AttrClient.String(c.ID),
+			AttrMessageType.String(string(msg.Type)),
+		))
+
+	traceID := channels.NewTraceID()
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		traceID = sc.TraceID().String()
+	}
+	ctx = channels.ContextWithTraceID(ctx, traceID)
+	if msg.Metadata != nil {
+		ctx = withMetadata(ctx, msg.Metadata)
+	}
+	logger := c.gateway.logger.With("client", c.ID, channels.LogKeyTraceID, traceID)
+	if p := c.Principal(); p != nil {
+		ctx = withPrincipal(ctx, p)
+		logger = logger.With("principal", p.Subject)
+	}
+	return channels.ContextWithLogger(ctx, logger), span
+}
@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion is the latest version of the wire protocol.
+const ProtocolVersion = 1
+
+// ProtocolVersionHeader carries the protocol version of a WebSocket
+// connection in the upgrade response.
+const ProtocolVersionHeader = "X-Envoy-Protocol-Version"
+
+// Wire encodings. Clients select one with the WebSocket subprotocol
+// "envoy." followed by its name; clients requesting no subprotocol use
+// JSON.
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// SubprotocolPrefix prefixes encoding names in WebSocket subprotocols.
+const SubprotocolPrefix = "envoy."
+
+// protocol encodes and decodes the frames of one protocol version in one
+// encoding.
+type protocol interface {
+	decode(data []byte) (*Message, error)
+	encode(msg *Message) ([]byte, error)
+
+	// frameType is the WebSocket message type of encoded frames.
+	frameType() int
+}
+
+// protocols holds the versions the gateway serves side by side, by version
+// and encoding. Each client keeps the version it negotiated, so a new wire
+// format is added here without breaking deployed clients. Every version
+// supports every encoding.
+var protocols = map[int]map[string]protocol{
+	1: {
+		EncodingJSON:    jsonV1{},
+		EncodingMsgpack: msgpackV1{},
+	},
+}
+
+// encodings lists the encodings in order of preference.
+var encodings = []string{EncodingMsgpack, EncodingJSON}
+
+// subprotocols returns the WebSocket subprotocols of the encodings, in
+// order of preference.
+func subprotocols() []string {
+	s := make([]string, len(encodings))
+	for i, e := range encodings {
+		s[i] = SubprotocolPrefix + e
+	}
+	return s
+}
+
+// jsonV1 encodes messages as JSON text frames.
+type jsonV1 struct{}
+
+func (jsonV1) decode(data []byte) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (jsonV1) encode(msg *Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonV1) frameType() int {
+	return websocket.TextMessage
+}
+
+// ProtocolVersions returns the protocol versions the gateway serves, in
+// ascending order.
+func ProtocolVersions() []int {
+	versions := make([]int, 0, len(protocols))
+	for v := range protocols {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// negotiateVersion returns the highest served version among those offered.
+func negotiateVersion(offered []int) (int, error) {
+	best := 0
+	for _, v := range offered {
+		if _, ok := protocols[v]; ok && v > best {
+			best = v
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("unsupported protocol version, supported versions: %s", versionList())
+	}
+	return best, nil
+}
+
+// parseVersions parses comma-separated protocol versions, skipping values
+// that are not numbers.
+func parseVersions(lists ...string) []int {
+	var versions []int
+	for _, list := range lists {
+		for _, s := range strings.Split(list, ",") {
+			if v, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+				versions = append(versions, v)
+			}
+		}
+	}
+	return versions
+}
+
+// versionList formats the served versions for error messages.
+func versionList() string {
+	versions := ProtocolVersions()
+	s := make([]string, len(versions))
+	for i, v := range versions {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// Version returns the protocol version the client negotiated.
+func (c *Client) Version() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// setVersion changes the client's protocol version.
+func (c *Client) setVersion(v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = v
+}
+
+// Encoding returns the client's wire encoding.
+func (c *Client) Encoding() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.encoding
+}
+
+// protocol returns the codec of the client's protocol version and
+// encoding.
+func (c *Client) protocol() protocol {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return protocols[c.version][c.encoding]
+}
+
+// helloVersions returns the versions offered in a hello message, in
+// data.version or data.versions.
+func helloVersions(msg *Message) ([]int, bool) {
+	var offered []int
+	if v, ok := msg.Data["version"].(float64); ok {
+		offered = append(offered, int(v))
+	}
+	list, _ := msg.Data["versions"].([]interface{})
+	for _, v := range list {
+		if v, ok := v.(float64); ok {
+			offered = append(offered, int(v))
+		}
+	}
+	_, hasVersion := msg.Data["version"]
+	_, hasVersions := msg.Data["versions"]
+	return offered, hasVersion || hasVersions
+}
+
+// unsupportedVersion returns the error frame rejecting a hello whose
+// versions the gateway does not serve.
+func unsupportedVersion(id string, err error) *Message {
+	msg := NewErrorMessage(id, err.Error())
+	msg.Data = map[string]interface{}{"versions": ProtocolVersions()}
+	return msg
+}
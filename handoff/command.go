@@ -0,0 +1,106 @@
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// CommandPrefix is the chat command with which users ask for a human.
+const CommandPrefix = "/human"
+
+// Command returns a handler for "/human [reason]", which puts the user's
+// chat in human mode. Register it with a higher priority than the agent
+// handler and Exclusive set.
+func Command(m *Manager) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		reason, ok := channels.ParseCommand(msg.Content, CommandPrefix)
+		if !ok || m.isOperators(msg) {
+			return nil
+		}
+		_, err := m.Start(ctx, msg.ChannelName, msg.ChatID, reason, sender(msg))
+		return err
+	}
+}
+
+// operatorUsage is the reply to unknown operator commands.
+const operatorUsage = "Commands: /handoffs, /takeover <chat> [reason], /reply <chat> <message>, /release <chat>"
+
+// OperatorCommand returns a handler for the commands of the operators'
+// chat, where chats are named "<channel>:<chat>":
+//
+//	/handoffs                  list the chats in human mode
+//	/takeover <chat> [reason]  put a chat in human mode
+//	/reply <chat> <message>    answer a chat in human mode
+//	/release <chat>            return a chat to the agent
+//
+// Route the operators' chat to it with Exclusive set; other messages there
+// are ignored.
+func OperatorCommand(m *Manager) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		if !m.isOperators(msg) || !strings.HasPrefix(msg.Content, "/") {
+			return nil
+		}
+		reply, err := m.operatorCommand(ctx, msg)
+		if err != nil || reply == "" {
+			return err
+		}
+		return m.toOperators(ctx, reply)
+	}
+}
+
+// operatorCommand runs an operator command and returns the reply, if any.
+func (m *Manager) operatorCommand(ctx context.Context, msg channels.IncomingMessage) (string, error) {
+	if _, ok := channels.ParseCommand(msg.Content, "/handoffs"); ok {
+		handoffs, err := m.List(ctx)
+		if err != nil {
+			return "", err
+		}
+		if len(handoffs) == 0 {
+			return "No chats in human mode.", nil
+		}
+		lines := make([]string, 0, len(handoffs))
+		for _, h := range handoffs {
+			line := h.Key() + " since " + h.StartedAt.Format("15:04")
+			if h.Reason != "" {
+				line += ": " + h.Reason
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	for _, name := range []string{"/takeover", "/reply", "/release"} {
+		args, ok := channels.ParseCommand(msg.Content, name)
+		if !ok {
+			continue
+		}
+		ref, rest, _ := strings.Cut(args, " ")
+		channelName, chatID, ok := strings.Cut(ref, ":")
+		if !ok || channelName == "" || chatID == "" {
+			return fmt.Sprintf("Usage: %s <channel>:<chat>", name), nil
+		}
+		rest = strings.TrimSpace(rest)
+
+		var err error
+		switch name {
+		case "/takeover":
+			_, err = m.Start(ctx, channelName, chatID, rest, sender(msg))
+		case "/reply":
+			if rest == "" {
+				return "Usage: /reply <channel>:<chat> <message>", nil
+			}
+			err = m.Reply(ctx, channelName, chatID, rest)
+		case "/release":
+			err = m.Release(ctx, channelName, chatID)
+		}
+		if errors.Is(err, ErrNotFound) {
+			return ref + " is not in human mode.", nil
+		}
+		return "", err
+	}
+	return operatorUsage, nil
+}
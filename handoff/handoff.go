@@ -0,0 +1,308 @@
+// Package handoff hands conversations over from the agent to human
+// operators.
+//
+// A chat in human mode bypasses the agent: its messages are forwarded to
+// the operators' chat, such as a Slack channel, or to the gateway clients
+// subscribed to a support queue ({Channel: "gateway", ChatID: "#support"}),
+// and operators answer with commands there until they release the chat.
+// Handoffs start with the /human command, an operator's /takeover, Start,
+// or the gateway admin API.
+//
+//	handoffs, err := handoff.New(handoff.Config{
+//		Operators: handoff.Target{Channel: "slack", ChatID: "C0123"},
+//		Sender:    router,
+//		History:   messageStore,
+//	})
+//	router.Use(handoffs.Middleware())
+//	router.AddHandler(channels.RouteHandler{
+//		Pattern:   channels.RoutePattern{Prefix: handoff.CommandPrefix},
+//		Handler:   handoff.Command(handoffs),
+//		Priority:  100,
+//		Exclusive: true,
+//	})
+//	router.AddHandler(channels.RouteHandler{
+//		Pattern:   channels.RoutePattern{Channels: []string{"slack"}, Chats: []string{"C0123"}},
+//		Handler:   handoff.OperatorCommand(handoffs),
+//		Priority:  100,
+//		Exclusive: true,
+//	})
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/history"
+	"github.com/agentplexus/envoy/store"
+)
+
+// DefaultHistoryLimit is the number of recent messages sent to operators
+// when a handoff starts.
+const DefaultHistoryLimit = 10
+
+// Default messages sent to users.
+const (
+	DefaultStartMessage   = "You're being connected to a human operator. They'll reply here shortly."
+	DefaultReleaseMessage = "The operator has closed the conversation. You're back with the assistant."
+)
+
+// ErrNotFound is returned for chats that are not in human mode.
+var ErrNotFound = errors.New("chat not in human mode")
+
+// Handoff is a chat in human mode.
+type Handoff struct {
+	ChannelName string `json:"channel"`
+	ChatID      string `json:"chat_id"`
+
+	// Reason says why the handoff started, shown to operators.
+	Reason string `json:"reason,omitempty"`
+
+	// StartedBy is the user or operator who started the handoff.
+	StartedBy string    `json:"started_by,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Key returns the chat's key, "<channel>:<chat>", used in operator
+// commands.
+func (h Handoff) Key() string {
+	return channels.SessionID(h.ChannelName, h.ChatID)
+}
+
+// Store persists handoffs by chat key.
+type Store interface {
+	// Load returns the handoff of a chat, or ErrNotFound.
+	Load(ctx context.Context, chat string) (*Handoff, error)
+
+	// Save creates or replaces a handoff.
+	Save(ctx context.Context, h *Handoff) error
+
+	// Delete removes a handoff. Deleting a missing handoff is not an error.
+	Delete(ctx context.Context, chat string) error
+
+	// List returns all handoffs, oldest first.
+	List(ctx context.Context) ([]Handoff, error)
+}
+
+// Target is a chat messages are sent to.
+type Target struct {
+	Channel string
+	ChatID  string
+}
+
+// Config configures a Manager.
+type Config struct {
+	// Operators is the chat operators work in. Messages of chats in human
+	// mode are forwarded there.
+	Operators Target
+
+	// Sender delivers messages to users and operators.
+	Sender channels.Sender
+
+	// Store persists handoffs (default: in-memory).
+	Store Store
+
+	// History, if set, supplies the recent messages of a chat sent to
+	// operators when its handoff starts.
+	History store.Lister
+
+	// HistoryLimit is how many recent messages are sent (default:
+	// DefaultHistoryLimit).
+	HistoryLimit int
+
+	// StartMessage and ReleaseMessage are sent to users when a handoff
+	// starts and ends (defaults: DefaultStartMessage and
+	// DefaultReleaseMessage).
+	StartMessage   string
+	ReleaseMessage string
+
+	Logger *slog.Logger
+}
+
+func (c Config) withDefaults() Config {
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+	if c.HistoryLimit <= 0 {
+		c.HistoryLimit = DefaultHistoryLimit
+	}
+	if c.StartMessage == "" {
+		c.StartMessage = DefaultStartMessage
+	}
+	if c.ReleaseMessage == "" {
+		c.ReleaseMessage = DefaultReleaseMessage
+	}
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	return c
+}
+
+// Manager starts, ends, and relays handoffs.
+type Manager struct {
+	config Config
+	now    func() time.Time
+}
+
+// New creates a new Manager.
+func New(config Config) (*Manager, error) {
+	if config.Sender == nil {
+		return nil, fmt.Errorf("sender required")
+	}
+	if config.Operators.Channel == "" || config.Operators.ChatID == "" {
+		return nil, fmt.Errorf("operators chat required")
+	}
+	return &Manager{config: config.withDefaults(), now: time.Now}, nil
+}
+
+// Get returns the handoff of a chat, or ErrNotFound.
+func (m *Manager) Get(ctx context.Context, channelName, chatID string) (*Handoff, error) {
+	h, err := m.config.Store.Load(ctx, channels.SessionID(channelName, chatID))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("load handoff: %w", err)
+	}
+	return h, err
+}
+
+// List returns the chats in human mode, oldest first.
+func (m *Manager) List(ctx context.Context) ([]Handoff, error) {
+	handoffs, err := m.config.Store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list handoffs: %w", err)
+	}
+	return handoffs, nil
+}
+
+// Start puts a chat in human mode, telling the user and sending the
+// operators the reason and the chat's recent messages. Starting a chat
+// already in human mode does nothing.
+func (m *Manager) Start(ctx context.Context, channelName, chatID, reason, by string) (*Handoff, error) {
+	if h, err := m.Get(ctx, channelName, chatID); err == nil {
+		return h, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	h := &Handoff{
+		ChannelName: channelName,
+		ChatID:      chatID,
+		Reason:      reason,
+		StartedBy:   by,
+		StartedAt:   m.now(),
+	}
+	if err := m.config.Store.Save(ctx, h); err != nil {
+		return nil, fmt.Errorf("save handoff: %w", err)
+	}
+	m.config.Logger.Info("handoff started", "chat", h.Key(), "reason", reason)
+
+	if err := m.toOperators(ctx, m.announce(ctx, h)); err != nil {
+		return h, err
+	}
+	return h, m.config.Sender.Send(ctx, channelName, chatID, channels.OutgoingMessage{Content: m.config.StartMessage})
+}
+
+// Release returns a chat to the agent, telling the user and the operators.
+func (m *Manager) Release(ctx context.Context, channelName, chatID string) error {
+	h, err := m.Get(ctx, channelName, chatID)
+	if err != nil {
+		return err
+	}
+	if err := m.config.Store.Delete(ctx, h.Key()); err != nil {
+		return fmt.Errorf("delete handoff: %w", err)
+	}
+	m.config.Logger.Info("handoff released", "chat", h.Key())
+
+	if err := m.config.Sender.Send(ctx, channelName, chatID, channels.OutgoingMessage{Content: m.config.ReleaseMessage}); err != nil {
+		return err
+	}
+	return m.toOperators(ctx, fmt.Sprintf("Released %s to the assistant.", h.Key()))
+}
+
+// Reply sends an operator's message to a chat in human mode.
+func (m *Manager) Reply(ctx context.Context, channelName, chatID, content string) error {
+	if _, err := m.Get(ctx, channelName, chatID); err != nil {
+		return err
+	}
+	return m.config.Sender.Send(ctx, channelName, chatID, channels.OutgoingMessage{Content: content})
+}
+
+// Middleware forwards the messages of chats in human mode to the
+// operators instead of passing them on to handlers. Messages from the
+// operators' chat always pass.
+func (m *Manager) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			if m.isOperators(msg) {
+				return next(ctx, msg)
+			}
+			h, err := m.Get(ctx, msg.ChannelName, msg.ChatID)
+			if errors.Is(err, ErrNotFound) {
+				return next(ctx, msg)
+			}
+			if err != nil {
+				return err
+			}
+			return m.toOperators(ctx, fmt.Sprintf("[%s] %s: %s", h.Key(), sender(msg), msg.Content))
+		}
+	}
+}
+
+// isOperators reports whether msg was sent in the operators' chat.
+func (m *Manager) isOperators(msg channels.IncomingMessage) bool {
+	return msg.ChannelName == m.config.Operators.Channel && msg.ChatID == m.config.Operators.ChatID
+}
+
+// announce returns the operators' notice of a new handoff, with the chat's
+// recent messages if History is set.
+func (m *Manager) announce(ctx context.Context, h *Handoff) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Handoff requested for %s", h.Key())
+	if h.Reason != "" {
+		fmt.Fprintf(&sb, ": %s", h.Reason)
+	}
+	fmt.Fprintf(&sb, "\nReply with /reply %s <message>, release with /release %s.", h.Key(), h.Key())
+
+	if m.config.History == nil {
+		return sb.String()
+	}
+	turns, err := history.History(ctx, m.config.History, h.Key(), m.config.HistoryLimit)
+	if err != nil {
+		m.config.Logger.Warn("handoff history unavailable", "chat", h.Key(), "error", err)
+		return sb.String()
+	}
+	if len(turns) > 0 {
+		sb.WriteString("\n\nRecent messages:")
+	}
+	for _, turn := range turns {
+		who := "assistant"
+		if turn.Direction == store.DirectionIncoming {
+			who = turn.SenderName
+			if who == "" {
+				who = turn.SenderID
+			}
+		}
+		fmt.Fprintf(&sb, "\n%s: %s", who, turn.Content)
+	}
+	return sb.String()
+}
+
+// toOperators sends content to the operators' chat.
+func (m *Manager) toOperators(ctx context.Context, content string) error {
+	target := m.config.Operators
+	if err := m.config.Sender.Send(ctx, target.Channel, target.ChatID, channels.OutgoingMessage{Content: content}); err != nil {
+		return fmt.Errorf("notify operators: %w", err)
+	}
+	return nil
+}
+
+// sender names the sender of msg for operators.
+func sender(msg channels.IncomingMessage) string {
+	if msg.SenderName != "" {
+		return msg.SenderName
+	}
+	return msg.SenderID
+}
@@ -0,0 +1,81 @@
+package handoff
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+	"github.com/agentplexus/envoy/store"
+)
+
+func TestHandoff(t *testing.T) {
+	ctx := context.Background()
+	messages := store.NewMemoryStore()
+	_ = messages.Append(ctx, store.Message{ID: "1", ChannelName: "telegram", ChatID: "42", SenderName: "Alice", Content: "my order is late", Direction: store.DirectionIncoming, Timestamp: time.Now()})
+
+	sender := &channelstest.Sender{}
+	m, err := New(Config{
+		Operators: Target{Channel: "slack", ChatID: "ops"},
+		Sender:    sender,
+		History:   messages,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	agentCalls := 0
+	handler := m.Middleware()(func(context.Context, channels.IncomingMessage) error {
+		agentCalls++
+		return nil
+	})
+	user := channels.IncomingMessage{ChannelName: "telegram", ChatID: "42", SenderName: "Alice"}
+	operator := channels.IncomingMessage{ChannelName: "slack", ChatID: "ops", SenderName: "Bob"}
+	say := func(h channels.MessageHandler, msg channels.IncomingMessage, content string) {
+		t.Helper()
+		msg.Content = content
+		if err := h(ctx, msg); err != nil {
+			t.Fatalf("%q failed: %v", content, err)
+		}
+	}
+
+	say(Command(m), user, "/human need a refund")
+	notice := sender.Last("ops")
+	if !strings.Contains(notice, "telegram:42: need a refund") || !strings.Contains(notice, "Alice: my order is late") {
+		t.Errorf("operator notice = %q", notice)
+	}
+	if got := sender.Last("42"); got != DefaultStartMessage {
+		t.Errorf("user message = %q", got)
+	}
+
+	// Messages of the chat go to the operators, not the agent
+	say(handler, user, "hello?")
+	say(handler, operator, "looking")
+	if agentCalls != 1 || sender.Last("ops") != "[telegram:42] Alice: hello?" {
+		t.Errorf("agent calls = %d, operators got %q", agentCalls, sender.Last("ops"))
+	}
+
+	say(OperatorCommand(m), operator, "/reply telegram:42 Refund issued.")
+	if got := sender.Last("42"); got != "Refund issued." {
+		t.Errorf("user got %q", got)
+	}
+	say(OperatorCommand(m), operator, "/handoffs")
+	if got := sender.Last("ops"); !strings.HasPrefix(got, "telegram:42 since") {
+		t.Errorf("handoffs = %q", got)
+	}
+
+	say(OperatorCommand(m), operator, "/release telegram:42")
+	if got := sender.Last("42"); got != DefaultReleaseMessage {
+		t.Errorf("user got %q", got)
+	}
+	say(handler, user, "thanks")
+	if agentCalls != 2 {
+		t.Errorf("agent calls = %d after release, want 2", agentCalls)
+	}
+	say(OperatorCommand(m), operator, "/reply telegram:42 hi")
+	if got := sender.Last("ops"); got != "telegram:42 is not in human mode." {
+		t.Errorf("reply to released chat = %q", got)
+	}
+}
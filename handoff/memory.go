@@ -0,0 +1,62 @@
+package handoff
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store for single-process deployments.
+type MemoryStore struct {
+	handoffs map[string]Handoff
+	mu       sync.Mutex
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{handoffs: make(map[string]Handoff)}
+}
+
+// Load returns a copy of a handoff.
+func (s *MemoryStore) Load(_ context.Context, chat string) (*Handoff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.handoffs[chat]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &h, nil
+}
+
+// Save creates or replaces a handoff.
+func (s *MemoryStore) Save(_ context.Context, h *Handoff) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handoffs[h.Key()] = *h
+	return nil
+}
+
+// Delete removes a handoff.
+func (s *MemoryStore) Delete(_ context.Context, chat string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.handoffs, chat)
+	return nil
+}
+
+// List returns all handoffs, oldest first.
+func (s *MemoryStore) List(_ context.Context) ([]Handoff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	handoffs := make([]Handoff, 0, len(s.handoffs))
+	for _, h := range s.handoffs {
+		handoffs = append(handoffs, h)
+	}
+	sort.Slice(handoffs, func(i, j int) bool {
+		return handoffs[i].StartedAt.Before(handoffs[j].StartedAt)
+	})
+	return handoffs, nil
+}
+
+// Ensure MemoryStore implements Store.
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,184 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+// DefaultContextTurns is the number of previous messages ContextAgent
+// includes in each prompt.
+const DefaultContextTurns = 20
+
+// History returns up to limit of the most recent messages in a session's
+// chat, oldest first, skipping deleted messages. Sessions are mapped to
+// their chat with channels.SplitSessionID, so per-sender sessions in a
+// group see the whole group's conversation.
+func History(ctx context.Context, l store.Lister, sessionID string, limit int) ([]store.Message, error) {
+	channelName, chatID, ok := channels.SplitSessionID(sessionID)
+	if !ok {
+		return nil, fmt.Errorf("invalid session ID %q", sessionID)
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var turns []store.Message
+	query := store.ListQuery{ChannelName: channelName, ChatID: chatID, Limit: limit, Reverse: true}
+	for len(turns) < limit {
+		page, err := l.List(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("list history: %w", err)
+		}
+		for _, m := range page.Messages {
+			if m.DeletedAt.IsZero() && len(turns) < limit {
+				turns = append(turns, m)
+			}
+		}
+		if page.Next == "" {
+			break
+		}
+		query.Cursor = page.Next
+	}
+
+	// Listed newest first
+	for i, j := 0, len(turns)-1; i < j; i, j = i+1, j-1 {
+		turns[i], turns[j] = turns[j], turns[i]
+	}
+	return turns, nil
+}
+
+// History returns up to limit of the most recent messages in a session's
+// chat, oldest first. See History.
+func (t *Transcript) History(ctx context.Context, sessionID string, limit int) ([]store.Message, error) {
+	return History(ctx, t.store, sessionID, limit)
+}
+
+// SessionHistory provides the recent messages of a session. *Transcript
+// satisfies this interface.
+type SessionHistory interface {
+	History(ctx context.Context, sessionID string, limit int) ([]store.Message, error)
+}
+
+// ContextConfig configures a ContextAgent.
+type ContextConfig struct {
+	// Agent processes the prompts with context.
+	Agent channels.AgentProcessor
+
+	// History provides the previous messages.
+	History SessionHistory
+
+	// Turns is the number of previous messages to include (default:
+	// DefaultContextTurns).
+	Turns int
+
+	// Format builds the prompt from the previous messages and the current
+	// message content (default: FormatContext).
+	Format func(turns []store.Message, content string) string
+
+	Logger *slog.Logger
+}
+
+// ContextAgent prefixes each prompt with the recent conversation, so
+// stateless LLM backends see the context assembled from the history store.
+//
+//	transcript := history.NewTranscript(s)
+//	router := channels.NewRouter(logger, channels.WithTranscript(transcript))
+//	router.SetAgent(history.NewContextAgent(history.ContextConfig{
+//		Agent:   backend,
+//		History: transcript,
+//	}))
+type ContextAgent struct {
+	config ContextConfig
+	logger *slog.Logger
+}
+
+// NewContextAgent creates a new ContextAgent.
+func NewContextAgent(config ContextConfig) *ContextAgent {
+	if config.Turns <= 0 {
+		config.Turns = DefaultContextTurns
+	}
+	if config.Format == nil {
+		config.Format = FormatContext
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &ContextAgent{config: config, logger: config.Logger}
+}
+
+// Process processes content prefixed with the session's recent messages.
+func (a *ContextAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return a.config.Agent.Process(ctx, sessionID, a.prompt(ctx, sessionID, content))
+}
+
+// ProcessStream streams the response to content prefixed with the session's
+// recent messages, falling back to Process if the agent does not stream.
+func (a *ContextAgent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error) {
+	agent, ok := a.config.Agent.(channels.StreamingAgentProcessor)
+	if !ok {
+		response, err := a.Process(ctx, sessionID, content)
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan channels.Chunk, 1)
+		out <- channels.Chunk{Content: response}
+		close(out)
+		return out, nil
+	}
+	return agent.ProcessStream(ctx, sessionID, a.prompt(ctx, sessionID, content))
+}
+
+// prompt builds the prompt for content. If history is unavailable, content
+// is used as is.
+func (a *ContextAgent) prompt(ctx context.Context, sessionID, content string) string {
+	// One extra message, as the transcript may already hold the current one
+	turns, err := a.config.History.History(ctx, sessionID, a.config.Turns+1)
+	if err != nil {
+		a.logger.Warn("conversation history unavailable", "session", sessionID, "error", err)
+		return content
+	}
+	if n := len(turns); n > 0 && turns[n-1].Direction == store.DirectionIncoming && turns[n-1].Content == content {
+		turns = turns[:n-1]
+	} else if n > a.config.Turns {
+		turns = turns[1:]
+	}
+	if len(turns) == 0 {
+		return content
+	}
+	return a.config.Format(turns, content)
+}
+
+// FormatContext formats previous messages as a transcript followed by the
+// current message:
+//
+//	Conversation so far:
+//	Alice: When is my flight?
+//	Assistant: Friday at 9:40.
+//
+//	Current message:
+//	Can I change it?
+func FormatContext(turns []store.Message, content string) string {
+	var sb strings.Builder
+	sb.WriteString("Conversation so far:\n")
+	for _, m := range turns {
+		name := "Assistant"
+		if m.Direction == store.DirectionIncoming {
+			name = m.SenderName
+			if name == "" {
+				name = "User"
+			}
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", name, m.Content)
+	}
+	sb.WriteString("\nCurrent message:\n")
+	sb.WriteString(content)
+	return sb.String()
+}
+
+// Ensure ContextAgent implements channels.StreamingAgentProcessor.
+var _ channels.StreamingAgentProcessor = (*ContextAgent)(nil)
@@ -0,0 +1,74 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+// promptAgent returns the prompt it received.
+type promptAgent struct{}
+
+func (promptAgent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	return content, nil
+}
+
+func TestContextAgent(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, m := range []store.Message{
+		{ID: "1", SenderName: "Alice", Content: "hello", Direction: store.DirectionIncoming},
+		{ID: "2", Content: "hi Alice", Direction: store.DirectionOutgoing},
+		{ID: "3", SenderName: "Alice", Content: "when is my flight?", Direction: store.DirectionIncoming},
+		{ID: "4", Content: "Friday", Direction: store.DirectionOutgoing},
+		{ID: "5", SenderName: "Alice", Content: "can I change it?", Direction: store.DirectionIncoming},
+	} {
+		m.ChannelName, m.ChatID, m.Timestamp = "telegram", "42", base.Add(time.Duration(i)*time.Minute)
+		if err := s.Append(ctx, m); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := s.Append(ctx, store.Message{ID: "x", ChannelName: "telegram", ChatID: "7", Content: "other chat", Timestamp: base}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	transcript := NewTranscript(s)
+	turns, err := transcript.History(ctx, "telegram:42:u1:abcd", 2)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(turns) != 2 || turns[0].ID != "4" || turns[1].ID != "5" {
+		t.Fatalf("turns = %+v, want messages 4 and 5", turns)
+	}
+
+	agent := NewContextAgent(ContextConfig{Agent: promptAgent{}, History: transcript, Turns: 2})
+
+	// The current message is already in the transcript and not repeated
+	prompt, err := agent.Process(ctx, "telegram:42", "can I change it?")
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	want := "Conversation so far:\nAlice: when is my flight?\nAssistant: Friday\n\nCurrent message:\ncan I change it?"
+	if prompt != want {
+		t.Errorf("prompt = %q, want %q", prompt, want)
+	}
+
+	// A message not yet recorded gets the latest turns
+	prompt, err = agent.Process(ctx, "telegram:42", "thanks")
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if !strings.HasPrefix(prompt, "Conversation so far:\nAssistant: Friday\nAlice: can I change it?\n") {
+		t.Errorf("prompt = %q", prompt)
+	}
+
+	// Without history the content is passed through
+	prompt, err = agent.Process(ctx, "discord:1", "hi")
+	if err != nil || prompt != "hi" {
+		t.Errorf("prompt = %q, %v, want %q", prompt, err, "hi")
+	}
+}
@@ -0,0 +1,120 @@
+// Package history connects the message router to conversation history stores.
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+// Recorder returns a message handler that appends every routed message to
+// the store. Register it with channels.All() to capture full transcripts.
+func Recorder(s store.MessageStore) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		return s.Append(ctx, FromIncoming(msg))
+	}
+}
+
+// FromIncoming converts an incoming channel message to a store message.
+func FromIncoming(msg channels.IncomingMessage) store.Message {
+	return store.Message{
+		ID:          msg.ID,
+		ChannelName: msg.ChannelName,
+		ChatID:      msg.ChatID,
+		SenderID:    msg.SenderID,
+		SenderName:  msg.SenderName,
+		Content:     msg.Content,
+		Direction:   store.DirectionIncoming,
+		Timestamp:   msg.Timestamp,
+		Metadata:    msg.Metadata,
+	}
+}
+
+// FromOutgoing converts a message sent to a chat to a store message. It is
+// keyed by the platform message ID from the send's receipt, so it can be
+// tombstoned or matched to edits; channels that report no ID are keyed by
+// the idempotency key, if any.
+func FromOutgoing(channelName, chatID, messageID string, msg channels.OutgoingMessage) store.Message {
+	if messageID == "" {
+		messageID = msg.IdempotencyKey
+	}
+	m := store.Message{
+		ID:          messageID,
+		ChannelName: channelName,
+		ChatID:      chatID,
+		Content:     msg.Content,
+		Direction:   store.DirectionOutgoing,
+		Timestamp:   time.Now(),
+		Metadata:    msg.Metadata,
+	}
+	if msg.ReplyTo != "" || msg.Ephemeral {
+		m.Metadata = make(map[string]interface{}, len(msg.Metadata)+2)
+		for k, v := range msg.Metadata {
+			m.Metadata[k] = v
+		}
+		if msg.ReplyTo != "" {
+			m.Metadata["reply_to"] = msg.ReplyTo
+		}
+		if msg.Ephemeral {
+			m.Metadata["recipient"] = msg.Recipient
+		}
+	}
+	return m
+}
+
+// Transcript records router traffic in a message store. Pass it to
+// channels.WithTranscript to persist every incoming and outgoing message.
+type Transcript struct {
+	store store.MessageStore
+}
+
+// NewTranscript creates a Transcript backed by s.
+func NewTranscript(s store.MessageStore) *Transcript {
+	return &Transcript{store: s}
+}
+
+// RecordIncoming appends a received message.
+func (t *Transcript) RecordIncoming(ctx context.Context, msg channels.IncomingMessage) error {
+	return t.store.Append(ctx, FromIncoming(msg))
+}
+
+// RecordOutgoing appends a sent message.
+func (t *Transcript) RecordOutgoing(ctx context.Context, channelName, chatID, messageID string, msg channels.OutgoingMessage) error {
+	return t.store.Append(ctx, FromOutgoing(channelName, chatID, messageID, msg))
+}
+
+// Ensure Transcript implements channels.Transcript.
+var _ channels.Transcript = (*Transcript)(nil)
+
+// Tombstoner returns an event handler that tombstones history entries when a
+// platform reports a message deletion. The deletion is propagated to each
+// archive as well. Register it with
+// router.OnEvent(h, channels.EventTypeMessageDeleted).
+func Tombstoner(primary store.Tombstoner, archives ...store.Tombstoner) channels.EventHandler {
+	stores := append([]store.Tombstoner{primary}, archives...)
+	return func(ctx context.Context, event channels.Event) error {
+		if event.Type != channels.EventTypeMessageDeleted {
+			return nil
+		}
+		messageID, _ := event.Data[channels.EventDataMessageID].(string)
+		if messageID == "" {
+			return nil
+		}
+		at := event.Timestamp
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		var errs []error
+		for _, s := range stores {
+			if err := s.Tombstone(ctx, event.ChannelName, event.ChatID, messageID, at); err != nil {
+				errs = append(errs, fmt.Errorf("tombstone message %s: %w", messageID, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
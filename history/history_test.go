@@ -0,0 +1,60 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+func TestTombstoner(t *testing.T) {
+	ctx := context.Background()
+	primary := store.NewMemoryStore()
+	archive := store.NewMemoryStore()
+	msg := store.Message{ID: "m1", ChannelName: "discord", ChatID: "100", Content: "delete me"}
+	for _, s := range []*store.MemoryStore{primary, archive} {
+		if err := s.Append(ctx, msg); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	handler := Tombstoner(primary, archive)
+	err := handler(ctx, channels.Event{
+		Type:        channels.EventTypeMessageDeleted,
+		ChannelName: "discord",
+		ChatID:      "100",
+		Data:        map[string]interface{}{channels.EventDataMessageID: "m1"},
+	})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	for name, s := range map[string]*store.MemoryStore{"primary": primary, "archive": archive} {
+		results, err := s.Search(ctx, store.SearchQuery{Text: "delete"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("%s still returns tombstoned message: %+v", name, results)
+		}
+	}
+}
+
+func TestFromOutgoingID(t *testing.T) {
+	tests := []struct {
+		messageID string
+		key       string
+		want      string
+	}{
+		{"m1", "k1", "m1"},
+		{"", "k1", "k1"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		got := FromOutgoing("discord", "100", tt.messageID, channels.OutgoingMessage{Content: "hi", IdempotencyKey: tt.key})
+		if got.ID != tt.want {
+			t.Errorf("FromOutgoing(%q, key %q).ID = %q, want %q", tt.messageID, tt.key, got.ID, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package history
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+// ImportOptions configures transcript imports.
+type ImportOptions struct {
+	// SelfID is the sender ID of the bot or account being migrated. Its
+	// messages are imported as outgoing; all others as incoming.
+	SelfID string
+
+	// ChannelName overrides the channel name recorded for imported
+	// messages (default: "telegram" or "discord"; for CSV, the channel
+	// column).
+	ChannelName string
+}
+
+// channel returns the channel name to record, preferring the override.
+func (o ImportOptions) channel(name string) string {
+	if o.ChannelName != "" {
+		return o.ChannelName
+	}
+	return name
+}
+
+// direction returns the direction of a message sent by senderID.
+func (o ImportOptions) direction(senderID string) store.Direction {
+	if o.SelfID != "" && senderID == o.SelfID {
+		return store.DirectionOutgoing
+	}
+	return store.DirectionIncoming
+}
+
+// telegramExport is a Telegram Desktop JSON export: either a single chat or
+// a full account export with a chat list.
+type telegramExport struct {
+	telegramChat
+	Chats struct {
+		List []telegramChat `json:"list"`
+	} `json:"chats"`
+}
+
+type telegramChat struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	ID       int64             `json:"id"`
+	Messages []telegramMessage `json:"messages"`
+}
+
+type telegramMessage struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type"`
+	DateUnixtime string          `json:"date_unixtime"`
+	Date         string          `json:"date"`
+	From         string          `json:"from"`
+	FromID       string          `json:"from_id"`
+	Text         json.RawMessage `json:"text"`
+	ReplyTo      int64           `json:"reply_to_message_id"`
+}
+
+// ImportTelegram imports a Telegram Desktop JSON export (result.json) into
+// s and returns the number of messages imported. Chat IDs are converted to
+// the Bot API IDs the Telegram adapter uses (e.g., -100 prefixed for
+// supergroups and channels), so imported history joins the live sessions.
+// Service messages are skipped.
+func ImportTelegram(ctx context.Context, s store.MessageStore, r io.Reader, opts ImportOptions) (int, error) {
+	var export telegramExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return 0, fmt.Errorf("decode telegram export: %w", err)
+	}
+
+	chats := export.Chats.List
+	if len(export.Messages) > 0 {
+		chats = append(chats, export.telegramChat)
+	}
+
+	n := 0
+	for _, chat := range chats {
+		chatID := telegramChatID(chat.Type, chat.ID)
+		for _, m := range chat.Messages {
+			if m.Type != "message" {
+				continue
+			}
+			ts, err := telegramTime(m)
+			if err != nil {
+				return n, fmt.Errorf("telegram message %d: %w", m.ID, err)
+			}
+			senderID := strings.TrimPrefix(strings.TrimPrefix(m.FromID, "user"), "channel")
+			msg := store.Message{
+				ID:          strconv.FormatInt(m.ID, 10),
+				ChannelName: opts.channel("telegram"),
+				ChatID:      chatID,
+				SenderID:    senderID,
+				SenderName:  m.From,
+				Content:     telegramText(m.Text),
+				Direction:   opts.direction(senderID),
+				Timestamp:   ts,
+				Metadata:    map[string]interface{}{"imported": "telegram"},
+			}
+			if m.ReplyTo != 0 {
+				msg.Metadata["reply_to"] = strconv.FormatInt(m.ReplyTo, 10)
+			}
+			if err := s.Append(ctx, msg); err != nil {
+				return n, fmt.Errorf("append message: %w", err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// telegramChatID converts an export chat ID to a Bot API chat ID.
+func telegramChatID(chatType string, id int64) string {
+	switch chatType {
+	case "private_group":
+		return "-" + strconv.FormatInt(id, 10)
+	case "private_supergroup", "public_supergroup", "private_channel", "public_channel":
+		return "-100" + strconv.FormatInt(id, 10)
+	default:
+		return strconv.FormatInt(id, 10)
+	}
+}
+
+// telegramTime returns a message's timestamp, preferring the unambiguous
+// Unix time over the local-time date.
+func telegramTime(m telegramMessage) (time.Time, error) {
+	if m.DateUnixtime != "" {
+		sec, err := strconv.ParseInt(m.DateUnixtime, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse date_unixtime: %w", err)
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Parse("2006-01-02T15:04:05", m.Date)
+}
+
+// telegramText flattens export text, which is either a string or a list of
+// strings and formatted entities.
+func telegramText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, part := range parts {
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(part, &text); err == nil {
+			sb.WriteString(text)
+		} else if err := json.Unmarshal(part, &entity); err == nil {
+			sb.WriteString(entity.Text)
+		}
+	}
+	return sb.String()
+}
+
+// discordExport is a DiscordChatExporter JSON export of one channel.
+type discordExport struct {
+	Guild struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"guild"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	Messages []struct {
+		ID        string    `json:"id"`
+		Type      string    `json:"type"`
+		Timestamp time.Time `json:"timestamp"`
+		Content   string    `json:"content"`
+		Author    struct {
+			ID       string `json:"id"`
+			Name     string `json:"name"`
+			Nickname string `json:"nickname"`
+		} `json:"author"`
+		Reference *struct {
+			MessageID string `json:"messageId"`
+		} `json:"reference"`
+	} `json:"messages"`
+}
+
+// ImportDiscord imports a DiscordChatExporter JSON export into s and
+// returns the number of messages imported. Messages are keyed by the
+// Discord channel ID, as the Discord adapter does. System messages other
+// than replies are skipped.
+func ImportDiscord(ctx context.Context, s store.MessageStore, r io.Reader, opts ImportOptions) (int, error) {
+	var export discordExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return 0, fmt.Errorf("decode discord export: %w", err)
+	}
+	if export.Channel.ID == "" {
+		return 0, errors.New("discord export has no channel ID")
+	}
+
+	n := 0
+	for _, m := range export.Messages {
+		if m.Type != "" && m.Type != "Default" && m.Type != "Reply" {
+			continue
+		}
+		name := m.Author.Nickname
+		if name == "" {
+			name = m.Author.Name
+		}
+		msg := store.Message{
+			ID:          m.ID,
+			ChannelName: opts.channel("discord"),
+			ChatID:      export.Channel.ID,
+			SenderID:    m.Author.ID,
+			SenderName:  name,
+			Content:     m.Content,
+			Direction:   opts.direction(m.Author.ID),
+			Timestamp:   m.Timestamp.UTC(),
+			Metadata: map[string]interface{}{
+				"imported": "discord",
+				"guild_id": export.Guild.ID,
+			},
+		}
+		if m.Reference != nil && m.Reference.MessageID != "" {
+			msg.Metadata["reply_to"] = m.Reference.MessageID
+		}
+		if err := s.Append(ctx, msg); err != nil {
+			return n, fmt.Errorf("append message: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// CSV columns read by ImportCSV. The chat_id, content, and timestamp
+// columns are required.
+const (
+	CSVColumnID         = "id"
+	CSVColumnChannel    = "channel"
+	CSVColumnChatID     = "chat_id"
+	CSVColumnSenderID   = "sender_id"
+	CSVColumnSenderName = "sender_name"
+	CSVColumnContent    = "content"
+	CSVColumnTimestamp  = "timestamp"
+	CSVColumnDirection  = "direction"
+)
+
+// ImportCSV imports messages from CSV with a header row naming the columns
+// (see the CSVColumn constants; others are ignored) and returns the number
+// of messages imported. Timestamps are RFC 3339 or Unix seconds. The
+// direction column, if present, holds "incoming" or "outgoing"; otherwise
+// it is derived from SelfID. The channel comes from ImportOptions or the
+// channel column.
+func ImportCSV(ctx context.Context, s store.MessageStore, r io.Reader, opts ImportOptions) (int, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("read csv header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{CSVColumnChatID, CSVColumnContent, CSVColumnTimestamp} {
+		if _, ok := columns[required]; !ok {
+			return 0, fmt.Errorf("csv missing %q column", required)
+		}
+	}
+	_, hasChannel := columns[CSVColumnChannel]
+	if opts.ChannelName == "" && !hasChannel {
+		return 0, fmt.Errorf("csv missing %q column and no channel name given", CSVColumnChannel)
+	}
+
+	n := 0
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("read csv line %d: %w", line, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		ts, err := parseTimestamp(field(CSVColumnTimestamp))
+		if err != nil {
+			return n, fmt.Errorf("csv line %d: %w", line, err)
+		}
+		senderID := field(CSVColumnSenderID)
+		direction := opts.direction(senderID)
+		if d := store.Direction(field(CSVColumnDirection)); d != "" {
+			direction = d
+		}
+		msg := store.Message{
+			ID:          field(CSVColumnID),
+			ChannelName: opts.channel(field(CSVColumnChannel)),
+			ChatID:      field(CSVColumnChatID),
+			SenderID:    senderID,
+			SenderName:  field(CSVColumnSenderName),
+			Content:     field(CSVColumnContent),
+			Direction:   direction,
+			Timestamp:   ts,
+			Metadata:    map[string]interface{}{"imported": "csv"},
+		}
+		if err := s.Append(ctx, msg); err != nil {
+			return n, fmt.Errorf("append message: %w", err)
+		}
+		n++
+	}
+}
+
+// parseTimestamp parses an RFC 3339 or Unix seconds timestamp.
+func parseTimestamp(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse timestamp %q: %w", s, err)
+	}
+	return ts, nil
+}
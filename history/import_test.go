@@ -0,0 +1,118 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+// allMessages returns the stored messages of a chat in order.
+func allMessages(t *testing.T, s *store.MemoryStore, chatID string) []store.Message {
+	t.Helper()
+	results, err := s.Search(context.Background(), store.SearchQuery{ChatID: chatID, Limit: 100})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	msgs := make([]store.Message, len(results))
+	for i, r := range results {
+		msgs[i] = r.Message
+	}
+	return msgs
+}
+
+func TestImportTelegram(t *testing.T) {
+	export := `{
+  "name": "Team",
+  "type": "private_supergroup",
+  "id": 1234567890,
+  "messages": [
+    {"id": 1, "type": "service", "date_unixtime": "1700000000", "action": "create_group"},
+    {"id": 2, "type": "message", "date_unixtime": "1700000060", "from": "Alice", "from_id": "user42",
+     "text": ["see ", {"type": "bold", "text": "docs"}]},
+    {"id": 3, "type": "message", "date_unixtime": "1700000120", "from": "Envoy", "from_id": "user7",
+     "text": "will do", "reply_to_message_id": 2}
+  ]
+}`
+	s := store.NewMemoryStore()
+	n, err := ImportTelegram(context.Background(), s, strings.NewReader(export), ImportOptions{SelfID: "7"})
+	if err != nil {
+		t.Fatalf("ImportTelegram failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("imported %d, want 2", n)
+	}
+
+	msgs := allMessages(t, s, "-1001234567890")
+	if len(msgs) != 2 {
+		t.Fatalf("stored %d messages in -1001234567890, want 2", len(msgs))
+	}
+	byID := map[string]store.Message{}
+	for _, m := range msgs {
+		byID[m.ID] = m
+	}
+	alice := byID["2"]
+	if alice.Content != "see docs" || alice.SenderID != "42" || alice.Direction != store.DirectionIncoming {
+		t.Errorf("message 2 = %+v", alice)
+	}
+	if !alice.Timestamp.Equal(time.Unix(1700000060, 0)) {
+		t.Errorf("timestamp = %v", alice.Timestamp)
+	}
+	if reply := byID["3"]; reply.Direction != store.DirectionOutgoing || reply.Metadata["reply_to"] != "2" {
+		t.Errorf("message 3 = %+v", reply)
+	}
+}
+
+func TestImportDiscord(t *testing.T) {
+	export := `{
+  "guild": {"id": "1", "name": "Server"},
+  "channel": {"id": "555", "name": "general"},
+  "messages": [
+    {"id": "10", "type": "Default", "timestamp": "2024-01-02T03:04:05+00:00", "content": "hello",
+     "author": {"id": "42", "name": "alice", "nickname": "Alice"}},
+    {"id": "11", "type": "ChannelPinnedMessage", "timestamp": "2024-01-02T03:05:00+00:00", "content": "",
+     "author": {"id": "42", "name": "alice"}}
+  ]
+}`
+	s := store.NewMemoryStore()
+	n, err := ImportDiscord(context.Background(), s, strings.NewReader(export), ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportDiscord failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("imported %d, want 1", n)
+	}
+	msgs := allMessages(t, s, "555")
+	if len(msgs) != 1 || msgs[0].SenderName != "Alice" || msgs[0].ChannelName != "discord" {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	data := "chat_id,sender_id,content,timestamp,direction\n" +
+		"c1,u1,hi there,2024-01-02T03:04:05Z,\n" +
+		"c1,bot,\"hello, u1\",1704164700,outgoing\n"
+	s := store.NewMemoryStore()
+	n, err := ImportCSV(context.Background(), s, strings.NewReader(data), ImportOptions{ChannelName: "slack"})
+	if err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("imported %d, want 2", n)
+	}
+	for _, m := range allMessages(t, s, "c1") {
+		if m.ChannelName != "slack" {
+			t.Errorf("channel = %q, want slack", m.ChannelName)
+		}
+		if m.SenderID == "bot" && m.Direction != store.DirectionOutgoing {
+			t.Errorf("bot message direction = %q", m.Direction)
+		}
+	}
+
+	_, err = ImportCSV(context.Background(), s, strings.NewReader("chat_id,content\n"), ImportOptions{ChannelName: "x"})
+	if err == nil {
+		t.Error("expected error for missing timestamp column")
+	}
+}
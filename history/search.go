@@ -0,0 +1,95 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+// SearchCommandPrefix is the chat command that triggers a history search.
+const SearchCommandPrefix = "/search"
+
+// maxSnippetLength is the maximum number of characters shown per result.
+const maxSnippetLength = 200
+
+// SearchCommand returns a handler for "/search <terms>" that replies with
+// matching messages from the same chat. Register it with
+// channels.RoutePattern{Prefix: history.SearchCommandPrefix}.
+func SearchCommand(searcher store.Searcher, sender channels.Sender) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		text, ok := channels.ParseCommand(msg.Content, SearchCommandPrefix)
+		if !ok {
+			return nil
+		}
+
+		reply := func(content string) error {
+			return sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+				Content: content,
+				ReplyTo: msg.ID,
+			})
+		}
+
+		if text == "" {
+			return reply("Usage: /search <terms>")
+		}
+
+		results, err := searcher.Search(ctx, store.SearchQuery{
+			Text:        text,
+			ChannelName: msg.ChannelName,
+			ChatID:      msg.ChatID,
+			// Fetch one extra in case the command message itself was recorded
+			Limit: 6,
+		})
+		if err != nil {
+			return fmt.Errorf("search history: %w", err)
+		}
+
+		// Skip the search command itself
+		filtered := results[:0]
+		for _, r := range results {
+			if r.Message.ID != msg.ID {
+				filtered = append(filtered, r)
+			}
+		}
+		if len(filtered) > 5 {
+			filtered = filtered[:5]
+		}
+
+		return reply(FormatResults(filtered))
+	}
+}
+
+// FormatResults renders search results as a plain-text list.
+func FormatResults(results []store.SearchResult) string {
+	if len(results) == 0 {
+		return "No matching messages found."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d matching message(s):\n", len(results))
+	for _, r := range results {
+		m := r.Message
+		sender := m.SenderName
+		if sender == "" {
+			sender = m.SenderID
+		}
+		if m.Direction == store.DirectionOutgoing {
+			sender = "envoy"
+		}
+		fmt.Fprintf(&b, "\n[%s] %s: %s", m.Timestamp.Format("2006-01-02 15:04"), sender, snippet(m.Content))
+	}
+	return b.String()
+}
+
+// snippet shortens content to a single line of at most maxSnippetLength characters.
+func snippet(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	runes := []rune(content)
+	if len(runes) > maxSnippetLength {
+		return string(runes[:maxSnippetLength]) + "…"
+	}
+	return content
+}
@@ -0,0 +1,53 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+	"github.com/agentplexus/envoy/store"
+)
+
+func TestSearchCommand(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	sender := &channelstest.Sender{}
+
+	record := Recorder(s)
+	search := SearchCommand(s, sender)
+
+	incoming := []channels.IncomingMessage{
+		{ID: "1", ChannelName: "telegram", ChatID: "42", SenderName: "Alice", Content: "My flight lands at 9pm", Timestamp: time.Now()},
+		{ID: "2", ChannelName: "telegram", ChatID: "99", SenderName: "Bob", Content: "Other chat flight", Timestamp: time.Now()},
+		{ID: "3", ChannelName: "telegram", ChatID: "42", SenderName: "Alice", Content: "/search flight", Timestamp: time.Now()},
+	}
+	for _, msg := range incoming {
+		if err := record(ctx, msg); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+
+	if err := search(ctx, incoming[2]); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	if len(sender.Sent()) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sender.Sent()))
+	}
+	reply := sender.Sent()[0]
+	if reply.ReplyTo != "3" {
+		t.Errorf("ReplyTo = %s, want 3", reply.ReplyTo)
+	}
+	if !strings.Contains(reply.Content, "lands at 9pm") {
+		t.Errorf("reply should contain matching message, got %q", reply.Content)
+	}
+	if strings.Contains(reply.Content, "Other chat") {
+		t.Errorf("reply should be scoped to the current chat, got %q", reply.Content)
+	}
+	if strings.Contains(reply.Content, "/search") {
+		t.Errorf("reply should not include the command itself, got %q", reply.Content)
+	}
+}
@@ -0,0 +1,62 @@
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// CommandPrefix is the chat command that dumps a session.
+const CommandPrefix = "/debug"
+
+// maxCommandOutput is the maximum dump size sent to a chat.
+const maxCommandOutput = 3500
+
+// Command returns a handler for "/debug session [<id>]" that replies with
+// the session dump; without an ID it dumps the current chat's session.
+// Dumps expose other users' conversations, so register it only for
+// operators, e.g. with channels.RoutePattern{Prefix: inspect.CommandPrefix,
+// Senders: admins}.
+func Command(inspector *Inspector, sender channels.Sender) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		args, ok := channels.ParseCommand(msg.Content, CommandPrefix)
+		if !ok {
+			return nil
+		}
+
+		reply := func(content string) error {
+			return sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+				Content: content,
+				ReplyTo: msg.ID,
+				Format:  channels.MessageFormatMarkdown,
+			})
+		}
+
+		fields := strings.Fields(args)
+		if len(fields) == 0 || fields[0] != "session" || len(fields) > 2 {
+			return reply("Usage: /debug session [<channel>:<chat>]")
+		}
+		sessionID := fmt.Sprintf("%s:%s", msg.ChannelName, msg.ChatID)
+		if len(fields) == 2 {
+			sessionID = fields[1]
+		}
+
+		state, err := inspector.Dump(ctx, sessionID)
+		if err != nil {
+			return reply(err.Error())
+		}
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode session state: %w", err)
+		}
+
+		out := string(data)
+		if len(out) > maxCommandOutput {
+			out = strings.ToValidUTF8(out[:maxCommandOutput], "") + "\n… (truncated; use the admin API for the full dump)"
+		}
+		return reply("```\n" + out + "\n```")
+	}
+}
@@ -0,0 +1,155 @@
+// Package inspect dumps a conversation session's state as JSON for
+// debugging reports like "the bot stopped responding to me".
+//
+// Each subsystem contributes a section through a Provider, so a dump
+// collects whatever the deployment uses: history, channel state, flows,
+// tags, approvals, or outbox entries.
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider contributes one section of a session dump.
+type Provider interface {
+	// Name is the section name in the dump (e.g., "history").
+	Name() string
+
+	// Inspect returns JSON-serializable state for the session.
+	Inspect(ctx context.Context, session Session) (interface{}, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc struct {
+	Section string
+	Fn      func(ctx context.Context, session Session) (interface{}, error)
+}
+
+// Name returns the section name.
+func (f ProviderFunc) Name() string {
+	return f.Section
+}
+
+// Inspect calls Fn.
+func (f ProviderFunc) Inspect(ctx context.Context, session Session) (interface{}, error) {
+	return f.Fn(ctx, session)
+}
+
+// Session identifies a conversation.
+type Session struct {
+	// ID is the router session ID, "<channel>:<chat>".
+	ID          string `json:"id"`
+	ChannelName string `json:"channel"`
+	ChatID      string `json:"chat_id"`
+}
+
+// ParseSession splits a router session ID ("<channel>:<chat>").
+func ParseSession(id string) (Session, error) {
+	channel, chat, ok := strings.Cut(id, ":")
+	if !ok || channel == "" || chat == "" {
+		return Session{}, fmt.Errorf("invalid session id %q: want <channel>:<chat>", id)
+	}
+	return Session{ID: id, ChannelName: channel, ChatID: chat}, nil
+}
+
+// State is a session dump.
+type State struct {
+	Session  Session                `json:"session"`
+	Time     time.Time              `json:"time"`
+	Sections map[string]interface{} `json:"sections"`
+
+	// Errors holds sections whose provider failed.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Config configures an Inspector.
+type Config struct {
+	Providers []Provider
+
+	// Timeout bounds each provider (default: 5s).
+	Timeout time.Duration
+
+	Logger *slog.Logger
+}
+
+// Inspector builds session dumps from registered providers.
+type Inspector struct {
+	providers []Provider
+	timeout   time.Duration
+	logger    *slog.Logger
+	mu        sync.RWMutex
+}
+
+// New creates a new Inspector.
+func New(config Config) *Inspector {
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Inspector{
+		providers: config.Providers,
+		timeout:   config.Timeout,
+		logger:    config.Logger,
+	}
+}
+
+// Register adds a provider.
+func (i *Inspector) Register(p Provider) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.providers = append(i.providers, p)
+}
+
+// Dump collects the state of a session from all providers. Provider
+// failures are reported in State.Errors rather than failing the dump.
+func (i *Inspector) Dump(ctx context.Context, sessionID string) (*State, error) {
+	session, err := ParseSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.RLock()
+	providers := make([]Provider, len(i.providers))
+	copy(providers, i.providers)
+	i.mu.RUnlock()
+
+	state := &State{
+		Session:  session,
+		Time:     time.Now().UTC(),
+		Sections: make(map[string]interface{}, len(providers)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range providers {
+		wg.Add(1)
+		go func(p Provider) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, i.timeout)
+			defer cancel()
+
+			section, err := p.Inspect(ctx, session)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				i.logger.Warn("inspect provider error", "section", p.Name(), "session", sessionID, "error", err)
+				if state.Errors == nil {
+					state.Errors = make(map[string]string)
+				}
+				state.Errors[p.Name()] = err.Error()
+				return
+			}
+			state.Sections[p.Name()] = section
+		}(p)
+	}
+	wg.Wait()
+	return state, nil
+}
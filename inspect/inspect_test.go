@@ -0,0 +1,84 @@
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+	"github.com/agentplexus/envoy/store"
+)
+
+func TestDump(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	now := time.Now()
+	_ = st.Append(ctx, store.Message{ID: "1", ChannelName: "telegram", ChatID: "42", Content: "hello", Timestamp: now})
+	_ = st.Append(ctx, store.Message{ID: "2", ChannelName: "telegram", ChatID: "99", Content: "other chat", Timestamp: now})
+	_ = st.Append(ctx, store.Message{ID: "3", ChannelName: "telegram", ChatID: "42", Content: "again", Timestamp: now.Add(time.Second)})
+
+	inspector := New(Config{Providers: []Provider{
+		History(st, 0),
+		Channel(channels.NewRouter(nil)),
+		ProviderFunc{Section: "flow", Fn: func(context.Context, Session) (interface{}, error) {
+			return nil, errors.New("flow store offline")
+		}},
+	}})
+
+	state, err := inspector.Dump(ctx, "telegram:42")
+	if err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+
+	history, _ := state.Sections["history"].([]store.Message)
+	if len(history) != 2 || history[0].ID != "1" || history[1].ID != "3" {
+		t.Errorf("history = %+v, want messages 1 and 3 in order", history)
+	}
+	if ch, _ := state.Sections["channel"].(channelState); ch.Registered {
+		t.Errorf("channel = %+v, want unregistered", ch)
+	}
+	if state.Errors["flow"] != "flow store offline" {
+		t.Errorf("errors = %v", state.Errors)
+	}
+
+	if _, err := inspector.Dump(ctx, "nocolon"); err == nil {
+		t.Error("expected error for malformed session id")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	inspector := New(Config{Providers: []Provider{
+		ProviderFunc{Section: "tags", Fn: func(_ context.Context, s Session) (interface{}, error) {
+			return []string{"vip"}, nil
+		}},
+	}})
+	sender := &channelstest.Sender{}
+	handler := Command(inspector, sender)
+
+	msg := channels.IncomingMessage{ChannelName: "discord", ChatID: "c1", Content: "/debug session"}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if len(sender.Sent()) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sender.Sent()))
+	}
+
+	body := strings.TrimSuffix(strings.TrimPrefix(sender.Sent()[0].Content, "```\n"), "\n```")
+	var state State
+	if err := json.Unmarshal([]byte(body), &state); err != nil {
+		t.Fatalf("reply is not a JSON dump: %v\n%s", err, body)
+	}
+	if state.Session.ID != "discord:c1" {
+		t.Errorf("session = %s, want discord:c1", state.Session.ID)
+	}
+
+	msg.Content = "/debug nonsense"
+	_ = handler(context.Background(), msg)
+	if !strings.HasPrefix(sender.Sent()[1].Content, "Usage:") {
+		t.Errorf("expected usage reply, got %q", sender.Sent()[1].Content)
+	}
+}
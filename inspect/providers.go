@@ -0,0 +1,63 @@
+package inspect
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+// DefaultHistoryLimit is the number of recent messages in a history section.
+const DefaultHistoryLimit = 20
+
+// History returns a provider listing the session's most recent messages,
+// oldest first. Deleted messages are included with their tombstones.
+func History(lister store.Lister, limit int) Provider {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	return ProviderFunc{
+		Section: "history",
+		Fn: func(ctx context.Context, session Session) (interface{}, error) {
+			page, err := lister.List(ctx, store.ListQuery{
+				ChannelName: session.ChannelName,
+				ChatID:      session.ChatID,
+				Limit:       limit,
+				Reverse:     true,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("list history: %w", err)
+			}
+			// Listed newest first
+			messages := page.Messages
+			for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+				messages[i], messages[j] = messages[j], messages[i]
+			}
+			return messages, nil
+		},
+	}
+}
+
+// channelState is the channel section of a dump.
+type channelState struct {
+	Name       string `json:"name"`
+	Registered bool   `json:"registered"`
+	Connected  bool   `json:"connected"`
+}
+
+// Channel returns a provider reporting whether the session's channel is
+// registered and connected.
+func Channel(router *channels.Router) Provider {
+	return ProviderFunc{
+		Section: "channel",
+		Fn: func(_ context.Context, session Session) (interface{}, error) {
+			_, registered := router.GetChannel(session.ChannelName)
+			return channelState{
+				Name:       session.ChannelName,
+				Registered: registered,
+				Connected:  router.IsConnected(session.ChannelName),
+			}, nil
+		},
+	}
+}
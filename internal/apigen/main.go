@@ -0,0 +1,574 @@
+// Command apigen generates Go server stubs and request validation from the
+// gateway OpenAPI spec.
+//
+// It supports the subset of OpenAPI 3 used by envoy: JSON request and
+// response bodies, component schemas referenced by $ref, path and query
+// parameters, and the string/number/array validation keywords.
+//
+// Usage:
+//
+//	go run ./internal/apigen -spec gateway/openapi.yaml -out gateway/api.gen.go -package gateway
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// spec is the subset of an OpenAPI 3 document understood by the generator.
+type spec struct {
+	Paths      map[string]map[string]*operation `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*schema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary"`
+	Parameters  []parameter          `yaml:"parameters"`
+	RequestBody *body                `yaml:"requestBody"`
+	Responses   map[string]*response `yaml:"responses"`
+
+	// Set by the generator
+	method string
+	path   string
+}
+
+type parameter struct {
+	Name     string `yaml:"name"`
+	In       string `yaml:"in"`
+	Required bool   `yaml:"required"`
+	Schema   schema `yaml:"schema"`
+}
+
+type body struct {
+	Required bool                  `yaml:"required"`
+	Content  map[string]*mediaType `yaml:"content"`
+}
+
+type response struct {
+	Description string                `yaml:"description"`
+	Content     map[string]*mediaType `yaml:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `yaml:"schema"`
+}
+
+type schema struct {
+	Ref         string             `yaml:"$ref"`
+	Type        string             `yaml:"type"`
+	Format      string             `yaml:"format"`
+	Description string             `yaml:"description"`
+	Properties  map[string]*schema `yaml:"properties"`
+	Required    []string           `yaml:"required"`
+	Enum        []string           `yaml:"enum"`
+	Items       *schema            `yaml:"items"`
+	MinLength   *int               `yaml:"minLength"`
+	MaxLength   *int               `yaml:"maxLength"`
+	MaxItems    *int               `yaml:"maxItems"`
+	Minimum     *float64           `yaml:"minimum"`
+	Maximum     *float64           `yaml:"maximum"`
+}
+
+func main() {
+	specPath := flag.String("spec", "openapi.yaml", "OpenAPI spec path")
+	outPath := flag.String("out", "api.gen.go", "output Go file")
+	pkg := flag.String("package", "gateway", "Go package name")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("read spec: %v", err)
+	}
+
+	var s spec
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		log.Fatalf("parse spec: %v", err)
+	}
+
+	src, err := generate(&s, *pkg, filepath.Base(*specPath))
+	if err != nil {
+		log.Fatalf("generate: %v", err)
+	}
+
+	if err := os.WriteFile(*outPath, src, 0o600); err != nil {
+		log.Fatalf("write output: %v", err)
+	}
+}
+
+// generator accumulates generated source.
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// generate renders the Go source for a spec.
+func generate(s *spec, pkg, specName string) ([]byte, error) {
+	g := &generator{imports: map[string]bool{
+		"context":  true,
+		"net/http": true,
+	}}
+
+	ops, err := collectOperations(s)
+	if err != nil {
+		return nil, err
+	}
+
+	g.genTypes(s)
+	g.genInterface(ops)
+	g.genRegister(ops)
+	for _, op := range ops {
+		if err := g.genHandler(op); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by internal/apigen from %s; DO NOT EDIT.\n\n", specName)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	out.WriteString("import (\n")
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("format source: %w", err)
+	}
+	return src, nil
+}
+
+// collectOperations returns all operations sorted by path and method.
+func collectOperations(s *spec) ([]*operation, error) {
+	var ops []*operation
+	for path, methods := range s.Paths {
+		for method, op := range methods {
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("%s %s: operationId required", method, path)
+			}
+			op.method = strings.ToUpper(method)
+			op.path = path
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].path != ops[j].path {
+			return ops[i].path < ops[j].path
+		}
+		return ops[i].method < ops[j].method
+	})
+	return ops, nil
+}
+
+// genTypes emits a struct and Validate method per component schema.
+func (g *generator) genTypes(s *spec) {
+	names := make([]string, 0, len(s.Components.Schemas))
+	for name := range s.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sc := s.Components.Schemas[name]
+		g.p("// %s is generated from the %s schema.", name, name)
+		if sc.Description != "" {
+			g.p("//")
+			g.p("// %s", sentence(sc.Description))
+		}
+		g.p("type %s struct {", name)
+		for _, prop := range sortedKeys(sc.Properties) {
+			ps := sc.Properties[prop]
+			tag := prop
+			if !contains(sc.Required, prop) {
+				tag += ",omitempty"
+			}
+			if ps.Description != "" {
+				g.p("// %s", sentence(ps.Description))
+			}
+			g.p("%s %s `json:%q`", goName(prop), g.goType(ps), tag)
+		}
+		g.p("}")
+		g.p("")
+		g.genValidate(name, sc)
+	}
+}
+
+// genValidate emits a Validate method enforcing the schema constraints.
+func (g *generator) genValidate(name string, sc *schema) {
+	g.p("// Validate checks the %s constraints declared in the spec.", name)
+	g.p("func (v *%s) Validate() error {", name)
+	for _, prop := range sortedKeys(sc.Properties) {
+		ps := sc.Properties[prop]
+		field := "v." + goName(prop)
+		required := contains(sc.Required, prop)
+
+		switch {
+		case ps.Ref != "":
+			g.imports["fmt"] = true
+			g.p("if err := %s.Validate(); err != nil {", field)
+			g.p("return fmt.Errorf(\"%s: %%w\", err)", prop)
+			g.p("}")
+		case ps.Type == "string" && ps.Format != "date-time":
+			g.genStringChecks(field, prop, ps, required)
+		case ps.Type == "integer" || ps.Type == "number":
+			g.genNumberChecks(field, prop, ps)
+		case ps.Type == "array":
+			if required {
+				g.imports["errors"] = true
+				g.p("if %s == nil {", field)
+				g.p("return errors.New(%q)", prop+" is required")
+				g.p("}")
+			}
+			if ps.MaxItems != nil {
+				g.imports["errors"] = true
+				g.p("if len(%s) > %d {", field, *ps.MaxItems)
+				g.p("return errors.New(\"%s must have at most %d items\")", prop, *ps.MaxItems)
+				g.p("}")
+			}
+			if ps.Items != nil && ps.Items.Ref != "" {
+				g.imports["fmt"] = true
+				g.p("for i := range %s {", field)
+				g.p("if err := %s[i].Validate(); err != nil {", field)
+				g.p("return fmt.Errorf(\"%s[%%d]: %%w\", i, err)", prop)
+				g.p("}")
+				g.p("}")
+			}
+		}
+	}
+	g.p("return nil")
+	g.p("}")
+	g.p("")
+}
+
+func (g *generator) genStringChecks(field, prop string, ps *schema, required bool) {
+	if required {
+		g.imports["errors"] = true
+		g.p("if %s == \"\" {", field)
+		g.p("return errors.New(%q)", prop+" is required")
+		g.p("}")
+	}
+	if ps.MinLength != nil {
+		g.imports["errors"] = true
+		g.imports["unicode/utf8"] = true
+		g.p("if %s != \"\" && utf8.RuneCountInString(%s) < %d {", field, field, *ps.MinLength)
+		g.p("return errors.New(\"%s must be at least %d characters\")", prop, *ps.MinLength)
+		g.p("}")
+	}
+	if ps.MaxLength != nil {
+		g.imports["errors"] = true
+		g.imports["unicode/utf8"] = true
+		g.p("if utf8.RuneCountInString(%s) > %d {", field, *ps.MaxLength)
+		g.p("return errors.New(\"%s must be at most %d characters\")", prop, *ps.MaxLength)
+		g.p("}")
+	}
+	if len(ps.Enum) > 0 {
+		g.imports["errors"] = true
+		quoted := make([]string, len(ps.Enum))
+		for i, e := range ps.Enum {
+			quoted[i] = strconv.Quote(e)
+		}
+		g.p("switch %s {", field)
+		if required {
+			g.p("case %s:", strings.Join(quoted, ", "))
+		} else {
+			g.p("case \"\", %s:", strings.Join(quoted, ", "))
+		}
+		g.p("default:")
+		g.p("return errors.New(\"%s must be one of %s\")", prop, strings.Join(ps.Enum, ", "))
+		g.p("}")
+	}
+}
+
+func (g *generator) genNumberChecks(field, prop string, ps *schema) {
+	if ps.Minimum != nil {
+		g.imports["errors"] = true
+		g.p("if %s < %v {", field, *ps.Minimum)
+		g.p("return errors.New(\"%s must be at least %v\")", prop, *ps.Minimum)
+		g.p("}")
+	}
+	if ps.Maximum != nil {
+		g.imports["errors"] = true
+		g.p("if %s > %v {", field, *ps.Maximum)
+		g.p("return errors.New(\"%s must be at most %v\")", prop, *ps.Maximum)
+		g.p("}")
+	}
+}
+
+// genInterface emits the ServerInterface with one method per operation.
+func (g *generator) genInterface(ops []*operation) {
+	g.p("// ServerInterface is implemented by the gateway to serve the REST API.")
+	g.p("type ServerInterface interface {")
+	for _, op := range ops {
+		if op.Summary != "" {
+			g.p("// %s handles %s %s: %s.", goName(op.OperationID), op.method, op.path, lowerFirst(op.Summary))
+		}
+		g.p("%s(%s) (%s, error)", goName(op.OperationID), g.params(op), g.resultType(op))
+	}
+	g.p("}")
+	g.p("")
+}
+
+// genRegister emits the route registration function.
+func (g *generator) genRegister(ops []*operation) {
+	g.p("// registerAPI mounts the REST API handlers on mux.")
+	g.p("func registerAPI(mux *http.ServeMux, si ServerInterface) {")
+	for _, op := range ops {
+		g.p("mux.HandleFunc(%q, %sHandler(si))", op.method+" "+op.path, lowerFirst(goName(op.OperationID)))
+	}
+	g.p("}")
+	g.p("")
+}
+
+// genHandler emits the HTTP handler adapting one operation to ServerInterface.
+func (g *generator) genHandler(op *operation) error {
+	name := goName(op.OperationID)
+	g.p("// %sHandler decodes and validates a %s %s request.", lowerFirst(name), op.method, op.path)
+	g.p("func %sHandler(si ServerInterface) http.HandlerFunc {", lowerFirst(name))
+	g.p("return func(w http.ResponseWriter, r *http.Request) {")
+
+	args := []string{"r.Context()"}
+	for _, param := range op.Parameters {
+		v := lowerFirst(goName(param.Name))
+		switch param.In {
+		case "path":
+			g.p("%s := r.PathValue(%q)", v, param.Name)
+		case "query":
+			g.p("%s := r.URL.Query().Get(%q)", v, param.Name)
+		default:
+			return fmt.Errorf("%s: unsupported parameter location %q", op.OperationID, param.In)
+		}
+		if param.Required {
+			g.p("if %s == \"\" {", v)
+			g.p("writeAPIError(w, http.StatusBadRequest, %q)", param.Name+" is required")
+			g.p("return")
+			g.p("}")
+		}
+		if param.Schema.Type == "integer" {
+			g.imports["strconv"] = true
+			g.p("var %sInt int", v)
+			g.p("if %s != \"\" {", v)
+			g.p("n, err := strconv.Atoi(%s)", v)
+			g.p("if err != nil {")
+			g.p("writeAPIError(w, http.StatusBadRequest, %q)", param.Name+" must be an integer")
+			g.p("return")
+			g.p("}")
+			g.p("%sInt = n", v)
+			g.p("}")
+			v += "Int"
+		}
+		args = append(args, v)
+	}
+
+	if bt := bodyType(op); bt != "" {
+		g.imports["encoding/json"] = true
+		g.p("var body %s", bt)
+		g.p("dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize))")
+		g.p("dec.DisallowUnknownFields()")
+		g.p("if err := dec.Decode(&body); err != nil {")
+		g.p("writeAPIError(w, http.StatusBadRequest, \"invalid request body: \"+err.Error())")
+		g.p("return")
+		g.p("}")
+		g.p("if err := body.Validate(); err != nil {")
+		g.p("writeAPIError(w, http.StatusBadRequest, err.Error())")
+		g.p("return")
+		g.p("}")
+		args = append(args, "&body")
+	}
+
+	status := successStatus(op)
+	g.p("resp, err := si.%s(%s)", name, strings.Join(args, ", "))
+	g.p("if err != nil {")
+	g.p("writeAPIErr(w, err)")
+	g.p("return")
+	g.p("}")
+	g.p("writeAPIResponse(w, %s, resp)", status)
+	g.p("}")
+	g.p("}")
+	g.p("")
+	return nil
+}
+
+// params returns the ServerInterface method parameter list for an operation.
+func (g *generator) params(op *operation) string {
+	params := []string{"ctx context.Context"}
+	for _, param := range op.Parameters {
+		t := "string"
+		if param.Schema.Type == "integer" {
+			t = "int"
+		}
+		params = append(params, lowerFirst(goName(param.Name))+" "+t)
+	}
+	if bt := bodyType(op); bt != "" {
+		params = append(params, "body *"+bt)
+	}
+	return strings.Join(params, ", ")
+}
+
+// resultType returns the Go type of the success response.
+func (g *generator) resultType(op *operation) string {
+	code := successCode(op)
+	if r := op.Responses[code]; r != nil {
+		if mt := r.Content["application/json"]; mt != nil && mt.Schema != nil {
+			return "*" + refName(mt.Schema.Ref)
+		}
+	}
+	return "interface{}"
+}
+
+// goType maps a schema to a Go type.
+func (g *generator) goType(s *schema) string {
+	if s.Ref != "" {
+		return refName(s.Ref)
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int64" {
+			return "int64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		if s.Items == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.goType(s.Items)
+	default:
+		return "map[string]interface{}"
+	}
+}
+
+// bodyType returns the Go type of the JSON request body, if any.
+func bodyType(op *operation) string {
+	if op.RequestBody == nil {
+		return ""
+	}
+	if mt := op.RequestBody.Content["application/json"]; mt != nil && mt.Schema != nil {
+		return refName(mt.Schema.Ref)
+	}
+	return ""
+}
+
+// successCode returns the first 2xx response code declared for op.
+func successCode(op *operation) string {
+	codes := sortedKeys(op.Responses)
+	for _, c := range codes {
+		if strings.HasPrefix(c, "2") {
+			return c
+		}
+	}
+	return "200"
+}
+
+// successStatus returns the net/http constant for the success response code.
+func successStatus(op *operation) string {
+	switch successCode(op) {
+	case "201":
+		return "http.StatusCreated"
+	case "202":
+		return "http.StatusAccepted"
+	case "204":
+		return "http.StatusNoContent"
+	default:
+		return "http.StatusOK"
+	}
+}
+
+// refName extracts the schema name from a "#/components/schemas/Name" ref.
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// goName converts snake_case or camelCase identifiers to exported Go names.
+func goName(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' })
+	for i, p := range parts {
+		switch strings.ToLower(p) {
+		case "id", "url", "api", "ttl":
+			parts[i] = strings.ToUpper(p)
+		default:
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// sentence capitalizes s and ensures it ends with a period.
+func sentence(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return s
+	}
+	s = strings.ToUpper(s[:1]) + s[1:]
+	if !strings.HasSuffix(s, ".") {
+		s += "."
+	}
+	return s
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	if strings.ToUpper(s) == s {
+		return strings.ToLower(s)
+	}
+	r := []rune(s)
+	// Keep acronyms like "ID" intact when they start the identifier
+	if len(r) > 1 && unicode.IsUpper(r[1]) {
+		return s
+	}
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
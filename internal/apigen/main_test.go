@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testSpec = `
+paths:
+  /v1/sessions/{id}/messages:
+    post:
+      operationId: postSessionMessage
+      summary: Send a message
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: string
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/ChatRequest"
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ChatResponse"
+components:
+  schemas:
+    ChatRequest:
+      type: object
+      required: [content]
+      properties:
+        content:
+          type: string
+          maxLength: 10
+        mode:
+          type: string
+          enum: [sync, async]
+    ChatResponse:
+      type: object
+      properties:
+        content:
+          type: string
+`
+
+func TestGenerate(t *testing.T) {
+	var s spec
+	if err := yaml.Unmarshal([]byte(testSpec), &s); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+
+	src, err := generate(&s, "gateway", "test.yaml")
+	if err != nil {
+		t.Fatalf("generate failed: %v\n%s", err, src)
+	}
+	out := string(src)
+
+	want := []string{
+		"PostSessionMessage(ctx context.Context, id string, body *ChatRequest) (*ChatResponse, error)",
+		`mux.HandleFunc("POST /v1/sessions/{id}/messages", postSessionMessageHandler(si))`,
+		`id := r.PathValue("id")`,
+		"dec.DisallowUnknownFields()",
+		"if err := body.Validate(); err != nil {",
+		`return errors.New("content is required")`,
+		"utf8.RuneCountInString(v.Content) > 10",
+		`case "", "sync", "async":`,
+		"writeAPIResponse(w, http.StatusCreated, resp)",
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("generated source missing %q", w)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"session_id":  "SessionID",
+		"getHealth":   "GetHealth",
+		"retry-after": "RetryAfter",
+		"url":         "URL",
+	}
+	for in, want := range tests {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package channelstest provides test doubles for the channels package.
+package channelstest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Message is a message sent through a Sender.
+type Message struct {
+	Channel string
+	ChatID  string
+	channels.OutgoingMessage
+}
+
+// Sender is a channels.Sender recording the messages it sends. It is safe
+// for concurrent use.
+type Sender struct {
+	// Err, if set, is called for each message; a message it fails is not
+	// recorded.
+	Err func(channelName, chatID string) error
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// Send records msg.
+func (s *Sender) Send(_ context.Context, channelName, chatID string, msg channels.OutgoingMessage) error {
+	if s.Err != nil {
+		if err := s.Err(channelName, chatID); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, Message{channelName, chatID, msg})
+	return nil
+}
+
+// Sent returns the messages sent so far.
+func (s *Sender) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.sent...)
+}
+
+// Contents returns the content of the messages sent so far.
+func (s *Sender) Contents() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	contents := make([]string, len(s.sent))
+	for i, m := range s.sent {
+		contents[i] = m.Content
+	}
+	return contents
+}
+
+// Last returns the content of the last message sent to chatID, or of the
+// last message sent if chatID is empty.
+func (s *Sender) Last(chatID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.sent) - 1; i >= 0; i-- {
+		if chatID == "" || s.sent[i].ChatID == chatID {
+			return s.sent[i].Content
+		}
+	}
+	return ""
+}
+
+// Reset forgets the messages sent so far.
+func (s *Sender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = nil
+}
+
+var _ channels.Sender = (*Sender)(nil)
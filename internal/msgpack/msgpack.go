@@ -0,0 +1,408 @@
+// Package msgpack encodes and decodes the subset of MessagePack used by the
+// gateway's binary wire format: nil, booleans, numbers, strings, binary
+// data, arrays, string-keyed maps, and timestamps.
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrShort is returned when the input ends inside a value.
+var ErrShort = errors.New("msgpack: unexpected end of input")
+
+// timestampExt is the extension type of timestamps.
+const timestampExt = -1
+
+// AppendNil appends nil.
+func AppendNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+// AppendBool appends a boolean.
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+// AppendInt appends a signed integer in its smallest encoding.
+func AppendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return AppendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+// AppendUint appends an unsigned integer in its smallest encoding.
+func AppendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+	}
+}
+
+// AppendFloat appends a float64, or an integer if v is a whole number that
+// fits in one.
+func AppendFloat(b []byte, v float64) []byte {
+	if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+		return AppendInt(b, int64(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+// AppendString appends a string.
+func AppendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+// AppendBytes appends binary data.
+func AppendBytes(b []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, v...)
+}
+
+// AppendArrayHeader appends the header of an array of n values.
+func AppendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+// AppendMapHeader appends the header of a map of n key-value pairs.
+func AppendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+// AppendTime appends a timestamp extension value.
+func AppendTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec>>34 == 0 && nsec == 0:
+		return binary.BigEndian.AppendUint32(append(b, 0xd6, 0xff), uint32(sec))
+	case sec >= 0 && sec>>34 == 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xd7, 0xff), nsec<<34|uint64(sec))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc7, 12, 0xff), uint32(nsec))
+		return binary.BigEndian.AppendUint64(b, uint64(sec))
+	}
+}
+
+// Append appends any value encoding/json can marshal. Types other than
+// nil, booleans, numbers, strings, []byte, time.Time, and slices and
+// string-keyed maps of those are encoded as their JSON representation
+// would be.
+func Append(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return AppendNil(b), nil
+	case bool:
+		return AppendBool(b, v), nil
+	case int:
+		return AppendInt(b, int64(v)), nil
+	case int8:
+		return AppendInt(b, int64(v)), nil
+	case int16:
+		return AppendInt(b, int64(v)), nil
+	case int32:
+		return AppendInt(b, int64(v)), nil
+	case int64:
+		return AppendInt(b, v), nil
+	case uint:
+		return AppendUint(b, uint64(v)), nil
+	case uint8:
+		return AppendUint(b, uint64(v)), nil
+	case uint16:
+		return AppendUint(b, uint64(v)), nil
+	case uint32:
+		return AppendUint(b, uint64(v)), nil
+	case uint64:
+		return AppendUint(b, v), nil
+	case float32:
+		return AppendFloat(b, float64(v)), nil
+	case float64:
+		return AppendFloat(b, v), nil
+	case string:
+		return AppendString(b, v), nil
+	case []byte:
+		return AppendBytes(b, v), nil
+	case time.Time:
+		return AppendTime(b, v), nil
+	case []interface{}:
+		b = AppendArrayHeader(b, len(v))
+		for _, e := range v {
+			var err error
+			if b, err = Append(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []string:
+		b = AppendArrayHeader(b, len(v))
+		for _, e := range v {
+			b = AppendString(b, e)
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = AppendMapHeader(b, len(v))
+		for k, e := range v {
+			b = AppendString(b, k)
+			var err error
+			if b, err = Append(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]string:
+		b = AppendMapHeader(b, len(v))
+		for k, e := range v {
+			b = AppendString(AppendString(b, k), e)
+		}
+		return b, nil
+	}
+
+	// Encode other types, such as structs, as JSON would see them
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	return Append(b, generic)
+}
+
+// Decode decodes the value at the start of b and returns the rest of the
+// input. Numbers decode as float64, as with encoding/json, maps as
+// map[string]interface{}, arrays as []interface{}, binary data as []byte,
+// and timestamps as time.Time.
+func Decode(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, ErrShort
+	}
+	c, b := b[0], b[1:]
+	switch {
+	case c <= 0x7f:
+		return float64(c), b, nil
+	case c >= 0xe0:
+		return float64(int8(c)), b, nil
+	case c&0xe0 == 0xa0:
+		return decodeString(b, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return decodeArray(b, int(c&0x0f))
+	case c&0xf0 == 0x80:
+		return decodeMap(b, int(c&0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, b, nil
+	case 0xc2:
+		return false, b, nil
+	case 0xc3:
+		return true, b, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n := 1 << (c - 0xcc)
+		if len(b) < n {
+			return nil, nil, ErrShort
+		}
+		return float64(readUint(b[:n])), b[n:], nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		if len(b) < n {
+			return nil, nil, ErrShort
+		}
+		u := readUint(b[:n])
+		shift := 64 - 8*n
+		return float64(int64(u<<shift) >> shift), b[n:], nil
+	case 0xca:
+		if len(b) < 4 {
+			return nil, nil, ErrShort
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), b[4:], nil
+	case 0xcb:
+		if len(b) < 8 {
+			return nil, nil, ErrShort
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	case 0xd9, 0xda, 0xdb:
+		n, rest, err := readLength(b, 1<<(c-0xd9))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeString(rest, n)
+	case 0xc4, 0xc5, 0xc6:
+		n, rest, err := readLength(b, 1<<(c-0xc4))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) < n {
+			return nil, nil, ErrShort
+		}
+		return append([]byte(nil), rest[:n]...), rest[n:], nil
+	case 0xdc, 0xdd:
+		n, rest, err := readLength(b, 2<<(c-0xdc))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeArray(rest, n)
+	case 0xde, 0xdf:
+		n, rest, err := readLength(b, 2<<(c-0xde))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeMap(rest, n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return decodeExt(b, 1<<(c-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, rest, err := readLength(b, 1<<(c-0xc7))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeExt(rest, n)
+	}
+	return nil, nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", c)
+}
+
+// readUint reads a big-endian unsigned integer of 1, 2, 4, or 8 bytes.
+func readUint(b []byte) uint64 {
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u
+}
+
+// readLength reads a length of size bytes.
+func readLength(b []byte, size int) (int, []byte, error) {
+	if len(b) < size {
+		return 0, nil, ErrShort
+	}
+	n := readUint(b[:size])
+	if n > uint64(len(b)) {
+		return 0, nil, ErrShort
+	}
+	return int(n), b[size:], nil
+}
+
+func decodeString(b []byte, n int) (interface{}, []byte, error) {
+	if len(b) < n {
+		return nil, nil, ErrShort
+	}
+	return string(b[:n]), b[n:], nil
+}
+
+func decodeArray(b []byte, n int) (interface{}, []byte, error) {
+	// Each element takes at least a byte
+	if n > len(b) {
+		return nil, nil, ErrShort
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		var err error
+		if a[i], b, err = Decode(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	return a, b, nil
+}
+
+func decodeMap(b []byte, n int) (interface{}, []byte, error) {
+	if 2*n > len(b) {
+		return nil, nil, ErrShort
+	}
+	m := make(map[string]interface{}, n)
+	for range n {
+		k, rest, err := Decode(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("msgpack: map key of type %T", k)
+		}
+		if m[key], b, err = Decode(rest); err != nil {
+			return nil, nil, err
+		}
+	}
+	return m, b, nil
+}
+
+// decodeExt decodes an extension value with n bytes of data. Only
+// timestamps are supported.
+func decodeExt(b []byte, n int) (interface{}, []byte, error) {
+	if len(b) < 1+n {
+		return nil, nil, ErrShort
+	}
+	typ, data, rest := int8(b[0]), b[1:1+n], b[1+n:]
+	if typ != timestampExt {
+		return nil, nil, fmt.Errorf("msgpack: unsupported extension type %d", typ)
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), rest, nil
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)).UTC(), rest, nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), rest, nil
+	}
+	return nil, nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
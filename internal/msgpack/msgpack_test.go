@@ -0,0 +1,112 @@
+package msgpack
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"nil", AppendNil(nil), []byte{0xc0}},
+		{"true", AppendBool(nil, true), []byte{0xc3}},
+		{"fixint", AppendInt(nil, 7), []byte{0x07}},
+		{"negative fixint", AppendInt(nil, -1), []byte{0xff}},
+		{"int8", AppendInt(nil, -100), []byte{0xd0, 0x9c}},
+		{"uint16", AppendUint(nil, 300), []byte{0xcd, 0x01, 0x2c}},
+		{"whole float", AppendFloat(nil, 2), []byte{0x02}},
+		{"float", AppendFloat(nil, 0.5), []byte{0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0}},
+		{"fixstr", AppendString(nil, "hi"), []byte{0xa2, 'h', 'i'}},
+		{"bin", AppendBytes(nil, []byte{1, 2}), []byte{0xc4, 0x02, 1, 2}},
+		{"fixarray", AppendArrayHeader(nil, 3), []byte{0x93}},
+		{"map16", AppendMapHeader(nil, 20), []byte{0xde, 0x00, 0x14}},
+		{"timestamp32", AppendTime(nil, time.Unix(1, 0)), []byte{0xd6, 0xff, 0, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s: % x, want % x", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+	}
+	long := strings.Repeat("x", 70000)
+	in := map[string]interface{}{
+		"nil":    nil,
+		"bool":   false,
+		"int":    -70000,
+		"uint":   uint64(1 << 40),
+		"float":  math.Pi,
+		"string": long,
+		"bytes":  []byte("raw"),
+		"list":   []interface{}{"a", 1, true},
+		"names":  []string{"a", "b"},
+		"struct": point{X: 3},
+		"nested": map[string]string{"k": "v"},
+	}
+	b, err := Append(nil, in)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	out, rest, err := Decode(b)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("Decode = %v, %d bytes left", err, len(rest))
+	}
+	want := map[string]interface{}{
+		"nil":    nil,
+		"bool":   false,
+		"int":    float64(-70000),
+		"uint":   float64(1 << 40),
+		"float":  math.Pi,
+		"string": long,
+		"bytes":  []byte("raw"),
+		"list":   []interface{}{"a", float64(1), true},
+		"names":  []interface{}{"a", "b"},
+		"struct": map[string]interface{}{"x": float64(3)},
+		"nested": map[string]interface{}{"k": "v"},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("Decode = %#v, want %#v", out, want)
+	}
+
+	for _, ts := range []time.Time{
+		time.Unix(1700000000, 0),
+		time.Unix(1700000000, 123456789),
+		time.Unix(-1, 5),
+	} {
+		v, _, err := Decode(AppendTime(nil, ts))
+		if err != nil || !v.(time.Time).Equal(ts) {
+			t.Errorf("timestamp %v decoded as %v, %v", ts, v, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	b, _ := Append(nil, map[string]interface{}{"key": "value"})
+	for i := range b {
+		if _, _, err := Decode(b[:i]); !errors.Is(err, ErrShort) {
+			t.Errorf("Decode of %d bytes: err = %v, want ErrShort", i, err)
+		}
+	}
+	for _, bad := range [][]byte{
+		{0xc1},                         // never used
+		{0x81, 0x01, 0x01},             // integer map key
+		{0xd4, 0x05, 0x00},             // unknown extension
+		{0xdd, 0xff, 0xff, 0xff, 0xff}, // array longer than the input
+	} {
+		if _, _, err := Decode(bad); err == nil {
+			t.Errorf("Decode(% x) succeeded", bad)
+		}
+	}
+}
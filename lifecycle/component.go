@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Hooks is a Component built from optional start and stop functions.
+type Hooks struct {
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Start calls OnStart if set.
+func (h Hooks) Start(ctx context.Context) error {
+	if h.OnStart == nil {
+		return nil
+	}
+	return h.OnStart(ctx)
+}
+
+// Stop calls OnStop if set.
+func (h Hooks) Stop(ctx context.Context) error {
+	if h.OnStop == nil {
+		return nil
+	}
+	return h.OnStop(ctx)
+}
+
+// Service returns a Component for a blocking run function such as
+// gateway.Run. Start launches run in the background; Stop cancels its
+// context and waits for it to return. If run fails while the Manager is
+// running, the Manager shuts down.
+func Service(run func(ctx context.Context) error) Component {
+	return &service{run: run}
+}
+
+// service runs a blocking function until stopped.
+type service struct {
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	done   chan error
+	failed chan error
+	mu     sync.Mutex
+}
+
+// Start launches the run function.
+func (s *service) Start(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan error, 1)
+	s.failed = make(chan error, 1)
+
+	done, failed := s.done, s.failed
+	go func() {
+		err := s.run(ctx)
+		if err != nil && ctx.Err() == nil {
+			// Reported to the Manager rather than from Stop
+			failed <- err
+			err = nil
+		}
+		close(failed)
+		done <- err
+	}()
+	return nil
+}
+
+// Stop cancels the run function and waits for it to return.
+func (s *service) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,263 @@
+// Package lifecycle orders startup and shutdown of envoy components.
+//
+// Components are started in dependency order and stopped in reverse, each
+// with its own timeout:
+//
+//	m := lifecycle.New(lifecycle.Config{Logger: logger})
+//	m.Add("store", lifecycle.Hooks{OnStart: db.Migrate})
+//	m.Add("router", router, lifecycle.DependsOn("store"))
+//	m.Add("gateway", lifecycle.Service(gw.Run), lifecycle.DependsOn("router"))
+//	err := m.Run(ctx) // blocks until ctx is canceled, then stops everything
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Component is something that can be started and stopped.
+//
+// The ctx passed to Start is canceled when the start timeout elapses, so
+// components that keep running must not tie their lifetime to it.
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Config configures a Manager.
+type Config struct {
+	// StartTimeout is the default per-component start timeout (default: 30s).
+	StartTimeout time.Duration
+
+	// StopTimeout is the default per-component stop timeout (default: 10s).
+	StopTimeout time.Duration
+
+	Logger *slog.Logger
+}
+
+// Option configures a registered component.
+type Option func(*entry)
+
+// DependsOn declares components that must start before, and stop after,
+// this one.
+func DependsOn(names ...string) Option {
+	return func(e *entry) {
+		e.deps = append(e.deps, names...)
+	}
+}
+
+// StartTimeout overrides the start timeout for a component.
+func StartTimeout(d time.Duration) Option {
+	return func(e *entry) {
+		e.startTimeout = d
+	}
+}
+
+// StopTimeout overrides the stop timeout for a component.
+func StopTimeout(d time.Duration) Option {
+	return func(e *entry) {
+		e.stopTimeout = d
+	}
+}
+
+// entry is a registered component.
+type entry struct {
+	name         string
+	component    Component
+	deps         []string
+	startTimeout time.Duration
+	stopTimeout  time.Duration
+}
+
+// Manager starts and stops components in dependency order.
+type Manager struct {
+	config  Config
+	logger  *slog.Logger
+	entries []*entry
+	started []*entry
+	mu      sync.Mutex
+}
+
+// New creates a new Manager.
+func New(config Config) *Manager {
+	if config.StartTimeout == 0 {
+		config.StartTimeout = 30 * time.Second
+	}
+	if config.StopTimeout == 0 {
+		config.StopTimeout = 10 * time.Second
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Manager{
+		config: config,
+		logger: config.Logger,
+	}
+}
+
+// Add registers a component. Components without dependencies between them
+// start in the order they were added.
+func (m *Manager) Add(name string, component Component, opts ...Option) {
+	e := &entry{
+		name:         name,
+		component:    component,
+		startTimeout: m.config.StartTimeout,
+		stopTimeout:  m.config.StopTimeout,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, e)
+}
+
+// Start starts all components in dependency order. If a component fails to
+// start, the components already started are stopped in reverse order and
+// the start error is returned.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.started) > 0 {
+		return errors.New("lifecycle: already started")
+	}
+
+	order, err := resolve(m.entries)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range order {
+		startCtx, cancel := context.WithTimeout(ctx, e.startTimeout)
+		start := time.Now()
+		err := e.component.Start(startCtx)
+		cancel()
+		if err != nil {
+			m.logger.Error("component failed to start", "name", e.name, "error", err)
+			stopErr := m.stopStarted(context.Background())
+			return errors.Join(fmt.Errorf("start %s: %w", e.name, err), stopErr)
+		}
+		m.started = append(m.started, e)
+		m.logger.Info("component started", "name", e.name, "duration", time.Since(start))
+	}
+	return nil
+}
+
+// Stop stops all started components in reverse start order, each with its
+// own timeout. All components are stopped even if some fail.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopStarted(ctx)
+}
+
+// stopStarted stops started components in reverse order. Callers must hold mu.
+func (m *Manager) stopStarted(ctx context.Context) error {
+	var errs []error
+	for i := len(m.started) - 1; i >= 0; i-- {
+		e := m.started[i]
+		stopCtx, cancel := context.WithTimeout(ctx, e.stopTimeout)
+		err := e.component.Stop(stopCtx)
+		cancel()
+		if err != nil {
+			m.logger.Error("component failed to stop", "name", e.name, "error", err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", e.name, err))
+			continue
+		}
+		m.logger.Info("component stopped", "name", e.name)
+	}
+	m.started = nil
+	return errors.Join(errs...)
+}
+
+// Run starts all components, blocks until ctx is canceled or a Service
+// exits with an error, then stops all components.
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-m.failures():
+	}
+
+	stopErr := m.Stop(context.Background())
+	return errors.Join(runErr, stopErr)
+}
+
+// failures merges failure notifications from started services.
+func (m *Manager) failures() <-chan error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(chan error, len(m.started))
+	for _, e := range m.started {
+		if s, ok := e.component.(*service); ok {
+			name := e.name
+			go func() {
+				if err, ok := <-s.failed; ok {
+					out <- fmt.Errorf("%s: %w", name, err)
+				}
+			}()
+		}
+	}
+	return out
+}
+
+// resolve orders entries so that dependencies come first, keeping
+// registration order otherwise.
+func resolve(entries []*entry) ([]*entry, error) {
+	byName := make(map[string]*entry, len(entries))
+	for _, e := range entries {
+		if _, ok := byName[e.name]; ok {
+			return nil, fmt.Errorf("lifecycle: duplicate component %q", e.name)
+		}
+		byName[e.name] = e
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(entries))
+	order := make([]*entry, 0, len(entries))
+
+	var visit func(e *entry) error
+	visit = func(e *entry) error {
+		switch state[e.name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("lifecycle: dependency cycle at %q", e.name)
+		}
+		state[e.name] = visiting
+		for _, dep := range e.deps {
+			d, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("lifecycle: %q depends on unknown component %q", e.name, dep)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[e.name] = done
+		order = append(order, e)
+		return nil
+	}
+
+	for _, e := range entries {
+		if err := visit(e); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,150 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records start/stop calls across components.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) hooks(name string, startErr error) Hooks {
+	return Hooks{
+		OnStart: func(context.Context) error {
+			r.record("start " + name)
+			return startErr
+		},
+		OnStop: func(context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func (r *recorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.calls, ", ")
+}
+
+func TestManagerOrdering(t *testing.T) {
+	rec := &recorder{}
+	m := New(Config{})
+	m.Add("gateway", rec.hooks("gateway", nil), DependsOn("router"))
+	m.Add("router", rec.hooks("router", nil), DependsOn("store"))
+	m.Add("store", rec.hooks("store", nil))
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	want := "start store, start router, start gateway, stop gateway, stop router, stop store"
+	if got := rec.String(); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestManagerStartFailure(t *testing.T) {
+	rec := &recorder{}
+	m := New(Config{})
+	m.Add("store", rec.hooks("store", nil))
+	m.Add("router", rec.hooks("router", errors.New("boom")))
+	m.Add("gateway", rec.hooks("gateway", nil))
+
+	err := m.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "start router: boom") {
+		t.Fatalf("Start error = %v, want router failure", err)
+	}
+
+	want := "start store, start router, stop store"
+	if got := rec.String(); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestManagerResolveErrors(t *testing.T) {
+	m := New(Config{})
+	m.Add("a", Hooks{}, DependsOn("b"))
+	m.Add("b", Hooks{}, DependsOn("a"))
+	if err := m.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+
+	m = New(Config{})
+	m.Add("a", Hooks{}, DependsOn("missing"))
+	if err := m.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected unknown dependency error, got %v", err)
+	}
+}
+
+func TestManagerStopTimeout(t *testing.T) {
+	m := New(Config{})
+	m.Add("slow", Hooks{OnStop: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}, StopTimeout(10*time.Millisecond))
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := m.Stop(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestManagerRunServiceFailure(t *testing.T) {
+	rec := &recorder{}
+	m := New(Config{})
+	m.Add("router", rec.hooks("router", nil))
+	m.Add("gateway", Service(func(ctx context.Context) error {
+		return errors.New("listen failed")
+	}), DependsOn("router"))
+
+	err := m.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "gateway: listen failed") {
+		t.Fatalf("Run error = %v, want gateway failure", err)
+	}
+	if got := rec.String(); got != "start router, stop router" {
+		t.Errorf("calls = %s", got)
+	}
+}
+
+func TestManagerRunCancel(t *testing.T) {
+	m := New(Config{})
+	stopped := make(chan struct{})
+	m.Add("gateway", Service(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("service was not stopped")
+	}
+}
@@ -0,0 +1,163 @@
+package markup
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// TelegramV2 renders Telegram MarkdownV2, escaping the characters it
+// reserves. Headings become bold and list bullets "•".
+var TelegramV2 Dialect = &dialect{
+	format:  FormatMarkdownV2,
+	text:    telegramEscaper.Replace,
+	bold:    wrap("*", "*"),
+	italic:  wrap("_", "_"),
+	strike:  wrap("~", "~"),
+	code:    func(s string) string { return "`" + telegramCodeEscaper.Replace(s) + "`" },
+	link:    func(text, url string) string { return "[" + text + "](" + telegramURLEscaper.Replace(url) + ")" },
+	heading: func(_ int, s string) string { return "*" + s + "*" },
+	quote:   func(s string) string { return ">" + s },
+	bullet:  "• ",
+	ordered: func(n string) string { return n + "\\. " },
+	codeBlock: func(lang, code string) string {
+		return "```" + lang + "\n" + telegramCodeEscaper.Replace(code) + "\n```"
+	},
+}
+
+var (
+	telegramEscaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`)
+	telegramCodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	telegramURLEscaper  = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+// Discord renders Discord markdown. Headings deeper than Discord supports
+// become bold.
+var Discord Dialect = &dialect{
+	format: FormatMarkdown,
+	text:   discordEscaper.Replace,
+	bold:   wrap("**", "**"),
+	italic: wrap("*", "*"),
+	strike: wrap("~~", "~~"),
+	code:   markdownCode,
+	link:   func(text, url string) string { return "[" + text + "](" + url + ")" },
+	heading: func(level int, s string) string {
+		if level > 3 {
+			return "**" + s + "**"
+		}
+		return strings.Repeat("#", level) + " " + s
+	},
+	quote:   func(s string) string { return "> " + s },
+	bullet:  "- ",
+	ordered: func(n string) string { return n + ". " },
+	codeBlock: func(lang, code string) string {
+		return "```" + lang + "\n" + code + "\n```"
+	},
+}
+
+var discordEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`)
+
+// Slack renders Slack mrkdwn. Headings become bold, and links use Slack's
+// "<url|text>" syntax.
+var Slack Dialect = &dialect{
+	format:  FormatMarkdown,
+	text:    slackText,
+	bold:    wrap("*", "*"),
+	italic:  wrap("_", "_"),
+	strike:  wrap("~", "~"),
+	code:    markdownCode,
+	link:    func(text, url string) string { return "<" + slackEscaper.Replace(url) + "|" + text + ">" },
+	heading: func(_ int, s string) string { return "*" + s + "*" },
+	quote:   func(s string) string { return "> " + s },
+	bullet:  "• ",
+	ordered: func(n string) string { return n + ". " },
+	codeBlock: func(_, code string) string {
+		return "```\n" + slackEscaper.Replace(code) + "\n```"
+	},
+}
+
+var (
+	slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+	// slackEntity matches Slack mentions, such as "<@U123>" and
+	// "<!here>", which are kept as is.
+	slackEntity = regexp.MustCompile(`<[@#!][^<>\s]+>`)
+)
+
+// slackText escapes text except Slack entities.
+func slackText(s string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range slackEntity.FindAllStringIndex(s, -1) {
+		sb.WriteString(slackEscaper.Replace(s[last:loc[0]]))
+		sb.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(slackEscaper.Replace(s[last:]))
+	return sb.String()
+}
+
+// HTML renders the HTML subset chat platforms commonly accept, such as
+// Telegram's: headings become bold, and lines are separated by newlines
+// rather than tags.
+var HTML Dialect = &dialect{
+	format:  FormatHTML,
+	text:    html.EscapeString,
+	bold:    wrap("<b>", "</b>"),
+	italic:  wrap("<i>", "</i>"),
+	strike:  wrap("<s>", "</s>"),
+	code:    func(s string) string { return "<code>" + html.EscapeString(s) + "</code>" },
+	link:    func(text, url string) string { return `<a href="` + html.EscapeString(url) + `">` + text + "</a>" },
+	heading: func(_ int, s string) string { return "<b>" + s + "</b>" },
+	quote:   wrap("<blockquote>", "</blockquote>"),
+	bullet:  "• ",
+	ordered: func(n string) string { return n + ". " },
+	codeBlock: func(lang, code string) string {
+		if lang == "" {
+			return "<pre>" + html.EscapeString(code) + "</pre>"
+		}
+		return `<pre><code class="language-` + html.EscapeString(lang) + `">` + html.EscapeString(code) + "</code></pre>"
+	},
+}
+
+// Plain removes formatting, keeping link targets in parentheses.
+var Plain Dialect = &dialect{
+	format:  FormatPlain,
+	text:    func(s string) string { return s },
+	bold:    func(s string) string { return s },
+	italic:  func(s string) string { return s },
+	strike:  func(s string) string { return s },
+	code:    func(s string) string { return s },
+	link:    plainLink,
+	heading: func(_ int, s string) string { return s },
+	quote:   func(s string) string { return "> " + s },
+	bullet:  "• ",
+	ordered: func(n string) string { return n + ". " },
+	codeBlock: func(_, code string) string {
+		return code
+	},
+}
+
+// plainLink returns a link's text followed by its target.
+func plainLink(text, url string) string {
+	if text == url {
+		return url
+	}
+	return text + " (" + url + ")"
+}
+
+// markdownCode renders a code span, lengthening the delimiter when the code
+// contains backticks.
+func markdownCode(s string) string {
+	delim := "`"
+	for strings.Contains(s, delim) {
+		delim += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return delim + s + delim
+}
@@ -0,0 +1,161 @@
+// Package markup converts canonical Markdown into the formatting dialects
+// of messaging platforms.
+//
+// Agents and handlers write messages in common Markdown (**bold**,
+// *italic*, ~~strike~~, `code`, fenced code blocks, [links](url), headings,
+// quotes, and lists) with channels.MessageFormatMarkdown. The router renders
+// the content for each platform when it sends the message: Telegram
+// MarkdownV2 with its escaping rules, Discord markdown, or Slack mrkdwn.
+// Other platforms receive the content unchanged unless a dialect is
+// registered for them, such as HTML:
+//
+//	markup.Register("matrix", markup.HTML)
+package markup
+
+import (
+	"html"
+	"strings"
+	"sync"
+)
+
+// Format is the message format rendered content is in. It mirrors
+// channels.MessageFormat.
+type Format string
+
+const (
+	FormatPlain      Format = "plain"
+	FormatMarkdown   Format = "markdown"
+	FormatMarkdownV2 Format = "markdown_v2"
+	FormatHTML       Format = "html"
+)
+
+// Dialect renders a parsed Markdown document for a platform.
+type Dialect interface {
+	// Format returns the format of the rendered content.
+	Format() Format
+
+	// Render returns the document in the dialect's syntax.
+	Render(doc Document) string
+}
+
+var (
+	mu       sync.RWMutex
+	dialects = map[string]Dialect{
+		"discord":  Discord,
+		"slack":    Slack,
+		"telegram": TelegramV2,
+	}
+)
+
+// Register sets the dialect of a platform (channel name), replacing any
+// built-in one. A nil dialect sends the platform's Markdown unchanged.
+func Register(platform string, d Dialect) {
+	mu.Lock()
+	defer mu.Unlock()
+	if d == nil {
+		delete(dialects, platform)
+		return
+	}
+	dialects[platform] = d
+}
+
+// Render converts Markdown content into the platform's dialect and returns
+// it with its format. Platforms without a dialect get the content unchanged
+// as FormatMarkdown.
+func Render(platform, content string) (string, Format) {
+	mu.RLock()
+	d, ok := dialects[platform]
+	mu.RUnlock()
+	if !ok {
+		return content, FormatMarkdown
+	}
+	return d.Render(Parse(content)), d.Format()
+}
+
+// Escape returns text escaped to read literally in content of format, such
+// as a marker appended to rendered content.
+func Escape(format Format, text string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return telegramEscaper.Replace(text)
+	case FormatHTML:
+		return html.EscapeString(text)
+	}
+	return text
+}
+
+// dialect is a Dialect built from per-node renderers.
+type dialect struct {
+	format Format
+
+	text      func(s string) string
+	bold      func(s string) string
+	italic    func(s string) string
+	strike    func(s string) string
+	code      func(s string) string
+	link      func(text, url string) string
+	codeBlock func(lang, code string) string
+	heading   func(level int, s string) string
+	quote     func(s string) string
+	bullet    string
+	ordered   func(n string) string
+}
+
+func (d *dialect) Format() Format {
+	return d.format
+}
+
+func (d *dialect) Render(doc Document) string {
+	lines := make([]string, len(doc))
+	for i, b := range doc {
+		lines[i] = d.block(b)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// block renders a block.
+func (d *dialect) block(b Block) string {
+	switch b.Kind {
+	case BlockCode:
+		return d.codeBlock(b.Lang, b.Text)
+	case BlockHeading:
+		return d.heading(b.Level, d.inlines(b.Inlines))
+	case BlockQuote:
+		return d.quote(d.inlines(b.Inlines))
+	case BlockBullet:
+		return b.Indent + d.bullet + d.inlines(b.Inlines)
+	case BlockOrdered:
+		return b.Indent + d.ordered(b.Number) + d.inlines(b.Inlines)
+	default:
+		return d.inlines(b.Inlines)
+	}
+}
+
+// inlines renders a run of inline nodes.
+func (d *dialect) inlines(nodes []Inline) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		switch n.Kind {
+		case InlineBold:
+			sb.WriteString(d.bold(d.inlines(n.Children)))
+		case InlineItalic:
+			sb.WriteString(d.italic(d.inlines(n.Children)))
+		case InlineStrike:
+			sb.WriteString(d.strike(d.inlines(n.Children)))
+		case InlineCode:
+			sb.WriteString(d.code(n.Text))
+		case InlineLink:
+			sb.WriteString(d.link(d.inlines(n.Children), n.URL))
+		default:
+			sb.WriteString(d.text(n.Text))
+		}
+	}
+	return sb.String()
+}
+
+// wrap returns a renderer surrounding content with a delimiter.
+func wrap(open, closing string) func(string) string {
+	return func(s string) string {
+		return open + s + closing
+	}
+}
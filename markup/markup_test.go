@@ -0,0 +1,51 @@
+package markup
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	content := "# Result\n**Total:** 4.5 (see [docs](https://example.com/a_(b))) for *snake_case* & ~~old~~ `a*b`\n- item one\n2. second\n> quoted\n```go\nx := `y`\n```"
+
+	tests := []struct {
+		platform string
+		want     string
+		format   Format
+	}{
+		{"telegram", "*Result*\n*Total:* 4\\.5 \\(see [docs](https://example.com/a_(b\\))\\) for _snake\\_case_ & ~old~ `a*b`\n• item one\n2\\. second\n>quoted\n```go\nx := \\`y\\`\n```", FormatMarkdownV2},
+		{"discord", "# Result\n**Total:** 4.5 (see [docs](https://example.com/a_(b))) for *snake\\_case* & ~~old~~ `a*b`\n- item one\n2. second\n> quoted\n```go\nx := `y`\n```", FormatMarkdown},
+		{"slack", "*Result*\n*Total:* 4.5 (see <https://example.com/a_(b)|docs>) for _snake_case_ &amp; ~old~ `a*b`\n• item one\n2. second\n> quoted\n```\nx := `y`\n```", FormatMarkdown},
+		{"matrix", content, FormatMarkdown},
+	}
+	for _, tt := range tests {
+		got, format := Render(tt.platform, content)
+		if got != tt.want || format != tt.format {
+			t.Errorf("Render(%s) = %q, %s\nwant %q, %s", tt.platform, got, format, tt.want, tt.format)
+		}
+	}
+}
+
+func TestDialects(t *testing.T) {
+	doc := Parse("**a <b>** and [link](https://x.io?a=1&b=2)\n```\n<tag>\n```")
+	if got, want := HTML.Render(doc), `<b>a &lt;b&gt;</b> and <a href="https://x.io?a=1&amp;b=2">link</a>`+"\n<pre>&lt;tag&gt;</pre>"; got != want {
+		t.Errorf("HTML = %q, want %q", got, want)
+	}
+	if got, want := Plain.Render(doc), "a <b> and link (https://x.io?a=1&b=2)\n<tag>"; got != want {
+		t.Errorf("Plain = %q, want %q", got, want)
+	}
+}
+
+func TestParseKeepsText(t *testing.T) {
+	for _, s := range []string{"2 * 3 * 4", "snake_case_name", "**unclosed", "[not a link]", "a \\*literal\\*"} {
+		doc := Parse(s)
+		if len(doc) != 1 || len(doc[0].Inlines) != 1 || doc[0].Inlines[0].Kind != InlineText {
+			t.Errorf("Parse(%q) = %+v, want text", s, doc)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("matrix", HTML)
+	defer Register("matrix", nil)
+	if got, format := Render("matrix", "*hi*"); got != "<i>hi</i>" || format != FormatHTML {
+		t.Errorf("Render = %q, %s", got, format)
+	}
+}
@@ -0,0 +1,289 @@
+package markup
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Document is parsed Markdown: one block per line, except code blocks.
+type Document []Block
+
+// BlockKind is the kind of a block.
+type BlockKind int
+
+const (
+	BlockLine BlockKind = iota
+	BlockHeading
+	BlockQuote
+	BlockBullet
+	BlockOrdered
+	BlockCode
+)
+
+// Block is a line of text or a fenced code block.
+type Block struct {
+	Kind    BlockKind
+	Inlines []Inline
+
+	// Level is the level of a heading.
+	Level int
+
+	// Indent is the leading whitespace of a list item.
+	Indent string
+
+	// Number is the number of an ordered list item.
+	Number string
+
+	// Lang and Text are the language and content of a code block.
+	Lang string
+	Text string
+}
+
+// InlineKind is the kind of an inline node.
+type InlineKind int
+
+const (
+	InlineText InlineKind = iota
+	InlineBold
+	InlineItalic
+	InlineStrike
+	InlineCode
+	InlineLink
+)
+
+// Inline is a span of text or formatting.
+type Inline struct {
+	Kind InlineKind
+
+	// Text is the content of text and code nodes.
+	Text string
+
+	// Children are the content of formatting and link nodes.
+	Children []Inline
+
+	// URL is the target of a link.
+	URL string
+}
+
+// Parse parses Markdown content. Unclosed formatting is kept as text, and
+// an unclosed code block runs to the end of the content.
+func Parse(content string) Document {
+	var doc Document
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fence, ok := strings.CutPrefix(strings.TrimSpace(line), "```"); ok {
+			var code []string
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+				code = append(code, lines[i])
+			}
+			doc = append(doc, Block{Kind: BlockCode, Lang: strings.TrimSpace(fence), Text: strings.Join(code, "\n")})
+			continue
+		}
+		doc = append(doc, parseLine(line))
+	}
+	return doc
+}
+
+// parseLine parses a line outside code blocks.
+func parseLine(line string) Block {
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+
+	if level := headingLevel(trimmed); level > 0 {
+		return Block{Kind: BlockHeading, Level: level, Inlines: parseInlines(strings.TrimSpace(trimmed[level:]))}
+	}
+	if rest, ok := strings.CutPrefix(trimmed, ">"); ok {
+		return Block{Kind: BlockQuote, Inlines: parseInlines(strings.TrimPrefix(rest, " "))}
+	}
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if rest, ok := strings.CutPrefix(trimmed, bullet); ok {
+			return Block{Kind: BlockBullet, Indent: indent, Inlines: parseInlines(rest)}
+		}
+	}
+	if n := leadingDigits(trimmed); n > 0 && strings.HasPrefix(trimmed[n:], ". ") {
+		return Block{Kind: BlockOrdered, Indent: indent, Number: trimmed[:n], Inlines: parseInlines(trimmed[n+2:])}
+	}
+	return Block{Kind: BlockLine, Inlines: parseInlines(line)}
+}
+
+// headingLevel returns the level of an ATX heading line, or 0.
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level >= len(line) || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
+
+// leadingDigits returns the number of ASCII digits line starts with.
+func leadingDigits(line string) int {
+	n := 0
+	for n < len(line) && n < 9 && line[n] >= '0' && line[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// parseInlines parses the inline formatting of text.
+func parseInlines(s string) []Inline {
+	var (
+		nodes []Inline
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, Inline{Kind: InlineText, Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if node, n := parseSpan(s, i); n > 0 {
+			flush()
+			nodes = append(nodes, node)
+			i += n
+			continue
+		}
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		text.WriteString(s[i : i+size])
+		i += size
+	}
+	flush()
+	return nodes
+}
+
+// parseSpan parses a formatting span or link starting at s[i]. It returns
+// the number of bytes consumed, or 0 if none starts there.
+func parseSpan(s string, i int) (Inline, int) {
+	rest := s[i:]
+	switch {
+	case rest[0] == '`':
+		run := len(rest) - len(strings.TrimLeft(rest, "`"))
+		delim := rest[:run]
+		if end := strings.Index(rest[run:], delim); end >= 0 {
+			code := rest[run : run+end]
+			if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			return Inline{Kind: InlineCode, Text: code}, run + end + run
+		}
+	case strings.HasPrefix(rest, "**"), strings.HasPrefix(rest, "__"):
+		return delimited(s, i, rest[:2], InlineBold)
+	case strings.HasPrefix(rest, "~~"):
+		return delimited(s, i, "~~", InlineStrike)
+	case rest[0] == '*', rest[0] == '_':
+		return delimited(s, i, rest[:1], InlineItalic)
+	case rest[0] == '[':
+		return parseLink(rest)
+	}
+	return Inline{}, 0
+}
+
+// delimited parses a span enclosed in delim starting at s[i]. Spans must
+// not start or end with a space, and underscores must not be inside words,
+// so "2 * 3" and snake_case stay text.
+func delimited(s string, i int, delim string, kind InlineKind) (Inline, int) {
+	open := i + len(delim)
+	if open >= len(s) || s[open] == ' ' {
+		return Inline{}, 0
+	}
+	if delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+		return Inline{}, 0
+	}
+	for j := open + 1; j+len(delim) <= len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == '`' {
+			// Delimiters inside code spans do not close the span
+			if _, n := parseSpan(s, j); n > 0 {
+				j += n - 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(s[j:], delim) || s[j-1] == ' ' {
+			continue
+		}
+		end := j + len(delim)
+		if len(delim) == 1 && end < len(s) && s[end] == delim[0] {
+			// "*a **b** c*": skip the inner bold delimiter
+			j++
+			continue
+		}
+		if delim[0] == '_' && end < len(s) && isWordByte(s[end]) {
+			continue
+		}
+		return Inline{Kind: kind, Children: parseInlines(s[open:j])}, end - i
+	}
+	return Inline{}, 0
+}
+
+// parseLink parses "[text](url)" at the start of s.
+func parseLink(s string) (Inline, int) {
+	depth := 0
+	for j := 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if j+1 >= len(s) || s[j+1] != '(' {
+				return Inline{}, 0
+			}
+			end := closingParen(s[j+2:])
+			if end < 0 {
+				return Inline{}, 0
+			}
+			url := s[j+2 : j+2+end]
+			if url == "" || strings.ContainsAny(url, " \t") {
+				return Inline{}, 0
+			}
+			return Inline{Kind: InlineLink, Children: parseInlines(s[1:j]), URL: url}, j + 2 + end + 1
+		}
+	}
+	return Inline{}, 0
+}
+
+// closingParen returns the index of the parenthesis closing a link target,
+// allowing balanced parentheses within it, or -1.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// isPunct reports whether Markdown allows escaping b.
+func isPunct(b byte) bool {
+	return b < utf8.RuneSelf && unicode.IsPunct(rune(b)) || strings.IndexByte("$+<=>^`|~", b) >= 0
+}
+
+// isWordByte reports whether b is part of a word for intraword emphasis.
+func isWordByte(b byte) bool {
+	return b >= utf8.RuneSelf || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
@@ -0,0 +1,188 @@
+package media
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the default size of a MemoryCache in bytes.
+const DefaultCacheSize = 64 << 20
+
+// Cache keeps fetched media by key.
+type Cache interface {
+	Get(key string) (*File, bool)
+	Put(key string, file *File)
+}
+
+// MemoryCache is an in-memory Cache evicting the least recently used
+// media beyond its size.
+type MemoryCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key  string
+	file *File
+}
+
+// NewMemoryCache returns a MemoryCache holding up to maxSize bytes.
+func NewMemoryCache(maxSize int64) *MemoryCache {
+	return &MemoryCache{maxSize: maxSize, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Get returns cached media and marks it recently used.
+func (c *MemoryCache) Get(key string) (*File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*memoryEntry).file, true
+}
+
+// Put caches media, evicting the least recently used beyond the size.
+// Media larger than the cache is not cached.
+func (c *MemoryCache) Put(key string, file *File) {
+	size := int64(len(file.Data))
+	if size > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.size -= int64(len(e.Value.(*memoryEntry).file.Data))
+		c.order.Remove(e)
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, file: file})
+	c.size += size
+	for c.size > c.maxSize {
+		e := c.order.Back()
+		entry := e.Value.(*memoryEntry)
+		c.order.Remove(e)
+		delete(c.entries, entry.key)
+		c.size -= int64(len(entry.file.Data))
+	}
+}
+
+// DiskCache is a Cache in a directory, evicting the least recently used
+// media beyond its size. It survives restarts and may be shared by
+// processes.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+}
+
+// diskMeta is stored next to the cached content.
+type diskMeta struct {
+	MimeType string `json:"mime_type"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// NewDiskCache returns a DiskCache in dir holding up to maxSize bytes.
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir, maxSize: maxSize}, nil
+}
+
+// path returns the content path of a key.
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns cached media and marks it recently used.
+func (c *DiskCache) Get(key string) (*File, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	raw, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, false
+	}
+	var meta diskMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return &File{Data: data, MimeType: meta.MimeType, Filename: meta.Filename}, true
+}
+
+// Put caches media, evicting the least recently used beyond the size.
+// Write errors leave the media uncached.
+func (c *DiskCache) Put(key string, file *File) {
+	if int64(len(file.Data)) > c.maxSize {
+		return
+	}
+	raw, err := json.Marshal(diskMeta{MimeType: file.MimeType, Filename: file.Filename})
+	if err != nil {
+		return
+	}
+	path := c.path(key)
+	if err := os.WriteFile(path+".json", raw, 0o600); err != nil {
+		return
+	}
+	if err := os.WriteFile(path, file.Data, 0o600); err != nil {
+		os.Remove(path + ".json")
+		return
+	}
+	c.evict()
+}
+
+// evict removes the least recently used media beyond the size.
+func (c *DiskCache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	var files []os.FileInfo
+	var size int64
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, info := range files {
+		if size <= c.maxSize {
+			break
+		}
+		path := filepath.Join(c.dir, info.Name())
+		os.Remove(path)
+		os.Remove(path + ".json")
+		size -= info.Size()
+	}
+}
+
+var (
+	_ Cache = (*MemoryCache)(nil)
+	_ Cache = (*DiskCache)(nil)
+)
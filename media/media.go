@@ -0,0 +1,240 @@
+// Package media downloads the media attached to messages, so agents can
+// read images and documents users send.
+//
+// A Fetcher resolves Media by inline data, URL (such as Discord CDN
+// attachments), or platform file ID through channels implementing
+// channels.MediaDownloader (Telegram), enforcing a size limit and caching
+// the results:
+//
+//	fetcher := media.New(media.Config{Channels: router})
+//	files, err := fetcher.FetchMessage(ctx, msg)
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DefaultMaxSize is the default download size limit, Telegram's limit for
+// bot downloads.
+const DefaultMaxSize = 20 << 20
+
+// ErrTooLarge is returned for media exceeding the size limit.
+var ErrTooLarge = errors.New("media exceeds size limit")
+
+// ChannelLookup returns registered channels by name. *channels.Router
+// implements it.
+type ChannelLookup interface {
+	GetChannel(name string) (channels.Channel, bool)
+}
+
+// Config configures a Fetcher.
+type Config struct {
+	// Channels looks up the channels downloading media by file ID.
+	Channels ChannelLookup
+
+	// Client downloads URLs (default: http.DefaultClient).
+	Client *http.Client
+
+	// MaxSize is the largest media downloaded, in bytes (default:
+	// DefaultMaxSize).
+	MaxSize int64
+
+	// Cache keeps fetched media (default: a MemoryCache of
+	// DefaultCacheSize bytes).
+	Cache Cache
+}
+
+// File is fetched media content.
+type File struct {
+	Data []byte
+
+	// MimeType is the declared MIME type, or sniffed from the content.
+	MimeType string
+
+	Filename string
+}
+
+// Fetcher downloads media.
+type Fetcher struct {
+	config Config
+}
+
+// New returns a Fetcher.
+func New(config Config) *Fetcher {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultMaxSize
+	}
+	if config.Cache == nil {
+		config.Cache = NewMemoryCache(DefaultCacheSize)
+	}
+	return &Fetcher{config: config}
+}
+
+// FetchMessage fetches the media attached to a message.
+func (f *Fetcher) FetchMessage(ctx context.Context, msg channels.IncomingMessage) ([]*File, error) {
+	files := make([]*File, 0, len(msg.Media))
+	for _, m := range msg.Media {
+		file, err := f.Fetch(ctx, msg.ChannelName, m)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Fetch returns the content of media received on the named channel,
+// from the cache if possible.
+func (f *Fetcher) Fetch(ctx context.Context, channelName string, m channels.Media) (*File, error) {
+	key := cacheKey(channelName, m)
+	if key != "" {
+		if file, ok := f.config.Cache.Get(key); ok {
+			return file, nil
+		}
+	}
+
+	r, err := f.Open(ctx, channelName, m)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{Data: data, MimeType: m.MimeType, Filename: m.Filename}
+	if file.MimeType == "" || file.MimeType == "application/octet-stream" {
+		file.MimeType = http.DetectContentType(data)
+	}
+	if key != "" {
+		f.config.Cache.Put(key, file)
+	}
+	return file, nil
+}
+
+// Open streams the content of media received on the named channel,
+// bypassing the cache. Reads fail with ErrTooLarge past the size limit.
+func (f *Fetcher) Open(ctx context.Context, channelName string, m channels.Media) (io.ReadCloser, error) {
+	if m.Size > f.config.MaxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, m.Size)
+	}
+
+	var r io.ReadCloser
+	var err error
+	switch {
+	case len(m.Data) > 0:
+		r = io.NopCloser(bytes.NewReader(m.Data))
+	case m.URL != "":
+		r, err = f.download(ctx, m.URL)
+	case m.FileID != "":
+		r, err = f.downloadFile(ctx, channelName, m.FileID)
+	default:
+		err = errors.New("media has no data, URL, or file ID")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &limitedReader{ReadCloser: r, remaining: f.config.MaxSize}, nil
+}
+
+// download opens a URL.
+func (f *Fetcher) download(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := f.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download media: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download media: %s", resp.Status)
+	}
+	if resp.ContentLength > f.config.MaxSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+	return resp.Body, nil
+}
+
+// downloadFile opens a platform file through its channel.
+func (f *Fetcher) downloadFile(ctx context.Context, channelName, fileID string) (io.ReadCloser, error) {
+	if f.config.Channels == nil {
+		return nil, fmt.Errorf("no channels to download file IDs from")
+	}
+	channel, ok := f.config.Channels.GetChannel(channelName)
+	if !ok {
+		return nil, fmt.Errorf("channel not found: %s", channelName)
+	}
+	downloader, ok := channel.(channels.MediaDownloader)
+	if !ok {
+		return nil, fmt.Errorf("%s does not download media by file ID", channelName)
+	}
+	r, err := downloader.DownloadMedia(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("download media: %w", err)
+	}
+	return r, nil
+}
+
+// signedHosts are CDN hosts whose media URLs carry expiring signatures in
+// the query, so the same file is served under changing URLs.
+var signedHosts = map[string]bool{
+	"cdn.discordapp.com":   true,
+	"media.discordapp.net": true,
+}
+
+// cacheKey identifies media across messages: by file ID, or by URL. The
+// query is dropped from URLs of signedHosts; on other hosts it may select
+// the file. Inline data is not cached.
+func cacheKey(channelName string, m channels.Media) string {
+	switch {
+	case len(m.Data) > 0:
+		return ""
+	case m.URL != "":
+		if u, err := url.Parse(m.URL); err == nil && signedHosts[strings.ToLower(u.Hostname())] {
+			u.RawQuery, u.Fragment = "", ""
+			return u.String()
+		}
+		return m.URL
+	case m.FileID != "":
+		return channelName + ":" + m.FileID
+	}
+	return ""
+}
+
+// limitedReader fails reads past a size limit.
+type limitedReader struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	// Read one byte past the limit to detect oversized content
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	return n, err
+}
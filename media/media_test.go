@@ -0,0 +1,165 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// fileChannel is a channel downloading files by ID.
+type fileChannel struct {
+	channels.Channel
+	files map[string][]byte
+}
+
+func (c fileChannel) DownloadMedia(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	data, ok := c.files[fileID]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+type lookup map[string]channels.Channel
+
+func (l lookup) GetChannel(name string) (channels.Channel, bool) {
+	c, ok := l[name]
+	return c, ok
+}
+
+func TestFetch(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/image":
+			w.Write(png)
+		case "/large":
+			w.Header().Set("Content-Length", "100")
+			w.Write(bytes.Repeat([]byte("x"), 100))
+		case "/stream":
+			// Chunked, without a length
+			w.Write(bytes.Repeat([]byte("x"), 60))
+			w.(http.Flusher).Flush()
+			w.Write(bytes.Repeat([]byte("x"), 60))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := New(Config{
+		Channels: lookup{"telegram": fileChannel{files: map[string][]byte{"abc": []byte("%PDF-1.4 report")}}},
+		MaxSize:  50,
+	})
+	ctx := context.Background()
+
+	// Signed Discord URLs share a key across signatures, see TestCacheKey
+	for i := 0; i < 2; i++ {
+		file, err := f.Fetch(ctx, "discord", channels.Media{URL: server.URL + "/image?ex=1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.MimeType != "image/png" || !bytes.Equal(file.Data, png) {
+			t.Errorf("file = %q, %s", file.Data, file.MimeType)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("downloads = %d, want 1 (cached)", n)
+	}
+
+	file, err := f.Fetch(ctx, "telegram", channels.Media{FileID: "abc", Filename: "report.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.MimeType != "application/pdf" || file.Filename != "report.pdf" {
+		t.Errorf("file = %+v", file)
+	}
+
+	for _, m := range []channels.Media{
+		{URL: server.URL + "/large"},
+		{URL: server.URL + "/stream"},
+		{URL: server.URL + "/unfetched", Size: 51},
+	} {
+		if _, err := f.Fetch(ctx, "discord", m); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("Fetch(%s) = %v, want ErrTooLarge", m.URL, err)
+		}
+	}
+
+	for _, m := range []channels.Media{
+		{URL: server.URL + "/missing"},
+		{FileID: "abc"},
+		{},
+	} {
+		if _, err := f.Fetch(ctx, "discord", m); err == nil {
+			t.Errorf("Fetch(%+v) succeeded", m)
+		}
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(10)
+	c.Put("a", &File{Data: []byte("aaaa")})
+	c.Put("b", &File{Data: []byte("bbbb")})
+	c.Get("a")
+	c.Put("c", &File{Data: []byte("cccc")})
+	c.Put("huge", &File{Data: []byte(strings.Repeat("h", 11))})
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "huge": false} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%s) cached = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("a", &File{Data: []byte("aaaa"), MimeType: "text/plain", Filename: "a.txt"})
+	file, ok := c.Get("a")
+	if !ok || string(file.Data) != "aaaa" || file.MimeType != "text/plain" || file.Filename != "a.txt" {
+		t.Fatalf("Get(a) = %+v, %v", file, ok)
+	}
+
+	// A new cache on the same directory sees the media
+	c, _ = NewDiskCache(dir, 10)
+	c.Put("b", &File{Data: []byte("bbbbbbbb")})
+	if _, ok := c.Get("a"); ok {
+		t.Error("a not evicted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("b not cached")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		name string
+		m    channels.Media
+		want string
+	}{
+		{"discord signed", channels.Media{URL: "https://cdn.discordapp.com/attachments/1/2/a.png?ex=1&is=2&hm=3"}, "https://cdn.discordapp.com/attachments/1/2/a.png"},
+		{"discord proxy", channels.Media{URL: "https://media.discordapp.net/attachments/1/2/a.png?ex=1"}, "https://media.discordapp.net/attachments/1/2/a.png"},
+		{"query selects file", channels.Media{URL: "https://example.com/download?id=7"}, "https://example.com/download?id=7"},
+		{"file id", channels.Media{FileID: "abc"}, "telegram:abc"},
+		{"inline", channels.Media{Data: []byte("x"), URL: "https://example.com/a"}, ""},
+	}
+	for _, tt := range tests {
+		if got := cacheKey("telegram", tt.m); got != tt.want {
+			t.Errorf("%s: cacheKey = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
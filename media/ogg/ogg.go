@@ -0,0 +1,218 @@
+// Package ogg reads and writes Ogg Opus streams (RFC 7845), the audio
+// format of Telegram voice notes and of tts.FormatOpus speech, so
+// Opus packets from voice chats can be transcribed and synthesized speech
+// can be played into them.
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// MimeType is the MIME type of Ogg Opus streams.
+const MimeType = "audio/ogg"
+
+// SilenceFrame is the Opus packet of 20ms of silence that voice clients
+// send when they stop talking.
+var SilenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+// Ogg page header flags.
+const (
+	flagContinued = 0x01
+	flagFirst     = 0x02
+	flagLast      = 0x04
+)
+
+// serial is the stream serial number of written streams.
+const serial = 0x656e7679
+
+// EncodeOpus wraps Opus packets in an Ogg Opus stream with the given number
+// of channels (1 or 2).
+func EncodeOpus(packets [][]byte, channels int) []byte {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = byte(channels)
+	binary.LittleEndian.PutUint32(head[12:], 48000)
+
+	tags := []byte("OpusTags")
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len("envoy")))
+	tags = append(tags, "envoy"...)
+	tags = binary.LittleEndian.AppendUint32(tags, 0)
+
+	var buf bytes.Buffer
+	seq := uint32(0)
+	writePage(&buf, head, flagFirst, 0, seq)
+	seq++
+	flags := byte(0)
+	if len(packets) == 0 {
+		flags = flagLast
+	}
+	writePage(&buf, tags, flags, 0, seq)
+	seq++
+
+	var granule int64
+	for i, p := range packets {
+		granule += int64(PacketSamples(p))
+		flags := byte(0)
+		if i == len(packets)-1 {
+			flags = flagLast
+		}
+		writePage(&buf, p, flags, granule, seq)
+		seq++
+	}
+	return buf.Bytes()
+}
+
+// writePage writes a page holding one packet.
+func writePage(buf *bytes.Buffer, packet []byte, flags byte, granule int64, seq uint32) {
+	var lacing []byte
+	n := len(packet)
+	for ; n >= 255; n -= 255 {
+		lacing = append(lacing, 255)
+	}
+	lacing = append(lacing, byte(n))
+
+	page := make([]byte, 27, 27+len(lacing)+len(packet))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], serial)
+	binary.LittleEndian.PutUint32(page[18:], seq)
+	page[26] = byte(len(lacing))
+	page = append(page, lacing...)
+	page = append(page, packet...)
+	binary.LittleEndian.PutUint32(page[22:], checksum(page))
+	buf.Write(page)
+}
+
+// DecodeOpus returns the audio packets of the first Opus stream in an Ogg
+// stream.
+func DecodeOpus(data []byte) ([][]byte, error) {
+	var packets [][]byte
+	var partial []byte
+	var stream uint32
+	headers := 0
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" {
+			return nil, errors.New("not an Ogg stream")
+		}
+		segments := int(data[26])
+		if len(data) < 27+segments {
+			return nil, errors.New("truncated Ogg page")
+		}
+		lacing := data[27 : 27+segments]
+		size := 0
+		for _, l := range lacing {
+			size += int(l)
+		}
+		end := 27 + segments + size
+		if len(data) < end {
+			return nil, errors.New("truncated Ogg page")
+		}
+		page := data[:end]
+		data = data[end:]
+
+		pageSerial := binary.LittleEndian.Uint32(page[14:])
+		if page[5]&flagFirst != 0 && headers == 0 {
+			stream = pageSerial
+		}
+		if pageSerial != stream {
+			continue
+		}
+		if page[5]&flagContinued == 0 {
+			partial = nil
+		}
+
+		body := page[27+segments:]
+		for _, l := range lacing {
+			partial = append(partial, body[:l]...)
+			body = body[l:]
+			if l == 255 {
+				continue
+			}
+			switch headers {
+			case 0:
+				if !bytes.HasPrefix(partial, []byte("OpusHead")) {
+					return nil, errors.New("not an Ogg Opus stream")
+				}
+				headers++
+			case 1:
+				headers++ // OpusTags
+			default:
+				packets = append(packets, partial)
+			}
+			partial = nil
+		}
+	}
+	if headers == 0 {
+		return nil, errors.New("empty Ogg stream")
+	}
+	return packets, nil
+}
+
+// frameSamples are the samples per frame at 48kHz of the 32 Opus
+// configurations (RFC 6716, section 3.1).
+var frameSamples = [32]int{
+	480, 960, 1920, 2880, 480, 960, 1920, 2880, 480, 960, 1920, 2880, // SILK
+	480, 960, 480, 960, // Hybrid
+	120, 240, 480, 960, 120, 240, 480, 960, 120, 240, 480, 960, 120, 240, 480, 960, // CELT
+}
+
+// PacketSamples returns the number of samples at 48kHz an Opus packet
+// decodes to.
+func PacketSamples(p []byte) int {
+	if len(p) == 0 {
+		return 0
+	}
+	samples := frameSamples[p[0]>>3]
+	switch p[0] & 0x03 {
+	case 0:
+		return samples
+	case 1, 2:
+		return 2 * samples
+	default:
+		if len(p) < 2 {
+			return 0
+		}
+		return int(p[1]&0x3F) * samples
+	}
+}
+
+// Duration returns the playback length of Opus packets.
+func Duration(packets [][]byte) time.Duration {
+	samples := 0
+	for _, p := range packets {
+		samples += PacketSamples(p)
+	}
+	return time.Duration(samples) * time.Second / 48000
+}
+
+// crcTable is the lookup table of the Ogg CRC-32 (polynomial 0x04c11db7,
+// not reflected).
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// checksum returns the CRC of a page with a zeroed checksum field.
+func checksum(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
@@ -0,0 +1,67 @@
+package ogg
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// 20ms CELT frames, one of them longer than a lacing segment
+	packets := [][]byte{
+		append([]byte{0xFC}, bytes.Repeat([]byte{1}, 40)...),
+		append([]byte{0xFC}, bytes.Repeat([]byte{2}, 600)...),
+		SilenceFrame,
+	}
+	data := EncodeOpus(packets, 2)
+	if !bytes.HasPrefix(data, []byte("OggS")) {
+		t.Fatalf("stream starts with %q", data[:4])
+	}
+
+	got, err := DecodeOpus(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(packets) {
+		t.Fatalf("decoded %d packets, want %d", len(got), len(packets))
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d = %d bytes, want %d", i, len(got[i]), len(packets[i]))
+		}
+	}
+	if d := Duration(got); d != 60*time.Millisecond {
+		t.Errorf("Duration = %s, want 60ms", d)
+	}
+
+	if _, err := DecodeOpus([]byte("ID3 not ogg")); err == nil {
+		t.Error("DecodeOpus accepted MP3 data")
+	}
+	if _, err := DecodeOpus(data[:len(data)-5]); err == nil {
+		t.Error("DecodeOpus accepted a truncated stream")
+	}
+}
+
+func TestPacketSamples(t *testing.T) {
+	tests := []struct {
+		packet []byte
+		want   int
+	}{
+		{[]byte{0xFC}, 960},            // CELT 20ms, one frame
+		{[]byte{0xFD}, 1920},           // two frames
+		{[]byte{0x1B, 0x03}, 3 * 2880}, // SILK 60ms, three frames
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := PacketSamples(tt.packet); got != tt.want {
+			t.Errorf("PacketSamples(% x) = %d, want %d", tt.packet, got, tt.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	// CRC-32/POSIX check value without the final inversion
+	if got := checksum([]byte("123456789")); got != 0x765E7680^0xFFFFFFFF {
+		t.Errorf("checksum = %#x", got)
+	}
+}
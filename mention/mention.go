@@ -0,0 +1,153 @@
+// Package mention builds platform-neutral user and role mentions for
+// outgoing messages.
+//
+// Mentions are written into content as tokens and rendered into each
+// platform's syntax when the router sends the message:
+//
+//	content := "Thanks " + mention.UserNamed(msg.SenderID, msg.SenderName) + "!"
+//	router.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{Content: content})
+//
+// On Discord this becomes "Thanks <@1234>!"; on Telegram with Markdown or
+// HTML formatting it becomes a link that notifies the user.
+package mention
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Kind is the kind of entity mentioned.
+type Kind string
+
+const (
+	KindUser Kind = "user"
+	KindRole Kind = "role"
+)
+
+// Mention is a parsed mention token.
+type Mention struct {
+	Kind Kind
+	ID   string
+
+	// Name is the display name, used where the platform cannot mention by
+	// ID alone.
+	Name string
+}
+
+// Format is the message format a mention is rendered into. It mirrors
+// channels.MessageFormat.
+type Format string
+
+const (
+	FormatPlain    Format = "plain"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// RenderFunc renders a mention for a platform.
+type RenderFunc func(m Mention, format Format) string
+
+var (
+	mu        sync.RWMutex
+	renderers = map[string]RenderFunc{
+		"discord":  renderDiscord,
+		"telegram": renderTelegram,
+	}
+)
+
+// tokenPattern matches tokens produced by User, UserNamed, and Role.
+var tokenPattern = regexp.MustCompile(`<@envoy:(user|role):([^|>]+)(?:\|([^>]*))?>`)
+
+// nameReplacer strips characters that would end a token early.
+var nameReplacer = strings.NewReplacer(">", "", "|", "")
+
+// User returns a token mentioning a user by platform ID.
+func User(id string) string {
+	return fmt.Sprintf("<@envoy:user:%s>", id)
+}
+
+// UserNamed returns a token mentioning a user by ID with a display name for
+// platforms that need one.
+func UserNamed(id, name string) string {
+	return fmt.Sprintf("<@envoy:user:%s|%s>", id, nameReplacer.Replace(name))
+}
+
+// Role returns a token mentioning a role or group by platform ID.
+func Role(id string) string {
+	return fmt.Sprintf("<@envoy:role:%s>", id)
+}
+
+// Register sets the renderer for a platform (channel name), replacing any
+// built-in one.
+func Register(platform string, fn RenderFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	renderers[platform] = fn
+}
+
+// Parse returns the mentions in content.
+func Parse(content string) []Mention {
+	var out []Mention
+	for _, m := range tokenPattern.FindAllStringSubmatch(content, -1) {
+		out = append(out, Mention{Kind: Kind(m[1]), ID: m[2], Name: m[3]})
+	}
+	return out
+}
+
+// Render replaces mention tokens in content with the platform's syntax.
+// Platforms without a renderer get "@name" (or "@id").
+func Render(platform, content string, format Format) string {
+	if !strings.Contains(content, "<@envoy:") {
+		return content
+	}
+
+	mu.RLock()
+	render, ok := renderers[platform]
+	mu.RUnlock()
+	if !ok {
+		render = renderPlain
+	}
+
+	return tokenPattern.ReplaceAllStringFunc(content, func(token string) string {
+		m := tokenPattern.FindStringSubmatch(token)
+		return render(Mention{Kind: Kind(m[1]), ID: m[2], Name: m[3]}, format)
+	})
+}
+
+// displayName returns the name to show for a mention.
+func (m Mention) displayName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.ID
+}
+
+func renderPlain(m Mention, _ Format) string {
+	return "@" + m.displayName()
+}
+
+func renderDiscord(m Mention, _ Format) string {
+	if m.Kind == KindRole {
+		return "<@&" + m.ID + ">"
+	}
+	return "<@" + m.ID + ">"
+}
+
+// renderTelegram links users by ID, which notifies them even without a
+// username. Plain text cannot carry links, so it falls back to "@name".
+func renderTelegram(m Mention, format Format) string {
+	if m.Kind != KindUser {
+		return renderPlain(m, format)
+	}
+	switch format {
+	case FormatMarkdown:
+		return fmt.Sprintf("[%s](tg://user?id=%s)", m.displayName(), m.ID)
+	case FormatHTML:
+		return fmt.Sprintf(`<a href="tg://user?id=%s">%s</a>`, html.EscapeString(m.ID), html.EscapeString(m.displayName()))
+	default:
+		return renderPlain(m, format)
+	}
+}
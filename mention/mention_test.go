@@ -0,0 +1,37 @@
+package mention
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	content := "Hi " + UserNamed("42", "Alice") + ", ping " + Role("7")
+
+	tests := []struct {
+		platform string
+		format   Format
+		want     string
+	}{
+		{"discord", FormatPlain, "Hi <@42>, ping <@&7>"},
+		{"telegram", FormatMarkdown, "Hi [Alice](tg://user?id=42), ping @7"},
+		{"telegram", FormatHTML, `Hi <a href="tg://user?id=42">Alice</a>, ping @7`},
+		{"telegram", FormatPlain, "Hi @Alice, ping @7"},
+		{"slack", FormatPlain, "Hi @Alice, ping @7"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.platform, content, tt.format); got != tt.want {
+			t.Errorf("Render(%s, %s) = %q, want %q", tt.platform, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	mentions := Parse("a " + User("1") + " b " + UserNamed("2", "B>ob|"))
+	if len(mentions) != 2 {
+		t.Fatalf("len = %d, want 2", len(mentions))
+	}
+	if mentions[0] != (Mention{Kind: KindUser, ID: "1"}) {
+		t.Errorf("mentions[0] = %+v", mentions[0])
+	}
+	if mentions[1].Name != "Bob" {
+		t.Errorf("name = %q, want sanitized Bob", mentions[1].Name)
+	}
+}
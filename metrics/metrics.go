@@ -0,0 +1,266 @@
+// Package metrics provides a small in-process metrics registry with
+// short rolling histories, used for the gateway dashboard and exported in
+// the Prometheus text format.
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Labels are metric dimensions, such as {"channel": "telegram"}.
+type Labels map[string]string
+
+// key returns a stable identifier for name and labels.
+func (l Labels) key(name string) string {
+	if len(l) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(l[k])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// clone returns a copy of the labels.
+func (l Labels) clone() Labels {
+	if len(l) == 0 {
+		return nil
+	}
+	out := make(Labels, len(l))
+	for k, v := range l {
+		out[k] = v
+	}
+	return out
+}
+
+// Kind identifies a metric type.
+type Kind string
+
+const (
+	KindCounter   Kind = "counter"
+	KindGauge     Kind = "gauge"
+	KindTimer     Kind = "timer"
+	KindHistogram Kind = "histogram"
+)
+
+// DefaultLatencyBuckets are the histogram bounds of timers, in seconds.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// DefaultSizeBuckets are histogram bounds for sizes in bytes.
+var DefaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+
+// Config configures a Registry.
+type Config struct {
+	// Interval is the width of each history bucket (default: 10s).
+	Interval time.Duration
+
+	// Buckets is the number of history buckets kept (default: 60).
+	Buckets int
+}
+
+// Registry holds named metrics. It is safe for concurrent use.
+type Registry struct {
+	interval time.Duration
+	buckets  int
+	now      func() time.Time
+
+	metrics map[string]metric
+	funcs   map[string]*gaugeFunc
+	mu      sync.RWMutex
+}
+
+// metric is implemented by Counter, Gauge, Timer, and Histogram.
+type metric interface {
+	ref() *base
+	snapshot(now time.Time) Sample
+}
+
+// NewRegistry creates a new Registry.
+func NewRegistry(config Config) *Registry {
+	if config.Interval == 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Buckets == 0 {
+		config.Buckets = 60
+	}
+	return &Registry{
+		interval: config.Interval,
+		buckets:  config.Buckets,
+		now:      time.Now,
+		metrics:  make(map[string]metric),
+		funcs:    make(map[string]*gaugeFunc),
+	}
+}
+
+// Counter returns the counter for name and labels, creating it if needed.
+func (r *Registry) Counter(name string, labels Labels) *Counter {
+	return getOrCreate(r, name, labels, func() *Counter {
+		return &Counter{}
+	})
+}
+
+// Gauge returns the gauge for name and labels, creating it if needed.
+func (r *Registry) Gauge(name string, labels Labels) *Gauge {
+	return getOrCreate(r, name, labels, func() *Gauge {
+		return &Gauge{}
+	})
+}
+
+// Timer returns the timer for name and labels, creating it if needed.
+func (r *Registry) Timer(name string, labels Labels) *Timer {
+	return getOrCreate(r, name, labels, func() *Timer {
+		return &Timer{Histogram: *newHistogram(DefaultLatencyBuckets, r.buckets)}
+	})
+}
+
+// Histogram returns the histogram for name and labels, creating it if
+// needed with bounds, the bucket upper bounds in ascending order (nil:
+// DefaultLatencyBuckets). The bounds of an existing histogram are kept.
+func (r *Registry) Histogram(name string, labels Labels, bounds []float64) *Histogram {
+	if bounds == nil {
+		bounds = DefaultLatencyBuckets
+	}
+	return getOrCreate(r, name, labels, func() *Histogram {
+		return newHistogram(bounds, r.buckets)
+	})
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at snapshot time,
+// such as a queue depth. Registering the same name and labels again
+// replaces fn.
+func (r *Registry) GaugeFunc(name string, labels Labels, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := labels.key(name)
+	if g, ok := r.funcs[key]; ok {
+		g.fn = fn
+		return
+	}
+	g := &gaugeFunc{fn: fn}
+	g.init(name, labels, r.interval, r.buckets, r.now)
+	r.funcs[key] = g
+}
+
+// getOrCreate returns an existing metric of type T or creates one.
+func getOrCreate[T metric](r *Registry, name string, labels Labels, create func() T) T {
+	key := labels.key(name)
+
+	r.mu.RLock()
+	m, ok := r.metrics[key]
+	r.mu.RUnlock()
+	if ok {
+		if t, ok := m.(T); ok {
+			return t
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[key]; ok {
+		if t, ok := m.(T); ok {
+			return t
+		}
+	}
+	t := create()
+	t.ref().init(name, labels, r.interval, r.buckets, r.now)
+	r.metrics[key] = t
+	return t
+}
+
+// Sample is a point-in-time view of one metric.
+type Sample struct {
+	Name   string `json:"name"`
+	Labels Labels `json:"labels,omitempty"`
+	Kind   Kind   `json:"kind"`
+
+	// Value is the counter total, gauge value, or histogram mean; timers
+	// are in seconds.
+	Value float64 `json:"value"`
+
+	// Count is the number of timer or histogram observations.
+	Count int64 `json:"count,omitempty"`
+
+	// Sum is the total of timer or histogram observations.
+	Sum float64 `json:"sum,omitempty"`
+
+	// Buckets is the cumulative distribution of timer or histogram
+	// observations, excluding the implicit +Inf bucket, which is Count.
+	Buckets []Bucket `json:"buckets,omitempty"`
+
+	// History holds one value per interval, oldest first: counter
+	// increments, the last gauge value, or the mean timer or histogram
+	// observation.
+	History []float64 `json:"history"`
+}
+
+// Snapshot is a point-in-time view of all metrics.
+type Snapshot struct {
+	Time     time.Time     `json:"time"`
+	Interval time.Duration `json:"interval"`
+	Samples  []Sample      `json:"samples"`
+}
+
+// Snapshot returns all metrics sorted by name and labels.
+func (r *Registry) Snapshot() Snapshot {
+	now := r.now()
+
+	r.mu.RLock()
+	samples := make([]Sample, 0, len(r.metrics)+len(r.funcs))
+	keys := make([]string, 0, len(r.metrics)+len(r.funcs))
+	for k, m := range r.metrics {
+		samples = append(samples, m.snapshot(now))
+		keys = append(keys, k)
+	}
+	funcs := make(map[string]*gaugeFunc, len(r.funcs))
+	for k, g := range r.funcs {
+		funcs[k] = g
+	}
+	r.mu.RUnlock()
+
+	// Call gauge funcs without holding the lock
+	for k, g := range funcs {
+		g.Set(g.fn())
+		samples = append(samples, g.snapshot(now))
+		keys = append(keys, k)
+	}
+
+	sort.Sort(byKey{samples, keys})
+	return Snapshot{Time: now, Interval: r.interval, Samples: samples}
+}
+
+// byKey sorts samples by their registry key.
+type byKey struct {
+	samples []Sample
+	keys    []string
+}
+
+func (b byKey) Len() int           { return len(b.samples) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.samples[i], b.samples[j] = b.samples[j], b.samples[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// gaugeFunc is a gauge sampled from a function.
+type gaugeFunc struct {
+	Gauge
+	fn func() float64
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterHistory(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := NewRegistry(Config{Interval: time.Second, Buckets: 3})
+	r.now = func() time.Time { return now }
+
+	c := r.Counter("messages_received", Labels{"channel": "telegram"})
+	c.Inc()
+	c.Inc()
+	now = now.Add(time.Second)
+	c.Add(3)
+	now = now.Add(time.Second)
+
+	if r.Counter("messages_received", Labels{"channel": "telegram"}) != c {
+		t.Fatal("Counter should return the existing counter")
+	}
+
+	snap := r.Snapshot()
+	if len(snap.Samples) != 1 {
+		t.Fatalf("samples = %d, want 1", len(snap.Samples))
+	}
+	s := snap.Samples[0]
+	if s.Value != 5 {
+		t.Errorf("Value = %v, want 5", s.Value)
+	}
+	want := []float64{2, 3, 0}
+	for i := range want {
+		if s.History[i] != want[i] {
+			t.Fatalf("History = %v, want %v", s.History, want)
+		}
+	}
+
+	// Gaps longer than the history clear it
+	now = now.Add(time.Minute)
+	s = r.Snapshot().Samples[0]
+	for _, v := range s.History {
+		if v != 0 {
+			t.Fatalf("History = %v, want all zero", s.History)
+		}
+	}
+}
+
+func TestGaugeAndTimer(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := NewRegistry(Config{Interval: time.Second, Buckets: 2})
+	r.now = func() time.Time { return now }
+
+	g := r.Gauge("queue_depth", nil)
+	g.Set(4)
+	now = now.Add(time.Second)
+	g.Add(-1)
+
+	tm := r.Timer("agent_latency", nil)
+	tm.Observe(100 * time.Millisecond)
+	tm.Observe(300 * time.Millisecond)
+
+	depth := 7.0
+	r.GaugeFunc("gateway_clients", nil, func() float64 { return depth })
+
+	samples := map[string]Sample{}
+	for _, s := range r.Snapshot().Samples {
+		samples[s.Name] = s
+	}
+
+	if s := samples["queue_depth"]; s.Value != 3 || s.History[0] != 4 || s.History[1] != 3 {
+		t.Errorf("gauge = %+v", s)
+	}
+	if s := samples["agent_latency"]; s.Count != 2 || s.Value < 0.199 || s.Value > 0.201 {
+		t.Errorf("timer = %+v, want mean 0.2s over 2", s)
+	}
+	if s := samples["gateway_clients"]; s.Value != 7 || s.Kind != KindGauge {
+		t.Errorf("gauge func = %+v", s)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	r := NewRegistry(Config{})
+	h := r.Histogram("message_bytes", nil, []float64{10, 100})
+	for _, v := range []float64{5, 10, 50, 500} {
+		h.Observe(v)
+	}
+
+	s := r.Snapshot().Samples[0]
+	if s.Kind != KindHistogram || s.Count != 4 || s.Sum != 565 {
+		t.Fatalf("histogram = %+v", s)
+	}
+	want := []Bucket{{10, 2}, {100, 3}}
+	if len(s.Buckets) != len(want) || s.Buckets[0] != want[0] || s.Buckets[1] != want[1] {
+		t.Errorf("Buckets = %v, want %v", s.Buckets, want)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry(Config{})
+	r.Counter("messages_sent", Labels{"channel": "telegram"}).Add(2)
+	r.Counter("messages_sent", Labels{"channel": `say "hi"`}).Inc()
+	r.Counter("messages_sent_bytes", nil).Add(10)
+	r.Gauge("gateway_clients", nil).Set(3)
+	r.Timer("agent_latency", nil).Observe(20 * time.Millisecond)
+
+	var buf strings.Builder
+	if err := WritePrometheus(&buf, r.Snapshot(), "envoy"); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	out := buf.String()
+
+	for _, line := range []string{
+		"# TYPE envoy_messages_sent_total counter",
+		`envoy_messages_sent_total{channel="telegram"} 2`,
+		`envoy_messages_sent_total{channel="say \"hi\""} 1`,
+		"envoy_messages_sent_bytes_total 10",
+		"# TYPE envoy_gateway_clients gauge",
+		"envoy_gateway_clients 3",
+		"# TYPE envoy_agent_latency_seconds histogram",
+		`envoy_agent_latency_seconds_bucket{le="0.01"} 0`,
+		`envoy_agent_latency_seconds_bucket{le="0.025"} 1`,
+		`envoy_agent_latency_seconds_bucket{le="+Inf"} 1`,
+		"envoy_agent_latency_seconds_sum 0.02",
+		"envoy_agent_latency_seconds_count 1",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("output missing %q:\n%s", line, out)
+		}
+	}
+	if strings.Count(out, "# TYPE envoy_messages_sent_total") != 1 {
+		t.Errorf("family split across TYPE lines:\n%s", out)
+	}
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrometheusContentType is the content type of the Prometheus text format.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes snap in the Prometheus text exposition format,
+// prefixing metric names with namespace and "_" if namespace is set.
+// Counters get a "_total" suffix and timers are histograms with a
+// "_seconds" suffix, per Prometheus naming conventions.
+func WritePrometheus(w io.Writer, snap Snapshot, namespace string) error {
+	// Group samples into families; registry order interleaves names that
+	// share a prefix
+	families := make(map[string][]Sample)
+	kinds := make(map[string]Kind)
+	for _, s := range snap.Samples {
+		name := prometheusName(namespace, s)
+		families[name] = append(families[name], s)
+		kinds[name] = s.Kind
+	}
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		bw.WriteString("# TYPE " + name + " " + prometheusType(kinds[name]) + "\n")
+		for _, s := range families[name] {
+			switch s.Kind {
+			case KindTimer, KindHistogram:
+				for _, b := range s.Buckets {
+					writeSample(bw, name+"_bucket", s.Labels, "le", formatFloat(b.UpperBound), float64(b.Count))
+				}
+				writeSample(bw, name+"_bucket", s.Labels, "le", "+Inf", float64(s.Count))
+				writeSample(bw, name+"_sum", s.Labels, "", "", s.Sum)
+				writeSample(bw, name+"_count", s.Labels, "", "", float64(s.Count))
+			default:
+				writeSample(bw, name, s.Labels, "", "", s.Value)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// PrometheusHandler serves the registry's metrics in the Prometheus text
+// format. See WritePrometheus.
+func (r *Registry) PrometheusHandler(namespace string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", PrometheusContentType)
+		_ = WritePrometheus(w, r.Snapshot(), namespace)
+	})
+}
+
+// prometheusName returns the exported metric family name of a sample.
+func prometheusName(namespace string, s Sample) string {
+	name := sanitizeName(s.Name)
+	if namespace != "" {
+		name = sanitizeName(namespace) + "_" + name
+	}
+	switch s.Kind {
+	case KindCounter:
+		if !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+	case KindTimer:
+		if !strings.HasSuffix(name, "_seconds") {
+			name += "_seconds"
+		}
+	}
+	return name
+}
+
+// prometheusType returns the Prometheus metric type of a kind.
+func prometheusType(k Kind) string {
+	switch k {
+	case KindCounter:
+		return "counter"
+	case KindTimer, KindHistogram:
+		return "histogram"
+	}
+	return "gauge"
+}
+
+// writeSample writes one sample line, with an extra label if extraName is
+// set.
+func writeSample(w *bufio.Writer, name string, labels Labels, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, sanitizeName(k), labels[k])
+		}
+		if extraName != "" {
+			if len(keys) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// labelEscaper escapes label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	labelEscaper.WriteString(w, value)
+	w.WriteByte('"')
+}
+
+// sanitizeName replaces characters not allowed in metric and label names.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// base holds identity and a rolling history shared by all metric types.
+type base struct {
+	name   string
+	labels Labels
+
+	interval time.Duration
+	history  []float64
+	head     int   // index of the current bucket
+	epoch    int64 // interval number of the current bucket
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+// init sets identity and allocates the history.
+func (b *base) init(name string, labels Labels, interval time.Duration, buckets int, now func() time.Time) {
+	b.name = name
+	b.labels = labels.clone()
+	b.interval = interval
+	b.history = make([]float64, buckets)
+	b.epoch = now().UnixNano() / int64(interval)
+	b.now = now
+}
+
+// ref returns the base for initialization.
+func (b *base) ref() *base { return b }
+
+// advance rotates the history to the bucket for now, calling reset for each
+// bucket that is reused. Callers must hold mu.
+func (b *base) advance(now time.Time, reset func(i int)) {
+	epoch := now.UnixNano() / int64(b.interval)
+	steps := epoch - b.epoch
+	if steps <= 0 {
+		return
+	}
+	if steps > int64(len(b.history)) {
+		steps = int64(len(b.history))
+	}
+	for i := int64(0); i < steps; i++ {
+		b.head = (b.head + 1) % len(b.history)
+		reset(b.head)
+	}
+	b.epoch = epoch
+}
+
+// ordered returns values oldest first. Callers must hold mu.
+func (b *base) ordered(values []float64) []float64 {
+	out := make([]float64, len(values))
+	n := len(values)
+	for i := 0; i < n; i++ {
+		out[i] = values[(b.head+1+i)%n]
+	}
+	return out
+}
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	base
+	total float64
+}
+
+// Inc adds one.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds n, which must not be negative.
+func (c *Counter) Add(n float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(c.now(), c.zero)
+	c.total += n
+	c.history[c.head] += n
+}
+
+// Value returns the total count.
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+func (c *Counter) zero(i int) { c.history[i] = 0 }
+
+func (c *Counter) snapshot(now time.Time) Sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(now, c.zero)
+	return Sample{
+		Name:    c.name,
+		Labels:  c.labels,
+		Kind:    KindCounter,
+		Value:   c.total,
+		History: c.ordered(c.history),
+	}
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	base
+	value float64
+}
+
+// Set sets the gauge value.
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.advance(g.now(), g.carry)
+	g.value = v
+	g.history[g.head] = v
+}
+
+// Add adds n (which may be negative) to the gauge value.
+func (g *Gauge) Add(n float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.advance(g.now(), g.carry)
+	g.value += n
+	g.history[g.head] = g.value
+}
+
+// Value returns the gauge value.
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+// carry starts a new bucket at the current value.
+func (g *Gauge) carry(i int) { g.history[i] = g.value }
+
+func (g *Gauge) snapshot(now time.Time) Sample {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.advance(now, g.carry)
+	return Sample{
+		Name:    g.name,
+		Labels:  g.labels,
+		Kind:    KindGauge,
+		Value:   g.value,
+		History: g.ordered(g.history),
+	}
+}
+
+// Histogram records the distribution of observed values, such as message
+// sizes, in cumulative buckets.
+type Histogram struct {
+	base
+	bounds  []float64
+	buckets []int64 // observations per bound; the last is +Inf
+	counts  []int64 // observations per history interval
+	count   int64
+	sum     float64
+}
+
+// Bucket is a cumulative histogram bucket: the number of observations less
+// than or equal to UpperBound.
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      int64   `json:"count"`
+}
+
+// newHistogram creates a histogram with the given upper bounds, sorted
+// ascending, and history length.
+func newHistogram(bounds []float64, history int) *Histogram {
+	return &Histogram{
+		bounds:  bounds,
+		buckets: make([]int64, len(bounds)+1),
+		counts:  make([]int64, history),
+	}
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.advance(h.now(), h.zero)
+	h.buckets[sort.SearchFloat64s(h.bounds, v)]++
+	h.count++
+	h.sum += v
+	h.history[h.head] += v
+	h.counts[h.head]++
+}
+
+func (h *Histogram) zero(i int) {
+	h.history[i] = 0
+	h.counts[i] = 0
+}
+
+func (h *Histogram) snapshot(now time.Time) Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.advance(now, h.zero)
+
+	means := make([]float64, len(h.history))
+	for i, sum := range h.history {
+		if h.counts[i] > 0 {
+			means[i] = sum / float64(h.counts[i])
+		}
+	}
+	var mean float64
+	if h.count > 0 {
+		mean = h.sum / float64(h.count)
+	}
+	buckets := make([]Bucket, len(h.bounds))
+	var cumulative int64
+	for i, bound := range h.bounds {
+		cumulative += h.buckets[i]
+		buckets[i] = Bucket{UpperBound: bound, Count: cumulative}
+	}
+	return Sample{
+		Name:    h.name,
+		Labels:  h.labels,
+		Kind:    KindHistogram,
+		Value:   mean,
+		Count:   h.count,
+		Sum:     h.sum,
+		Buckets: buckets,
+		History: h.ordered(means),
+	}
+}
+
+// Timer records durations, such as request latency, as a histogram of
+// seconds.
+type Timer struct {
+	Histogram
+}
+
+// Observe records a duration.
+func (t *Timer) Observe(d time.Duration) {
+	t.Histogram.Observe(d.Seconds())
+}
+
+// Since records the time elapsed since start.
+func (t *Timer) Since(start time.Time) {
+	t.Observe(time.Since(start))
+}
+
+func (t *Timer) snapshot(now time.Time) Sample {
+	s := t.Histogram.snapshot(now)
+	s.Kind = KindTimer
+	return s
+}
@@ -0,0 +1,169 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// HTTP is a moderator backed by an HTTP service. It POSTs
+// {"direction": "...", "content": "..."} to URL and expects a Verdict as
+// JSON in response.
+type HTTP struct {
+	URL string
+
+	// Header is added to every request, e.g. for authorization.
+	Header http.Header
+
+	// Client sends requests (default: http.DefaultClient).
+	Client *http.Client
+}
+
+// Name returns the moderator name.
+func (h *HTTP) Name() string {
+	return "http:" + h.URL
+}
+
+// Moderate asks the service for a verdict.
+func (h *HTTP) Moderate(ctx context.Context, direction Direction, content string) (Verdict, error) {
+	in := map[string]string{"direction": string(direction), "content": content}
+	var v Verdict
+	if err := postJSON(ctx, h.Client, h.URL, h.Header, in, &v); err != nil {
+		return Verdict{}, err
+	}
+	if v.Action == "" {
+		v.Action = ActionAllow
+	}
+	if v.Action == ActionRedact && v.Content == "" {
+		return Verdict{}, fmt.Errorf("moderation service redacted without content")
+	}
+	return v, nil
+}
+
+// DefaultOpenAIModel is the OpenAI moderation model.
+const DefaultOpenAIModel = "omni-moderation-latest"
+
+// DefaultOpenAIURL is the OpenAI API base URL.
+const DefaultOpenAIURL = "https://api.openai.com/v1"
+
+// OpenAI is a moderator backed by the OpenAI moderation endpoint. Texts it
+// flags get Action, with the flagged categories.
+type OpenAI struct {
+	// APIKey authenticates requests (default: the OPENAI_API_KEY
+	// environment variable).
+	APIKey string
+
+	// Model is the moderation model (default: DefaultOpenAIModel).
+	Model string
+
+	// BaseURL is the API base URL (default: DefaultOpenAIURL).
+	BaseURL string
+
+	// Action is taken for flagged texts (default: ActionBlock).
+	Action Action
+
+	// Client sends requests (default: http.DefaultClient).
+	Client *http.Client
+}
+
+// Name returns the moderator name.
+func (o *OpenAI) Name() string {
+	return "openai"
+}
+
+// Moderate classifies content with the moderation endpoint.
+func (o *OpenAI) Moderate(ctx context.Context, _ Direction, content string) (Verdict, error) {
+	apiKey := o.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	model, baseURL, action := o.Model, o.BaseURL, o.Action
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	if baseURL == "" {
+		baseURL = DefaultOpenAIURL
+	}
+	if action == "" {
+		action = ActionBlock
+	}
+
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+	var resp struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	in := map[string]string{"model": model, "input": content}
+	if err := postJSON(ctx, o.Client, strings.TrimSuffix(baseURL, "/")+"/moderations", header, in, &resp); err != nil {
+		return Verdict{}, err
+	}
+	if len(resp.Results) == 0 || !resp.Results[0].Flagged {
+		return Verdict{Action: ActionAllow}, nil
+	}
+
+	var categories []string
+	for name, flagged := range resp.Results[0].Categories {
+		if flagged {
+			categories = append(categories, name)
+		}
+	}
+	sort.Strings(categories)
+	v := Verdict{Action: action, Categories: categories}
+	if action == ActionRedact {
+		// The endpoint does not locate violations, so the whole text goes.
+		v.Content = DefaultReplacement
+	}
+	return v, nil
+}
+
+// postJSON sends in as JSON and decodes the JSON response into out.
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, in, out interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("moderation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("moderation %s: %s: %s", url, resp.Status, msg)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// Ensure HTTP and OpenAI implement Moderator.
+var (
+	_ Moderator = (*HTTP)(nil)
+	_ Moderator = (*OpenAI)(nil)
+)
@@ -0,0 +1,312 @@
+// Package moderation screens user messages before they reach the agent and
+// agent responses before they reach users.
+//
+// Moderators return a Verdict for a text: allow it, flag it for review,
+// redact parts of it, or block it. A Pipeline runs its incoming moderators
+// as router middleware and its outgoing moderators as an agent
+// interceptor, and publishes every non-allow verdict as an
+// events.MessageModerated audit event:
+//
+//	pii, _ := moderation.Regexp("pii", moderation.ActionRedact, moderation.EmailPattern)
+//	pipeline := moderation.New(moderation.Config{
+//		Incoming: []moderation.Moderator{pii, &moderation.OpenAI{}},
+//		Outgoing: []moderation.Moderator{moderation.Keywords("profanity", moderation.ActionRedact, "darn")},
+//		Sender:   router,
+//		Events:   bus,
+//		Flags:    flags,
+//	})
+//	router.Use(pipeline.Middleware())
+//	router.SetAgent(agent.Chain(pipeline.Interceptor())(a))
+package moderation
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/agentplexus/envoy/agent"
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/digest"
+	"github.com/agentplexus/envoy/events"
+)
+
+// MetadataKey is the IncomingMessage.Metadata key holding the Verdict of a
+// flagged or redacted message.
+const MetadataKey = "moderation"
+
+// Default messages sent in place of blocked content.
+const (
+	DefaultBlockedMessage  = "Your message couldn't be processed because it violates our content policy."
+	DefaultWithheldMessage = "Sorry, I can't help with that."
+)
+
+// Action is what a moderator decided to do with a text.
+type Action string
+
+// Actions, from least to most severe.
+const (
+	ActionAllow  Action = "allow"
+	ActionFlag   Action = "flag"
+	ActionRedact Action = "redact"
+	ActionBlock  Action = "block"
+)
+
+// severity orders actions; unknown actions count as allow.
+func (a Action) severity() int {
+	switch a {
+	case ActionFlag:
+		return 1
+	case ActionRedact:
+		return 2
+	case ActionBlock:
+		return 3
+	}
+	return 0
+}
+
+// Direction says whether a text is a user message or an agent response.
+type Direction string
+
+const (
+	DirectionIncoming Direction = "incoming"
+	DirectionOutgoing Direction = "outgoing"
+)
+
+// Verdict is a moderator's decision about a text.
+type Verdict struct {
+	Action Action `json:"action"`
+
+	// Categories name the policies the text violates, e.g. "hate" or "pii".
+	Categories []string `json:"categories,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+
+	// Content is the redacted text when Action is ActionRedact.
+	Content string `json:"content,omitempty"`
+
+	// Moderator names the moderator that decided the action. It is set by
+	// the Pipeline.
+	Moderator string `json:"moderator,omitempty"`
+}
+
+// Moderator decides what to do with a text.
+type Moderator interface {
+	// Name identifies the moderator in logs and audit events.
+	Name() string
+
+	// Moderate returns the verdict for content.
+	Moderate(ctx context.Context, direction Direction, content string) (Verdict, error)
+}
+
+// Config configures a Pipeline.
+type Config struct {
+	// Incoming moderators screen user messages, and Outgoing moderators
+	// agent responses. They run in order, each seeing the text redacted by
+	// the previous ones, until one blocks it.
+	Incoming []Moderator
+	Outgoing []Moderator
+
+	// Sender, if set, tells users their message was blocked.
+	Sender channels.Sender
+
+	// BlockedMessage is sent to users whose message was blocked, and
+	// WithheldMessage replaces blocked agent responses (defaults:
+	// DefaultBlockedMessage and DefaultWithheldMessage).
+	BlockedMessage  string
+	WithheldMessage string
+
+	// Events, if set, receives an events.MessageModerated for every text
+	// flagged, redacted, or blocked.
+	Events *events.Bus
+
+	// Flags, if set, records those texts for the review digest.
+	Flags *digest.Collector
+
+	// Timeout bounds each moderator call (default: 5s).
+	Timeout time.Duration
+
+	// FailClosed blocks texts when a moderator fails. By default, moderator
+	// errors are logged and the moderator is skipped.
+	FailClosed bool
+
+	Logger *slog.Logger
+}
+
+func (c Config) withDefaults() Config {
+	if c.BlockedMessage == "" {
+		c.BlockedMessage = DefaultBlockedMessage
+	}
+	if c.WithheldMessage == "" {
+		c.WithheldMessage = DefaultWithheldMessage
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	return c
+}
+
+// Pipeline runs moderators over messages and responses.
+type Pipeline struct {
+	config Config
+}
+
+// New creates a new Pipeline.
+func New(config Config) *Pipeline {
+	return &Pipeline{config: config.withDefaults()}
+}
+
+// Check runs the moderators for direction over content and returns the
+// combined verdict: the most severe action, the categories of all
+// moderators that did not allow the text, and the text after redactions
+// in Content.
+func (p *Pipeline) Check(ctx context.Context, direction Direction, content string) Verdict {
+	moderators := p.config.Incoming
+	if direction == DirectionOutgoing {
+		moderators = p.config.Outgoing
+	}
+
+	result := Verdict{Action: ActionAllow, Content: content}
+	for _, m := range moderators {
+		v, err := p.moderate(ctx, m, direction, result.Content)
+		if err != nil {
+			p.config.Logger.Warn("moderator error", "moderator", m.Name(), "direction", direction, "error", err)
+			if !p.config.FailClosed {
+				continue
+			}
+			v = Verdict{Action: ActionBlock, Reason: "moderation unavailable"}
+		}
+		if v.Action.severity() == 0 {
+			continue
+		}
+
+		result.Categories = append(result.Categories, v.Categories...)
+		if v.Action.severity() > result.Action.severity() {
+			result.Action = v.Action
+			result.Reason = v.Reason
+			result.Moderator = m.Name()
+		}
+		if v.Action == ActionRedact {
+			result.Content = v.Content
+		}
+		if v.Action == ActionBlock {
+			break
+		}
+	}
+	return result
+}
+
+// moderate calls one moderator with the configured timeout.
+func (p *Pipeline) moderate(ctx context.Context, m Moderator, direction Direction, content string) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	return m.Moderate(ctx, direction, content)
+}
+
+// Middleware returns router middleware that screens incoming messages.
+// Blocked messages are dropped, and their sender is told if Sender is set;
+// redacted messages continue with the redacted content. The verdict of
+// flagged and redacted messages is stored in their metadata under
+// MetadataKey.
+func (p *Pipeline) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			if len(p.config.Incoming) == 0 {
+				return next(ctx, msg)
+			}
+			v := p.Check(ctx, DirectionIncoming, msg.Content)
+			if v.Action == ActionAllow {
+				return next(ctx, msg)
+			}
+			p.audit(msg, DirectionIncoming, v, msg.Content)
+
+			if v.Action == ActionBlock {
+				if p.config.Sender == nil {
+					return nil
+				}
+				return p.config.Sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+					Content: p.config.BlockedMessage,
+					ReplyTo: msg.ID,
+				})
+			}
+
+			metadata := make(map[string]interface{}, len(msg.Metadata)+1)
+			for k, v := range msg.Metadata {
+				metadata[k] = v
+			}
+			metadata[MetadataKey] = v
+			msg.Metadata = metadata
+			msg.Content = v.Content
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Interceptor returns an agent interceptor that screens agent responses.
+// Blocked responses are replaced with WithheldMessage. Responses are
+// moderated whole, so agents it wraps do not stream.
+func (p *Pipeline) Interceptor() agent.Interceptor {
+	return agent.Wrap(func(ctx context.Context, sessionID, content string, next agent.ProcessFunc) (string, error) {
+		response, err := next(ctx, sessionID, content)
+		if err != nil || len(p.config.Outgoing) == 0 {
+			return response, err
+		}
+		v := p.Check(ctx, DirectionOutgoing, response)
+		if v.Action == ActionAllow {
+			return response, nil
+		}
+		msg, _ := channels.MessageFromContext(ctx)
+		p.audit(msg, DirectionOutgoing, v, response)
+
+		if v.Action == ActionBlock {
+			return p.config.WithheldMessage, nil
+		}
+		return v.Content, nil
+	}, nil)
+}
+
+// audit logs a verdict, publishes its event, and records its flag.
+func (p *Pipeline) audit(msg channels.IncomingMessage, direction Direction, v Verdict, content string) {
+	p.config.Logger.Info("message moderated",
+		"channel", msg.ChannelName,
+		"chat", msg.ChatID,
+		"direction", direction,
+		"action", v.Action,
+		"moderator", v.Moderator,
+		"categories", v.Categories)
+
+	if p.config.Events != nil {
+		p.config.Events.Publish(events.MessageModerated{
+			Channel:    msg.ChannelName,
+			ChatID:     msg.ChatID,
+			MessageID:  msg.ID,
+			SenderID:   msg.SenderID,
+			Direction:  string(direction),
+			Action:     string(v.Action),
+			Moderator:  v.Moderator,
+			Categories: v.Categories,
+			Reason:     v.Reason,
+			Time:       time.Now(),
+		})
+	}
+
+	if p.config.Flags != nil && v.Action != ActionRedact {
+		note := string(v.Action) + " by " + v.Moderator
+		if direction == DirectionOutgoing {
+			note += " (agent response)"
+		}
+		if v.Reason != "" {
+			note += ": " + v.Reason
+		}
+		p.config.Flags.Add(digest.Flag{
+			Reason:      digest.ReasonModeration,
+			ChannelName: msg.ChannelName,
+			ChatID:      msg.ChatID,
+			MessageID:   msg.ID,
+			SenderID:    msg.SenderID,
+			Excerpt:     content,
+			Note:        note,
+		})
+	}
+}
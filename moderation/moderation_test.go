@@ -0,0 +1,143 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/digest"
+	"github.com/agentplexus/envoy/events"
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+// echoAgent answers with the content it receives.
+type echoAgent struct{}
+
+func (echoAgent) Process(_ context.Context, _, content string) (string, error) {
+	return content, nil
+}
+
+func TestPipelineMiddleware(t *testing.T) {
+	pii, err := Regexp("pii", ActionRedact, EmailPattern)
+	if err != nil {
+		t.Fatalf("Regexp failed: %v", err)
+	}
+	bus := events.New(events.Config{})
+	defer bus.Close()
+	audit := make(chan events.MessageModerated, 4)
+	bus.Subscribe(func(e events.Event) { audit <- e.(events.MessageModerated) }, events.TypeMessageModerated)
+
+	sender := &channelstest.Sender{}
+	flags := digest.NewCollector()
+	p := New(Config{
+		Incoming: []Moderator{pii, Keywords("abuse", ActionBlock, "idiot")},
+		Sender:   sender,
+		Events:   bus,
+		Flags:    flags,
+	})
+
+	var got []channels.IncomingMessage
+	handler := p.Middleware()(func(_ context.Context, msg channels.IncomingMessage) error {
+		got = append(got, msg)
+		return nil
+	})
+	ctx := context.Background()
+	for _, content := range []string{"hello", "mail me at ann@example.com", "you IDIOT"} {
+		msg := channels.IncomingMessage{ID: content, ChannelName: "telegram", ChatID: "42", Content: content}
+		if err := handler(ctx, msg); err != nil {
+			t.Fatalf("%q failed: %v", content, err)
+		}
+	}
+
+	if len(got) != 2 || got[0].Content != "hello" || got[1].Content != "mail me at [redacted]" {
+		t.Fatalf("handled = %+v", got)
+	}
+	if v, _ := got[1].Metadata[MetadataKey].(Verdict); v.Action != ActionRedact || v.Moderator != "rule:pii" {
+		t.Errorf("verdict = %+v", v)
+	}
+	if len(sender.Contents()) != 1 || sender.Contents()[0] != DefaultBlockedMessage {
+		t.Errorf("sent = %q", sender.Contents())
+	}
+	if f := flags.Take(); len(f) != 1 || f[0].Reason != digest.ReasonModeration || f[0].Excerpt != "you IDIOT" {
+		t.Errorf("flags = %+v", f)
+	}
+
+	for _, want := range []string{"redact", "block"} {
+		select {
+		case e := <-audit:
+			if e.Action != want || e.Direction != "incoming" || e.ChatID != "42" {
+				t.Errorf("event = %+v, want action %s", e, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+}
+
+func TestPipelineInterceptor(t *testing.T) {
+	p := New(Config{
+		Outgoing: []Moderator{
+			Keywords("profanity", ActionRedact, "darn"),
+			Keywords("secrets", ActionBlock, "launch codes"),
+		},
+	})
+	a := p.Interceptor()(echoAgent{})
+	ctx := context.Background()
+
+	cases := map[string]string{
+		"fine":                    "fine",
+		"Darn it":                 "[redacted] it",
+		"the darn launch codes":   DefaultWithheldMessage,
+		"darned is a longer word": "darned is a longer word",
+	}
+	for in, want := range cases {
+		got, err := a.Process(ctx, "s", in)
+		if err != nil || got != want {
+			t.Errorf("Process(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestFailClosed(t *testing.T) {
+	down := &HTTP{URL: "http://127.0.0.1:1/moderate"}
+	ctx := context.Background()
+	if v := New(Config{Incoming: []Moderator{down}}).Check(ctx, DirectionIncoming, "hi"); v.Action != ActionAllow {
+		t.Errorf("fail open verdict = %+v", v)
+	}
+	if v := New(Config{Incoming: []Moderator{down}, FailClosed: true}).Check(ctx, DirectionIncoming, "hi"); v.Action != ActionBlock {
+		t.Errorf("fail closed verdict = %+v", v)
+	}
+}
+
+func TestOpenAI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model, Input string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/v1/moderations" || r.Header.Get("Authorization") != "Bearer key" || req.Model != DefaultOpenAIModel {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		flagged := req.Input == "threat"
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]interface{}{{
+				"flagged":    flagged,
+				"categories": map[string]bool{"violence": flagged, "hate": false},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	o := &OpenAI{APIKey: "key", BaseURL: server.URL + "/v1"}
+	ctx := context.Background()
+	if v, err := o.Moderate(ctx, DirectionIncoming, "hello"); err != nil || v.Action != ActionAllow {
+		t.Errorf("hello = %+v, %v", v, err)
+	}
+	v, err := o.Moderate(ctx, DirectionIncoming, "threat")
+	if err != nil || v.Action != ActionBlock || len(v.Categories) != 1 || v.Categories[0] != "violence" {
+		t.Errorf("threat = %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,87 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Common patterns for Regexp.
+const (
+	EmailPattern      = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+	CardNumberPattern = `\b(?:\d[ -]?){12,18}\d\b`
+)
+
+// DefaultReplacement replaces the matches of redacting rules.
+const DefaultReplacement = "[redacted]"
+
+// Rule is a built-in moderator that matches content against a regular
+// expression. It needs no external service.
+type Rule struct {
+	// Category is reported in verdicts and names the rule.
+	Category string
+
+	// Action is taken when the pattern matches.
+	Action Action
+
+	Pattern *regexp.Regexp
+
+	// Replacement replaces matches when Action is ActionRedact (default:
+	// DefaultReplacement).
+	Replacement string
+}
+
+// Keywords returns a rule matching any of words, case-insensitively and as
+// whole words or phrases.
+func Keywords(category string, action Action, words ...string) *Rule {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(strings.TrimSpace(w))
+	}
+	return &Rule{
+		Category: category,
+		Action:   action,
+		Pattern:  regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+// Regexp returns a rule matching any of patterns.
+func Regexp(category string, action Action, patterns ...string) (*Rule, error) {
+	re, err := regexp.Compile(`(?:` + strings.Join(patterns, `)|(?:`) + `)`)
+	if err != nil {
+		return nil, fmt.Errorf("compile %s patterns: %w", category, err)
+	}
+	return &Rule{Category: category, Action: action, Pattern: re}, nil
+}
+
+// Name returns the rule name.
+func (r *Rule) Name() string {
+	return "rule:" + r.Category
+}
+
+// Moderate takes the rule's action if the pattern matches content.
+func (r *Rule) Moderate(_ context.Context, _ Direction, content string) (Verdict, error) {
+	match := r.Pattern.FindString(content)
+	if match == "" {
+		return Verdict{Action: ActionAllow}, nil
+	}
+
+	v := Verdict{
+		Action:     r.Action,
+		Categories: []string{r.Category},
+		Reason:     fmt.Sprintf("matched %q", match),
+	}
+	if r.Action == ActionRedact {
+		replacement := r.Replacement
+		if replacement == "" {
+			replacement = DefaultReplacement
+		}
+		v.Content = r.Pattern.ReplaceAllLiteralString(content, replacement)
+		v.Reason = ""
+	}
+	return v, nil
+}
+
+// Ensure Rule implements Moderator.
+var _ Moderator = (*Rule)(nil)
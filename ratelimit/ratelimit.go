@@ -0,0 +1,278 @@
+// Package ratelimit throttles incoming messages per sender, per chat, and
+// globally using token buckets.
+//
+// A Limiter runs as router middleware; throttled messages never reach
+// handlers or the agent:
+//
+//	limiter := ratelimit.New(ratelimit.Config{
+//		Sender:    router,
+//		PerSender: ratelimit.Rate{Events: 5, Per: time.Minute},
+//		PerChat:   ratelimit.Rate{Events: 20, Per: time.Minute},
+//	})
+//	router.Use(limiter.Middleware())
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DefaultMessage is the reply sent to throttled senders.
+const DefaultMessage = "You're sending messages too quickly. Please slow down."
+
+// DefaultNoticeInterval is the minimum time between slow-down replies to
+// the same sender.
+const DefaultNoticeInterval = time.Minute
+
+// Rate is a token bucket refilling Events tokens every Per, holding at most
+// Burst tokens (default: Events). The zero Rate is unlimited.
+type Rate struct {
+	Events int
+	Per    time.Duration
+	Burst  int
+}
+
+// unlimited reports whether the rate imposes no limit.
+func (r Rate) unlimited() bool {
+	return r.Events <= 0 || r.Per <= 0
+}
+
+// burst returns the bucket capacity.
+func (r Rate) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Events)
+}
+
+// Scope identifies which limit throttled a message.
+type Scope string
+
+const (
+	ScopeSender Scope = "sender"
+	ScopeChat   Scope = "chat"
+	ScopeGlobal Scope = "global"
+)
+
+// Config configures a Limiter.
+type Config struct {
+	// PerSender limits each sender on each channel.
+	PerSender Rate
+
+	// PerChat limits each chat.
+	PerChat Rate
+
+	// Global limits all messages together.
+	Global Rate
+
+	// Sender delivers slow-down replies. If nil, throttled messages are
+	// dropped silently.
+	Sender channels.Sender
+
+	// Message is the slow-down reply (default: DefaultMessage). Set
+	// MessageFunc to customize it per message and scope.
+	Message string
+
+	// MessageFunc, if set, overrides Message. Returning "" suppresses the
+	// reply.
+	MessageFunc func(msg channels.IncomingMessage, scope Scope) string
+
+	// NoticeInterval is the minimum time between slow-down replies to the
+	// same sender (default: 1m).
+	NoticeInterval time.Duration
+
+	Logger *slog.Logger
+}
+
+// Limiter throttles incoming messages.
+type Limiter struct {
+	config Config
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	senders   map[string]*bucket
+	chats     map[string]*bucket
+	global    *bucket
+	notices   map[string]time.Time
+	lastSweep time.Time
+}
+
+// New creates a new Limiter.
+func New(config Config) *Limiter {
+	if config.Message == "" {
+		config.Message = DefaultMessage
+	}
+	if config.NoticeInterval <= 0 {
+		config.NoticeInterval = DefaultNoticeInterval
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Limiter{
+		config:  config,
+		logger:  config.Logger,
+		now:     time.Now,
+		senders: make(map[string]*bucket),
+		chats:   make(map[string]*bucket),
+		notices: make(map[string]time.Time),
+	}
+}
+
+// Middleware returns router middleware that drops throttled messages and
+// replies with the slow-down message.
+func (l *Limiter) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			scope, ok := l.Allow(msg)
+			if ok {
+				return next(ctx, msg)
+			}
+
+			l.logger.Warn("message rate limited",
+				"channel", msg.ChannelName,
+				"chat", msg.ChatID,
+				"sender", msg.SenderID,
+				"scope", scope)
+			return l.notify(ctx, msg, scope)
+		}
+	}
+}
+
+// Allow consumes a token from each applicable bucket and reports whether the
+// message is within limits. If not, it returns the scope that was exceeded;
+// no tokens are consumed in that case.
+func (l *Limiter) Allow(msg channels.IncomingMessage) (Scope, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	type check struct {
+		scope  Scope
+		bucket *bucket
+	}
+	var checks []check
+	if !l.config.PerSender.unlimited() {
+		key := msg.ChannelName + "\x00" + msg.SenderID
+		checks = append(checks, check{ScopeSender, l.bucket(l.senders, key, l.config.PerSender, now)})
+	}
+	if !l.config.PerChat.unlimited() {
+		key := msg.ChannelName + "\x00" + msg.ChatID
+		checks = append(checks, check{ScopeChat, l.bucket(l.chats, key, l.config.PerChat, now)})
+	}
+	if !l.config.Global.unlimited() {
+		if l.global == nil {
+			l.global = newBucket(l.config.Global, now)
+		}
+		checks = append(checks, check{ScopeGlobal, l.global})
+	}
+
+	for _, c := range checks {
+		c.bucket.refill(now)
+		if c.bucket.tokens < 1 {
+			return c.scope, false
+		}
+	}
+	for _, c := range checks {
+		c.bucket.tokens--
+	}
+	return "", true
+}
+
+// notify sends the slow-down reply unless one was sent to the sender
+// recently.
+func (l *Limiter) notify(ctx context.Context, msg channels.IncomingMessage, scope Scope) error {
+	if l.config.Sender == nil {
+		return nil
+	}
+
+	text := l.config.Message
+	if l.config.MessageFunc != nil {
+		text = l.config.MessageFunc(msg, scope)
+	}
+	if text == "" {
+		return nil
+	}
+
+	key := msg.ChannelName + "\x00" + msg.ChatID + "\x00" + msg.SenderID
+	l.mu.Lock()
+	now := l.now()
+	if last, ok := l.notices[key]; ok && now.Sub(last) < l.config.NoticeInterval {
+		l.mu.Unlock()
+		return nil
+	}
+	l.notices[key] = now
+	l.mu.Unlock()
+
+	return l.config.Sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+		Content: text,
+		ReplyTo: msg.ID,
+	})
+}
+
+// bucket returns the bucket for key, creating a full one if needed.
+func (l *Limiter) bucket(buckets map[string]*bucket, key string, rate Rate, now time.Time) *bucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = newBucket(rate, now)
+		buckets[key] = b
+	}
+	return b
+}
+
+// sweep drops buckets that have refilled completely and stale notices so
+// memory stays bounded by recently active senders and chats. The caller
+// must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for _, buckets := range []map[string]*bucket{l.senders, l.chats} {
+		for key, b := range buckets {
+			b.refill(now)
+			if b.tokens >= b.capacity {
+				delete(buckets, key)
+			}
+		}
+	}
+	for key, t := range l.notices {
+		if now.Sub(t) >= l.config.NoticeInterval {
+			delete(l.notices, key)
+		}
+	}
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	tokens   float64
+	capacity float64
+	perSec   float64
+	updated  time.Time
+}
+
+func newBucket(rate Rate, now time.Time) *bucket {
+	return &bucket{
+		tokens:   rate.burst(),
+		capacity: rate.burst(),
+		perSec:   float64(rate.Events) / rate.Per.Seconds(),
+		updated:  now,
+	}
+}
+
+// refill adds the tokens accrued since the last update.
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.perSec
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.updated = now
+}
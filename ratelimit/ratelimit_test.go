@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+func TestLimiterPerSender(t *testing.T) {
+	sender := &channelstest.Sender{}
+	l := New(Config{
+		Sender:    sender,
+		PerSender: Rate{Events: 2, Per: time.Minute},
+	})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	var handled int
+	handler := l.Middleware()(func(context.Context, channels.IncomingMessage) error {
+		handled++
+		return nil
+	})
+
+	ctx := context.Background()
+	alice := channels.IncomingMessage{ChannelName: "telegram", ChatID: "1", SenderID: "alice"}
+	bob := channels.IncomingMessage{ChannelName: "telegram", ChatID: "1", SenderID: "bob"}
+	for i := 0; i < 4; i++ {
+		if err := handler(ctx, alice); err != nil {
+			t.Fatalf("handler failed: %v", err)
+		}
+	}
+	if err := handler(ctx, bob); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if handled != 3 {
+		t.Errorf("handled = %d, want 3 (2 from alice, 1 from bob)", handled)
+	}
+	if len(sender.Sent()) != 1 || sender.Sent()[0].Content != DefaultMessage {
+		t.Errorf("sent = %+v, want a single slow-down reply", sender.Sent())
+	}
+
+	// Tokens refill over time.
+	now = now.Add(30 * time.Second)
+	if _, ok := l.Allow(alice); !ok {
+		t.Error("alice should be allowed after refill")
+	}
+}
+
+func TestLimiterScopes(t *testing.T) {
+	l := New(Config{
+		PerChat: Rate{Events: 3, Per: time.Minute},
+		Global:  Rate{Events: 4, Per: time.Minute},
+	})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	msg := func(chat string) channels.IncomingMessage {
+		return channels.IncomingMessage{ChannelName: "discord", ChatID: chat}
+	}
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Allow(msg("a")); !ok {
+			t.Fatalf("message %d should be allowed", i)
+		}
+	}
+	if scope, ok := l.Allow(msg("a")); ok || scope != ScopeChat {
+		t.Errorf("Allow = %q, %v; want chat limit", scope, ok)
+	}
+	if _, ok := l.Allow(msg("b")); !ok {
+		t.Error("other chat should be allowed")
+	}
+	if scope, ok := l.Allow(msg("c")); ok || scope != ScopeGlobal {
+		t.Errorf("Allow = %q, %v; want global limit", scope, ok)
+	}
+}
@@ -0,0 +1,255 @@
+// Package redact masks personal data such as email addresses, phone
+// numbers, and card numbers in messages before the agent sees them and
+// before transcripts store them.
+//
+// Each match is replaced with a token naming its kind, e.g.
+// "[EMAIL:9f86d081884c7d65]". With a Vault configured, tokens are
+// reversible: Restore swaps them back for the original values, for replay
+// by authorized staff. The same value always gets the same token, so the
+// agent can still tell that two messages mention the same address.
+//
+//	redactor := redact.New(redact.Config{Vault: redact.NewMemoryVault(), Key: key})
+//	router := channels.NewRouter(logger,
+//		channels.WithTranscript(redactor.Transcript(history.NewTranscript(messages))))
+//	router.Use(redactor.Middleware())
+package redact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// ErrUnknownToken is returned by vaults for tokens they do not hold.
+var ErrUnknownToken = errors.New("unknown token")
+
+// Pattern detects one kind of personal data.
+type Pattern struct {
+	// Name is the kind of data, used in tokens, e.g. "EMAIL". It should
+	// consist of upper-case letters, digits, and underscores.
+	Name string
+
+	Regexp *regexp.Regexp
+
+	// Valid, if set, rejects false positives among the matches.
+	Valid func(match string) bool
+}
+
+// Built-in patterns.
+var (
+	Email = Pattern{
+		Name:   "EMAIL",
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}
+	CreditCard = Pattern{
+		Name:   "CARD",
+		Regexp: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Valid:  luhn,
+	}
+	Phone = Pattern{
+		Name:   "PHONE",
+		Regexp: regexp.MustCompile(`(?:\+|\b)\d[\d\s().-]{6,}\d\b`),
+		Valid: func(match string) bool {
+			n := len(digits(match))
+			return n >= 9 && n <= 15
+		},
+	}
+)
+
+// DefaultPatterns are the patterns used when Config.Patterns is empty.
+var DefaultPatterns = []Pattern{Email, CreditCard, Phone}
+
+// NewPattern compiles a custom pattern.
+func NewPattern(name, expr string) (Pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Pattern{}, fmt.Errorf("compile %s pattern: %w", name, err)
+	}
+	return Pattern{Name: name, Regexp: re}, nil
+}
+
+// Vault stores the values behind reversible tokens.
+type Vault interface {
+	// Put stores the value of a token.
+	Put(ctx context.Context, token, value string) error
+
+	// Get returns the value of a token, or ErrUnknownToken.
+	Get(ctx context.Context, token string) (string, error)
+}
+
+// Config configures a Redactor.
+type Config struct {
+	// Patterns detect the data to mask, earlier patterns winning over
+	// later ones that match the same text (default: DefaultPatterns).
+	// Append custom patterns to DefaultPatterns to keep the built-ins.
+	Patterns []Pattern
+
+	// Vault, if set, makes tokens reversible. Without it, matches are
+	// replaced with their kind alone, e.g. "[EMAIL]".
+	Vault Vault
+
+	// Key keys the token hash. Set it when the vault outlives the process,
+	// so values keep their tokens across restarts (default: random).
+	Key []byte
+}
+
+func (c Config) withDefaults() Config {
+	if len(c.Patterns) == 0 {
+		c.Patterns = DefaultPatterns
+	}
+	if len(c.Key) == 0 {
+		c.Key = make([]byte, 32)
+		_, _ = rand.Read(c.Key)
+	}
+	return c
+}
+
+// Redactor masks and restores personal data.
+type Redactor struct {
+	config Config
+}
+
+// New creates a new Redactor.
+func New(config Config) *Redactor {
+	return &Redactor{config: config.withDefaults()}
+}
+
+// match is a span of text detected by a pattern.
+type match struct {
+	start, end int
+	pattern    int
+}
+
+// Redact returns text with its personal data replaced by tokens.
+func (r *Redactor) Redact(ctx context.Context, text string) (string, error) {
+	var matches []match
+	for i, p := range r.config.Patterns {
+		for _, loc := range p.Regexp.FindAllStringIndex(text, -1) {
+			if p.Valid == nil || p.Valid(text[loc[0]:loc[1]]) {
+				matches = append(matches, match{loc[0], loc[1], i})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return text, nil
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].pattern < matches[j].pattern
+	})
+
+	var sb strings.Builder
+	end := 0
+	for _, m := range matches {
+		if m.start < end {
+			continue
+		}
+		token, err := r.token(ctx, r.config.Patterns[m.pattern].Name, text[m.start:m.end])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(text[end:m.start])
+		sb.WriteString(token)
+		end = m.end
+	}
+	sb.WriteString(text[end:])
+	return sb.String(), nil
+}
+
+// token returns the token for a value, storing it in the vault if any.
+func (r *Redactor) token(ctx context.Context, kind, value string) (string, error) {
+	if r.config.Vault == nil {
+		return "[" + kind + "]", nil
+	}
+	mac := hmac.New(sha256.New, r.config.Key)
+	mac.Write([]byte(value))
+	token := "[" + kind + ":" + hex.EncodeToString(mac.Sum(nil)[:8]) + "]"
+	if err := r.config.Vault.Put(ctx, token, value); err != nil {
+		return "", fmt.Errorf("store token: %w", err)
+	}
+	return token, nil
+}
+
+// tokenPattern matches reversible tokens.
+var tokenPattern = regexp.MustCompile(`\[[A-Z0-9_]+:[0-9a-f]{16}\]`)
+
+// Restore returns text with its reversible tokens replaced by the original
+// values. Tokens missing from the vault are left as they are.
+func (r *Redactor) Restore(ctx context.Context, text string) (string, error) {
+	if r.config.Vault == nil {
+		return text, nil
+	}
+	var err error
+	restored := tokenPattern.ReplaceAllStringFunc(text, func(token string) string {
+		if err != nil {
+			return token
+		}
+		value, getErr := r.config.Vault.Get(ctx, token)
+		if getErr != nil {
+			if !errors.Is(getErr, ErrUnknownToken) {
+				err = fmt.Errorf("load token: %w", getErr)
+			}
+			return token
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return restored, nil
+}
+
+// Middleware returns router middleware that masks incoming messages before
+// they reach handlers. Messages that cannot be masked are dropped rather
+// than passed on unmasked.
+func (r *Redactor) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			content, err := r.Redact(ctx, msg.Content)
+			if err != nil {
+				return fmt.Errorf("redact message: %w", err)
+			}
+			msg.Content = content
+			return next(ctx, msg)
+		}
+	}
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of card
+// numbers.
+func luhn(s string) bool {
+	d := digits(s)
+	sum := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if (len(d)-i)%2 == 0 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// digits returns the digits of s.
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,74 @@
+package redact
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/history"
+	"github.com/agentplexus/envoy/store"
+)
+
+func TestRedact(t *testing.T) {
+	order, err := NewPattern("ORDER", `\bORD-\d{6}\b`)
+	if err != nil {
+		t.Fatalf("NewPattern failed: %v", err)
+	}
+	r := New(Config{Patterns: append(DefaultPatterns, order)})
+	ctx := context.Background()
+
+	cases := map[string]string{
+		"mail ann@example.com today":          "mail [EMAIL] today",
+		"card 4111 1111 1111 1111 please":     "card [CARD] please",
+		"card 4111 1111 1111 1112 is invalid": "card 4111 1111 1111 1112 is invalid",
+		"call +1 (555) 123-4567":              "call [PHONE]",
+		"meeting on 2024-01-15":               "meeting on 2024-01-15",
+		"where is ORD-123456?":                "where is [ORDER]?",
+	}
+	for in, want := range cases {
+		got, err := r.Redact(ctx, in)
+		if err != nil || got != want {
+			t.Errorf("Redact(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestTokensAndReplay(t *testing.T) {
+	r := New(Config{Vault: NewMemoryVault(), Key: []byte("key")})
+	ctx := context.Background()
+
+	first, _ := r.Redact(ctx, "I'm ann@example.com")
+	second, _ := r.Redact(ctx, "again: ann@example.com")
+	token := strings.TrimPrefix(first, "I'm ")
+	if !tokenPattern.MatchString(token) || second != "again: "+token {
+		t.Fatalf("tokens = %q, %q", first, second)
+	}
+	if got, _ := r.Restore(ctx, first+" [EMAIL:0000000000000000]"); got != "I'm ann@example.com [EMAIL:0000000000000000]" {
+		t.Errorf("Restore = %q", got)
+	}
+
+	// Handlers see masked content, and so does the stored transcript
+	var seen string
+	handler := r.Middleware()(func(_ context.Context, msg channels.IncomingMessage) error {
+		seen = msg.Content
+		return nil
+	})
+	msg := channels.IncomingMessage{ID: "1", ChannelName: "telegram", ChatID: "42", Content: "I'm ann@example.com"}
+	if err := handler(ctx, msg); err != nil || seen != first {
+		t.Errorf("handler saw %q, %v", seen, err)
+	}
+	messages := store.NewMemoryStore()
+	if err := r.Transcript(history.NewTranscript(messages)).RecordIncoming(ctx, msg); err != nil {
+		t.Fatalf("RecordIncoming failed: %v", err)
+	}
+
+	page, err := messages.List(ctx, store.ListQuery{})
+	if err != nil || len(page.Messages) != 1 || page.Messages[0].Content != first {
+		t.Fatalf("stored = %+v, %v", page, err)
+	}
+	page, err = r.Reveal(messages).List(ctx, store.ListQuery{})
+	if err != nil || page.Messages[0].Content != "I'm ann@example.com" {
+		t.Errorf("revealed = %+v, %v", page, err)
+	}
+}
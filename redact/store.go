@@ -0,0 +1,107 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+// Transcript returns a transcript that masks messages before recording
+// them in next. The router records incoming messages before running
+// middleware, so transcripts need masking of their own.
+func (r *Redactor) Transcript(next channels.Transcript) channels.Transcript {
+	return &transcript{redactor: r, next: next}
+}
+
+// transcript is a masking channels.Transcript.
+type transcript struct {
+	redactor *Redactor
+	next     channels.Transcript
+}
+
+func (t *transcript) RecordIncoming(ctx context.Context, msg channels.IncomingMessage) error {
+	content, err := t.redactor.Redact(ctx, msg.Content)
+	if err != nil {
+		return fmt.Errorf("redact message: %w", err)
+	}
+	msg.Content = content
+	return t.next.RecordIncoming(ctx, msg)
+}
+
+func (t *transcript) RecordOutgoing(ctx context.Context, channelName, chatID, messageID string, msg channels.OutgoingMessage) error {
+	content, err := t.redactor.Redact(ctx, msg.Content)
+	if err != nil {
+		return fmt.Errorf("redact message: %w", err)
+	}
+	msg.Content = content
+	return t.next.RecordOutgoing(ctx, channelName, chatID, messageID, msg)
+}
+
+// Reveal returns a lister that restores the tokens in the messages listed
+// by l. Give it only to callers authorized to see personal data, such as
+// compliance replays.
+func (r *Redactor) Reveal(l store.Lister) store.Lister {
+	return &revealer{redactor: r, next: l}
+}
+
+// revealer is a restoring store.Lister.
+type revealer struct {
+	redactor *Redactor
+	next     store.Lister
+}
+
+func (v *revealer) List(ctx context.Context, query store.ListQuery) (*store.Page, error) {
+	page, err := v.next.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]store.Message, len(page.Messages))
+	for i, msg := range page.Messages {
+		if msg.Content, err = v.redactor.Restore(ctx, msg.Content); err != nil {
+			return nil, err
+		}
+		messages[i] = msg
+	}
+	return &store.Page{Messages: messages, Next: page.Next}, nil
+}
+
+// MemoryVault is an in-memory Vault for single-process deployments. Its
+// tokens cannot be restored after a restart.
+type MemoryVault struct {
+	values map[string]string
+	mu     sync.RWMutex
+}
+
+// NewMemoryVault creates a new in-memory vault.
+func NewMemoryVault() *MemoryVault {
+	return &MemoryVault{values: make(map[string]string)}
+}
+
+// Put stores the value of a token.
+func (v *MemoryVault) Put(_ context.Context, token, value string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[token] = value
+	return nil
+}
+
+// Get returns the value of a token.
+func (v *MemoryVault) Get(_ context.Context, token string) (string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, ok := v.values[token]
+	if !ok {
+		return "", ErrUnknownToken
+	}
+	return value, nil
+}
+
+// Ensure the types implement their interfaces.
+var (
+	_ channels.Transcript = (*transcript)(nil)
+	_ store.Lister        = (*revealer)(nil)
+	_ Vault               = (*MemoryVault)(nil)
+)
@@ -0,0 +1,179 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AWS reads secrets from AWS Secrets Manager. Names are "<secret-id>" for
+// plain secrets, or "<secret-id>#<key>" for a key of a JSON secret.
+type AWS struct {
+	// Region is the AWS region (default: the AWS_REGION or
+	// AWS_DEFAULT_REGION environment variable).
+	Region string
+
+	// AccessKeyID, SecretAccessKey, and SessionToken sign requests
+	// (defaults: the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+	// AWS_SESSION_TOKEN environment variables).
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Endpoint overrides the regional endpoint, e.g. for VPC endpoints.
+	Endpoint string
+
+	// Client sends requests (default: a client with DefaultTimeout).
+	Client *http.Client
+}
+
+// Get returns the current version of a secret.
+func (a *AWS) Get(ctx context.Context, name string) (string, error) {
+	region := firstNonEmpty(a.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	accessKey := firstNonEmpty(a.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secretKey := firstNonEmpty(a.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	sessionToken := firstNonEmpty(a.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+	if region == "" || accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("aws region and credentials required")
+	}
+	endpoint := firstNonEmpty(a.Endpoint, "https://secretsmanager."+region+".amazonaws.com")
+	id, key := splitKey(name)
+
+	body, err := json.Marshal(map[string]string{"SecretId": id})
+	if err != nil {
+		return "", fmt.Errorf("encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+	signV4(req, body, region, "secretsmanager", accessKey, secretKey, time.Now().UTC())
+
+	client := a.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("aws request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if bytes.Contains(msg, []byte("ResourceNotFoundException")) {
+			return "", fmt.Errorf("%w: aws %s", ErrNotFound, id)
+		}
+		return "", fmt.Errorf("aws %s: %s: %s", id, resp.Status, msg)
+	}
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if key == "" {
+		return out.SecretString, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("aws %s is not a JSON secret: %w", id, err)
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: aws %s has no string key %q", ErrNotFound, id, key)
+	}
+	return value, nil
+}
+
+// signV4 signs req with AWS Signature Version 4.
+func signV4(req *http.Request, body []byte, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	var names []string
+	for _, name := range []string{"content-type", "host", "x-amz-date", "x-amz-security-token", "x-amz-target"} {
+		if name == "host" || req.Header.Get(name) != "" {
+			names = append(names, name)
+		}
+	}
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		signed,
+		hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signed, signature))
+}
+
+// canonicalQuery returns the sorted, escaped query string.
+func canonicalQuery(q url.Values) string {
+	return strings.ReplaceAll(q.Encode(), "+", "%20")
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Ensure AWS implements Provider.
+var _ Provider = (*AWS)(nil)
@@ -0,0 +1,123 @@
+// Package secrets fetches credentials such as bot tokens from secret
+// stores, so configs reference them instead of holding them.
+//
+// A reference names a provider scheme and a secret, e.g. "env:BOT_TOKEN",
+// "file:telegram_token", "vault:bots/telegram#token", or
+// "aws:prod/envoy#discord". A Resolver maps schemes to providers, and a
+// Watcher polls references and reports rotated secrets:
+//
+//	resolver := secrets.NewResolver(map[string]secrets.Provider{
+//		"vault": &secrets.Vault{Address: "https://vault.example.com:8200"},
+//	})
+//	token, err := resolver.Resolve(ctx, "vault:bots/telegram#token")
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a request to a remote secret store.
+const DefaultTimeout = 10 * time.Second
+
+// ErrNotFound is returned for secrets a provider does not hold.
+var ErrNotFound = errors.New("secret not found")
+
+// defaultClient sends the requests of providers without a Client.
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// Provider fetches secrets by name.
+type Provider interface {
+	// Get returns the current value of a secret, or ErrNotFound.
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+// Get calls f.
+func (f ProviderFunc) Get(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// Env reads secrets from environment variables.
+type Env struct {
+	// Prefix is prepended to names, e.g. "ENVOY_".
+	Prefix string
+}
+
+// Get returns the environment variable Prefix+name.
+func (e Env) Get(_ context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(e.Prefix + name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s", ErrNotFound, e.Prefix+name)
+	}
+	return v, nil
+}
+
+// File reads secrets from files, such as Docker or Kubernetes secret
+// mounts. Trailing newlines are removed.
+type File struct {
+	// Dir is the directory names are relative to (default: the working
+	// directory). Names may not leave it.
+	Dir string
+}
+
+// Get returns the content of the file name.
+func (f File) Get(_ context.Context, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid secret file %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(f.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: file %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Resolver resolves secret references with the provider of their scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver creates a Resolver. The "env" and "file" schemes are
+// available unless providers replaces them.
+func NewResolver(providers map[string]Provider) *Resolver {
+	r := &Resolver{providers: map[string]Provider{"env": Env{}, "file": File{}}}
+	for scheme, p := range providers {
+		r.providers[scheme] = p
+	}
+	return r
+}
+
+// Resolve returns the value of a "<scheme>:<name>" reference.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, name, ok := strings.Cut(ref, ":")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference %q, want scheme:name", ref)
+	}
+	p, ok := r.providers[scheme]
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q", scheme)
+	}
+	v, err := p.Get(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	return v, nil
+}
+
+// splitKey splits "name#key" into its parts.
+func splitKey(name string) (string, string) {
+	name, key, _ := strings.Cut(name, "#")
+	return name, key
+}
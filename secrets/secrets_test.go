@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bot_token"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SECRETS_TOKEN", "from-env")
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data/bots/telegram" || r.Header.Get("X-Vault-Token") != "vt" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"token": "from-vault"}}}`))
+	}))
+	defer vault.Close()
+
+	r := NewResolver(map[string]Provider{
+		"file":  File{Dir: dir},
+		"vault": &Vault{Address: vault.URL, Token: "vt", Mount: "kv"},
+	})
+	ctx := context.Background()
+	for ref, want := range map[string]string{
+		"env:TEST_SECRETS_TOKEN":     "from-env",
+		"file:bot_token":             "from-file",
+		"vault:bots/telegram#token":  "from-vault",
+		"vault:bots/telegram#absent": "",
+		"vault:bots/discord#token":   "",
+		"env:TEST_SECRETS_UNSET":     "",
+	} {
+		got, err := r.Resolve(ctx, ref)
+		if want == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Resolve(%q) error = %v, want ErrNotFound", ref, err)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"file:../etc/passwd", "nope:x", "no-scheme"} {
+		if _, err := r.Resolve(ctx, ref); err == nil {
+			t.Errorf("Resolve(%q) succeeded", ref)
+		}
+	}
+}
+
+func TestSignV4(t *testing.T) {
+	// The "get-vanilla" case of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signV4(req, nil, "us-east-1", "service", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestAWS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ SecretId string }
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/secretsmanager/aws4_request") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if in.SecretId != "prod/envoy" {
+			http.Error(w, `{"__type": "ResourceNotFoundException"}`, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"SecretString": "{\"discord\": \"from-aws\"}"}`))
+	}))
+	defer server.Close()
+
+	a := &AWS{Region: "eu-west-1", AccessKeyID: "id", SecretAccessKey: "key", Endpoint: server.URL}
+	ctx := context.Background()
+	if got, err := a.Get(ctx, "prod/envoy#discord"); err != nil || got != "from-aws" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if _, err := a.Get(ctx, "prod/other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing secret error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	value := "v1"
+	r := NewResolver(map[string]Provider{
+		"test": ProviderFunc(func(context.Context, string) (string, error) { return value, nil }),
+	})
+	w := NewWatcher(WatchConfig{Resolver: r, Refs: []string{"test:a"}})
+	ctx := context.Background()
+
+	if rotated := w.Check(ctx); len(rotated) != 0 {
+		t.Errorf("first check rotated %q", rotated)
+	}
+	if rotated := w.Check(ctx); len(rotated) != 0 {
+		t.Errorf("unchanged check rotated %q", rotated)
+	}
+	value = "v2"
+	if rotated := w.Check(ctx); len(rotated) != 1 || rotated[0] != "test:a" {
+		t.Errorf("rotated = %q, want [test:a]", rotated)
+	}
+}
@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Vault reads secrets from a HashiCorp Vault KV version 2 engine. Names are
+// "<path>#<key>"; the key defaults to "value".
+type Vault struct {
+	// Address is the Vault address (default: the VAULT_ADDR environment
+	// variable).
+	Address string
+
+	// Token authenticates requests (default: the VAULT_TOKEN environment
+	// variable).
+	Token string
+
+	// Mount is the KV engine's mount path (default: "secret").
+	Mount string
+
+	// Client sends requests (default: a client with DefaultTimeout).
+	Client *http.Client
+}
+
+// Get returns a key of the latest version of a secret.
+func (v *Vault) Get(ctx context.Context, name string) (string, error) {
+	address, token, mount := v.Address, v.Token, v.Mount
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if mount == "" {
+		mount = "secret"
+	}
+	if address == "" {
+		return "", fmt.Errorf("vault address required")
+	}
+	path, key := splitKey(name)
+	if key == "" {
+		key = "value"
+	}
+
+	url := strings.TrimSuffix(address, "/") + "/v1/" + strings.Trim(mount, "/") + "/data/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	client := v.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: vault %s", ErrNotFound, path)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("vault %s: %s: %s", path, resp.Status, msg)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	value, ok := body.Data.Data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: vault %s has no string key %q", ErrNotFound, path, key)
+	}
+	return value, nil
+}
+
+// Ensure Vault implements Provider.
+var _ Provider = (*Vault)(nil)
@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"time"
+)
+
+// DefaultWatchInterval is how often a Watcher checks its references.
+const DefaultWatchInterval = 5 * time.Minute
+
+// WatchConfig configures a Watcher.
+type WatchConfig struct {
+	Resolver *Resolver
+
+	// Refs are the references to watch.
+	Refs []string
+
+	// Interval is the time between checks (default: DefaultWatchInterval).
+	Interval time.Duration
+
+	// OnRotate is called with the references whose values changed since
+	// the previous check, e.g. to reconnect the adapters using them.
+	OnRotate func(ctx context.Context, refs []string)
+
+	Logger *slog.Logger
+}
+
+// Watcher polls secret references for rotations.
+type Watcher struct {
+	config WatchConfig
+	hashes map[string][sha256.Size]byte
+}
+
+// NewWatcher creates a Watcher.
+func NewWatcher(config WatchConfig) *Watcher {
+	if config.Interval <= 0 {
+		config.Interval = DefaultWatchInterval
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Watcher{config: config, hashes: make(map[string][sha256.Size]byte)}
+}
+
+// Run checks the references every interval until ctx is done. The first
+// check records the current values. Failed lookups are logged and retried
+// at the next check.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.Check(ctx)
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if rotated := w.Check(ctx); len(rotated) > 0 && w.config.OnRotate != nil {
+				w.config.OnRotate(ctx, rotated)
+			}
+		}
+	}
+}
+
+// Check looks up every reference and returns those whose values changed
+// since the previous check. Only hashes of the values are kept.
+func (w *Watcher) Check(ctx context.Context) []string {
+	var rotated []string
+	for _, ref := range w.config.Refs {
+		value, err := w.config.Resolver.Resolve(ctx, ref)
+		if err != nil {
+			w.config.Logger.Warn("secret lookup failed", "ref", ref, "error", err)
+			continue
+		}
+		hash := sha256.Sum256([]byte(value))
+		if previous, ok := w.hashes[ref]; ok && previous != hash {
+			w.config.Logger.Info("secret rotated", "ref", ref)
+			rotated = append(rotated, ref)
+		}
+		w.hashes[ref] = hash
+	}
+	return rotated
+}
@@ -0,0 +1,185 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/agentplexus/envoy/acl"
+	"github.com/agentplexus/envoy/channels"
+)
+
+// ResetCommandPrefix is the chat command that resets the sender's session.
+const ResetCommandPrefix = "/reset"
+
+// ResetCommand returns a handler for "/reset" that ends the current session
+// and confirms in the chat. Register it with a higher priority than the
+// agent handler and Exclusive set so the command is not sent to the agent.
+func ResetCommand(manager *Manager, sender channels.Sender) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		if _, ok := channels.ParseCommand(msg.Content, ResetCommandPrefix); !ok {
+			return nil
+		}
+		if err := manager.Reset(ctx, msg); err != nil {
+			return err
+		}
+		return sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+			Content: "Conversation reset. Let's start fresh.",
+			ReplyTo: msg.ID,
+		})
+	}
+}
+
+// ConfigCommandPrefix is the chat command managing the session's agent
+// configuration.
+const ConfigCommandPrefix = "/session"
+
+// ConfigCommand returns a handler for the /session command:
+//
+//	/session                      show the session's overrides
+//	/session set <field> <value>  override prompt, model, temperature, or language
+//	/session unset <field>        remove an override
+//
+// Overrides last until the session expires or is reset. Anyone in the chat
+// may change the temperature and language; the prompt and model replace the
+// system prompt and change the cost, so only senders the acl middleware
+// gives the admin role may change those. Register it with a higher
+// priority than the agent handler and Exclusive set.
+func ConfigCommand(manager *Manager, sender channels.Sender) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		args, ok := channels.ParseCommand(msg.Content, ConfigCommandPrefix)
+		if !ok {
+			return nil
+		}
+
+		reply, err := manager.command(ctx, msg, args)
+		if err != nil {
+			return err
+		}
+		return sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+			Content: reply,
+			ReplyTo: msg.ID,
+		})
+	}
+}
+
+// configUsage is the reply to malformed /session commands.
+const configUsage = "Usage: /session [set <field> <value> | unset <field>], fields: prompt, model, temperature, language"
+
+// command runs a /session command and returns the reply.
+func (m *Manager) command(ctx context.Context, msg channels.IncomingMessage, args string) (string, error) {
+	s, err := m.Resolve(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+	config := s.Config
+
+	action, rest, _ := strings.Cut(args, " ")
+	field, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	value = strings.TrimSpace(value)
+	if (action == "set" || action == "unset") && adminField(field) {
+		if role, _ := acl.RoleFromContext(ctx); role != acl.RoleAdmin {
+			return fmt.Sprintf("Only admins can change the %s.", field), nil
+		}
+	}
+	switch action {
+	case "":
+		return describeConfig(config), nil
+
+	case "set":
+		if value == "" {
+			return configUsage, nil
+		}
+		switch field {
+		case "prompt":
+			config.SystemPrompt = value
+		case "model":
+			config.Model = value
+		case "temperature":
+			t, err := strconv.ParseFloat(value, 64)
+			if err != nil || t < 0 || t > 2 {
+				return "Temperature must be a number between 0 and 2.", nil
+			}
+			config.Temperature = &t
+		case "language":
+			if !validLanguage(value) {
+				return fmt.Sprintf("Language must be a name or tag of up to %d letters, e.g. French or pt-BR.", maxLanguageLength), nil
+			}
+			config.Language = value
+		default:
+			return configUsage, nil
+		}
+
+	case "unset":
+		switch field {
+		case "prompt":
+			config.SystemPrompt = ""
+		case "model":
+			config.Model = ""
+		case "temperature":
+			config.Temperature = nil
+		case "language":
+			config.Language = ""
+		default:
+			return configUsage, nil
+		}
+
+	default:
+		return configUsage, nil
+	}
+
+	if err := m.SetConfig(ctx, s.Key, config); err != nil {
+		return "", err
+	}
+	if action == "set" {
+		return fmt.Sprintf("Set %s for this conversation.", field), nil
+	}
+	return fmt.Sprintf("Removed %s.", field), nil
+}
+
+// adminField reports whether only admins may change a /session field.
+func adminField(field string) bool {
+	return field == "prompt" || field == "model"
+}
+
+// maxLanguageLength caps /session languages.
+const maxLanguageLength = 32
+
+// validLanguage reports whether a /session language is a language name or
+// BCP-47 tag: letters, digits, spaces, and hyphens. The language is added
+// to the system prompt, so anything else is rejected.
+func validLanguage(value string) bool {
+	if utf8.RuneCountInString(value) > maxLanguageLength {
+		return false
+	}
+	for _, r := range value {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// describeConfig lists a session's overrides.
+func describeConfig(config channels.SessionConfig) string {
+	if config.IsZero() {
+		return "No session settings."
+	}
+	var lines []string
+	if config.SystemPrompt != "" {
+		lines = append(lines, "prompt = "+config.SystemPrompt)
+	}
+	if config.Model != "" {
+		lines = append(lines, "model = "+config.Model)
+	}
+	if config.Temperature != nil {
+		lines = append(lines, "temperature = "+strconv.FormatFloat(*config.Temperature, 'g', -1, 64))
+	}
+	if config.Language != "" {
+		lines = append(lines, "language = "+config.Language)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,72 @@
+package sessions
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store for single-process deployments.
+type MemoryStore struct {
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+type memoryEntry struct {
+	session   Session
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+// Load returns a copy of a session.
+func (s *MemoryStore) Load(_ context.Context, key string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		return nil, ErrNotFound
+	}
+	session := e.session
+	return &session, nil
+}
+
+// Save creates or replaces a session.
+func (s *MemoryStore) Save(_ context.Context, session *Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	s.sessions[session.Key] = memoryEntry{session: *session, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// sweep discards expired sessions, at most once a minute, so memory stays
+// bounded by recently active conversations. The caller must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.sessions {
+		if now.After(e.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+// Delete removes a session.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+// Ensure MemoryStore implements Store.
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,84 @@
+// Package redisstore provides a Redis-backed session store, so sessions
+// survive restarts and are shared between envoy instances.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/agentplexus/envoy/sessions"
+)
+
+// Store keeps sessions in Redis as JSON values with a TTL.
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// Config configures the Redis store.
+type Config struct {
+	// Client is the Redis client.
+	Client redis.UniversalClient
+
+	// Prefix is prepended to keys (default: "envoy:session:").
+	Prefix string
+}
+
+// New creates a new Redis session store.
+func New(config Config) (*Store, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("redis client required")
+	}
+	if config.Prefix == "" {
+		config.Prefix = "envoy:session:"
+	}
+	return &Store{
+		client: config.Client,
+		prefix: config.Prefix,
+	}, nil
+}
+
+// Load returns a session, or sessions.ErrNotFound.
+func (s *Store) Load(ctx context.Context, key string) (*sessions.Session, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, sessions.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis get: %w", err)
+	}
+
+	var session sessions.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("decode session: %w", err)
+	}
+	return &session, nil
+}
+
+// Save creates or replaces a session, expiring it after ttl.
+func (s *Store) Save(ctx context.Context, session *sessions.Session, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+	if err := s.client.Set(ctx, s.prefix+session.Key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a session.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+		return fmt.Errorf("redis del: %w", err)
+	}
+	return nil
+}
+
+// Ensure Store implements sessions.Store.
+var _ sessions.Store = (*Store)(nil)
@@ -0,0 +1,60 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/agentplexus/envoy/sessions"
+)
+
+func TestStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	if _, err := New(Config{}); err == nil {
+		t.Error("New without a client succeeded")
+	}
+	s, err := New(Config{Client: client})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := s.Load(ctx, "telegram:1"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Fatalf("Load missing = %v, want ErrNotFound", err)
+	}
+
+	session := &sessions.Session{ID: "abc", Key: "telegram:1", ChannelName: "telegram", ChatID: "1"}
+	if err := s.Save(ctx, session, time.Minute); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if !server.Exists("envoy:session:telegram:1") {
+		t.Error("session not stored under the default prefix")
+	}
+	got, err := s.Load(ctx, "telegram:1")
+	if err != nil || got.ID != "abc" || got.ChatID != "1" {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if _, err := s.Load(ctx, "telegram:1"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("Load expired = %v, want ErrNotFound", err)
+	}
+
+	_ = s.Save(ctx, session, time.Minute)
+	if err := s.Delete(ctx, "telegram:1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Load(ctx, "telegram:1"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("Load deleted = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "telegram:1"); err != nil {
+		t.Errorf("Delete missing = %v", err)
+	}
+}
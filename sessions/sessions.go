@@ -0,0 +1,231 @@
+// Package sessions assigns agent session IDs to conversations.
+//
+// A Manager maps each conversation (a chat, or a sender within a group chat)
+// to a session that expires after a period of inactivity or when reset with
+// the /reset command. Plug it into the router so agents see a fresh session
+// ID whenever a conversation starts over:
+//
+//	manager := sessions.New(sessions.Config{TTL: time.Hour, PerSender: true})
+//	router := channels.NewRouter(logger, channels.WithSessions(manager))
+//	router.AddHandler(channels.RouteHandler{
+//		Pattern:   channels.RoutePattern{Prefix: sessions.ResetCommandPrefix},
+//		Handler:   sessions.ResetCommand(manager, router),
+//		Priority:  100,
+//		Exclusive: true,
+//	})
+//
+// Sessions also carry agent configuration overrides, set with the /session
+// command or the gateway admin API, which the router passes to agents.
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DefaultTTL is how long a session survives without activity.
+const DefaultTTL = 30 * time.Minute
+
+// ErrNotFound is returned by stores for unknown or expired sessions.
+var ErrNotFound = errors.New("session not found")
+
+// Session is an agent conversation.
+type Session struct {
+	// ID is the session ID passed to agents.
+	ID string `json:"id"`
+
+	// Key identifies the conversation the session belongs to.
+	Key string `json:"key"`
+
+	ChannelName string `json:"channel"`
+	ChatID      string `json:"chat_id"`
+
+	// SenderID is set for per-sender sessions in group chats.
+	SenderID string `json:"sender_id,omitempty"`
+
+	CreatedAt  time.Time `json:"created_at"`
+	LastActive time.Time `json:"last_active"`
+
+	// Config overrides agent settings for the session. It is discarded
+	// with the session when it expires or is reset.
+	Config channels.SessionConfig `json:"config"`
+}
+
+// Store persists sessions by key.
+type Store interface {
+	// Load returns the session for a key, or ErrNotFound.
+	Load(ctx context.Context, key string) (*Session, error)
+
+	// Save creates or replaces a session, expiring it after ttl.
+	Save(ctx context.Context, s *Session, ttl time.Duration) error
+
+	// Delete removes a session. Deleting a missing session is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Config configures a Manager.
+type Config struct {
+	// Store persists sessions (default: in-memory).
+	Store Store
+
+	// TTL expires sessions after inactivity (default: 30m).
+	TTL time.Duration
+
+	// PerSender gives each sender in a group chat their own session.
+	// Direct messages always have one session per chat.
+	PerSender bool
+
+	Logger *slog.Logger
+}
+
+// Manager resolves and resets sessions.
+type Manager struct {
+	store     Store
+	ttl       time.Duration
+	perSender bool
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+// New creates a new Manager.
+func New(config Config) *Manager {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Manager{
+		store:     config.Store,
+		ttl:       config.TTL,
+		perSender: config.PerSender,
+		logger:    config.Logger,
+		now:       time.Now,
+	}
+}
+
+// Key returns the conversation key for a message.
+func (m *Manager) Key(msg channels.IncomingMessage) string {
+	key := channels.SessionID(msg.ChannelName, msg.ChatID)
+	if m.perSender && msg.ChatType != channels.ChannelTypeDM && msg.SenderID != "" {
+		key += ":" + msg.SenderID
+	}
+	return key
+}
+
+// Resolve returns the active session for a message, starting a new one if
+// none exists or the previous one expired, and marks it active.
+func (m *Manager) Resolve(ctx context.Context, msg channels.IncomingMessage) (*Session, error) {
+	key := m.Key(msg)
+	now := m.now()
+
+	s, err := m.store.Load(ctx, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		s = nil
+	case err != nil:
+		return nil, fmt.Errorf("load session: %w", err)
+	case now.Sub(s.LastActive) > m.ttl:
+		m.logger.Debug("session expired", "session", s.ID)
+		s = nil
+	}
+
+	if s == nil {
+		s = &Session{
+			ID:          key + ":" + newSuffix(),
+			Key:         key,
+			ChannelName: msg.ChannelName,
+			ChatID:      msg.ChatID,
+			CreatedAt:   now,
+		}
+		if key != channels.SessionID(msg.ChannelName, msg.ChatID) {
+			s.SenderID = msg.SenderID
+		}
+	}
+	s.LastActive = now
+
+	if err := m.store.Save(ctx, s, m.ttl); err != nil {
+		return nil, fmt.Errorf("save session: %w", err)
+	}
+	return s, nil
+}
+
+// SessionID returns the session ID for a message. It satisfies
+// channels.SessionResolver.
+func (m *Manager) SessionID(ctx context.Context, msg channels.IncomingMessage) (string, error) {
+	s, err := m.Resolve(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+	return s.ID, nil
+}
+
+// ResolveSession returns the session ID and configuration for a message. It
+// satisfies channels.SessionConfigResolver, so the router passes the
+// session's configuration to agents.
+func (m *Manager) ResolveSession(ctx context.Context, msg channels.IncomingMessage) (string, channels.SessionConfig, error) {
+	s, err := m.Resolve(ctx, msg)
+	if err != nil {
+		return "", channels.SessionConfig{}, err
+	}
+	return s.ID, s.Config, nil
+}
+
+// Session returns the active session of a conversation by key, or
+// ErrNotFound.
+func (m *Manager) Session(ctx context.Context, key string) (*Session, error) {
+	s, err := m.store.Load(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	if m.now().Sub(s.LastActive) > m.ttl {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+// SetConfig replaces the configuration of the active session of a
+// conversation by key, or returns ErrNotFound.
+func (m *Manager) SetConfig(ctx context.Context, key string, config channels.SessionConfig) error {
+	s, err := m.Session(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.Config = config
+	if err := m.store.Save(ctx, s, m.ttl); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+// Reset ends the session for a message's conversation; the next message
+// starts a new one.
+func (m *Manager) Reset(ctx context.Context, msg channels.IncomingMessage) error {
+	if err := m.store.Delete(ctx, m.Key(msg)); err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
+
+// newSuffix returns a short random session suffix.
+func newSuffix() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Ensure Manager implements channels.SessionConfigResolver.
+var _ channels.SessionConfigResolver = (*Manager)(nil)
@@ -0,0 +1,319 @@
+// Package shadow evaluates a candidate agent against production traffic.
+//
+// An Agent wraps the production agent: every message is answered by the
+// primary agent as usual, and a copy is sent to the candidate in the
+// background. The candidate's response is never delivered; it is compared
+// with the primary's response, logged, and counted in divergence metrics,
+// so a new model or prompt can be validated before switching it live:
+//
+//	evaluated := shadow.New(shadow.Config{
+//		Primary:   production,
+//		Candidate: candidate,
+//		Name:      "gpt-4o-new-prompt",
+//		Metrics:   registry,
+//	})
+//	router.SetAgent(evaluated)
+package shadow
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/metrics"
+)
+
+// DefaultThreshold is the similarity below which responses diverge.
+const DefaultThreshold = 0.5
+
+// DefaultTimeout bounds each candidate call.
+const DefaultTimeout = 2 * time.Minute
+
+// DefaultMaxConcurrent is the number of candidate calls in flight at once.
+const DefaultMaxConcurrent = 8
+
+// Result is the outcome of one shadowed message.
+type Result struct {
+	SessionID string
+	Content   string
+
+	Primary   string
+	Candidate string
+
+	// Similarity is the Compare score in [0, 1].
+	Similarity float64
+
+	// Diverged reports whether Similarity fell below the threshold or the
+	// candidate failed.
+	Diverged bool
+
+	PrimaryLatency   time.Duration
+	CandidateLatency time.Duration
+
+	// Err is the candidate's error, if any.
+	Err error
+}
+
+// Config configures an Agent.
+type Config struct {
+	// Primary answers messages.
+	Primary channels.AgentProcessor
+
+	// Candidate receives a copy of each sampled message. Its responses are
+	// never delivered.
+	Candidate channels.AgentProcessor
+
+	// Name identifies the candidate in logs and metric labels (default:
+	// "candidate").
+	Name string
+
+	// SampleRate is the fraction of messages shadowed, in (0, 1]
+	// (default: 1).
+	SampleRate float64
+
+	// Compare scores the similarity of two responses in [0, 1] (default:
+	// Similarity).
+	Compare func(primary, candidate string) float64
+
+	// Threshold is the similarity below which responses count as diverged
+	// (default: DefaultThreshold).
+	Threshold float64
+
+	// Timeout bounds each candidate call (default: DefaultTimeout).
+	Timeout time.Duration
+
+	// MaxConcurrent is the number of candidate calls in flight at once
+	// (default: DefaultMaxConcurrent). Messages arriving while all are
+	// busy are not shadowed and count as shadow_skipped.
+	MaxConcurrent int
+
+	// OnResult, if set, receives every result, e.g. to store it for review.
+	OnResult func(Result)
+
+	// Metrics records shadow_requests, shadow_divergences, shadow_errors,
+	// shadow_skipped, and shadow_latency labeled by candidate name.
+	Metrics *metrics.Registry
+
+	Logger *slog.Logger
+}
+
+// Agent answers with the primary agent and shadows the candidate.
+type Agent struct {
+	config Config
+	logger *slog.Logger
+	wg     sync.WaitGroup
+	slots  chan struct{}
+}
+
+// New creates a new shadowing Agent.
+func New(config Config) *Agent {
+	if config.Name == "" {
+		config.Name = "candidate"
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		config.SampleRate = 1
+	}
+	if config.Compare == nil {
+		config.Compare = Similarity
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultThreshold
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = DefaultMaxConcurrent
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Agent{
+		config: config,
+		logger: config.Logger.With("candidate", config.Name),
+		slots:  make(chan struct{}, config.MaxConcurrent),
+	}
+}
+
+// Process answers with the primary agent and shadows the message to the
+// candidate in the background.
+func (a *Agent) Process(ctx context.Context, sessionID, content string) (string, error) {
+	start := time.Now()
+	response, err := a.config.Primary.Process(ctx, sessionID, content)
+	if err != nil {
+		return response, err
+	}
+	a.shadow(ctx, sessionID, content, response, time.Since(start))
+	return response, nil
+}
+
+// ProcessStream streams the primary agent's response, falling back to
+// Process if it does not stream, and shadows the message once the response
+// is complete.
+func (a *Agent) ProcessStream(ctx context.Context, sessionID, content string) (<-chan channels.Chunk, error) {
+	primary, ok := a.config.Primary.(channels.StreamingAgentProcessor)
+	if !ok {
+		response, err := a.Process(ctx, sessionID, content)
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan channels.Chunk, 1)
+		out <- channels.Chunk{Content: response}
+		close(out)
+		return out, nil
+	}
+
+	start := time.Now()
+	in, err := primary.ProcessStream(ctx, sessionID, content)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan channels.Chunk)
+	go func() {
+		defer close(out)
+		var sb strings.Builder
+		var streamErr error
+		for chunk := range in {
+			sb.WriteString(chunk.Content)
+			if chunk.Err != nil {
+				streamErr = chunk.Err
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// The primary closes its stream once it sees ctx is done
+				for range in {
+				}
+				return
+			}
+		}
+		if streamErr == nil {
+			a.shadow(ctx, sessionID, content, sb.String(), time.Since(start))
+		}
+	}()
+	return out, nil
+}
+
+// Wait blocks until in-flight candidate calls finish.
+func (a *Agent) Wait() {
+	a.wg.Wait()
+}
+
+// shadow sends a sampled message to the candidate in the background.
+func (a *Agent) shadow(ctx context.Context, sessionID, content, primary string, primaryLatency time.Duration) {
+	if a.config.SampleRate < 1 && rand.Float64() >= a.config.SampleRate {
+		return
+	}
+
+	select {
+	case a.slots <- struct{}{}:
+	default:
+		if reg := a.config.Metrics; reg != nil {
+			reg.Counter("shadow_skipped", metrics.Labels{"candidate": a.config.Name}).Inc()
+		}
+		a.logger.Debug("shadow agent busy, message not shadowed", "session", sessionID)
+		return
+	}
+
+	// The candidate call must not be canceled when the reply is sent
+	ctx = context.WithoutCancel(ctx)
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer func() { <-a.slots }()
+		ctx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+		defer cancel()
+
+		start := time.Now()
+		// Separate sessions keep the candidate's history from mixing with
+		// the primary's when both share a session store
+		candidate, err := a.config.Candidate.Process(ctx, "shadow:"+sessionID, content)
+		result := Result{
+			SessionID:        sessionID,
+			Content:          content,
+			Primary:          primary,
+			Candidate:        candidate,
+			PrimaryLatency:   primaryLatency,
+			CandidateLatency: time.Since(start),
+			Err:              err,
+		}
+		if err == nil {
+			result.Similarity = a.config.Compare(primary, candidate)
+		}
+		result.Diverged = err != nil || result.Similarity < a.config.Threshold
+		a.record(result)
+	}()
+}
+
+// record logs a result and updates metrics.
+func (a *Agent) record(result Result) {
+	if reg := a.config.Metrics; reg != nil {
+		labels := metrics.Labels{"candidate": a.config.Name}
+		reg.Counter("shadow_requests", labels).Inc()
+		reg.Timer("shadow_latency", labels).Observe(result.CandidateLatency)
+		if result.Err != nil {
+			reg.Counter("shadow_errors", labels).Inc()
+		}
+		if result.Diverged {
+			reg.Counter("shadow_divergences", labels).Inc()
+		}
+	}
+
+	switch {
+	case result.Err != nil:
+		a.logger.Warn("shadow agent failed", "session", result.SessionID, "error", result.Err)
+	case result.Diverged:
+		a.logger.Info("shadow response diverged",
+			"session", result.SessionID,
+			"similarity", result.Similarity)
+		// Responses may hold personal data, so they are only logged for
+		// debugging
+		a.logger.Debug("shadow response diverged",
+			"session", result.SessionID,
+			"primary", result.Primary,
+			"shadow", result.Candidate)
+	default:
+		a.logger.Debug("shadow response matched",
+			"session", result.SessionID,
+			"similarity", result.Similarity)
+	}
+
+	if a.config.OnResult != nil {
+		a.config.OnResult(result)
+	}
+}
+
+// Similarity returns the Jaccard similarity of the lowercased word sets of
+// a and b: 1 for identical wording, 0 for no words in common.
+func Similarity(a, b string) float64 {
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 && len(wb) == 0 {
+		return 1
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+// words returns the set of lowercased words in s.
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		set[w] = true
+	}
+	return set
+}
+
+// Ensure Agent implements channels.StreamingAgentProcessor.
+var _ channels.StreamingAgentProcessor = (*Agent)(nil)
@@ -0,0 +1,190 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/metrics"
+)
+
+type fixedAgent struct {
+	response string
+	err      error
+
+	mu       sync.Mutex
+	sessions []string
+}
+
+func (f *fixedAgent) Process(_ context.Context, sessionID, _ string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = append(f.sessions, sessionID)
+	return f.response, f.err
+}
+
+func TestShadowComparesResponses(t *testing.T) {
+	primary := &fixedAgent{response: "The capital of France is Paris."}
+	candidate := &fixedAgent{response: "Paris is the capital of France."}
+	registry := metrics.NewRegistry(metrics.Config{})
+
+	var results []Result
+	a := New(Config{
+		Primary:   primary,
+		Candidate: candidate,
+		Name:      "v2",
+		Metrics:   registry,
+		OnResult:  func(r Result) { results = append(results, r) },
+	})
+
+	got, err := a.Process(context.Background(), "telegram:1", "capital of France?")
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if got != primary.response {
+		t.Errorf("response = %q, want the primary's", got)
+	}
+	a.Wait()
+
+	if len(results) != 1 {
+		t.Fatalf("results = %d, want 1", len(results))
+	}
+	if results[0].Diverged || results[0].Similarity != 1 {
+		t.Errorf("result = %+v, want identical word sets", results[0])
+	}
+	if candidate.sessions[0] != "shadow:telegram:1" {
+		t.Errorf("candidate session = %q, want shadow:telegram:1", candidate.sessions[0])
+	}
+	if c := registry.Counter("shadow_requests", metrics.Labels{"candidate": "v2"}).Value(); c != 1 {
+		t.Errorf("shadow_requests = %v, want 1", c)
+	}
+}
+
+func TestShadowDivergence(t *testing.T) {
+	registry := metrics.NewRegistry(metrics.Config{})
+	a := New(Config{
+		Primary:   &fixedAgent{response: "yes"},
+		Candidate: &fixedAgent{response: "absolutely not"},
+		Metrics:   registry,
+	})
+	_, _ = a.Process(context.Background(), "s", "question")
+	a.Wait()
+
+	failing := New(Config{
+		Primary:   &fixedAgent{response: "yes"},
+		Candidate: &fixedAgent{err: errors.New("boom")},
+		Metrics:   registry,
+	})
+	_, _ = failing.Process(context.Background(), "s", "question")
+	failing.Wait()
+
+	labels := metrics.Labels{"candidate": "candidate"}
+	if c := registry.Counter("shadow_divergences", labels).Value(); c != 2 {
+		t.Errorf("shadow_divergences = %v, want 2", c)
+	}
+	if c := registry.Counter("shadow_errors", labels).Value(); c != 1 {
+		t.Errorf("shadow_errors = %v, want 1", c)
+	}
+}
+
+// blockedAgent answers once released.
+type blockedAgent struct {
+	release chan struct{}
+}
+
+func (b *blockedAgent) Process(ctx context.Context, _, _ string) (string, error) {
+	<-b.release
+	return "late", nil
+}
+
+func TestShadowConcurrencyLimit(t *testing.T) {
+	registry := metrics.NewRegistry(metrics.Config{})
+	candidate := &blockedAgent{release: make(chan struct{})}
+	var results int
+	a := New(Config{
+		Primary:       &fixedAgent{response: "yes"},
+		Candidate:     candidate,
+		MaxConcurrent: 1,
+		Metrics:       registry,
+		OnResult:      func(Result) { results++ },
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := a.Process(context.Background(), "s", "question"); err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+	}
+	close(candidate.release)
+	a.Wait()
+
+	if results != 1 {
+		t.Errorf("results = %d, want 1 while the candidate was busy", results)
+	}
+	if c := registry.Counter("shadow_skipped", metrics.Labels{"candidate": "candidate"}).Value(); c != 2 {
+		t.Errorf("shadow_skipped = %v, want 2", c)
+	}
+}
+
+// endlessAgent streams until its context is canceled.
+type endlessAgent struct {
+	fixedAgent
+	stopped chan struct{}
+}
+
+func (e *endlessAgent) ProcessStream(ctx context.Context, _, _ string) (<-chan channels.Chunk, error) {
+	out := make(chan channels.Chunk)
+	go func() {
+		defer close(e.stopped)
+		defer close(out)
+		for {
+			select {
+			case out <- channels.Chunk{Content: "more "}:
+			case <-ctx.Done():
+				// Unbuffered sends after cancellation block unless the
+				// reader keeps draining
+				out <- channels.Chunk{Err: ctx.Err()}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestShadowStreamDrainsPrimary(t *testing.T) {
+	primary := &endlessAgent{stopped: make(chan struct{})}
+	a := New(Config{Primary: primary, Candidate: &fixedAgent{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := a.ProcessStream(ctx, "s", "question")
+	if err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	<-out
+	cancel()
+
+	select {
+	case <-primary.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("primary stream blocked after the reader gave up")
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"Hello, world!", "hello world", 1},
+		{"a b", "c d", 0},
+		{"a b c", "a b d", 0.5},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package spamguard protects chats from floods and spam: bursts of
+// messages, repeated messages, and links or invites. Senders who keep
+// offending are muted for a while.
+//
+// A Guard runs as router middleware; messages it stops never reach
+// handlers or the agent:
+//
+//	guard := spamguard.New(spamguard.Config{
+//		Sender: router,
+//		Channels: map[string]spamguard.Rules{
+//			"discord": {BurstMessages: 8, BurstWindow: 10 * time.Second, BlockInvites: true},
+//		},
+//	})
+//	router.Use(guard.Middleware())
+package spamguard
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Reason says why a message was stopped.
+type Reason string
+
+const (
+	ReasonBurst     Reason = "burst"
+	ReasonDuplicate Reason = "duplicate"
+	ReasonLink      Reason = "link"
+	ReasonInvite    Reason = "invite"
+	ReasonMuted     Reason = "muted"
+)
+
+// DefaultMessages are the replies to stopped messages, by reason. The
+// ReasonMuted reply is sent once, when a sender is muted.
+var DefaultMessages = map[Reason]string{
+	ReasonLink:   "Links aren't allowed here.",
+	ReasonInvite: "Invite links aren't allowed here.",
+	ReasonMuted:  "You've been muted for a while for flooding the chat.",
+}
+
+// Rules tune the guard. A zero field disables its check.
+type Rules struct {
+	// BurstMessages is the most messages a sender may send within
+	// BurstWindow.
+	BurstMessages int
+	BurstWindow   time.Duration
+
+	// MaxDuplicates is how often a sender may repeat the same message
+	// within DuplicateWindow.
+	MaxDuplicates   int
+	DuplicateWindow time.Duration
+
+	// BlockLinks stops messages with links, except to AllowedDomains and
+	// their subdomains. BlockInvites stops invites to Discord servers,
+	// Telegram groups, WhatsApp groups, and Slack workspaces.
+	BlockLinks     bool
+	BlockInvites   bool
+	AllowedDomains []string
+
+	// Strikes is the number of stopped messages within MuteFor after which
+	// the sender is muted for MuteFor.
+	Strikes int
+	MuteFor time.Duration
+}
+
+// DefaultRules apply when Config.Rules is zero.
+var DefaultRules = Rules{
+	BurstMessages:   6,
+	BurstWindow:     10 * time.Second,
+	MaxDuplicates:   2,
+	DuplicateWindow: time.Minute,
+	Strikes:         3,
+	MuteFor:         10 * time.Minute,
+}
+
+// Config configures a Guard.
+type Config struct {
+	// Rules apply to every channel (default: DefaultRules).
+	Rules Rules
+
+	// Channels replace Rules for the channels they name.
+	Channels map[string]Rules
+
+	// Exempt, if set, lets the messages it approves bypass the guard, e.g.
+	// those of moderators.
+	Exempt func(msg channels.IncomingMessage) bool
+
+	// Sender delivers the replies to stopped messages. If nil, messages are
+	// dropped silently.
+	Sender channels.Sender
+
+	// Messages override DefaultMessages by reason; an empty message
+	// suppresses the reply.
+	Messages map[Reason]string
+
+	Logger *slog.Logger
+}
+
+func (c Config) withDefaults() Config {
+	if c.Rules.isZero() {
+		c.Rules = DefaultRules
+	}
+	messages := make(map[Reason]string, len(DefaultMessages)+len(c.Messages))
+	for r, m := range DefaultMessages {
+		messages[r] = m
+	}
+	for r, m := range c.Messages {
+		messages[r] = m
+	}
+	c.Messages = messages
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	return c
+}
+
+// isZero reports whether no rule is set.
+func (r Rules) isZero() bool {
+	return r.BurstMessages == 0 && r.MaxDuplicates == 0 && !r.BlockLinks && !r.BlockInvites && r.Strikes == 0
+}
+
+// Guard stops spam and floods.
+type Guard struct {
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	senders   map[string]*record
+	lastSweep time.Time
+}
+
+// record is the recent activity of a sender on a channel.
+type record struct {
+	sent       []time.Time
+	contents   map[string][]time.Time
+	strikes    []time.Time
+	mutedUntil time.Time
+}
+
+// New creates a new Guard.
+func New(config Config) *Guard {
+	return &Guard{
+		config:  config.withDefaults(),
+		now:     time.Now,
+		senders: make(map[string]*record),
+	}
+}
+
+// Middleware returns router middleware that drops the messages the guard
+// stops and replies to their senders.
+func (g *Guard) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			reason, muted, ok := g.Check(msg)
+			if ok {
+				return next(ctx, msg)
+			}
+			// Messages of muted senders are dropped silently
+			if reason == ReasonMuted {
+				return nil
+			}
+			g.config.Logger.Warn("message stopped by spam guard",
+				"channel", msg.ChannelName,
+				"chat", msg.ChatID,
+				"sender", msg.SenderID,
+				"reason", reason,
+				"muted", muted)
+			if muted {
+				return g.notify(ctx, msg, ReasonMuted)
+			}
+			return g.notify(ctx, msg, reason)
+		}
+	}
+}
+
+// Check records a message and reports whether it may pass. If not, it
+// returns the reason, and whether the sender was muted because of it.
+func (g *Guard) Check(msg channels.IncomingMessage) (reason Reason, muted, ok bool) {
+	if g.config.Exempt != nil && g.config.Exempt(msg) {
+		return "", false, true
+	}
+	rules := g.rules(msg.ChannelName)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	g.sweep(now)
+
+	key := msg.ChannelName + "\x00" + msg.SenderID
+	rec, exists := g.senders[key]
+	if !exists {
+		rec = &record{contents: make(map[string][]time.Time)}
+		g.senders[key] = rec
+	}
+	if now.Before(rec.mutedUntil) {
+		return ReasonMuted, false, false
+	}
+
+	reason = rec.check(msg.Content, rules, now)
+	if reason == "" {
+		return "", false, true
+	}
+	if rules.Strikes > 0 && rules.MuteFor > 0 {
+		rec.strikes = append(within(rec.strikes, now, rules.MuteFor), now)
+		if len(rec.strikes) >= rules.Strikes {
+			rec.strikes = nil
+			rec.mutedUntil = now.Add(rules.MuteFor)
+			muted = true
+		}
+	}
+	return reason, muted, false
+}
+
+// check records a message in the sender's activity and returns the rule it
+// breaks, if any.
+func (rec *record) check(content string, rules Rules, now time.Time) Reason {
+	if rules.BlockInvites && invitePattern.MatchString(content) {
+		return ReasonInvite
+	}
+	if rules.BlockLinks && hasLink(content, rules.AllowedDomains) {
+		return ReasonLink
+	}
+
+	if rules.BurstMessages > 0 && rules.BurstWindow > 0 {
+		rec.sent = append(within(rec.sent, now, rules.BurstWindow), now)
+		if len(rec.sent) > rules.BurstMessages {
+			return ReasonBurst
+		}
+	}
+	if rules.MaxDuplicates > 0 && rules.DuplicateWindow > 0 {
+		text := normalize(content)
+		if text != "" {
+			rec.contents[text] = append(within(rec.contents[text], now, rules.DuplicateWindow), now)
+			if len(rec.contents[text]) > rules.MaxDuplicates {
+				return ReasonDuplicate
+			}
+		}
+	}
+	return ""
+}
+
+// Unmute lifts the mute of a sender on a channel.
+func (g *Guard) Unmute(channelName, senderID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if rec, ok := g.senders[channelName+"\x00"+senderID]; ok {
+		rec.mutedUntil = time.Time{}
+		rec.strikes = nil
+	}
+}
+
+// rules returns the rules of a channel.
+func (g *Guard) rules(channelName string) Rules {
+	if r, ok := g.config.Channels[channelName]; ok {
+		return r
+	}
+	return g.config.Rules
+}
+
+// notify sends the reply for reason, if any.
+func (g *Guard) notify(ctx context.Context, msg channels.IncomingMessage, reason Reason) error {
+	text := g.config.Messages[reason]
+	if g.config.Sender == nil || text == "" {
+		return nil
+	}
+	return g.config.Sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+		Content: text,
+		ReplyTo: msg.ID,
+	})
+}
+
+// sweepHorizon is how long the activity of senders is kept.
+const sweepHorizon = time.Hour
+
+// sweep drops the activity older than sweepHorizon and the records of
+// senders that are neither muted nor recently active, so memory stays
+// bounded. The caller must hold g.mu.
+func (g *Guard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = now
+	for key, rec := range g.senders {
+		for text, times := range rec.contents {
+			if times = within(times, now, sweepHorizon); len(times) == 0 {
+				delete(rec.contents, text)
+			}
+		}
+		rec.sent = within(rec.sent, now, sweepHorizon)
+		rec.strikes = within(rec.strikes, now, sweepHorizon)
+		if len(rec.contents) == 0 && len(rec.sent) == 0 && len(rec.strikes) == 0 && !now.Before(rec.mutedUntil) {
+			delete(g.senders, key)
+		}
+	}
+}
+
+// within returns the times no older than window.
+func within(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= window {
+		i++
+	}
+	return times[i:]
+}
+
+// normalize folds case and whitespace so trivially altered repeats count
+// as duplicates.
+func normalize(content string) string {
+	return strings.Join(strings.Fields(strings.ToLower(content)), " ")
+}
+
+var (
+	invitePattern = regexp.MustCompile(`(?i)\b(?:discord(?:app)?\.(?:gg|com/invite)/|t\.me/(?:\+|joinchat/)|chat\.whatsapp\.com/|join\.slack\.com/)`)
+	linkPattern   = regexp.MustCompile(`(?i)\b(?:https?://[^\s<>]+|www\.[^\s<>]+|(?:[a-z0-9-]+\.)+(?:com|net|org|io|gg|me|ly|co|app|xyz|info|ru)\b(?:/[^\s<>]*)?)`)
+)
+
+// hasLink reports whether content links to a domain not allowed.
+func hasLink(content string, allowed []string) bool {
+	for _, link := range linkPattern.FindAllString(content, -1) {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil || !domainAllowed(strings.ToLower(u.Hostname()), allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainAllowed reports whether host is one of domains or a subdomain.
+func domainAllowed(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
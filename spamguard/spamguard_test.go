@@ -0,0 +1,76 @@
+package spamguard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+func TestGuard(t *testing.T) {
+	sender := &channelstest.Sender{}
+	g := New(Config{
+		Rules: Rules{
+			BurstMessages: 3, BurstWindow: 10 * time.Second,
+			MaxDuplicates: 1, DuplicateWindow: time.Minute,
+			Strikes: 2, MuteFor: 5 * time.Minute,
+		},
+		Channels: map[string]Rules{
+			"discord": {BlockLinks: true, BlockInvites: true, AllowedDomains: []string{"example.com"}},
+		},
+		Sender: sender,
+	})
+	now := time.Unix(1700000000, 0)
+	g.now = func() time.Time { return now }
+
+	handled := 0
+	handler := g.Middleware()(func(context.Context, channels.IncomingMessage) error {
+		handled++
+		return nil
+	})
+	say := func(channelName, from, content string) {
+		t.Helper()
+		msg := channels.IncomingMessage{ChannelName: channelName, ChatID: "c1", SenderID: from, Content: content}
+		if err := handler(context.Background(), msg); err != nil {
+			t.Fatalf("%q failed: %v", content, err)
+		}
+	}
+
+	say("telegram", "alice", "hi")
+	say("telegram", "alice", "HI ") // duplicate: strike 1
+	say("telegram", "bob", "hi")    // other senders are unaffected
+	if handled != 2 {
+		t.Fatalf("handled = %d, want 2", handled)
+	}
+
+	say("telegram", "alice", "one")
+	say("telegram", "alice", "two") // fourth message in the window: strike 2, muted
+	if handled != 3 || len(sender.Contents()) != 1 || sender.Contents()[0] != DefaultMessages[ReasonMuted] {
+		t.Fatalf("handled = %d, sent = %q", handled, sender.Contents())
+	}
+
+	now = now.Add(time.Minute)
+	say("telegram", "alice", "let me talk")
+	if handled != 3 || len(sender.Contents()) != 1 {
+		t.Errorf("muted sender got through: handled = %d, sent = %q", handled, sender.Contents())
+	}
+	now = now.Add(5 * time.Minute)
+	say("telegram", "alice", "sorry")
+	if handled != 4 {
+		t.Errorf("mute did not expire: handled = %d", handled)
+	}
+
+	// Per-channel rules
+	for content, want := range map[string]Reason{
+		"see https://docs.example.com/faq": "",
+		"free stuff at spam.xyz":           ReasonLink,
+		"join discord.gg/abc123":           ReasonInvite,
+	} {
+		reason, _, ok := g.Check(channels.IncomingMessage{ChannelName: "discord", SenderID: "carol", Content: content})
+		if reason != want || ok != (want == "") {
+			t.Errorf("Check(%q) = %q, %v; want %q", content, reason, ok, want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Package elastic provides an Elasticsearch-backed message store for envoy.
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+// Store indexes and searches messages in Elasticsearch.
+type Store struct {
+	baseURL string
+	index   string
+	apiKey  string
+	client  *http.Client
+}
+
+// Config configures the Elasticsearch store.
+type Config struct {
+	// URL is the Elasticsearch base URL (e.g., "http://localhost:9200").
+	URL string
+
+	// Index is the index name (default: "envoy-messages").
+	Index string
+
+	// APIKey is an optional base64-encoded API key.
+	APIKey string
+
+	// HTTPClient is the HTTP client to use (default: 10s timeout).
+	HTTPClient *http.Client
+}
+
+// New creates a new Elasticsearch store.
+func New(config Config) (*Store, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("elasticsearch URL required")
+	}
+	if config.Index == "" {
+		config.Index = "envoy-messages"
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Store{
+		baseURL: strings.TrimRight(config.URL, "/"),
+		index:   config.Index,
+		apiKey:  config.APIKey,
+		client:  config.HTTPClient,
+	}, nil
+}
+
+// document is the indexed representation of a message.
+type document struct {
+	ID          string                 `json:"id"`
+	ChannelName string                 `json:"channel"`
+	ChatID      string                 `json:"chat_id"`
+	SenderID    string                 `json:"sender_id,omitempty"`
+	SenderName  string                 `json:"sender_name,omitempty"`
+	Content     string                 `json:"content"`
+	Direction   string                 `json:"direction"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	DeletedAt   time.Time              `json:"deleted_at,omitzero"`
+}
+
+// Append indexes a message.
+func (s *Store) Append(ctx context.Context, msg store.Message) error {
+	doc := document{
+		ID:          msg.ID,
+		ChannelName: msg.ChannelName,
+		ChatID:      msg.ChatID,
+		SenderID:    msg.SenderID,
+		SenderName:  msg.SenderName,
+		Content:     msg.Content,
+		Direction:   string(msg.Direction),
+		Timestamp:   msg.Timestamp,
+		Metadata:    msg.Metadata,
+	}
+	return s.do(ctx, http.MethodPost, "/"+url.PathEscape(s.index)+"/_doc", doc, nil)
+}
+
+// Search performs a full-text match query with filters.
+func (s *Store) Search(ctx context.Context, query store.SearchQuery) ([]store.SearchResult, error) {
+	if strings.TrimSpace(query.Text) == "" {
+		return nil, nil
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = store.DefaultSearchLimit
+	}
+
+	body := map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"match": map[string]interface{}{
+						"content": map[string]interface{}{
+							"query":    query.Text,
+							"operator": "and",
+						},
+					},
+				},
+				"filter": filters(query),
+				"must_not": map[string]interface{}{
+					"exists": map[string]interface{}{"field": "deleted_at"},
+				},
+			},
+		},
+		"sort": []interface{}{"_score", map[string]interface{}{"timestamp": "desc"}},
+	}
+
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				Score  float64  `json:"_score"`
+				Source document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/"+url.PathEscape(s.index)+"/_search", body, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make([]store.SearchResult, 0, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		results = append(results, store.SearchResult{
+			Message: hit.Source.message(),
+			Score:   hit.Score,
+		})
+	}
+	return results, nil
+}
+
+// List returns a page of messages ordered by timestamp, then message ID.
+// Cursors hold the sort values of the last message for search_after.
+func (s *Store) List(ctx context.Context, query store.ListQuery) (*store.Page, error) {
+	order := "asc"
+	if query.Reverse {
+		order = "desc"
+	}
+	limit := query.PageLimit()
+	body := map[string]interface{}{
+		"size": limit + 1,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"filter": filters(query.Filters())},
+		},
+		"sort": []interface{}{
+			map[string]interface{}{"timestamp": order},
+			map[string]interface{}{"id": order},
+		},
+	}
+	if query.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor %q", query.Cursor)
+		}
+		var after []interface{}
+		if err := json.Unmarshal(data, &after); err != nil {
+			return nil, fmt.Errorf("invalid cursor %q", query.Cursor)
+		}
+		body["search_after"] = after
+	}
+
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				Source document        `json:"_source"`
+				Sort   json.RawMessage `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/"+url.PathEscape(s.index)+"/_search", body, &resp); err != nil {
+		return nil, err
+	}
+
+	page := &store.Page{}
+	for i, hit := range resp.Hits.Hits {
+		if i == limit {
+			page.Next = base64.RawURLEncoding.EncodeToString(resp.Hits.Hits[i-1].Sort)
+			break
+		}
+		page.Messages = append(page.Messages, hit.Source.message())
+	}
+	return page, nil
+}
+
+// filters returns the bool filter clauses for a query's non-text filters.
+func filters(query store.SearchQuery) []map[string]interface{} {
+	clauses := []map[string]interface{}{}
+	term := func(field, value string) {
+		if value != "" {
+			clauses = append(clauses, map[string]interface{}{
+				"term": map[string]interface{}{field: value},
+			})
+		}
+	}
+	term("channel", query.ChannelName)
+	term("chat_id", query.ChatID)
+	term("sender_id", query.SenderID)
+
+	if !query.Since.IsZero() || !query.Until.IsZero() {
+		r := map[string]interface{}{}
+		if !query.Since.IsZero() {
+			r["gte"] = query.Since
+		}
+		if !query.Until.IsZero() {
+			r["lt"] = query.Until
+		}
+		clauses = append(clauses, map[string]interface{}{
+			"range": map[string]interface{}{"timestamp": r},
+		})
+	}
+	return clauses
+}
+
+// message converts an indexed document to a store message.
+func (d document) message() store.Message {
+	return store.Message{
+		ID:          d.ID,
+		ChannelName: d.ChannelName,
+		ChatID:      d.ChatID,
+		SenderID:    d.SenderID,
+		SenderName:  d.SenderName,
+		Content:     d.Content,
+		Direction:   store.Direction(d.Direction),
+		Timestamp:   d.Timestamp,
+		Metadata:    d.Metadata,
+		DeletedAt:   d.DeletedAt,
+	}
+}
+
+// Tombstone removes a message's content and marks it deleted.
+func (s *Store) Tombstone(ctx context.Context, channelName, chatID, messageID string, at time.Time) error {
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{"term": map[string]interface{}{"channel": channelName}},
+					{"term": map[string]interface{}{"chat_id": chatID}},
+					{"term": map[string]interface{}{"id": messageID}},
+				},
+			},
+		},
+		"script": map[string]interface{}{
+			"source": "ctx._source.content = ''; ctx._source.deleted_at = params.at",
+			"params": map[string]interface{}{"at": at},
+		},
+	}
+	return s.do(ctx, http.MethodPost, "/"+url.PathEscape(s.index)+"/_update_by_query?conflicts=proceed", body, nil)
+}
+
+// do sends a JSON request and decodes the JSON response into out, if set.
+func (s *Store) do(ctx context.Context, method, path string, in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("elasticsearch request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("elasticsearch %s %s: %s: %s", method, path, resp.Status, msg)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// Ensure Store implements MessageStore and Tombstoner interfaces.
+var (
+	_ store.MessageStore = (*Store)(nil)
+	_ store.Tombstoner   = (*Store)(nil)
+)
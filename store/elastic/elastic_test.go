@@ -0,0 +1,340 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+var base = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// request is a request received by the test server.
+type request struct {
+	Method, Path, Auth string
+	Body               map[string]interface{}
+}
+
+// newTestStore returns a store against a server replying with response
+// and recording the requests it receives.
+func newTestStore(t *testing.T, status int, response string) (*Store, *[]request) {
+	t.Helper()
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{Method: r.Method, Path: r.URL.RequestURI(), Auth: r.Header.Get("Authorization")}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &req.Body); err != nil {
+			t.Errorf("request body %s: %v", data, err)
+		}
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	s, err := New(Config{URL: server.URL + "/", Index: "chats", APIKey: "secret"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return s, &requests
+}
+
+// jsonPath returns the value at a dot-separated path in a decoded body.
+func jsonPath(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("New without a URL succeeded")
+	}
+	s, err := New(Config{URL: "http://localhost:9200"})
+	if err != nil || s.index != "envoy-messages" {
+		t.Errorf("New = %+v, %v; want the default index", s, err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	s, requests := newTestStore(t, http.StatusCreated, `{"result": "created"}`)
+	err := s.Append(context.Background(), store.Message{
+		ID: "1", ChannelName: "telegram", ChatID: "100", SenderID: "alice",
+		Content: "hello", Direction: store.DirectionIncoming, Timestamp: base,
+		Metadata: map[string]interface{}{"lang": "en"},
+	})
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	req := (*requests)[0]
+	if req.Method != http.MethodPost || req.Path != "/chats/_doc" || req.Auth != "ApiKey secret" {
+		t.Errorf("request = %s %s, auth %q", req.Method, req.Path, req.Auth)
+	}
+	for path, want := range map[string]interface{}{
+		"id": "1", "channel": "telegram", "chat_id": "100", "sender_id": "alice", "content": "hello",
+		"direction": "incoming", "timestamp": "2025-01-01T12:00:00Z", "metadata.lang": "en",
+	} {
+		if got := jsonPath(req.Body, path); got != want {
+			t.Errorf("document %s = %v, want %v", path, got, want)
+		}
+	}
+	for _, field := range []string{"sender_name", "deleted_at"} {
+		if _, ok := req.Body[field]; ok {
+			t.Errorf("document has empty field %s", field)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s, requests := newTestStore(t, http.StatusOK, `{"hits": {"hits": [
+		{"_score": 2.5, "_source": {"id": "1", "channel": "telegram", "chat_id": "100", "sender_id": "alice", "content": "my flight", "direction": "incoming", "timestamp": "2025-01-01T12:00:00Z"}},
+		{"_score": 1.2, "_source": {"id": "2", "channel": "telegram", "chat_id": "100", "content": "noted your flight", "direction": "outgoing", "timestamp": "2025-01-01T13:00:00Z", "metadata": {"reply_to": "1"}}}
+	]}}`)
+	ctx := context.Background()
+
+	results, err := s.Search(ctx, store.SearchQuery{
+		Text: "flight", ChannelName: "telegram", ChatID: "100", SenderID: "alice",
+		Since: base, Until: base.Add(time.Hour), Limit: 5,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Score != 2.5 || results[1].Score != 1.2 {
+		t.Fatalf("results = %+v", results)
+	}
+	m := results[1].Message
+	if m.ID != "2" || m.Direction != store.DirectionOutgoing || !m.Timestamp.Equal(base.Add(time.Hour)) || m.Metadata["reply_to"] != "1" {
+		t.Errorf("message = %+v", m)
+	}
+
+	req := (*requests)[0]
+	if req.Path != "/chats/_search" {
+		t.Errorf("path = %s", req.Path)
+	}
+	for path, want := range map[string]interface{}{
+		"size":                                   5.0,
+		"query.bool.must.match.content.query":    "flight",
+		"query.bool.must.match.content.operator": "and",
+		"query.bool.must_not.exists.field":       "deleted_at",
+	} {
+		if got := jsonPath(req.Body, path); got != want {
+			t.Errorf("request %s = %v, want %v", path, got, want)
+		}
+	}
+	filter, _ := json.Marshal(jsonPath(req.Body, "query.bool.filter"))
+	want := `[{"term":{"channel":"telegram"}},{"term":{"chat_id":"100"}},{"term":{"sender_id":"alice"}},` +
+		`{"range":{"timestamp":{"gte":"2025-01-01T12:00:00Z","lt":"2025-01-01T13:00:00Z"}}}]`
+	if string(filter) != want {
+		t.Errorf("filter = %s, want %s", filter, want)
+	}
+
+	// Blank queries are not sent
+	if results, err := s.Search(ctx, store.SearchQuery{Text: " "}); err != nil || results != nil || len(*requests) != 1 {
+		t.Errorf("blank Search = %v, %v after %d requests", results, err, len(*requests))
+	}
+}
+
+func TestTombstone(t *testing.T) {
+	s, requests := newTestStore(t, http.StatusOK, `{"updated": 1}`)
+	if err := s.Tombstone(context.Background(), "discord", "100", "m1", base); err != nil {
+		t.Fatalf("Tombstone failed: %v", err)
+	}
+
+	req := (*requests)[0]
+	if req.Path != "/chats/_update_by_query?conflicts=proceed" {
+		t.Errorf("path = %s", req.Path)
+	}
+	filter, _ := json.Marshal(jsonPath(req.Body, "query.bool.filter"))
+	if want := `[{"term":{"channel":"discord"}},{"term":{"chat_id":"100"}},{"term":{"id":"m1"}}]`; string(filter) != want {
+		t.Errorf("filter = %s, want %s", filter, want)
+	}
+	if at := jsonPath(req.Body, "script.params.at"); at != "2025-01-01T12:00:00Z" {
+		t.Errorf("deleted at = %v", at)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	s, _ := newTestStore(t, http.StatusBadRequest, `{"error": "parsing_exception"}`)
+	_, err := s.Search(context.Background(), store.SearchQuery{Text: "flight"})
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "parsing_exception") {
+		t.Errorf("Search = %v, want the status and response", err)
+	}
+}
+
+// listServer serves _search requests sorted by timestamp and id over docs,
+// honoring size, the chat and time filters, and search_after, like
+// Elasticsearch does for List.
+func listServer(t *testing.T, docs []document) *Store {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Size  int `json:"size"`
+			Query struct {
+				Bool struct {
+					Filter []map[string]map[string]json.RawMessage `json:"filter"`
+				} `json:"bool"`
+			} `json:"query"`
+			Sort        []map[string]string `json:"sort"`
+			SearchAfter []interface{}       `json:"search_after"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		desc := body.Sort[0]["timestamp"] == "desc"
+		if body.Sort[1]["id"] != body.Sort[0]["timestamp"] {
+			t.Errorf("sort = %v, want timestamp and id in the same order", body.Sort)
+		}
+
+		// before reports whether a sorts before b in the requested order
+		before := func(aMillis float64, aID string, bMillis float64, bID string) bool {
+			if aMillis != bMillis {
+				return (aMillis < bMillis) != desc
+			}
+			if aID == bID {
+				return false
+			}
+			return (aID < bID) != desc
+		}
+		matches := func(d document) bool {
+			for _, clause := range body.Query.Bool.Filter {
+				if term, ok := clause["term"]; ok {
+					for field, value := range term {
+						doc := map[string]string{"channel": d.ChannelName, "chat_id": d.ChatID}
+						if string(value) != `"`+doc[field]+`"` {
+							return false
+						}
+					}
+				}
+				if rng, ok := clause["range"]; ok {
+					var bounds struct{ Gte, Lt time.Time }
+					json.Unmarshal(rng["timestamp"], &bounds)
+					if !bounds.Gte.IsZero() && d.Timestamp.Before(bounds.Gte) ||
+						!bounds.Lt.IsZero() && !d.Timestamp.Before(bounds.Lt) {
+						return false
+					}
+				}
+			}
+			return true
+		}
+
+		sorted := append([]document(nil), docs...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return before(float64(sorted[i].Timestamp.UnixMilli()), sorted[i].ID,
+				float64(sorted[j].Timestamp.UnixMilli()), sorted[j].ID)
+		})
+		type hit struct {
+			Source document      `json:"_source"`
+			Sort   []interface{} `json:"sort"`
+		}
+		var hits []hit
+		for _, d := range sorted {
+			millis := float64(d.Timestamp.UnixMilli())
+			if !matches(d) {
+				continue
+			}
+			if body.SearchAfter != nil && !before(body.SearchAfter[0].(float64), body.SearchAfter[1].(string), millis, d.ID) {
+				continue
+			}
+			if len(hits) == body.Size {
+				break
+			}
+			hits = append(hits, hit{Source: d, Sort: []interface{}{millis, d.ID}})
+		}
+		var resp struct {
+			Hits struct {
+				Hits []hit `json:"hits"`
+			} `json:"hits"`
+		}
+		resp.Hits.Hits = hits
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	s, err := New(Config{URL: server.URL})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return s
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	// A timestamp tie between 2 and 3, and a tombstoned 4
+	s := listServer(t, []document{
+		{ID: "4", ChannelName: "telegram", ChatID: "100", Timestamp: base.Add(3 * time.Minute), DeletedAt: base},
+		{ID: "1", ChannelName: "telegram", ChatID: "100", Content: "one", Timestamp: base},
+		{ID: "3", ChannelName: "telegram", ChatID: "100", Content: "three", Timestamp: base.Add(time.Minute)},
+		{ID: "2", ChannelName: "telegram", ChatID: "100", Content: "two", Timestamp: base.Add(time.Minute)},
+		{ID: "5", ChannelName: "telegram", ChatID: "100", Content: "five", Timestamp: base.Add(4 * time.Minute)},
+		{ID: "x", ChannelName: "telegram", ChatID: "200", Content: "other", Timestamp: base},
+	})
+
+	list := func(query store.ListQuery) []string {
+		t.Helper()
+		var ids []string
+		for {
+			page, err := s.List(ctx, query)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(page.Messages) > query.Limit {
+				t.Fatalf("page has %d messages, limit %d", len(page.Messages), query.Limit)
+			}
+			for _, m := range page.Messages {
+				ids = append(ids, m.ID)
+			}
+			if page.Next == "" {
+				return ids
+			}
+			query.Cursor = page.Next
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query store.ListQuery
+		want  string
+	}{
+		{"chat", store.ListQuery{ChannelName: "telegram", ChatID: "100", Limit: 2}, "1 2 3 4 5"},
+		{"page size 1", store.ListQuery{ChatID: "100", Limit: 1}, "1 2 3 4 5"},
+		{"reverse", store.ListQuery{ChannelName: "telegram", ChatID: "100", Limit: 2, Reverse: true}, "5 4 3 2 1"},
+		{"reverse page size 1", store.ListQuery{ChatID: "100", Limit: 1, Reverse: true}, "5 4 3 2 1"},
+		{"time range", store.ListQuery{ChatID: "100", Since: base.Add(time.Minute), Until: base.Add(4 * time.Minute), Limit: 1}, "2 3 4"},
+		{"reverse time range", store.ListQuery{ChatID: "100", Since: base.Add(time.Minute), Until: base.Add(4 * time.Minute), Limit: 2, Reverse: true}, "4 3 2"},
+		{"all chats", store.ListQuery{Limit: 4}, "1 x 2 3 4 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(list(tt.query), " "); got != tt.want {
+				t.Errorf("ids = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Tombstoned messages are listed
+	page, err := s.List(ctx, store.ListQuery{ChatID: "100", Since: base.Add(3 * time.Minute), Limit: 1})
+	if err != nil || len(page.Messages) != 1 {
+		t.Fatalf("List = %+v, %v", page, err)
+	}
+	if m := page.Messages[0]; m.ID != "4" || !m.DeletedAt.Equal(base) {
+		t.Errorf("tombstoned message = %+v", m)
+	}
+
+	if _, err := s.List(ctx, store.ListQuery{Cursor: "bogus!"}); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+}
@@ -0,0 +1,295 @@
+// Package encrypted encrypts conversation data at rest.
+//
+// Values are sealed with envelope encryption: content is encrypted with a
+// random data key, and the data key is wrapped by a KeyProvider, such as a
+// local Keyring or a cloud KMS. Wrap a store to keep transcripts and
+// session overrides out of the database in plaintext:
+//
+//	keys, _ := encrypted.NewKeyring("2025-01", key)
+//	cipher := encrypted.NewCipher(encrypted.Config{Keys: keys})
+//	messages := encrypted.New(sqlStore, cipher)
+//	manager := sessions.New(sessions.Config{Store: cipher.Sessions(redisStore)})
+//
+// Rotating the key encryption key only affects new values; values sealed
+// under older keys stay readable while their keys remain available.
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix marks sealed values. Values without it are treated as plaintext
+// written before encryption was enabled.
+const Prefix = "enc1:"
+
+// DefaultDataKeyLifetime is how long a data key encrypts new values before
+// a fresh one is generated.
+const DefaultDataKeyLifetime = time.Hour
+
+// ErrUnknownKey is returned for values wrapped with a key the KeyProvider
+// does not have.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// KeyProvider wraps data keys with key encryption keys it holds, e.g. in a
+// KMS.
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the current key encryption key and
+	// returns that key's ID.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+
+	// UnwrapKey decrypts a data key wrapped with the key keyID.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Config configures a Cipher.
+type Config struct {
+	// Keys wraps data keys.
+	Keys KeyProvider
+
+	// DataKeyLifetime bounds how long a data key is reused, and so how long
+	// after a rotation values are still wrapped with the previous key
+	// (default: DefaultDataKeyLifetime).
+	DataKeyLifetime time.Duration
+}
+
+// Cipher seals and opens values with envelope encryption. Data keys are
+// cached, so the KeyProvider is called about once per lifetime when
+// sealing and once per data key when opening.
+type Cipher struct {
+	keys     KeyProvider
+	lifetime time.Duration
+
+	mu        sync.Mutex
+	current   *dataKey
+	unwrapped map[string]cipher.AEAD
+}
+
+// dataKey is the data key new values are sealed with.
+type dataKey struct {
+	aead    cipher.AEAD
+	header  string // "<key id>:<wrapped key>"
+	created time.Time
+}
+
+// NewCipher creates a Cipher.
+func NewCipher(config Config) *Cipher {
+	if config.DataKeyLifetime <= 0 {
+		config.DataKeyLifetime = DefaultDataKeyLifetime
+	}
+	return &Cipher{
+		keys:      config.Keys,
+		lifetime:  config.DataKeyLifetime,
+		unwrapped: make(map[string]cipher.AEAD),
+	}
+}
+
+// Seal encrypts a value. Empty values are kept empty.
+func (c *Cipher) Seal(ctx context.Context, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	key, err := c.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(key.header))
+	return Prefix + key.header + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed by Seal. Values without Prefix are returned
+// unchanged.
+func (c *Cipher) Open(ctx context.Context, value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return value, nil
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	header := parts[0] + ":" + parts[1]
+	aead, err := c.unwrap(ctx, parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(header))
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rotate discards the current data key, so the next value is sealed with a
+// fresh one wrapped by the provider's current key. Call it after rotating
+// the key encryption key.
+func (c *Cipher) Rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = nil
+}
+
+// dataKey returns the data key for new values, generating one when there
+// is none or it expired.
+func (c *Cipher) dataKey(ctx context.Context) (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil && time.Since(c.current.created) < c.lifetime {
+		return c.current, nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	keyID, wrapped, err := c.keys.WrapKey(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return nil, fmt.Errorf("invalid key ID %q", keyID)
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	header := keyID + ":" + base64.RawURLEncoding.EncodeToString(wrapped)
+	c.current = &dataKey{aead: aead, header: header, created: time.Now()}
+	c.unwrapped[header] = aead
+	return c.current, nil
+}
+
+// unwrap returns the data key of a sealed value.
+func (c *Cipher) unwrap(ctx context.Context, keyID, wrapped string) (cipher.AEAD, error) {
+	header := keyID + ":" + wrapped
+	c.mu.Lock()
+	aead, ok := c.unwrapped[header]
+	c.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	raw, err := c.keys.UnwrapKey(ctx, keyID, data)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	if aead, err = newAEAD(raw); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.unwrapped[header] = aead
+	c.mu.Unlock()
+	return aead, nil
+}
+
+// newAEAD returns an AES-GCM cipher for a key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keyring is a KeyProvider holding key encryption keys in memory, e.g.
+// loaded from a secret store. Keep retired keys in the ring as long as
+// values wrapped with them are stored.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+// NewKeyring creates a keyring whose current key is the 32-byte key id.
+func NewKeyring(id string, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Add adds a key that only unwraps existing data keys.
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid key ID %q", id)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("key %s must be 32 bytes, got %d", id, len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = aead
+	return nil
+}
+
+// Rotate adds a key and makes it the key new data keys are wrapped with.
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.current = id
+	return nil
+}
+
+// WrapKey encrypts a data key with the current key.
+func (k *Keyring) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	k.mu.RLock()
+	id, aead := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return id, aead.Seal(nonce, nonce, dataKey, []byte(id)), nil
+}
+
+// UnwrapKey decrypts a data key wrapped with the key keyID.
+func (k *Keyring) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	k.mu.RLock()
+	aead, ok := k.keys[keyID]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed wrapped key")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// Ensure Keyring implements KeyProvider.
+var _ KeyProvider = (*Keyring)(nil)
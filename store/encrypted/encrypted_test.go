@@ -0,0 +1,129 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/sessions"
+	"github.com/agentplexus/envoy/store"
+)
+
+func testKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	keys, err := NewKeyring("k1", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestCipherRotation(t *testing.T) {
+	ctx := context.Background()
+	keys := testKeyring(t)
+	c := NewCipher(Config{Keys: keys})
+
+	old, err := c.Seal(ctx, "before rotation")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !strings.HasPrefix(old, Prefix+"k1:") || strings.Contains(old, "rotation") {
+		t.Fatalf("sealed = %q", old)
+	}
+
+	if err := keys.Rotate("k2", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	c.Rotate()
+	sealed, err := c.Seal(ctx, "after rotation")
+	if err != nil || !strings.HasPrefix(sealed, Prefix+"k2:") {
+		t.Fatalf("Seal after rotation = %q, %v", sealed, err)
+	}
+
+	// A fresh cipher, as after a restart, opens values of both keys.
+	fresh := NewCipher(Config{Keys: keys})
+	for value, want := range map[string]string{old: "before rotation", sealed: "after rotation", "plain": "plain"} {
+		if got, err := fresh.Open(ctx, value); err != nil || got != want {
+			t.Errorf("Open = %q, %v; want %q", got, err, want)
+		}
+	}
+
+	retired, err := NewKeyring("k2", bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCipher(Config{Keys: retired}).Open(ctx, old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open with retired key error = %v, want ErrUnknownKey", err)
+	}
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-5] ^= 'A' ^ 'B'
+	if _, err := fresh.Open(ctx, string(tampered)); err == nil {
+		t.Error("Open of tampered value succeeded")
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	backend := store.NewMemoryStore()
+	s := New(backend, NewCipher(Config{Keys: testKeyring(t)}))
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, content := range []string{"my flight to Berlin", "flight flight", "nothing here"} {
+		msg := store.Message{ID: string(rune('1' + i)), ChannelName: "telegram", ChatID: "100",
+			Content: content, Timestamp: base.Add(time.Duration(i) * time.Minute)}
+		if err := s.Append(ctx, msg); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	raw, _ := backend.List(ctx, store.ListQuery{})
+	for _, msg := range raw.Messages {
+		if !strings.HasPrefix(msg.Content, Prefix) {
+			t.Errorf("stored content %q is not encrypted", msg.Content)
+		}
+	}
+
+	page, err := s.List(ctx, store.ListQuery{ChatID: "100"})
+	if err != nil || len(page.Messages) != 3 || page.Messages[0].Content != "my flight to Berlin" {
+		t.Fatalf("List = %+v, %v", page, err)
+	}
+
+	results, err := s.Search(ctx, store.SearchQuery{Text: "flight"})
+	if err != nil || len(results) != 2 || results[0].Message.ID != "2" {
+		t.Fatalf("Search = %+v, %v", results, err)
+	}
+
+	if err := s.Tombstone(ctx, "telegram", "100", "2", base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := s.Search(ctx, store.SearchQuery{Text: "flight"}); len(results) != 1 {
+		t.Errorf("Search after tombstone = %+v", results)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	backend := sessions.NewMemoryStore()
+	s := NewCipher(Config{Keys: testKeyring(t)}).Sessions(backend)
+
+	session := &sessions.Session{ID: "s1", Key: "telegram:100", Config: channels.SessionConfig{SystemPrompt: "be terse", Model: "gpt-4o"}}
+	if err := s.Save(ctx, session, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if session.Config.SystemPrompt != "be terse" {
+		t.Error("Save modified the session")
+	}
+	raw, _ := backend.Load(ctx, "telegram:100")
+	if !strings.HasPrefix(raw.Config.SystemPrompt, Prefix) || raw.Config.Model != "gpt-4o" {
+		t.Errorf("stored config = %+v", raw.Config)
+	}
+	loaded, err := s.Load(ctx, "telegram:100")
+	if err != nil || loaded.Config.SystemPrompt != "be terse" {
+		t.Errorf("Load = %+v, %v", loaded, err)
+	}
+}
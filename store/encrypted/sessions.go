@@ -0,0 +1,49 @@
+package encrypted
+
+import (
+	"context"
+	"time"
+
+	"github.com/agentplexus/envoy/sessions"
+)
+
+// Sessions returns a session store that encrypts the system prompt
+// overrides of sessions before saving them in next. Other settings are not
+// free text and are stored as is.
+func (c *Cipher) Sessions(next sessions.Store) sessions.Store {
+	return &sessionStore{cipher: c, next: next}
+}
+
+// sessionStore is an encrypting sessions.Store.
+type sessionStore struct {
+	cipher *Cipher
+	next   sessions.Store
+}
+
+func (s *sessionStore) Load(ctx context.Context, key string) (*sessions.Session, error) {
+	session, err := s.next.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	opened := *session
+	if opened.Config.SystemPrompt, err = s.cipher.Open(ctx, session.Config.SystemPrompt); err != nil {
+		return nil, err
+	}
+	return &opened, nil
+}
+
+func (s *sessionStore) Save(ctx context.Context, session *sessions.Session, ttl time.Duration) error {
+	sealed := *session
+	var err error
+	if sealed.Config.SystemPrompt, err = s.cipher.Seal(ctx, session.Config.SystemPrompt); err != nil {
+		return err
+	}
+	return s.next.Save(ctx, &sealed, ttl)
+}
+
+func (s *sessionStore) Delete(ctx context.Context, key string) error {
+	return s.next.Delete(ctx, key)
+}
+
+// Ensure sessionStore implements sessions.Store.
+var _ sessions.Store = (*sessionStore)(nil)
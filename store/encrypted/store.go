@@ -0,0 +1,118 @@
+package encrypted
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+// DefaultSearchScan is the number of recent messages a Store decrypts to
+// answer a search.
+const DefaultSearchScan = 5000
+
+// Store is a MessageStore that encrypts message content before passing
+// messages to another store. Identifiers, senders, timestamps, and metadata
+// are stored as is, so listing and tombstoning work unchanged.
+type Store struct {
+	next   store.MessageStore
+	cipher *Cipher
+
+	// SearchScan bounds how many of the most recent messages in the
+	// queried scope are decrypted to answer a search, since the underlying
+	// store cannot index encrypted content (default: DefaultSearchScan).
+	SearchScan int
+}
+
+// New creates a Store encrypting messages into next.
+func New(next store.MessageStore, cipher *Cipher) *Store {
+	return &Store{next: next, cipher: cipher, SearchScan: DefaultSearchScan}
+}
+
+// Append encrypts the content of msg and stores it.
+func (s *Store) Append(ctx context.Context, msg store.Message) error {
+	content, err := s.cipher.Seal(ctx, msg.Content)
+	if err != nil {
+		return err
+	}
+	msg.Content = content
+	return s.next.Append(ctx, msg)
+}
+
+// List returns a page of decrypted messages.
+func (s *Store) List(ctx context.Context, query store.ListQuery) (*store.Page, error) {
+	page, err := s.next.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]store.Message, len(page.Messages))
+	for i, msg := range page.Messages {
+		if msg.Content, err = s.cipher.Open(ctx, msg.Content); err != nil {
+			return nil, err
+		}
+		messages[i] = msg
+	}
+	return &store.Page{Messages: messages, Next: page.Next}, nil
+}
+
+// Search decrypts the most recent messages in the queried scope and ranks
+// those containing every query term like store.MemoryStore does.
+func (s *Store) Search(ctx context.Context, query store.SearchQuery) ([]store.SearchResult, error) {
+	terms := store.Tokenize(query.Text)
+	list := store.ListQuery{
+		ChannelName: query.ChannelName,
+		ChatID:      query.ChatID,
+		Since:       query.Since,
+		Until:       query.Until,
+		Reverse:     true,
+	}
+
+	var results []store.SearchResult
+	for scanned := 0; scanned < s.SearchScan; {
+		page, err := s.List(ctx, list)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range page.Messages {
+			scanned++
+			if !msg.DeletedAt.IsZero() || (query.SenderID != "" && msg.SenderID != query.SenderID) {
+				continue
+			}
+			if score, ok := store.ScoreTerms(terms, msg.Content); ok {
+				results = append(results, store.SearchResult{Message: msg, Score: score})
+			}
+		}
+		if page.Next == "" {
+			break
+		}
+		list.Cursor = page.Next
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	limit := query.Limit
+	if limit <= 0 {
+		limit = store.DefaultSearchLimit
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Tombstone marks a message deleted if the underlying store supports it.
+func (s *Store) Tombstone(ctx context.Context, channelName, chatID, messageID string, at time.Time) error {
+	t, ok := s.next.(store.Tombstoner)
+	if !ok {
+		return nil
+	}
+	return t.Tombstone(ctx, channelName, chatID, messageID, at)
+}
+
+// Ensure Store implements MessageStore and Tombstoner interfaces.
+var (
+	_ store.MessageStore = (*Store)(nil)
+	_ store.Tombstoner   = (*Store)(nil)
+)
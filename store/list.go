@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultListLimit is the page size used when a list query sets no limit.
+const DefaultListLimit = 100
+
+// Lister pages through stored messages in chronological order.
+type Lister interface {
+	// List returns a page of messages matching the query, oldest first
+	// unless the query is reversed. Tombstoned messages are included.
+	List(ctx context.Context, query ListQuery) (*Page, error)
+}
+
+// ListQuery selects a range of stored messages.
+type ListQuery struct {
+	// ChannelName limits results to a channel (empty = all).
+	ChannelName string
+
+	// ChatID limits results to a chat (empty = all).
+	ChatID string
+
+	// Since limits results to messages at or after this time.
+	Since time.Time
+
+	// Until limits results to messages before this time.
+	Until time.Time
+
+	// Limit is the page size (0 = DefaultListLimit).
+	Limit int
+
+	// Cursor continues a previous listing from its Page.Next.
+	Cursor string
+
+	// Reverse lists newest messages first.
+	Reverse bool
+}
+
+// Page is one page of a listing.
+type Page struct {
+	Messages []Message `json:"messages"`
+
+	// Next is the cursor for the following page, empty on the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// PageLimit returns the effective page size.
+func (q ListQuery) PageLimit() int {
+	if q.Limit <= 0 {
+		return DefaultListLimit
+	}
+	return q.Limit
+}
+
+// Filters returns the search filters equivalent to the query's chat and
+// time range.
+func (q ListQuery) Filters() SearchQuery {
+	return SearchQuery{
+		ChannelName: q.ChannelName,
+		ChatID:      q.ChatID,
+		Since:       q.Since,
+		Until:       q.Until,
+	}
+}
+
+// Cursor is a position in a listing: a message timestamp in Unix
+// nanoseconds and a store-specific sequence number breaking ties.
+type Cursor struct {
+	Timestamp int64
+	Seq       int64
+}
+
+// String encodes the cursor for Page.Next.
+func (c Cursor) String() string {
+	return strconv.FormatInt(c.Timestamp, 10) + "." + strconv.FormatInt(c.Seq, 10)
+}
+
+// After reports whether the position (ts, seq) comes after the cursor in
+// the listing order.
+func (c Cursor) After(ts, seq int64, reverse bool) bool {
+	if reverse {
+		return ts < c.Timestamp || (ts == c.Timestamp && seq < c.Seq)
+	}
+	return ts > c.Timestamp || (ts == c.Timestamp && seq > c.Seq)
+}
+
+// ParseCursor decodes a cursor produced by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	ts, seq, ok := strings.Cut(s, ".")
+	if !ok {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	var c Cursor
+	var err error
+	if c.Timestamp, err = strconv.ParseInt(ts, 10, 64); err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	if c.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory MessageStore, suitable for tests and
+// single-process deployments that do not need durable history.
+type MemoryStore struct {
+	messages []Message
+	mu       sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append stores a message.
+func (s *MemoryStore) Append(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Tombstone removes a message's content and marks it deleted.
+func (s *MemoryStore) Tombstone(_ context.Context, channelName, chatID, messageID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.messages {
+		m := &s.messages[i]
+		if m.ID == messageID && m.ChannelName == channelName && m.ChatID == chatID {
+			m.Content = ""
+			m.DeletedAt = at
+		}
+	}
+	return nil
+}
+
+// List returns a page of messages in timestamp order. Messages with equal
+// timestamps are listed in the order they were appended.
+func (s *MemoryStore) List(_ context.Context, query ListQuery) (*Page, error) {
+	var cursor *Cursor
+	if query.Cursor != "" {
+		c, err := ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = &c
+	}
+	filters := query.Filters()
+
+	// Positions in the append log break timestamp ties
+	type entry struct {
+		msg Message
+		pos Cursor
+	}
+	s.mu.RLock()
+	var entries []entry
+	for i, msg := range s.messages {
+		pos := Cursor{Timestamp: msg.Timestamp.UnixNano(), Seq: int64(i)}
+		if !filters.matchesScope(msg) || (cursor != nil && !cursor.After(pos.Timestamp, pos.Seq, query.Reverse)) {
+			continue
+		}
+		entries = append(entries, entry{msg: msg, pos: pos})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].pos.After(entries[j].pos.Timestamp, entries[j].pos.Seq, query.Reverse)
+	})
+
+	page := &Page{}
+	limit := query.PageLimit()
+	for i, e := range entries {
+		if i == limit {
+			page.Next = entries[i-1].pos.String()
+			break
+		}
+		page.Messages = append(page.Messages, e.msg)
+	}
+	return page, nil
+}
+
+// Search returns messages containing every query term, ranked by term
+// frequency and then recency.
+func (s *MemoryStore) Search(_ context.Context, query SearchQuery) ([]SearchResult, error) {
+	terms := Tokenize(query.Text)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []SearchResult
+	for _, msg := range s.messages {
+		if !query.matchesFilters(msg) {
+			continue
+		}
+		score, ok := ScoreTerms(terms, msg.Content)
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{Message: msg, Score: score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Message.Timestamp.After(results[j].Message.Timestamp)
+	})
+
+	if len(results) > query.limit() {
+		results = results[:query.limit()]
+	}
+	return results, nil
+}
+
+// ScoreTerms counts term occurrences in content. It reports false if any
+// term is missing.
+func ScoreTerms(terms []string, content string) (float64, bool) {
+	if len(terms) == 0 {
+		return 0, true
+	}
+
+	counts := make(map[string]int)
+	for _, tok := range Tokenize(content) {
+		counts[tok]++
+	}
+
+	var score float64
+	for _, term := range terms {
+		n := counts[term]
+		if n == 0 {
+			return 0, false
+		}
+		score += float64(n)
+	}
+	return score, true
+}
+
+// Ensure MemoryStore implements MessageStore and Tombstoner interfaces.
+var (
+	_ MessageStore = (*MemoryStore)(nil)
+	_ Tombstoner   = (*MemoryStore)(nil)
+)
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSearch(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	messages := []Message{
+		{ID: "1", ChannelName: "telegram", ChatID: "100", Content: "My flight to Berlin is on Friday", Timestamp: base},
+		{ID: "2", ChannelName: "telegram", ChatID: "100", Content: "Flight flight flight", Timestamp: base.Add(time.Minute)},
+		{ID: "3", ChannelName: "telegram", ChatID: "200", Content: "Another flight in another chat", Timestamp: base.Add(2 * time.Minute)},
+		{ID: "4", ChannelName: "discord", ChatID: "100", Content: "Nothing relevant here", Timestamp: base.Add(3 * time.Minute)},
+	}
+	for _, m := range messages {
+		if err := s.Append(ctx, m); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	t.Run("ranking", func(t *testing.T) {
+		results, err := s.Search(ctx, SearchQuery{Text: "flight"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 3 {
+			t.Fatalf("len(results) = %d, want 3", len(results))
+		}
+		if results[0].Message.ID != "2" {
+			t.Errorf("top result = %s, want 2", results[0].Message.ID)
+		}
+	})
+
+	t.Run("all-terms", func(t *testing.T) {
+		results, err := s.Search(ctx, SearchQuery{Text: "flight berlin"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Message.ID != "1" {
+			t.Errorf("results = %+v, want only message 1", results)
+		}
+	})
+
+	t.Run("chat-scope", func(t *testing.T) {
+		results, err := s.Search(ctx, SearchQuery{Text: "flight", ChannelName: "telegram", ChatID: "200"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Message.ID != "3" {
+			t.Errorf("results = %+v, want only message 3", results)
+		}
+	})
+
+	t.Run("time-range", func(t *testing.T) {
+		results, err := s.Search(ctx, SearchQuery{
+			Text:  "flight",
+			Since: base.Add(30 * time.Second),
+			Until: base.Add(90 * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Message.ID != "2" {
+			t.Errorf("results = %+v, want only message 2", results)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		results, err := s.Search(ctx, SearchQuery{Text: "flight", Limit: 2})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("len(results) = %d, want 2", len(results))
+		}
+	})
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Hello, World! It's 2025.")
+	want := []string{"hello", "world", "it", "s", "2025"}
+	if len(got) != len(want) {
+		t.Fatalf("Tokenize = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Tokenize[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMemoryStoreTombstone(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	at := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+
+	for _, m := range []Message{
+		{ID: "1", ChannelName: "discord", ChatID: "100", Content: "secret plans", SenderID: "u1"},
+		{ID: "2", ChannelName: "discord", ChatID: "100", Content: "public plans"},
+	} {
+		if err := s.Append(ctx, m); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	if err := s.Tombstone(ctx, "discord", "100", "1", at); err != nil {
+		t.Fatalf("Tombstone failed: %v", err)
+	}
+
+	results, err := s.Search(ctx, SearchQuery{Text: "plans"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Message.ID != "2" {
+		t.Fatalf("results = %+v, want only message 2", results)
+	}
+
+	m := s.messages[0]
+	if m.Content != "" || !m.DeletedAt.Equal(at) {
+		t.Errorf("tombstoned message = %+v, want empty content and DeletedAt set", m)
+	}
+	if m.SenderID != "u1" {
+		t.Errorf("SenderID = %q, want metadata retained", m.SenderID)
+	}
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Appended out of order, with a timestamp tie between 2 and 3
+	for _, m := range []Message{
+		{ID: "4", ChannelName: "telegram", ChatID: "100", Timestamp: base.Add(3 * time.Minute)},
+		{ID: "1", ChannelName: "telegram", ChatID: "100", Timestamp: base},
+		{ID: "2", ChannelName: "telegram", ChatID: "100", Timestamp: base.Add(time.Minute)},
+		{ID: "3", ChannelName: "telegram", ChatID: "100", Timestamp: base.Add(time.Minute)},
+		{ID: "x", ChannelName: "telegram", ChatID: "200", Timestamp: base},
+	} {
+		if err := s.Append(ctx, m); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := s.Tombstone(ctx, "telegram", "100", "4", base); err != nil {
+		t.Fatalf("Tombstone failed: %v", err)
+	}
+
+	list := func(query ListQuery) []string {
+		t.Helper()
+		var ids []string
+		for {
+			page, err := s.List(ctx, query)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(page.Messages) > query.Limit {
+				t.Fatalf("page has %d messages, limit %d", len(page.Messages), query.Limit)
+			}
+			for _, m := range page.Messages {
+				ids = append(ids, m.ID)
+			}
+			if page.Next == "" {
+				return ids
+			}
+			query.Cursor = page.Next
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  string
+	}{
+		{"chat", ListQuery{ChannelName: "telegram", ChatID: "100", Limit: 2}, "1 2 3 4"},
+		{"reverse", ListQuery{ChannelName: "telegram", ChatID: "100", Limit: 3, Reverse: true}, "4 3 2 1"},
+		{"time-range", ListQuery{ChatID: "100", Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute), Limit: 1}, "2 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(list(tt.query), " "); got != tt.want {
+				t.Errorf("ids = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.List(ctx, ListQuery{Cursor: "bogus"}); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+}
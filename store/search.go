@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultSearchLimit is the number of results returned when a query sets no limit.
+const DefaultSearchLimit = 20
+
+// Searcher performs full-text search over stored messages.
+type Searcher interface {
+	// Search returns messages matching the query, best matches first.
+	Search(ctx context.Context, query SearchQuery) ([]SearchResult, error)
+}
+
+// SearchQuery describes a history search.
+type SearchQuery struct {
+	// Text is the full-text query. All terms must match.
+	Text string
+
+	// ChannelName limits results to a channel (empty = all).
+	ChannelName string
+
+	// ChatID limits results to a chat (empty = all).
+	ChatID string
+
+	// SenderID limits results to a sender (empty = all).
+	SenderID string
+
+	// Since limits results to messages at or after this time.
+	Since time.Time
+
+	// Until limits results to messages before this time.
+	Until time.Time
+
+	// Limit is the maximum number of results (0 = DefaultSearchLimit).
+	Limit int
+}
+
+// SearchResult is a single search hit.
+type SearchResult struct {
+	Message Message `json:"message"`
+
+	// Score is the backend-specific relevance score; higher is better.
+	Score float64 `json:"score"`
+}
+
+// limit returns the effective result limit.
+func (q SearchQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultSearchLimit
+	}
+	return q.Limit
+}
+
+// matchesFilters reports whether a searchable message satisfies the
+// non-text filters.
+func (q SearchQuery) matchesFilters(msg Message) bool {
+	return msg.DeletedAt.IsZero() && q.matchesScope(msg)
+}
+
+// matchesScope reports whether a message is in the channel, chat, sender,
+// and time range selected by the query.
+func (q SearchQuery) matchesScope(msg Message) bool {
+	if q.ChannelName != "" && msg.ChannelName != q.ChannelName {
+		return false
+	}
+	if q.ChatID != "" && msg.ChatID != q.ChatID {
+		return false
+	}
+	if q.SenderID != "" && msg.SenderID != q.SenderID {
+		return false
+	}
+	if !q.Since.IsZero() && msg.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !msg.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Tokenize splits text into lowercase search terms.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,375 @@
+// Package sqlstore provides SQL-backed message stores for SQLite and PostgreSQL.
+//
+// The store works with any database/sql driver. Callers open the *sql.DB with
+// the driver of their choice (e.g., modernc.org/sqlite or pgx) and pass it in.
+// Full-text search uses FTS5 on SQLite and tsvector on PostgreSQL.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+// Dialect identifies the SQL database flavor.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// Store is a MessageStore backed by a SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// Config configures the SQL store.
+type Config struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// New creates a new SQL store. Call Migrate before first use to create the schema.
+func New(config Config) (*Store, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database required")
+	}
+	switch config.Dialect {
+	case DialectSQLite, DialectPostgres:
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", config.Dialect)
+	}
+
+	return &Store{
+		db:      config.DB,
+		dialect: config.Dialect,
+	}, nil
+}
+
+// Migrate creates the message tables and search indexes if they don't exist,
+// and adds columns introduced since they were created.
+func (s *Store) Migrate(ctx context.Context) error {
+	for _, stmt := range s.schema() {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+	if err := s.addColumn(ctx, "deleted_at", "BIGINT"); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+// addColumn adds a nullable column to envoy_messages if it is missing.
+func (s *Store) addColumn(ctx context.Context, name, typ string) error {
+	if s.dialect == DialectPostgres {
+		_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+			"ALTER TABLE envoy_messages ADD COLUMN IF NOT EXISTS %s %s", name, typ))
+		return err
+	}
+
+	// SQLite has no IF NOT EXISTS for columns
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"ALTER TABLE envoy_messages ADD COLUMN %s %s", name, typ))
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "duplicate column") {
+		return nil
+	}
+	return err
+}
+
+// Tombstone removes a message's content and marks it deleted. On SQLite the
+// update trigger also removes it from the search index.
+func (s *Store) Tombstone(ctx context.Context, channelName, chatID, messageID string, at time.Time) error {
+	q := s.newQuery()
+	stmt := fmt.Sprintf(`UPDATE envoy_messages SET content = '', deleted_at = %s
+		WHERE channel = %s AND chat_id = %s AND id = %s`,
+		q.arg(at.UnixNano()), q.arg(channelName), q.arg(chatID), q.arg(messageID))
+
+	if _, err := s.db.ExecContext(ctx, stmt, q.args...); err != nil {
+		return fmt.Errorf("tombstone message: %w", err)
+	}
+	return nil
+}
+
+// Append stores a message.
+func (s *Store) Append(ctx context.Context, msg store.Message) error {
+	metadata, err := encodeMetadata(msg.Metadata)
+	if err != nil {
+		return err
+	}
+
+	q := s.newQuery()
+	stmt := fmt.Sprintf(`INSERT INTO envoy_messages
+		(id, channel, chat_id, sender_id, sender_name, content, direction, ts, metadata)
+		VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)`,
+		q.arg(msg.ID), q.arg(msg.ChannelName), q.arg(msg.ChatID),
+		q.arg(msg.SenderID), q.arg(msg.SenderName), q.arg(msg.Content),
+		q.arg(string(msg.Direction)), q.arg(msg.Timestamp.UnixNano()), q.arg(metadata))
+
+	if _, err := s.db.ExecContext(ctx, stmt, q.args...); err != nil {
+		return fmt.Errorf("insert message: %w", err)
+	}
+	return nil
+}
+
+// Search performs a full-text search over stored messages.
+func (s *Store) Search(ctx context.Context, query store.SearchQuery) ([]store.SearchResult, error) {
+	terms := store.Tokenize(query.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	q := s.newQuery()
+	var stmt strings.Builder
+
+	switch s.dialect {
+	case DialectSQLite:
+		// bm25() returns lower values for better matches
+		fmt.Fprintf(&stmt, `SELECT %s, -bm25(envoy_messages_fts) AS score
+			FROM envoy_messages_fts JOIN envoy_messages m ON m.seq = envoy_messages_fts.rowid
+			WHERE envoy_messages_fts MATCH %s`, columns("m"), q.arg(ftsQuery(terms)))
+	case DialectPostgres:
+		tsq := q.arg(strings.Join(terms, " "))
+		fmt.Fprintf(&stmt, `SELECT %s, ts_rank(m.content_tsv, plainto_tsquery('simple', %s)) AS score
+			FROM envoy_messages m
+			WHERE m.content_tsv @@ plainto_tsquery('simple', %s)`, columns("m"), tsq, tsq)
+	}
+
+	stmt.WriteString(" AND m.deleted_at IS NULL")
+	q.filters(&stmt, query)
+	fmt.Fprintf(&stmt, " ORDER BY score DESC, m.ts DESC LIMIT %s", q.arg(limit(query)))
+
+	rows, err := s.db.QueryContext(ctx, stmt.String(), q.args...)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var results []store.SearchResult
+	for rows.Next() {
+		var result store.SearchResult
+		if err := scanMessage(rows, &result.Message, &result.Score); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// List returns a page of messages ordered by timestamp, then insertion
+// order.
+func (s *Store) List(ctx context.Context, query store.ListQuery) (*store.Page, error) {
+	q := s.newQuery()
+	var stmt strings.Builder
+	fmt.Fprintf(&stmt, "SELECT %s, m.seq FROM envoy_messages m WHERE 1 = 1", columns("m"))
+	q.filters(&stmt, query.Filters())
+
+	op, order := ">", "ASC"
+	if query.Reverse {
+		op, order = "<", "DESC"
+	}
+	if query.Cursor != "" {
+		cursor, err := store.ParseCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		ts := q.arg(cursor.Timestamp)
+		fmt.Fprintf(&stmt, " AND (m.ts %s %s OR (m.ts = %s AND m.seq %s %s))",
+			op, ts, q.arg(cursor.Timestamp), op, q.arg(cursor.Seq))
+	}
+
+	// Fetch one extra row to detect a following page
+	limit := query.PageLimit()
+	fmt.Fprintf(&stmt, " ORDER BY m.ts %s, m.seq %s LIMIT %s", order, order, q.arg(limit+1))
+
+	rows, err := s.db.QueryContext(ctx, stmt.String(), q.args...)
+	if err != nil {
+		return nil, fmt.Errorf("list messages: %w", err)
+	}
+	defer rows.Close()
+
+	page := &store.Page{}
+	var last store.Cursor
+	for rows.Next() {
+		if len(page.Messages) == limit {
+			page.Next = last.String()
+			break
+		}
+		var msg store.Message
+		if err := scanMessage(rows, &msg, &last.Seq); err != nil {
+			return nil, err
+		}
+		last.Timestamp = msg.Timestamp.UnixNano()
+		page.Messages = append(page.Messages, msg)
+	}
+	return page, rows.Err()
+}
+
+// schema returns the DDL statements for the dialect.
+func (s *Store) schema() []string {
+	switch s.dialect {
+	case DialectPostgres:
+		return []string{
+			`CREATE TABLE IF NOT EXISTS envoy_messages (
+				seq BIGSERIAL PRIMARY KEY,
+				id TEXT NOT NULL,
+				channel TEXT NOT NULL,
+				chat_id TEXT NOT NULL,
+				sender_id TEXT NOT NULL DEFAULT '',
+				sender_name TEXT NOT NULL DEFAULT '',
+				content TEXT NOT NULL,
+				direction TEXT NOT NULL,
+				ts BIGINT NOT NULL,
+				metadata TEXT NOT NULL DEFAULT '',
+				content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED
+			)`,
+			`CREATE INDEX IF NOT EXISTS envoy_messages_chat_idx ON envoy_messages (channel, chat_id, ts)`,
+			`CREATE INDEX IF NOT EXISTS envoy_messages_tsv_idx ON envoy_messages USING GIN (content_tsv)`,
+		}
+	default:
+		return []string{
+			`CREATE TABLE IF NOT EXISTS envoy_messages (
+				seq INTEGER PRIMARY KEY AUTOINCREMENT,
+				id TEXT NOT NULL,
+				channel TEXT NOT NULL,
+				chat_id TEXT NOT NULL,
+				sender_id TEXT NOT NULL DEFAULT '',
+				sender_name TEXT NOT NULL DEFAULT '',
+				content TEXT NOT NULL,
+				direction TEXT NOT NULL,
+				ts INTEGER NOT NULL,
+				metadata TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX IF NOT EXISTS envoy_messages_chat_idx ON envoy_messages (channel, chat_id, ts)`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS envoy_messages_fts USING fts5(
+				content, content='envoy_messages', content_rowid='seq'
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS envoy_messages_ai AFTER INSERT ON envoy_messages BEGIN
+				INSERT INTO envoy_messages_fts(rowid, content) VALUES (new.seq, new.content);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS envoy_messages_ad AFTER DELETE ON envoy_messages BEGIN
+				INSERT INTO envoy_messages_fts(envoy_messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS envoy_messages_au AFTER UPDATE ON envoy_messages BEGIN
+				INSERT INTO envoy_messages_fts(envoy_messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+				INSERT INTO envoy_messages_fts(rowid, content) VALUES (new.seq, new.content);
+			END`,
+		}
+	}
+}
+
+// query accumulates positional arguments with dialect-specific placeholders.
+type query struct {
+	dialect Dialect
+	args    []interface{}
+}
+
+func (s *Store) newQuery() *query {
+	return &query{dialect: s.dialect}
+}
+
+// arg appends an argument and returns its placeholder.
+func (q *query) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	if q.dialect == DialectPostgres {
+		return fmt.Sprintf("$%d", len(q.args))
+	}
+	return "?"
+}
+
+// filters appends the non-text search filters as AND clauses.
+func (q *query) filters(stmt *strings.Builder, query store.SearchQuery) {
+	if query.ChannelName != "" {
+		fmt.Fprintf(stmt, " AND m.channel = %s", q.arg(query.ChannelName))
+	}
+	if query.ChatID != "" {
+		fmt.Fprintf(stmt, " AND m.chat_id = %s", q.arg(query.ChatID))
+	}
+	if query.SenderID != "" {
+		fmt.Fprintf(stmt, " AND m.sender_id = %s", q.arg(query.SenderID))
+	}
+	if !query.Since.IsZero() {
+		fmt.Fprintf(stmt, " AND m.ts >= %s", q.arg(query.Since.UnixNano()))
+	}
+	if !query.Until.IsZero() {
+		fmt.Fprintf(stmt, " AND m.ts < %s", q.arg(query.Until.UnixNano()))
+	}
+}
+
+// columns returns the message column list for a table alias.
+func columns(alias string) string {
+	cols := []string{"id", "channel", "chat_id", "sender_id", "sender_name", "content", "direction", "ts", "metadata", "deleted_at"}
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
+// scanMessage scans a row produced by columns() plus any extra destinations.
+func scanMessage(rows *sql.Rows, msg *store.Message, extra ...interface{}) error {
+	var direction, metadata string
+	var ts int64
+	var deletedAt sql.NullInt64
+	dest := []interface{}{
+		&msg.ID, &msg.ChannelName, &msg.ChatID, &msg.SenderID, &msg.SenderName,
+		&msg.Content, &direction, &ts, &metadata, &deletedAt,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return fmt.Errorf("scan message: %w", err)
+	}
+
+	msg.Direction = store.Direction(direction)
+	msg.Timestamp = time.Unix(0, ts)
+	if deletedAt.Valid {
+		msg.DeletedAt = time.Unix(0, deletedAt.Int64)
+	}
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &msg.Metadata); err != nil {
+			return fmt.Errorf("decode metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+// encodeMetadata serializes message metadata as JSON.
+func encodeMetadata(metadata map[string]interface{}) (string, error) {
+	if len(metadata) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("encode metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// ftsQuery builds an FTS5 query requiring every term. Terms are quoted so
+// FTS5 operators in user input are treated as literals.
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// limit returns the effective result limit for a query.
+func limit(query store.SearchQuery) int {
+	if query.Limit <= 0 {
+		return store.DefaultSearchLimit
+	}
+	return query.Limit
+}
+
+// Ensure Store implements MessageStore and Tombstoner interfaces.
+var (
+	_ store.MessageStore = (*Store)(nil)
+	_ store.Tombstoner   = (*Store)(nil)
+)
@@ -0,0 +1,222 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/agentplexus/envoy/store"
+)
+
+var base = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestStore returns a migrated store on an in-memory SQLite database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Each connection would open its own in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	s, err := New(Config{DB: db, Dialect: DialectSQLite})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	// Migrating twice is a no-op
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(context.Background()); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+	}
+	return s
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("New without a database succeeded")
+	}
+	if _, err := New(Config{DB: &sql.DB{}, Dialect: "mysql"}); err == nil {
+		t.Error("New with an unsupported dialect succeeded")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	for _, m := range []store.Message{
+		{ID: "1", ChannelName: "telegram", ChatID: "100", SenderID: "alice", Content: "My flight lands at 9pm, the flight was delayed", Direction: store.DirectionIncoming, Timestamp: base, Metadata: map[string]interface{}{"lang": "en"}},
+		{ID: "2", ChannelName: "telegram", ChatID: "100", SenderID: "bot", Content: "Noted your flight and the many other details you mentioned earlier today", Direction: store.DirectionOutgoing, Timestamp: base.Add(time.Hour)},
+		{ID: "3", ChannelName: "discord", ChatID: "200", SenderID: "bob", Content: "Flight booked", Direction: store.DirectionIncoming, Timestamp: base.Add(2 * time.Hour)},
+		{ID: "4", ChannelName: "telegram", ChatID: "100", SenderID: "alice", Content: "What about the hotel?", Direction: store.DirectionIncoming, Timestamp: base.Add(3 * time.Hour)},
+		{ID: "5", ChannelName: "telegram", ChatID: "100", SenderID: "alice", Content: `She said "c++ AND rust" OR (go*)`, Direction: store.DirectionIncoming, Timestamp: base.Add(4 * time.Hour)},
+	} {
+		if err := s.Append(ctx, m); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query store.SearchQuery
+		want  string
+	}{
+		// The repeated term in the first message ranks it above the longer second
+		{"ranking", store.SearchQuery{Text: "flight", ChannelName: "telegram"}, "1 2"},
+		{"all terms", store.SearchQuery{Text: "flight delayed"}, "1"},
+		{"case", store.SearchQuery{Text: "HOTEL"}, "4"},
+		{"chat", store.SearchQuery{Text: "flight", ChannelName: "discord", ChatID: "200"}, "3"},
+		{"sender", store.SearchQuery{Text: "flight", SenderID: "bob"}, "3"},
+		{"since", store.SearchQuery{Text: "flight", Since: base.Add(time.Hour)}, "3 2"},
+		{"until", store.SearchQuery{Text: "flight", Until: base.Add(time.Hour)}, "1"},
+		{"limit", store.SearchQuery{Text: "flight", Limit: 1, ChannelName: "telegram"}, "1"},
+		{"operators", store.SearchQuery{Text: `"c++ AND rust" OR (go*`}, "5"},
+		{"no match", store.SearchQuery{Text: "train"}, ""},
+		{"no terms", store.SearchQuery{Text: "?!"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.Search(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			var ids []string
+			for _, r := range results {
+				ids = append(ids, r.Message.ID)
+			}
+			if got := strings.Join(ids, " "); got != tt.want {
+				t.Errorf("ids = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	results, err := s.Search(ctx, store.SearchQuery{Text: "lands"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search = %+v, %v", results, err)
+	}
+	m := results[0].Message
+	if m.ChannelName != "telegram" || m.ChatID != "100" || m.SenderID != "alice" || m.Direction != store.DirectionIncoming ||
+		!m.Timestamp.Equal(base) || m.Metadata["lang"] != "en" || results[0].Score <= 0 {
+		t.Errorf("result = %+v", results[0])
+	}
+}
+
+func TestTombstone(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	msg := store.Message{ID: "m1", ChannelName: "discord", ChatID: "100", Content: "delete me", Direction: store.DirectionIncoming, Timestamp: base}
+	if err := s.Append(ctx, msg); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := s.Tombstone(ctx, "discord", "100", "m1", base.Add(time.Minute)); err != nil {
+		t.Fatalf("Tombstone failed: %v", err)
+	}
+	if err := s.Tombstone(ctx, "discord", "100", "missing", base); err != nil {
+		t.Errorf("Tombstone missing = %v", err)
+	}
+
+	results, err := s.Search(ctx, store.SearchQuery{Text: "delete"})
+	if err != nil || len(results) != 0 {
+		t.Errorf("Search = %+v, %v; want the tombstoned message excluded", results, err)
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		terms []string
+		want  string
+	}{
+		{[]string{"flight"}, `"flight"`},
+		{[]string{"flight", "delayed"}, `"flight" "delayed"`},
+		{[]string{"AND", "NEAR"}, `"AND" "NEAR"`},
+		{[]string{`say"hi`}, `"say""hi"`},
+	}
+	for _, tt := range tests {
+		if got := ftsQuery(tt.terms); got != tt.want {
+			t.Errorf("ftsQuery(%q) = %s, want %s", tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	// Appended out of order, with a timestamp tie between 2 and 3
+	for _, m := range []store.Message{
+		{ID: "4", ChannelName: "telegram", ChatID: "100", Content: "four", Timestamp: base.Add(3 * time.Minute)},
+		{ID: "1", ChannelName: "telegram", ChatID: "100", Content: "one", Timestamp: base},
+		{ID: "2", ChannelName: "telegram", ChatID: "100", Content: "two", Timestamp: base.Add(time.Minute)},
+		{ID: "3", ChannelName: "telegram", ChatID: "100", Content: "three", Timestamp: base.Add(time.Minute)},
+		{ID: "5", ChannelName: "telegram", ChatID: "100", Content: "five", Timestamp: base.Add(4 * time.Minute)},
+		{ID: "x", ChannelName: "telegram", ChatID: "200", Content: "other", Timestamp: base},
+	} {
+		if err := s.Append(ctx, m); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := s.Tombstone(ctx, "telegram", "100", "4", base); err != nil {
+		t.Fatalf("Tombstone failed: %v", err)
+	}
+
+	list := func(query store.ListQuery) []string {
+		t.Helper()
+		var ids []string
+		for {
+			page, err := s.List(ctx, query)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(page.Messages) > query.Limit {
+				t.Fatalf("page has %d messages, limit %d", len(page.Messages), query.Limit)
+			}
+			for _, m := range page.Messages {
+				ids = append(ids, m.ID)
+			}
+			if page.Next == "" {
+				return ids
+			}
+			query.Cursor = page.Next
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query store.ListQuery
+		want  string
+	}{
+		{"chat", store.ListQuery{ChannelName: "telegram", ChatID: "100", Limit: 2}, "1 2 3 4 5"},
+		{"page size 1", store.ListQuery{ChatID: "100", Limit: 1}, "1 2 3 4 5"},
+		{"reverse", store.ListQuery{ChannelName: "telegram", ChatID: "100", Limit: 2, Reverse: true}, "5 4 3 2 1"},
+		{"reverse page size 1", store.ListQuery{ChatID: "100", Limit: 1, Reverse: true}, "5 4 3 2 1"},
+		{"time range", store.ListQuery{ChatID: "100", Since: base.Add(time.Minute), Until: base.Add(4 * time.Minute), Limit: 1}, "2 3 4"},
+		{"reverse time range", store.ListQuery{ChatID: "100", Since: base.Add(time.Minute), Until: base.Add(4 * time.Minute), Limit: 2, Reverse: true}, "4 3 2"},
+		{"all chats", store.ListQuery{Limit: 4}, "1 x 2 3 4 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(list(tt.query), " "); got != tt.want {
+				t.Errorf("ids = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Tombstoned messages are listed without content
+	page, err := s.List(ctx, store.ListQuery{ChatID: "100", Since: base.Add(3 * time.Minute), Limit: 1})
+	if err != nil || len(page.Messages) != 1 {
+		t.Fatalf("List = %+v, %v", page, err)
+	}
+	if m := page.Messages[0]; m.ID != "4" || m.Content != "" || !m.DeletedAt.Equal(base) {
+		t.Errorf("tombstoned message = %+v", m)
+	}
+
+	if _, err := s.List(ctx, store.ListQuery{Cursor: "bogus"}); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+}
@@ -0,0 +1,67 @@
+// Package store provides conversation history persistence for envoy.
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Direction indicates whether a message was received or sent.
+type Direction string
+
+const (
+	DirectionIncoming Direction = "incoming"
+	DirectionOutgoing Direction = "outgoing"
+)
+
+// Message is a persisted conversation message.
+type Message struct {
+	// ID is the platform message identifier.
+	ID string `json:"id"`
+
+	// ChannelName is the channel the message belongs to (e.g., "telegram").
+	ChannelName string `json:"channel"`
+
+	// ChatID is the chat/conversation identifier.
+	ChatID string `json:"chat_id"`
+
+	// SenderID is the sender's identifier.
+	SenderID string `json:"sender_id,omitempty"`
+
+	// SenderName is the sender's display name.
+	SenderName string `json:"sender_name,omitempty"`
+
+	// Content is the message text content.
+	Content string `json:"content"`
+
+	// Direction is whether the message was received or sent.
+	Direction Direction `json:"direction"`
+
+	// Timestamp is when the message was sent.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Metadata contains additional message attributes.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// DeletedAt is set when the message was deleted on the platform. Deleted
+	// messages keep their identifiers and metadata but not their content.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// MessageStore persists conversation history.
+type MessageStore interface {
+	Searcher
+	Lister
+
+	// Append stores a message.
+	Append(ctx context.Context, msg Message) error
+}
+
+// Tombstoner marks messages deleted on the platform. Tombstoned messages
+// have their content removed and are excluded from search, while their
+// identifiers and metadata are retained.
+type Tombstoner interface {
+	// Tombstone marks a message deleted at the given time. Tombstoning a
+	// message that is not stored is not an error.
+	Tombstone(ctx context.Context, channelName, chatID, messageID string, at time.Time) error
+}
@@ -0,0 +1,65 @@
+package toolguard
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Chat commands answering confirmation prompts.
+const (
+	ConfirmCommand = "/confirm"
+	CancelCommand  = "/cancel"
+)
+
+// Command returns a handler for the confirmation commands:
+//
+//	/confirm [code]  run a held call
+//	/cancel [code]   drop a held call
+//
+// The code may be omitted when the sender has a single call pending. Only
+// the sender whose message triggered a call can confirm or cancel it, and
+// each call runs at most once. Register it with a higher priority than the
+// agent handler and Exclusive set.
+func Command(g *Guard, sender channels.Sender) channels.MessageHandler {
+	return func(ctx context.Context, msg channels.IncomingMessage) error {
+		confirm := true
+		code, ok := channels.ParseCommand(msg.Content, ConfirmCommand)
+		if !ok {
+			if code, ok = channels.ParseCommand(msg.Content, CancelCommand); !ok {
+				return nil
+			}
+			confirm = false
+		}
+
+		reply := g.answer(ctx, msg, code, confirm)
+		return sender.Send(ctx, msg.ChannelName, msg.ChatID, channels.OutgoingMessage{
+			Content: reply,
+			ReplyTo: msg.ID,
+		})
+	}
+}
+
+// answer confirms or cancels a held call and returns the reply.
+func (g *Guard) answer(ctx context.Context, msg channels.IncomingMessage, code string, confirm bool) string {
+	sessionID := channels.SessionID(msg.ChannelName, msg.ChatID)
+	p, ok := g.take(sessionID, code, msg.SenderID)
+	if !ok {
+		return "Nothing to confirm. The code may be wrong or expired."
+	}
+	desc := p.tool.describe(p.args)
+	if !confirm {
+		return fmt.Sprintf("Canceled %s.", desc)
+	}
+
+	g.logger.Info("tool call confirmed", "tool", p.tool.Name(), "session", sessionID, "sender", msg.SenderID)
+	result, err := g.run(ctx, sessionID, p.tool, p.args)
+	if err != nil {
+		return fmt.Sprintf("%s failed: %v", desc, err)
+	}
+	if result == "" {
+		return fmt.Sprintf("Done: %s.", desc)
+	}
+	return fmt.Sprintf("Done: %s.\n%s", desc, result)
+}
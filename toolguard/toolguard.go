@@ -0,0 +1,358 @@
+// Package toolguard protects agent tools with side effects, such as
+// purchases or deployments, from duplicate and racing calls.
+//
+// Guarded tools run one at a time per conversation, so rapid-fire messages
+// cannot trigger the same action concurrently. Tools can also require
+// explicit confirmation: the call is held and the agent asks the user to
+// reply "/confirm <code>", which runs it exactly once.
+//
+//	guard := toolguard.New(toolguard.Config{})
+//	ag.RegisterTool(guard.Wrap(deployTool, toolguard.ToolConfig{Confirm: true}))
+//	router.AddHandler(channels.RouteHandler{
+//		Pattern:   channels.All(),
+//		Handler:   toolguard.Command(guard, router),
+//		Priority:  100,
+//		Exclusive: true,
+//	})
+package toolguard
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/envoy/agent"
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DefaultConfirmTimeout is how long a confirmation code stays valid.
+const DefaultConfirmTimeout = 5 * time.Minute
+
+// ErrNoConversation is returned when a guarded tool runs outside message
+// processing, where it cannot tell which conversation to lock.
+var ErrNoConversation = errors.New("no conversation in context")
+
+// Config configures a Guard.
+type Config struct {
+	// ConfirmTimeout is how long a call awaits confirmation before it is
+	// dropped (default: DefaultConfirmTimeout).
+	ConfirmTimeout time.Duration
+
+	Logger *slog.Logger
+}
+
+// ToolConfig configures a guarded tool.
+type ToolConfig struct {
+	// Confirm holds each call until the user who sent the triggering
+	// message confirms it.
+	Confirm bool
+
+	// DedupWindow, if set, returns the previous result instead of running
+	// the tool again when it is called with the same arguments in the same
+	// conversation within the window.
+	DedupWindow time.Duration
+
+	// Describe summarizes a call for confirmation prompts (default: the
+	// tool name and arguments).
+	Describe func(args json.RawMessage) string
+}
+
+// Guard holds per-conversation locks and pending confirmations.
+type Guard struct {
+	timeout time.Duration
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	locks   map[string]*sessionLock
+	pending map[string]map[string]*pendingCall
+	recent  map[string]recentCall
+}
+
+// sessionLock is a context-aware mutex shared by callers in a conversation.
+type sessionLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// pendingCall is a call awaiting confirmation.
+type pendingCall struct {
+	code    string
+	tool    *Tool
+	args    json.RawMessage
+	sender  string
+	expires time.Time
+}
+
+// recentCall is the result of a completed call, kept for DedupWindow.
+type recentCall struct {
+	result  string
+	expires time.Time
+}
+
+// New creates a new Guard.
+func New(config Config) *Guard {
+	if config.ConfirmTimeout <= 0 {
+		config.ConfirmTimeout = DefaultConfirmTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Guard{
+		timeout: config.ConfirmTimeout,
+		logger:  config.Logger,
+		locks:   make(map[string]*sessionLock),
+		pending: make(map[string]map[string]*pendingCall),
+		recent:  make(map[string]recentCall),
+	}
+}
+
+// Lock acquires the lock of a conversation, waiting until it is free or ctx
+// is done, and returns the function releasing it.
+func (g *Guard) Lock(ctx context.Context, sessionID string) (unlock func(), err error) {
+	g.mu.Lock()
+	l, ok := g.locks[sessionID]
+	if !ok {
+		l = &sessionLock{ch: make(chan struct{}, 1)}
+		g.locks[sessionID] = l
+	}
+	l.refs++
+	g.mu.Unlock()
+
+	select {
+	case l.ch <- struct{}{}:
+	case <-ctx.Done():
+		g.release(sessionID, l)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.ch
+			g.release(sessionID, l)
+		})
+	}, nil
+}
+
+// release drops a reference to a lock, removing it when unused.
+func (g *Guard) release(sessionID string, l *sessionLock) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(g.locks, sessionID)
+	}
+}
+
+// Wrap returns tool guarded by g.
+func (g *Guard) Wrap(tool agent.Tool, config ToolConfig) *Tool {
+	return &Tool{tool: tool, guard: g, config: config}
+}
+
+// Tool is an agent tool guarded by a Guard.
+type Tool struct {
+	tool   agent.Tool
+	guard  *Guard
+	config ToolConfig
+}
+
+// Name returns the wrapped tool's name.
+func (t *Tool) Name() string {
+	return t.tool.Name()
+}
+
+// Description returns the wrapped tool's description, noting whether calls
+// need confirmation.
+func (t *Tool) Description() string {
+	if t.config.Confirm {
+		return t.tool.Description() + " Calls must be confirmed by the user before they run."
+	}
+	return t.tool.Description()
+}
+
+// Parameters returns the wrapped tool's parameters.
+func (t *Tool) Parameters() map[string]interface{} {
+	return t.tool.Parameters()
+}
+
+// Execute runs the tool under the conversation's lock, or, with Confirm,
+// holds the call and returns instructions for the user to confirm it.
+func (t *Tool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	msg, ok := channels.MessageFromContext(ctx)
+	if !ok {
+		return "", ErrNoConversation
+	}
+	sessionID := channels.SessionID(msg.ChannelName, msg.ChatID)
+
+	if !t.config.Confirm {
+		return t.guard.run(ctx, sessionID, t, args)
+	}
+	p, err := t.guard.hold(sessionID, t, args, msg.SenderID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Not run yet: %s needs confirmation. Ask the user to reply %q to proceed or %q to abort within %s.",
+		t.describe(args), ConfirmCommand+" "+p.code, CancelCommand+" "+p.code, t.guard.timeout), nil
+}
+
+// describe summarizes a call.
+func (t *Tool) describe(args json.RawMessage) string {
+	if t.config.Describe != nil {
+		return t.config.Describe(args)
+	}
+	return fmt.Sprintf("%s %s", t.tool.Name(), compact(args))
+}
+
+// run executes a call under the conversation's lock.
+func (g *Guard) run(ctx context.Context, sessionID string, t *Tool, args json.RawMessage) (string, error) {
+	unlock, err := g.Lock(ctx, sessionID)
+	if err != nil {
+		return "", fmt.Errorf("lock conversation: %w", err)
+	}
+	defer unlock()
+
+	key := sessionID + "\x00" + t.Name() + "\x00" + compact(args)
+	if t.config.DedupWindow > 0 {
+		g.mu.Lock()
+		prev, ok := g.recent[key]
+		g.mu.Unlock()
+		if ok && time.Now().Before(prev.expires) {
+			g.logger.Info("duplicate tool call skipped", "tool", t.Name(), "session", sessionID)
+			return prev.result, nil
+		}
+	}
+
+	result, err := t.tool.Execute(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	if t.config.DedupWindow > 0 {
+		g.mu.Lock()
+		g.pruneRecent()
+		g.recent[key] = recentCall{result: result, expires: time.Now().Add(t.config.DedupWindow)}
+		g.mu.Unlock()
+	}
+	return result, nil
+}
+
+// pruneRecent drops expired results. g.mu must be held.
+func (g *Guard) pruneRecent() {
+	now := time.Now()
+	for k, c := range g.recent {
+		if !now.Before(c.expires) {
+			delete(g.recent, k)
+		}
+	}
+}
+
+// hold records a call awaiting confirmation. A repeated identical call
+// returns the call already pending.
+func (g *Guard) hold(sessionID string, t *Tool, args json.RawMessage, sender string) (*pendingCall, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	calls := g.pendingCalls(sessionID)
+	for _, p := range calls {
+		if p.tool == t && p.sender == sender && compact(p.args) == compact(args) {
+			p.expires = time.Now().Add(g.timeout)
+			return p, nil
+		}
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return nil, err
+	}
+	p := &pendingCall{
+		code:    code,
+		tool:    t,
+		args:    args,
+		sender:  sender,
+		expires: time.Now().Add(g.timeout),
+	}
+	if calls == nil {
+		calls = make(map[string]*pendingCall)
+		g.pending[sessionID] = calls
+	}
+	calls[code] = p
+	return p, nil
+}
+
+// take removes and returns a conversation's pending call by code, or its
+// only pending call if code is empty. Calls from other senders are not
+// returned.
+func (g *Guard) take(sessionID, code, sender string) (*pendingCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	calls := g.pendingCalls(sessionID)
+	var p *pendingCall
+	if code == "" {
+		for _, c := range calls {
+			if c.sender != sender {
+				continue
+			}
+			if p != nil {
+				return nil, false
+			}
+			p = c
+		}
+	} else {
+		p = calls[strings.ToUpper(code)]
+	}
+	if p == nil || p.sender != sender {
+		return nil, false
+	}
+	delete(calls, p.code)
+	if len(calls) == 0 {
+		delete(g.pending, sessionID)
+	}
+	return p, true
+}
+
+// pendingCalls returns a conversation's unexpired pending calls. g.mu must
+// be held.
+func (g *Guard) pendingCalls(sessionID string) map[string]*pendingCall {
+	calls := g.pending[sessionID]
+	now := time.Now()
+	for code, p := range calls {
+		if now.After(p.expires) {
+			delete(calls, code)
+		}
+	}
+	if calls != nil && len(calls) == 0 {
+		delete(g.pending, sessionID)
+		return nil
+	}
+	return calls
+}
+
+// newCode returns a random confirmation code.
+func newCode() (string, error) {
+	var b [3]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate confirmation code: %w", err)
+	}
+	return strings.ToUpper(hex.EncodeToString(b[:])), nil
+}
+
+// compact returns args without insignificant whitespace, so equivalent
+// calls compare equal.
+func compact(args json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, args); err != nil {
+		return string(args)
+	}
+	return buf.String()
+}
+
+// Ensure Tool implements agent.Tool.
+var _ agent.Tool = (*Tool)(nil)
@@ -0,0 +1,195 @@
+package toolguard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+// countingTool counts its executions, optionally holding each one until
+// release is closed.
+type countingTool struct {
+	calls   atomic.Int32
+	running atomic.Int32
+	overlap atomic.Bool
+	release chan struct{}
+}
+
+func (t *countingTool) Name() string                       { return "deploy" }
+func (t *countingTool) Description() string                { return "Deploys." }
+func (t *countingTool) Parameters() map[string]interface{} { return nil }
+
+func (t *countingTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	n := t.calls.Add(1)
+	if t.running.Add(1) > 1 {
+		t.overlap.Store(true)
+	}
+	defer t.running.Add(-1)
+	if t.release != nil {
+		<-t.release
+	}
+	return fmt.Sprintf("deployed #%d", n), nil
+}
+
+func chatContext(sender string) context.Context {
+	return channels.WithMessage(context.Background(), channels.IncomingMessage{
+		ChannelName: "telegram",
+		ChatID:      "42",
+		SenderID:    sender,
+	})
+}
+
+func TestGuardSerializesPerConversation(t *testing.T) {
+	inner := &countingTool{release: make(chan struct{})}
+	tool := New(Config{}).Wrap(inner, ToolConfig{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tool.Execute(chatContext("alice"), json.RawMessage(`{}`)); err != nil {
+				t.Errorf("Execute: %v", err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if inner.calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", inner.calls.Load())
+	}
+	if inner.overlap.Load() {
+		t.Error("calls in the same conversation overlapped")
+	}
+
+	if _, err := tool.Execute(context.Background(), nil); !errors.Is(err, ErrNoConversation) {
+		t.Errorf("Execute without message = %v, want ErrNoConversation", err)
+	}
+}
+
+func TestGuardLockHonorsContext(t *testing.T) {
+	g := New(Config{})
+	unlock, err := g.Lock(context.Background(), "s")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.Lock(ctx, "s"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Lock = %v, want deadline exceeded", err)
+	}
+
+	unlock()
+	unlock()
+	if len(g.locks) != 0 {
+		t.Errorf("%d locks retained after release", len(g.locks))
+	}
+}
+
+func TestGuardDedupWindow(t *testing.T) {
+	inner := &countingTool{}
+	tool := New(Config{}).Wrap(inner, ToolConfig{DedupWindow: time.Minute})
+	ctx := chatContext("alice")
+
+	first, _ := tool.Execute(ctx, json.RawMessage(`{"env": "prod"}`))
+	second, _ := tool.Execute(ctx, json.RawMessage(`{"env":"prod"}`))
+	if inner.calls.Load() != 1 || second != first {
+		t.Errorf("calls = %d, results %q/%q; want one call with shared result", inner.calls.Load(), first, second)
+	}
+
+	if _, err := tool.Execute(ctx, json.RawMessage(`{"env":"staging"}`)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if inner.calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2 after different arguments", inner.calls.Load())
+	}
+}
+
+func TestGuardConfirmation(t *testing.T) {
+	inner := &countingTool{}
+	g := New(Config{})
+	tool := g.Wrap(inner, ToolConfig{Confirm: true})
+	sender := &channelstest.Sender{}
+	handle := Command(g, sender)
+	args := json.RawMessage(`{"env":"prod"}`)
+
+	prompt, err := tool.Execute(chatContext("alice"), args)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	again, _ := tool.Execute(chatContext("alice"), args)
+	if inner.calls.Load() != 0 {
+		t.Fatal("tool ran before confirmation")
+	}
+	if again != prompt {
+		t.Errorf("repeated call prompt = %q, want the pending %q", again, prompt)
+	}
+	i := strings.Index(prompt, ConfirmCommand+" ")
+	if i < 0 {
+		t.Fatalf("prompt %q has no confirm command", prompt)
+	}
+	code := prompt[i+len(ConfirmCommand)+1 : i+len(ConfirmCommand)+7]
+
+	msg := func(sender, content string) channels.IncomingMessage {
+		return channels.IncomingMessage{ChannelName: "telegram", ChatID: "42", SenderID: sender, Content: content}
+	}
+
+	// Only the requester can confirm
+	if err := handle(context.Background(), msg("bob", "/confirm "+code)); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if inner.calls.Load() != 0 || !strings.HasPrefix(sender.Last(""), "Nothing to confirm") {
+		t.Fatalf("other sender confirmed: calls = %d, reply %q", inner.calls.Load(), sender.Last(""))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := handle(context.Background(), msg("alice", "/confirm "+strings.ToLower(code))); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+	if inner.calls.Load() != 1 {
+		t.Errorf("calls = %d, want exactly 1 after double confirmation", inner.calls.Load())
+	}
+	if got := sender.Contents()[1]; got != "Done: deploy {\"env\":\"prod\"}.\ndeployed #1" {
+		t.Errorf("confirm reply = %q", got)
+	}
+
+	// Cancel without a code when a single call is pending
+	if _, err := tool.Execute(chatContext("alice"), args); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if err := handle(context.Background(), msg("alice", "/cancel")); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if !strings.HasPrefix(sender.Last(""), "Canceled") || inner.calls.Load() != 1 {
+		t.Errorf("cancel reply = %q, calls = %d", sender.Last(""), inner.calls.Load())
+	}
+}
+
+func TestGuardConfirmationExpires(t *testing.T) {
+	g := New(Config{ConfirmTimeout: time.Millisecond})
+	tool := g.Wrap(&countingTool{}, ToolConfig{Confirm: true})
+	if _, err := tool.Execute(chatContext("alice"), json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := g.take(channels.SessionID("telegram", "42"), "", "alice"); ok {
+		t.Error("expired call was confirmable")
+	}
+	if len(g.pending) != 0 {
+		t.Errorf("%d conversations with expired calls retained", len(g.pending))
+	}
+}
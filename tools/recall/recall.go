@@ -0,0 +1,222 @@
+// Package recall provides a conversation history retrieval tool for envoy.
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/envoy/agent"
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+const (
+	defaultLimit = 5
+	maxLimit     = 20
+)
+
+// Tool lets the agent search earlier conversation history.
+type Tool struct {
+	searcher    store.Searcher
+	allowGlobal bool
+	logger      *slog.Logger
+}
+
+// Config configures the recall tool.
+type Config struct {
+	// Searcher is the history search backend.
+	Searcher store.Searcher
+
+	// AllowGlobal permits the "all" scope, searching across every chat.
+	// Disabled by default so one user's conversations can't leak into another's.
+	AllowGlobal bool
+
+	Logger *slog.Logger
+}
+
+// New creates a new recall tool.
+func New(config Config) (*Tool, error) {
+	if config.Searcher == nil {
+		return nil, fmt.Errorf("searcher required")
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	return &Tool{
+		searcher:    config.Searcher,
+		allowGlobal: config.AllowGlobal,
+		logger:      config.Logger,
+	}, nil
+}
+
+// Name returns the tool name.
+func (t *Tool) Name() string {
+	return "search_history"
+}
+
+// Description returns the tool description.
+func (t *Tool) Description() string {
+	return "Search earlier messages in the conversation history. Use this to recall facts the user mentioned before instead of asking again."
+}
+
+// Parameters returns the JSON schema for tool parameters.
+func (t *Tool) Parameters() map[string]interface{} {
+	scopes := []string{"chat"}
+	if t.allowGlobal {
+		scopes = append(scopes, "all")
+	}
+
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Keywords to search for",
+			},
+			"scope": map[string]interface{}{
+				"type":        "string",
+				"description": "Which conversations to search (default: chat, the current conversation)",
+				"enum":        scopes,
+			},
+			"since": map[string]interface{}{
+				"type":        "string",
+				"description": "Only messages after this time: RFC3339 timestamp or relative age like 24h or 7d",
+			},
+			"until": map[string]interface{}{
+				"type":        "string",
+				"description": "Only messages before this time: RFC3339 timestamp or relative age like 24h or 7d",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of results (default: 5, max: 20)",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+// Execute runs the history search.
+func (t *Tool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Query string `json:"query"`
+		Scope string `json:"scope"`
+		Since string `json:"since"`
+		Until string `json:"until"`
+		Limit int    `json:"limit"`
+	}
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("parse parameters: %w", err)
+	}
+
+	if strings.TrimSpace(params.Query) == "" {
+		return "", fmt.Errorf("query required")
+	}
+
+	if params.Limit <= 0 {
+		params.Limit = defaultLimit
+	}
+	if params.Limit > maxLimit {
+		params.Limit = maxLimit
+	}
+
+	query := store.SearchQuery{
+		Text:  params.Query,
+		Limit: params.Limit,
+	}
+
+	now := time.Now()
+	var err error
+	if query.Since, err = parseTime(params.Since, now); err != nil {
+		return "", fmt.Errorf("invalid since: %w", err)
+	}
+	if query.Until, err = parseTime(params.Until, now); err != nil {
+		return "", fmt.Errorf("invalid until: %w", err)
+	}
+
+	switch params.Scope {
+	case "", "chat":
+		msg, ok := channels.MessageFromContext(ctx)
+		if !ok {
+			return "", fmt.Errorf("no current conversation to search")
+		}
+		query.ChannelName = msg.ChannelName
+		query.ChatID = msg.ChatID
+	case "all":
+		if !t.allowGlobal {
+			return "", fmt.Errorf("scope all is not permitted")
+		}
+	default:
+		return "", fmt.Errorf("unknown scope: %s", params.Scope)
+	}
+
+	t.logger.Debug("searching history",
+		"query", query.Text,
+		"channel", query.ChannelName,
+		"chat", query.ChatID)
+
+	results, err := t.searcher.Search(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("search history: %w", err)
+	}
+
+	return formatResults(results), nil
+}
+
+// formatResults renders results with full timestamps so the agent can reason
+// about when things were said.
+func formatResults(results []store.SearchResult) string {
+	if len(results) == 0 {
+		return "No matching messages found."
+	}
+
+	var b strings.Builder
+	for i, r := range results {
+		m := r.Message
+		who := m.SenderName
+		if who == "" {
+			who = m.SenderID
+		}
+		if m.Direction == store.DirectionOutgoing {
+			who = "assistant"
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s", m.Timestamp.Format(time.RFC3339), who, m.Content)
+	}
+	return b.String()
+}
+
+// parseTime parses an RFC3339 timestamp or a relative age such as "24h" or
+// "7d", measured back from now. Empty input returns the zero time.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.AddDate(0, 0, -days), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-d), nil
+}
+
+// Ensure Tool implements agent.Tool interface.
+var _ agent.Tool = (*Tool)(nil)
@@ -0,0 +1,96 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/store"
+)
+
+func TestExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	now := time.Now()
+	for _, m := range []store.Message{
+		{ID: "1", ChannelName: "telegram", ChatID: "42", SenderName: "Alice", Content: "My flight lands at 9pm", Direction: store.DirectionIncoming, Timestamp: now.Add(-48 * time.Hour)},
+		{ID: "2", ChannelName: "telegram", ChatID: "42", Content: "Noted your flight", Direction: store.DirectionOutgoing, Timestamp: now.Add(-time.Hour)},
+		{ID: "3", ChannelName: "telegram", ChatID: "99", SenderName: "Bob", Content: "Bob's flight is delayed", Direction: store.DirectionIncoming, Timestamp: now},
+	} {
+		if err := s.Append(ctx, m); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	chat := channels.WithMessage(ctx, channels.IncomingMessage{ChannelName: "telegram", ChatID: "42"})
+
+	tests := []struct {
+		name      string
+		global    bool
+		ctx       context.Context
+		args      string
+		want      []string
+		wantNot   []string
+		wantErr   bool
+		wantEmpty bool
+	}{
+		{name: "current chat", ctx: chat, args: `{"query": "flight"}`, want: []string{"Alice: My flight lands", "assistant: Noted"}, wantNot: []string{"Bob"}},
+		{name: "since", ctx: chat, args: `{"query": "flight", "since": "1d"}`, want: []string{"assistant: Noted"}, wantNot: []string{"Alice"}},
+		{name: "until", ctx: chat, args: `{"query": "flight", "until": "24h"}`, want: []string{"Alice"}, wantNot: []string{"assistant"}},
+		{name: "no match", ctx: chat, args: `{"query": "hotel"}`, wantEmpty: true},
+		{name: "all", global: true, ctx: ctx, args: `{"query": "flight", "scope": "all"}`, want: []string{"Alice", "Bob"}},
+		{name: "all not permitted", ctx: chat, args: `{"query": "flight", "scope": "all"}`, wantErr: true},
+		{name: "no conversation", ctx: ctx, args: `{"query": "flight"}`, wantErr: true},
+		{name: "no query", ctx: chat, args: `{"query": " "}`, wantErr: true},
+		{name: "bad since", ctx: chat, args: `{"query": "flight", "since": "soon"}`, wantErr: true},
+		{name: "unknown scope", ctx: chat, args: `{"query": "flight", "scope": "team"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tool, err := New(Config{Searcher: s, AllowGlobal: tt.global})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		got, err := tool.Execute(tt.ctx, json.RawMessage(tt.args))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantEmpty && got != "No matching messages found." {
+			t.Errorf("%s: got %q, want no matches", tt.name, got)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: got %q, want it to contain %q", tt.name, got, w)
+			}
+		}
+		for _, w := range tt.wantNot {
+			if strings.Contains(got, w) {
+				t.Errorf("%s: got %q, want it without %q", tt.name, got, w)
+			}
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"2025-06-01T08:00:00Z", time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC), false},
+		{"7d", now.AddDate(0, 0, -7), false},
+		{"90m", now.Add(-90 * time.Minute), false},
+		{"xd", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.in, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q) = %v, %v; want %v, err %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package transcribe turns voice messages into text, so agents can answer
+// voice notes like typed messages.
+//
+// Router middleware transcribes the voice and audio media of incoming
+// messages with a Transcriber, such as the Whisper API or a local
+// whisper.cpp server, and puts the transcript into Content:
+//
+//	pipeline := transcribe.New(transcribe.Config{
+//		Transcriber: &transcribe.Whisper{APIKey: os.Getenv("OPENAI_API_KEY")},
+//		Fetcher:     media.New(media.Config{Channels: router}),
+//	})
+//	router.Use(pipeline.Middleware())
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/media"
+)
+
+// MetadataTranscript is the IncomingMessage.Metadata key holding the
+// transcript of a transcribed message.
+const MetadataTranscript = "transcript"
+
+// Transcriber converts speech to text.
+type Transcriber interface {
+	// Transcribe returns the text spoken in audio.
+	Transcribe(ctx context.Context, audio *media.File) (string, error)
+}
+
+// Config configures a Pipeline.
+type Config struct {
+	// Transcriber transcribes the audio.
+	Transcriber Transcriber
+
+	// Fetcher downloads the audio.
+	Fetcher *media.Fetcher
+
+	// Types are the media types transcribed (default: voice and audio).
+	Types []channels.MediaType
+}
+
+// Pipeline transcribes the voice media of incoming messages.
+type Pipeline struct {
+	config Config
+}
+
+// New returns a Pipeline.
+func New(config Config) *Pipeline {
+	if config.Fetcher == nil {
+		config.Fetcher = media.New(media.Config{})
+	}
+	if len(config.Types) == 0 {
+		config.Types = []channels.MediaType{channels.MediaTypeVoice, channels.MediaTypeAudio}
+	}
+	return &Pipeline{config: config}
+}
+
+// Middleware returns router middleware that transcribes incoming voice
+// messages before they reach handlers. Messages that cannot be transcribed
+// fail, so the router's error policy applies.
+func (p *Pipeline) Middleware() channels.Middleware {
+	return func(next channels.MessageHandler) channels.MessageHandler {
+		return func(ctx context.Context, msg channels.IncomingMessage) error {
+			msg, err := p.TranscribeMessage(ctx, msg)
+			if err != nil {
+				return err
+			}
+			return next(ctx, msg)
+		}
+	}
+}
+
+// TranscribeMessage returns msg with the transcript of its voice media as
+// Content, after the caption if any, and in Metadata. Messages without
+// voice media are returned as is.
+func (p *Pipeline) TranscribeMessage(ctx context.Context, msg channels.IncomingMessage) (channels.IncomingMessage, error) {
+	var transcripts []string
+	for _, m := range msg.Media {
+		if !p.transcribes(m.Type) {
+			continue
+		}
+		file, err := p.config.Fetcher.Fetch(ctx, msg.ChannelName, m)
+		if err != nil {
+			return msg, fmt.Errorf("fetch voice message: %w", err)
+		}
+		text, err := p.config.Transcriber.Transcribe(ctx, file)
+		if err != nil {
+			return msg, fmt.Errorf("transcribe voice message: %w", err)
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			transcripts = append(transcripts, text)
+		}
+	}
+	if len(transcripts) == 0 {
+		return msg, nil
+	}
+
+	transcript := strings.Join(transcripts, "\n")
+	if msg.Content == "" {
+		msg.Content = transcript
+	} else {
+		msg.Content += "\n\n" + transcript
+	}
+	metadata := make(map[string]interface{}, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataTranscript] = transcript
+	msg.Metadata = metadata
+	return msg, nil
+}
+
+// transcribes reports whether media of type t is transcribed.
+func (p *Pipeline) transcribes(t channels.MediaType) bool {
+	for _, typ := range p.config.Types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
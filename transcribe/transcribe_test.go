@@ -0,0 +1,97 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/media"
+)
+
+// fakeTranscriber returns the audio content as its transcript.
+type fakeTranscriber struct{ err error }
+
+func (f fakeTranscriber) Transcribe(ctx context.Context, audio *media.File) (string, error) {
+	return string(audio.Data), f.err
+}
+
+func TestMiddleware(t *testing.T) {
+	pipeline := New(Config{Transcriber: fakeTranscriber{}})
+	var got channels.IncomingMessage
+	handler := pipeline.Middleware()(func(ctx context.Context, msg channels.IncomingMessage) error {
+		got = msg
+		return nil
+	})
+	voice := channels.Media{Type: channels.MediaTypeVoice, Data: []byte(" book a table for two "), MimeType: "audio/ogg"}
+
+	tests := []struct {
+		msg  channels.IncomingMessage
+		want string
+	}{
+		{channels.IncomingMessage{Media: []channels.Media{voice}}, "book a table for two"},
+		{channels.IncomingMessage{Content: "from Ann:", Media: []channels.Media{voice}}, "from Ann:\n\nbook a table for two"},
+		{channels.IncomingMessage{Content: "photo", Media: []channels.Media{{Type: channels.MediaTypeImage, Data: []byte("x")}}}, "photo"},
+	}
+	for _, tt := range tests {
+		if err := handler(context.Background(), tt.msg); err != nil {
+			t.Fatal(err)
+		}
+		if got.Content != tt.want {
+			t.Errorf("Content = %q, want %q", got.Content, tt.want)
+		}
+		if _, ok := got.Metadata[MetadataTranscript]; ok != (len(tt.msg.Media) > 0 && tt.msg.Media[0].Type == channels.MediaTypeVoice) {
+			t.Errorf("Metadata = %v", got.Metadata)
+		}
+	}
+
+	failing := New(Config{Transcriber: fakeTranscriber{err: errors.New("quota exceeded")}}).Middleware()(handler)
+	if err := failing(context.Background(), channels.IncomingMessage{Media: []channels.Media{voice}}); err == nil {
+		t.Error("failed transcription passed")
+	}
+}
+
+func TestWhisper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil || header.Filename != "audio.ogg" || r.FormValue("model") != "whisper-1" || r.FormValue("language") != "de" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		file.Close()
+		w.Write([]byte(`{"text":"Hallo Welt"}`))
+	}))
+	defer server.Close()
+
+	w := &Whisper{APIKey: "key", BaseURL: server.URL + "/v1", Language: "de"}
+	text, err := w.Transcribe(context.Background(), &media.File{Data: []byte("OggS"), MimeType: "audio/ogg"})
+	if err != nil || text != "Hallo Welt" {
+		t.Errorf("Transcribe = %q, %v", text, err)
+	}
+
+	w.APIKey = "wrong"
+	if _, err := w.Transcribe(context.Background(), &media.File{Data: []byte("OggS")}); err == nil {
+		t.Error("rejected request succeeded")
+	}
+}
+
+func TestToWAV(t *testing.T) {
+	wav := toWAV(&media.File{Data: []byte{0xff, 0x00, 0x80}, MimeType: "audio/x-mulaw;rate=8000"})
+	if wav.MimeType != "audio/wav" || len(wav.Data) != 44+2*6 || string(wav.Data[:4]) != "RIFF" {
+		t.Fatalf("toWAV = %s, %d bytes", wav.MimeType, len(wav.Data))
+	}
+	if decodeMulaw(0xff) != 0 || decodeMulaw(0x00) != -32124 || decodeMulaw(0x80) != 32124 {
+		t.Errorf("decodeMulaw = %d, %d, %d", decodeMulaw(0xff), decodeMulaw(0x00), decodeMulaw(0x80))
+	}
+
+	ogg := &media.File{Data: []byte("OggS"), MimeType: "audio/ogg"}
+	if toWAV(ogg) != ogg {
+		t.Error("toWAV converted Ogg audio")
+	}
+}
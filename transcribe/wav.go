@@ -0,0 +1,57 @@
+package transcribe
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/agentplexus/envoy/media"
+)
+
+// toWAV converts raw 8kHz mu-law audio, as sent by Twilio, to 16kHz 16-bit
+// PCM WAV, which all transcribers read. Other audio is returned as is.
+func toWAV(audio *media.File) *media.File {
+	mimeType := strings.ToLower(audio.MimeType)
+	if !strings.HasPrefix(mimeType, "audio/x-mulaw") && !strings.HasPrefix(mimeType, "audio/basic") {
+		return audio
+	}
+
+	// Decode and upsample by linear interpolation
+	samples := make([]int16, 0, 2*len(audio.Data))
+	for i, b := range audio.Data {
+		s := decodeMulaw(b)
+		next := s
+		if i+1 < len(audio.Data) {
+			next = decodeMulaw(audio.Data[i+1])
+		}
+		samples = append(samples, s, int16((int32(s)+int32(next))/2))
+	}
+
+	const rate = 16000
+	wav := make([]byte, 44, 44+2*len(samples))
+	copy(wav[0:], "RIFF")
+	binary.LittleEndian.PutUint32(wav[4:], uint32(36+2*len(samples)))
+	copy(wav[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(wav[16:], 16)
+	binary.LittleEndian.PutUint16(wav[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(wav[22:], 1) // mono
+	binary.LittleEndian.PutUint32(wav[24:], rate)
+	binary.LittleEndian.PutUint32(wav[28:], 2*rate)
+	binary.LittleEndian.PutUint16(wav[32:], 2)
+	binary.LittleEndian.PutUint16(wav[34:], 16)
+	copy(wav[36:], "data")
+	binary.LittleEndian.PutUint32(wav[40:], uint32(2*len(samples)))
+	for _, s := range samples {
+		wav = binary.LittleEndian.AppendUint16(wav, uint16(s))
+	}
+	return &media.File{Data: wav, MimeType: "audio/wav", Filename: "audio.wav"}
+}
+
+// decodeMulaw decodes a G.711 mu-law sample.
+func decodeMulaw(b byte) int16 {
+	b = ^b
+	t := (int32(b&0x0f)<<3 + 0x84) << ((b & 0x70) >> 4)
+	if b&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+	return int16(t - 0x84)
+}
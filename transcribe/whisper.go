@@ -0,0 +1,165 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/agentplexus/envoy/media"
+)
+
+// Whisper transcribes with the OpenAI audio transcription API or a
+// compatible server.
+type Whisper struct {
+	// APIKey authenticates requests.
+	APIKey string
+
+	// BaseURL is the API base URL (default: "https://api.openai.com/v1").
+	BaseURL string
+
+	// Model is the transcription model (default: "whisper-1").
+	Model string
+
+	// Language is the ISO-639-1 code of the spoken language; detected if
+	// empty.
+	Language string
+
+	// Client sends requests (default: http.DefaultClient).
+	Client *http.Client
+}
+
+// Transcribe returns the text spoken in audio.
+func (w *Whisper) Transcribe(ctx context.Context, audio *media.File) (string, error) {
+	base, model := w.BaseURL, w.Model
+	if base == "" {
+		base = "https://api.openai.com/v1"
+	}
+	if model == "" {
+		model = "whisper-1"
+	}
+	fields := map[string]string{"model": model, "response_format": "json"}
+	if w.Language != "" {
+		fields["language"] = w.Language
+	}
+	header := http.Header{}
+	if w.APIKey != "" {
+		header.Set("Authorization", "Bearer "+w.APIKey)
+	}
+	return postAudio(ctx, w.Client, strings.TrimSuffix(base, "/")+"/audio/transcriptions", header, fields, audio)
+}
+
+// WhisperCPP transcribes with a local whisper.cpp server (whisper-server).
+// The server reads 16kHz WAV unless started with --convert, which needs
+// ffmpeg for other formats such as Telegram's Ogg voice notes; Twilio call
+// audio is converted here.
+type WhisperCPP struct {
+	// URL is the server address (default: "http://127.0.0.1:8080").
+	URL string
+
+	// Language is the spoken language (default: detected).
+	Language string
+
+	// Client sends requests (default: http.DefaultClient).
+	Client *http.Client
+}
+
+// Transcribe returns the text spoken in audio.
+func (w *WhisperCPP) Transcribe(ctx context.Context, audio *media.File) (string, error) {
+	url := w.URL
+	if url == "" {
+		url = "http://127.0.0.1:8080"
+	}
+	language := w.Language
+	if language == "" {
+		language = "auto"
+	}
+	fields := map[string]string{"response_format": "json", "language": language}
+	return postAudio(ctx, w.Client, strings.TrimSuffix(url, "/")+"/inference", nil, fields, audio)
+}
+
+// postAudio uploads audio with form fields and returns the "text" of the
+// JSON response.
+func postAudio(ctx context.Context, client *http.Client, url string, header http.Header, fields map[string]string, audio *media.File) (string, error) {
+	audio = toWAV(audio)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for k, v := range fields {
+		if err := form.WriteField(k, v); err != nil {
+			return "", err
+		}
+	}
+	part, err := form.CreateFormFile("file", audioFilename(audio))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio.Data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("transcription request: %s: %s", resp.Status, msg)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode transcription: %w", err)
+	}
+	return result.Text, nil
+}
+
+// audioExtensions maps MIME types to the file extensions transcription
+// APIs detect formats by.
+var audioExtensions = map[string]string{
+	"audio/ogg":   ".ogg",
+	"audio/opus":  ".ogg",
+	"audio/mpeg":  ".mp3",
+	"audio/mp4":   ".m4a",
+	"audio/x-m4a": ".m4a",
+	"audio/aac":   ".m4a",
+	"audio/wav":   ".wav",
+	"audio/x-wav": ".wav",
+	"audio/webm":  ".webm",
+	"audio/flac":  ".flac",
+	"video/mp4":   ".mp4",
+}
+
+// audioFilename returns the name audio is uploaded as.
+func audioFilename(audio *media.File) string {
+	if audio.Filename != "" {
+		return audio.Filename
+	}
+	mimeType, _, _ := strings.Cut(audio.MimeType, ";")
+	if ext, ok := audioExtensions[strings.TrimSpace(mimeType)]; ok {
+		return "audio" + ext
+	}
+	return "audio.ogg"
+}
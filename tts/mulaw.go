@@ -0,0 +1,49 @@
+package tts
+
+import (
+	"encoding/binary"
+
+	"github.com/agentplexus/envoy/media"
+)
+
+// MimeTypeMulaw is the MIME type of FormatMulaw audio.
+const MimeTypeMulaw = "audio/x-mulaw;rate=8000"
+
+// pcmToMulaw converts 16-bit little-endian mono PCM at rate, a multiple of
+// 8kHz, to 8kHz mu-law, averaging the samples dropped.
+func pcmToMulaw(pcm []byte, rate int) *media.File {
+	step := rate / 8000
+	n := len(pcm) / 2 / step
+	out := make([]byte, n)
+	for i := range out {
+		var sum int32
+		for j := 0; j < step; j++ {
+			sum += int32(int16(binary.LittleEndian.Uint16(pcm[2*(i*step+j):])))
+		}
+		out[i] = encodeMulaw(int16(sum / int32(step)))
+	}
+	return &media.File{Data: out, MimeType: MimeTypeMulaw, Filename: "voice.ulaw"}
+}
+
+// encodeMulaw encodes a G.711 mu-law sample.
+func encodeMulaw(s int16) byte {
+	const (
+		bias = 0x84
+		clip = 32635
+	)
+	x := int32(s)
+	var sign byte
+	if x < 0 {
+		x, sign = -x, 0x80
+	}
+	if x > clip {
+		x = clip
+	}
+	x += bias
+	exponent := byte(7)
+	for mask := int32(0x4000); x&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := byte(x>>(exponent+3)) & 0x0f
+	return ^(sign | exponent<<4 | mantissa)
+}
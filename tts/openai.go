@@ -0,0 +1,113 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/agentplexus/envoy/media"
+)
+
+// OpenAI synthesizes speech with the OpenAI speech API or a compatible
+// server.
+type OpenAI struct {
+	// APIKey authenticates requests.
+	APIKey string
+
+	// BaseURL is the API base URL (default: "https://api.openai.com/v1").
+	BaseURL string
+
+	// Model is the speech model (default: "tts-1").
+	Model string
+
+	// Voice is the voice (default: "alloy").
+	Voice string
+
+	// Speed is the speaking speed from 0.25 to 4 (default: 1).
+	Speed float64
+
+	// Client sends requests (default: http.DefaultClient).
+	Client *http.Client
+}
+
+// openAIFormats maps formats to the API's response formats and the MIME
+// types and file names of the audio returned.
+var openAIFormats = map[Format]struct{ format, mimeType, filename string }{
+	FormatOpus: {"opus", "audio/ogg", "voice.ogg"},
+	FormatMP3:  {"mp3", "audio/mpeg", "voice.mp3"},
+	FormatWAV:  {"wav", "audio/wav", "voice.wav"},
+	// Converted from 24kHz PCM
+	FormatMulaw: {"pcm", "", ""},
+}
+
+// Synthesize returns text spoken in format.
+func (o *OpenAI) Synthesize(ctx context.Context, text string, format Format) (*media.File, error) {
+	f, ok := openAIFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audio format %q", format)
+	}
+	base, model, voice := o.BaseURL, o.Model, o.Voice
+	if base == "" {
+		base = "https://api.openai.com/v1"
+	}
+	if model == "" {
+		model = "tts-1"
+	}
+	if voice == "" {
+		voice = "alloy"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model":           model,
+		"input":           text,
+		"voice":           voice,
+		"response_format": f.format,
+		"speed":           speed(o.Speed),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("speech request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("speech request: %s: %s", resp.Status, msg)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read speech: %w", err)
+	}
+
+	if format == FormatMulaw {
+		return pcmToMulaw(data, 24000), nil
+	}
+	return &media.File{Data: data, MimeType: f.mimeType, Filename: f.filename}, nil
+}
+
+// speed returns the speaking speed to request.
+func speed(s float64) float64 {
+	if s <= 0 {
+		return 1
+	}
+	return s
+}
@@ -0,0 +1,195 @@
+// Package tts speaks agent responses, so voice notes are answered with
+// voice and phone calls can be answered at all.
+//
+// A router response hook synthesizes the response with a Synthesizer, such
+// as the OpenAI speech API, and attaches the audio as MediaTypeVoice when
+// the incoming message was a voice note or its chat has voice replies on:
+//
+//	speech := tts.New(tts.Config{
+//		Synthesizer: &tts.OpenAI{APIKey: os.Getenv("OPENAI_API_KEY")},
+//		Chats:       []string{"telegram:12345"},
+//	})
+//	router := channels.NewRouter(logger, channels.WithResponseHook(speech.Hook()))
+package tts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/markup"
+	"github.com/agentplexus/envoy/media"
+)
+
+// Format is an audio format.
+type Format string
+
+const (
+	// FormatOpus is Ogg Opus, played inline as a Telegram voice note.
+	FormatOpus Format = "opus"
+
+	// FormatMP3 is MP3.
+	FormatMP3 Format = "mp3"
+
+	// FormatWAV is 16-bit PCM WAV.
+	FormatWAV Format = "wav"
+
+	// FormatMulaw is raw 8kHz mu-law, played into Twilio calls.
+	FormatMulaw Format = "mulaw"
+)
+
+// DefaultMaxLength is the default limit on the characters spoken, the
+// OpenAI speech API's input limit.
+const DefaultMaxLength = 4096
+
+// defaultFormats are the formats of channels whose voice messages need one.
+var defaultFormats = map[string]Format{
+	"telegram": FormatOpus,
+	"twilio":   FormatMulaw,
+}
+
+// Synthesizer converts text to speech.
+type Synthesizer interface {
+	// Synthesize returns text spoken in format.
+	Synthesize(ctx context.Context, text string, format Format) (*media.File, error)
+}
+
+// Config configures a Speaker.
+type Config struct {
+	// Synthesizer speaks the responses.
+	Synthesizer Synthesizer
+
+	// Chats are the chats answered with voice regardless of the incoming
+	// message, as "<channel>:<chat>" keys.
+	Chats []string
+
+	// Enabled reports whether a chat is answered with voice regardless of
+	// the incoming message, e.g. from a chat variable. It is consulted
+	// for chats not in Chats.
+	Enabled func(ctx context.Context, msg channels.IncomingMessage) (bool, error)
+
+	// Formats are the audio formats by channel name, added to the
+	// defaults: FormatOpus for Telegram and FormatMulaw for Twilio. Other
+	// channels get FormatMP3.
+	Formats map[string]Format
+
+	// VoiceOnly drops the text of voice replies.
+	VoiceOnly bool
+
+	// MaxLength limits the characters spoken (default:
+	// DefaultMaxLength). Longer responses are cut at the last sentence
+	// that fits.
+	MaxLength int
+
+	Logger *slog.Logger
+}
+
+// Speaker attaches spoken versions of agent responses.
+type Speaker struct {
+	config  Config
+	chats   map[string]bool
+	formats map[string]Format
+}
+
+// New returns a Speaker.
+func New(config Config) *Speaker {
+	if config.MaxLength <= 0 {
+		config.MaxLength = DefaultMaxLength
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	s := &Speaker{config: config, chats: make(map[string]bool), formats: make(map[string]Format)}
+	for _, chat := range config.Chats {
+		s.chats[chat] = true
+	}
+	for name, format := range defaultFormats {
+		s.formats[name] = format
+	}
+	for name, format := range config.Formats {
+		s.formats[name] = format
+	}
+	return s
+}
+
+// Hook returns a router response hook that speaks responses to voice
+// notes and to chats with voice replies on. Responses that cannot be
+// synthesized are logged and sent as text.
+func (s *Speaker) Hook() channels.ResponseHook {
+	return func(ctx context.Context, msg channels.IncomingMessage, response channels.OutgoingMessage) (channels.OutgoingMessage, error) {
+		speak, err := s.speaks(ctx, msg)
+		if err != nil {
+			s.config.Logger.Error("voice reply check failed", "channel", msg.ChannelName, "chat", msg.ChatID, "error", err)
+			return response, nil
+		}
+		if !speak || strings.TrimSpace(response.Content) == "" {
+			return response, nil
+		}
+		voice, err := s.Speak(ctx, msg.ChannelName, response.Content)
+		if err != nil {
+			s.config.Logger.Error("speech synthesis failed, replying with text", "channel", msg.ChannelName, "chat", msg.ChatID, "error", err)
+			return response, nil
+		}
+		response.Media = append(response.Media, voice)
+		if s.config.VoiceOnly {
+			response.Content = ""
+		}
+		return response, nil
+	}
+}
+
+// Speak synthesizes text as voice media for the named channel. Markdown is
+// removed before speaking.
+func (s *Speaker) Speak(ctx context.Context, channelName, text string) (channels.Media, error) {
+	format, ok := s.formats[channelName]
+	if !ok {
+		format = FormatMP3
+	}
+	text = truncate(markup.Plain.Render(markup.Parse(text)), s.config.MaxLength)
+	file, err := s.config.Synthesizer.Synthesize(ctx, text, format)
+	if err != nil {
+		return channels.Media{}, fmt.Errorf("synthesize speech: %w", err)
+	}
+	return channels.Media{
+		Type:     channels.MediaTypeVoice,
+		Data:     file.Data,
+		MimeType: file.MimeType,
+		Filename: file.Filename,
+		Size:     int64(len(file.Data)),
+	}, nil
+}
+
+// speaks reports whether the response to msg is spoken.
+func (s *Speaker) speaks(ctx context.Context, msg channels.IncomingMessage) (bool, error) {
+	for _, m := range msg.Media {
+		if m.Type == channels.MediaTypeVoice {
+			return true, nil
+		}
+	}
+	if s.chats[channels.SessionID(msg.ChannelName, msg.ChatID)] {
+		return true, nil
+	}
+	if s.config.Enabled == nil {
+		return false, nil
+	}
+	return s.config.Enabled(ctx, msg)
+}
+
+// truncate cuts text to at most max characters, at the end of the last
+// sentence that fits if there is one.
+func truncate(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	cut := []rune(text)[:max]
+	for i := len(cut) - 1; i > 0; i-- {
+		if strings.ContainsRune(".!?\n", cut[i]) {
+			return string(cut[:i+1])
+		}
+	}
+	return string(cut)
+}
@@ -0,0 +1,153 @@
+package tts
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/media"
+)
+
+// fakeSynthesizer returns the text and format as audio.
+type fakeSynthesizer struct{}
+
+func (fakeSynthesizer) Synthesize(ctx context.Context, text string, format Format) (*media.File, error) {
+	return &media.File{Data: []byte(text), MimeType: string(format)}, nil
+}
+
+func TestHook(t *testing.T) {
+	speaker := New(Config{Synthesizer: fakeSynthesizer{}, Chats: []string{"discord:loud"}})
+	hook := speaker.Hook()
+	voice := []channels.Media{{Type: channels.MediaTypeVoice, FileID: "f1"}}
+	response := channels.OutgoingMessage{Content: "**Booked** for two."}
+
+	tests := []struct {
+		msg    channels.IncomingMessage
+		format string
+	}{
+		{channels.IncomingMessage{ChannelName: "telegram", ChatID: "1", Media: voice}, "opus"},
+		{channels.IncomingMessage{ChannelName: "twilio", ChatID: "CA1", Media: voice}, "mulaw"},
+		{channels.IncomingMessage{ChannelName: "discord", ChatID: "loud", Content: "book"}, "mp3"},
+		{channels.IncomingMessage{ChannelName: "discord", ChatID: "quiet", Content: "book"}, ""},
+	}
+	for _, tt := range tests {
+		out, err := hook(context.Background(), tt.msg, response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Content != response.Content {
+			t.Errorf("%s content = %q", tt.msg.ChatID, out.Content)
+		}
+		if tt.format == "" {
+			if len(out.Media) != 0 {
+				t.Errorf("%s media = %+v, want none", tt.msg.ChatID, out.Media)
+			}
+			continue
+		}
+		if len(out.Media) != 1 || out.Media[0].Type != channels.MediaTypeVoice ||
+			out.Media[0].MimeType != tt.format || string(out.Media[0].Data) != "Booked for two." {
+			t.Errorf("%s media = %+v, want %s voice", tt.msg.ChatID, out.Media, tt.format)
+		}
+	}
+
+	speaker = New(Config{
+		Synthesizer: fakeSynthesizer{},
+		VoiceOnly:   true,
+		Enabled: func(ctx context.Context, msg channels.IncomingMessage) (bool, error) {
+			return msg.ChatID == "on", nil
+		},
+	})
+	out, err := speaker.Hook()(context.Background(), channels.IncomingMessage{ChannelName: "discord", ChatID: "on"}, response)
+	if err != nil || out.Content != "" || len(out.Media) != 1 {
+		t.Errorf("voice-only reply = %+v, %v", out, err)
+	}
+}
+
+// failingSynthesizer is a speech provider outage.
+type failingSynthesizer struct{}
+
+func (failingSynthesizer) Synthesize(ctx context.Context, text string, format Format) (*media.File, error) {
+	return nil, errors.New("provider unavailable")
+}
+
+func TestHookSynthesisFailure(t *testing.T) {
+	speaker := New(Config{Synthesizer: failingSynthesizer{}, VoiceOnly: true})
+	msg := channels.IncomingMessage{ChannelName: "telegram", ChatID: "1", Media: []channels.Media{{Type: channels.MediaTypeVoice}}}
+	response := channels.OutgoingMessage{Content: "Booked for two."}
+
+	out, err := speaker.Hook()(context.Background(), msg, response)
+	if err != nil {
+		t.Fatalf("hook failed: %v", err)
+	}
+	if out.Content != response.Content || len(out.Media) != 0 {
+		t.Errorf("reply = %+v, want the text unchanged", out)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"Short.", 10, "Short."},
+		{"First one. Second one.", 15, "First one."},
+		{"no sentence end here", 8, "no sente"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAI(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/speech" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		got = nil
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if got["response_format"] == "pcm" {
+			// 24kHz silence, then a loud sample three times
+			pcm := make([]byte, 12)
+			for i := 6; i < 12; i += 2 {
+				binary.LittleEndian.PutUint16(pcm[i:], 32767)
+			}
+			w.Write(pcm)
+			return
+		}
+		w.Write([]byte("OggS"))
+	}))
+	defer server.Close()
+
+	synth := &OpenAI{APIKey: "key", BaseURL: server.URL + "/v1/", Voice: "nova"}
+	file, err := synth.Synthesize(context.Background(), "Hello", FormatOpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(file.Data) != "OggS" || file.MimeType != "audio/ogg" || got["voice"] != "nova" || got["model"] != "tts-1" || got["input"] != "Hello" {
+		t.Errorf("file = %+v, request = %v", file, got)
+	}
+
+	file, err = synth.Synthesize(context.Background(), "Hello", FormatMulaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(file.Data) != "\xff\x80" || file.MimeType != MimeTypeMulaw {
+		t.Errorf("mulaw = %x, %s", file.Data, file.MimeType)
+	}
+
+	if _, err := synth.Synthesize(context.Background(), "Hello", "flac"); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Synthesize(flac) = %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package verify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store persists pending challenges.
+type Store interface {
+	// Save creates or replaces a challenge.
+	Save(ctx context.Context, c *Challenge) error
+
+	// Load returns a challenge, or ErrNotFound.
+	Load(ctx context.Context, id string) (*Challenge, error)
+
+	// Attempt atomically increments a challenge's attempt count and returns
+	// the new count, or ErrNotFound.
+	Attempt(ctx context.Context, id string) (int, error)
+
+	// Delete removes a challenge. Deleting a missing challenge is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is an in-memory Store for single-process deployments.
+type MemoryStore struct {
+	challenges map[string]Challenge
+	mu         sync.Mutex
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{challenges: make(map[string]Challenge)}
+}
+
+// Save creates or replaces a challenge, discarding expired ones.
+func (s *MemoryStore) Save(_ context.Context, c *Challenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range s.challenges {
+		if now.After(existing.ExpiresAt) {
+			delete(s.challenges, id)
+		}
+	}
+	s.challenges[c.ID] = *c
+	return nil
+}
+
+// Load returns a copy of a challenge.
+func (s *MemoryStore) Load(_ context.Context, id string) (*Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.challenges[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+// Attempt increments a challenge's attempt count.
+func (s *MemoryStore) Attempt(_ context.Context, id string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.challenges[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	c.Attempts++
+	s.challenges[id] = c
+	return c.Attempts, nil
+}
+
+// Delete removes a challenge.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.challenges, id)
+	return nil
+}
+
+// Ensure MemoryStore implements Store.
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,248 @@
+// Package verify issues and checks one-time verification codes delivered
+// over a messaging channel. It is intended for identity linking, new-member
+// verification, and application-level auth flows.
+package verify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// Verification errors.
+var (
+	ErrNotFound        = errors.New("verification not found")
+	ErrExpired         = errors.New("verification code expired")
+	ErrInvalidCode     = errors.New("invalid verification code")
+	ErrTooManyAttempts = errors.New("too many verification attempts")
+)
+
+// DefaultTemplate is the default message used to deliver a code. %s is
+// replaced with the code.
+const DefaultTemplate = "Your verification code is %s"
+
+// Target identifies where a code is delivered.
+type Target struct {
+	Channel string
+	ChatID  string
+}
+
+// Challenge is an issued verification code awaiting confirmation. The code
+// itself is never stored, only its HMAC.
+type Challenge struct {
+	ID        string
+	Target    Target
+	Purpose   string
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+// Config configures a Verifier.
+type Config struct {
+	// Sender delivers codes.
+	Sender channels.Sender
+
+	// Store holds pending challenges (default: in-memory).
+	Store Store
+
+	// CodeLength is the number of digits in a code (default: 6).
+	CodeLength int
+
+	// TTL is how long a code is valid (default: 10m).
+	TTL time.Duration
+
+	// MaxAttempts is how many wrong codes are accepted before the challenge
+	// is invalidated (default: 5).
+	MaxAttempts int
+
+	// Template formats the delivered message (default: DefaultTemplate).
+	Template string
+
+	// Key signs stored code hashes. Verifiers sharing a Store must share
+	// the key (default: random per process).
+	Key []byte
+
+	Logger *slog.Logger
+}
+
+// Verifier issues and checks verification codes.
+type Verifier struct {
+	sender      channels.Sender
+	store       Store
+	codeLength  int
+	ttl         time.Duration
+	maxAttempts int
+	template    string
+	key         []byte
+	logger      *slog.Logger
+	now         func() time.Time
+}
+
+// New creates a new Verifier.
+func New(config Config) (*Verifier, error) {
+	if config.Sender == nil {
+		return nil, errors.New("sender is required")
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.CodeLength == 0 {
+		config.CodeLength = 6
+	}
+	if config.TTL == 0 {
+		config.TTL = 10 * time.Minute
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 5
+	}
+	if config.Template == "" {
+		config.Template = DefaultTemplate
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	key := config.Key
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+	}
+
+	return &Verifier{
+		sender:      config.Sender,
+		store:       config.Store,
+		codeLength:  config.CodeLength,
+		ttl:         config.TTL,
+		maxAttempts: config.MaxAttempts,
+		template:    config.Template,
+		key:         key,
+		logger:      config.Logger,
+		now:         time.Now,
+	}, nil
+}
+
+// Start generates a code, delivers it to target, and returns the challenge
+// ID to pass to Verify. Purpose scopes the code (e.g., "link-account") so a
+// code issued for one flow cannot satisfy another.
+func (v *Verifier) Start(ctx context.Context, target Target, purpose string) (string, error) {
+	code, err := v.generateCode()
+	if err != nil {
+		return "", err
+	}
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := &Challenge{
+		ID:        id,
+		Target:    target,
+		Purpose:   purpose,
+		CodeHash:  v.hash(id, code),
+		ExpiresAt: v.now().Add(v.ttl),
+	}
+	if err := v.store.Save(ctx, challenge); err != nil {
+		return "", fmt.Errorf("save challenge: %w", err)
+	}
+
+	err = v.sender.Send(ctx, target.Channel, target.ChatID, channels.OutgoingMessage{
+		Content:        fmt.Sprintf(v.template, code),
+		IdempotencyKey: "verify:" + id,
+	})
+	if err != nil {
+		_ = v.store.Delete(ctx, id)
+		return "", fmt.Errorf("deliver code: %w", err)
+	}
+
+	v.logger.Info("verification code sent",
+		"channel", target.Channel,
+		"chat", target.ChatID,
+		"purpose", purpose)
+	return id, nil
+}
+
+// Verify checks a code for a challenge issued for purpose. On success the
+// challenge is consumed and its target is returned.
+func (v *Verifier) Verify(ctx context.Context, id, purpose, code string) (Target, error) {
+	challenge, err := v.store.Load(ctx, id)
+	if err != nil {
+		return Target{}, err
+	}
+	if challenge.Purpose != purpose {
+		return Target{}, ErrNotFound
+	}
+
+	if v.now().After(challenge.ExpiresAt) {
+		_ = v.store.Delete(ctx, id)
+		return Target{}, ErrExpired
+	}
+
+	// Count the attempt before checking the code so concurrent guesses
+	// cannot exceed the limit
+	attempts, err := v.store.Attempt(ctx, id)
+	if err != nil {
+		return Target{}, err
+	}
+	if attempts > v.maxAttempts {
+		_ = v.store.Delete(ctx, id)
+		return Target{}, ErrTooManyAttempts
+	}
+
+	code = strings.TrimSpace(code)
+	if !hmac.Equal([]byte(challenge.CodeHash), []byte(v.hash(id, code))) {
+		if attempts >= v.maxAttempts {
+			_ = v.store.Delete(ctx, id)
+			return Target{}, ErrTooManyAttempts
+		}
+		return Target{}, ErrInvalidCode
+	}
+
+	if err := v.store.Delete(ctx, id); err != nil {
+		return Target{}, fmt.Errorf("delete challenge: %w", err)
+	}
+	return challenge.Target, nil
+}
+
+// generateCode returns a random numeric code.
+func (v *Verifier) generateCode() (string, error) {
+	digits := make([]byte, v.codeLength)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("generate code: %w", err)
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
+}
+
+// hash returns the HMAC of a code bound to its challenge ID.
+func (v *Verifier) hash(id, code string) string {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(id))
+	mac.Write([]byte{0})
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomID returns a random challenge ID.
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
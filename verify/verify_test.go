@@ -0,0 +1,130 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/envoy/internal/channelstest"
+)
+
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+// lastCode returns the code in the last message sent.
+func lastCode(t *testing.T, sender *channelstest.Sender) string {
+	t.Helper()
+	code := codePattern.FindString(sender.Last(""))
+	if code == "" {
+		t.Fatalf("no code in %q", sender.Last(""))
+	}
+	return code
+}
+
+func TestVerifySuccess(t *testing.T) {
+	sender := &channelstest.Sender{}
+	v, err := New(Config{Sender: sender})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+	target := Target{Channel: "telegram", ChatID: "42"}
+
+	id, err := v.Start(ctx, target, "link")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	code := lastCode(t, sender)
+
+	if _, err := v.Verify(ctx, id, "other", code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wrong purpose: err = %v, want ErrNotFound", err)
+	}
+	got, err := v.Verify(ctx, id, "link", code)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got != target {
+		t.Errorf("target = %+v, want %+v", got, target)
+	}
+	if _, err := v.Verify(ctx, id, "link", code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("reuse: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVerifyAttemptLimit(t *testing.T) {
+	sender := &channelstest.Sender{}
+	v, _ := New(Config{Sender: sender, MaxAttempts: 2})
+	ctx := context.Background()
+
+	id, _ := v.Start(ctx, Target{Channel: "discord", ChatID: "1"}, "login")
+	code := lastCode(t, sender)
+
+	if _, err := v.Verify(ctx, id, "login", "wrong"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("err = %v, want ErrInvalidCode", err)
+	}
+	if _, err := v.Verify(ctx, id, "login", "wrong"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("err = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := v.Verify(ctx, id, "login", code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("after lockout: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	sender := &channelstest.Sender{}
+	v, _ := New(Config{Sender: sender, TTL: time.Minute})
+	now := time.Now()
+	v.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	id, _ := v.Start(ctx, Target{Channel: "discord", ChatID: "1"}, "login")
+	code := lastCode(t, sender)
+
+	now = now.Add(2 * time.Minute)
+	if _, err := v.Verify(ctx, id, "login", code); !errors.Is(err, ErrExpired) {
+		t.Errorf("err = %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifySharedKey(t *testing.T) {
+	sender := &channelstest.Sender{}
+	store := NewMemoryStore()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	issuer, _ := New(Config{Sender: sender, Store: store, Key: key})
+	checker, _ := New(Config{Sender: sender, Store: store, Key: key})
+	ctx := context.Background()
+
+	id, err := issuer.Start(ctx, Target{Channel: "discord", ChatID: "1"}, "login")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := checker.Verify(ctx, id, "login", lastCode(t, sender)); err != nil {
+		t.Errorf("Verify on another replica failed: %v", err)
+	}
+}
+
+func TestVerifyConcurrentAttempts(t *testing.T) {
+	sender := &channelstest.Sender{}
+	v, _ := New(Config{Sender: sender, MaxAttempts: 3})
+	ctx := context.Background()
+	id, _ := v.Start(ctx, Target{Channel: "discord", ChatID: "1"}, "login")
+
+	var wg sync.WaitGroup
+	var invalid atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(ctx, id, "login", "wrong"); errors.Is(err, ErrInvalidCode) {
+				invalid.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := invalid.Load(); got > 2 {
+		t.Errorf("%d guesses checked, want at most 2 before lockout", got)
+	}
+}
@@ -0,0 +1,57 @@
+package vision
+
+import (
+	"encoding/base64"
+
+	"github.com/agentplexus/envoy/channels"
+)
+
+// DataURL returns an image as a base64 data URL, or its URL if it has no
+// data.
+func DataURL(img channels.Image) string {
+	if len(img.Data) == 0 {
+		return img.URL
+	}
+	mimeType := img.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// OpenAIContent returns text and images as the content parts of an OpenAI
+// chat completion user message.
+func OpenAIContent(text string, images []channels.Image) []map[string]interface{} {
+	parts := make([]map[string]interface{}, 0, len(images)+1)
+	for _, img := range images {
+		parts = append(parts, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": DataURL(img)},
+		})
+	}
+	if text != "" {
+		parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+	}
+	return parts
+}
+
+// AnthropicContent returns text and images as the content blocks of an
+// Anthropic Messages API user message. Images come first, as recommended.
+func AnthropicContent(text string, images []channels.Image) []map[string]interface{} {
+	blocks := make([]map[string]interface{}, 0, len(images)+1)
+	for _, img := range images {
+		source := map[string]interface{}{"type": "url", "url": img.URL}
+		if len(img.Data) > 0 {
+			source = map[string]interface{}{
+				"type":       "base64",
+				"media_type": img.MimeType,
+				"data":       base64.StdEncoding.EncodeToString(img.Data),
+			}
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "image", "source": source})
+	}
+	if text != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+	}
+	return blocks
+}
@@ -0,0 +1,169 @@
+// Package vision prepares image attachments for vision-capable agents:
+// it downloads them, downscales them to the size models work at, and
+// encodes them for model APIs.
+//
+// The router passes images to agents implementing
+// channels.MultimodalAgentProcessor; Loader makes it fetch platform files,
+// such as Telegram photos, and downscale them first:
+//
+//	router.SetImageLoader(vision.Loader(vision.Config{
+//		Fetcher: media.New(media.Config{Channels: router}),
+//	}))
+package vision
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"path"
+	"strings"
+
+	// Register GIF decoding
+	_ "image/gif"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/media"
+)
+
+// DefaultMaxDimension is the default limit on the longer side of images
+// passed to agents. Larger images cost more tokens without helping most
+// models, which downscale them anyway.
+const DefaultMaxDimension = 1568
+
+// DefaultQuality is the default JPEG quality of downscaled images.
+const DefaultQuality = 85
+
+// MaxPixels is the largest image, in pixels, Downscale decodes. Images
+// compress well enough that a small file can declare a frame many
+// gigabytes in size.
+const MaxPixels = 50_000_000
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels.
+var ErrTooLarge = errors.New("image too large")
+
+// Config configures Loader.
+type Config struct {
+	// Fetcher downloads the images.
+	Fetcher *media.Fetcher
+
+	// MaxDimension limits the longer side of images in pixels (default:
+	// DefaultMaxDimension).
+	MaxDimension int
+
+	// Quality is the JPEG quality of downscaled images (default:
+	// DefaultQuality).
+	Quality int
+}
+
+// Loader returns an image loader for Router.SetImageLoader that
+// downloads images and downscales them to config.MaxDimension.
+func Loader(config Config) channels.ImageLoader {
+	if config.Fetcher == nil {
+		config.Fetcher = media.New(media.Config{})
+	}
+	return func(ctx context.Context, channelName string, m channels.Media) (channels.Image, error) {
+		file, err := config.Fetcher.Fetch(ctx, channelName, m)
+		if err != nil {
+			return channels.Image{}, err
+		}
+		if file, err = Downscale(file, config.MaxDimension, config.Quality); err != nil {
+			return channels.Image{}, err
+		}
+		return channels.Image{Data: file.Data, MimeType: file.MimeType}, nil
+	}
+}
+
+// Downscale shrinks an image whose longer side exceeds maxDimension
+// (default: DefaultMaxDimension), keeping its aspect ratio. Shrunk images
+// are encoded as JPEG at quality (default: DefaultQuality), or as PNG if
+// they are transparent. Images that fit, and formats other than JPEG, PNG,
+// and GIF, are returned as is. Images over MaxPixels are rejected with
+// ErrTooLarge before they are decoded.
+func Downscale(file *media.File, maxDimension, quality int) (*media.File, error) {
+	if maxDimension <= 0 {
+		maxDimension = DefaultMaxDimension
+	}
+	if quality <= 0 {
+		quality = DefaultQuality
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(file.Data))
+	if errors.Is(err, image.ErrFormat) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if config.Width <= maxDimension && config.Height <= maxDimension {
+		return file, nil
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(file.Data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	width, height := config.Width, config.Height
+	if width >= height {
+		width, height = maxDimension, max(1, height*maxDimension/width)
+	} else {
+		width, height = max(1, width*maxDimension/height), maxDimension
+	}
+	dst := resize(src, width, height)
+
+	var buf bytes.Buffer
+	out := &media.File{MimeType: "image/jpeg"}
+	ext := ".jpg"
+	if dst.Opaque() {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, dst)
+		out.MimeType, ext = "image/png", ".png"
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	out.Data = buf.Bytes()
+	if file.Filename != "" {
+		out.Filename = strings.TrimSuffix(file.Filename, path.Ext(file.Filename)) + ext
+	}
+	return out, nil
+}
+
+// resize scales src to width by height, averaging the source pixels each
+// destination pixel covers.
+func resize(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*b.Dy()/height, max((y+1)*b.Dy()/height, y*b.Dy()/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*b.Dx()/width, max((x+1)*b.Dx()/width, x*b.Dx()/width+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[4*sx+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,127 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/agentplexus/envoy/channels"
+	"github.com/agentplexus/envoy/media"
+)
+
+// pngFile returns a width by height PNG, left half black and right half
+// white, with alpha if transparent.
+func pngFile(t *testing.T, width, height int, transparent bool) *media.File {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{A: 255}
+			if x >= width/2 {
+				c = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			if transparent && y == 0 {
+				c.A = 0
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return &media.File{Data: buf.Bytes(), MimeType: "image/png", Filename: "photo.png"}
+}
+
+func TestDownscale(t *testing.T) {
+	small := pngFile(t, 40, 20, false)
+	if got, err := Downscale(small, 40, 0); err != nil || got != small {
+		t.Errorf("Downscale(fits) = %v, %v, want unchanged", got, err)
+	}
+	text := &media.File{Data: []byte("not an image"), MimeType: "image/webp"}
+	if got, err := Downscale(text, 10, 0); err != nil || got != text {
+		t.Errorf("Downscale(unknown format) = %v, %v, want unchanged", got, err)
+	}
+
+	got, err := Downscale(pngFile(t, 400, 100, false), 40, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(got.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || got.MimeType != "image/jpeg" || got.Filename != "photo.jpg" || img.Bounds().Dx() != 40 || img.Bounds().Dy() != 10 {
+		t.Errorf("downscaled to %s %s %v", got.MimeType, got.Filename, img.Bounds())
+	}
+	if r, _, _, _ := img.At(5, 5).RGBA(); r > 0x1000 {
+		t.Errorf("left pixel = %v, want black", img.At(5, 5))
+	}
+	if r, _, _, _ := img.At(35, 5).RGBA(); r < 0xf000 {
+		t.Errorf("right pixel = %v, want white", img.At(35, 5))
+	}
+
+	got, err = Downscale(pngFile(t, 100, 400, true), 40, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MimeType != "image/png" || !bytes.HasPrefix(got.Data, []byte("\x89PNG")) {
+		t.Errorf("transparent image encoded as %s", got.MimeType)
+	}
+}
+
+func TestDownscaleRejectsBombs(t *testing.T) {
+	// A valid PNG whose header declares a 50000x50000 frame
+	file := pngFile(t, 2, 2, false)
+	data := append([]byte(nil), file.Data...)
+	ihdr := data[12:29]
+	binary.BigEndian.PutUint32(ihdr[4:], 50000)
+	binary.BigEndian.PutUint32(ihdr[8:], 50000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(ihdr))
+
+	if _, err := Downscale(&media.File{Data: data}, 0, 0); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	photo := pngFile(t, 300, 300, false)
+	load := Loader(Config{MaxDimension: 100})
+	img, err := load(context.Background(), "discord", channels.Media{Type: channels.MediaTypeImage, Data: photo.Data, MimeType: "image/png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(img.Data)); err != nil || config.Width != 100 || img.MimeType != "image/jpeg" {
+		t.Errorf("loaded %s %+v, %v", img.MimeType, config, err)
+	}
+}
+
+func TestContent(t *testing.T) {
+	images := []channels.Image{{Data: []byte("img"), MimeType: "image/png"}, {URL: "https://example.com/a.jpg"}}
+	if got := DataURL(images[0]); got != "data:image/png;base64,aW1n" {
+		t.Errorf("DataURL = %q", got)
+	}
+
+	parts := OpenAIContent("What is this?", images)
+	if len(parts) != 3 || parts[1]["image_url"].(map[string]interface{})["url"] != "https://example.com/a.jpg" || parts[2]["text"] != "What is this?" {
+		t.Errorf("OpenAIContent = %v", parts)
+	}
+
+	blocks := AnthropicContent("", images)
+	if len(blocks) != 2 {
+		t.Fatalf("AnthropicContent = %v", blocks)
+	}
+	if source := blocks[0]["source"].(map[string]interface{}); source["type"] != "base64" || source["data"] != "aW1n" || source["media_type"] != "image/png" {
+		t.Errorf("base64 source = %v", source)
+	}
+	if source := blocks[1]["source"].(map[string]interface{}); source["type"] != "url" || !strings.HasSuffix(source["url"].(string), "a.jpg") {
+		t.Errorf("url source = %v", source)
+	}
+}